@@ -6,6 +6,8 @@ import (
     "testing"
     "time"
 
+    "github.com/hashicorp/terraform-plugin-framework/providerserver"
+    "github.com/hashicorp/terraform-plugin-go/tfprotov6"
     "github.com/hashicorp/terraform-plugin-testing/helper/resource"
     "abstract-provider/provider"
 )
@@ -18,8 +20,8 @@ func TestAccBucketAWS(t *testing.T) {
     name := fmt.Sprintf("tf-acc-%d", time.Now().UnixNano())
 
     resource.Test(t, resource.TestCase{
-        ProtoV6ProviderFactories: map[string]func() (resource.Provider, error){
-            "abstract": provider.New,
+        ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+            "abstract": providerserver.NewProtocol6WithError(provider.New()),
         },
         Steps: []resource.TestStep{
             {
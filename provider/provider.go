@@ -2,46 +2,78 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 
 	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appservice/armappservice"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/cdn/armcdn"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
 	ci "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerinstance/armcontainerinstance"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/msi/armmsi"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mysql/armmysqlflexibleservers"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/postgresql/armpostgresqlflexibleservers"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/servicebus/armservicebus"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 	cloudfunctions "google.golang.org/api/cloudfunctions/v1"
+	cloudfunctionsv2 "google.golang.org/api/cloudfunctions/v2"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	crm "google.golang.org/api/cloudresourcemanager/v1"
 	compute "google.golang.org/api/compute/v1"
 	container "google.golang.org/api/container/v1"
 	dnsapi "google.golang.org/api/dns/v1"
+	iamapi "google.golang.org/api/iam/v1"
+	memcache "google.golang.org/api/memcache/v1"
+	monitoring "google.golang.org/api/monitoring/v1"
+	monitoringv3 "google.golang.org/api/monitoring/v3"
 	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+	redis "google.golang.org/api/redis/v1"
+	run "google.golang.org/api/run/v2"
 	secretmanager "google.golang.org/api/secretmanager/v1"
+	servicenetworking "google.golang.org/api/servicenetworking/v1"
 	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	htransport "google.golang.org/api/transport/http"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	pschema "github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 
 	"abstract-provider/provider/resources"
 	"abstract-provider/provider/shared"
@@ -59,16 +91,30 @@ type abstractProvider struct {
 	elb     *elasticloadbalancingv2.Client
 	route53 *route53.Client
 	secrets *secretsmanager.Client
+	cdn     *cloudfront.Client
+	sns     *sns.Client
+	cw      *cloudwatch.Client
+	iam     *iam.Client
+	cache   *elasticache.Client
+	kms     *kms.Client
 
 	azureRG         *armresources.ResourceGroupsClient
+	azureResources  *armresources.Client
 	azureAcct       *armstorage.AccountsClient
 	azureCont       *armstorage.BlobContainersClient
+	azureBlobSvc    *armstorage.BlobServicesClient
 	azureVNet       *armnetwork.VirtualNetworksClient
 	azureSubnets    *armnetwork.SubnetsClient
 	azureNIC        *armnetwork.InterfacesClient
 	azurePIP        *armnetwork.PublicIPAddressesClient
 	azureLB         *armnetwork.LoadBalancersClient
+	azureVPNGW      *armnetwork.VirtualNetworkGatewaysClient
+	azureLocalGW    *armnetwork.LocalNetworkGatewaysClient
+	azureVPNConn    *armnetwork.VirtualNetworkGatewayConnectionsClient
+	azureNAT        *armnetwork.NatGatewaysClient
+	azurePeering    *armnetwork.VirtualNetworkPeeringsClient
 	azureVM         *armcompute.VirtualMachinesClient
+	azureSnapshots  *armcompute.SnapshotsClient
 	azureAKS        *armcontainerservice.ManagedClustersClient
 	azureWeb        *armappservice.WebAppsClient
 	azurePlan       *armappservice.PlansClient
@@ -78,9 +124,21 @@ type abstractProvider struct {
 	azureCI         *ci.ContainerGroupsClient
 	azureDNSZones   *armdns.ZonesClient
 	azureDNSRecords *armdns.RecordSetsClient
+	azureCDNProf    *armcdn.ProfilesClient
+	azureCDNEndpts  *armcdn.EndpointsClient
+	azureCDNDomains *armcdn.CustomDomainsClient
+	azureSBNS       *armservicebus.NamespacesClient
+	azureSBTopics   *armservicebus.TopicsClient
+	azureSBSubs     *armservicebus.SubscriptionsClient
+	azureIdentities *armmsi.UserAssignedIdentitiesClient
+	azureRoleAssign *armauthorization.RoleAssignmentsClient
+	azureRoleDefs   *armauthorization.RoleDefinitionsClient
+	azureRedis      *shared.AzureRedisClient
 	azureSubID      string
 	azureCred       *azidentity.ClientSecretCredential
 	azureLoc        string
+	azureSharedAcct string
+	azureSharedRG   string
 
 	gcpStorage   *storage.Client
 	gcpCompute   *compute.Service
@@ -89,8 +147,20 @@ type abstractProvider struct {
 	gcpSQL       *sqladmin.Service
 	gcpDNS       *dnsapi.Service
 	gcpSecrets   *secretmanager.Service
+	gcpPubSub    *pubsub.Service
+	gcpMonitor   *monitoring.Service
+	gcpRun       *run.Service
+	gcpIAM       *iamapi.Service
+	gcpProjects  *crm.Service
+	gcpRedis     *redis.Service
+	gcpMemcache  *memcache.Service
+	gcpKMS       *cloudkms.Service
+	gcpSNet      *servicenetworking.APIService
 	gcpProject   string
 	gcpRegion    string
+
+	gcpFunctionsV2 *cloudfunctionsv2.Service
+	gcpMonitorV3   *monitoringv3.Service
 }
 
 func New() provider.Provider {
@@ -104,6 +174,24 @@ func (p *abstractProvider) Metadata(ctx context.Context, req provider.MetadataRe
 func (p *abstractProvider) Schema(ctx context.Context, req provider.SchemaRequest, resp *provider.SchemaResponse) {
 	resp.Schema = pschema.Schema{
 		Attributes: map[string]pschema.Attribute{
+			"max_retries": pschema.Int64Attribute{
+				Optional:    true,
+				Description: "Retries for throttled or failed API requests on every cloud. Defaults to 5.",
+			},
+			"request_timeout": pschema.StringAttribute{
+				Optional:    true,
+				Description: "Time limit for each resource operation, such as \"30m\". Unset means no limit.",
+			},
+			"size_aliases": pschema.MapAttribute{
+				ElementType: types.MapType{ElemType: types.StringType},
+				Optional:    true,
+				Description: "Instance sizes per cloud, such as { aws = { large = \"m5.large\" } }. These add to or override the built-in small, medium and large.",
+			},
+			"default_tags": pschema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Tags added to every resource's tags, or labels on GCP. A resource's own tag wins on a shared key.",
+			},
 			"aws": pschema.SingleNestedAttribute{
 				Optional: true,
 				Attributes: map[string]pschema.Attribute{
@@ -115,11 +203,13 @@ func (p *abstractProvider) Schema(ctx context.Context, req provider.SchemaReques
 			"azure": pschema.SingleNestedAttribute{
 				Optional: true,
 				Attributes: map[string]pschema.Attribute{
-					"subscription_id": pschema.StringAttribute{Optional: true},
-					"client_id":       pschema.StringAttribute{Optional: true, Sensitive: true},
-					"client_secret":   pschema.StringAttribute{Optional: true, Sensitive: true},
-					"tenant_id":       pschema.StringAttribute{Optional: true},
-					"location":        pschema.StringAttribute{Optional: true},
+					"subscription_id":        pschema.StringAttribute{Optional: true},
+					"client_id":              pschema.StringAttribute{Optional: true, Sensitive: true},
+					"client_secret":          pschema.StringAttribute{Optional: true, Sensitive: true},
+					"tenant_id":              pschema.StringAttribute{Optional: true},
+					"location":               pschema.StringAttribute{Optional: true},
+					"storage_account":        pschema.StringAttribute{Optional: true},
+					"storage_resource_group": pschema.StringAttribute{Optional: true},
 				},
 			},
 			"gcp": pschema.SingleNestedAttribute{
@@ -136,7 +226,11 @@ func (p *abstractProvider) Schema(ctx context.Context, req provider.SchemaReques
 
 func (p *abstractProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
 	var cfg struct {
-		AWS struct {
+		MaxRetries     types.Int64                  `tfsdk:"max_retries"`
+		RequestTimeout types.String                 `tfsdk:"request_timeout"`
+		SizeAliases    map[string]map[string]string `tfsdk:"size_aliases"`
+		DefaultTags    map[string]string            `tfsdk:"default_tags"`
+		AWS            struct {
 			Region    string `tfsdk:"region"`
 			AccessKey string `tfsdk:"access_key"`
 			SecretKey string `tfsdk:"secret_key"`
@@ -147,6 +241,8 @@ func (p *abstractProvider) Configure(ctx context.Context, req provider.Configure
 			ClientSecret   string `tfsdk:"client_secret"`
 			TenantID       string `tfsdk:"tenant_id"`
 			Location       string `tfsdk:"location"`
+			StorageAccount string `tfsdk:"storage_account"`
+			StorageRG      string `tfsdk:"storage_resource_group"`
 		} `tfsdk:"azure"`
 		GCP struct {
 			Project     string `tfsdk:"project"`
@@ -161,7 +257,41 @@ func (p *abstractProvider) Configure(ctx context.Context, req provider.Configure
 		return
 	}
 
-	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	maxRetries := shared.DefaultMaxRetries
+	if !cfg.MaxRetries.IsNull() && !cfg.MaxRetries.IsUnknown() {
+		if cfg.MaxRetries.ValueInt64() < 0 {
+			resp.Diagnostics.AddError("invalid max_retries", "max_retries must not be negative")
+			return
+		}
+		maxRetries = int(cfg.MaxRetries.ValueInt64())
+	}
+
+	var requestTimeout time.Duration
+	if v := cfg.RequestTimeout.ValueString(); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			resp.Diagnostics.AddError("invalid request_timeout", fmt.Sprintf("%q is not a positive duration such as \"30m\"", v))
+			return
+		}
+		requestTimeout = d
+	}
+
+	sizeAliases := map[string]map[string]string{}
+	for cloud, aliases := range cfg.SizeAliases {
+		if cloud != "aws" && cloud != "azure" && cloud != "gcp" {
+			resp.Diagnostics.AddError("invalid size_aliases", fmt.Sprintf("%q is not aws, azure or gcp", cloud))
+			return
+		}
+		sizeAliases[cloud] = map[string]string{}
+		for alias, size := range aliases {
+			// aliases match case-insensitively, like the built-in ones
+			sizeAliases[cloud][strings.ToLower(alias)] = size
+		}
+	}
+
+	// every SDK sends its requests through a transport that logs them
+	awsHTTP := &http.Client{Transport: &shared.LogTransport{Base: awshttp.NewBuildableClient().GetTransport()}}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRetryMaxAttempts(maxRetries+1), awsconfig.WithHTTPClient(awsHTTP))
 	if err != nil {
 		resp.Diagnostics.AddError("aws config", err.Error())
 		return
@@ -173,127 +303,243 @@ func (p *abstractProvider) Configure(ctx context.Context, req provider.Configure
 		awsCfg.Credentials = credentials.NewStaticCredentialsProvider(cfg.AWS.AccessKey, cfg.AWS.SecretKey, "")
 	}
 
-	p.s3 = s3.NewFromConfig(awsCfg)
-	p.ec2 = ec2.NewFromConfig(awsCfg)
-	p.eks = eks.NewFromConfig(awsCfg)
-	p.lambda = lambda.NewFromConfig(awsCfg)
-	p.rds = rds.NewFromConfig(awsCfg)
-	p.sqs = sqs.NewFromConfig(awsCfg)
-	p.ecr = ecr.NewFromConfig(awsCfg)
-	p.ecs = ecs.NewFromConfig(awsCfg)
-	p.elb = elasticloadbalancingv2.NewFromConfig(awsCfg)
+	// clients for other regions are built when a resource first asks for them
+	home := shared.NewAWSClients(awsCfg)
+	p.s3 = home.S3
+	p.ec2 = home.EC2
+	p.eks = home.EKS
+	p.lambda = home.Lambda
+	p.rds = home.RDS
+	p.sqs = home.SQS
+	p.ecr = home.ECR
+	p.ecs = home.ECS
+	p.elb = home.ELB
 	p.route53 = route53.NewFromConfig(awsCfg)
-	p.secrets = secretsmanager.NewFromConfig(awsCfg)
-	baseCfg := &shared.ProviderConfig{AWSS3: p.s3, AWSEC2: p.ec2, AWSEKS: p.eks, AWSLambda: p.lambda, AWSRDS: p.rds, AWSSQS: p.sqs, AWSECR: p.ecr, AWSECS: p.ecs, AWSELB: p.elb, AWSRoute53: p.route53, AWSSM: p.secrets}
+	p.secrets = home.SM
+	p.cdn = cloudfront.NewFromConfig(awsCfg)
+	p.sns = home.SNS
+	p.cw = home.CloudWatch
+	p.iam = iam.NewFromConfig(awsCfg)
+	p.cache = home.ElastiCache
+	p.kms = home.KMS
+	baseCfg := &shared.ProviderConfig{RequestTimeout: requestTimeout, SizeAliases: sizeAliases, DefaultTags: cfg.DefaultTags, AWSS3: p.s3, AWSEC2: p.ec2, AWSEKS: p.eks, AWSLambda: p.lambda, AWSRDS: p.rds, AWSSQS: p.sqs, AWSECR: p.ecr, AWSECS: p.ecs, AWSELB: p.elb, AWSRoute53: p.route53, AWSSM: p.secrets, AWSCloudFront: p.cdn, AWSSNS: p.sns, AWSCloudWatch: p.cw, AWSIAM: p.iam, AWSElastiCache: p.cache, AWSKMS: p.kms, AWSRegions: shared.NewAWSRegions(awsCfg, home)}
 	resp.DataSourceData = baseCfg
 	// base config before cloud-specific additions
 
 	// Azure setup
 	if cfg.Azure.SubscriptionID != "" && cfg.Azure.ClientID != "" && cfg.Azure.ClientSecret != "" && cfg.Azure.TenantID != "" {
-		cred, err := azidentity.NewClientSecretCredential(cfg.Azure.TenantID, cfg.Azure.ClientID, cfg.Azure.ClientSecret, nil)
+		// zero means the SDK default to azcore, so no retries is -1
+		azRetries := int32(maxRetries)
+		if azRetries == 0 {
+			azRetries = -1
+		}
+		azOpts := &arm.ClientOptions{ClientOptions: policy.ClientOptions{
+			Retry:     policy.RetryOptions{MaxRetries: azRetries},
+			Transport: &http.Client{Transport: &shared.LogTransport{Base: http.DefaultTransport}},
+		}}
+		cred, err := azidentity.NewClientSecretCredential(cfg.Azure.TenantID, cfg.Azure.ClientID, cfg.Azure.ClientSecret,
+			&azidentity.ClientSecretCredentialOptions{ClientOptions: azOpts.ClientOptions})
 		if err != nil {
 			resp.Diagnostics.AddError("azure credential", err.Error())
 			return
 		}
-		rgClient, err := armresources.NewResourceGroupsClient(cfg.Azure.SubscriptionID, cred, nil)
+		rgClient, err := armresources.NewResourceGroupsClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure rg client", err.Error())
 			return
 		}
-		acctClient, err := armstorage.NewAccountsClient(cfg.Azure.SubscriptionID, cred, nil)
+		resClient, err := armresources.NewClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure resources client", err.Error())
+			return
+		}
+		acctClient, err := armstorage.NewAccountsClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure account client", err.Error())
 			return
 		}
-		contClient, err := armstorage.NewBlobContainersClient(cfg.Azure.SubscriptionID, cred, nil)
+		contClient, err := armstorage.NewBlobContainersClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure container client", err.Error())
 			return
 		}
-		vnetClient, err := armnetwork.NewVirtualNetworksClient(cfg.Azure.SubscriptionID, cred, nil)
+		blobSvcClient, err := armstorage.NewBlobServicesClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure blob service client", err.Error())
+			return
+		}
+		vnetClient, err := armnetwork.NewVirtualNetworksClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure vnet client", err.Error())
 			return
 		}
-		subnetClient, err := armnetwork.NewSubnetsClient(cfg.Azure.SubscriptionID, cred, nil)
+		subnetClient, err := armnetwork.NewSubnetsClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure subnet client", err.Error())
 			return
 		}
-		nicClient, err := armnetwork.NewInterfacesClient(cfg.Azure.SubscriptionID, cred, nil)
+		nicClient, err := armnetwork.NewInterfacesClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure nic client", err.Error())
 			return
 		}
-		pipClient, err := armnetwork.NewPublicIPAddressesClient(cfg.Azure.SubscriptionID, cred, nil)
+		pipClient, err := armnetwork.NewPublicIPAddressesClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure pip client", err.Error())
 			return
 		}
-		lbClient, err := armnetwork.NewLoadBalancersClient(cfg.Azure.SubscriptionID, cred, nil)
+		lbClient, err := armnetwork.NewLoadBalancersClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure lb client", err.Error())
 			return
 		}
-		vmClient, err := armcompute.NewVirtualMachinesClient(cfg.Azure.SubscriptionID, cred, nil)
+		vpnGWClient, err := armnetwork.NewVirtualNetworkGatewaysClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure vpn gateway client", err.Error())
+			return
+		}
+		localGWClient, err := armnetwork.NewLocalNetworkGatewaysClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure local gateway client", err.Error())
+			return
+		}
+		vpnConnClient, err := armnetwork.NewVirtualNetworkGatewayConnectionsClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure vpn connection client", err.Error())
+			return
+		}
+		natClient, err := armnetwork.NewNatGatewaysClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure nat gateway client", err.Error())
+			return
+		}
+		peeringClient, err := armnetwork.NewVirtualNetworkPeeringsClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure peering client", err.Error())
+			return
+		}
+		vmClient, err := armcompute.NewVirtualMachinesClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure vm client", err.Error())
 			return
 		}
-		aksClient, err := armcontainerservice.NewManagedClustersClient(cfg.Azure.SubscriptionID, cred, nil)
+		snapshotClient, err := armcompute.NewSnapshotsClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure snapshot client", err.Error())
+			return
+		}
+		aksClient, err := armcontainerservice.NewManagedClustersClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure aks client", err.Error())
 			return
 		}
-		webClient, err := armappservice.NewWebAppsClient(cfg.Azure.SubscriptionID, cred, nil)
+		webClient, err := armappservice.NewWebAppsClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure web client", err.Error())
 			return
 		}
-		planClient, err := armappservice.NewPlansClient(cfg.Azure.SubscriptionID, cred, nil)
+		planClient, err := armappservice.NewPlansClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure plan client", err.Error())
 			return
 		}
-		mysqlClient, err := armmysqlflexibleservers.NewServersClient(cfg.Azure.SubscriptionID, cred, nil)
+		mysqlClient, err := armmysqlflexibleservers.NewServersClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure mysql client", err.Error())
 			return
 		}
-		pgClient, err := armpostgresqlflexibleservers.NewServersClient(cfg.Azure.SubscriptionID, cred, nil)
+		pgClient, err := armpostgresqlflexibleservers.NewServersClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure postgres client", err.Error())
 			return
 		}
-		regClient, err := armcontainerregistry.NewRegistriesClient(cfg.Azure.SubscriptionID, cred, nil)
+		regClient, err := armcontainerregistry.NewRegistriesClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure registry client", err.Error())
 			return
 		}
-		ciClient, err := ci.NewContainerGroupsClient(cfg.Azure.SubscriptionID, cred, nil)
+		ciClient, err := ci.NewContainerGroupsClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure container client", err.Error())
 			return
 		}
-		dnsZoneClient, err := armdns.NewZonesClient(cfg.Azure.SubscriptionID, cred, nil)
+		dnsZoneClient, err := armdns.NewZonesClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure dns zone client", err.Error())
 			return
 		}
-		dnsRecordClient, err := armdns.NewRecordSetsClient(cfg.Azure.SubscriptionID, cred, nil)
+		dnsRecordClient, err := armdns.NewRecordSetsClient(cfg.Azure.SubscriptionID, cred, azOpts)
 		if err != nil {
 			resp.Diagnostics.AddError("azure dns record client", err.Error())
 			return
 		}
+		cdnProfClient, err := armcdn.NewProfilesClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure cdn profile client", err.Error())
+			return
+		}
+		cdnEndptClient, err := armcdn.NewEndpointsClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure cdn endpoint client", err.Error())
+			return
+		}
+		cdnDomainClient, err := armcdn.NewCustomDomainsClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure cdn domain client", err.Error())
+			return
+		}
+		sbNSClient, err := armservicebus.NewNamespacesClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure servicebus namespace client", err.Error())
+			return
+		}
+		sbTopicClient, err := armservicebus.NewTopicsClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure servicebus topic client", err.Error())
+			return
+		}
+		sbSubClient, err := armservicebus.NewSubscriptionsClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure servicebus subscription client", err.Error())
+			return
+		}
+		identityClient, err := armmsi.NewUserAssignedIdentitiesClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure identity client", err.Error())
+			return
+		}
+		roleAssignClient, err := armauthorization.NewRoleAssignmentsClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure role assignment client", err.Error())
+			return
+		}
+		roleDefClient, err := armauthorization.NewRoleDefinitionsClient(cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure role definition client", err.Error())
+			return
+		}
+		redisClient, err := shared.NewAzureRedisClient(cfg.Azure.SubscriptionID, cred, azOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure redis client", err.Error())
+			return
+		}
 		p.azureRG = rgClient
+		p.azureResources = resClient
 		p.azureAcct = acctClient
 		p.azureCont = contClient
+		p.azureBlobSvc = blobSvcClient
 		p.azureVNet = vnetClient
 		p.azureSubnets = subnetClient
 		p.azureNIC = nicClient
 		p.azurePIP = pipClient
 		p.azureLB = lbClient
+		p.azureVPNGW = vpnGWClient
+		p.azureLocalGW = localGWClient
+		p.azureVPNConn = vpnConnClient
+		p.azureNAT = natClient
+		p.azurePeering = peeringClient
 		p.azureVM = vmClient
+		p.azureSnapshots = snapshotClient
 		p.azureAKS = aksClient
 		p.azureWeb = webClient
 		p.azurePlan = planClient
@@ -303,23 +549,45 @@ func (p *abstractProvider) Configure(ctx context.Context, req provider.Configure
 		p.azureCI = ciClient
 		p.azureDNSZones = dnsZoneClient
 		p.azureDNSRecords = dnsRecordClient
+		p.azureCDNProf = cdnProfClient
+		p.azureCDNEndpts = cdnEndptClient
+		p.azureCDNDomains = cdnDomainClient
+		p.azureSBNS = sbNSClient
+		p.azureSBTopics = sbTopicClient
+		p.azureSBSubs = sbSubClient
+		p.azureIdentities = identityClient
+		p.azureRoleAssign = roleAssignClient
+		p.azureRoleDefs = roleDefClient
+		p.azureRedis = redisClient
 		p.azureSubID = cfg.Azure.SubscriptionID
 		p.azureCred = cred
 		p.azureLoc = cfg.Azure.Location
+		p.azureSharedAcct = cfg.Azure.StorageAccount
+		p.azureSharedRG = cfg.Azure.StorageRG
 	}
 
 	baseCfg.AzureCred = p.azureCred
 	baseCfg.AzureSubID = p.azureSubID
 	baseCfg.AzureLocation = p.azureLoc
+	baseCfg.AzureStorageAccount = p.azureSharedAcct
+	baseCfg.AzureStorageResourceGroup = p.azureSharedRG
 	baseCfg.AzureRGClient = p.azureRG
+	baseCfg.AzureResourcesClient = p.azureResources
 	baseCfg.AzureStorageAcct = p.azureAcct
 	baseCfg.AzureBlobContainers = p.azureCont
+	baseCfg.AzureBlobServices = p.azureBlobSvc
 	baseCfg.AzureVNetClient = p.azureVNet
 	baseCfg.AzureSubnetClient = p.azureSubnets
 	baseCfg.AzureNICClient = p.azureNIC
 	baseCfg.AzurePIPClient = p.azurePIP
 	baseCfg.AzureLBClient = p.azureLB
+	baseCfg.AzureVPNGatewayClient = p.azureVPNGW
+	baseCfg.AzureLocalGatewayClient = p.azureLocalGW
+	baseCfg.AzureVPNConnectionClient = p.azureVPNConn
+	baseCfg.AzureNATClient = p.azureNAT
+	baseCfg.AzurePeeringClient = p.azurePeering
 	baseCfg.AzureVMClient = p.azureVM
+	baseCfg.AzureSnapshotClient = p.azureSnapshots
 	baseCfg.AzureAKSClient = p.azureAKS
 	baseCfg.AzureWebClient = p.azureWeb
 	baseCfg.AzurePlanClient = p.azurePlan
@@ -329,6 +597,16 @@ func (p *abstractProvider) Configure(ctx context.Context, req provider.Configure
 	baseCfg.AzureContainerClient = p.azureCI
 	baseCfg.AzureDNSZoneClient = p.azureDNSZones
 	baseCfg.AzureDNSRecordClient = p.azureDNSRecords
+	baseCfg.AzureCDNProfileClient = p.azureCDNProf
+	baseCfg.AzureCDNEndpointClient = p.azureCDNEndpts
+	baseCfg.AzureCDNDomainClient = p.azureCDNDomains
+	baseCfg.AzureSBNamespaceClient = p.azureSBNS
+	baseCfg.AzureSBTopicClient = p.azureSBTopics
+	baseCfg.AzureSBSubscriptionClient = p.azureSBSubs
+	baseCfg.AzureIdentityClient = p.azureIdentities
+	baseCfg.AzureRoleAssignmentClient = p.azureRoleAssign
+	baseCfg.AzureRoleDefinitionClient = p.azureRoleDefs
+	baseCfg.AzureRedisClient = p.azureRedis
 
 	// GCP setup
 	if cfg.GCP.Project != "" {
@@ -336,11 +614,27 @@ func (p *abstractProvider) Configure(ctx context.Context, req provider.Configure
 		if cfg.GCP.Credentials != "" {
 			opts = append(opts, option.WithCredentialsJSON([]byte(cfg.GCP.Credentials)))
 		}
-		storageClient, err := storage.NewClient(ctx, opts...)
+		opts = append(opts, option.WithScopes("https://www.googleapis.com/auth/cloud-platform"))
+		logged := &shared.LogTransport{Base: http.DefaultTransport}
+		// Cloud Storage retries on its own, so it gets a transport without retries
+		storageTrans, err := htransport.NewTransport(ctx, logged, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp transport", err.Error())
+			return
+		}
+		storageClient, err := storage.NewClient(ctx, option.WithHTTPClient(&http.Client{Transport: storageTrans}))
 		if err != nil {
 			resp.Diagnostics.AddError("gcp storage client", err.Error())
 			return
 		}
+		storageClient.SetRetry(storage.WithMaxAttempts(maxRetries + 1))
+		// the API clients share one authenticated transport that retries
+		trans, err := htransport.NewTransport(ctx, &shared.RetryTransport{Base: logged, MaxRetries: maxRetries}, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp transport", err.Error())
+			return
+		}
+		opts = []option.ClientOption{option.WithHTTPClient(&http.Client{Transport: trans})}
 		computeSvc, err := compute.NewService(ctx, opts...)
 		if err != nil {
 			resp.Diagnostics.AddError("gcp compute client", err.Error())
@@ -356,6 +650,11 @@ func (p *abstractProvider) Configure(ctx context.Context, req provider.Configure
 			resp.Diagnostics.AddError("gcp functions client", err.Error())
 			return
 		}
+		funcV2Svc, err := cloudfunctionsv2.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp functions v2 client", err.Error())
+			return
+		}
 		secretSvc, err := secretmanager.NewService(ctx, opts...)
 		if err != nil {
 			resp.Diagnostics.AddError("gcp secret client", err.Error())
@@ -371,13 +670,74 @@ func (p *abstractProvider) Configure(ctx context.Context, req provider.Configure
 			resp.Diagnostics.AddError("gcp sql client", err.Error())
 			return
 		}
+		pubsubSvc, err := pubsub.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp pubsub client", err.Error())
+			return
+		}
+		monitorSvc, err := monitoring.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp monitoring client", err.Error())
+			return
+		}
+		monitorV3Svc, err := monitoringv3.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp monitoring v3 client", err.Error())
+			return
+		}
+		runSvc, err := run.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp cloud run client", err.Error())
+			return
+		}
+		iamSvc, err := iamapi.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp iam client", err.Error())
+			return
+		}
+		crmSvc, err := crm.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp resource manager client", err.Error())
+			return
+		}
+		redisSvc, err := redis.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp memorystore client", err.Error())
+			return
+		}
+		memcacheSvc, err := memcache.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp memcache client", err.Error())
+			return
+		}
+		kmsSvc, err := cloudkms.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp kms client", err.Error())
+			return
+		}
+		snetSvc, err := servicenetworking.NewService(ctx, opts...)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp service networking client", err.Error())
+			return
+		}
 		p.gcpStorage = storageClient
 		p.gcpCompute = computeSvc
 		p.gcpGKE = gkeSvc
 		p.gcpFunctions = funcSvc
+		p.gcpFunctionsV2 = funcV2Svc
 		p.gcpSQL = sqlSvc
 		p.gcpSecrets = secretSvc
 		p.gcpDNS = dnsSvc
+		p.gcpPubSub = pubsubSvc
+		p.gcpMonitor = monitorSvc
+		p.gcpMonitorV3 = monitorV3Svc
+		p.gcpRun = runSvc
+		p.gcpIAM = iamSvc
+		p.gcpProjects = crmSvc
+		p.gcpRedis = redisSvc
+		p.gcpMemcache = memcacheSvc
+		p.gcpKMS = kmsSvc
+		p.gcpSNet = snetSvc
 		p.gcpProject = cfg.GCP.Project
 		p.gcpRegion = cfg.GCP.Region
 	}
@@ -386,11 +746,31 @@ func (p *abstractProvider) Configure(ctx context.Context, req provider.Configure
 	baseCfg.GCPCompute = p.gcpCompute
 	baseCfg.GCPGKE = p.gcpGKE
 	baseCfg.GCPFunctions = p.gcpFunctions
+	baseCfg.GCPFunctionsV2 = p.gcpFunctionsV2
+	baseCfg.GCPMonitoringV3 = p.gcpMonitorV3
 	baseCfg.GCPCloudSQL = p.gcpSQL
 	baseCfg.GCPDNS = p.gcpDNS
 	baseCfg.GCPSecrets = p.gcpSecrets
+	baseCfg.GCPPubSub = p.gcpPubSub
+	baseCfg.GCPMonitoring = p.gcpMonitor
+	baseCfg.GCPRun = p.gcpRun
+	baseCfg.GCPIAM = p.gcpIAM
+	baseCfg.GCPProjects = p.gcpProjects
+	baseCfg.GCPRedis = p.gcpRedis
+	baseCfg.GCPMemcache = p.gcpMemcache
+	baseCfg.GCPKMS = p.gcpKMS
+	baseCfg.GCPServiceNetworking = p.gcpSNet
 	baseCfg.GCPProject = p.gcpProject
 	baseCfg.GCPRegion = p.gcpRegion
+	// the account ID is only needed for ARNs, so it is looked up on first use
+	stsClient := sts.NewFromConfig(awsCfg)
+	baseCfg.CloudIDs = shared.NewCloudIDs(awsCfg.Region, p.azureSubID, p.gcpProject, func(ctx context.Context) (string, error) {
+		out, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			return "", err
+		}
+		return aws.ToString(out.Account), nil
+	})
 	resp.ResourceData = baseCfg
 }
 
@@ -402,12 +782,26 @@ func (p *abstractProvider) Resources(ctx context.Context) []func() resource.Reso
 		resources.NewClusterResource,
 		resources.NewFunctionResource,
 		resources.NewDatabaseResource,
+		resources.NewCacheResource,
 		resources.NewQueueResource,
 		resources.NewRegistryResource,
 		resources.NewLoadBalancerResource,
 		resources.NewServerlessContainerResource,
 		resources.NewDNSRecordResource,
+		resources.NewDNSZoneResource,
 		resources.NewSecretResource,
+		resources.NewCDNResource,
+		resources.NewTopicResource,
+		resources.NewDashboardResource,
+		resources.NewIAMRoleResource,
+		resources.NewServiceAccountKeyResource,
+		resources.NewKMSKeyResource,
+		resources.NewManagedIdentityAssignmentResource,
+		resources.NewSnapshotResource,
+		resources.NewVPNGatewayResource,
+		resources.NewNetworkPeeringResource,
+		resources.NewMonitoringAlarmResource,
+		resources.NewStaticIPResource,
 	}
 }
 
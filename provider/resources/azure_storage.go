@@ -0,0 +1,98 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+)
+
+// storageAccountName derives an Azure storage account name from a bucket name.
+func storageAccountName(name string) string {
+	acctName := strings.ToLower(name)
+	if len(acctName) > 24 {
+		acctName = acctName[:24]
+	}
+	return acctName
+}
+
+// azureConnectionString returns the connection string for a storage account
+// authenticated with one of its access keys.
+func azureConnectionString(acctName, key string) string {
+	return "DefaultEndpointsProtocol=https;AccountName=" + acctName + ";AccountKey=" + key + ";EndpointSuffix=core.windows.net"
+}
+
+// connectionStringAccount returns the account name in a storage connection
+// string.
+func connectionStringAccount(cs string) (string, bool) {
+	for _, part := range strings.Split(cs, ";") {
+		if name, ok := strings.CutPrefix(part, "AccountName="); ok && name != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// azureStorage locates or creates the storage account backing a container or
+// queue. With a shared account configured on the provider it is used as is;
+// otherwise a dedicated account is derived from the resource name.
+type azureStorage struct {
+	rg         *armresources.ResourceGroupsClient
+	acct       *armstorage.AccountsClient
+	loc        string
+	sharedAcct string
+	sharedRG   string
+}
+
+// names returns the account and resource group ensure uses for name,
+// without looking them up. Read falls back to them when state predates the
+// attributes or was imported.
+func (s azureStorage) names(name string) (acctName, rgName string) {
+	if s.sharedAcct != "" {
+		rgName = s.sharedRG
+		if rgName == "" {
+			rgName = "abstract-rg"
+		}
+		return s.sharedAcct, rgName
+	}
+	return storageAccountName(name), "abstract-rg"
+}
+
+// ensure returns the account and resource group to use for name. created is
+// true only when the account did not exist and was created by this call, which
+// makes the calling resource its owner.
+func (s azureStorage) ensure(ctx context.Context, name string) (acctName, rgName string, created bool, err error) {
+	acctName, rgName = s.names(name)
+	if s.sharedAcct != "" {
+		return acctName, rgName, false, nil
+	}
+	if err = shared.EnsureResourceGroup(ctx, s.rg, rgName, s.loc); err != nil {
+		return "", "", false, err
+	}
+	_, err = s.acct.GetProperties(ctx, rgName, acctName, nil)
+	if err == nil {
+		return acctName, rgName, false, nil
+	}
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusNotFound {
+		return "", "", false, err
+	}
+	poller, err := s.acct.BeginCreate(ctx, rgName, acctName, armstorage.AccountCreateParameters{
+		Location: &s.loc,
+		Kind:     to.Ptr(armstorage.KindStorageV2),
+		SKU:      &armstorage.SKU{Name: to.Ptr(armstorage.SKUNameStandardLRS)},
+	}, nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, poller)
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	return acctName, rgName, true, nil
+}
@@ -2,12 +2,18 @@ package resources
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
 	"strings"
+	"time"
 
 	"abstract-provider/provider/shared"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 
 	"cloud.google.com/go/storage"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -15,23 +21,69 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 type BucketResource struct {
-	s3         *s3.Client
+	s3         shared.S3Buckets
 	azureRG    *armresources.ResourceGroupsClient
 	azureAcct  *armstorage.AccountsClient
 	azureCont  *armstorage.BlobContainersClient
+	azureBlob  *armstorage.BlobServicesClient
 	azureCred  azcore.TokenCredential
 	azureSubID string
 	azureLoc   string
-	gcpStorage *storage.Client
+	gcpStorage shared.GCSBuckets
 	gcpProject string
 	gcpRegion  string
+
+	// azureShared* name a provider-wide storage account that is never deleted.
+	azureSharedAcct string
+	azureSharedRG   string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	// defaultTags are the provider's default_tags, merged under tags.
+	defaultTags map[string]string
+
+	ids *shared.CloudIDs
+}
+
+type bucketResourceModel struct {
+	ID             types.String `tfsdk:"id"`
+	CloudID        types.String `tfsdk:"cloud_id"`
+	Name           types.String `tfsdk:"name"`
+	Type           types.String `tfsdk:"type"`
+	Region         types.String `tfsdk:"region"`
+	Versioning     types.Bool   `tfsdk:"versioning"`
+	Tags           types.Map    `tfsdk:"tags"`
+	Labels         types.Map    `tfsdk:"labels"`
+	KMSKeyID       types.String `tfsdk:"kms_key_id"`
+	ExpirationDays types.Int64  `tfsdk:"expiration_days"`
+	Account        types.String `tfsdk:"account"`
+	ResourceGroup  types.String `tfsdk:"resource_group"`
+	AccountCreated types.Bool   `tfsdk:"account_created"`
+	Project        types.String `tfsdk:"project"`
+	ARN            types.String `tfsdk:"arn"`
+	DomainName     types.String `tfsdk:"domain_name"`
+	PublicAccess   types.String `tfsdk:"public_access"`
+	ForceDestroy   types.Bool   `tfsdk:"force_destroy"`
+
+	ConnectionString types.String `tfsdk:"connection_string"`
 }
 
 func NewBucketResource() resource.Resource {
@@ -47,14 +99,25 @@ func (r *BucketResource) Configure(ctx context.Context, req resource.ConfigureRe
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
-	r.s3 = cfg.AWSS3
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.ids = cfg.CloudIDs
+	r.defaultTags = cfg.DefaultTags
+	if cfg.AWSS3 != nil {
+		r.s3 = cfg.AWSS3
+	}
 	r.azureRG = cfg.AzureRGClient
 	r.azureAcct = cfg.AzureStorageAcct
 	r.azureCont = cfg.AzureBlobContainers
+	r.azureBlob = cfg.AzureBlobServices
 	r.azureCred = cfg.AzureCred
 	r.azureSubID = cfg.AzureSubID
 	r.azureLoc = cfg.AzureLocation
-	r.gcpStorage = cfg.GCPStorage
+	r.azureSharedAcct = cfg.AzureStorageAccount
+	r.azureSharedRG = cfg.AzureStorageResourceGroup
+	if cfg.GCPStorage != nil {
+		r.gcpStorage = shared.NewGCSBuckets(cfg.GCPStorage)
+	}
 	r.gcpProject = cfg.GCPProject
 	r.gcpRegion = cfg.GCPRegion
 }
@@ -66,33 +129,569 @@ func (r *BucketResource) Metadata(ctx context.Context, req resource.MetadataRequ
 func (r *BucketResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
-			"id":         schema.StringAttribute{Computed: true},
-			"name":       schema.StringAttribute{Required: true},
-			"type":       schema.StringAttribute{Required: true},
-			"region":     schema.StringAttribute{Optional: true},
-			"versioning": schema.BoolAttribute{Optional: true},
+			"id":             schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"cloud_id":       cloudIDAttribute(),
+			"name":           schema.StringAttribute{Required: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"type":           schema.StringAttribute{Required: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"region":         schema.StringAttribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown(), stringplanmodifier.RequiresReplace()}},
+			"versioning":     schema.BoolAttribute{Optional: true},
+			"account":        schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"resource_group": schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"project":        schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+
+			// Only an account created for this bucket is deleted with it.
+			"account_created": schema.BoolAttribute{Computed: true, PlanModifiers: []planmodifier.Bool{boolplanmodifier.UseStateForUnknown()}},
+
+			// Labels on GCP and container metadata on Azure.
+			"tags": schema.MapAttribute{ElementType: types.StringType, Optional: true},
+			// GCP only: labels added to tags, winning on a shared key.
+			"labels": schema.MapAttribute{ElementType: types.StringType, Optional: true},
+			// Customer-managed key for default encryption; unset uses the cloud-managed key.
+			"kms_key_id": schema.StringAttribute{Optional: true},
+			// Delete objects this many days after creation.
+			"expiration_days": schema.Int64Attribute{Optional: true},
+
+			// AWS only.
+			"arn": schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			// The regional S3 domain, storage.googleapis.com/<name> on GCP or
+			// the storage account's blob endpoint on Azure.
+			"domain_name": schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+
+			// "blocked" blocks public access to the bucket; "allowed" lets
+			// policies and ACLs make it public.
+			"public_access": schema.StringAttribute{Optional: true, Computed: true, Default: stringdefault.StaticString("blocked")},
+			// Delete every object, and every version of it, before deleting
+			// the bucket. Without it only empty buckets can be deleted.
+			"force_destroy": schema.BoolAttribute{Optional: true},
+
+			// Azure only: the storage account's connection string, for apps
+			// using the container.
+			"connection_string": schema.StringAttribute{Computed: true, Sensitive: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
 		},
 	}
 }
 
-func (r *BucketResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name       types.String `tfsdk:"name"`
-		Type       types.String `tfsdk:"type"`
-		Region     types.String `tfsdk:"region"`
-		Versioning types.Bool   `tfsdk:"versioning"`
+func (r *BucketResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg bucketResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	checkGCPLabels(&resp.Diagnostics, cfg.Type, "labels", cfg.Labels)
+	r.checkName(&cfg, &resp.Diagnostics)
+	if v := cfg.PublicAccess.ValueString(); v != "" && v != "blocked" && v != "allowed" {
+		resp.Diagnostics.AddAttributeError(path.Root("public_access"), "invalid public_access", fmt.Sprintf("%q is not blocked or allowed", v))
+	}
+	// tags become labels on GCP and must follow the same rules
+	if cfg.Type.ValueString() == "gcp" && !cfg.Tags.IsUnknown() {
+		if err := shared.ValidateGCPLabels(stringMap(cfg.Tags)); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("tags"), "invalid gcp labels", err.Error())
+		}
+	}
+}
+
+// checkName checks the bucket name against the rules of its cloud. On Azure,
+// without a shared storage account, it also warns when the account derived
+// from the name differs from it.
+func (r *BucketResource) checkName(cfg *bucketResourceModel, diags *diag.Diagnostics) {
+	if cfg.Name.IsUnknown() || cfg.Type.IsUnknown() {
+		return
+	}
+	name := cfg.Name.ValueString()
+	if err := shared.ValidateBucketName(cfg.Type.ValueString(), name); err != nil {
+		diags.AddAttributeError(path.Root("name"), "invalid bucket name", fmt.Sprintf("%q: %s", name, err))
+		return
+	}
+	if cfg.Type.ValueString() != "azure" || r.azureSharedAcct != "" {
+		return
+	}
+	switch acct := storageAccountName(name); {
+	case !shared.ValidAzureStorageAccount(acct):
+		diags.AddAttributeWarning(path.Root("name"), "invalid storage account name",
+			fmt.Sprintf("Azure containers go into a storage account named after the bucket, %q, but account names are 3 to 24 lowercase letters and digits. "+
+				"Creating the bucket will fail unless storage_account is set in the provider's azure block.", acct))
+	case acct != name:
+		diags.AddAttributeWarning(path.Root("name"), "storage account name truncated",
+			fmt.Sprintf("Azure containers go into a storage account named after the bucket, which is cut to 24 characters: %q. "+
+				"Buckets whose names start with the same 24 characters share that account.", acct))
+	}
+}
+
+// azureBucketSettingsWarning explains why encryption and lifecycle are ignored on Azure.
+const azureBucketSettingsWarning = "kms_key_id and expiration_days are storage account settings on Azure and are not applied to containers"
+
+// bucketChanges records which mutable bucket settings differ from the prior state.
+type bucketChanges struct {
+	versioning bool
+	tags       bool
+	labels     bool
+	encryption bool
+	lifecycle  bool
+	// publicAccess is always set on create, where the default matters too.
+	publicAccess bool
+}
+
+// diffBucket compares plan against prior; on create prior is the zero model.
+func diffBucket(plan, prior *bucketResourceModel) bucketChanges {
+	return bucketChanges{
+		versioning: plan.Versioning.ValueBool() != prior.Versioning.ValueBool(),
+		tags:       !maps.Equal(stringMap(plan.Tags), stringMap(prior.Tags)),
+		labels:     !maps.Equal(stringMap(plan.Labels), stringMap(prior.Labels)),
+		encryption: plan.KMSKeyID.ValueString() != prior.KMSKeyID.ValueString(),
+		lifecycle:  plan.ExpirationDays.ValueInt64() != prior.ExpirationDays.ValueInt64(),
+
+		publicAccess: plan.publicAccessBlocked() != prior.publicAccessBlocked(),
+	}
+}
+
+// publicAccessBlocked reports whether public access is to be blocked, the
+// default when public_access is unset or not yet known.
+func (m *bucketResourceModel) publicAccessBlocked() bool {
+	return m.PublicAccess.ValueString() != "allowed"
+}
+
+// setPublicAccess records whether the cloud blocks public access.
+func (m *bucketResourceModel) setPublicAccess(blocked bool) {
+	m.PublicAccess = types.StringValue("allowed")
+	if blocked {
+		m.PublicAccess = types.StringValue("blocked")
+	}
+}
+
+// gcsLabels returns the labels for a GCS bucket: defaults overlaid with tags,
+// then with labels.
+func (m *bucketResourceModel) gcsLabels(defaults map[string]string) map[string]string {
+	labels := shared.MergeTags(defaults, stringMap(m.Tags))
+	maps.Copy(labels, stringMap(m.Labels))
+	return labels
+}
+
+// stringMap returns the entries of a string map such as tags; a null map
+// yields an empty one.
+func stringMap(m types.Map) map[string]string {
+	vals := map[string]string{}
+	for k, v := range m.Elements() {
+		if s, ok := v.(types.String); ok {
+			vals[k] = s.ValueString()
+		}
+	}
+	return vals
+}
+
+// reconcileS3 applies the settings flagged in c to the bucket, leaving the rest untouched.
+func (r *BucketResource) reconcileS3(ctx context.Context, plan *bucketResourceModel, c bucketChanges) error {
+	bucket := aws.String(plan.Name.ValueString())
+	if c.versioning {
+		status := s3types.BucketVersioningStatusSuspended
+		if plan.Versioning.ValueBool() {
+			status = s3types.BucketVersioningStatusEnabled
+		}
+		_, err := r.s3.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+			Bucket:                  bucket,
+			VersioningConfiguration: &s3types.VersioningConfiguration{Status: status},
+		})
+		if err != nil {
+			return fmt.Errorf("versioning: %w", err)
+		}
+	}
+	if c.tags {
+		tags := shared.MergeTags(r.defaultTags, stringMap(plan.Tags))
+		var err error
+		if len(tags) == 0 {
+			_, err = r.s3.DeleteBucketTagging(ctx, &s3.DeleteBucketTaggingInput{Bucket: bucket})
+		} else {
+			keys := make([]string, 0, len(tags))
+			for k := range tags {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			tagSet := make([]s3types.Tag, 0, len(keys))
+			for _, k := range keys {
+				tagSet = append(tagSet, s3types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+			}
+			_, err = r.s3.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{Bucket: bucket, Tagging: &s3types.Tagging{TagSet: tagSet}})
+		}
+		if err != nil {
+			return fmt.Errorf("tags: %w", err)
+		}
+	}
+	if c.encryption {
+		var err error
+		if key := plan.KMSKeyID.ValueString(); key == "" {
+			// reverts to the default SSE-S3 encryption
+			_, err = r.s3.DeleteBucketEncryption(ctx, &s3.DeleteBucketEncryptionInput{Bucket: bucket})
+		} else {
+			_, err = r.s3.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+				Bucket: bucket,
+				ServerSideEncryptionConfiguration: &s3types.ServerSideEncryptionConfiguration{
+					Rules: []s3types.ServerSideEncryptionRule{{
+						ApplyServerSideEncryptionByDefault: &s3types.ServerSideEncryptionByDefault{
+							SSEAlgorithm:   s3types.ServerSideEncryptionAwsKms,
+							KMSMasterKeyID: aws.String(key),
+						},
+					}},
+				},
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("encryption: %w", err)
+		}
+	}
+	if c.publicAccess {
+		blocked := aws.Bool(plan.publicAccessBlocked())
+		_, err := r.s3.PutPublicAccessBlock(ctx, &s3.PutPublicAccessBlockInput{
+			Bucket: bucket,
+			PublicAccessBlockConfiguration: &s3types.PublicAccessBlockConfiguration{
+				BlockPublicAcls:       blocked,
+				IgnorePublicAcls:      blocked,
+				BlockPublicPolicy:     blocked,
+				RestrictPublicBuckets: blocked,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("public access: %w", err)
+		}
+	}
+	if c.lifecycle {
+		var err error
+		if days := plan.ExpirationDays.ValueInt64(); days == 0 {
+			_, err = r.s3.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{Bucket: bucket})
+		} else {
+			_, err = r.s3.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+				Bucket: bucket,
+				LifecycleConfiguration: &s3types.BucketLifecycleConfiguration{
+					Rules: []s3types.LifecycleRule{{
+						ID:         aws.String("abstract-expiration"),
+						Status:     s3types.ExpirationStatusEnabled,
+						Filter:     &s3types.LifecycleRuleFilter{Prefix: aws.String("")},
+						Expiration: &s3types.LifecycleExpiration{Days: aws.Int32(int32(days))},
+					}},
+				},
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("lifecycle: %w", err)
+		}
+	}
+	return nil
+}
+
+// gcsLifecycle builds the expiration rule; zero days clears the lifecycle.
+func gcsLifecycle(days int64) *storage.Lifecycle {
+	if days == 0 {
+		return &storage.Lifecycle{}
+	}
+	return &storage.Lifecycle{Rules: []storage.LifecycleRule{{
+		Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+		Condition: storage.LifecycleCondition{AgeInDays: days},
+	}}}
+}
+
+// reconcileGCS applies the settings flagged in c to the bucket in a single update.
+func (r *BucketResource) reconcileGCS(ctx context.Context, plan, prior *bucketResourceModel, c bucketChanges) error {
+	if c == (bucketChanges{}) {
+		return nil
+	}
+	var upd storage.BucketAttrsToUpdate
+	if c.versioning {
+		upd.VersioningEnabled = plan.Versioning.ValueBool()
+	}
+	if c.encryption {
+		// an empty key name removes the bucket's default key
+		upd.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: plan.KMSKeyID.ValueString()}
+	}
+	if c.lifecycle {
+		upd.Lifecycle = gcsLifecycle(plan.ExpirationDays.ValueInt64())
+	}
+	if c.publicAccess {
+		upd.PublicAccessPrevention = storage.PublicAccessPreventionInherited
+		if plan.publicAccessBlocked() {
+			upd.UniformBucketLevelAccess = &storage.UniformBucketLevelAccess{Enabled: true}
+			upd.PublicAccessPrevention = storage.PublicAccessPreventionEnforced
+		}
+	}
+	var setLabels map[string]string
+	var deleteLabels []string
+	if c.tags || c.labels {
+		setLabels = plan.gcsLabels(r.defaultTags)
+		for k := range prior.gcsLabels(r.defaultTags) {
+			if _, ok := setLabels[k]; !ok {
+				deleteLabels = append(deleteLabels, k)
+			}
+		}
+	}
+	_, err := r.gcpStorage.UpdateBucket(ctx, plan.Name.ValueString(), upd, setLabels, deleteLabels)
+	return err
+}
+
+// azureMetadata converts tags to container metadata.
+func azureMetadata(tags map[string]string) map[string]*string {
+	if len(tags) == 0 {
+		return nil
+	}
+	md := make(map[string]*string, len(tags))
+	for k, v := range tags {
+		md[k] = to.Ptr(v)
+	}
+	return md
+}
+
+// s3Region maps a GetBucketLocation constraint to its region. Buckets in
+// us-east-1 report no constraint and old eu-west-1 buckets report "EU".
+func s3Region(c s3types.BucketLocationConstraint) string {
+	switch c {
+	case "":
+		return "us-east-1"
+	case s3types.BucketLocationConstraintEu:
+		return "eu-west-1"
+	}
+	return string(c)
+}
+
+// sameRegion compares region names the way the clouds treat them: GCS
+// reports locations in upper case and Azure accepts display names.
+func sameRegion(a, b string) bool {
+	return strings.EqualFold(strings.ReplaceAll(a, " ", ""), strings.ReplaceAll(b, " ", ""))
+}
+
+// bucketRegion returns the region the bucket actually lives in.
+func (r *BucketResource) bucketRegion(ctx context.Context, state *bucketResourceModel) (string, error) {
+	switch state.Type.ValueString() {
+	case "aws":
+		out, err := r.s3.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: aws.String(state.ID.ValueString())})
+		if err != nil {
+			return "", err
+		}
+		return s3Region(out.LocationConstraint), nil
+	case "azure":
+		acct, err := r.azureAcct.GetProperties(ctx, state.ResourceGroup.ValueString(), state.Account.ValueString(), nil)
+		if err != nil {
+			return "", err
+		}
+		if acct.Location == nil {
+			return "", fmt.Errorf("storage account %s has no location", state.Account.ValueString())
+		}
+		return *acct.Location, nil
+	case "gcp":
+		attrs, err := r.gcpStorage.BucketAttrs(ctx, state.ID.ValueString())
+		if err != nil {
+			return "", err
+		}
+		return attrs.Location, nil
+	}
+	return "", fmt.Errorf("unsupported cloud %s", state.Type.ValueString())
+}
+
+// setRegion records the actual region unless it only differs from the
+// configured one in spelling, which would otherwise force a replacement. On
+// Azure a configured region is always kept: the container lives wherever its
+// storage account is, and replacing the bucket would not move the account.
+func (m *bucketResourceModel) setRegion(actual string) {
+	if m.Region.IsNull() || m.Region.IsUnknown() {
+		m.Region = types.StringValue(actual)
+		return
+	}
+	if m.Type.ValueString() != "azure" && !sameRegion(m.Region.ValueString(), actual) {
+		m.Region = types.StringValue(actual)
+	}
+}
+
+// unknownAsNull clears the computed attributes Create could not record.
+func (m *bucketResourceModel) unknownAsNull() {
+	for _, v := range []*types.String{&m.Region, &m.ARN, &m.DomainName, &m.CloudID, &m.PublicAccess} {
+		if v.IsUnknown() {
+			*v = types.StringNull()
+		}
+	}
+}
+
+// emptyS3 deletes every object version and delete marker in the bucket, a
+// page of up to 1000 at a time.
+func (r *BucketResource) emptyS3(ctx context.Context, bucket string) error {
+	input := &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)}
+	for {
+		page, err := r.s3.ListObjectVersions(ctx, input)
+		if err != nil {
+			return err
+		}
+		var objects []s3types.ObjectIdentifier
+		for _, v := range page.Versions {
+			objects = append(objects, s3types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+		}
+		for _, m := range page.DeleteMarkers {
+			objects = append(objects, s3types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+		}
+		if len(objects) > 0 {
+			out, err := r.s3.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: aws.String(bucket),
+				Delete: &s3types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+			})
+			if err != nil {
+				return err
+			}
+			if len(out.Errors) > 0 {
+				e := out.Errors[0]
+				return fmt.Errorf("delete %s: %s", aws.ToString(e.Key), aws.ToString(e.Message))
+			}
+		}
+		if !aws.ToBool(page.IsTruncated) {
+			return nil
+		}
+		input.KeyMarker = page.NextKeyMarker
+		input.VersionIdMarker = page.NextVersionIdMarker
+	}
+}
+
+// s3PublicAccessBlocked reports whether the bucket's public access block
+// blocks every kind of public access. A bucket without one is public.
+func (r *BucketResource) s3PublicAccessBlocked(ctx context.Context, bucket string) (bool, error) {
+	out, err := r.s3.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: aws.String(bucket)})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchPublicAccessBlockConfiguration" {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	c := out.PublicAccessBlockConfiguration
+	return c != nil && aws.ToBool(c.BlockPublicAcls) && aws.ToBool(c.IgnorePublicAcls) &&
+		aws.ToBool(c.BlockPublicPolicy) && aws.ToBool(c.RestrictPublicBuckets), nil
+}
+
+// setAzurePublicAccess sets anonymous read access to the bucket's blobs on its
+// container. An account created for the bucket also allows or disallows public
+// access as a whole; a shared or reused account is left alone and must allow
+// it for "allowed" to take effect.
+func (r *BucketResource) setAzurePublicAccess(ctx context.Context, m *bucketResourceModel, blocked bool) error {
+	rg, acct := m.ResourceGroup.ValueString(), m.Account.ValueString()
+	if m.AccountCreated.ValueBool() {
+		params := armstorage.AccountUpdateParameters{Properties: &armstorage.AccountPropertiesUpdateParameters{
+			AllowBlobPublicAccess: to.Ptr(!blocked),
+		}}
+		if _, err := r.azureAcct.Update(ctx, rg, acct, params, nil); err != nil {
+			return err
+		}
+	}
+	access := armstorage.PublicAccessBlob
+	if blocked {
+		access = armstorage.PublicAccessNone
+	}
+	_, err := r.azureCont.Update(ctx, rg, acct, m.ID.ValueString(), armstorage.BlobContainer{ContainerProperties: &armstorage.ContainerProperties{PublicAccess: &access}}, nil)
+	return err
+}
+
+// setAzureVersioning turns blob versioning on or off. Versioning is a storage
+// account setting covering every container in it, so it is only changed on an
+// account created for the bucket; a shared or reused account must already match.
+func (r *BucketResource) setAzureVersioning(ctx context.Context, m *bucketResourceModel, enabled bool) error {
+	rg, acct := m.ResourceGroup.ValueString(), m.Account.ValueString()
+	current, err := r.azureBlob.GetServiceProperties(ctx, rg, acct, nil)
+	if err != nil {
+		return err
+	}
+	if azureVersioning(current.BlobServiceProperties) == enabled {
+		return nil
+	}
+	if !m.AccountCreated.ValueBool() {
+		state := "off"
+		if !enabled {
+			state = "on"
+		}
+		return fmt.Errorf("blob versioning is %s in storage account %s, which this bucket does not own; change it on the account or leave versioning unset", state, acct)
+	}
+	// the service properties are replaced as a whole, so keep the rest
+	props := current.BlobServiceProperties
+	if props.BlobServiceProperties == nil {
+		props.BlobServiceProperties = &armstorage.BlobServicePropertiesProperties{}
+	}
+	props.BlobServiceProperties.IsVersioningEnabled = to.Ptr(enabled)
+	_, err = r.azureBlob.SetServiceProperties(ctx, rg, acct, armstorage.BlobServiceProperties{BlobServiceProperties: props.BlobServiceProperties}, nil)
+	return err
+}
+
+// azureVersioning reports whether blob versioning is on for a storage account.
+func azureVersioning(props armstorage.BlobServiceProperties) bool {
+	return props.BlobServiceProperties != nil && props.BlobServiceProperties.IsVersioningEnabled != nil && *props.BlobServiceProperties.IsVersioningEnabled
+}
+
+// setVersioning records the versioning reported by the cloud. An unset
+// versioning stays null: on Azure another container in a shared account may
+// have turned it on.
+func (m *bucketResourceModel) setVersioning(enabled bool) {
+	if m.Versioning.IsNull() {
+		return
+	}
+	m.Versioning = types.BoolValue(enabled)
+}
+
+// azureBlobClient returns a blob service client for the bucket's storage account.
+func (r *BucketResource) azureBlobClient(ctx context.Context, state *bucketResourceModel) (*azblob.Client, error) {
+	acctName := state.Account.ValueString()
+	keys, err := r.azureAcct.ListKeys(ctx, state.ResourceGroup.ValueString(), acctName, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys.Keys) == 0 {
+		return nil, fmt.Errorf("no keys for storage account %s", acctName)
+	}
+	cred, err := azblob.NewSharedKeyCredential(acctName, *keys.Keys[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	return azblob.NewClientWithSharedKeyCredential("https://"+acctName+".blob.core.windows.net/", cred, nil)
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *BucketResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.s3 = c.S3
 	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *BucketResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.s3 != nil
+	case "azure":
+		return r.azureRG != nil && r.azureAcct != nil && r.azureCont != nil && r.azureBlob != nil
+	case "gcp":
+		return r.gcpStorage != nil
+	}
+	return false
+}
+
+func (r *BucketResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan bucketResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = types.StringValue(plan.Name.ValueString())
+	plan.CloudID = types.StringNull()
+	plan.Account = types.StringNull()
+	plan.ResourceGroup = types.StringNull()
+	plan.AccountCreated = types.BoolNull()
+	plan.Project = types.StringNull()
+	plan.ConnectionString = types.StringNull()
 
 	switch plan.Type.ValueString() {
 	case "aws":
 		input := &s3.CreateBucketInput{Bucket: aws.String(plan.Name.ValueString())}
-		if plan.Region.ValueString() != "" {
+		// us-east-1 is the default location and S3 rejects it as an explicit constraint
+		if region := plan.Region.ValueString(); region != "" && region != "us-east-1" {
 			input.CreateBucketConfiguration = &s3types.CreateBucketConfiguration{LocationConstraint: s3types.BucketLocationConstraint(plan.Region.ValueString())}
 		}
 		_, err := r.s3.CreateBucket(ctx, input)
@@ -100,60 +699,68 @@ func (r *BucketResource) Create(ctx context.Context, req resource.CreateRequest,
 			resp.Diagnostics.AddError("aws create", err.Error())
 			return
 		}
-		if plan.Versioning.ValueBool() {
-			_, err = r.s3.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
-				Bucket:                  aws.String(plan.Name.ValueString()),
-				VersioningConfiguration: &s3types.VersioningConfiguration{Status: s3types.BucketVersioningStatusEnabled},
-			})
-			if err != nil {
-				resp.Diagnostics.AddError("aws versioning", err.Error())
-				return
-			}
-		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":         plan.Name.ValueString(),
-			"name":       plan.Name.ValueString(),
-			"type":       plan.Type.ValueString(),
-			"region":     plan.Region.ValueString(),
-			"versioning": plan.Versioning.ValueBool(),
+		changes := diffBucket(&plan, &bucketResourceModel{})
+		changes.publicAccess = true
+		// default tags apply even when the bucket has none of its own
+		changes.tags = changes.tags || len(r.defaultTags) > 0
+		// the new bucket may not be visible yet; the settings calls are idempotent
+		err = shared.RetryAWS(ctx, func() error {
+			return r.reconcileS3(ctx, &plan, changes)
 		})
-	case "azure":
-		if r.azureAcct == nil || r.azureCont == nil || r.azureRG == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
+		if err != nil {
+			resp.Diagnostics.AddError("aws configure", err.Error())
+			// keep the bucket in state so it is not leaked; Read fills in the rest
+			plan.unknownAsNull()
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 			return
 		}
-		rgName := "abstract-rg"
-		if r.azureLoc == "" && plan.Region.ValueString() != "" {
-			r.azureLoc = plan.Region.ValueString()
-		}
-		_, err := r.azureRG.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{Location: &r.azureLoc}, nil)
+		var region string
+		err = shared.RetryAWS(ctx, func() error {
+			var err error
+			region, err = r.bucketRegion(ctx, &plan)
+			return err
+		})
 		if err != nil {
-			resp.Diagnostics.AddError("azure rg", err.Error())
+			resp.Diagnostics.AddError("aws region", err.Error())
+			// keep the bucket in state so it is not leaked; Read fills in the region
+			plan.unknownAsNull()
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 			return
 		}
-		acctName := plan.Name.ValueString()
-		acctName = strings.ToLower(acctName)
-		if len(acctName) > 24 {
-			acctName = acctName[:24]
+		plan.setRegion(region)
+	case "azure":
+		if !plan.KMSKeyID.IsNull() || !plan.ExpirationDays.IsNull() {
+			resp.Diagnostics.AddWarning("bucket settings ignored", azureBucketSettingsWarning)
 		}
-		poller, err := r.azureAcct.BeginCreate(ctx, rgName, acctName, armstorage.AccountCreateParameters{
-			Location: &r.azureLoc,
-			Kind:     to.Ptr(armstorage.KindStorageV2),
-			SKU:      &armstorage.SKU{Name: to.Ptr(armstorage.SKUNameStandardLRS)},
-		}, nil)
-		if err == nil {
-			_, err = poller.PollUntilDone(ctx, nil)
+		if acct := storageAccountName(plan.Name.ValueString()); r.azureSharedAcct == "" && !shared.ValidAzureStorageAccount(acct) {
+			resp.Diagnostics.AddAttributeError(path.Root("name"), "invalid storage account name",
+				fmt.Sprintf("the storage account name %q derived from the bucket name must be 3 to 24 lowercase letters and digits; rename the bucket or set storage_account in the provider's azure block", acct))
+			return
 		}
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		store := azureStorage{rg: r.azureRG, acct: r.azureAcct, loc: loc, sharedAcct: r.azureSharedAcct, sharedRG: r.azureSharedRG}
+		acctName, rgName, created, err := store.ensure(ctx, plan.Name.ValueString())
 		if err != nil {
 			resp.Diagnostics.AddError("azure create account", err.Error())
 			return
 		}
+		plan.Account = types.StringValue(acctName)
+		plan.ResourceGroup = types.StringValue(rgName)
+		plan.AccountCreated = types.BoolValue(created)
+		// checked before the container exists, since a shared account may not match
+		if !plan.Versioning.IsNull() {
+			if err := r.setAzureVersioning(ctx, &plan, plan.Versioning.ValueBool()); err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("versioning"), "azure versioning", err.Error())
+				return
+			}
+		}
 		keys, err := r.azureAcct.ListKeys(ctx, rgName, acctName, nil)
 		if err != nil || keys.Keys == nil || len(keys.Keys) == 0 {
 			resp.Diagnostics.AddError("azure keys", "unable to get account key")
 			return
 		}
 		key := *keys.Keys[0].Value
+		plan.ConnectionString = types.StringValue(azureConnectionString(acctName, key))
 		cred, err := azblob.NewSharedKeyCredential(acctName, key)
 		if err != nil {
 			resp.Diagnostics.AddError("azure cred", err.Error())
@@ -164,76 +771,137 @@ func (r *BucketResource) Create(ctx context.Context, req resource.CreateRequest,
 			resp.Diagnostics.AddError("azure svc", err.Error())
 			return
 		}
-		_, err = svc.CreateContainer(ctx, plan.Name.ValueString(), nil)
+		_, err = svc.CreateContainer(ctx, plan.Name.ValueString(), &azblob.CreateContainerOptions{Metadata: azureMetadata(shared.MergeTags(r.defaultTags, stringMap(plan.Tags)))})
 		if err != nil {
 			resp.Diagnostics.AddError("azure container", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":             plan.Name.ValueString(),
-			"name":           plan.Name.ValueString(),
-			"type":           plan.Type.ValueString(),
-			"region":         r.azureLoc,
-			"versioning":     plan.Versioning.ValueBool(),
-			"account":        acctName,
-			"resource_group": rgName,
-		})
-	case "gcp":
-		if r.gcpStorage == nil {
-			resp.Diagnostics.AddError("gcp", "missing client")
+		if err := r.setAzurePublicAccess(ctx, &plan, plan.publicAccessBlocked()); err != nil {
+			resp.Diagnostics.AddError("azure public access", err.Error())
+			// keep the container in state so it is not leaked
+			plan.unknownAsNull()
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 			return
 		}
+		// a reused or shared account may be in another location
+		region, err := r.bucketRegion(ctx, &plan)
+		if err != nil {
+			resp.Diagnostics.AddError("azure region", err.Error())
+			plan.unknownAsNull()
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+			return
+		}
+		if want := plan.Region.ValueString(); want != "" && !sameRegion(want, region) {
+			resp.Diagnostics.AddAttributeWarning(path.Root("region"), "region not applied",
+				fmt.Sprintf("the container is in storage account %s, which is in %s", acctName, region))
+		}
+		plan.setRegion(region)
+	case "gcp":
 		region := plan.Region.ValueString()
 		if region == "" {
 			region = r.gcpRegion
 		}
-		attrs := &storage.BucketAttrs{Location: region}
-		if plan.Versioning.ValueBool() {
-			attrs.VersioningEnabled = true
+		attrs := &storage.BucketAttrs{Location: region, VersioningEnabled: plan.Versioning.ValueBool()}
+		if labels := plan.gcsLabels(r.defaultTags); len(labels) > 0 {
+			attrs.Labels = labels
+		}
+		if key := plan.KMSKeyID.ValueString(); key != "" {
+			attrs.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: key}
+		}
+		if days := plan.ExpirationDays.ValueInt64(); days > 0 {
+			attrs.Lifecycle = *gcsLifecycle(days)
 		}
-		err := r.gcpStorage.Bucket(plan.Name.ValueString()).Create(ctx, r.gcpProject, attrs)
+		if plan.publicAccessBlocked() {
+			attrs.UniformBucketLevelAccess = storage.UniformBucketLevelAccess{Enabled: true}
+			attrs.PublicAccessPrevention = storage.PublicAccessPreventionEnforced
+		}
+		err := r.gcpStorage.CreateBucket(ctx, plan.Name.ValueString(), r.gcpProject, attrs)
 		if err != nil {
 			resp.Diagnostics.AddError("gcp create", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":         plan.Name.ValueString(),
-			"name":       plan.Name.ValueString(),
-			"type":       plan.Type.ValueString(),
-			"region":     region,
-			"versioning": plan.Versioning.ValueBool(),
-			"project":    r.gcpProject,
-		})
+		region, err = r.bucketRegion(ctx, &plan)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp region", err.Error())
+			return
+		}
+		plan.setRegion(region)
+		plan.Project = types.StringValue(r.gcpProject)
 	default:
-		resp.Diagnostics.AddError("unsupported cloud", "only aws implemented")
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
 	}
+	plan.setPublicAccess(plan.publicAccessBlocked())
+	plan.setEndpoints()
+	id, err := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
-func (r *BucketResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID            types.String `tfsdk:"id"`
-		Type          types.String `tfsdk:"type"`
-		Account       types.String `tfsdk:"account"`
-		ResourceGroup types.String `tfsdk:"resource_group"`
-		Project       types.String `tfsdk:"project"`
+// setEndpoints records the bucket's ARN and domain name, which follow from
+// its name, region and account.
+func (m *bucketResourceModel) setEndpoints() {
+	name := m.Name.ValueString()
+	m.ARN = types.StringNull()
+	m.DomainName = types.StringNull()
+	switch m.Type.ValueString() {
+	case "aws":
+		region := m.Region.ValueString()
+		m.ARN = types.StringValue(fmt.Sprintf("arn:%s:s3:::%s", shared.AWSPartition(region), name))
+		suffix := "amazonaws.com"
+		if shared.AWSPartition(region) == "aws-cn" {
+			suffix = "amazonaws.com.cn"
+		}
+		m.DomainName = types.StringValue(fmt.Sprintf("%s.s3.%s.%s", name, region, suffix))
+	case "azure":
+		m.DomainName = types.StringValue(m.Account.ValueString() + ".blob.core.windows.net")
+	case "gcp":
+		m.DomainName = types.StringValue("storage.googleapis.com/" + name)
 	}
+}
+
+// cloudID is the bucket's ARN, container resource ID or GCS self link.
+func (r *BucketResource) cloudID(ctx context.Context, m *bucketResourceModel) (string, error) {
+	name := m.Name.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.AccountlessARN("s3", false, name), nil
+	case "azure":
+		return r.ids.AzureID(m.ResourceGroup.ValueString(), "Microsoft.Storage/storageAccounts", m.Account.ValueString()+"/blobServices/default/containers/"+name), nil
+	case "gcp":
+		return "https://www.googleapis.com/storage/v1/b/" + name, nil
+	}
+	return "", nil
+}
+
+func (r *BucketResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state bucketResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
 		_, err := r.s3.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(state.ID.ValueString())})
 		if err != nil {
 			resp.Diagnostics.AddError("aws read", err.Error())
 			resp.State.RemoveResource(ctx)
+			return
 		}
-	case "azure":
-		if r.azureAcct == nil || r.azureCont == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
+		blocked, err := r.s3PublicAccessBlocked(ctx, state.ID.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("aws read public access", err.Error())
 			return
 		}
+		state.setPublicAccess(blocked)
+	case "azure":
 		keys, err := r.azureAcct.ListKeys(ctx, state.ResourceGroup.ValueString(), state.Account.ValueString(), nil)
 		if err != nil || keys.Keys == nil || len(keys.Keys) == 0 {
 			resp.Diagnostics.AddError("azure keys", "unable to get account key")
@@ -241,6 +909,8 @@ func (r *BucketResource) Read(ctx context.Context, req resource.ReadRequest, res
 			return
 		}
 		key := *keys.Keys[0].Value
+		// a rotated key shows up in the connection string
+		state.ConnectionString = types.StringValue(azureConnectionString(state.Account.ValueString(), key))
 		cred, err := azblob.NewSharedKeyCredential(state.Account.ValueString(), key)
 		if err != nil {
 			resp.Diagnostics.AddError("azure cred", err.Error())
@@ -252,88 +922,145 @@ func (r *BucketResource) Read(ctx context.Context, req resource.ReadRequest, res
 			return
 		}
 		cont := svc.ServiceClient().NewContainerClient(state.ID.ValueString())
-		_, err = cont.GetProperties(ctx, nil)
+		contProps, err := cont.GetProperties(ctx, nil)
 		if err != nil {
 			resp.Diagnostics.AddError("azure read", err.Error())
 			resp.State.RemoveResource(ctx)
+			return
 		}
-	case "gcp":
-		if r.gcpStorage == nil {
-			resp.Diagnostics.AddError("gcp", "missing client")
+		props, err := r.azureBlob.GetServiceProperties(ctx, state.ResourceGroup.ValueString(), state.Account.ValueString(), nil)
+		if err != nil {
+			resp.Diagnostics.AddError("azure read versioning", err.Error())
 			return
 		}
-		_, err := r.gcpStorage.Bucket(state.ID.ValueString()).Attrs(ctx)
+		state.setVersioning(azureVersioning(props.BlobServiceProperties))
+		acct, err := r.azureAcct.GetProperties(ctx, state.ResourceGroup.ValueString(), state.Account.ValueString(), nil)
+		if err != nil {
+			resp.Diagnostics.AddError("azure read public access", err.Error())
+			return
+		}
+		// accounts created since late 2023 leave AllowBlobPublicAccess unset and
+		// off, which overrides the container's own access level
+		acctBlocked := acct.Properties == nil || acct.Properties.AllowBlobPublicAccess == nil || !*acct.Properties.AllowBlobPublicAccess
+		state.setPublicAccess(acctBlocked || contProps.BlobPublicAccess == nil)
+	case "gcp":
+		attrs, err := r.gcpStorage.BucketAttrs(ctx, state.ID.ValueString())
 		if err != nil {
 			resp.Diagnostics.AddError("gcp read", err.Error())
 			resp.State.RemoveResource(ctx)
+			return
 		}
+		state.setPublicAccess(attrs.UniformBucketLevelAccess.Enabled && attrs.PublicAccessPrevention == storage.PublicAccessPreventionEnforced)
+	default:
+		return
 	}
+	region, err := r.bucketRegion(ctx, &state)
+	if err != nil {
+		resp.Diagnostics.AddError("read region", err.Error())
+		return
+	}
+	state.setRegion(region)
+	state.setEndpoints()
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
 }
 
 func (r *BucketResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var plan struct {
-		Name       types.String `tfsdk:"name"`
-		Type       types.String `tfsdk:"type"`
-		Versioning types.Bool   `tfsdk:"versioning"`
-	}
-	diags := req.Plan.Get(ctx, &plan)
-	resp.Diagnostics.Append(diags...)
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state bucketResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	changes := diffBucket(&plan, &state)
 	switch plan.Type.ValueString() {
 	case "aws":
-		status := s3types.BucketVersioningStatusSuspended
-		if plan.Versioning.ValueBool() {
-			status = s3types.BucketVersioningStatusEnabled
-		}
-		_, err := r.s3.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
-			Bucket:                  aws.String(plan.Name.ValueString()),
-			VersioningConfiguration: &s3types.VersioningConfiguration{Status: status},
-		})
-		if err != nil {
+		if err := r.reconcileS3(ctx, &plan, changes); err != nil {
 			resp.Diagnostics.AddError("aws update", err.Error())
 			return
 		}
-	case "gcp":
-		if r.gcpStorage == nil {
-			resp.Diagnostics.AddError("gcp", "missing client")
+	case "azure":
+		if changes.encryption || changes.lifecycle {
+			resp.Diagnostics.AddWarning("bucket settings ignored", azureBucketSettingsWarning)
+		}
+		// unsetting versioning turns it off in an owned account and leaves a shared one alone
+		if changes.versioning && (!plan.Versioning.IsNull() || state.AccountCreated.ValueBool()) {
+			if err := r.setAzureVersioning(ctx, &state, plan.Versioning.ValueBool()); err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("versioning"), "azure versioning", err.Error())
+				return
+			}
+		}
+		if changes.publicAccess {
+			if err := r.setAzurePublicAccess(ctx, &state, plan.publicAccessBlocked()); err != nil {
+				resp.Diagnostics.AddError("azure public access", err.Error())
+				return
+			}
+		}
+		if !changes.tags {
+			break
+		}
+		svc, err := r.azureBlobClient(ctx, &state)
+		if err != nil {
+			resp.Diagnostics.AddError("azure svc", err.Error())
 			return
 		}
-		_, err := r.gcpStorage.Bucket(plan.Name.ValueString()).Update(ctx, storage.BucketAttrsToUpdate{
-			VersioningEnabled: plan.Versioning.ValueBool(),
-		})
+		cont := svc.ServiceClient().NewContainerClient(state.ID.ValueString())
+		_, err = cont.SetMetadata(ctx, &container.SetMetadataOptions{Metadata: azureMetadata(shared.MergeTags(r.defaultTags, stringMap(plan.Tags)))})
 		if err != nil {
+			resp.Diagnostics.AddError("azure update", err.Error())
+			return
+		}
+	case "gcp":
+		if err := r.reconcileGCS(ctx, &plan, &state, changes); err != nil {
 			resp.Diagnostics.AddError("gcp update", err.Error())
 			return
 		}
 	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	plan.Account = state.Account
+	plan.ResourceGroup = state.ResourceGroup
+	plan.AccountCreated = state.AccountCreated
+	plan.Project = state.Project
+	plan.ARN = state.ARN
+	plan.DomainName = state.DomainName
+	plan.ConnectionString = state.ConnectionString
+	plan.setPublicAccess(plan.publicAccessBlocked())
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
 func (r *BucketResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		ID            types.String `tfsdk:"id"`
-		Type          types.String `tfsdk:"type"`
-		Account       types.String `tfsdk:"account"`
-		ResourceGroup types.String `tfsdk:"resource_group"`
-		Project       types.String `tfsdk:"project"`
-	}
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state bucketResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
+		if state.ForceDestroy.ValueBool() {
+			if err := r.emptyS3(ctx, state.ID.ValueString()); err != nil {
+				resp.Diagnostics.AddError("aws empty bucket", err.Error())
+				return
+			}
+		}
 		_, err := r.s3.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(state.ID.ValueString())})
 		if err != nil {
 			resp.Diagnostics.AddError("aws delete", err.Error())
 		}
 	case "azure":
-		if r.azureAcct == nil || r.azureCont == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
-			return
-		}
 		keys, err := r.azureAcct.ListKeys(ctx, state.ResourceGroup.ValueString(), state.Account.ValueString(), nil)
 		if err != nil || keys.Keys == nil || len(keys.Keys) == 0 {
 			resp.Diagnostics.AddError("azure keys", "unable to get account key")
@@ -354,17 +1081,21 @@ func (r *BucketResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		if err != nil {
 			resp.Diagnostics.AddError("azure delete", err.Error())
 		}
-		// also delete storage account
+		if !state.AccountCreated.ValueBool() {
+			return
+		}
 		_, err = r.azureAcct.Delete(ctx, state.ResourceGroup.ValueString(), state.Account.ValueString(), nil)
 		if err != nil {
 			resp.Diagnostics.AddError("azure delete account", err.Error())
 		}
 	case "gcp":
-		if r.gcpStorage == nil {
-			resp.Diagnostics.AddError("gcp", "missing client")
-			return
+		if state.ForceDestroy.ValueBool() {
+			if err := r.gcpStorage.DeleteObjects(ctx, state.ID.ValueString()); err != nil {
+				resp.Diagnostics.AddError("gcp empty bucket", err.Error())
+				return
+			}
 		}
-		err := r.gcpStorage.Bucket(state.ID.ValueString()).Delete(ctx)
+		err := r.gcpStorage.DeleteBucket(ctx, state.ID.ValueString())
 		if err != nil {
 			resp.Diagnostics.AddError("gcp delete", err.Error())
 		}
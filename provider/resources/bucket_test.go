@@ -0,0 +1,743 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"abstract-provider/provider/shared"
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func createBucket(t *testing.T, r *BucketResource, vals map[string]tftypes.Value) (bucketResourceModel, *resource.CreateResponse) {
+	t.Helper()
+	ctx := context.Background()
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, vals)}, resp)
+	var got bucketResourceModel
+	if !resp.Diagnostics.HasError() {
+		resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	}
+	return got, resp
+}
+
+func TestBucketCreateAWS(t *testing.T) {
+	s3 := newFakeS3()
+	r := &BucketResource{s3: s3}
+	got, resp := createBucket(t, r, map[string]tftypes.Value{
+		"name":       str("assets"),
+		"type":       str("aws"),
+		"region":     str("eu-west-1"),
+		"versioning": boolean(true),
+	})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	in, ok := s3.buckets["assets"]
+	if !ok {
+		t.Fatal("bucket not created")
+	}
+	if in.CreateBucketConfiguration == nil || in.CreateBucketConfiguration.LocationConstraint != "eu-west-1" {
+		t.Errorf("location constraint = %+v, want eu-west-1", in.CreateBucketConfiguration)
+	}
+	if s3.versioning["assets"] != s3types.BucketVersioningStatusEnabled {
+		t.Errorf("versioning = %q, want Enabled", s3.versioning["assets"])
+	}
+	if got.ID.ValueString() != "assets" {
+		t.Errorf("id = %q, want assets", got.ID.ValueString())
+	}
+	if got.ARN.ValueString() != "arn:aws:s3:::assets" || got.DomainName.ValueString() != "assets.s3.eu-west-1.amazonaws.com" {
+		t.Errorf("arn, domain_name = %q, %q", got.ARN.ValueString(), got.DomainName.ValueString())
+	}
+	if c := s3.publicAccess["assets"]; c == nil || !*c.BlockPublicAcls || !*c.RestrictPublicBuckets || got.PublicAccess.ValueString() != "blocked" {
+		t.Errorf("public access = %+v, %v; want blocked by default", c, got.PublicAccess)
+	}
+	if !got.Account.IsNull() || !got.Project.IsNull() || !got.ConnectionString.IsNull() {
+		t.Errorf("unexpected azure/gcp attributes: %+v", got)
+	}
+}
+
+func TestBucketCreateAWSCloudID(t *testing.T) {
+	r := &BucketResource{s3: newFakeS3(), ids: shared.NewCloudIDs("cn-north-1", "", "", nil)}
+	got, resp := createBucket(t, r, map[string]tftypes.Value{
+		"name": str("assets"),
+		"type": str("aws"),
+	})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if got.CloudID.ValueString() != "arn:aws-cn:s3:::assets" {
+		t.Errorf("cloud_id = %q, want arn:aws-cn:s3:::assets", got.CloudID.ValueString())
+	}
+}
+
+func TestBucketCreateAWSDefaults(t *testing.T) {
+	s3 := newFakeS3()
+	r := &BucketResource{s3: s3}
+	got, resp := createBucket(t, r, map[string]tftypes.Value{
+		"name": str("assets"),
+		"type": str("aws"),
+	})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if s3.buckets["assets"].CreateBucketConfiguration != nil {
+		t.Error("location constraint set without region")
+	}
+	if got.Region.ValueString() != "us-east-1" {
+		t.Errorf("region = %q, want us-east-1", got.Region.ValueString())
+	}
+	if _, ok := s3.versioning["assets"]; ok {
+		t.Error("versioning configured without versioning = true")
+	}
+}
+
+func TestBucketCreateAWSUSEast1(t *testing.T) {
+	s3 := newFakeS3()
+	r := &BucketResource{s3: s3}
+	_, resp := createBucket(t, r, map[string]tftypes.Value{
+		"name":   str("assets"),
+		"type":   str("aws"),
+		"region": str("us-east-1"),
+	})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if s3.buckets["assets"].CreateBucketConfiguration != nil {
+		t.Error("location constraint sent for us-east-1")
+	}
+}
+
+func TestBucketCreateAWSError(t *testing.T) {
+	s3 := newFakeS3()
+	s3.err = errors.New("BucketAlreadyExists")
+	r := &BucketResource{s3: s3}
+	_, resp := createBucket(t, r, map[string]tftypes.Value{
+		"name": str("assets"),
+		"type": str("aws"),
+	})
+	if !resp.Diagnostics.HasError() {
+		t.Fatal("expected error")
+	}
+	if !resp.State.Raw.IsNull() {
+		t.Error("state written after failed create")
+	}
+}
+
+func TestBucketCreateAWSConfigureError(t *testing.T) {
+	s3 := newFakeS3()
+	s3.versioningErr = errors.New("AccessDenied")
+	r := &BucketResource{s3: s3}
+	_, resp := createBucket(t, r, map[string]tftypes.Value{
+		"name":       str("assets"),
+		"type":       str("aws"),
+		"versioning": boolean(true),
+	})
+	if !resp.Diagnostics.HasError() {
+		t.Fatal("expected error")
+	}
+	// the bucket exists, so it must stay in state
+	var got bucketResourceModel
+	resp.State.Get(context.Background(), &got)
+	if got.ID.ValueString() != "assets" || !resp.State.Raw.IsFullyKnown() {
+		t.Errorf("state = %v", resp.State.Raw)
+	}
+}
+
+func TestBucketSetRegion(t *testing.T) {
+	for _, tc := range []struct {
+		cloud      string
+		configured types.String
+		actual     string
+		want       string
+	}{
+		{"aws", types.StringUnknown(), "us-east-1", "us-east-1"},
+		{"aws", types.StringValue("us-west-2"), "eu-west-1", "eu-west-1"},
+		{"azure", types.StringValue("East US"), "eastus", "East US"},
+		// a shared account elsewhere does not replace the bucket on every plan
+		{"azure", types.StringValue("westeurope"), "eastus", "westeurope"},
+		{"azure", types.StringUnknown(), "eastus", "eastus"},
+	} {
+		m := bucketResourceModel{Type: types.StringValue(tc.cloud), Region: tc.configured}
+		m.setRegion(tc.actual)
+		if got := m.Region.ValueString(); got != tc.want {
+			t.Errorf("%s %v in %s: region = %q, want %q", tc.cloud, tc.configured, tc.actual, got, tc.want)
+		}
+	}
+}
+
+func TestBucketCreateGCP(t *testing.T) {
+	gcs := newFakeGCS()
+	r := &BucketResource{gcpStorage: gcs, gcpProject: "proj", gcpRegion: "us-central1"}
+	got, resp := createBucket(t, r, map[string]tftypes.Value{
+		"name":       str("assets"),
+		"type":       str("gcp"),
+		"versioning": boolean(true),
+	})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	attrs, ok := gcs.buckets["assets"]
+	if !ok {
+		t.Fatal("bucket not created")
+	}
+	if attrs.Location != "us-central1" {
+		t.Errorf("location = %q, want provider default us-central1", attrs.Location)
+	}
+	if !attrs.VersioningEnabled {
+		t.Error("versioning not enabled")
+	}
+	if gcs.projects["assets"] != "proj" || got.Project.ValueString() != "proj" {
+		t.Errorf("project = %q/%q, want proj", gcs.projects["assets"], got.Project.ValueString())
+	}
+	if !got.ARN.IsNull() || got.DomainName.ValueString() != "storage.googleapis.com/assets" {
+		t.Errorf("arn, domain_name = %v, %v", got.ARN, got.DomainName)
+	}
+}
+
+func TestBucketDefaultTags(t *testing.T) {
+	s3 := newFakeS3()
+	r := &BucketResource{s3: s3, defaultTags: map[string]string{"owner": "platform", "team": "ops"}}
+	_, resp := createBucket(t, r, map[string]tftypes.Value{
+		"name": str("assets"),
+		"type": str("aws"),
+		"tags": strMap(map[string]string{"team": "web"}),
+	})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	got := map[string]string{}
+	for _, tag := range s3.tags["assets"] {
+		got[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	if len(got) != 2 || got["owner"] != "platform" || got["team"] != "web" {
+		t.Errorf("tags = %v, want owner=platform and the bucket's team=web", got)
+	}
+
+	// defaults are tagged even when the bucket has no tags of its own
+	s3 = newFakeS3()
+	r = &BucketResource{s3: s3, defaultTags: map[string]string{"owner": "platform"}}
+	if _, resp := createBucket(t, r, map[string]tftypes.Value{"name": str("logs"), "type": str("aws")}); resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if len(s3.tags["logs"]) != 1 {
+		t.Errorf("tags = %+v, want the default owner tag", s3.tags["logs"])
+	}
+
+	gcs := newFakeGCS()
+	r = &BucketResource{gcpStorage: gcs, gcpProject: "proj", gcpRegion: "us-central1", defaultTags: map[string]string{"owner": "platform", "team": "ops"}}
+	_, resp = createBucket(t, r, map[string]tftypes.Value{
+		"name":   str("assets"),
+		"type":   str("gcp"),
+		"labels": strMap(map[string]string{"team": "web"}),
+	})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if labels := gcs.buckets["assets"].Labels; len(labels) != 2 || labels["owner"] != "platform" || labels["team"] != "web" {
+		t.Errorf("labels = %v", labels)
+	}
+}
+
+func TestBucketCreateMissingClient(t *testing.T) {
+	for _, cloud := range []string{"aws", "azure", "gcp"} {
+		t.Run(cloud, func(t *testing.T) {
+			_, resp := createBucket(t, &BucketResource{}, map[string]tftypes.Value{
+				"name": str("assets"),
+				"type": str(cloud),
+			})
+			if !resp.Diagnostics.HasError() {
+				t.Fatal("expected error")
+			}
+			if d := resp.Diagnostics.Errors()[0]; d.Summary() != cloud+" not configured in provider block" {
+				t.Errorf("diagnostic = %q", d.Summary())
+			}
+		})
+	}
+}
+
+func TestBucketCreateUnsupportedCloud(t *testing.T) {
+	_, resp := createBucket(t, &BucketResource{s3: newFakeS3()}, map[string]tftypes.Value{
+		"name": str("assets"),
+		"type": str("oracle"),
+	})
+	if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Summary() != "unsupported cloud" {
+		t.Fatalf("diagnostics = %v", resp.Diagnostics)
+	}
+}
+
+func TestBucketUpdateVersioning(t *testing.T) {
+	s3 := newFakeS3()
+	r := &BucketResource{s3: s3}
+	ctx := context.Background()
+	state := map[string]tftypes.Value{
+		"id":         str("assets"),
+		"name":       str("assets"),
+		"type":       str("aws"),
+		"versioning": boolean(true),
+	}
+	plan := map[string]tftypes.Value{
+		"id":         str("assets"),
+		"name":       str("assets"),
+		"type":       str("aws"),
+		"versioning": boolean(false),
+	}
+	resp := &resource.UpdateResponse{State: testState(t, r, state)}
+	r.Update(ctx, resource.UpdateRequest{Plan: testPlan(t, r, plan), State: testState(t, r, state)}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("update: %v", resp.Diagnostics)
+	}
+	if s3.versioning["assets"] != s3types.BucketVersioningStatusSuspended {
+		t.Errorf("versioning = %q, want Suspended", s3.versioning["assets"])
+	}
+	var got bucketResourceModel
+	resp.State.Get(ctx, &got)
+	if got.ID.ValueString() != "assets" || got.Versioning.ValueBool() {
+		t.Errorf("state = %+v", got)
+	}
+}
+
+func updateBucket(t *testing.T, r *BucketResource, state, plan map[string]tftypes.Value) (bucketResourceModel, *resource.UpdateResponse) {
+	t.Helper()
+	ctx := context.Background()
+	resp := &resource.UpdateResponse{State: testState(t, r, state)}
+	r.Update(ctx, resource.UpdateRequest{Plan: testPlan(t, r, plan), State: testState(t, r, state)}, resp)
+	var got bucketResourceModel
+	if !resp.Diagnostics.HasError() {
+		resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	}
+	return got, resp
+}
+
+// awsBucketSettings describes an AWS bucket with every mutable setting configured.
+func awsBucketSettings() map[string]tftypes.Value {
+	return map[string]tftypes.Value{
+		"id":              str("assets"),
+		"name":            str("assets"),
+		"type":            str("aws"),
+		"versioning":      boolean(true),
+		"tags":            strMap(map[string]string{"team": "web"}),
+		"kms_key_id":      str("arn:aws:kms:eu-west-1:123456789012:key/one"),
+		"expiration_days": number(30),
+	}
+}
+
+// withAttrs returns a copy of vals with overrides applied.
+func withAttrs(vals, overrides map[string]tftypes.Value) map[string]tftypes.Value {
+	out := map[string]tftypes.Value{}
+	for k, v := range vals {
+		out[k] = v
+	}
+	for k, v := range overrides {
+		out[k] = v
+	}
+	return out
+}
+
+func TestBucketCreateAWSSettings(t *testing.T) {
+	s3 := newFakeS3()
+	r := &BucketResource{s3: s3}
+	_, resp := createBucket(t, r, withAttrs(awsBucketSettings(), map[string]tftypes.Value{"id": tftypes.NewValue(tftypes.String, tftypes.UnknownValue)}))
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if s3.kmsKeys["assets"] != "arn:aws:kms:eu-west-1:123456789012:key/one" {
+		t.Errorf("kms key = %q", s3.kmsKeys["assets"])
+	}
+	if s3.expiration["assets"] != 30 {
+		t.Errorf("expiration = %d, want 30", s3.expiration["assets"])
+	}
+	if tags := s3.tags["assets"]; len(tags) != 1 || *tags[0].Key != "team" || *tags[0].Value != "web" {
+		t.Errorf("tags = %+v", tags)
+	}
+}
+
+func TestBucketUpdateAWSPartial(t *testing.T) {
+	cases := []struct {
+		name  string
+		plan  map[string]tftypes.Value
+		calls []string
+	}{
+		{"unchanged", nil, nil},
+		{"tags", map[string]tftypes.Value{"tags": strMap(map[string]string{"team": "data"})}, []string{"PutBucketTagging"}},
+		{"encryption", map[string]tftypes.Value{"kms_key_id": str("arn:aws:kms:eu-west-1:123456789012:key/two")}, []string{"PutBucketEncryption"}},
+		{"lifecycle", map[string]tftypes.Value{"expiration_days": number(7)}, []string{"PutBucketLifecycleConfiguration"}},
+		{"versioning", map[string]tftypes.Value{"versioning": boolean(false)}, []string{"PutBucketVersioning"}},
+		{"public access", map[string]tftypes.Value{"public_access": str("allowed")}, []string{"PutPublicAccessBlock"}},
+		{"removed", map[string]tftypes.Value{
+			"tags":            tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil),
+			"kms_key_id":      tftypes.NewValue(tftypes.String, nil),
+			"expiration_days": tftypes.NewValue(tftypes.Number, nil),
+		}, []string{"DeleteBucketTagging", "DeleteBucketEncryption", "DeleteBucketLifecycle"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s3 := newFakeS3()
+			r := &BucketResource{s3: s3}
+			_, resp := updateBucket(t, r, awsBucketSettings(), withAttrs(awsBucketSettings(), tc.plan))
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("update: %v", resp.Diagnostics)
+			}
+			if !reflect.DeepEqual(s3.calls, tc.calls) {
+				t.Errorf("calls = %v, want %v", s3.calls, tc.calls)
+			}
+		})
+	}
+}
+
+func TestBucketUpdateGCPPartial(t *testing.T) {
+	gcs := newFakeGCS()
+	gcs.buckets["assets"] = &storage.BucketAttrs{
+		VersioningEnabled: true,
+		Labels:            map[string]string{"team": "web", "env": "dev"},
+		Encryption:        &storage.BucketEncryption{DefaultKMSKeyName: "keys/one"},
+	}
+	r := &BucketResource{gcpStorage: gcs}
+	state := map[string]tftypes.Value{
+		"id":         str("assets"),
+		"name":       str("assets"),
+		"type":       str("gcp"),
+		"versioning": boolean(true),
+		"tags":       strMap(map[string]string{"team": "web", "env": "dev"}),
+		"kms_key_id": str("keys/one"),
+	}
+	plan := withAttrs(state, nil)
+
+	if _, resp := updateBucket(t, r, state, plan); resp.Diagnostics.HasError() {
+		t.Fatalf("update: %v", resp.Diagnostics)
+	}
+	if len(gcs.updates) != 0 {
+		t.Fatalf("unchanged plan sent %d updates", len(gcs.updates))
+	}
+
+	plan["tags"] = strMap(map[string]string{"team": "web"})
+	if _, resp := updateBucket(t, r, state, plan); resp.Diagnostics.HasError() {
+		t.Fatalf("update: %v", resp.Diagnostics)
+	}
+	if len(gcs.updates) != 1 {
+		t.Fatalf("updates = %d, want 1", len(gcs.updates))
+	}
+	upd := gcs.updates[0]
+	if upd.VersioningEnabled != nil || upd.Encryption != nil || upd.Lifecycle != nil {
+		t.Errorf("label change touched other settings: %+v", upd)
+	}
+	attrs := gcs.buckets["assets"]
+	if !reflect.DeepEqual(attrs.Labels, map[string]string{"team": "web"}) {
+		t.Errorf("labels = %v", attrs.Labels)
+	}
+	if !attrs.VersioningEnabled || attrs.Encryption.DefaultKMSKeyName != "keys/one" {
+		t.Errorf("unrelated settings changed: %+v", attrs)
+	}
+
+	// labels are added to tags and win on a shared key
+	plan["labels"] = strMap(map[string]string{"env": "prod"})
+	if _, resp := updateBucket(t, r, state, plan); resp.Diagnostics.HasError() {
+		t.Fatalf("update: %v", resp.Diagnostics)
+	}
+	if want := map[string]string{"team": "web", "env": "prod"}; !reflect.DeepEqual(gcs.buckets["assets"].Labels, want) {
+		t.Errorf("labels = %v, want %v", gcs.buckets["assets"].Labels, want)
+	}
+}
+
+func TestBucketReadRemovesMissing(t *testing.T) {
+	r := &BucketResource{gcpStorage: newFakeGCS()}
+	state := testState(t, r, map[string]tftypes.Value{
+		"id":   str("gone"),
+		"name": str("gone"),
+		"type": str("gcp"),
+	})
+	resp := &resource.ReadResponse{State: state}
+	r.Read(context.Background(), resource.ReadRequest{State: state}, resp)
+	if !resp.State.Raw.IsNull() {
+		t.Error("missing bucket not removed from state")
+	}
+}
+
+func TestBucketReadRegion(t *testing.T) {
+	fake := newFakeS3()
+	fake.buckets["eu"] = &s3.CreateBucketInput{CreateBucketConfiguration: &s3types.CreateBucketConfiguration{LocationConstraint: "eu-west-1"}}
+	fake.buckets["legacy"] = &s3.CreateBucketInput{CreateBucketConfiguration: &s3types.CreateBucketConfiguration{LocationConstraint: s3types.BucketLocationConstraintEu}}
+	gcs := newFakeGCS()
+	gcs.buckets["gcs"] = &storage.BucketAttrs{Location: "EUROPE-WEST1"}
+	r := &BucketResource{s3: fake, gcpStorage: gcs}
+
+	cases := []struct {
+		name, cloud, id string
+		region          tftypes.Value
+		want            string
+	}{
+		{"aws unset", "aws", "eu", str(""), "eu-west-1"},
+		{"aws moved", "aws", "eu", str("us-west-2"), "eu-west-1"},
+		{"aws legacy EU", "aws", "legacy", str(""), "eu-west-1"},
+		{"gcp keeps spelling", "gcp", "gcs", str("europe-west1"), "europe-west1"},
+		{"gcp unset", "gcp", "gcs", tftypes.NewValue(tftypes.String, nil), "EUROPE-WEST1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := testState(t, r, map[string]tftypes.Value{
+				"id":     str(tc.id),
+				"name":   str(tc.id),
+				"type":   str(tc.cloud),
+				"region": tc.region,
+			})
+			resp := &resource.ReadResponse{State: state}
+			r.Read(context.Background(), resource.ReadRequest{State: state}, resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("read: %v", resp.Diagnostics)
+			}
+			var got bucketResourceModel
+			resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+			if got.Region.ValueString() != tc.want {
+				t.Errorf("region = %q, want %q", got.Region.ValueString(), tc.want)
+			}
+		})
+	}
+}
+
+func TestBucketReadPublicAccess(t *testing.T) {
+	fake := newFakeS3()
+	fake.buckets["open"] = &s3.CreateBucketInput{}
+	fake.buckets["partial"] = &s3.CreateBucketInput{}
+	fake.publicAccess["partial"] = &s3types.PublicAccessBlockConfiguration{BlockPublicAcls: aws.Bool(true), IgnorePublicAcls: aws.Bool(true)}
+	gcs := newFakeGCS()
+	gcs.buckets["gcs"] = &storage.BucketAttrs{UniformBucketLevelAccess: storage.UniformBucketLevelAccess{Enabled: true}, PublicAccessPrevention: storage.PublicAccessPreventionEnforced}
+	r := &BucketResource{s3: fake, gcpStorage: gcs}
+
+	cases := []struct {
+		name, cloud, id, want string
+	}{
+		{"aws without block", "aws", "open", "allowed"},
+		{"aws partial block", "aws", "partial", "allowed"},
+		{"gcp enforced", "gcp", "gcs", "blocked"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := testState(t, r, map[string]tftypes.Value{
+				"id":            str(tc.id),
+				"name":          str(tc.id),
+				"type":          str(tc.cloud),
+				"public_access": str("blocked"),
+			})
+			resp := &resource.ReadResponse{State: state}
+			r.Read(context.Background(), resource.ReadRequest{State: state}, resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("read: %v", resp.Diagnostics)
+			}
+			var got bucketResourceModel
+			resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+			if got.PublicAccess.ValueString() != tc.want {
+				t.Errorf("public_access = %q, want %q", got.PublicAccess.ValueString(), tc.want)
+			}
+		})
+	}
+}
+
+func TestBucketDeleteNonEmpty(t *testing.T) {
+	ctx := context.Background()
+	for _, force := range []bool{false, true} {
+		for _, cloud := range []string{"aws", "gcp"} {
+			s3 := newFakeS3()
+			gcs := newFakeGCS()
+			r := &BucketResource{s3: s3, gcpStorage: gcs}
+			_, resp := createBucket(t, r, map[string]tftypes.Value{
+				"name":          str("assets"),
+				"type":          str(cloud),
+				"force_destroy": boolean(force),
+			})
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("create: %v", resp.Diagnostics)
+			}
+			s3.objects["assets"] = []string{"index.html", "app.js"}
+			gcs.objects["assets"] = 2
+			dresp := &resource.DeleteResponse{State: resp.State}
+			r.Delete(ctx, resource.DeleteRequest{State: resp.State}, dresp)
+			if dresp.Diagnostics.HasError() == force {
+				t.Errorf("%s force_destroy=%v: diagnostics = %v", cloud, force, dresp.Diagnostics)
+			}
+		}
+	}
+}
+
+func TestBucketDelete(t *testing.T) {
+	s3 := newFakeS3()
+	gcs := newFakeGCS()
+	r := &BucketResource{s3: s3, gcpStorage: gcs}
+	ctx := context.Background()
+	for _, cloud := range []string{"aws", "gcp"} {
+		t.Run(cloud, func(t *testing.T) {
+			_, resp := createBucket(t, r, map[string]tftypes.Value{
+				"name": str("assets"),
+				"type": str(cloud),
+			})
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("create: %v", resp.Diagnostics)
+			}
+			dresp := &resource.DeleteResponse{State: resp.State}
+			r.Delete(ctx, resource.DeleteRequest{State: resp.State}, dresp)
+			if dresp.Diagnostics.HasError() {
+				t.Fatalf("delete: %v", dresp.Diagnostics)
+			}
+		})
+	}
+	if len(s3.buckets) != 0 || len(gcs.buckets) != 0 {
+		t.Errorf("buckets left behind: aws=%d gcp=%d", len(s3.buckets), len(gcs.buckets))
+	}
+}
+
+func TestStorageAccountName(t *testing.T) {
+	cases := map[string]string{
+		"Assets":                          "assets",
+		"averyveryverylongbucketname1234": "averyveryverylongbucketn",
+	}
+	for in, want := range cases {
+		if got := storageAccountName(in); got != want {
+			t.Errorf("storageAccountName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAzureStorageShared(t *testing.T) {
+	cases := map[string]string{"": "abstract-rg", "shared-rg": "shared-rg"}
+	for rg, wantRG := range cases {
+		s := azureStorage{sharedAcct: "sharedacct", sharedRG: rg}
+		acct, gotRG, created, err := s.ensure(context.Background(), "assets")
+		if err != nil {
+			t.Fatalf("ensure: %v", err)
+		}
+		if acct != "sharedacct" || gotRG != wantRG || created {
+			t.Errorf("ensure() = %q, %q, %v; want sharedacct, %q, false", acct, gotRG, created, wantRG)
+		}
+	}
+}
+
+func TestAzureVersioningReadBack(t *testing.T) {
+	on := armstorage.BlobServiceProperties{BlobServiceProperties: &armstorage.BlobServicePropertiesProperties{IsVersioningEnabled: to.Ptr(true)}}
+	if !azureVersioning(on) || azureVersioning(armstorage.BlobServiceProperties{}) {
+		t.Error("azureVersioning misreads the service properties")
+	}
+	m := bucketResourceModel{Versioning: types.BoolValue(true)}
+	m.setVersioning(false)
+	if m.Versioning.ValueBool() {
+		t.Error("versioning turned off out of band not detected")
+	}
+	unset := bucketResourceModel{Versioning: types.BoolNull()}
+	unset.setVersioning(true)
+	if !unset.Versioning.IsNull() {
+		t.Errorf("unset versioning = %v, want null", unset.Versioning)
+	}
+}
+
+func TestAzureVersioningSharedAccount(t *testing.T) {
+	arm := newFakeARM()
+	blob, err := armstorage.NewBlobServicesClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &BucketResource{azureBlob: blob}
+	ctx := context.Background()
+	const props = "/subscriptions/sub/resourceGroups/shared-rg/providers/Microsoft.Storage/storageAccounts/sharedacct/blobServices/default"
+	acct := &bucketResourceModel{ResourceGroup: types.StringValue("shared-rg"), Account: types.StringValue("sharedacct"), AccountCreated: types.BoolValue(false)}
+	if err := r.setAzureVersioning(ctx, acct, true); err == nil {
+		t.Error("versioning changed on a shared account")
+	}
+	if _, ok := arm.puts[props]; ok {
+		t.Error("shared account's blob service properties rewritten")
+	}
+	// a shared account that already matches is fine
+	if err := r.setAzureVersioning(ctx, acct, false); err != nil {
+		t.Errorf("matching versioning: %v", err)
+	}
+	owned := *acct
+	owned.AccountCreated = types.BoolValue(true)
+	if err := r.setAzureVersioning(ctx, &owned, true); err != nil {
+		t.Fatalf("owned account: %v", err)
+	}
+	if p, _ := arm.puts[props]["properties"].(map[string]any); p["isVersioningEnabled"] != true {
+		t.Errorf("blob service properties = %v", arm.puts[props])
+	}
+}
+
+func TestAzurePublicAccess(t *testing.T) {
+	arm := newFakeARM()
+	accts, err := armstorage.NewAccountsClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conts, err := armstorage.NewBlobContainersClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &BucketResource{azureAcct: accts, azureCont: conts}
+	ctx := context.Background()
+	const acctPath = "/subscriptions/sub/resourceGroups/shared-rg/providers/Microsoft.Storage/storageAccounts/sharedacct"
+	const contPath = acctPath + "/blobServices/default/containers/assets"
+	access := func() any {
+		p, _ := arm.puts[contPath]["properties"].(map[string]any)
+		return p["publicAccess"]
+	}
+	m := &bucketResourceModel{ID: types.StringValue("assets"), ResourceGroup: types.StringValue("shared-rg"), Account: types.StringValue("sharedacct"), AccountCreated: types.BoolValue(false)}
+	if err := r.setAzurePublicAccess(ctx, m, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := arm.puts[acctPath]; ok {
+		t.Error("shared account updated")
+	}
+	if got := access(); got != "Blob" {
+		t.Errorf("container public access = %v, want Blob", got)
+	}
+	m.AccountCreated = types.BoolValue(true)
+	if err := r.setAzurePublicAccess(ctx, m, true); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := arm.puts[acctPath]["properties"].(map[string]any); p["allowBlobPublicAccess"] != false {
+		t.Errorf("owned account = %v", arm.puts[acctPath])
+	}
+	if got := access(); got != "None" {
+		t.Errorf("container public access = %v, want None", got)
+	}
+}
+
+func TestAzureConnectionString(t *testing.T) {
+	want := "DefaultEndpointsProtocol=https;AccountName=sharedacct;AccountKey=a2V5;EndpointSuffix=core.windows.net"
+	if got := azureConnectionString("sharedacct", "a2V5"); got != want {
+		t.Errorf("azureConnectionString() = %q", got)
+	}
+}
+
+func TestBucketNameConfig(t *testing.T) {
+	cases := []struct {
+		name, cloud, bucket, shared string
+		errors, warning             bool
+	}{
+		{"valid s3", "aws", "my-bucket", "", false, false},
+		{"invalid s3", "aws", "My_Bucket", "", true, false},
+		{"invalid gcs", "gcp", "google-assets", "", true, false},
+		{"account as named", "azure", "assets", "", false, false},
+		{"account truncated", "azure", "assetsforthewebfrontend2024", "", false, true},
+		{"account with hyphen", "azure", "web-assets", "", false, true},
+		{"shared account", "azure", "web-assets", "sharedacct", false, false},
+		{"invalid container", "azure", "web--assets", "", true, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &BucketResource{azureSharedAcct: tc.shared}
+			s := testSchema(t, r)
+			resp := &resource.ValidateConfigResponse{}
+			cfg := testValue(s, map[string]tftypes.Value{"name": str(tc.bucket), "type": str(tc.cloud)}, false)
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: cfg}}, resp)
+			if resp.Diagnostics.HasError() != tc.errors || (resp.Diagnostics.WarningsCount() > 0) != tc.warning {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
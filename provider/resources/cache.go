@@ -0,0 +1,599 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	ectypes "github.com/aws/aws-sdk-go-v2/service/elasticache/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	memcache "google.golang.org/api/memcache/v1"
+	redis "google.golang.org/api/redis/v1"
+)
+
+// cacheDefaultSize is the size of a cache without one: the smallest Redis or
+// Memcached node each cloud offers.
+var cacheDefaultSize = map[string]map[string]string{
+	"aws":   {"redis": "cache.t3.micro", "memcached": "cache.t3.micro"},
+	"azure": {"redis": "Basic_C0"},
+	"gcp":   {"redis": "BASIC_1", "memcached": "1024"},
+}
+
+// cachePollInterval is how often Create and Delete check on a cache.
+var cachePollInterval = 15 * time.Second
+
+// CacheResource manages a Redis or Memcached cache: a single-node ElastiCache
+// cluster on AWS, Azure Cache for Redis or a Memorystore instance on GCP.
+type CacheResource struct {
+	elastiCache *elasticache.Client
+
+	azureRG    *armresources.ResourceGroupsClient
+	azureRedis *shared.AzureRedisClient
+	azureLoc   string
+
+	gcpRedis    *redis.Service
+	gcpMemcache *memcache.Service
+	gcpProj     string
+	gcpRegion   string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	ids *shared.CloudIDs
+}
+
+type cacheResourceModel struct {
+	ID       types.String `tfsdk:"id"`
+	CloudID  types.String `tfsdk:"cloud_id"`
+	Type     types.String `tfsdk:"type"`
+	Name     types.String `tfsdk:"name"`
+	Engine   types.String `tfsdk:"engine"`
+	Size     types.String `tfsdk:"size"`
+	Region   types.String `tfsdk:"region"`
+	Endpoint types.String `tfsdk:"endpoint"`
+	Port     types.Int64  `tfsdk:"port"`
+}
+
+func NewCacheResource() resource.Resource { return &CacheResource{} }
+
+func (r *CacheResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.ids = cfg.CloudIDs
+	r.elastiCache = cfg.AWSElastiCache
+	r.azureRG = cfg.AzureRGClient
+	r.azureRedis = cfg.AzureRedisClient
+	r.azureLoc = cfg.AzureLocation
+	r.gcpRedis = cfg.GCPRedis
+	r.gcpMemcache = cfg.GCPMemcache
+	r.gcpProj = cfg.GCPProject
+	r.gcpRegion = cfg.GCPRegion
+}
+
+func (r *CacheResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_cache"
+}
+
+func (r *CacheResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	computed := []planmodifier.String{stringplanmodifier.UseStateForUnknown()}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			// ElastiCache cluster ID, Azure cache name or Memorystore instance ID; all are name.
+			"id":       schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			"cloud_id": cloudIDAttribute(),
+			"type":     schema.StringAttribute{Required: true, PlanModifiers: replace},
+			"name":     schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// redis or memcached; Azure only has redis.
+			"engine": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// ElastiCache node type, Azure SKU such as Basic_C0, or on GCP a
+			// Redis tier and GB such as BASIC_1 or Memcached node MB such as 1024.
+			"size": schema.StringAttribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown(), stringplanmodifier.RequiresReplace()}},
+			// AWS region, Azure location or GCP region.
+			"region":   schema.StringAttribute{Optional: true, PlanModifiers: replace},
+			"endpoint": schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			"port":     schema.Int64Attribute{Computed: true, PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()}},
+		},
+	}
+}
+
+func (r *CacheResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg cacheResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() || cfg.Type.IsUnknown() || cfg.Engine.IsUnknown() {
+		return
+	}
+	cloud, engine := cfg.Type.ValueString(), cfg.Engine.ValueString()
+	if engine != "redis" && engine != "memcached" {
+		resp.Diagnostics.AddAttributeError(path.Root("engine"), "invalid engine", fmt.Sprintf("%q is not redis or memcached", engine))
+		return
+	}
+	if _, ok := cacheDefaultSize[cloud][engine]; !ok {
+		if _, known := cacheDefaultSize[cloud]; known {
+			resp.Diagnostics.AddAttributeError(path.Root("engine"), "unsupported engine", "Azure Cache is Redis only; use engine = \"redis\"")
+		}
+		return
+	}
+	if cfg.Size.IsNull() || cfg.Size.IsUnknown() {
+		return
+	}
+	if err := checkCacheSize(cloud, engine, cfg.Size.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("size"), "invalid size", err.Error())
+	}
+}
+
+var (
+	azureRedisSKUPattern = regexp.MustCompile(`^(Basic|Standard|Premium)_([CP])([0-6])$`)
+	gcpRedisSizePattern  = regexp.MustCompile(`^(BASIC|STANDARD_HA)_([0-9]+)$`)
+)
+
+// checkCacheSize reports a size the cloud would reject for engine.
+func checkCacheSize(cloud, engine, size string) error {
+	var err error
+	switch {
+	case cloud == "aws" && !strings.HasPrefix(size, "cache."):
+		err = fmt.Errorf("%q is not an ElastiCache node type such as cache.t3.micro", size)
+	case cloud == "azure":
+		_, err = azureRedisSKU(size)
+	case cloud == "gcp" && engine == "redis":
+		_, _, err = gcpRedisSize(size)
+	case cloud == "gcp":
+		_, err = gcpMemcacheSize(size)
+	}
+	return err
+}
+
+// azureRedisSKU parses an Azure cache size such as Basic_C0 or Premium_P1.
+// Premium caches are family P, the others family C.
+func azureRedisSKU(size string) (shared.AzureRedisSKU, error) {
+	m := azureRedisSKUPattern.FindStringSubmatch(size)
+	if m == nil || (m[1] == "Premium") != (m[2] == "P") {
+		return shared.AzureRedisSKU{}, fmt.Errorf("%q is not an Azure cache size such as Basic_C0, Standard_C1 or Premium_P1", size)
+	}
+	capacity, _ := strconv.Atoi(m[3])
+	return shared.AzureRedisSKU{Name: m[1], Family: m[2], Capacity: capacity}, nil
+}
+
+// gcpRedisSize parses a Memorystore for Redis size, the tier and memory in
+// GB such as BASIC_1 or STANDARD_HA_5.
+func gcpRedisSize(size string) (tier string, gb int64, err error) {
+	m := gcpRedisSizePattern.FindStringSubmatch(size)
+	if m != nil {
+		gb, _ = strconv.ParseInt(m[2], 10, 64)
+	}
+	if gb < 1 || gb > 300 {
+		return "", 0, fmt.Errorf("%q is not a Memorystore for Redis size such as BASIC_1 or STANDARD_HA_5 (1 to 300 GB)", size)
+	}
+	return m[1], gb, nil
+}
+
+// gcpMemcacheSize parses a Memorystore for Memcached size, the memory of its
+// node in MB.
+func gcpMemcacheSize(size string) (int64, error) {
+	mb, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || mb < 1024 || mb > 5*1024*1024 {
+		return 0, fmt.Errorf("%q is not a Memorystore for Memcached node size in MB, such as 1024", size)
+	}
+	return mb, nil
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *CacheResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.elastiCache = c.ElastiCache
+	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *CacheResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.elastiCache != nil
+	case "azure":
+		return r.azureRG != nil && r.azureRedis != nil
+	case "gcp":
+		return r.gcpRedis != nil && r.gcpMemcache != nil
+	}
+	return false
+}
+
+// gcpParent is the Memorystore location of m: its region, or the region of a
+// zone, defaulting to the provider's region.
+func (r *CacheResource) gcpParent(m *cacheResourceModel) string {
+	region := m.Region.ValueString()
+	if region == "" {
+		region = r.gcpRegion
+	}
+	return fmt.Sprintf("projects/%s/locations/%s", r.gcpProj, gcpZoneRegion(region))
+}
+
+func (r *CacheResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan cacheResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	cloud, engine := plan.Type.ValueString(), plan.Engine.ValueString()
+	size := plan.Size.ValueString()
+	if size == "" {
+		size = cacheDefaultSize[cloud][engine]
+	}
+	plan.ID = plan.Name
+	plan.Size = types.StringValue(size)
+	plan.CloudID = types.StringNull()
+	var err error
+	switch cloud {
+	case "aws":
+		err = r.createAWS(ctx, &plan)
+	case "azure":
+		err = r.createAzure(ctx, &plan)
+	case "gcp":
+		err = r.createGCP(ctx, &plan)
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	if err != nil {
+		if cloud == "azure" {
+			shared.AddAzureError(&resp.Diagnostics, "azure create cache", err)
+		} else {
+			resp.Diagnostics.AddError(cloud+" create cache", err.Error())
+		}
+		return
+	}
+	id, err := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// describeCacheCluster returns the cluster with its nodes.
+func (r *CacheResource) describeCacheCluster(ctx context.Context, id string) (*ectypes.CacheCluster, error) {
+	out, err := r.elastiCache.DescribeCacheClusters(ctx, &elasticache.DescribeCacheClustersInput{
+		CacheClusterId:    aws.String(id),
+		ShowCacheNodeInfo: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.CacheClusters) == 0 {
+		return nil, &ectypes.CacheClusterNotFoundFault{Message: aws.String("cache cluster " + id + " not found")}
+	}
+	return &out.CacheClusters[0], nil
+}
+
+// cacheEndpoint returns the address clients connect to: the configuration
+// endpoint of a Memcached cluster or the node of a Redis one. It is nil
+// until the cluster is available.
+func cacheEndpoint(c *ectypes.CacheCluster) *ectypes.Endpoint {
+	if c.ConfigurationEndpoint != nil && aws.ToString(c.ConfigurationEndpoint.Address) != "" {
+		return c.ConfigurationEndpoint
+	}
+	for _, n := range c.CacheNodes {
+		if n.Endpoint != nil && aws.ToString(n.Endpoint.Address) != "" {
+			return n.Endpoint
+		}
+	}
+	return nil
+}
+
+func (r *CacheResource) createAWS(ctx context.Context, plan *cacheResourceModel) error {
+	id := plan.ID.ValueString()
+	_, err := r.elastiCache.CreateCacheCluster(ctx, &elasticache.CreateCacheClusterInput{
+		CacheClusterId: aws.String(id),
+		Engine:         aws.String(plan.Engine.ValueString()),
+		CacheNodeType:  aws.String(plan.Size.ValueString()),
+		NumCacheNodes:  aws.Int32(1),
+	})
+	if err != nil {
+		return err
+	}
+	// the endpoint is only known once the cluster is available
+	for {
+		cluster, err := r.describeCacheCluster(ctx, id)
+		if err != nil {
+			return err
+		}
+		status := aws.ToString(cluster.CacheClusterStatus)
+		if ep := cacheEndpoint(cluster); status == "available" && ep != nil {
+			plan.Endpoint = types.StringValue(aws.ToString(ep.Address))
+			plan.Port = types.Int64Value(int64(aws.ToInt32(ep.Port)))
+			return nil
+		}
+		if status == "create-failed" || status == "incompatible-network" {
+			return fmt.Errorf("cache cluster %s is %s", id, status)
+		}
+		if err := shared.Sleep(ctx, cachePollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *CacheResource) createAzure(ctx context.Context, plan *cacheResourceModel) error {
+	sku, err := azureRedisSKU(plan.Size.ValueString())
+	if err != nil {
+		return err
+	}
+	loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+	if err := shared.EnsureResourceGroup(ctx, r.azureRG, "abstract-rg", loc); err != nil {
+		return err
+	}
+	poller, err := r.azureRedis.BeginCreate(ctx, "abstract-rg", plan.ID.ValueString(), shared.AzureRedis{
+		Location:   loc,
+		Properties: shared.AzureRedisProperties{SKU: sku, MinimumTLSVersion: "1.2"},
+	})
+	if err != nil {
+		return err
+	}
+	cache, err := shared.PollAzure(ctx, poller)
+	if err != nil {
+		return err
+	}
+	if cache.Properties.HostName == "" {
+		// the final response of some operations has no body; read the cache back
+		if cache, err = r.azureRedis.Get(ctx, "abstract-rg", plan.ID.ValueString()); err != nil {
+			return err
+		}
+	}
+	plan.Endpoint = types.StringValue(cache.Properties.HostName)
+	plan.Port = types.Int64Value(cache.Properties.SSLPort)
+	return nil
+}
+
+func (r *CacheResource) createGCP(ctx context.Context, plan *cacheResourceModel) error {
+	parent, id := r.gcpParent(plan), plan.ID.ValueString()
+	if plan.Engine.ValueString() == "memcached" {
+		mb, err := gcpMemcacheSize(plan.Size.ValueString())
+		if err != nil {
+			return err
+		}
+		inst := &memcache.Instance{NodeCount: 1, NodeConfig: &memcache.NodeConfig{CpuCount: 1, MemorySizeMb: mb}}
+		op, err := r.gcpMemcache.Projects.Locations.Instances.Create(parent, inst).InstanceId(id).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+		if err := r.waitMemcacheOperation(ctx, op); err != nil {
+			return err
+		}
+		created, err := r.gcpMemcache.Projects.Locations.Instances.Get(parent + "/instances/" + id).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+		plan.setMemcacheEndpoint(created)
+		return nil
+	}
+	tier, gb, err := gcpRedisSize(plan.Size.ValueString())
+	if err != nil {
+		return err
+	}
+	op, err := r.gcpRedis.Projects.Locations.Instances.Create(parent, &redis.Instance{Tier: tier, MemorySizeGb: gb}).InstanceId(id).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	if err := r.waitRedisOperation(ctx, op); err != nil {
+		return err
+	}
+	created, err := r.gcpRedis.Projects.Locations.Instances.Get(parent + "/instances/" + id).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	plan.Endpoint = types.StringValue(created.Host)
+	plan.Port = types.Int64Value(created.Port)
+	return nil
+}
+
+// setMemcacheEndpoint records the discovery endpoint, reported as "host:port".
+func (m *cacheResourceModel) setMemcacheEndpoint(inst *memcache.Instance) {
+	host, port, _ := strings.Cut(inst.DiscoveryEndpoint, ":")
+	m.Endpoint = types.StringValue(host)
+	if p, err := strconv.ParseInt(port, 10, 64); err == nil {
+		m.Port = types.Int64Value(p)
+	} else {
+		m.Port = types.Int64Value(11211)
+	}
+}
+
+// waitRedisOperation polls a Memorystore for Redis operation until it is done.
+func (r *CacheResource) waitRedisOperation(ctx context.Context, op *redis.Operation) error {
+	for !op.Done {
+		if err := shared.Sleep(ctx, cachePollInterval); err != nil {
+			return err
+		}
+		var err error
+		if op, err = r.gcpRedis.Projects.Locations.Operations.Get(op.Name).Context(ctx).Do(); err != nil {
+			return err
+		}
+	}
+	if op.Error != nil {
+		return errors.New(op.Error.Message)
+	}
+	return nil
+}
+
+// waitMemcacheOperation polls a Memorystore for Memcached operation until it is done.
+func (r *CacheResource) waitMemcacheOperation(ctx context.Context, op *memcache.Operation) error {
+	for !op.Done {
+		if err := shared.Sleep(ctx, cachePollInterval); err != nil {
+			return err
+		}
+		var err error
+		if op, err = r.gcpMemcache.Projects.Locations.Operations.Get(op.Name).Context(ctx).Do(); err != nil {
+			return err
+		}
+	}
+	if op.Error != nil {
+		return errors.New(op.Error.Message)
+	}
+	return nil
+}
+
+// cloudID is the ElastiCache cluster's ARN, the Azure cache's ID or the
+// Memorystore instance's full name.
+func (r *CacheResource) cloudID(ctx context.Context, m *cacheResourceModel) (string, error) {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.ARN(ctx, "elasticache", true, "cluster:"+id)
+	case "azure":
+		return r.ids.AzureID("abstract-rg", "Microsoft.Cache/redis", id), nil
+	case "gcp":
+		if r.gcpProj == "" {
+			return "", nil
+		}
+		return r.gcpParent(m) + "/instances/" + id, nil
+	}
+	return "", nil
+}
+
+// Read refreshes the endpoint, port and size from the cloud.
+func (r *CacheResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state cacheResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	id := state.ID.ValueString()
+	switch state.Type.ValueString() {
+	case "aws":
+		cluster, err := r.describeCacheCluster(ctx, id)
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		state.Size = types.StringValue(aws.ToString(cluster.CacheNodeType))
+		if ep := cacheEndpoint(cluster); ep != nil {
+			state.Endpoint = types.StringValue(aws.ToString(ep.Address))
+			state.Port = types.Int64Value(int64(aws.ToInt32(ep.Port)))
+		}
+	case "azure":
+		cache, err := r.azureRedis.Get(ctx, "abstract-rg", id)
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		sku := cache.Properties.SKU
+		state.Size = types.StringValue(fmt.Sprintf("%s_%s%d", sku.Name, sku.Family, sku.Capacity))
+		state.Endpoint = types.StringValue(cache.Properties.HostName)
+		state.Port = types.Int64Value(cache.Properties.SSLPort)
+	case "gcp":
+		name := r.gcpParent(&state) + "/instances/" + id
+		if state.Engine.ValueString() == "memcached" {
+			inst, err := r.gcpMemcache.Projects.Locations.Instances.Get(name).Context(ctx).Do()
+			if err != nil {
+				resp.State.RemoveResource(ctx)
+				return
+			}
+			if inst.NodeConfig != nil {
+				state.Size = types.StringValue(strconv.FormatInt(inst.NodeConfig.MemorySizeMb, 10))
+			}
+			state.setMemcacheEndpoint(inst)
+		} else {
+			inst, err := r.gcpRedis.Projects.Locations.Instances.Get(name).Context(ctx).Do()
+			if err != nil {
+				resp.State.RemoveResource(ctx)
+				return
+			}
+			state.Size = types.StringValue(fmt.Sprintf("%s_%d", inst.Tier, inst.MemorySizeGb))
+			state.Endpoint = types.StringValue(inst.Host)
+			state.Port = types.Int64Value(inst.Port)
+		}
+	default:
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+// Update has nothing to do: every attribute replaces the cache.
+func (r *CacheResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan cacheResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *CacheResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state cacheResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	id := state.ID.ValueString()
+	switch state.Type.ValueString() {
+	case "aws":
+		var nf *ectypes.CacheClusterNotFoundFault
+		if _, err := r.elastiCache.DeleteCacheCluster(ctx, &elasticache.DeleteCacheClusterInput{CacheClusterId: aws.String(id)}); err != nil && !errors.As(err, &nf) {
+			resp.Diagnostics.AddError("aws delete cache", err.Error())
+		}
+	case "azure":
+		poller, err := r.azureRedis.BeginDelete(ctx, "abstract-rg", id)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete cache", err)
+		}
+	case "gcp":
+		name := r.gcpParent(&state) + "/instances/" + id
+		var err error
+		if state.Engine.ValueString() == "memcached" {
+			var op *memcache.Operation
+			if op, err = r.gcpMemcache.Projects.Locations.Instances.Delete(name).Context(ctx).Do(); err == nil {
+				err = r.waitMemcacheOperation(ctx, op)
+			}
+		} else {
+			var op *redis.Operation
+			if op, err = r.gcpRedis.Projects.Locations.Instances.Delete(name).Context(ctx).Do(); err == nil {
+				err = r.waitRedisOperation(ctx, op)
+			}
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete cache", err.Error())
+		}
+	}
+}
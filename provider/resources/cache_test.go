@@ -0,0 +1,204 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	memcache "google.golang.org/api/memcache/v1"
+	"google.golang.org/api/option"
+	redis "google.golang.org/api/redis/v1"
+)
+
+func TestCacheConfig(t *testing.T) {
+	r := &CacheResource{}
+	s := testSchema(t, r)
+	cache := func(cloud, engine, size string) map[string]tftypes.Value {
+		vals := map[string]tftypes.Value{"type": str(cloud), "name": str("sessions"), "engine": str(engine)}
+		if size != "" {
+			vals["size"] = str(size)
+		}
+		return vals
+	}
+	cases := []struct {
+		name string
+		vals map[string]tftypes.Value
+		errs bool
+	}{
+		{"aws redis", cache("aws", "redis", "cache.m7g.large"), false},
+		{"aws memcached", cache("aws", "memcached", ""), false},
+		{"aws bad size", cache("aws", "redis", "db.t3.micro"), true},
+		{"bad engine", cache("aws", "valkey", ""), true},
+		{"azure redis", cache("azure", "redis", "Premium_P1"), false},
+		{"azure memcached", cache("azure", "memcached", ""), true},
+		{"azure bad family", cache("azure", "redis", "Basic_P1"), true},
+		{"gcp redis", cache("gcp", "redis", "STANDARD_HA_5"), false},
+		{"gcp redis bad size", cache("gcp", "redis", "5"), true},
+		{"gcp memcached", cache("gcp", "memcached", "2048"), false},
+		{"gcp memcached too small", cache("gcp", "memcached", "512"), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestAzureRedisSKU(t *testing.T) {
+	sku, err := azureRedisSKU("Standard_C2")
+	if err != nil || sku != (shared.AzureRedisSKU{Name: "Standard", Family: "C", Capacity: 2}) {
+		t.Errorf("azureRedisSKU = %+v, %v", sku, err)
+	}
+	if tier, gb, err := gcpRedisSize("STANDARD_HA_5"); err != nil || tier != "STANDARD_HA" || gb != 5 {
+		t.Errorf("gcpRedisSize = %q, %d, %v", tier, gb, err)
+	}
+}
+
+func TestCacheCreateAWS(t *testing.T) {
+	defer func(d time.Duration) { cachePollInterval = d }(cachePollInterval)
+	cachePollInterval = 0
+	describes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		if id := req.Form.Get("CacheClusterId"); id != "sessions" {
+			t.Errorf("CacheClusterId = %q", id)
+		}
+		switch req.Form.Get("Action") {
+		case "CreateCacheCluster":
+			if req.Form.Get("Engine") != "redis" || req.Form.Get("CacheNodeType") != "cache.t3.micro" || req.Form.Get("NumCacheNodes") != "1" {
+				t.Errorf("create form = %v", req.Form)
+			}
+			fmt.Fprint(w, `<CreateCacheClusterResponse><CreateCacheClusterResult><CacheCluster><CacheClusterId>sessions</CacheClusterId><CacheClusterStatus>creating</CacheClusterStatus></CacheCluster></CreateCacheClusterResult></CreateCacheClusterResponse>`)
+		case "DescribeCacheClusters":
+			describes++
+			status, nodes := "creating", ""
+			if describes > 1 {
+				status = "available"
+				nodes = `<CacheNodes><CacheNode><Endpoint><Address>sessions.abc123.0001.usw2.cache.amazonaws.com</Address><Port>6379</Port></Endpoint></CacheNode></CacheNodes>`
+			}
+			fmt.Fprintf(w, `<DescribeCacheClustersResponse><DescribeCacheClustersResult><CacheClusters><CacheCluster><CacheClusterId>sessions</CacheClusterId><CacheClusterStatus>%s</CacheClusterStatus><CacheNodeType>cache.t3.micro</CacheNodeType><Engine>redis</Engine>%s</CacheCluster></CacheClusters></DescribeCacheClustersResult></DescribeCacheClustersResponse>`, status, nodes)
+		case "DeleteCacheCluster":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>CacheClusterNotFound</Code><Message>CacheCluster sessions not found.</Message></Error></ErrorResponse>`)
+		default:
+			t.Errorf("unexpected %v", req.Form)
+		}
+	}))
+	defer srv.Close()
+	client := elasticache.New(elasticache.Options{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(srv.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "secret", ""),
+		Retryer:      aws.NopRetryer{},
+	})
+	r := &CacheResource{elastiCache: client}
+	plan := testPlan(t, r, map[string]tftypes.Value{"type": str("aws"), "name": str("sessions"), "engine": str("redis")})
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(context.Background(), resource.CreateRequest{Plan: plan}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatal(resp.Diagnostics)
+	}
+	var got cacheResourceModel
+	resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+	if got.Endpoint.ValueString() != "sessions.abc123.0001.usw2.cache.amazonaws.com" || got.Port.ValueInt64() != 6379 || describes != 2 {
+		t.Errorf("state = %+v after %d describes", got, describes)
+	}
+
+	// a cluster deleted outside Terraform does not fail the destroy
+	delResp := &resource.DeleteResponse{State: resp.State}
+	r.Delete(context.Background(), resource.DeleteRequest{State: resp.State}, delResp)
+	if delResp.Diagnostics.HasError() {
+		t.Errorf("delete: %v", delResp.Diagnostics)
+	}
+}
+
+func TestCacheCreateAzure(t *testing.T) {
+	arm := newFakeARM()
+	rg, err := armresources.NewResourceGroupsClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheClient, err := shared.NewAzureRedisClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &CacheResource{azureRG: rg, azureRedis: cacheClient, azureLoc: "westeurope"}
+	plan := testPlan(t, r, map[string]tftypes.Value{"type": str("azure"), "name": str("sessions"), "engine": str("redis")})
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(context.Background(), resource.CreateRequest{Plan: plan}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatal(resp.Diagnostics)
+	}
+	put := arm.puts["/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.Cache/redis/sessions"]
+	if put == nil || put["location"] != "westeurope" {
+		t.Fatalf("cache PUT = %v", put)
+	}
+	sku := put["properties"].(map[string]any)["sku"].(map[string]any)
+	if sku["name"] != "Basic" || sku["family"] != "C" || sku["capacity"] != float64(0) {
+		t.Errorf("sku = %v, want Basic C0", sku)
+	}
+	var size types.String
+	resp.State.GetAttribute(context.Background(), path.Root("size"), &size)
+	if size.ValueString() != "Basic_C0" {
+		t.Errorf("size = %s", size)
+	}
+}
+
+func TestCacheCreateGCPRedis(t *testing.T) {
+	defer func(d time.Duration) { cachePollInterval = d }(cachePollInterval)
+	cachePollInterval = 0
+	const name = "/v1/projects/p/locations/us-east1/instances/sessions"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/v1/projects/p/locations/us-east1/instances":
+			if got := req.URL.Query().Get("instanceId"); got != "sessions" {
+				t.Errorf("instanceId = %q", got)
+			}
+			fmt.Fprint(w, `{"name": "projects/p/locations/us-east1/operations/op1"}`)
+		case req.URL.Path == "/v1/projects/p/locations/us-east1/operations/op1":
+			fmt.Fprint(w, `{"name": "projects/p/locations/us-east1/operations/op1", "done": true}`)
+		case req.URL.Path == name:
+			fmt.Fprint(w, `{"name": "sessions", "tier": "BASIC", "memorySizeGb": 1, "host": "10.0.0.3", "port": 6379}`)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+	redisSvc, err := redis.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	memcacheSvc, err := memcache.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &CacheResource{gcpRedis: redisSvc, gcpMemcache: memcacheSvc, gcpProj: "p", gcpRegion: "us-central1"}
+	plan := testPlan(t, r, map[string]tftypes.Value{"type": str("gcp"), "name": str("sessions"), "engine": str("redis"), "region": str("us-east1-b")})
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(context.Background(), resource.CreateRequest{Plan: plan}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatal(resp.Diagnostics)
+	}
+	var got cacheResourceModel
+	resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+	if got.Endpoint.ValueString() != "10.0.0.3" || got.Port.ValueInt64() != 6379 || got.Size.ValueString() != "BASIC_1" {
+		t.Errorf("state = %+v", got)
+	}
+}
@@ -0,0 +1,822 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/cdn/armcdn"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// cachingOptimizedPolicyID is the AWS managed "CachingOptimized" cache policy.
+const cachingOptimizedPolicyID = "658327ea-f89d-4fab-a63d-7e88639e58f6"
+
+// cdnDeployTimeout bounds how long we wait for a CloudFront distribution to deploy.
+const cdnDeployTimeout = 45 * time.Minute
+
+// CDNResource fronts an existing bucket with a content delivery network.
+type CDNResource struct {
+	cloudfront *cloudfront.Client
+
+	azureRG       *armresources.ResourceGroupsClient
+	azureProfiles *armcdn.ProfilesClient
+	azureEndpts   *armcdn.EndpointsClient
+	azureDomains  *armcdn.CustomDomainsClient
+	azureLoc      string
+	// azureShared* name the provider-wide storage account buckets may live in.
+	azureSharedAcct string
+	azureSharedRG   string
+
+	gcp     *compute.Service
+	gcpProj string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	ids *shared.CloudIDs
+}
+
+type cdnResourceModel struct {
+	ID            types.String `tfsdk:"id"`
+	CloudID       types.String `tfsdk:"cloud_id"`
+	Type          types.String `tfsdk:"type"`
+	Bucket        types.String `tfsdk:"bucket"`
+	CustomDomain  types.String `tfsdk:"custom_domain"`
+	CertificateID types.String `tfsdk:"certificate_id"`
+	DomainName    types.String `tfsdk:"domain_name"`
+}
+
+func NewCDNResource() resource.Resource { return &CDNResource{} }
+
+func (r *CDNResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.ids = cfg.CloudIDs
+	r.cloudfront = cfg.AWSCloudFront
+	r.azureRG = cfg.AzureRGClient
+	r.azureProfiles = cfg.AzureCDNProfileClient
+	r.azureEndpts = cfg.AzureCDNEndpointClient
+	r.azureDomains = cfg.AzureCDNDomainClient
+	r.azureLoc = cfg.AzureLocation
+	r.azureSharedAcct = cfg.AzureStorageAccount
+	r.azureSharedRG = cfg.AzureStorageResourceGroup
+	r.gcp = cfg.GCPCompute
+	r.gcpProj = cfg.GCPProject
+}
+
+func (r *CDNResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_cdn"
+}
+
+func (r *CDNResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"cloud_id": cloudIDAttribute(),
+			"type": schema.StringAttribute{
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"bucket": schema.StringAttribute{
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"custom_domain": schema.StringAttribute{Optional: true},
+			// ACM certificate ARN (aws), Key Vault secret ID (azure) or managed SSL certificate name (gcp).
+			"certificate_id": schema.StringAttribute{
+				Optional: true,
+				// GCP serves HTTPS from a separate proxy and port, so toggling TLS rebuilds the frontend.
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplaceIf(
+					func(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+						var cloud types.String
+						resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("type"), &cloud)...)
+						hadCert := req.StateValue.ValueString() != ""
+						hasCert := req.PlanValue.ValueString() != ""
+						resp.RequiresReplace = cloud.ValueString() == "gcp" && hadCert != hasCert
+					},
+					"Adding or removing a certificate on gcp replaces the CDN frontend.",
+					"Adding or removing a certificate on gcp replaces the CDN frontend.",
+				)},
+			},
+			"domain_name": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+		},
+	}
+}
+
+// azureOriginHost is the blob endpoint of the storage account holding bucket,
+// which is the provider's shared account when one is configured.
+func (r *CDNResource) azureOriginHost(bucket string) string {
+	acctName, _ := azureStorage{sharedAcct: r.azureSharedAcct, sharedRG: r.azureSharedRG}.names(bucket)
+	return acctName + ".blob.core.windows.net"
+}
+
+// cdnName derives a name valid for Azure CDN endpoints and GCP compute resources.
+func cdnName(bucket string) string {
+	name := strings.ToLower(bucket)
+	name = strings.NewReplacer(".", "-", "_", "-").Replace(name)
+	return name + "-cdn"
+}
+
+// waitComputeOperation polls a GCP compute operation, which may be global,
+// regional or zonal, until it is done.
+func waitComputeOperation(ctx context.Context, svc *compute.Service, project string, op *compute.Operation) error {
+	for {
+		var oper *compute.Operation
+		var err error
+		switch {
+		case op.Zone != "":
+			oper, err = svc.ZoneOperations.Get(project, op.Zone[strings.LastIndex(op.Zone, "/")+1:], op.Name).Context(ctx).Do()
+		case op.Region != "":
+			oper, err = svc.RegionOperations.Get(project, op.Region[strings.LastIndex(op.Region, "/")+1:], op.Name).Context(ctx).Do()
+		default:
+			oper, err = svc.GlobalOperations.Get(project, op.Name).Context(ctx).Do()
+		}
+		if err != nil {
+			return err
+		}
+		if oper.Status == "DONE" {
+			if oper.Error != nil && len(oper.Error.Errors) > 0 {
+				return fmt.Errorf("%s", oper.Error.Errors[0].Message)
+			}
+			return nil
+		}
+		if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+			return err
+		}
+	}
+}
+
+// cdnDomainName derives the Azure custom domain resource name for a hostname.
+func cdnDomainName(domain string) string {
+	return strings.ReplaceAll(domain, ".", "-")
+}
+
+// keyVaultCert identifies a certificate stored as a Key Vault secret.
+type keyVaultCert struct {
+	subscription  string
+	resourceGroup string
+	vault         string
+	secret        string
+	version       string
+}
+
+// parseKeyVaultCert parses a Key Vault secret or certificate resource ID of the form
+// /subscriptions/{sub}/resourceGroups/{rg}/providers/Microsoft.KeyVault/vaults/{vault}/secrets/{name}[/{version}].
+func parseKeyVaultCert(id string) (keyVaultCert, error) {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	if len(parts) < 10 || len(parts) > 11 ||
+		!strings.EqualFold(parts[0], "subscriptions") ||
+		!strings.EqualFold(parts[2], "resourceGroups") ||
+		!strings.EqualFold(parts[5], "Microsoft.KeyVault") ||
+		!strings.EqualFold(parts[6], "vaults") ||
+		(!strings.EqualFold(parts[8], "secrets") && !strings.EqualFold(parts[8], "certificates")) {
+		return keyVaultCert{}, fmt.Errorf("%q is not a Key Vault secret or certificate ID", id)
+	}
+	kv := keyVaultCert{subscription: parts[1], resourceGroup: parts[3], vault: parts[7], secret: parts[9]}
+	if len(parts) == 11 {
+		kv.version = parts[10]
+	}
+	return kv, nil
+}
+
+// validateCertificate checks that cert belongs to the selected cloud.
+func validateCertificate(cloud, cert string) error {
+	switch cloud {
+	case "aws":
+		a, err := arn.Parse(cert)
+		if err != nil || a.Service != "acm" {
+			return fmt.Errorf("%q is not an ACM certificate ARN", cert)
+		}
+		// CloudFront only accepts certificates issued in us-east-1
+		if a.Region != "us-east-1" {
+			return fmt.Errorf("ACM certificate %q must be in us-east-1 to be used by CloudFront", cert)
+		}
+	case "azure":
+		_, err := parseKeyVaultCert(cert)
+		return err
+	case "gcp":
+		if arn.IsARN(cert) || strings.Contains(strings.ToLower(cert), "microsoft.keyvault") {
+			return fmt.Errorf("%q is not a GCP SSL certificate", cert)
+		}
+	}
+	return nil
+}
+
+// gcpCertLink expands a certificate name to its global self link.
+func gcpCertLink(project, cert string) string {
+	if strings.Contains(cert, "/") {
+		return cert
+	}
+	return fmt.Sprintf("projects/%s/global/sslCertificates/%s", project, cert)
+}
+
+// cdnAliases returns the CloudFront alternate domain names for domain.
+func cdnAliases(domain string) *cftypes.Aliases {
+	if domain == "" {
+		return &cftypes.Aliases{Quantity: aws.Int32(0)}
+	}
+	return &cftypes.Aliases{Quantity: aws.Int32(1), Items: []string{domain}}
+}
+
+// cdnViewerCertificate returns the CloudFront viewer certificate for an ACM ARN,
+// falling back to the default *.cloudfront.net certificate.
+func cdnViewerCertificate(cert string) *cftypes.ViewerCertificate {
+	if cert == "" {
+		return &cftypes.ViewerCertificate{CloudFrontDefaultCertificate: aws.Bool(true)}
+	}
+	return &cftypes.ViewerCertificate{
+		ACMCertificateArn:      aws.String(cert),
+		SSLSupportMethod:       cftypes.SSLSupportMethodSniOnly,
+		MinimumProtocolVersion: cftypes.MinimumProtocolVersionTLSv122021,
+	}
+}
+
+func (r *CDNResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg cdnResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() || cfg.Type.IsUnknown() || cfg.CertificateID.IsUnknown() || cfg.CustomDomain.IsUnknown() {
+		return
+	}
+	cert := cfg.CertificateID.ValueString()
+	domain := cfg.CustomDomain.ValueString()
+	if cert != "" && domain == "" {
+		resp.Diagnostics.AddAttributeError(path.Root("certificate_id"), "invalid certificate", "certificate_id requires custom_domain")
+		return
+	}
+	if cert == "" && domain != "" && cfg.Type.ValueString() == "aws" {
+		resp.Diagnostics.AddAttributeError(path.Root("certificate_id"), "invalid certificate", "CloudFront requires an ACM certificate covering custom_domain")
+		return
+	}
+	if cert != "" {
+		if err := validateCertificate(cfg.Type.ValueString(), cert); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("certificate_id"), "invalid certificate", err.Error())
+		}
+	}
+}
+
+// azureBindDomain checks the CNAME from domain to the endpoint and attaches it as a custom domain.
+func (r *CDNResource) azureBindDomain(ctx context.Context, name, domain string) error {
+	check, err := r.azureEndpts.ValidateCustomDomain(ctx, "abstract-rg", name, name, armcdn.ValidateCustomDomainInput{HostName: to.Ptr(domain)}, nil)
+	if err != nil {
+		return err
+	}
+	if check.CustomDomainValidated == nil || !*check.CustomDomainValidated {
+		msg := ""
+		if check.Message != nil {
+			msg = *check.Message
+		}
+		return fmt.Errorf("%s must have a CNAME record pointing at the CDN endpoint: %s", domain, msg)
+	}
+	poller, err := r.azureDomains.BeginCreate(ctx, "abstract-rg", name, name, cdnDomainName(domain), armcdn.CustomDomainParameters{
+		Properties: &armcdn.CustomDomainPropertiesParameters{HostName: to.Ptr(domain)},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	_, err = shared.PollAzure(ctx, poller)
+	return err
+}
+
+// azureEnableHTTPS serves domain with the Key Vault certificate cert.
+func (r *CDNResource) azureEnableHTTPS(ctx context.Context, name, domain, cert string) error {
+	kv, err := parseKeyVaultCert(cert)
+	if err != nil {
+		return err
+	}
+	params := &armcdn.UserManagedHTTPSParameters{
+		CertificateSource: to.Ptr(armcdn.CertificateSourceAzureKeyVault),
+		ProtocolType:      to.Ptr(armcdn.ProtocolTypeServerNameIndication),
+		MinimumTLSVersion: to.Ptr(armcdn.MinimumTLSVersionTLS12),
+		CertificateSourceParameters: &armcdn.KeyVaultCertificateSourceParameters{
+			TypeName:          to.Ptr(armcdn.KeyVaultCertificateSourceParametersTypeNameKeyVaultCertificateSourceParameters),
+			SubscriptionID:    to.Ptr(kv.subscription),
+			ResourceGroupName: to.Ptr(kv.resourceGroup),
+			VaultName:         to.Ptr(kv.vault),
+			SecretName:        to.Ptr(kv.secret),
+			UpdateRule:        to.Ptr(armcdn.UpdateRuleNoAction),
+			DeleteRule:        to.Ptr(armcdn.DeleteRuleNoAction),
+		},
+	}
+	if kv.version != "" {
+		params.CertificateSourceParameters.SecretVersion = to.Ptr(kv.version)
+	}
+	_, err = r.azureDomains.EnableCustomHTTPS(ctx, "abstract-rg", name, name, cdnDomainName(domain), &armcdn.CustomDomainsClientEnableCustomHTTPSOptions{
+		CustomDomainHTTPSParameters: params,
+	})
+	return err
+}
+
+// gcpCheckCert verifies cert is a Google-managed certificate that covers domain.
+func (r *CDNResource) gcpCheckCert(ctx context.Context, cert, domain string) error {
+	name := cert[strings.LastIndex(cert, "/")+1:]
+	sc, err := r.gcp.SslCertificates.Get(r.gcpProj, name).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	if sc.Type != "MANAGED" || sc.Managed == nil {
+		return fmt.Errorf("%s is not a Google-managed certificate", name)
+	}
+	for _, d := range sc.Managed.Domains {
+		if strings.EqualFold(d, domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("managed certificate %s does not cover %s", name, domain)
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *CDNResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.cloudfront != nil
+	case "azure":
+		return r.azureRG != nil && r.azureProfiles != nil && r.azureEndpts != nil && r.azureDomains != nil
+	case "gcp":
+		return r.gcp != nil
+	}
+	return false
+}
+
+func (r *CDNResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan cdnResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.CloudID = types.StringNull()
+	bucket := plan.Bucket.ValueString()
+	domain := plan.CustomDomain.ValueString()
+	cert := plan.CertificateID.ValueString()
+
+	switch plan.Type.ValueString() {
+	case "aws":
+		originID := "s3-" + bucket
+		dist := &cftypes.DistributionConfig{
+			CallerReference:   aws.String(fmt.Sprintf("%s-%d", bucket, time.Now().UnixNano())),
+			Comment:           aws.String("abstract_cdn for " + bucket),
+			Enabled:           aws.Bool(true),
+			DefaultRootObject: aws.String("index.html"),
+			Origins: &cftypes.Origins{
+				Quantity: aws.Int32(1),
+				Items: []cftypes.Origin{{
+					Id:             aws.String(originID),
+					DomainName:     aws.String(bucket + ".s3.amazonaws.com"),
+					S3OriginConfig: &cftypes.S3OriginConfig{OriginAccessIdentity: aws.String("")},
+				}},
+			},
+			DefaultCacheBehavior: &cftypes.DefaultCacheBehavior{
+				TargetOriginId:       aws.String(originID),
+				ViewerProtocolPolicy: cftypes.ViewerProtocolPolicyRedirectToHttps,
+				CachePolicyId:        aws.String(cachingOptimizedPolicyID),
+			},
+			Aliases:           cdnAliases(domain),
+			ViewerCertificate: cdnViewerCertificate(cert),
+		}
+		out, err := r.cloudfront.CreateDistribution(ctx, &cloudfront.CreateDistributionInput{DistributionConfig: dist})
+		if err != nil {
+			resp.Diagnostics.AddError("aws create distribution", err.Error())
+			return
+		}
+		id := aws.ToString(out.Distribution.Id)
+		waiter := cloudfront.NewDistributionDeployedWaiter(r.cloudfront)
+		err = waiter.Wait(ctx, &cloudfront.GetDistributionInput{Id: aws.String(id)}, cdnDeployTimeout)
+		if err != nil {
+			resp.Diagnostics.AddError("aws wait distribution", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(id)
+		plan.DomainName = types.StringValue(aws.ToString(out.Distribution.DomainName))
+	case "azure":
+		rgName := "abstract-rg"
+		// CDN profiles are global; only the resource group has a location
+		loc := shared.AzureLocation("", r.azureLoc)
+		err := shared.EnsureResourceGroup(ctx, r.azureRG, rgName, loc)
+		if err != nil {
+			resp.Diagnostics.AddError("azure rg", err.Error())
+			return
+		}
+		name := cdnName(bucket)
+		profPoller, err := r.azureProfiles.BeginCreate(ctx, rgName, name, armcdn.Profile{
+			Location: to.Ptr("global"),
+			SKU:      &armcdn.SKU{Name: to.Ptr(armcdn.SKUNameStandardMicrosoft)},
+		}, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, profPoller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure cdn profile", err)
+			return
+		}
+		originHost := r.azureOriginHost(bucket)
+		epPoller, err := r.azureEndpts.BeginCreate(ctx, rgName, name, name, armcdn.Endpoint{
+			Location: to.Ptr("global"),
+			Properties: &armcdn.EndpointProperties{
+				OriginHostHeader: to.Ptr(originHost),
+				OriginPath:       to.Ptr("/" + bucket),
+				IsHTTPAllowed:    to.Ptr(false),
+				IsHTTPSAllowed:   to.Ptr(true),
+				Origins: []*armcdn.DeepCreatedOrigin{{
+					Name:       to.Ptr("blob"),
+					Properties: &armcdn.DeepCreatedOriginProperties{HostName: to.Ptr(originHost)},
+				}},
+			},
+		}, nil)
+		var ep armcdn.EndpointsClientCreateResponse
+		if err == nil {
+			ep, err = shared.PollAzure(ctx, epPoller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure cdn endpoint", err)
+			return
+		}
+		if domain != "" {
+			if err := r.azureBindDomain(ctx, name, domain); err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure cdn custom domain", err)
+				return
+			}
+		}
+		if cert != "" {
+			if err := r.azureEnableHTTPS(ctx, name, domain, cert); err != nil {
+				resp.Diagnostics.AddError("azure cdn https", err.Error())
+				return
+			}
+		}
+		host := ""
+		if ep.Properties != nil && ep.Properties.HostName != nil {
+			host = *ep.Properties.HostName
+		}
+		plan.ID = types.StringValue(*ep.ID)
+		plan.DomainName = types.StringValue(host)
+	case "gcp":
+		name := cdnName(bucket)
+		global := fmt.Sprintf("projects/%s/global", r.gcpProj)
+		if cert != "" {
+			if err := r.gcpCheckCert(ctx, cert, domain); err != nil {
+				resp.Diagnostics.AddError("gcp certificate", err.Error())
+				return
+			}
+		}
+		op, err := r.gcp.BackendBuckets.Insert(r.gcpProj, &compute.BackendBucket{
+			Name:       name,
+			BucketName: bucket,
+			EnableCdn:  true,
+		}).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp backend bucket", err.Error())
+			return
+		}
+		op, err = r.gcp.UrlMaps.Insert(r.gcpProj, &compute.UrlMap{
+			Name:           name,
+			DefaultService: global + "/backendBuckets/" + name,
+		}).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp url map", err.Error())
+			return
+		}
+		target, port := global+"/targetHttpProxies/"+name, "80"
+		if cert != "" {
+			target, port = global+"/targetHttpsProxies/"+name, "443"
+			op, err = r.gcp.TargetHttpsProxies.Insert(r.gcpProj, &compute.TargetHttpsProxy{
+				Name:            name,
+				UrlMap:          global + "/urlMaps/" + name,
+				SslCertificates: []string{gcpCertLink(r.gcpProj, cert)},
+			}).Context(ctx).Do()
+		} else {
+			op, err = r.gcp.TargetHttpProxies.Insert(r.gcpProj, &compute.TargetHttpProxy{
+				Name:   name,
+				UrlMap: global + "/urlMaps/" + name,
+			}).Context(ctx).Do()
+		}
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp proxy", err.Error())
+			return
+		}
+		op, err = r.gcp.GlobalForwardingRules.Insert(r.gcpProj, &compute.ForwardingRule{
+			Name:                name,
+			Target:              target,
+			PortRange:           port,
+			LoadBalancingScheme: "EXTERNAL",
+		}).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp forwarding rule", err.Error())
+			return
+		}
+		rule, err := r.gcp.GlobalForwardingRules.Get(r.gcpProj, name).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp forwarding rule", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(name)
+		plan.DomainName = types.StringValue(rule.IPAddress)
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	id, err := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// cloudID is the distribution's ARN, the endpoint's ID or the self link of the
+// global forwarding rule that fronts the bucket.
+func (r *CDNResource) cloudID(ctx context.Context, m *cdnResourceModel) (string, error) {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.ARN(ctx, "cloudfront", false, "distribution/"+id)
+	case "azure":
+		return id, nil
+	case "gcp":
+		return r.ids.GCPSelfLink("global/forwardingRules/" + id), nil
+	}
+	return "", nil
+}
+
+func (r *CDNResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state cdnResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		out, err := r.cloudfront.GetDistribution(ctx, &cloudfront.GetDistributionInput{Id: aws.String(state.ID.ValueString())})
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		state.DomainName = types.StringValue(aws.ToString(out.Distribution.DomainName))
+	case "azure":
+		name := cdnName(state.Bucket.ValueString())
+		ep, err := r.azureEndpts.Get(ctx, "abstract-rg", name, name, nil)
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		if ep.Properties != nil && ep.Properties.HostName != nil {
+			state.DomainName = types.StringValue(*ep.Properties.HostName)
+		}
+	case "gcp":
+		rule, err := r.gcp.GlobalForwardingRules.Get(r.gcpProj, state.ID.ValueString()).Context(ctx).Do()
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		state.DomainName = types.StringValue(rule.IPAddress)
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+// Update rebinds custom_domain and certificate_id; all other changes force replacement.
+func (r *CDNResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state cdnResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	domain, oldDomain := plan.CustomDomain.ValueString(), state.CustomDomain.ValueString()
+	cert, oldCert := plan.CertificateID.ValueString(), state.CertificateID.ValueString()
+
+	switch plan.Type.ValueString() {
+	case "aws":
+		id := aws.String(state.ID.ValueString())
+		cfg, err := r.cloudfront.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{Id: id})
+		if err != nil {
+			resp.Diagnostics.AddError("aws get distribution", err.Error())
+			return
+		}
+		cfg.DistributionConfig.Aliases = cdnAliases(domain)
+		cfg.DistributionConfig.ViewerCertificate = cdnViewerCertificate(cert)
+		_, err = r.cloudfront.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
+			Id:                 id,
+			IfMatch:            cfg.ETag,
+			DistributionConfig: cfg.DistributionConfig,
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("aws update distribution", err.Error())
+			return
+		}
+		waiter := cloudfront.NewDistributionDeployedWaiter(r.cloudfront)
+		err = waiter.Wait(ctx, &cloudfront.GetDistributionInput{Id: id}, cdnDeployTimeout)
+		if err != nil {
+			resp.Diagnostics.AddError("aws wait distribution", err.Error())
+			return
+		}
+	case "azure":
+		name := cdnName(plan.Bucket.ValueString())
+		if domain != oldDomain {
+			if oldDomain != "" {
+				poller, err := r.azureDomains.BeginDelete(ctx, "abstract-rg", name, name, cdnDomainName(oldDomain), nil)
+				if err == nil {
+					_, err = shared.PollAzure(ctx, poller)
+				}
+				if err != nil {
+					shared.AddAzureError(&resp.Diagnostics, "azure cdn custom domain", err)
+					return
+				}
+			}
+			if domain != "" {
+				if err := r.azureBindDomain(ctx, name, domain); err != nil {
+					shared.AddAzureError(&resp.Diagnostics, "azure cdn custom domain", err)
+					return
+				}
+			}
+		}
+		if cert != "" && (cert != oldCert || domain != oldDomain) {
+			if err := r.azureEnableHTTPS(ctx, name, domain, cert); err != nil {
+				resp.Diagnostics.AddError("azure cdn https", err.Error())
+				return
+			}
+		} else if cert == "" && oldCert != "" && domain == oldDomain {
+			_, err := r.azureDomains.DisableCustomHTTPS(ctx, "abstract-rg", name, name, cdnDomainName(domain), nil)
+			if err != nil {
+				resp.Diagnostics.AddError("azure cdn https", err.Error())
+				return
+			}
+		}
+	case "gcp":
+		// adding or removing the certificate forces replacement, so only a swap reaches here
+		if cert != "" && (cert != oldCert || domain != oldDomain) {
+			if err := r.gcpCheckCert(ctx, cert, domain); err != nil {
+				resp.Diagnostics.AddError("gcp certificate", err.Error())
+				return
+			}
+			op, err := r.gcp.TargetHttpsProxies.SetSslCertificates(r.gcpProj, state.ID.ValueString(), &compute.TargetHttpsProxiesSetSslCertificatesRequest{
+				SslCertificates: []string{gcpCertLink(r.gcpProj, cert)},
+			}).Context(ctx).Do()
+			if err == nil {
+				err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp https proxy", err.Error())
+				return
+			}
+		}
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	plan.DomainName = state.DomainName
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *CDNResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state cdnResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		id := aws.String(state.ID.ValueString())
+		// a distribution must be disabled and fully deployed before it can be deleted
+		cfg, err := r.cloudfront.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{Id: id})
+		if err != nil {
+			resp.Diagnostics.AddError("aws get distribution", err.Error())
+			return
+		}
+		etag := cfg.ETag
+		if aws.ToBool(cfg.DistributionConfig.Enabled) {
+			cfg.DistributionConfig.Enabled = aws.Bool(false)
+			upd, err := r.cloudfront.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
+				Id:                 id,
+				IfMatch:            etag,
+				DistributionConfig: cfg.DistributionConfig,
+			})
+			if err != nil {
+				resp.Diagnostics.AddError("aws disable distribution", err.Error())
+				return
+			}
+			etag = upd.ETag
+		}
+		waiter := cloudfront.NewDistributionDeployedWaiter(r.cloudfront)
+		err = waiter.Wait(ctx, &cloudfront.GetDistributionInput{Id: id}, cdnDeployTimeout)
+		if err != nil {
+			resp.Diagnostics.AddError("aws wait distribution", err.Error())
+			return
+		}
+		_, err = r.cloudfront.DeleteDistribution(ctx, &cloudfront.DeleteDistributionInput{Id: id, IfMatch: etag})
+		if err != nil {
+			resp.Diagnostics.AddError("aws delete", err.Error())
+		}
+	case "azure":
+		name := cdnName(state.Bucket.ValueString())
+		epPoller, err := r.azureEndpts.BeginDelete(ctx, "abstract-rg", name, name, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, epPoller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete endpoint", err)
+			return
+		}
+		profPoller, err := r.azureProfiles.BeginDelete(ctx, "abstract-rg", name, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, profPoller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete profile", err)
+		}
+	case "gcp":
+		name := state.ID.ValueString()
+		// tear down in reverse dependency order
+		op, err := r.gcp.GlobalForwardingRules.Delete(r.gcpProj, name).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete forwarding rule", err.Error())
+			return
+		}
+		if state.CertificateID.ValueString() != "" {
+			op, err = r.gcp.TargetHttpsProxies.Delete(r.gcpProj, name).Context(ctx).Do()
+		} else {
+			op, err = r.gcp.TargetHttpProxies.Delete(r.gcpProj, name).Context(ctx).Do()
+		}
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete proxy", err.Error())
+			return
+		}
+		op, err = r.gcp.UrlMaps.Delete(r.gcpProj, name).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete url map", err.Error())
+			return
+		}
+		op, err = r.gcp.BackendBuckets.Delete(r.gcpProj, name).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete backend bucket", err.Error())
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package resources
+
+import "testing"
+
+func TestCDNAzureOriginHost(t *testing.T) {
+	if got := (&CDNResource{}).azureOriginHost("Assets"); got != "assets.blob.core.windows.net" {
+		t.Errorf("origin = %q, want the bucket's own account", got)
+	}
+	r := &CDNResource{azureSharedAcct: "sharedacct", azureSharedRG: "shared-rg"}
+	if got := r.azureOriginHost("web-assets"); got != "sharedacct.blob.core.windows.net" {
+		t.Errorf("origin = %q, want the shared account", got)
+	}
+}
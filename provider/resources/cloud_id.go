@@ -0,0 +1,50 @@
+package resources
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// cloudIDAttribute is every resource's cloud_id: its ARN, Azure resource ID
+// or GCP self link or full name, whatever the resource keeps in id.
+func cloudIDAttribute() schema.StringAttribute {
+	return schema.StringAttribute{
+		Computed:      true,
+		PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+	}
+}
+
+// setCloudID records a built cloud_id, null when there is none. The resource
+// exists either way, so a failed lookup only warns.
+func setCloudID(diags *diag.Diagnostics, dst *types.String, id string, err error) {
+	*dst = types.StringNull()
+	if err != nil {
+		diags.AddAttributeWarning(path.Root("cloud_id"), "cloud_id unavailable", err.Error())
+		return
+	}
+	if id != "" {
+		*dst = types.StringValue(id)
+	}
+}
+
+// refreshCloudID fills in cloud_id on Read for resources created before the
+// attribute existed.
+func refreshCloudID(ctx context.Context, state *tfsdk.State, diags *diag.Diagnostics, current types.String, build func() (string, error)) {
+	if !current.IsNull() && !current.IsUnknown() {
+		return
+	}
+	id, err := build()
+	var value types.String
+	setCloudID(diags, &value, id, err)
+	if value.IsNull() {
+		return
+	}
+	diags.Append(state.SetAttribute(ctx, path.Root("cloud_id"), value)...)
+}
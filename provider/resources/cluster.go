@@ -3,10 +3,13 @@ package resources
 import (
 	"context"
 	"fmt"
-	"os"
+	"maps"
+	"strconv"
+	"strings"
 	"time"
 
 	"abstract-provider/provider/shared"
+	"abstract-provider/provider/shared/naming"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice"
@@ -16,8 +19,15 @@ import (
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/eks"
 	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	container "google.golang.org/api/container/v1"
 )
@@ -34,8 +44,99 @@ type ClusterResource struct {
 	gke       *container.Service
 	gcpProj   string
 	gcpRegion string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	// defaultTags are the provider's default_tags, merged under GCP labels.
+	defaultTags map[string]string
+
+	ids *shared.CloudIDs
+}
+
+type clusterResourceModel struct {
+	ID          types.String `tfsdk:"id"`
+	CloudID     types.String `tfsdk:"cloud_id"`
+	Name        types.String `tfsdk:"name"`
+	Type        types.String `tfsdk:"type"`
+	Region      types.String `tfsdk:"region"`
+	NodeCount   types.Int64  `tfsdk:"node_count"`
+	NodeSize    types.String `tfsdk:"node_size"`
+	RoleARN     types.String `tfsdk:"role_arn"`
+	NodeRoleARN types.String `tfsdk:"node_role_arn"`
+
+	KubernetesVersion types.String `tfsdk:"kubernetes_version"`
+
+	NetworkID types.String `tfsdk:"network_id"`
+	SubnetIDs []string     `tfsdk:"subnet_ids"`
+	Spot      types.Bool   `tfsdk:"spot"`
+
+	MinNodes types.Int64 `tfsdk:"min_nodes"`
+	MaxNodes types.Int64 `tfsdk:"max_nodes"`
+
+	Labels types.Map `tfsdk:"labels"`
+}
+
+// autoscaling returns min_nodes and max_nodes when both are set.
+func (m clusterResourceModel) autoscaling() (lo, hi int64, ok bool) {
+	if m.MinNodes.IsNull() || m.MaxNodes.IsNull() {
+		return 0, 0, false
+	}
+	return m.MinNodes.ValueInt64(), m.MaxNodes.ValueInt64(), true
+}
+
+// nodeCount returns node_count, or 3 when unset, kept between min_nodes and
+// max_nodes when the cluster autoscales.
+func (m clusterResourceModel) nodeCount() int64 {
+	n := int64(3)
+	if m.NodeCount.ValueInt64() > 0 {
+		n = m.NodeCount.ValueInt64()
+	}
+	if lo, hi, ok := m.autoscaling(); ok {
+		n = max(lo, min(n, hi))
+	}
+	return n
+}
+
+// setDefaults records the region, node size and node count Create used for
+// attributes left out of the config; configured values are kept as written.
+func (m *clusterResourceModel) setDefaults(region, size string, count int64) {
+	if m.Region.IsNull() || m.Region.IsUnknown() {
+		m.Region = types.StringValue(region)
+	}
+	if m.NodeSize.IsNull() || m.NodeSize.IsUnknown() {
+		m.NodeSize = types.StringValue(size)
+	}
+	if m.NodeCount.IsNull() || m.NodeCount.IsUnknown() {
+		m.NodeCount = types.Int64Value(count)
+	}
 }
 
+// replaceIfConfigured replaces the cluster when a configured value changes;
+// leaving the attribute out keeps the value in state.
+var replaceIfConfigured = stringplanmodifier.RequiresReplaceIf(
+	func(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+		resp.RequiresReplace = !req.ConfigValue.IsNull()
+	},
+	"Changing this replaces the cluster.",
+	"Changing this replaces the cluster.",
+)
+
+// replaceOnNewRegion replaces the cluster when the configured region names
+// another one. A new spelling ("East US" for "eastus") is only recorded, and
+// leaving region out keeps the state, which is null for AWS clusters created
+// before the region was recorded.
+var replaceOnNewRegion = stringplanmodifier.RequiresReplaceIf(
+	func(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+		resp.RequiresReplace = !req.ConfigValue.IsNull() && !sameRegion(req.ConfigValue.ValueString(), req.StateValue.ValueString())
+	},
+	"Moving to another region replaces the cluster.",
+	"Moving to another region replaces the cluster.",
+)
+
 func NewClusterResource() resource.Resource { return &ClusterResource{} }
 
 func (r *ClusterResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
@@ -47,6 +148,10 @@ func (r *ClusterResource) Configure(ctx context.Context, req resource.ConfigureR
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.defaultTags = cfg.DefaultTags
+	r.ids = cfg.CloudIDs
 	r.eks = cfg.AWSEKS
 	r.ec2 = cfg.AWSEC2
 	r.azureAKS = cfg.AzureAKSClient
@@ -65,64 +170,194 @@ func (r *ClusterResource) Metadata(ctx context.Context, req resource.MetadataReq
 func (r *ClusterResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
-			"id":         schema.StringAttribute{Computed: true},
-			"name":       schema.StringAttribute{Optional: true},
-			"type":       schema.StringAttribute{Required: true},
-			"region":     schema.StringAttribute{Optional: true},
-			"node_count": schema.Int64Attribute{Optional: true},
-			"node_size":  schema.StringAttribute{Optional: true},
+			"id":       schema.StringAttribute{Computed: true},
+			"cloud_id": cloudIDAttribute(),
+			"type":     schema.StringAttribute{Required: true},
+			// Defaults to abstract-cluster on GCP.
+			"name":   schema.StringAttribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown(), replaceIfConfigured}},
+			"region": schema.StringAttribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown(), replaceOnNewRegion}},
+			// Defaults to 3 nodes of a general-purpose size; node_count is
+			// resized in place and a new node_size replaces the cluster.
+			"node_count": schema.Int64Attribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()}},
+			"node_size":  schema.StringAttribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown(), replaceIfConfigured}},
+
+			// Autoscaling bounds; node_count is then only the initial size.
+			"min_nodes": schema.Int64Attribute{Optional: true},
+			"max_nodes": schema.Int64Attribute{Optional: true},
+
+			// EKS cluster and node group roles; neither can be changed after creation.
+			"role_arn":      schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"node_role_arn": schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+
+			// Control plane version ("1.29"); upgraded in place one minor version at a time.
+			"kubernetes_version": schema.StringAttribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.String{
+				stringplanmodifier.UseStateForUnknown(),
+				stringplanmodifier.RequiresReplaceIf(func(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+					resp.RequiresReplace = !k8sUpgradable(req.StateValue.ValueString(), req.PlanValue.ValueString())
+				}, "Downgrades and upgrades of more than one minor version replace the cluster.", "Downgrades and upgrades of more than one minor version replace the cluster."),
+			}},
+
+			// An existing network, e.g. an abstract_network's id and subnet_id.
+			// Without them the default VPC, abstract-vnet or default network is used.
+			"network_id": schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"subnet_ids": schema.ListAttribute{ElementType: types.StringType, Optional: true, PlanModifiers: []planmodifier.List{listplanmodifier.RequiresReplace()}},
+			// Spot (AWS, Azure, GCP) capacity for the nodes; fixed at creation.
+			// State from before the attribute existed is null and already matches the default.
+			"spot": schema.BoolAttribute{Optional: true, Computed: true, Default: booldefault.StaticBool(false),
+				PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplaceIf(
+					func(ctx context.Context, req planmodifier.BoolRequest, resp *boolplanmodifier.RequiresReplaceIfFuncResponse) {
+						resp.RequiresReplace = req.StateValue.ValueBool() != req.PlanValue.ValueBool()
+					},
+					"Changing spot replaces the cluster.",
+					"Changing spot replaces the cluster.",
+				)}},
+			// GCP only: GKE resource labels, updated in place.
+			"labels": schema.MapAttribute{ElementType: types.StringType, Optional: true},
 		},
 	}
 }
 
-func (r *ClusterResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name      types.String `tfsdk:"name"`
-		Type      types.String `tfsdk:"type"`
-		Region    types.String `tfsdk:"region"`
-		NodeCount types.Int64  `tfsdk:"node_count"`
-		NodeSize  types.String `tfsdk:"node_size"`
+func (r *ClusterResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cloud, size types.String
+	var spot types.Bool
+	var count, lo, hi types.Int64
+	var subnets types.List
+	var labels types.Map
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("type"), &cloud)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("node_size"), &size)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("spot"), &spot)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("node_count"), &count)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("min_nodes"), &lo)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("max_nodes"), &hi)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("subnet_ids"), &subnets)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("labels"), &labels)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if lo.IsNull() != hi.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("max_nodes"), "incomplete autoscaling", "min_nodes and max_nodes must be set together")
+	} else if !lo.IsNull() && !lo.IsUnknown() && !hi.IsUnknown() {
+		// the AKS system pool always keeps a node
+		least := int64(0)
+		if cloud.ValueString() == "azure" && !spot.ValueBool() {
+			least = 1
+		}
+		switch {
+		case lo.ValueInt64() < least:
+			resp.Diagnostics.AddAttributeError(path.Root("min_nodes"), "invalid min_nodes", fmt.Sprintf("min_nodes must be at least %d", least))
+		case hi.ValueInt64() < max(lo.ValueInt64(), 1):
+			resp.Diagnostics.AddAttributeError(path.Root("max_nodes"), "invalid max_nodes", "max_nodes must be at least 1 and no less than min_nodes")
+		case !count.IsNull() && !count.IsUnknown() && (count.ValueInt64() < lo.ValueInt64() || count.ValueInt64() > hi.ValueInt64()):
+			resp.Diagnostics.AddAttributeError(path.Root("node_count"), "node_count out of range", fmt.Sprintf("node_count must be between min_nodes (%d) and max_nodes (%d)", lo.ValueInt64(), hi.ValueInt64()))
+		}
+	}
+	if cloud.IsUnknown() {
+		return
+	}
+	checkGCPLabels(&resp.Diagnostics, cloud, "labels", labels)
+	if spot.ValueBool() && cloud.ValueString() == "azure" {
+		// B-series and promo sizes cannot run as spot VMs
+		if s := strings.ToLower(size.ValueString()); strings.HasPrefix(s, "standard_b") || strings.HasSuffix(s, "_promo") {
+			resp.Diagnostics.AddAttributeError(path.Root("node_size"), "no spot capacity", fmt.Sprintf("%s is not available as an Azure spot VM", size.ValueString()))
+		}
+	}
+	if subnets.IsNull() || subnets.IsUnknown() {
+		return
+	}
+	n := len(subnets.Elements())
+	switch cloud.ValueString() {
+	case "aws":
+		if n < 2 {
+			resp.Diagnostics.AddAttributeError(path.Root("subnet_ids"), "too few subnets", "EKS needs subnets in at least two availability zones")
+		}
+	case "azure", "gcp":
+		if n > 1 {
+			resp.Diagnostics.AddAttributeError(path.Root("subnet_ids"), "too many subnets", fmt.Sprintf("%s node pools use a single subnet", cloud.ValueString()))
+		}
 	}
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *ClusterResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.eks = c.EKS
+		r.ec2 = c.EC2
+	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *ClusterResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.eks != nil && r.ec2 != nil
+	case "azure":
+		return r.azureRG != nil && r.azureAKS != nil
+	case "gcp":
+		return r.gke != nil
+	}
+	return false
+}
+
+func (r *ClusterResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan clusterResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.CloudID = types.StringNull()
 	switch plan.Type.ValueString() {
 	case "aws":
-		if r.eks == nil || r.ec2 == nil {
-			resp.Diagnostics.AddError("missing AWS client", "")
-			return
-		}
 
-		// determine subnets from default VPC
-		vpcs, err := r.ec2.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{Filters: []ec2types.Filter{{Name: aws.String("isDefault"), Values: []string{"true"}}}})
-		if err != nil || len(vpcs.Vpcs) == 0 {
-			resp.Diagnostics.AddError("aws default vpc", "unable to find default vpc")
-			return
+		subnetIDs := plan.SubnetIDs
+		if len(subnetIDs) == 0 {
+			// determine subnets from network_id or the default VPC
+			vpcID := plan.NetworkID.ValueString()
+			if vpcID == "" {
+				vpcs, err := r.ec2.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{Filters: []ec2types.Filter{{Name: aws.String("isDefault"), Values: []string{"true"}}}})
+				if err != nil || len(vpcs.Vpcs) == 0 {
+					resp.Diagnostics.AddError("aws default vpc", "unable to find default vpc")
+					return
+				}
+				vpcID = aws.ToString(vpcs.Vpcs[0].VpcId)
+			}
+			subnetsOut, err := r.ec2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{Filters: []ec2types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}})
+			if err != nil || len(subnetsOut.Subnets) == 0 {
+				resp.Diagnostics.AddError("aws subnets", fmt.Sprintf("unable to find subnets in vpc %s", vpcID))
+				return
+			}
+			for i, s := range subnetsOut.Subnets {
+				if i >= 2 {
+					break
+				}
+				subnetIDs = append(subnetIDs, aws.ToString(s.SubnetId))
+			}
 		}
-		vpcID := aws.ToString(vpcs.Vpcs[0].VpcId)
-		subnetsOut, err := r.ec2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{Filters: []ec2types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}})
-		if err != nil || len(subnetsOut.Subnets) == 0 {
-			resp.Diagnostics.AddError("aws subnets", "unable to find subnets in default vpc")
-			return
+		role := roleARN(plan.RoleARN, "role_arn", "EKS_ROLE_ARN", &resp.Diagnostics)
+		if role == "" {
+			resp.Diagnostics.AddAttributeError(path.Root("role_arn"), "missing role", "role_arn must be set for aws clusters")
 		}
-		subnetIDs := []string{}
-		for i, s := range subnetsOut.Subnets {
-			if i >= 2 {
-				break
-			}
-			subnetIDs = append(subnetIDs, aws.ToString(s.SubnetId))
+		nodeRole := roleARN(plan.NodeRoleARN, "node_role_arn", "EKS_NODE_ROLE_ARN", &resp.Diagnostics)
+		if nodeRole == "" {
+			resp.Diagnostics.AddAttributeError(path.Root("node_role_arn"), "missing role", "node_role_arn must be set for aws clusters")
 		}
-		role := os.Getenv("EKS_ROLE_ARN")
-		nodeRole := os.Getenv("EKS_NODE_ROLE_ARN")
-		if role == "" || nodeRole == "" {
-			resp.Diagnostics.AddError("missing roles", "EKS_ROLE_ARN and EKS_NODE_ROLE_ARN must be set")
+		if resp.Diagnostics.HasError() {
 			return
 		}
-		_, err = r.eks.CreateCluster(ctx, &eks.CreateClusterInput{
+		out, err := r.eks.CreateCluster(ctx, &eks.CreateClusterInput{
 			Name:    aws.String(plan.Name.ValueString()),
 			RoleArn: aws.String(role),
+			Version: plan.KubernetesVersion.ValueStringPointer(),
 			ResourcesVpcConfig: &ekstypes.VpcConfigRequest{
 				SubnetIds: subnetIDs,
 			},
@@ -131,93 +366,74 @@ func (r *ClusterResource) Create(ctx context.Context, req resource.CreateRequest
 			resp.Diagnostics.AddError("aws create cluster", err.Error())
 			return
 		}
-		desired := int32(3)
-		if plan.NodeCount.ValueInt64() > 0 {
-			desired = int32(plan.NodeCount.ValueInt64())
-		}
+		desired := int32(plan.nodeCount())
 		instanceType := plan.NodeSize.ValueString()
 		if instanceType == "" {
 			instanceType = "t3.medium"
 		}
+		capacity := ekstypes.CapacityTypesOnDemand
+		if plan.Spot.ValueBool() {
+			capacity = ekstypes.CapacityTypesSpot
+		}
 		_, err = r.eks.CreateNodegroup(ctx, &eks.CreateNodegroupInput{
 			ClusterName:   aws.String(plan.Name.ValueString()),
 			NodegroupName: aws.String(plan.Name.ValueString() + "-ng"),
 			NodeRole:      aws.String(nodeRole),
 			Subnets:       subnetIDs,
-			ScalingConfig: &ekstypes.NodegroupScalingConfig{DesiredSize: aws.Int32(desired), MinSize: aws.Int32(desired), MaxSize: aws.Int32(desired)},
+			ScalingConfig: eksScaling(plan, desired),
 			InstanceTypes: []string{instanceType},
+			CapacityType:  capacity,
 		})
 		if err != nil {
 			resp.Diagnostics.AddError("aws create nodegroup", err.Error())
 			return
 		}
 
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":         plan.Name.ValueString(),
-			"name":       plan.Name.ValueString(),
-			"type":       plan.Type.ValueString(),
-			"region":     plan.Region.ValueString(),
-			"node_count": int64(desired),
-			"node_size":  instanceType,
-		})
+		plan.ID = plan.Name
+		plan.setDefaults(r.eks.Options().Region, instanceType, int64(desired))
+		plan.setVersion(aws.ToString(out.Cluster.Version))
 	case "azure":
-		if r.azureAKS == nil || r.azureRG == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
-			return
-		}
-		if r.azureLoc == "" {
-			r.azureLoc = plan.Region.ValueString()
-			if r.azureLoc == "" {
-				r.azureLoc = "eastus"
-			}
-		}
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
 		rgName := "abstract-rg"
-		_, err := r.azureRG.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{Location: &r.azureLoc}, nil)
+		err := shared.EnsureResourceGroup(ctx, r.azureRG, rgName, loc)
 		if err != nil {
 			resp.Diagnostics.AddError("azure rg", err.Error())
 			return
 		}
-		nodeCount := int32(3)
-		if plan.NodeCount.ValueInt64() > 0 {
-			nodeCount = int32(plan.NodeCount.ValueInt64())
-		}
+		nodeCount := int32(plan.nodeCount())
 		vmSize := plan.NodeSize.ValueString()
 		if vmSize == "" {
 			vmSize = "Standard_DS2_v2"
 		}
 		name := plan.Name.ValueString()
+		dnsPrefix, err := naming.AzureDNSPrefix(name)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("name"), "invalid name", err.Error())
+			return
+		}
 		poller, err := r.azureAKS.BeginCreateOrUpdate(ctx, rgName, name, armcontainerservice.ManagedCluster{
-			Location: &r.azureLoc,
+			Location: &loc,
 			Properties: &armcontainerservice.ManagedClusterProperties{
-				DNSPrefix: &name,
-				AgentPoolProfiles: []*armcontainerservice.ManagedClusterAgentPoolProfile{{
-					Name:   to.Ptr("nodepool1"),
-					Count:  &nodeCount,
-					VMSize: &vmSize,
-				}},
+				DNSPrefix:         &dnsPrefix,
+				KubernetesVersion: plan.KubernetesVersion.ValueStringPointer(),
+				AgentPoolProfiles: azureAgentPools(plan, nodeCount, vmSize),
 			},
 		}, nil)
+		var res armcontainerservice.ManagedClustersClientCreateOrUpdateResponse
 		if err == nil {
-			_, err = poller.PollUntilDone(ctx, nil)
+			res, err = shared.PollAzure(ctx, poller)
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure create aks", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure create aks", err)
 			return
 		}
 
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":         plan.Name.ValueString(),
-			"name":       plan.Name.ValueString(),
-			"type":       plan.Type.ValueString(),
-			"region":     r.azureLoc,
-			"node_count": int64(nodeCount),
-			"node_size":  vmSize,
-		})
-	case "gcp":
-		if r.gke == nil {
-			resp.Diagnostics.AddError("gcp", "missing client")
-			return
+		plan.ID = plan.Name
+		plan.setDefaults(loc, vmSize, int64(nodeCount))
+		if res.Properties != nil && res.Properties.CurrentKubernetesVersion != nil {
+			plan.setVersion(*res.Properties.CurrentKubernetesVersion)
 		}
+	case "gcp":
 		region := plan.Region.ValueString()
 		if region == "" {
 			region = r.gcpRegion
@@ -229,109 +445,324 @@ func (r *ClusterResource) Create(ctx context.Context, req resource.CreateRequest
 		if name == "" {
 			name = "abstract-cluster"
 		}
-		count := int64(3)
-		if plan.NodeCount.ValueInt64() > 0 {
-			count = plan.NodeCount.ValueInt64()
-		}
+		count := plan.nodeCount()
 		machine := plan.NodeSize.ValueString()
 		if machine == "" {
 			machine = "e2-medium"
 		}
 		parent := fmt.Sprintf("projects/%s/locations/%s", r.gcpProj, region)
 		cluster := &container.Cluster{
-			Name:             name,
-			InitialNodeCount: count,
-			NodeConfig: &container.NodeConfig{
-				MachineType: machine,
-			},
+			Name:                  name,
+			InitialClusterVersion: plan.KubernetesVersion.ValueString(),
+			NodePools: []*container.NodePool{{
+				Name:             "default-pool",
+				InitialNodeCount: count,
+				Config: &container.NodeConfig{
+					MachineType: machine,
+					Spot:        plan.Spot.ValueBool(),
+				},
+				Autoscaling: gkeAutoscaling(plan),
+			}},
+			// names resolve in the project and, for the subnetwork, the cluster's region
+			Network: plan.NetworkID.ValueString(),
+		}
+		if labels := shared.MergeTags(r.defaultTags, stringMap(plan.Labels)); len(labels) > 0 {
+			cluster.ResourceLabels = labels
+		}
+		if len(plan.SubnetIDs) > 0 {
+			cluster.Subnetwork = plan.SubnetIDs[0]
+		}
+		op, err := r.gke.Projects.Locations.Clusters.Create(parent, &container.CreateClusterRequest{Cluster: cluster}).Context(ctx).Do()
+		if err == nil {
+			err = r.waitGKE(ctx, op)
 		}
-		op, err := r.gke.Projects.Locations.Clusters.Create(parent, cluster).Context(ctx).Do()
 		if err != nil {
 			resp.Diagnostics.AddError("gcp create cluster", err.Error())
 			return
 		}
-		for {
-			oper, err := r.gke.Projects.Locations.Operations.Get(op.Name).Context(ctx).Do()
-			if err != nil {
-				resp.Diagnostics.AddError("gcp create cluster", err.Error())
-				return
-			}
-			if oper.Status == "DONE" {
-				break
-			}
-			time.Sleep(5 * time.Second)
-		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":         name,
-			"name":       name,
-			"type":       plan.Type.ValueString(),
-			"region":     region,
-			"node_count": count,
-			"node_size":  machine,
-		})
+		plan.ID = types.StringValue(name)
+		plan.Name = types.StringValue(name)
+		plan.setDefaults(region, machine, count)
+		created, err := r.gke.Projects.Locations.Clusters.Get(r.gkeName(plan)).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp create cluster", err.Error())
+			return
+		}
+		plan.setVersion(created.CurrentMasterVersion)
 	default:
 		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
 	}
+	id, err := r.cloudID(ctx, plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
-func (r *ClusterResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID   types.String `tfsdk:"id"`
-		Type types.String `tfsdk:"type"`
+// cloudID is the EKS cluster's ARN, the AKS cluster's ID or the GKE cluster's
+// full name.
+func (r *ClusterResource) cloudID(ctx context.Context, m clusterResourceModel) (string, error) {
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.ARN(ctx, "eks", true, "cluster/"+m.ID.ValueString())
+	case "azure":
+		return r.ids.AzureID("abstract-rg", "Microsoft.ContainerService/managedClusters", m.ID.ValueString()), nil
+	case "gcp":
+		return r.gkeName(m), nil
 	}
+	return "", nil
+}
+
+func (r *ClusterResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state clusterResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.eks == nil {
-			return
-		}
-		_, err := r.eks.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(state.ID.ValueString())})
+		out, err := r.eks.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(state.ID.ValueString())})
 		if err != nil {
 			resp.State.RemoveResource(ctx)
-		}
-	case "azure":
-		if r.azureAKS == nil {
 			return
 		}
-		_, err := r.azureAKS.Get(ctx, "abstract-rg", state.ID.ValueString(), nil)
+		state.setVersion(aws.ToString(out.Cluster.Version))
+	case "azure":
+		res, err := r.azureAKS.Get(ctx, "abstract-rg", state.ID.ValueString(), nil)
 		if err != nil {
 			resp.State.RemoveResource(ctx)
-		}
-	case "gcp":
-		if r.gke == nil {
 			return
 		}
-		region := r.gcpRegion
-		if region == "" {
-			region = "us-central1"
+		if res.Properties != nil && res.Properties.CurrentKubernetesVersion != nil {
+			state.setVersion(*res.Properties.CurrentKubernetesVersion)
 		}
-		_, err := r.gke.Projects.Locations.Clusters.Get(fmt.Sprintf("projects/%s/locations/%s/clusters/%s", r.gcpProj, region, state.ID.ValueString())).Context(ctx).Do()
+	case "gcp":
+		cluster, err := r.gke.Projects.Locations.Clusters.Get(r.gkeName(state)).Context(ctx).Do()
 		if err != nil {
 			resp.State.RemoveResource(ctx)
+			return
 		}
+		state.setVersion(cluster.CurrentMasterVersion)
+	default:
+		return
 	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, state) })
 }
+
+// Update upgrades the control plane and then the nodes when
+// kubernetes_version changes, and applies node_count, min_nodes and
+// max_nodes to the node pool; larger version changes replace the cluster.
 func (r *ClusterResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state clusterResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	// only a region missing from state from before it was recorded is
+	// unknown here, and the cluster is still where it was created
+	if plan.Region.IsUnknown() {
+		plan.Region = state.Region
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	if plan.KubernetesVersion.IsUnknown() {
+		plan.KubernetesVersion = state.KubernetesVersion
+	}
+	if plan.Name.IsUnknown() {
+		plan.Name = state.Name
+	}
+	if plan.NodeSize.IsUnknown() {
+		plan.NodeSize = state.NodeSize
+	}
+	if plan.NodeCount.IsUnknown() {
+		plan.NodeCount = state.NodeCount
+	}
+	version := plan.KubernetesVersion.ValueString()
+	upgrade := version != "" && !sameVersion(version, state.KubernetesVersion.ValueString())
+	rescale := !plan.MinNodes.Equal(state.MinNodes) || !plan.MaxNodes.Equal(state.MaxNodes)
+	resize := !plan.NodeCount.Equal(state.NodeCount)
+	if _, _, ok := plan.autoscaling(); ok && resize {
+		// the autoscaler owns the size between min_nodes and max_nodes
+		resp.Diagnostics.AddAttributeWarning(path.Root("node_count"), "node_count not applied", "node_count only sets the initial size of an autoscaling cluster")
+		resize = false
+	}
+	rescale = rescale || resize
+	relabel := state.Type.ValueString() == "gcp" && !maps.Equal(stringMap(plan.Labels), stringMap(state.Labels))
+	if !upgrade && !rescale && !relabel {
+		resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+		return
+	}
+	name := state.ID.ValueString()
+	switch state.Type.ValueString() {
+	case "aws":
+		nodeGroup := name + "-ng"
+		if upgrade {
+			up, err := r.eks.UpdateClusterVersion(ctx, &eks.UpdateClusterVersionInput{Name: aws.String(name), Version: aws.String(version)})
+			if err == nil {
+				err = r.waitEKSUpdate(ctx, name, "", up.Update)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("aws upgrade cluster", err.Error())
+				return
+			}
+			ngUp, err := r.eks.UpdateNodegroupVersion(ctx, &eks.UpdateNodegroupVersionInput{ClusterName: aws.String(name), NodegroupName: aws.String(nodeGroup), Version: aws.String(version)})
+			if err == nil {
+				err = r.waitEKSUpdate(ctx, name, nodeGroup, ngUp.Update)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("aws upgrade nodegroup", err.Error())
+				return
+			}
+		}
+		if rescale {
+			// keep the running size when it is still within the new bounds
+			desired := int32(plan.nodeCount())
+			if _, _, ok := plan.autoscaling(); ok {
+				ng, err := r.eks.DescribeNodegroup(ctx, &eks.DescribeNodegroupInput{ClusterName: aws.String(name), NodegroupName: aws.String(nodeGroup)})
+				if err != nil {
+					resp.Diagnostics.AddError("aws scale nodegroup", err.Error())
+					return
+				}
+				if sc := ng.Nodegroup.ScalingConfig; sc != nil && sc.DesiredSize != nil {
+					desired = *sc.DesiredSize
+				}
+			}
+			up, err := r.eks.UpdateNodegroupConfig(ctx, &eks.UpdateNodegroupConfigInput{ClusterName: aws.String(name), NodegroupName: aws.String(nodeGroup), ScalingConfig: eksScaling(plan, desired)})
+			if err == nil {
+				err = r.waitEKSUpdate(ctx, name, nodeGroup, up.Update)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("aws scale nodegroup", err.Error())
+				return
+			}
+		}
+	case "azure":
+		res, err := r.azureAKS.Get(ctx, "abstract-rg", name, nil)
+		if err != nil {
+			resp.Diagnostics.AddError("azure update aks", err.Error())
+			return
+		}
+		cluster := res.ManagedCluster
+		if cluster.Properties == nil {
+			resp.Diagnostics.AddError("azure update aks", "cluster has no properties")
+			return
+		}
+		if upgrade {
+			cluster.Properties.KubernetesVersion = &version
+			for _, pool := range cluster.Properties.AgentPoolProfiles {
+				pool.OrchestratorVersion = &version
+			}
+		}
+		if rescale {
+			// the spot pool holds the nodes when there is one
+			want := "nodepool1"
+			if state.Spot.ValueBool() {
+				want = "spot"
+			}
+			for _, pool := range cluster.Properties.AgentPoolProfiles {
+				if pool.Name != nil && *pool.Name == want {
+					setAzureScaling(pool, plan)
+				}
+			}
+		}
+		poller, err := r.azureAKS.BeginCreateOrUpdate(ctx, "abstract-rg", name, cluster, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure update aks", err)
+			return
+		}
+	case "gcp":
+		if upgrade {
+			// GKE takes one change per request: the master first, then the
+			// default node pool.
+			for _, update := range []*container.ClusterUpdate{
+				{DesiredMasterVersion: version},
+				{DesiredNodeVersion: version, DesiredNodePoolId: "default-pool"},
+			} {
+				op, err := r.gke.Projects.Locations.Clusters.Update(r.gkeName(state), &container.UpdateClusterRequest{Update: update}).Context(ctx).Do()
+				if err == nil {
+					err = r.waitGKE(ctx, op)
+				}
+				if err != nil {
+					resp.Diagnostics.AddError("gcp upgrade cluster", err.Error())
+					return
+				}
+			}
+		}
+		if rescale {
+			pool := r.gkeName(state) + "/nodePools/default-pool"
+			op, err := r.gke.Projects.Locations.Clusters.NodePools.SetAutoscaling(pool, &container.SetNodePoolAutoscalingRequest{Autoscaling: gkeAutoscaling(plan)}).Context(ctx).Do()
+			if err == nil {
+				err = r.waitGKE(ctx, op)
+			}
+			if _, _, ok := plan.autoscaling(); !ok && err == nil {
+				// back to a fixed size
+				op, err = r.gke.Projects.Locations.Clusters.NodePools.SetSize(pool, &container.SetNodePoolSizeRequest{NodeCount: plan.nodeCount()}).Context(ctx).Do()
+				if err == nil {
+					err = r.waitGKE(ctx, op)
+				}
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp scale node pool", err.Error())
+				return
+			}
+		}
+		if relabel {
+			// SetResourceLabels replaces every label and needs the current fingerprint
+			cluster, err := r.gke.Projects.Locations.Clusters.Get(r.gkeName(state)).Context(ctx).Do()
+			if err == nil {
+				var op *container.Operation
+				op, err = r.gke.Projects.Locations.Clusters.SetResourceLabels(r.gkeName(state), &container.SetLabelsRequest{
+					ResourceLabels:   shared.MergeTags(r.defaultTags, stringMap(plan.Labels)),
+					LabelFingerprint: cluster.LabelFingerprint,
+				}).Context(ctx).Do()
+				if err == nil {
+					err = r.waitGKE(ctx, op)
+				}
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp set labels", err.Error())
+				return
+			}
+		}
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
+
 func (r *ClusterResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		ID   types.String `tfsdk:"id"`
-		Type types.String `tfsdk:"type"`
-	}
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state clusterResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.eks == nil {
-			return
-		}
 		nodeGroup := state.ID.ValueString() + "-ng"
 		_, _ = r.eks.DeleteNodegroup(ctx, &eks.DeleteNodegroupInput{ClusterName: aws.String(state.ID.ValueString()), NodegroupName: aws.String(nodeGroup)})
 		_, err := r.eks.DeleteCluster(ctx, &eks.DeleteClusterInput{Name: aws.String(state.ID.ValueString())})
@@ -339,39 +770,198 @@ func (r *ClusterResource) Delete(ctx context.Context, req resource.DeleteRequest
 			resp.Diagnostics.AddError("aws delete", err.Error())
 		}
 	case "azure":
-		if r.azureAKS == nil {
-			return
-		}
 		poller, err := r.azureAKS.BeginDelete(ctx, "abstract-rg", state.ID.ValueString(), nil)
 		if err == nil {
-			_, err = poller.PollUntilDone(ctx, nil)
+			_, err = shared.PollAzure(ctx, poller)
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure delete", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure delete", err)
 		}
 	case "gcp":
-		if r.gke == nil {
-			return
-		}
-		region := r.gcpRegion
-		if region == "" {
-			region = "us-central1"
+		op, err := r.gke.Projects.Locations.Clusters.Delete(r.gkeName(state)).Context(ctx).Do()
+		if err == nil {
+			err = r.waitGKE(ctx, op)
 		}
-		op, err := r.gke.Projects.Locations.Clusters.Delete(fmt.Sprintf("projects/%s/locations/%s/clusters/%s", r.gcpProj, region, state.ID.ValueString())).Context(ctx).Do()
 		if err != nil {
 			resp.Diagnostics.AddError("gcp delete", err.Error())
-			return
 		}
-		for {
-			oper, err := r.gke.Projects.Locations.Operations.Get(op.Name).Context(ctx).Do()
-			if err != nil {
-				resp.Diagnostics.AddError("gcp delete", err.Error())
-				return
-			}
-			if oper.Status == "DONE" {
-				break
-			}
-			time.Sleep(5 * time.Second)
+	}
+}
+
+// azureClusterSubnet returns the node pool subnet: the first of subnet_ids,
+// or the "default" subnet abstract_network creates in network_id. Nil leaves
+// AKS to create its own virtual network.
+func azureClusterSubnet(m clusterResourceModel) *string {
+	if len(m.SubnetIDs) > 0 {
+		return &m.SubnetIDs[0]
+	}
+	if vnet := m.NetworkID.ValueString(); vnet != "" {
+		return to.Ptr(strings.TrimSuffix(vnet, "/") + "/subnets/default")
+	}
+	return nil
+}
+
+// azureAgentPools returns the cluster's node pools. AKS only allows spot
+// nodes in user pools, so a spot cluster keeps a single regular node in the
+// system pool and puts its nodes in a "spot" pool.
+func azureAgentPools(m clusterResourceModel, count int32, size string) []*armcontainerservice.ManagedClusterAgentPoolProfile {
+	subnet := azureClusterSubnet(m)
+	system := &armcontainerservice.ManagedClusterAgentPoolProfile{
+		Name:         to.Ptr("nodepool1"),
+		Mode:         to.Ptr(armcontainerservice.AgentPoolModeSystem),
+		Count:        &count,
+		VMSize:       &size,
+		VnetSubnetID: subnet,
+	}
+	if !m.Spot.ValueBool() {
+		setAzureScaling(system, m)
+		return []*armcontainerservice.ManagedClusterAgentPoolProfile{system}
+	}
+	system.Count = to.Ptr[int32](1)
+	spot := &armcontainerservice.ManagedClusterAgentPoolProfile{
+		Name:                   to.Ptr("spot"),
+		Mode:                   to.Ptr(armcontainerservice.AgentPoolModeUser),
+		Count:                  &count,
+		VMSize:                 &size,
+		VnetSubnetID:           subnet,
+		ScaleSetPriority:       to.Ptr(armcontainerservice.ScaleSetPrioritySpot),
+		ScaleSetEvictionPolicy: to.Ptr(armcontainerservice.ScaleSetEvictionPolicyDelete),
+		// pay up to the on-demand price
+		SpotMaxPrice: to.Ptr[float32](-1),
+	}
+	setAzureScaling(spot, m)
+	return []*armcontainerservice.ManagedClusterAgentPoolProfile{system, spot}
+}
+
+// setAzureScaling turns the cluster autoscaler on pool on or off to match
+// m. Without autoscaling the pool is resized to node_count.
+func setAzureScaling(pool *armcontainerservice.ManagedClusterAgentPoolProfile, m clusterResourceModel) {
+	lo, hi, ok := m.autoscaling()
+	pool.EnableAutoScaling = to.Ptr(ok)
+	if !ok {
+		pool.MinCount, pool.MaxCount = nil, nil
+		pool.Count = to.Ptr(int32(m.nodeCount()))
+		return
+	}
+	pool.MinCount, pool.MaxCount = to.Ptr(int32(lo)), to.Ptr(int32(hi))
+}
+
+// eksScaling returns the node group size: fixed at desired, or between
+// min_nodes and max_nodes.
+func eksScaling(m clusterResourceModel, desired int32) *ekstypes.NodegroupScalingConfig {
+	lo, hi := desired, desired
+	if a, b, ok := m.autoscaling(); ok {
+		lo, hi = int32(a), int32(b)
+		desired = max(lo, min(desired, hi))
+	}
+	return &ekstypes.NodegroupScalingConfig{DesiredSize: aws.Int32(desired), MinSize: aws.Int32(lo), MaxSize: aws.Int32(hi)}
+}
+
+// gkeAutoscaling returns the default pool's autoscaling settings; GKE
+// counts nodes per zone, like node_count.
+func gkeAutoscaling(m clusterResourceModel) *container.NodePoolAutoscaling {
+	lo, hi, ok := m.autoscaling()
+	if !ok {
+		return &container.NodePoolAutoscaling{Enabled: false}
+	}
+	return &container.NodePoolAutoscaling{Enabled: true, MinNodeCount: lo, MaxNodeCount: hi, ForceSendFields: []string{"MinNodeCount"}}
+}
+
+// gkeName is the full resource name of the cluster in m, in the region it
+// was created in.
+func (r *ClusterResource) gkeName(m clusterResourceModel) string {
+	region := m.Region.ValueString()
+	if region == "" {
+		region = r.gcpRegion
+	}
+	if region == "" {
+		region = "us-central1"
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/clusters/%s", r.gcpProj, region, m.ID.ValueString())
+}
+
+// waitGKE polls op until it is done and returns its error, if any.
+func (r *ClusterResource) waitGKE(ctx context.Context, op *container.Operation) error {
+	for op.Status != "DONE" {
+		if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+			return err
+		}
+		var err error
+		op, err = r.gke.Projects.Locations.Operations.Get(fmt.Sprintf("projects/%s/locations/%s/operations/%s", r.gcpProj, op.Location, op.Name)).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+	}
+	if op.Error != nil {
+		return fmt.Errorf("%s", op.Error.Message)
+	}
+	return nil
+}
+
+// waitEKSUpdate polls an EKS cluster or node group update until it finishes.
+func (r *ClusterResource) waitEKSUpdate(ctx context.Context, cluster, nodeGroup string, up *ekstypes.Update) error {
+	in := &eks.DescribeUpdateInput{Name: aws.String(cluster), UpdateId: up.Id}
+	if nodeGroup != "" {
+		in.NodegroupName = aws.String(nodeGroup)
+	}
+	for up.Status == ekstypes.UpdateStatusInProgress {
+		if err := shared.Sleep(ctx, 15*time.Second); err != nil {
+			return err
 		}
+		out, err := r.eks.DescribeUpdate(ctx, in)
+		if err != nil {
+			return err
+		}
+		up = out.Update
+	}
+	if up.Status != ekstypes.UpdateStatusSuccessful {
+		msg := string(up.Status)
+		for _, e := range up.Errors {
+			msg += ": " + aws.ToString(e.ErrorMessage)
+		}
+		return fmt.Errorf("update %s %s", aws.ToString(up.Id), msg)
+	}
+	return nil
+}
+
+// setVersion records the running version unless it is already described by
+// the configured one ("1.29" for "1.29.4-gke.100").
+func (m *clusterResourceModel) setVersion(actual string) {
+	if actual != "" && !sameVersion(m.KubernetesVersion.ValueString(), actual) {
+		m.KubernetesVersion = types.StringValue(actual)
+	}
+}
+
+// k8sMinor parses the major and minor numbers from a version such as
+// "1.29", "1.29.4" or "v1.29.4-gke.100".
+func k8sMinor(v string) (major, minor int, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// k8sUpgradable reports whether a cluster running from can move to to in
+// place: the same minor version or the next one. An unknown current version
+// (state from before kubernetes_version existed) or an unset target is
+// never a replacement.
+func k8sUpgradable(from, to string) bool {
+	if from == "" || to == "" {
+		return true
+	}
+	fMajor, fMinor, ok1 := k8sMinor(from)
+	tMajor, tMinor, ok2 := k8sMinor(to)
+	if !ok1 || !ok2 {
+		return from == to
 	}
+	return fMajor == tMajor && (tMinor == fMinor || tMinor == fMinor+1)
 }
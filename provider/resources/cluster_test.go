@@ -0,0 +1,192 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestK8sUpgradable(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		want     bool
+	}{
+		{"1.28", "1.29", true},
+		{"1.29.4", "1.29", true},
+		{"1.29.4-gke.100", "1.30", true},
+		{"1.28", "1.30", false},
+		{"1.29", "1.28", false},
+		{"", "1.30", true},
+		{"1.29", "", true},
+		{"latest", "latest", true},
+		{"latest", "1.29", false},
+	} {
+		if got := k8sUpgradable(tc.from, tc.to); got != tc.want {
+			t.Errorf("k8sUpgradable(%q, %q) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestClusterSetVersion(t *testing.T) {
+	m := clusterResourceModel{KubernetesVersion: types.StringValue("1.29")}
+	m.setVersion("1.29.4-gke.100")
+	if got := m.KubernetesVersion.ValueString(); got != "1.29" {
+		t.Errorf("matching version replaced the configured one: %q", got)
+	}
+	m.setVersion("1.30.1")
+	if got := m.KubernetesVersion.ValueString(); got != "1.30.1" {
+		t.Errorf("version = %q, want 1.30.1", got)
+	}
+	m = clusterResourceModel{KubernetesVersion: types.StringNull()}
+	m.setVersion("1.29")
+	if got := m.KubernetesVersion.ValueString(); got != "1.29" {
+		t.Errorf("default version not recorded: %q", got)
+	}
+}
+
+func TestClusterConfig(t *testing.T) {
+	r := &ClusterResource{}
+	s := testSchema(t, r)
+	cases := []struct {
+		name string
+		vals map[string]tftypes.Value
+		errs bool
+	}{
+		{"aws default network", map[string]tftypes.Value{"type": str("aws")}, false},
+		{"aws subnets", map[string]tftypes.Value{"type": str("aws"), "subnet_ids": strList("subnet-a", "subnet-b")}, false},
+		{"aws one subnet", map[string]tftypes.Value{"type": str("aws"), "subnet_ids": strList("subnet-a")}, true},
+		{"azure subnet", map[string]tftypes.Value{"type": str("azure"), "subnet_ids": strList("/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Network/virtualNetworks/net/subnets/default")}, false},
+		{"gcp two subnets", map[string]tftypes.Value{"type": str("gcp"), "subnet_ids": strList("a", "b")}, true},
+		{"azure spot", map[string]tftypes.Value{"type": str("azure"), "spot": boolean(true), "node_size": str("Standard_D2s_v3")}, false},
+		{"azure spot burstable", map[string]tftypes.Value{"type": str("azure"), "spot": boolean(true), "node_size": str("Standard_B2s")}, true},
+		{"aws spot burstable", map[string]tftypes.Value{"type": str("aws"), "spot": boolean(true), "node_size": str("t3.medium")}, false},
+		{"autoscaling", map[string]tftypes.Value{"type": str("aws"), "min_nodes": number(1), "max_nodes": number(5), "node_count": number(2)}, false},
+		{"min without max", map[string]tftypes.Value{"type": str("aws"), "min_nodes": number(1)}, true},
+		{"min above max", map[string]tftypes.Value{"type": str("gcp"), "min_nodes": number(4), "max_nodes": number(2)}, true},
+		{"count above max", map[string]tftypes.Value{"type": str("gcp"), "min_nodes": number(1), "max_nodes": number(2), "node_count": number(3)}, true},
+		{"aws scale to zero", map[string]tftypes.Value{"type": str("aws"), "min_nodes": number(0), "max_nodes": number(3)}, false},
+		{"azure system pool to zero", map[string]tftypes.Value{"type": str("azure"), "min_nodes": number(0), "max_nodes": number(3)}, true},
+		{"azure spot pool to zero", map[string]tftypes.Value{"type": str("azure"), "spot": boolean(true), "min_nodes": number(0), "max_nodes": number(3)}, false},
+		{"gcp labels", map[string]tftypes.Value{"type": str("gcp"), "labels": strMap(map[string]string{"team": "web", "cost-center": ""})}, false},
+		{"gcp uppercase label", map[string]tftypes.Value{"type": str("gcp"), "labels": strMap(map[string]string{"Team": "web"})}, true},
+		{"aws labels ignored", map[string]tftypes.Value{"type": str("aws"), "labels": strMap(map[string]string{"Team": "web"})}, false},
+		{"unknown subnets", map[string]tftypes.Value{"type": str("aws"), "subnet_ids": tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, tftypes.UnknownValue)}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestAzureClusterSubnet(t *testing.T) {
+	const vnet = "/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Network/virtualNetworks/net"
+	if got := azureClusterSubnet(clusterResourceModel{}); got != nil {
+		t.Errorf("subnet without a network = %q", *got)
+	}
+	if got := azureClusterSubnet(clusterResourceModel{NetworkID: types.StringValue(vnet)}); got == nil || *got != vnet+"/subnets/default" {
+		t.Errorf("network subnet = %v", got)
+	}
+	m := clusterResourceModel{NetworkID: types.StringValue(vnet), SubnetIDs: []string{vnet + "/subnets/aks"}}
+	if got := azureClusterSubnet(m); got == nil || *got != vnet+"/subnets/aks" {
+		t.Errorf("explicit subnet = %v", got)
+	}
+}
+
+func TestAzureAgentPools(t *testing.T) {
+	pools := azureAgentPools(clusterResourceModel{Spot: types.BoolValue(false)}, 3, "Standard_DS2_v2")
+	if len(pools) != 1 || *pools[0].Count != 3 || pools[0].ScaleSetPriority != nil {
+		t.Fatalf("regular pools = %+v", pools)
+	}
+	pools = azureAgentPools(clusterResourceModel{Spot: types.BoolValue(true)}, 3, "Standard_DS2_v2")
+	if len(pools) != 2 {
+		t.Fatalf("spot cluster has %d pools", len(pools))
+	}
+	system, spot := pools[0], pools[1]
+	if *system.Mode != armcontainerservice.AgentPoolModeSystem || *system.Count != 1 || system.ScaleSetPriority != nil {
+		t.Errorf("system pool = %+v", system)
+	}
+	if *spot.Mode != armcontainerservice.AgentPoolModeUser || *spot.Count != 3 || *spot.ScaleSetPriority != armcontainerservice.ScaleSetPrioritySpot {
+		t.Errorf("spot pool = %+v", spot)
+	}
+}
+
+func TestClusterScaling(t *testing.T) {
+	fixed := clusterResourceModel{NodeCount: types.Int64Value(4), MinNodes: types.Int64Null(), MaxNodes: types.Int64Null()}
+	if sc := eksScaling(fixed, int32(fixed.nodeCount())); *sc.MinSize != 4 || *sc.MaxSize != 4 || *sc.DesiredSize != 4 {
+		t.Errorf("fixed scaling = %d/%d/%d", *sc.MinSize, *sc.DesiredSize, *sc.MaxSize)
+	}
+	auto := clusterResourceModel{NodeCount: types.Int64Null(), MinNodes: types.Int64Value(5), MaxNodes: types.Int64Value(10)}
+	if n := auto.nodeCount(); n != 5 {
+		t.Errorf("default node count = %d, want min_nodes", n)
+	}
+	// a running size outside the new bounds is clamped
+	if sc := eksScaling(auto, 12); *sc.MinSize != 5 || *sc.MaxSize != 10 || *sc.DesiredSize != 10 {
+		t.Errorf("autoscaling = %d/%d/%d", *sc.MinSize, *sc.DesiredSize, *sc.MaxSize)
+	}
+	if a := gkeAutoscaling(auto); !a.Enabled || a.MinNodeCount != 5 || a.MaxNodeCount != 10 {
+		t.Errorf("gke autoscaling = %+v", a)
+	}
+
+	pool := azureAgentPools(auto, int32(auto.nodeCount()), "Standard_DS2_v2")[0]
+	if !*pool.EnableAutoScaling || *pool.MinCount != 5 || *pool.MaxCount != 10 {
+		t.Errorf("azure pool = %+v", pool)
+	}
+	setAzureScaling(pool, fixed)
+	if *pool.EnableAutoScaling || pool.MinCount != nil || *pool.Count != 4 {
+		t.Errorf("azure pool after disabling = %+v", pool)
+	}
+}
+
+func TestClusterSetDefaults(t *testing.T) {
+	m := clusterResourceModel{Region: types.StringUnknown(), NodeCount: types.Int64Unknown(), NodeSize: types.StringValue("m5.large")}
+	m.setDefaults("us-east-1", "t3.medium", 3)
+	if m.Region.ValueString() != "us-east-1" || m.NodeCount.ValueInt64() != 3 || m.NodeSize.ValueString() != "m5.large" {
+		t.Errorf("region, node_count, node_size = %v, %v, %v; want the defaults and the configured size", m.Region, m.NodeCount, m.NodeSize)
+	}
+	// the resolved Azure location does not replace the configured spelling
+	m = clusterResourceModel{Region: types.StringValue("East US"), NodeCount: types.Int64Value(2), NodeSize: types.StringUnknown()}
+	m.setDefaults("eastus", "Standard_DS2_v2", 2)
+	if m.Region.ValueString() != "East US" {
+		t.Errorf("region = %v, want the configured East US", m.Region)
+	}
+}
+
+func TestClusterReplaceIfConfigured(t *testing.T) {
+	r := &ClusterResource{}
+	vals := map[string]tftypes.Value{"type": str("aws"), "name": str("prod")}
+	for _, tc := range []struct {
+		name                string
+		modifier            planmodifier.String
+		config, plan, state types.String
+		replace             bool
+	}{
+		{"configured change", replaceIfConfigured, types.StringValue("m5.large"), types.StringValue("m5.large"), types.StringValue("t3.medium"), true},
+		{"left out", replaceIfConfigured, types.StringNull(), types.StringValue("t3.medium"), types.StringValue("t3.medium"), false},
+		{"new region", replaceOnNewRegion, types.StringValue("westus"), types.StringValue("westus"), types.StringValue("eastus"), true},
+		{"region spelling", replaceOnNewRegion, types.StringValue("East US"), types.StringValue("East US"), types.StringValue("eastus"), false},
+		{"region left out without state", replaceOnNewRegion, types.StringNull(), types.StringUnknown(), types.StringNull(), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := planmodifier.StringRequest{
+				State: testState(t, r, vals), Plan: testPlan(t, r, vals),
+				ConfigValue: tc.config, PlanValue: tc.plan, StateValue: tc.state,
+			}
+			resp := &planmodifier.StringResponse{PlanValue: tc.plan}
+			tc.modifier.PlanModifyString(context.Background(), req, resp)
+			if resp.RequiresReplace != tc.replace {
+				t.Errorf("requires replace = %v, want %v", resp.RequiresReplace, tc.replace)
+			}
+		})
+	}
+}
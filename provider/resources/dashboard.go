@@ -0,0 +1,428 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	monitoring "google.golang.org/api/monitoring/v1"
+)
+
+// azureDashboardAPIVersion is the Microsoft.Portal API version used for
+// dashboards, which have no dedicated client in the Azure SDK.
+const azureDashboardAPIVersion = "2020-09-01-preview"
+
+// DashboardResource manages a monitoring dashboard defined by a JSON body.
+type DashboardResource struct {
+	cw *cloudwatch.Client
+
+	azureRG    *armresources.ResourceGroupsClient
+	azureRes   *armresources.Client
+	azureSubID string
+	azureLoc   string
+
+	monitoring *monitoring.Service
+	gcpProj    string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	ids *shared.CloudIDs
+}
+
+type dashboardResourceModel struct {
+	ID      types.String `tfsdk:"id"`
+	CloudID types.String `tfsdk:"cloud_id"`
+	Type    types.String `tfsdk:"type"`
+	Name    types.String `tfsdk:"name"`
+	Region  types.String `tfsdk:"region"`
+	Body    types.String `tfsdk:"body"`
+}
+
+func NewDashboardResource() resource.Resource { return &DashboardResource{} }
+
+func (r *DashboardResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.ids = cfg.CloudIDs
+	r.cw = cfg.AWSCloudWatch
+	r.azureRG = cfg.AzureRGClient
+	r.azureRes = cfg.AzureResourcesClient
+	r.azureSubID = cfg.AzureSubID
+	r.azureLoc = cfg.AzureLocation
+	r.monitoring = cfg.GCPMonitoring
+	r.gcpProj = cfg.GCPProject
+}
+
+func (r *DashboardResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_dashboard"
+}
+
+func (r *DashboardResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"cloud_id": cloudIDAttribute(),
+			"type": schema.StringAttribute{
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"name": schema.StringAttribute{
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			// Azure location or AWS region; GCP uses the provider project.
+			"region": schema.StringAttribute{
+				Optional:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			// CloudWatch dashboard body, Azure dashboard properties or a
+			// Cloud Monitoring Dashboard object, as JSON.
+			"body": schema.StringAttribute{Required: true},
+		},
+	}
+}
+
+func (r *DashboardResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg dashboardResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() || cfg.Body.IsUnknown() || cfg.Body.IsNull() {
+		return
+	}
+	var body map[string]any
+	if err := json.Unmarshal([]byte(cfg.Body.ValueString()), &body); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("body"), "invalid body", "body must be a JSON object: "+err.Error())
+	}
+}
+
+// azureDashboardID returns the ARM resource ID of the dashboard called name.
+func (r *DashboardResource) azureDashboardID(name string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/abstract-rg/providers/Microsoft.Portal/dashboards/%s", r.azureSubID, name)
+}
+
+// gcpDashboard builds the Cloud Monitoring dashboard for plan.
+func (r *DashboardResource) gcpDashboard(plan *dashboardResourceModel) (*monitoring.Dashboard, error) {
+	d := &monitoring.Dashboard{}
+	if err := json.Unmarshal([]byte(plan.Body.ValueString()), d); err != nil {
+		return nil, err
+	}
+	d.Name = fmt.Sprintf("projects/%s/dashboards/%s", r.gcpProj, plan.Name.ValueString())
+	if d.DisplayName == "" {
+		d.DisplayName = plan.Name.ValueString()
+	}
+	return d, nil
+}
+
+// put creates or overwrites the dashboard and returns its cloud identifier.
+func (r *DashboardResource) put(ctx context.Context, plan *dashboardResourceModel, exists bool) (string, error) {
+	name := plan.Name.ValueString()
+	switch plan.Type.ValueString() {
+	case "aws":
+		_, err := r.cw.PutDashboard(ctx, &cloudwatch.PutDashboardInput{
+			DashboardName: aws.String(name),
+			DashboardBody: aws.String(plan.Body.ValueString()),
+		})
+		if err != nil {
+			return "", err
+		}
+		return name, nil
+	case "azure":
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		if !exists {
+			err := shared.EnsureResourceGroup(ctx, r.azureRG, "abstract-rg", loc)
+			if err != nil {
+				return "", err
+			}
+		}
+		var props any
+		if err := json.Unmarshal([]byte(plan.Body.ValueString()), &props); err != nil {
+			return "", err
+		}
+		id := r.azureDashboardID(name)
+		poller, err := r.azureRes.BeginCreateOrUpdateByID(ctx, id, azureDashboardAPIVersion, armresources.GenericResource{
+			Location:   &loc,
+			Properties: props,
+			// the portal shows this tag as the dashboard title
+			Tags: map[string]*string{"hidden-title": to.Ptr(name)},
+		}, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			return "", err
+		}
+		return id, nil
+	case "gcp":
+		d, err := r.gcpDashboard(plan)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			_, err = r.monitoring.Projects.Dashboards.Patch(d.Name, d).Context(ctx).Do()
+		} else {
+			_, err = r.monitoring.Projects.Dashboards.Create("projects/"+r.gcpProj, d).Context(ctx).Do()
+		}
+		if err != nil {
+			return "", err
+		}
+		return d.Name, nil
+	}
+	return "", fmt.Errorf("unsupported cloud %s", plan.Type.ValueString())
+}
+
+// remoteBody fetches the dashboard definition as the cloud currently stores it.
+func (r *DashboardResource) remoteBody(ctx context.Context, state *dashboardResourceModel) ([]byte, error) {
+	switch state.Type.ValueString() {
+	case "aws":
+		out, err := r.cw.GetDashboard(ctx, &cloudwatch.GetDashboardInput{DashboardName: aws.String(state.Name.ValueString())})
+		if err != nil {
+			return nil, err
+		}
+		return []byte(aws.ToString(out.DashboardBody)), nil
+	case "azure":
+		out, err := r.azureRes.GetByID(ctx, state.ID.ValueString(), azureDashboardAPIVersion, nil)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(out.Properties)
+	case "gcp":
+		d, err := r.monitoring.Projects.Dashboards.Get(state.ID.ValueString()).Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+		return d.MarshalJSON()
+	}
+	return nil, fmt.Errorf("unsupported cloud %s", state.Type.ValueString())
+}
+
+// jsonContains reports whether every value in want is present in got. Objects
+// may carry extra keys in got, since clouds fill in defaults and metadata such
+// as etags; arrays must match element by element. Scalars compare by their
+// string form because the Google APIs encode 64-bit integers as strings.
+func jsonContains(got, want any) bool {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range w {
+			if !jsonContains(g[k], v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !jsonContains(g[i], w[i]) {
+				return false
+			}
+		}
+		return true
+	case nil:
+		return got == nil
+	}
+	return fmt.Sprint(got) == fmt.Sprint(want)
+}
+
+// dashboardDrift compares the remote definition with the configured body and
+// returns the compacted remote body when they differ.
+func dashboardDrift(remote []byte, body string) (string, bool, error) {
+	var got, want any
+	if err := json.Unmarshal(remote, &got); err != nil {
+		return "", false, err
+	}
+	if err := json.Unmarshal([]byte(body), &want); err != nil {
+		return "", false, err
+	}
+	if jsonContains(got, want) {
+		return "", false, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, remote); err != nil {
+		return "", false, err
+	}
+	return buf.String(), true, nil
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *DashboardResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.cw = c.CloudWatch
+	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *DashboardResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.cw != nil
+	case "azure":
+		return r.azureRG != nil && r.azureRes != nil
+	case "gcp":
+		return r.monitoring != nil
+	}
+	return false
+}
+
+func (r *DashboardResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan dashboardResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	id, err := r.put(ctx, &plan, false)
+	if err != nil {
+		resp.Diagnostics.AddError(plan.Type.ValueString()+" create", err.Error())
+		return
+	}
+	plan.ID = types.StringValue(id)
+	cloudID, err := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, cloudID, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// cloudID is the CloudWatch dashboard's ARN; the Azure and GCP IDs already are
+// the dashboard's resource ID and full name.
+func (r *DashboardResource) cloudID(ctx context.Context, m *dashboardResourceModel) (string, error) {
+	if m.Type.ValueString() == "aws" {
+		return r.ids.ARN(ctx, "cloudwatch", false, "dashboard/"+m.ID.ValueString())
+	}
+	return m.ID.ValueString(), nil
+}
+
+// Read replaces body with the remote definition when it was edited outside
+// Terraform, so the next plan restores the configured one.
+func (r *DashboardResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state dashboardResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws", "azure", "gcp":
+	default:
+		return
+	}
+	remote, err := r.remoteBody(ctx, &state)
+	if err != nil {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	body, drifted, err := dashboardDrift(remote, state.Body.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("read body", err.Error())
+		return
+	}
+	if drifted {
+		state.Body = types.StringValue(body)
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+func (r *DashboardResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state dashboardResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	if _, err := r.put(ctx, &plan, true); err != nil {
+		resp.Diagnostics.AddError(plan.Type.ValueString()+" update", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *DashboardResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state dashboardResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err := r.cw.DeleteDashboards(ctx, &cloudwatch.DeleteDashboardsInput{DashboardNames: []string{state.Name.ValueString()}})
+		if err != nil {
+			resp.Diagnostics.AddError("aws delete", err.Error())
+		}
+	case "azure":
+		poller, err := r.azureRes.BeginDeleteByID(ctx, state.ID.ValueString(), azureDashboardAPIVersion, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete", err)
+		}
+	case "gcp":
+		_, err := r.monitoring.Projects.Dashboards.Delete(state.ID.ValueString()).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete", err.Error())
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package resources
+
+import "testing"
+
+func TestDashboardDrift(t *testing.T) {
+	body := `{"widgets": [{"type": "metric", "width": 12, "properties": {"title": "CPU"}}]}`
+	cases := []struct {
+		name    string
+		remote  string
+		drifted bool
+	}{
+		{"identical", `{"widgets":[{"type":"metric","width":12,"properties":{"title":"CPU"}}]}`, false},
+		{"extra remote keys", `{"etag":"x","widgets":[{"type":"metric","width":12,"height":6,"properties":{"title":"CPU"}}]}`, false},
+		{"integer as string", `{"widgets":[{"type":"metric","width":"12","properties":{"title":"CPU"}}]}`, false},
+		{"value edited", `{"widgets":[{"type":"metric","width":12,"properties":{"title":"Memory"}}]}`, true},
+		{"widget added", `{"widgets":[{"type":"metric","width":12,"properties":{"title":"CPU"}},{"type":"text"}]}`, true},
+		{"key removed", `{"widgets":[{"type":"metric","properties":{"title":"CPU"}}]}`, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, drifted, err := dashboardDrift([]byte(tc.remote), body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if drifted != tc.drifted {
+				t.Fatalf("drifted = %v, want %v", drifted, tc.drifted)
+			}
+			if drifted && got != tc.remote {
+				t.Errorf("body = %s, want %s", got, tc.remote)
+			}
+		})
+	}
+}
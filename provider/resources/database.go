@@ -3,7 +3,10 @@ package resources
 import (
 	"context"
 	"fmt"
+	"maps"
+	"net"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,14 +14,27 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mysql/armmysqlflexibleservers"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/postgresql/armpostgresqlflexibleservers"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
        "github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
        sqladmin "google.golang.org/api/sqladmin/v1beta4"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	crm "google.golang.org/api/cloudresourcemanager/v1"
+	compute "google.golang.org/api/compute/v1"
+	servicenetworking "google.golang.org/api/servicenetworking/v1"
 )
 
 type DatabaseResource struct {
@@ -32,8 +48,76 @@ type DatabaseResource struct {
        gcpSQL   *sqladmin.Service
        gcpProj  string
        gcpRegion string
+
+	// clients for private_network_id
+	ec2         *ec2.Client
+	azureVNet   *armnetwork.VirtualNetworksClient
+	azureSub    *armnetwork.SubnetsClient
+	azureRes    *armresources.Client
+	gcpCompute  *compute.Service
+	gcpProjects *crm.Service
+	gcpSNet     *servicenetworking.APIService
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	// defaultTags are the provider's default_tags, merged under GCP labels.
+	defaultTags map[string]string
+
+	ids *shared.CloudIDs
+}
+
+type databaseResourceModel struct {
+	ID               types.String `tfsdk:"id"`
+	CloudID          types.String `tfsdk:"cloud_id"`
+	Name             types.String `tfsdk:"name"`
+	Type             types.String `tfsdk:"type"`
+	Region           types.String `tfsdk:"region"`
+	Engine           types.String `tfsdk:"engine"`
+	Version          types.String `tfsdk:"version"`
+	Size             types.String `tfsdk:"size"`
+	StorageType      types.String `tfsdk:"storage_type"`
+	StorageEncrypted types.Bool   `tfsdk:"storage_encrypted"`
+	KMSKeyID         types.String `tfsdk:"kms_key_id"`
+
+	StorageGB          types.Int64 `tfsdk:"storage_gb"`
+	MultiAZ            types.Bool  `tfsdk:"multi_az"`
+	PubliclyAccessible types.Bool  `tfsdk:"publicly_accessible"`
+
+	SkipFinalSnapshot       types.Bool   `tfsdk:"skip_final_snapshot"`
+	FinalSnapshotIdentifier types.String `tfsdk:"final_snapshot_identifier"`
+	DeletionProtection      types.Bool   `tfsdk:"deletion_protection"`
+
+	Labels types.Map `tfsdk:"labels"`
+
+	PrivateNetworkID  types.String `tfsdk:"private_network_id"`
+	PrivateSubnetCIDR types.String `tfsdk:"private_subnet_cidr"`
+}
+
+// databaseInfo is what a cloud reports about a database instance. Empty
+// strings, zero storage and nil flags mean the value is not reported.
+type databaseInfo struct {
+	engine, version, size      string
+	storageGB                  int64
+	multiAZ, public, protected *bool
 }
 
+// rdsStorageTypes are the accepted storage_type values.
+var rdsStorageTypes = []string{"gp2", "gp3", "io1", "io2", "standard"}
+
+// Provisioned IOPS volumes need more than the default 20 GiB and an explicit IOPS figure.
+const (
+	rdsAllocatedStorage   = 20
+	rdsProvisionedStorage = 100
+	rdsProvisionedIOPS    = 1000
+)
+
+// rdsModifyTimeout bounds the wait for an RDS instance to apply a modification.
+const rdsModifyTimeout = 60 * time.Minute
+
 func NewDatabaseResource() resource.Resource { return &DatabaseResource{} }
 
 func (r *DatabaseResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
@@ -45,6 +129,10 @@ func (r *DatabaseResource) Configure(ctx context.Context, req resource.Configure
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.defaultTags = cfg.DefaultTags
+	r.ids = cfg.CloudIDs
 	r.rds = cfg.AWSRDS
 	r.azureMySQL = cfg.AzureMySQLClient
 	r.azurePG = cfg.AzurePostgresClient
@@ -55,6 +143,13 @@ func (r *DatabaseResource) Configure(ctx context.Context, req resource.Configure
        r.gcpSQL = cfg.GCPCloudSQL
        r.gcpProj = cfg.GCPProject
        r.gcpRegion = cfg.GCPRegion
+	r.ec2 = cfg.AWSEC2
+	r.azureVNet = cfg.AzureVNetClient
+	r.azureSub = cfg.AzureSubnetClient
+	r.azureRes = cfg.AzureResourcesClient
+	r.gcpCompute = cfg.GCPCompute
+	r.gcpProjects = cfg.GCPProjects
+	r.gcpSNet = cfg.GCPServiceNetworking
 }
 
 func (r *DatabaseResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -64,35 +159,269 @@ func (r *DatabaseResource) Metadata(ctx context.Context, req resource.MetadataRe
 func (r *DatabaseResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
-			"id":      schema.StringAttribute{Computed: true},
-			"name":    schema.StringAttribute{Optional: true},
-			"type":    schema.StringAttribute{Required: true},
-			"engine":  schema.StringAttribute{Required: true},
-			"version": schema.StringAttribute{Optional: true},
-			"size":    schema.StringAttribute{Optional: true},
+			"id":      schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"name":    schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"type":    schema.StringAttribute{Required: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"engine":  schema.StringAttribute{Required: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			// Azure location, AWS region or Cloud SQL region.
+			"region": schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"version": schema.StringAttribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.String{
+				stringplanmodifier.UseStateForUnknown(),
+				// flexible servers cannot be upgraded through the update API
+				stringplanmodifier.RequiresReplaceIf(func(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+					var cloud types.String
+					resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("type"), &cloud)...)
+					resp.RequiresReplace = cloud.ValueString() == "azure"
+				}, "Azure databases are replaced to change version.", "Azure databases are replaced to change version."),
+			}},
+			"size":    schema.StringAttribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+
+			// RDS volume type; changed in place with ModifyDBInstance.
+			"storage_type": schema.StringAttribute{Optional: true},
+			// Encryption at rest is fixed at creation on every cloud.
+			"storage_encrypted": schema.BoolAttribute{Optional: true, PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()}},
+			"kms_key_id":        schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+
+			// Allocated storage on AWS and GCP; volumes can grow in place but not shrink.
+			"storage_gb": schema.Int64Attribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.Int64{
+				int64planmodifier.UseStateForUnknown(),
+				int64planmodifier.RequiresReplaceIf(func(ctx context.Context, req planmodifier.Int64Request, resp *int64planmodifier.RequiresReplaceIfFuncResponse) {
+					resp.RequiresReplace = req.PlanValue.ValueInt64() < req.StateValue.ValueInt64()
+				}, "Shrinking storage replaces the database.", "Shrinking storage replaces the database."),
+			}},
+			// Multi-AZ on AWS, zone-redundant HA on Azure, a regional instance on GCP.
+			"multi_az":            schema.BoolAttribute{Optional: true, Computed: true, Default: booldefault.StaticBool(false)},
+			"publicly_accessible": schema.BoolAttribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.Bool{boolplanmodifier.UseStateForUnknown()}},
+
+			// Only used on delete: an RDS final snapshot or a Cloud SQL final backup.
+			"skip_final_snapshot":       schema.BoolAttribute{Optional: true, Computed: true, Default: booldefault.StaticBool(true)},
+			"final_snapshot_identifier": schema.StringAttribute{Optional: true},
+			// While true the database cannot be destroyed; turn it off in an earlier apply first.
+			"deletion_protection": schema.BoolAttribute{Optional: true, Computed: true, Default: booldefault.StaticBool(false)},
+			// GCP only: Cloud SQL user labels, updated in place.
+			"labels": schema.MapAttribute{ElementType: types.StringType, Optional: true},
+			// Network the database is reachable from instead of the internet:
+			// a VPC ID, virtual network ID or GCP network name.
+			"private_network_id": schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			// Azure only: range of the subnet delegated to the server.
+			"private_subnet_cidr": schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+
+			"cloud_id": cloudIDAttribute(),
 		},
 	}
 }
 
-func (r *DatabaseResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name    types.String `tfsdk:"name"`
-		Type    types.String `tfsdk:"type"`
-		Engine  types.String `tfsdk:"engine"`
-		Version types.String `tfsdk:"version"`
-		Size    types.String `tfsdk:"size"`
+func (r *DatabaseResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg databaseResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if st := cfg.StorageType.ValueString(); st != "" && !slices.Contains(rdsStorageTypes, st) {
+		resp.Diagnostics.AddAttributeError(path.Root("storage_type"), "invalid storage type",
+			fmt.Sprintf("%q is not one of %s", st, strings.Join(rdsStorageTypes, ", ")))
+	}
+	switch cfg.Type.ValueString() {
+	case "aws":
+		if cfg.KMSKeyID.ValueString() != "" && !cfg.StorageEncrypted.ValueBool() {
+			resp.Diagnostics.AddAttributeError(path.Root("kms_key_id"), "encryption disabled", "kms_key_id requires storage_encrypted = true")
+		}
+	case "azure", "gcp":
+		if !cfg.StorageEncrypted.IsNull() && !cfg.StorageEncrypted.ValueBool() {
+			resp.Diagnostics.AddAttributeError(path.Root("storage_encrypted"), "encryption always on",
+				cfg.Type.ValueString()+" databases are always encrypted at rest")
+		}
+		if !cfg.StorageType.IsNull() {
+			resp.Diagnostics.AddAttributeWarning(path.Root("storage_type"), "storage type ignored", "storage_type only applies to aws")
+		}
+	}
+	if cfg.Type.ValueString() == "azure" && !cfg.KMSKeyID.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("kms_key_id"), "unsupported", "customer-managed keys on Azure flexible servers are not supported")
+	}
+	if cfg.Type.ValueString() == "azure" && !cfg.StorageGB.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("storage_gb"), "unsupported", "storage_gb only applies to aws and gcp")
+	}
+	if !cfg.SkipFinalSnapshot.IsNull() && !cfg.SkipFinalSnapshot.ValueBool() {
+		switch cfg.Type.ValueString() {
+		case "aws":
+			if cfg.FinalSnapshotIdentifier.IsNull() {
+				resp.Diagnostics.AddAttributeError(path.Root("final_snapshot_identifier"), "missing snapshot identifier",
+					"final_snapshot_identifier is required when skip_final_snapshot = false")
+			}
+		case "azure":
+			resp.Diagnostics.AddAttributeWarning(path.Root("skip_final_snapshot"), "final snapshot unsupported",
+				"Azure flexible servers cannot take a backup on delete; only the automatic backups kept for the retention period remain")
+		}
+	}
+	if cfg.Type.ValueString() == "azure" && cfg.DeletionProtection.ValueBool() {
+		resp.Diagnostics.AddAttributeWarning(path.Root("deletion_protection"), "provider-side protection",
+			"Azure flexible servers have no deletion protection setting; only this provider refuses to delete the server")
+	}
+	checkGCPLabels(&resp.Diagnostics, cfg.Type, "labels", cfg.Labels)
+	r.validatePrivateNetwork(&cfg, &resp.Diagnostics)
+	if t := cfg.Type.ValueString(); (t == "azure" || t == "gcp") && cfg.PrivateNetworkID.IsNull() && !cfg.PubliclyAccessible.IsNull() && !cfg.PubliclyAccessible.ValueBool() {
+		// both clouds need private networking to drop the public endpoint
+		resp.Diagnostics.AddAttributeError(path.Root("publicly_accessible"), "unsupported",
+			t+" databases are created with a public endpoint; publicly_accessible = false needs private_network_id")
+	}
+}
+
+// validatePrivateNetwork checks private_network_id against the cloud and the
+// settings it rules out.
+func (r *DatabaseResource) validatePrivateNetwork(cfg *databaseResourceModel, diags *diag.Diagnostics) {
+	cloud := cfg.Type.ValueString()
+	if cfg.PrivateNetworkID.IsNull() {
+		if !cfg.PrivateSubnetCIDR.IsNull() {
+			diags.AddAttributeError(path.Root("private_subnet_cidr"), "missing private_network_id", "private_subnet_cidr requires private_network_id")
+		}
+		return
+	}
+	if cfg.PubliclyAccessible.ValueBool() {
+		diags.AddAttributeError(path.Root("publicly_accessible"), "conflicting settings", "a database with private_network_id cannot be publicly accessible")
+	}
+	if id := cfg.PrivateNetworkID; !id.IsUnknown() && !cfg.Type.IsUnknown() {
+		if msg := checkNetworkID(cloud, id.ValueString()); msg != "" {
+			diags.AddAttributeError(path.Root("private_network_id"), "network of another cloud", fmt.Sprintf("%q is not a %s network: %s", id.ValueString(), cloud, msg))
+		}
+	}
+	switch cidr := cfg.PrivateSubnetCIDR; {
+	case cloud == "azure" && cidr.IsNull():
+		diags.AddAttributeError(path.Root("private_subnet_cidr"), "missing private_subnet_cidr",
+			"Azure databases on a private network need a subnet of their own; set private_subnet_cidr to a free range")
+	case cloud == "azure" && !cidr.IsUnknown():
+		if _, _, err := net.ParseCIDR(cidr.ValueString()); err != nil {
+			diags.AddAttributeError(path.Root("private_subnet_cidr"), "invalid private_subnet_cidr", err.Error())
+		}
+	case cloud != "azure" && !cidr.IsNull():
+		diags.AddAttributeWarning(path.Root("private_subnet_cidr"), "private_subnet_cidr ignored", "private_subnet_cidr only applies to azure")
+	}
+}
+
+// databasePasswordEnv names the environment variable holding the admin
+// password for each cloud that needs one. Cloud SQL instances are created
+// without one.
+var databasePasswordEnv = map[string]string{"aws": "RDS_PASSWORD", "azure": "AZURE_DB_PASSWORD"}
+
+// ModifyPlan checks that the admin password is available before a database is
+// created, so a missing password fails the plan rather than the apply.
+func (r *DatabaseResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || !req.State.Raw.IsNull() {
+		return
+	}
+	var cloud types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("type"), &cloud)...)
+	if env := databasePasswordEnv[cloud.ValueString()]; env != "" && os.Getenv(env) == "" {
+		resp.Diagnostics.AddAttributeError(path.Root("type"), "missing password",
+			fmt.Sprintf("%s must be set to create an %s database", env, cloud.ValueString()))
+	}
+}
+
+// checkRDSOptions verifies the engine and instance class support the requested
+// storage type and encryption.
+func (r *DatabaseResource) checkRDSOptions(ctx context.Context, plan *databaseResourceModel, class string) error {
+	storageType := plan.StorageType.ValueString()
+	encrypted := plan.StorageEncrypted.ValueBool()
+	if storageType == "" && !encrypted {
+		return nil
+	}
+	input := &rds.DescribeOrderableDBInstanceOptionsInput{
+		Engine:          aws.String(plan.Engine.ValueString()),
+		DBInstanceClass: aws.String(class),
+	}
+	if plan.Version.ValueString() != "" {
+		input.EngineVersion = aws.String(plan.Version.ValueString())
+	}
+	var typeOK, encryptionOK bool
+	pages := rds.NewDescribeOrderableDBInstanceOptionsPaginator(r.rds, input)
+	for pages.HasMorePages() && !(typeOK && encryptionOK) {
+		out, err := pages.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, opt := range out.OrderableDBInstanceOptions {
+			if storageType == "" || aws.ToString(opt.StorageType) == storageType {
+				typeOK = true
+				encryptionOK = encryptionOK || aws.ToBool(opt.SupportsStorageEncryption)
+			}
+		}
+	}
+	if !typeOK {
+		return fmt.Errorf("%s on %s does not support storage type %s", plan.Engine.ValueString(), class, storageType)
+	}
+	if encrypted && !encryptionOK {
+		return fmt.Errorf("%s on %s does not support storage encryption", plan.Engine.ValueString(), class)
+	}
+	return nil
+}
+
+// applyRDSStorageType sets the storage type and the volume size and IOPS it requires.
+func applyRDSStorageType(input *rds.CreateDBInstanceInput, storageType string) {
+	if storageType == "" {
+		return
+	}
+	input.StorageType = aws.String(storageType)
+	if storageType == "io1" || storageType == "io2" {
+		input.AllocatedStorage = aws.Int32(max(aws.ToInt32(input.AllocatedStorage), rdsProvisionedStorage))
+		input.Iops = aws.Int32(rdsProvisionedIOPS)
+	}
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *DatabaseResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.rds = c.RDS
+		r.ec2 = c.EC2
+	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// privateConfigured reports whether the clients private_network_id needs on
+// cloud were set up by the provider.
+func (r *DatabaseResource) privateConfigured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.ec2 != nil
+	case "azure":
+		return r.azureVNet != nil && r.azureSub != nil && r.azureRes != nil
+	case "gcp":
+		return r.gcpCompute != nil && r.gcpProjects != nil && r.gcpSNet != nil
 	}
+	return false
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *DatabaseResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.rds != nil
+	case "azure":
+		return r.azureRG != nil && r.azureMySQL != nil && r.azurePG != nil
+	case "gcp":
+		return r.gcpSQL != nil
+	}
+	return false
+}
+
+func (r *DatabaseResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan databaseResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(plan.Type, plan.Region)
+	private := plan.PrivateNetworkID.ValueString()
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t) && (private == "" || r.privateConfigured(t))) {
+		return
+	}
+	plan.CloudID = types.StringNull()
        switch plan.Type.ValueString() {
        case "aws":
-		if r.rds == nil {
-			resp.Diagnostics.AddError("missing AWS client", "")
-			return
-		}
 		id := plan.Name.ValueString()
 		if id == "" {
 			id = fmt.Sprintf("db-%d", time.Now().Unix())
@@ -101,46 +430,60 @@ func (r *DatabaseResource) Create(ctx context.Context, req resource.CreateReques
 		if class == "" {
 			class = "db.t3.micro"
 		}
-		password := os.Getenv("RDS_PASSWORD")
+		password := os.Getenv(databasePasswordEnv["aws"])
 		if password == "" {
 			resp.Diagnostics.AddError("missing password", "RDS_PASSWORD must be set")
 			return
 		}
+		if err := r.checkRDSOptions(ctx, &plan, class); err != nil {
+			resp.Diagnostics.AddError("aws storage", err.Error())
+			return
+		}
 		input := &rds.CreateDBInstanceInput{
 			DBInstanceIdentifier: aws.String(id),
 			Engine:               aws.String(plan.Engine.ValueString()),
 			DBInstanceClass:      aws.String(class),
 			MasterUsername:       aws.String("admin"),
 			MasterUserPassword:   aws.String(password),
-			AllocatedStorage:     aws.Int32(20),
-			PubliclyAccessible:   aws.Bool(false),
+			AllocatedStorage:     aws.Int32(rdsAllocatedStorage),
+			PubliclyAccessible:   aws.Bool(plan.PubliclyAccessible.ValueBool()),
+			MultiAZ:              aws.Bool(plan.MultiAZ.ValueBool()),
+			DeletionProtection:   aws.Bool(plan.DeletionProtection.ValueBool()),
+		}
+		if gb := plan.StorageGB.ValueInt64(); gb > 0 {
+			input.AllocatedStorage = aws.Int32(int32(gb))
 		}
 		if plan.Version.ValueString() != "" {
 			input.EngineVersion = aws.String(plan.Version.ValueString())
 		}
-		_, err := r.rds.CreateDBInstance(ctx, input)
+		applyRDSStorageType(input, plan.StorageType.ValueString())
+		if plan.StorageEncrypted.ValueBool() {
+			input.StorageEncrypted = aws.Bool(true)
+			if key := plan.KMSKeyID.ValueString(); key != "" {
+				input.KmsKeyId = aws.String(key)
+			}
+		}
+		if private != "" {
+			group, err := r.awsSubnetGroup(ctx, id, private)
+			if err != nil {
+				resp.Diagnostics.AddError("aws subnet group", err.Error())
+				return
+			}
+			input.DBSubnetGroupName = aws.String(group)
+			input.PubliclyAccessible = aws.Bool(false)
+		}
+		out, err := r.rds.CreateDBInstance(ctx, input)
 		if err != nil {
 			resp.Diagnostics.AddError("aws create", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":      id,
-			"name":    plan.Name.ValueString(),
-			"type":    plan.Type.ValueString(),
-			"engine":  plan.Engine.ValueString(),
-			"version": plan.Version.ValueString(),
-			"size":    class,
-		})
+		plan.ID = types.StringValue(id)
+		plan.Size = types.StringValue(class)
+		plan.refresh(rdsInfo(*out.DBInstance))
        case "azure":
-		if r.azureMySQL == nil || r.azurePG == nil || r.azureRG == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
-			return
-		}
 		rgName := "abstract-rg"
-		if r.azureLoc == "" && plan.Size.ValueString() != "" {
-			r.azureLoc = plan.Size.ValueString()
-		}
-		_, err := r.azureRG.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{Location: &r.azureLoc}, nil)
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		err := shared.EnsureResourceGroup(ctx, r.azureRG, rgName, loc)
 		if err != nil {
 			resp.Diagnostics.AddError("azure rg", err.Error())
 			return
@@ -149,7 +492,7 @@ func (r *DatabaseResource) Create(ctx context.Context, req resource.CreateReques
 		if name == "" {
 			name = fmt.Sprintf("db-%d", time.Now().Unix())
 		}
-		password := os.Getenv("AZURE_DB_PASSWORD")
+		password := os.Getenv(databasePasswordEnv["azure"])
 		if password == "" {
 			resp.Diagnostics.AddError("missing password", "AZURE_DB_PASSWORD must be set")
 			return
@@ -159,60 +502,84 @@ func (r *DatabaseResource) Create(ctx context.Context, req resource.CreateReques
 		if size == "" {
 			size = "Standard_B1ms"
 		}
+		tier := azureSKUTier(size)
+		var subnetID, zoneID *string
+		if private != "" {
+			subnet, zone, err := r.azurePrivateAccess(ctx, name, engine, private, plan.PrivateSubnetCIDR.ValueString())
+			if err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure private network", err)
+				return
+			}
+			subnetID, zoneID = &subnet, &zone
+		}
+		var info databaseInfo
 		switch engine {
 		case "mysql":
+			props := &armmysqlflexibleservers.ServerProperties{
+				AdministratorLogin:         to.Ptr("adminuser"),
+				AdministratorLoginPassword: to.Ptr(password),
+			}
+			if plan.MultiAZ.ValueBool() {
+				props.HighAvailability = &armmysqlflexibleservers.HighAvailability{Mode: to.Ptr(armmysqlflexibleservers.HighAvailabilityModeZoneRedundant)}
+			}
+			if subnetID != nil {
+				props.Network = &armmysqlflexibleservers.Network{DelegatedSubnetResourceID: subnetID, PrivateDNSZoneResourceID: zoneID}
+			}
 			poller, err := r.azureMySQL.BeginCreate(ctx, rgName, name, armmysqlflexibleservers.Server{
-				Location: &r.azureLoc,
-				Properties: &armmysqlflexibleservers.ServerProperties{
-					AdministratorLogin:         to.Ptr("adminuser"),
-					AdministratorLoginPassword: to.Ptr(password),
-				},
+				Location:   &loc,
+				Properties: props,
+				SKU:        &armmysqlflexibleservers.SKU{Name: to.Ptr(size), Tier: to.Ptr(armmysqlflexibleservers.SKUTier(tier))},
 			}, nil)
+			var res armmysqlflexibleservers.ServersClientCreateResponse
 			if err == nil {
-				_, err = poller.PollUntilDone(ctx, nil)
+				res, err = shared.PollAzure(ctx, poller)
 			}
 			if err != nil {
-				resp.Diagnostics.AddError("azure create", err.Error())
+				shared.AddAzureError(&resp.Diagnostics, "azure create", err)
 				return
 			}
+			info = mysqlInfo(res.Server)
 		case "postgresql", "postgres":
+			props := &armpostgresqlflexibleservers.ServerProperties{
+				AdministratorLogin:         to.Ptr("adminuser"),
+				AdministratorLoginPassword: to.Ptr(password),
+			}
+			if plan.MultiAZ.ValueBool() {
+				props.HighAvailability = &armpostgresqlflexibleservers.HighAvailability{Mode: to.Ptr(armpostgresqlflexibleservers.HighAvailabilityModeZoneRedundant)}
+			}
+			if subnetID != nil {
+				props.Network = &armpostgresqlflexibleservers.Network{DelegatedSubnetResourceID: subnetID, PrivateDNSZoneArmResourceID: zoneID}
+			}
 			poller, err := r.azurePG.BeginCreate(ctx, rgName, name, armpostgresqlflexibleservers.Server{
-				Location: &r.azureLoc,
-				Properties: &armpostgresqlflexibleservers.ServerProperties{
-					AdministratorLogin:         to.Ptr("adminuser"),
-					AdministratorLoginPassword: to.Ptr(password),
-				},
-				SKU: &armpostgresqlflexibleservers.SKU{Name: to.Ptr(size)},
+				Location:   &loc,
+				Properties: props,
+				SKU:        &armpostgresqlflexibleservers.SKU{Name: to.Ptr(size), Tier: to.Ptr(armpostgresqlflexibleservers.SKUTier(tier))},
 			}, nil)
+			var res armpostgresqlflexibleservers.ServersClientCreateResponse
 			if err == nil {
-				_, err = poller.PollUntilDone(ctx, nil)
+				res, err = shared.PollAzure(ctx, poller)
 			}
 			if err != nil {
-				resp.Diagnostics.AddError("azure create", err.Error())
+				shared.AddAzureError(&resp.Diagnostics, "azure create", err)
 				return
 			}
+			info = postgresInfo(res.Server)
 		default:
 			resp.Diagnostics.AddError("unsupported engine", engine)
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":      name,
-			"name":    plan.Name.ValueString(),
-			"type":    plan.Type.ValueString(),
-			"engine":  plan.Engine.ValueString(),
-			"version": plan.Version.ValueString(),
-			"size":    size,
-		})
+		plan.ID = types.StringValue(name)
+		plan.Size = types.StringValue(size)
+		plan.refresh(info)
        case "gcp":
-               if r.gcpSQL == nil {
-                       resp.Diagnostics.AddError("gcp", "missing client")
-                       return
-               }
                name := plan.Name.ValueString()
                if name == "" {
                        name = fmt.Sprintf("db-%d", time.Now().Unix())
                }
-               region := r.gcpRegion
+               region := plan.Region.ValueString()
+               if region == "" {
+                       region = r.gcpRegion
+               }
                if region == "" {
                        region = "us-central1"
                }
@@ -233,8 +600,32 @@ func (r *DatabaseResource) Create(ctx context.Context, req resource.CreateReques
                        Name:           name,
                        Region:         region,
                        DatabaseVersion: version,
-                       Settings:       &sqladmin.Settings{Tier: tier},
+                       Settings:       &sqladmin.Settings{Tier: tier, DataDiskSizeGb: rdsAllocatedStorage},
                }
+		if gb := plan.StorageGB.ValueInt64(); gb > 0 {
+			// an explicit size is kept fixed rather than grown automatically
+			inst.Settings.DataDiskSizeGb = gb
+			inst.Settings.StorageAutoResize = to.Ptr(false)
+		}
+		if plan.MultiAZ.ValueBool() {
+			inst.Settings.AvailabilityType = "REGIONAL"
+		}
+		inst.Settings.DeletionProtectionEnabled = plan.DeletionProtection.ValueBool()
+		if labels := shared.MergeTags(r.defaultTags, stringMap(plan.Labels)); len(labels) > 0 {
+			inst.Settings.UserLabels = labels
+		}
+		if key := plan.KMSKeyID.ValueString(); key != "" {
+			// Cloud SQL always encrypts; a key switches it to CMEK
+			inst.DiskEncryptionConfiguration = &sqladmin.DiskEncryptionConfiguration{KmsKeyName: key}
+		}
+		if private != "" {
+			network, err := r.gcpPrivateServiceAccess(ctx, private)
+			if err != nil {
+				resp.Diagnostics.AddError("gcp private network", err.Error())
+				return
+			}
+			inst.Settings.IpConfiguration = &sqladmin.IpConfiguration{PrivateNetwork: network, Ipv4Enabled: false, ForceSendFields: []string{"Ipv4Enabled"}}
+		}
                op, err := r.gcpSQL.Instances.Insert(r.gcpProj, inst).Context(ctx).Do()
                if err != nil {
                        resp.Diagnostics.AddError("gcp create", err.Error())
@@ -249,105 +640,566 @@ func (r *DatabaseResource) Create(ctx context.Context, req resource.CreateReques
                        if oper.Status == "DONE" {
                                break
                        }
-                       time.Sleep(5 * time.Second)
+                       if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+                               resp.Diagnostics.AddError("gcp create", err.Error())
+                               return
+                       }
                }
-               resp.State.Set(ctx, map[string]interface{}{
-                       "id":      name,
-                       "name":    plan.Name.ValueString(),
-                       "type":    plan.Type.ValueString(),
-                       "engine":  plan.Engine.ValueString(),
-                       "version": version,
-                       "size":    tier,
-               })
+		plan.ID = types.StringValue(name)
+		plan.Size = types.StringValue(tier)
+		plan.Version = types.StringValue(version)
+		created, err := r.gcpSQL.Instances.Get(r.gcpProj, name).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp read", err.Error())
+			return
+		}
+		plan.refresh(cloudSQLInfo(created))
        default:
                resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
                return
        }
+	if plan.Version.IsUnknown() {
+		plan.Version = types.StringNull()
+	}
+	if plan.StorageGB.IsUnknown() {
+		plan.StorageGB = types.Int64Null()
+	}
+	if plan.PubliclyAccessible.IsUnknown() {
+		plan.PubliclyAccessible = types.BoolNull()
+	}
+	id, err := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
-func (r *DatabaseResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID   types.String `tfsdk:"id"`
-		Type types.String `tfsdk:"type"`
+// cloudID is the RDS instance's ARN, the flexible server's ID or the Cloud SQL
+// instance's self link.
+func (r *DatabaseResource) cloudID(ctx context.Context, m *databaseResourceModel) (string, error) {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.ARN(ctx, "rds", true, "db:"+id)
+	case "azure":
+		if sameEngine(m.Engine.ValueString(), "postgres") {
+			return r.ids.AzureID("abstract-rg", "Microsoft.DBforPostgreSQL/flexibleServers", id), nil
+		}
+		return r.ids.AzureID("abstract-rg", "Microsoft.DBforMySQL/flexibleServers", id), nil
+	case "gcp":
+		if r.gcpProj == "" {
+			return "", nil
+		}
+		return fmt.Sprintf("https://sqladmin.googleapis.com/sql/v1beta4/projects/%s/instances/%s", r.gcpProj, id), nil
 	}
+	return "", nil
+}
+
+// Read refreshes engine, version and size from the cloud. Values that only
+// differ in spelling from the configured ones, such as a "postgresql" engine
+// or a "8.0" version of a "8.0.35" instance, are left as configured.
+func (r *DatabaseResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state databaseResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	var info databaseInfo
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.rds == nil {
+		out, err := r.rds.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{DBInstanceIdentifier: aws.String(state.ID.ValueString())})
+		if err != nil || len(out.DBInstances) == 0 {
+			resp.State.RemoveResource(ctx)
 			return
 		}
-		_, err := r.rds.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{DBInstanceIdentifier: aws.String(state.ID.ValueString())})
+		info = rdsInfo(out.DBInstances[0])
+	case "azure":
+		// the engine is not recorded in the ID, so try each server type
+		if srv, err := r.azureMySQL.Get(ctx, "abstract-rg", state.ID.ValueString(), nil); err == nil {
+			info = mysqlInfo(srv.Server)
+		} else if srv, err := r.azurePG.Get(ctx, "abstract-rg", state.ID.ValueString(), nil); err == nil {
+			info = postgresInfo(srv.Server)
+		} else {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+	case "gcp":
+		inst, err := r.gcpSQL.Instances.Get(r.gcpProj, state.ID.ValueString()).Context(ctx).Do()
 		if err != nil {
 			resp.State.RemoveResource(ctx)
+			return
 		}
-       case "azure":
-               if r.azureMySQL == nil || r.azurePG == nil {
-                       return
-               }
-               _, err := r.azureMySQL.Get(ctx, "abstract-rg", state.ID.ValueString(), nil)
-               if err != nil {
-                       _, err2 := r.azurePG.Get(ctx, "abstract-rg", state.ID.ValueString(), nil)
-                       if err2 != nil {
-                               resp.State.RemoveResource(ctx)
-                       }
-               }
-       case "gcp":
-               if r.gcpSQL == nil {
-                       return
-               }
-               _, err := r.gcpSQL.Instances.Get(r.gcpProj, state.ID.ValueString()).Context(ctx).Do()
-               if err != nil {
-                       resp.State.RemoveResource(ctx)
-               }
-       }
+		info = cloudSQLInfo(inst)
+	default:
+		return
+	}
+	state.refresh(info)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+// refresh records the values reported by the cloud. Engine, version and size
+// are only replaced when they differ from the current ones; values the cloud
+// did not report are left alone.
+func (m *databaseResourceModel) refresh(info databaseInfo) {
+	if info.engine != "" && !sameEngine(m.Engine.ValueString(), info.engine) {
+		m.Engine = types.StringValue(info.engine)
+	}
+	if info.version != "" && !sameVersion(m.Version.ValueString(), info.version) {
+		m.Version = types.StringValue(info.version)
+	}
+	if info.size != "" && !strings.EqualFold(m.Size.ValueString(), info.size) {
+		m.Size = types.StringValue(info.size)
+	}
+	if info.storageGB > 0 {
+		m.StorageGB = types.Int64Value(info.storageGB)
+	}
+	if info.multiAZ != nil {
+		m.MultiAZ = types.BoolValue(*info.multiAZ)
+	}
+	if info.public != nil {
+		m.PubliclyAccessible = types.BoolValue(*info.public)
+	}
+	if info.protected != nil {
+		m.DeletionProtection = types.BoolValue(*info.protected)
+	}
+}
+
+func rdsInfo(db rdstypes.DBInstance) databaseInfo {
+	return databaseInfo{
+		engine:    aws.ToString(db.Engine),
+		version:   aws.ToString(db.EngineVersion),
+		size:      aws.ToString(db.DBInstanceClass),
+		storageGB: int64(aws.ToInt32(db.AllocatedStorage)),
+		multiAZ:   db.MultiAZ,
+		public:    db.PubliclyAccessible,
+		protected: db.DeletionProtection,
+	}
+}
+
+func mysqlInfo(srv armmysqlflexibleservers.Server) databaseInfo {
+	info := databaseInfo{engine: "mysql"}
+	if srv.SKU != nil && srv.SKU.Name != nil {
+		info.size = *srv.SKU.Name
+	}
+	if p := srv.Properties; p != nil {
+		info.version = azureServerVersion(p)
+		if p.Storage != nil && p.Storage.StorageSizeGB != nil {
+			info.storageGB = int64(*p.Storage.StorageSizeGB)
+		}
+		info.multiAZ = to.Ptr(p.HighAvailability != nil && p.HighAvailability.Mode != nil &&
+			*p.HighAvailability.Mode != armmysqlflexibleservers.HighAvailabilityModeDisabled)
+		if p.Network != nil && p.Network.PublicNetworkAccess != nil {
+			info.public = to.Ptr(*p.Network.PublicNetworkAccess == armmysqlflexibleservers.EnableStatusEnumEnabled)
+		}
+	}
+	return info
+}
+
+func postgresInfo(srv armpostgresqlflexibleservers.Server) databaseInfo {
+	info := databaseInfo{engine: "postgresql"}
+	if srv.SKU != nil && srv.SKU.Name != nil {
+		info.size = *srv.SKU.Name
+	}
+	if p := srv.Properties; p != nil {
+		info.version = azureServerVersion(p)
+		if p.Storage != nil && p.Storage.StorageSizeGB != nil {
+			info.storageGB = int64(*p.Storage.StorageSizeGB)
+		}
+		info.multiAZ = to.Ptr(p.HighAvailability != nil && p.HighAvailability.Mode != nil &&
+			*p.HighAvailability.Mode != armpostgresqlflexibleservers.HighAvailabilityModeDisabled)
+		if p.Network != nil && p.Network.PublicNetworkAccess != nil {
+			info.public = to.Ptr(*p.Network.PublicNetworkAccess == armpostgresqlflexibleservers.ServerPublicNetworkAccessStateEnabled)
+		}
+	}
+	return info
+}
+
+func cloudSQLInfo(inst *sqladmin.DatabaseInstance) databaseInfo {
+	info := databaseInfo{engine: cloudSQLEngine(inst.DatabaseVersion), version: inst.DatabaseVersion}
+	if s := inst.Settings; s != nil {
+		info.size = s.Tier
+		info.storageGB = s.DataDiskSizeGb
+		info.multiAZ = to.Ptr(s.AvailabilityType == "REGIONAL")
+		info.protected = to.Ptr(s.DeletionProtectionEnabled)
+		if s.IpConfiguration != nil {
+			info.public = to.Ptr(s.IpConfiguration.Ipv4Enabled)
+		}
+	}
+	return info
+}
+
+// ImportState accepts "<type>:<identifier>", e.g. "aws:mydb", where the
+// identifier is the RDS instance, flexible server or Cloud SQL instance name.
+func (r *DatabaseResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	cloud, id, ok := strings.Cut(req.ID, ":")
+	if !ok || id == "" || !slices.Contains([]string{"aws", "azure", "gcp"}, cloud) {
+		resp.Diagnostics.AddError("invalid import id", fmt.Sprintf("expected <aws|azure|gcp>:<name>, got %q", req.ID))
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), id)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), id)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("type"), cloud)...)
+}
+
+// azureServerVersion returns the server version of a MySQL or PostgreSQL
+// flexible server, whose properties types differ only by package.
+func azureServerVersion(props any) string {
+	switch p := props.(type) {
+	case *armmysqlflexibleservers.ServerProperties:
+		if p != nil && p.Version != nil {
+			return string(*p.Version)
+		}
+	case *armpostgresqlflexibleservers.ServerProperties:
+		if p != nil && p.Version != nil {
+			return string(*p.Version)
+		}
+	}
+	return ""
+}
+
+// cloudSQLEngine derives the engine from a Cloud SQL version such as "POSTGRES_15".
+func cloudSQLEngine(version string) string {
+	engine, _, _ := strings.Cut(version, "_")
+	return strings.ToLower(engine)
+}
+
+// sameEngine treats the engine aliases accepted by Create as equal.
+func sameEngine(a, b string) bool {
+	norm := func(e string) string {
+		e = strings.ToLower(e)
+		if e == "postgresql" {
+			return "postgres"
+		}
+		return e
+	}
+	return norm(a) == norm(b)
+}
+
+// sameVersion reports whether actual matches the configured version, which
+// may name only a prefix of it ("8.0" for "8.0.35").
+func sameVersion(configured, actual string) bool {
+	return configured != "" && (strings.EqualFold(configured, actual) || strings.HasPrefix(actual, configured+"."))
 }
+
+// Update changes the instance class, version, storage, availability and
+// public access in place and waits for the change to be applied. Engine
+// changes replace the database.
 func (r *DatabaseResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state databaseResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	if plan.Version.IsUnknown() {
+		plan.Version = state.Version
+	}
+	if plan.Size.IsUnknown() {
+		plan.Size = state.Size
+	}
+	if plan.StorageGB.IsUnknown() {
+		plan.StorageGB = state.StorageGB
+	}
+	if plan.PubliclyAccessible.IsUnknown() {
+		plan.PubliclyAccessible = state.PubliclyAccessible
+	}
+	sizeChanged := !plan.Size.Equal(state.Size)
+	versionChanged := !plan.Version.Equal(state.Version)
+	gbChanged := !plan.StorageGB.IsNull() && !plan.StorageGB.Equal(state.StorageGB)
+	haChanged := !plan.MultiAZ.Equal(state.MultiAZ)
+	publicChanged := !plan.PubliclyAccessible.IsNull() && !plan.PubliclyAccessible.Equal(state.PubliclyAccessible)
+	protectionChanged := !plan.DeletionProtection.Equal(state.DeletionProtection)
+	labelsChanged := !maps.Equal(stringMap(plan.Labels), stringMap(state.Labels))
+	switch plan.Type.ValueString() {
+	case "aws":
+		storageType := plan.StorageType.ValueString()
+		storageChanged := storageType != "" && storageType != state.StorageType.ValueString()
+		if !sizeChanged && !versionChanged && !storageChanged && !gbChanged && !haChanged && !publicChanged && !protectionChanged {
+			break
+		}
+		class := plan.Size.ValueString()
+		if class == "" {
+			class = "db.t3.micro"
+		}
+		input := &rds.ModifyDBInstanceInput{
+			DBInstanceIdentifier: aws.String(state.ID.ValueString()),
+			ApplyImmediately:     aws.Bool(true),
+		}
+		if sizeChanged {
+			input.DBInstanceClass = aws.String(class)
+		}
+		if versionChanged {
+			input.EngineVersion = aws.String(plan.Version.ValueString())
+			input.AllowMajorVersionUpgrade = aws.Bool(true)
+		}
+		if storageChanged {
+			if err := r.checkRDSOptions(ctx, &plan, class); err != nil {
+				resp.Diagnostics.AddError("aws storage", err.Error())
+				return
+			}
+			input.StorageType = aws.String(storageType)
+			if storageType == "io1" || storageType == "io2" {
+				input.AllocatedStorage = aws.Int32(max(int32(plan.StorageGB.ValueInt64()), rdsProvisionedStorage))
+				input.Iops = aws.Int32(rdsProvisionedIOPS)
+			}
+		}
+		if gbChanged && input.AllocatedStorage == nil {
+			input.AllocatedStorage = aws.Int32(int32(plan.StorageGB.ValueInt64()))
+		}
+		if haChanged {
+			input.MultiAZ = aws.Bool(plan.MultiAZ.ValueBool())
+		}
+		if publicChanged {
+			input.PubliclyAccessible = aws.Bool(plan.PubliclyAccessible.ValueBool())
+		}
+		if protectionChanged {
+			input.DeletionProtection = aws.Bool(plan.DeletionProtection.ValueBool())
+		}
+		if _, err := r.rds.ModifyDBInstance(ctx, input); err != nil {
+			resp.Diagnostics.AddError("aws modify", err.Error())
+			return
+		}
+		db, err := r.waitRDSModified(ctx, state.ID.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("aws modify", err.Error())
+			return
+		}
+		plan.refresh(rdsInfo(db))
+	case "azure":
+		if !sizeChanged && !haChanged {
+			break
+		}
+		size := plan.Size.ValueString()
+		tier := azureSKUTier(size)
+		if sameEngine(plan.Engine.ValueString(), "mysql") {
+			update := armmysqlflexibleservers.ServerForUpdate{}
+			if sizeChanged {
+				update.SKU = &armmysqlflexibleservers.SKU{Name: to.Ptr(size), Tier: to.Ptr(armmysqlflexibleservers.SKUTier(tier))}
+			}
+			if haChanged {
+				mode := armmysqlflexibleservers.HighAvailabilityModeDisabled
+				if plan.MultiAZ.ValueBool() {
+					mode = armmysqlflexibleservers.HighAvailabilityModeZoneRedundant
+				}
+				update.Properties = &armmysqlflexibleservers.ServerPropertiesForUpdate{HighAvailability: &armmysqlflexibleservers.HighAvailability{Mode: &mode}}
+			}
+			poller, err := r.azureMySQL.BeginUpdate(ctx, "abstract-rg", state.ID.ValueString(), update, nil)
+			var res armmysqlflexibleservers.ServersClientUpdateResponse
+			if err == nil {
+				res, err = shared.PollAzure(ctx, poller)
+			}
+			if err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure update", err)
+				return
+			}
+			plan.refresh(mysqlInfo(res.Server))
+		} else {
+			update := armpostgresqlflexibleservers.ServerForUpdate{}
+			if sizeChanged {
+				update.SKU = &armpostgresqlflexibleservers.SKU{Name: to.Ptr(size), Tier: to.Ptr(armpostgresqlflexibleservers.SKUTier(tier))}
+			}
+			if haChanged {
+				mode := armpostgresqlflexibleservers.HighAvailabilityModeDisabled
+				if plan.MultiAZ.ValueBool() {
+					mode = armpostgresqlflexibleservers.HighAvailabilityModeZoneRedundant
+				}
+				update.Properties = &armpostgresqlflexibleservers.ServerPropertiesForUpdate{HighAvailability: &armpostgresqlflexibleservers.HighAvailability{Mode: &mode}}
+			}
+			poller, err := r.azurePG.BeginUpdate(ctx, "abstract-rg", state.ID.ValueString(), update, nil)
+			var res armpostgresqlflexibleservers.ServersClientUpdateResponse
+			if err == nil {
+				res, err = shared.PollAzure(ctx, poller)
+			}
+			if err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure update", err)
+				return
+			}
+			plan.refresh(postgresInfo(res.Server))
+		}
+	case "gcp":
+		if !sizeChanged && !versionChanged && !gbChanged && !haChanged && !protectionChanged && !labelsChanged {
+			break
+		}
+		patch := &sqladmin.DatabaseInstance{Settings: &sqladmin.Settings{}}
+		if sizeChanged {
+			patch.Settings.Tier = plan.Size.ValueString()
+		}
+		if gbChanged {
+			patch.Settings.DataDiskSizeGb = plan.StorageGB.ValueInt64()
+			patch.Settings.StorageAutoResize = to.Ptr(false)
+		}
+		if haChanged {
+			patch.Settings.AvailabilityType = "ZONAL"
+			if plan.MultiAZ.ValueBool() {
+				patch.Settings.AvailabilityType = "REGIONAL"
+			}
+		}
+		if versionChanged {
+			// Cloud SQL performs major version upgrades in place
+			patch.DatabaseVersion = plan.Version.ValueString()
+		}
+		if protectionChanged {
+			patch.Settings.DeletionProtectionEnabled = plan.DeletionProtection.ValueBool()
+			patch.Settings.ForceSendFields = append(patch.Settings.ForceSendFields, "DeletionProtectionEnabled")
+		}
+		if labelsChanged {
+			// the patch replaces the instance's labels; an empty map clears them
+			patch.Settings.UserLabels = shared.MergeTags(r.defaultTags, stringMap(plan.Labels))
+			patch.Settings.ForceSendFields = append(patch.Settings.ForceSendFields, "UserLabels")
+		}
+		op, err := r.gcpSQL.Instances.Patch(r.gcpProj, state.ID.ValueString(), patch).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp update", err.Error())
+			return
+		}
+		for {
+			oper, err := r.gcpSQL.Operations.Get(r.gcpProj, op.Name).Context(ctx).Do()
+			if err != nil {
+				resp.Diagnostics.AddError("gcp update", err.Error())
+				return
+			}
+			if oper.Status == "DONE" {
+				if oper.Error != nil && len(oper.Error.Errors) > 0 {
+					resp.Diagnostics.AddError("gcp update", oper.Error.Errors[0].Message)
+					return
+				}
+				break
+			}
+			if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+				resp.Diagnostics.AddError("gcp update", err.Error())
+				return
+			}
+		}
+		inst, err := r.gcpSQL.Instances.Get(r.gcpProj, state.ID.ValueString()).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp read", err.Error())
+			return
+		}
+		plan.refresh(cloudSQLInfo(inst))
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
-func (r *DatabaseResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		ID   types.String `tfsdk:"id"`
-		Type types.String `tfsdk:"type"`
+
+// waitRDSModified polls the instance until it is available with no pending
+// class, version, storage or Multi-AZ changes, and returns it.
+func (r *DatabaseResource) waitRDSModified(ctx context.Context, id string) (rdstypes.DBInstance, error) {
+	deadline := time.Now().Add(rdsModifyTimeout)
+	for {
+		out, err := r.rds.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{DBInstanceIdentifier: aws.String(id)})
+		if err != nil {
+			return rdstypes.DBInstance{}, err
+		}
+		if len(out.DBInstances) == 0 {
+			return rdstypes.DBInstance{}, fmt.Errorf("instance %s not found", id)
+		}
+		db := out.DBInstances[0]
+		pending := db.PendingModifiedValues
+		if aws.ToString(db.DBInstanceStatus) == "available" &&
+			(pending == nil || pending.DBInstanceClass == nil && pending.EngineVersion == nil && pending.StorageType == nil &&
+				pending.AllocatedStorage == nil && pending.MultiAZ == nil) {
+			return db, nil
+		}
+		if time.Now().After(deadline) {
+			return db, fmt.Errorf("instance %s still %s after %s", id, aws.ToString(db.DBInstanceStatus), rdsModifyTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return db, ctx.Err()
+		case <-time.After(15 * time.Second):
+		}
+	}
+}
+
+// azureSKUTier returns the flexible server tier of a SKU such as "Standard_B1ms".
+func azureSKUTier(sku string) string {
+	switch {
+	case strings.HasPrefix(sku, "Standard_B"):
+		return "Burstable"
+	case strings.HasPrefix(sku, "Standard_E"):
+		return "MemoryOptimized"
 	}
+	return "GeneralPurpose"
+}
+
+func (r *DatabaseResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state databaseResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	if state.DeletionProtection.ValueBool() {
+		resp.Diagnostics.AddAttributeError(path.Root("deletion_protection"), "deletion protection",
+			fmt.Sprintf("database %s has deletion_protection = true; set it to false and apply before destroying it", state.ID.ValueString()))
+		return
+	}
+	// imported databases have no setting yet and keep the old behaviour
+	skip := state.SkipFinalSnapshot.IsNull() || state.SkipFinalSnapshot.ValueBool()
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.rds == nil {
-			return
+		input := &rds.DeleteDBInstanceInput{DBInstanceIdentifier: aws.String(state.ID.ValueString()), SkipFinalSnapshot: aws.Bool(skip)}
+		if !skip {
+			input.FinalDBSnapshotIdentifier = aws.String(state.FinalSnapshotIdentifier.ValueString())
 		}
-		_, err := r.rds.DeleteDBInstance(ctx, &rds.DeleteDBInstanceInput{DBInstanceIdentifier: aws.String(state.ID.ValueString()), SkipFinalSnapshot: true})
+		_, err := r.rds.DeleteDBInstance(ctx, input)
 		if err != nil {
 			resp.Diagnostics.AddError("aws delete", err.Error())
+			return
+		}
+		if !state.PrivateNetworkID.IsNull() {
+			if err := r.deleteAWSSubnetGroup(ctx, state.ID.ValueString()); err != nil {
+				resp.Diagnostics.AddError("aws delete subnet group", err.Error())
+			}
 		}
        case "azure":
-               if r.azureMySQL == nil || r.azurePG == nil {
-                       return
-               }
                poller, err := r.azureMySQL.BeginDelete(ctx, "abstract-rg", state.ID.ValueString(), nil)
                if err == nil {
-                       _, err = poller.PollUntilDone(ctx, nil)
+                       _, err = shared.PollAzure(ctx, poller)
                }
                if err != nil {
                        poller2, err2 := r.azurePG.BeginDelete(ctx, "abstract-rg", state.ID.ValueString(), nil)
                        if err2 == nil {
-                               _, err2 = poller2.PollUntilDone(ctx, nil)
+                               _, err2 = shared.PollAzure(ctx, poller2)
                        }
                        if err2 != nil {
-                               resp.Diagnostics.AddError("azure delete", err2.Error())
+                               shared.AddAzureError(&resp.Diagnostics, "azure delete", err2)
+                               return
                        }
                }
+		if vnet := state.PrivateNetworkID.ValueString(); vnet != "" && r.privateConfigured("azure") {
+			if err := r.deleteAzurePrivateAccess(ctx, state.ID.ValueString(), state.Engine.ValueString(), vnet); err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure delete private network", err)
+			}
+		}
        case "gcp":
-               if r.gcpSQL == nil {
-                       return
-               }
-               op, err := r.gcpSQL.Instances.Delete(r.gcpProj, state.ID.ValueString()).Context(ctx).Do()
+		call := r.gcpSQL.Instances.Delete(r.gcpProj, state.ID.ValueString())
+		if !skip {
+			call = call.EnableFinalBackup(true)
+			if d := state.FinalSnapshotIdentifier.ValueString(); d != "" {
+				call = call.FinalBackupDescription(d)
+			}
+		}
+		op, err := call.Context(ctx).Do()
                if err != nil {
                        resp.Diagnostics.AddError("gcp delete", err.Error())
                        return
@@ -361,7 +1213,10 @@ func (r *DatabaseResource) Delete(ctx context.Context, req resource.DeleteReques
                        if oper.Status == "DONE" {
                                break
                        }
-                       time.Sleep(5 * time.Second)
+                       if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+                               resp.Diagnostics.AddError("gcp delete", err.Error())
+                               return
+                       }
                }
        }
 }
@@ -0,0 +1,259 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	compute "google.golang.org/api/compute/v1"
+	servicenetworking "google.golang.org/api/servicenetworking/v1"
+)
+
+// A database with private_network_id gets the networking it needs to be
+// reached only from inside that network: an RDS DB subnet group, a delegated
+// subnet and private DNS zone on Azure, or private services access on GCP.
+
+// azurePrivateDNSAPIVersion is the Microsoft.Network/privateDnsZones API
+// version; there is no armprivatedns module among the provider's
+// dependencies, so zones are managed as generic resources.
+const azurePrivateDNSAPIVersion = "2020-06-01"
+
+// gcpServiceNetworking is the service Cloud SQL private IPs are peered with.
+const gcpServiceNetworking = "services/servicenetworking.googleapis.com"
+
+// rdsSubnetGroupName names the DB subnet group created for database id.
+func rdsSubnetGroupName(id string) string { return id + "-subnets" }
+
+// awsSubnetGroup creates a DB subnet group of the subnets of vpcID for
+// database id and returns its name. RDS requires subnets in at least two
+// availability zones.
+func (r *DatabaseResource) awsSubnetGroup(ctx context.Context, id, vpcID string) (string, error) {
+	out, err := r.ec2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{Filters: []ec2types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}})
+	if err != nil {
+		return "", err
+	}
+	var subnets, zones []string
+	for _, s := range out.Subnets {
+		subnets = append(subnets, aws.ToString(s.SubnetId))
+		if z := aws.ToString(s.AvailabilityZone); !slices.Contains(zones, z) {
+			zones = append(zones, z)
+		}
+	}
+	if len(zones) < 2 {
+		return "", fmt.Errorf("%s has subnets in %d availability zones; RDS needs at least two", vpcID, len(zones))
+	}
+	name := rdsSubnetGroupName(id)
+	_, err = r.rds.CreateDBSubnetGroup(ctx, &rds.CreateDBSubnetGroupInput{
+		DBSubnetGroupName:        aws.String(name),
+		DBSubnetGroupDescription: aws.String("abstract_database " + id),
+		SubnetIds:                subnets,
+	})
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// deleteAWSSubnetGroup waits for database id to be gone, as its subnet group
+// cannot be deleted while in use, and deletes the group.
+func (r *DatabaseResource) deleteAWSSubnetGroup(ctx context.Context, id string) error {
+	err := rds.NewDBInstanceDeletedWaiter(r.rds).Wait(ctx, &rds.DescribeDBInstancesInput{DBInstanceIdentifier: aws.String(id)}, rdsModifyTimeout)
+	if err != nil {
+		return err
+	}
+	_, err = r.rds.DeleteDBSubnetGroup(ctx, &rds.DeleteDBSubnetGroupInput{DBSubnetGroupName: aws.String(rdsSubnetGroupName(id))})
+	return err
+}
+
+// azureDelegatedService is the service a flexible server's subnet is
+// delegated to.
+func azureDelegatedService(engine string) string {
+	if sameEngine(engine, "postgres") {
+		return "Microsoft.DBforPostgreSQL/flexibleServers"
+	}
+	return "Microsoft.DBforMySQL/flexibleServers"
+}
+
+// azurePrivateDNSZoneName names the private DNS zone of a flexible server,
+// which must end in the engine's domain.
+func azurePrivateDNSZoneName(server, engine string) string {
+	if sameEngine(engine, "postgres") {
+		return server + ".private.postgres.database.azure.com"
+	}
+	return server + ".private.mysql.database.azure.com"
+}
+
+// azurePrivateDNSZoneID returns the resource ID of a flexible server's
+// private DNS zone, which is kept in abstract-rg.
+func (r *DatabaseResource) azurePrivateDNSZoneID(server, engine string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/abstract-rg/providers/Microsoft.Network/privateDnsZones/%s", r.azureSubID, azurePrivateDNSZoneName(server, engine))
+}
+
+// azurePrivateAccess prepares the network of a flexible server: a subnet
+// "<server>-db" of cidr in vnetID delegated to the engine's service, and a
+// private DNS zone linked to the network. It returns the subnet and zone IDs.
+func (r *DatabaseResource) azurePrivateAccess(ctx context.Context, server, engine, vnetID, cidr string) (string, string, error) {
+	rg, vnetName, err := azureVNetRef(vnetID)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := addAzureAddressSpace(ctx, r.azureVNet, rg, vnetName, cidr); err != nil {
+		return "", "", err
+	}
+	subnetPoller, err := r.azureSub.BeginCreateOrUpdate(ctx, rg, vnetName, server+"-db", armnetwork.Subnet{
+		Properties: &armnetwork.SubnetPropertiesFormat{
+			AddressPrefix: &cidr,
+			Delegations: []*armnetwork.Delegation{{
+				Name:       to.Ptr("flexibleServers"),
+				Properties: &armnetwork.ServiceDelegationPropertiesFormat{ServiceName: to.Ptr(azureDelegatedService(engine))},
+			}},
+		},
+	}, nil)
+	var subnet armnetwork.SubnetsClientCreateOrUpdateResponse
+	if err == nil {
+		subnet, err = shared.PollAzure(ctx, subnetPoller)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("delegated subnet: %w", err)
+	}
+	if subnet.ID == nil {
+		return "", "", fmt.Errorf("delegated subnet %s-db has no ID", server)
+	}
+	zoneID := r.azurePrivateDNSZoneID(server, engine)
+	if err := r.putAzureGeneric(ctx, zoneID, armresources.GenericResource{Location: to.Ptr("global")}); err != nil {
+		return "", "", fmt.Errorf("private dns zone: %w", err)
+	}
+	link := armresources.GenericResource{
+		Location: to.Ptr("global"),
+		Properties: map[string]any{
+			"virtualNetwork":      map[string]any{"id": vnetID},
+			"registrationEnabled": false,
+		},
+	}
+	if err := r.putAzureGeneric(ctx, zoneID+"/virtualNetworkLinks/"+vnetName, link); err != nil {
+		return "", "", fmt.Errorf("private dns zone link: %w", err)
+	}
+	return *subnet.ID, zoneID, nil
+}
+
+func (r *DatabaseResource) putAzureGeneric(ctx context.Context, id string, res armresources.GenericResource) error {
+	poller, err := r.azureRes.BeginCreateOrUpdateByID(ctx, id, azurePrivateDNSAPIVersion, res, nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, poller)
+	}
+	return err
+}
+
+// deleteAzurePrivateAccess removes what azurePrivateAccess created once the
+// server is gone. Parts that no longer exist are skipped.
+func (r *DatabaseResource) deleteAzurePrivateAccess(ctx context.Context, server, engine, vnetID string) error {
+	rg, vnetName, err := azureVNetRef(vnetID)
+	if err != nil {
+		return err
+	}
+	zoneID := r.azurePrivateDNSZoneID(server, engine)
+	for _, id := range []string{zoneID + "/virtualNetworkLinks/" + vnetName, zoneID} {
+		poller, err := r.azureRes.BeginDeleteByID(ctx, id, azurePrivateDNSAPIVersion, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil && azureStatus(err) != http.StatusNotFound {
+			return err
+		}
+	}
+	poller, err := r.azureSub.BeginDelete(ctx, rg, vnetName, server+"-db", nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, poller)
+	}
+	if err != nil && azureStatus(err) != http.StatusNotFound {
+		return err
+	}
+	return nil
+}
+
+// gcpPeeringRange names the address range reserved in network for the
+// services it is peered with.
+func gcpPeeringRange(network string) string {
+	name := "abstract-sql-" + network
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	return name
+}
+
+// gcpPrivateServiceAccess sets up private services access in network: a
+// reserved /16 range and a service networking connection using it. Both are
+// shared by every private Cloud SQL instance in the network and are kept
+// when an instance is deleted. It returns the network path Cloud SQL takes.
+func (r *DatabaseResource) gcpPrivateServiceAccess(ctx context.Context, network string) (string, error) {
+	networkPath := fmt.Sprintf("projects/%s/global/networks/%s", r.gcpProj, network)
+	rangeName := gcpPeeringRange(network)
+	if _, err := r.gcpCompute.GlobalAddresses.Get(r.gcpProj, rangeName).Context(ctx).Do(); err != nil {
+		op, err := r.gcpCompute.GlobalAddresses.Insert(r.gcpProj, &compute.Address{
+			Name:         rangeName,
+			Purpose:      "VPC_PEERING",
+			AddressType:  "INTERNAL",
+			PrefixLength: 16,
+			Network:      networkPath,
+		}).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcpCompute, r.gcpProj, op)
+		}
+		if err != nil {
+			return "", fmt.Errorf("peering range: %w", err)
+		}
+	}
+	// service networking identifies networks by project number
+	project, err := r.gcpProjects.Projects.Get(r.gcpProj).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	peered := fmt.Sprintf("projects/%d/global/networks/%s", project.ProjectNumber, network)
+	conns, err := r.gcpSNet.Services.Connections.List(gcpServiceNetworking).Network(peered).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	var op *servicenetworking.Operation
+	switch {
+	case len(conns.Connections) == 0:
+		op, err = r.gcpSNet.Services.Connections.Create(gcpServiceNetworking, &servicenetworking.Connection{
+			Network:               peered,
+			ReservedPeeringRanges: []string{rangeName},
+		}).Context(ctx).Do()
+	case !slices.Contains(conns.Connections[0].ReservedPeeringRanges, rangeName):
+		conn := conns.Connections[0]
+		op, err = r.gcpSNet.Services.Connections.Patch(gcpServiceNetworking+"/connections/-", &servicenetworking.Connection{
+			Network:               peered,
+			ReservedPeeringRanges: append(conn.ReservedPeeringRanges, rangeName),
+		}).UpdateMask("reservedPeeringRanges").Force(true).Context(ctx).Do()
+	default:
+		return networkPath, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("service networking connection: %w", err)
+	}
+	for !op.Done {
+		if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+			return "", err
+		}
+		if op, err = r.gcpSNet.Operations.Get(op.Name).Context(ctx).Do(); err != nil {
+			return "", err
+		}
+	}
+	if op.Error != nil {
+		return "", errors.New(op.Error.Message)
+	}
+	return networkPath, nil
+}
@@ -0,0 +1,374 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mysql/armmysqlflexibleservers"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/postgresql/armpostgresqlflexibleservers"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+)
+
+func TestDatabaseImportState(t *testing.T) {
+	r := &DatabaseResource{}
+	cases := []struct {
+		id, cloud, name string
+		ok              bool
+	}{
+		{"aws:mydb", "aws", "mydb", true},
+		{"gcp:orders-db", "gcp", "orders-db", true},
+		{"mydb", "", "", false},
+		{"aws:", "", "", false},
+		{"oracle:mydb", "", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.id, func(t *testing.T) {
+			resp := &resource.ImportStateResponse{State: testState(t, r, nil)}
+			r.ImportState(context.Background(), resource.ImportStateRequest{ID: tc.id}, resp)
+			if resp.Diagnostics.HasError() == tc.ok {
+				t.Fatalf("diagnostics = %v", resp.Diagnostics)
+			}
+			if !tc.ok {
+				return
+			}
+			var got databaseResourceModel
+			resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+			if got.Type.ValueString() != tc.cloud || got.ID.ValueString() != tc.name || got.Name.ValueString() != tc.name {
+				t.Errorf("state = %+v", got)
+			}
+		})
+	}
+}
+
+func TestDatabaseReadBackMatching(t *testing.T) {
+	if !sameEngine("postgresql", "postgres") || sameEngine("mysql", "postgres") {
+		t.Error("sameEngine")
+	}
+	for _, tc := range []struct {
+		configured, actual string
+		want               bool
+	}{
+		{"8.0", "8.0.35", true},
+		{"8.0.35", "8.0.35", true},
+		{"8", "8.0.35", true},
+		{"8.0.3", "8.0.35", false},
+		{"", "8.0.35", false},
+		{"POSTGRES_15", "POSTGRES_15", true},
+	} {
+		if got := sameVersion(tc.configured, tc.actual); got != tc.want {
+			t.Errorf("sameVersion(%q, %q) = %v, want %v", tc.configured, tc.actual, got, tc.want)
+		}
+	}
+	if got := cloudSQLEngine("POSTGRES_15"); got != "postgres" {
+		t.Errorf("cloudSQLEngine = %q, want postgres", got)
+	}
+}
+
+func TestDatabaseRefreshAvailability(t *testing.T) {
+	m := databaseResourceModel{
+		Engine:             types.StringValue("postgres"),
+		Version:            types.StringValue("POSTGRES_15"),
+		StorageGB:          types.Int64Unknown(),
+		MultiAZ:            types.BoolValue(false),
+		PubliclyAccessible: types.BoolUnknown(),
+	}
+	m.refresh(cloudSQLInfo(&sqladmin.DatabaseInstance{
+		DatabaseVersion: "POSTGRES_15",
+		Settings: &sqladmin.Settings{
+			Tier:             "db-f1-micro",
+			DataDiskSizeGb:   50,
+			AvailabilityType: "REGIONAL",
+			IpConfiguration:  &sqladmin.IpConfiguration{Ipv4Enabled: true},
+		},
+	}))
+	if m.StorageGB.ValueInt64() != 50 || !m.MultiAZ.ValueBool() || !m.PubliclyAccessible.ValueBool() {
+		t.Errorf("gcp refresh = %+v", m)
+	}
+	if m.Engine.ValueString() != "postgres" || m.Size.ValueString() != "db-f1-micro" {
+		t.Errorf("gcp refresh = %+v", m)
+	}
+
+	m.refresh(postgresInfo(armpostgresqlflexibleservers.Server{
+		Properties: &armpostgresqlflexibleservers.ServerProperties{
+			HighAvailability: &armpostgresqlflexibleservers.HighAvailability{Mode: to.Ptr(armpostgresqlflexibleservers.HighAvailabilityModeDisabled)},
+			Storage:          &armpostgresqlflexibleservers.Storage{StorageSizeGB: to.Ptr[int32](128)},
+		},
+	}))
+	if m.StorageGB.ValueInt64() != 128 || m.MultiAZ.ValueBool() {
+		t.Errorf("azure refresh = %+v", m)
+	}
+	if !m.PubliclyAccessible.ValueBool() {
+		t.Error("unreported public access was overwritten")
+	}
+}
+
+func TestDatabaseDeletionProtection(t *testing.T) {
+	m := databaseResourceModel{DeletionProtection: types.BoolValue(false)}
+	m.refresh(cloudSQLInfo(&sqladmin.DatabaseInstance{Settings: &sqladmin.Settings{DeletionProtectionEnabled: true}}))
+	if !m.DeletionProtection.ValueBool() {
+		t.Error("gcp deletion protection not read back")
+	}
+	m.refresh(mysqlInfo(armmysqlflexibleservers.Server{}))
+	if !m.DeletionProtection.ValueBool() {
+		t.Error("azure refresh cleared deletion protection")
+	}
+
+	// Delete must refuse before touching any client, so none are configured.
+	r := &DatabaseResource{}
+	for _, cloud := range []string{"aws", "azure", "gcp"} {
+		resp := &resource.DeleteResponse{}
+		r.Delete(context.Background(), resource.DeleteRequest{State: testState(t, r, map[string]tftypes.Value{
+			"id":                  str("mydb"),
+			"type":                str(cloud),
+			"engine":              str("mysql"),
+			"deletion_protection": boolean(true),
+		})}, resp)
+		if !resp.Diagnostics.HasError() {
+			t.Errorf("%s: protected database deleted", cloud)
+		}
+	}
+}
+
+func TestDatabaseFinalSnapshotConfig(t *testing.T) {
+	r := &DatabaseResource{}
+	s := testSchema(t, r)
+	cases := []struct {
+		name            string
+		vals            map[string]tftypes.Value
+		errors, warning bool
+	}{
+		{"aws default", map[string]tftypes.Value{"type": str("aws")}, false, false},
+		{"aws without identifier", map[string]tftypes.Value{"type": str("aws"), "skip_final_snapshot": boolean(false)}, true, false},
+		{"aws with identifier", map[string]tftypes.Value{"type": str("aws"), "skip_final_snapshot": boolean(false), "final_snapshot_identifier": str("mydb-final")}, false, false},
+		{"gcp final backup", map[string]tftypes.Value{"type": str("gcp"), "skip_final_snapshot": boolean(false)}, false, false},
+		{"azure unsupported", map[string]tftypes.Value{"type": str("azure"), "skip_final_snapshot": boolean(false)}, false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errors || (resp.Diagnostics.WarningsCount() > 0) != tc.warning {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestDatabaseModifyPlanPassword(t *testing.T) {
+	r := &DatabaseResource{}
+	cases := []struct {
+		name     string
+		cloud    string
+		password string
+		existing bool
+		errors   bool
+	}{
+		{"aws with password", "aws", "secret", false, false},
+		{"aws without password", "aws", "", false, true},
+		{"azure without password", "azure", "", false, true},
+		{"gcp needs none", "gcp", "", false, false},
+		{"existing database", "aws", "", true, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("RDS_PASSWORD", tc.password)
+			t.Setenv("AZURE_DB_PASSWORD", tc.password)
+			vals := map[string]tftypes.Value{"name": str("db"), "type": str(tc.cloud), "engine": str("mysql")}
+			state := testState(t, r, nil)
+			if tc.existing {
+				state = testState(t, r, vals)
+			}
+			plan := testPlan(t, r, vals)
+			resp := &resource.ModifyPlanResponse{Plan: plan}
+			r.ModifyPlan(context.Background(), resource.ModifyPlanRequest{Plan: plan, State: state}, resp)
+			if resp.Diagnostics.HasError() != tc.errors {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestAzureSKUTier(t *testing.T) {
+	for sku, want := range map[string]string{
+		"Standard_B1ms":    "Burstable",
+		"Standard_D2ds_v4": "GeneralPurpose",
+		"Standard_E4ds_v5": "MemoryOptimized",
+	} {
+		if got := azureSKUTier(sku); got != want {
+			t.Errorf("azureSKUTier(%q) = %q, want %q", sku, got, want)
+		}
+	}
+}
+
+// newAzureDatabase returns a DatabaseResource whose Azure clients talk to arm.
+func newAzureDatabase(t *testing.T, arm *fakeARM, location string) *DatabaseResource {
+	t.Helper()
+	rg, err := armresources.NewResourceGroupsClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mysql, err := armmysqlflexibleservers.NewServersClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pg, err := armpostgresqlflexibleservers.NewServersClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &DatabaseResource{azureRG: rg, azureMySQL: mysql, azurePG: pg, azureLoc: location}
+}
+
+func TestDatabaseCreateAzureLocation(t *testing.T) {
+	t.Setenv("AZURE_DB_PASSWORD", "secret")
+	const server = "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.DBforMySQL/flexibleServers/db"
+	cases := []struct {
+		name, provider string
+		region         tftypes.Value
+		want           string
+	}{
+		{"size only", "", tftypes.NewValue(tftypes.String, nil), "eastus"},
+		{"provider location", "westeurope", tftypes.NewValue(tftypes.String, nil), "westeurope"},
+		{"region", "westeurope", str("northeurope"), "northeurope"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			arm := newFakeARM()
+			r := newAzureDatabase(t, arm, tc.provider)
+			plan := testPlan(t, r, map[string]tftypes.Value{"name": str("db"), "type": str("azure"), "engine": str("mysql"), "size": str("Standard_B1ms"), "region": tc.region})
+			resp := &resource.CreateResponse{State: testState(t, r, nil)}
+			r.Create(context.Background(), resource.CreateRequest{Plan: plan}, resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatal(resp.Diagnostics)
+			}
+			for _, p := range []string{"/subscriptions/sub/resourcegroups/abstract-rg", server} {
+				put, ok := arm.puts[p]
+				if !ok {
+					t.Fatalf("no PUT to %s in %v", p, arm.puts)
+				}
+				if put["location"] != tc.want {
+					t.Errorf("%s location = %v, want %s", p, put["location"], tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDatabaseCreateAzureSKU(t *testing.T) {
+	t.Setenv("AZURE_DB_PASSWORD", "secret")
+	const servers = "/subscriptions/sub/resourceGroups/abstract-rg/providers/"
+	cases := []struct {
+		engine, path string
+		size         tftypes.Value
+		sku, tier    string
+	}{
+		{"mysql", "Microsoft.DBforMySQL/flexibleServers/db", str("Standard_D2ds_v4"), "Standard_D2ds_v4", "GeneralPurpose"},
+		{"mysql", "Microsoft.DBforMySQL/flexibleServers/db", tftypes.NewValue(tftypes.String, nil), "Standard_B1ms", "Burstable"},
+		{"postgresql", "Microsoft.DBforPostgreSQL/flexibleServers/db", str("Standard_E4ds_v5"), "Standard_E4ds_v5", "MemoryOptimized"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.engine+" "+tc.sku, func(t *testing.T) {
+			arm := newFakeARM()
+			r := newAzureDatabase(t, arm, "")
+			plan := testPlan(t, r, map[string]tftypes.Value{"name": str("db"), "type": str("azure"), "engine": str(tc.engine), "size": tc.size})
+			resp := &resource.CreateResponse{State: testState(t, r, nil)}
+			r.Create(context.Background(), resource.CreateRequest{Plan: plan}, resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatal(resp.Diagnostics)
+			}
+			sku, _ := arm.puts[servers+tc.path]["sku"].(map[string]any)
+			if sku["name"] != tc.sku || sku["tier"] != tc.tier {
+				t.Errorf("sku = %v, want %s (%s)", sku, tc.sku, tc.tier)
+			}
+			var got databaseResourceModel
+			resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+			if got.Size.ValueString() != tc.sku {
+				t.Errorf("size = %v, want %s", got.Size, tc.sku)
+			}
+		})
+	}
+}
+
+func TestDatabasePrivateNetworkConfig(t *testing.T) {
+	const vnet = "/subscriptions/sub/resourceGroups/net-rg/providers/Microsoft.Network/virtualNetworks/prod"
+	r := &DatabaseResource{}
+	s := testSchema(t, r)
+	cases := []struct {
+		name            string
+		vals            map[string]tftypes.Value
+		errors, warning bool
+	}{
+		{"aws vpc", map[string]tftypes.Value{"type": str("aws"), "private_network_id": str("vpc-0abc")}, false, false},
+		{"aws vnet", map[string]tftypes.Value{"type": str("aws"), "private_network_id": str(vnet)}, true, false},
+		{"public and private", map[string]tftypes.Value{"type": str("aws"), "private_network_id": str("vpc-0abc"), "publicly_accessible": boolean(true)}, true, false},
+		{"azure without cidr", map[string]tftypes.Value{"type": str("azure"), "private_network_id": str(vnet)}, true, false},
+		{"azure with cidr", map[string]tftypes.Value{"type": str("azure"), "private_network_id": str(vnet), "private_subnet_cidr": str("10.1.0.0/24")}, false, false},
+		{"azure bad cidr", map[string]tftypes.Value{"type": str("azure"), "private_network_id": str(vnet), "private_subnet_cidr": str("10.1.0.0")}, true, false},
+		{"gcp private", map[string]tftypes.Value{"type": str("gcp"), "private_network_id": str("prod"), "publicly_accessible": boolean(false)}, false, false},
+		{"gcp not public without network", map[string]tftypes.Value{"type": str("gcp"), "publicly_accessible": boolean(false)}, true, false},
+		{"gcp cidr ignored", map[string]tftypes.Value{"type": str("gcp"), "private_network_id": str("prod"), "private_subnet_cidr": str("10.1.0.0/24")}, false, true},
+		{"cidr without network", map[string]tftypes.Value{"type": str("azure"), "private_subnet_cidr": str("10.1.0.0/24")}, true, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errors || (resp.Diagnostics.WarningsCount() > 0) != tc.warning {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestDatabaseCreateAzurePrivate(t *testing.T) {
+	t.Setenv("AZURE_DB_PASSWORD", "secret")
+	const (
+		vnet   = "/subscriptions/sub/resourceGroups/net-rg/providers/Microsoft.Network/virtualNetworks/prod"
+		server = "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.DBforPostgreSQL/flexibleServers/db"
+		zone   = "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.Network/privateDnsZones/db.private.postgres.database.azure.com"
+	)
+	arm := newFakeARM()
+	arm.notFound = true
+	arm.puts[vnet] = map[string]any{"id": vnet, "location": "eastus", "properties": map[string]any{"addressSpace": map[string]any{"addressPrefixes": []any{"10.0.0.0/16"}}}}
+	r := newAzureDatabase(t, arm, "")
+	r.azureSubID = "sub"
+	var err error
+	if r.azureVNet, err = armnetwork.NewVirtualNetworksClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	if r.azureSub, err = armnetwork.NewSubnetsClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	if r.azureRes, err = armresources.NewClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	plan := testPlan(t, r, map[string]tftypes.Value{"name": str("db"), "type": str("azure"), "engine": str("postgresql"),
+		"private_network_id": str(vnet), "private_subnet_cidr": str("10.1.0.0/24")})
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(context.Background(), resource.CreateRequest{Plan: plan}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatal(resp.Diagnostics)
+	}
+	space, _ := arm.puts[vnet]["properties"].(map[string]any)["addressSpace"].(map[string]any)
+	if prefixes, _ := space["addressPrefixes"].([]any); len(prefixes) != 2 || prefixes[1] != "10.1.0.0/24" {
+		t.Errorf("address space = %v", space)
+	}
+	subnet, _ := arm.puts[vnet+"/subnets/db-db"]["properties"].(map[string]any)
+	delegations, _ := subnet["delegations"].([]any)
+	if len(delegations) != 1 || delegations[0].(map[string]any)["properties"].(map[string]any)["serviceName"] != "Microsoft.DBforPostgreSQL/flexibleServers" {
+		t.Errorf("subnet = %v", subnet)
+	}
+	if _, ok := arm.puts[zone+"/virtualNetworkLinks/prod"]; !ok {
+		t.Errorf("zone not linked to the network: %v", arm.puts)
+	}
+	network, _ := arm.puts[server]["properties"].(map[string]any)["network"].(map[string]any)
+	if network["delegatedSubnetResourceId"] != vnet+"/subnets/db-db" || network["privateDnsZoneArmResourceId"] != zone {
+		t.Errorf("server network = %v", network)
+	}
+}
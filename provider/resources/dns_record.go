@@ -3,7 +3,10 @@ package resources
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"abstract-provider/provider/shared"
 
@@ -12,11 +15,19 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
 	r53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"google.golang.org/api/compute/v1"
 	dnsapi "google.golang.org/api/dns/v1"
 )
 
@@ -29,7 +40,39 @@ type DNSRecordResource struct {
 	azureCred    azcore.TokenCredential
 	azureSub     string
 	gcpDNS       *dnsapi.Service
+	gcpCompute   *compute.Service
 	gcpProject   string
+
+	// awsRegions has the ELB clients alias targets are looked up with.
+	awsRegions *shared.AWSRegions
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	ids *shared.CloudIDs
+}
+
+type dnsRecordResourceModel struct {
+	ID      types.String `tfsdk:"id"`
+	CloudID types.String `tfsdk:"cloud_id"`
+	Name    types.String `tfsdk:"name"`
+	Zone    types.String `tfsdk:"zone"`
+	ZoneID  types.String `tfsdk:"zone_id"`
+	Type    types.String `tfsdk:"type"`
+	// RecordType is the DNS record type, since type names the cloud.
+	RecordType types.String `tfsdk:"record_type"`
+	Value      types.String `tfsdk:"value"`
+	TTL        types.Int64  `tfsdk:"ttl"`
+
+	AliasTarget types.String `tfsdk:"alias_target"`
+
+	RoutingPolicy types.String `tfsdk:"routing_policy"`
+	SetIdentifier types.String `tfsdk:"set_identifier"`
+	Weight        types.Int64  `tfsdk:"weight"`
+	LatencyRegion types.String `tfsdk:"latency_region"`
+	GeoLocation   types.String `tfsdk:"geo_location"`
+
+	NameServers types.List `tfsdk:"name_servers"`
 }
 
 func NewDNSRecordResource() resource.Resource { return &DNSRecordResource{} }
@@ -43,6 +86,8 @@ func (r *DNSRecordResource) Configure(ctx context.Context, req resource.Configur
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
+	r.timeout = cfg.RequestTimeout
+	r.ids = cfg.CloudIDs
 	r.route53 = cfg.AWSRoute53
 	r.azureRG = cfg.AzureRGClient
 	r.azureZones = cfg.AzureDNSZoneClient
@@ -50,6 +95,8 @@ func (r *DNSRecordResource) Configure(ctx context.Context, req resource.Configur
 	r.azureCred = cfg.AzureCred
 	r.azureSub = cfg.AzureSubID
 	r.gcpDNS = cfg.GCPDNS
+	r.gcpCompute = cfg.GCPCompute
+	r.awsRegions = cfg.AWSRegions
 	r.gcpProject = cfg.GCPProject
 }
 
@@ -60,222 +107,710 @@ func (r *DNSRecordResource) Metadata(ctx context.Context, req resource.MetadataR
 func (r *DNSRecordResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
-			"id":    schema.StringAttribute{Computed: true},
-			"name":  schema.StringAttribute{Required: true},
-			"zone":  schema.StringAttribute{Required: true},
-			"type":  schema.StringAttribute{Required: true},
-			"value": schema.StringAttribute{Required: true},
-			"ttl":   schema.Int64Attribute{Optional: true, Computed: true},
+			"id":   schema.StringAttribute{Computed: true},
+			"name": schema.StringAttribute{Required: true},
+			"zone": schema.StringAttribute{Required: true},
+			// An abstract_dns_zone's id. Without it the zone is looked up by
+			// name on AWS, and created if missing on Azure and GCP.
+			"zone_id": schema.StringAttribute{Optional: true},
+			"type":    schema.StringAttribute{Required: true},
+			// A, AAAA, CNAME or TXT.
+			"record_type": schema.StringAttribute{Optional: true, Computed: true, Default: stringdefault.StaticString("A")},
+			"value":       schema.StringAttribute{Optional: true},
+			"ttl":         schema.Int64Attribute{Optional: true, Computed: true},
+			// An abstract_load_balancer's id to point an A record at instead
+			// of value: an alias record on AWS and Azure, the load balancer's
+			// address on GCP.
+			"alias_target": schema.StringAttribute{Optional: true},
+
+			// Update may move the record to another name or record type, so
+			// cloud_id is not carried over from state.
+			"cloud_id": schema.StringAttribute{Computed: true},
+			// simple (the default), weighted, latency or geo; the others are
+			// Route 53 routing policies and need set_identifier.
+			"routing_policy": schema.StringAttribute{Optional: true},
+			"set_identifier": schema.StringAttribute{Optional: true},
+			// Share of weighted answers, 0 to 255.
+			"weight": schema.Int64Attribute{Optional: true},
+			// AWS region whose clients a latency record answers.
+			"latency_region": schema.StringAttribute{Optional: true},
+			// Country code, country-subdivision code, continent:<code> or * for
+			// the default geo record.
+			"geo_location": schema.StringAttribute{Optional: true},
+			// The zone's delegation name servers, for zones the record created.
+			"name_servers": schema.ListAttribute{ElementType: types.StringType, Computed: true, PlanModifiers: []planmodifier.List{listplanmodifier.UseStateForUnknown()}},
 		},
 	}
 }
 
-func (r *DNSRecordResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name  types.String `tfsdk:"name"`
-		Zone  types.String `tfsdk:"zone"`
-		Type  types.String `tfsdk:"type"`
-		Value types.String `tfsdk:"value"`
-		TTL   types.Int64  `tfsdk:"ttl"`
+// dnsRoutingFields maps each routing policy to the attribute it needs.
+var dnsRoutingFields = map[string]string{
+	"weighted": "weight",
+	"latency":  "latency_region",
+	"geo":      "geo_location",
+}
+
+var dnsRoutingAttrs = []string{"weight", "latency_region", "geo_location"}
+
+// dnsRecordTypes are the record types abstract_dns_record writes on every cloud.
+var dnsRecordTypes = []string{"A", "AAAA", "CNAME", "TXT"}
+
+func (r *DNSRecordResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg dnsRecordResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if rt := cfg.RecordType; !rt.IsNull() && !rt.IsUnknown() {
+		if !slices.Contains(dnsRecordTypes, strings.ToUpper(rt.ValueString())) {
+			resp.Diagnostics.AddAttributeError(path.Root("record_type"), "invalid record_type", fmt.Sprintf("%q is not one of %s", rt.ValueString(), strings.Join(dnsRecordTypes, ", ")))
+		} else if !cfg.AliasTarget.IsNull() && !strings.EqualFold(rt.ValueString(), "A") {
+			resp.Diagnostics.AddAttributeError(path.Root("record_type"), "alias record type", "alias_target records are A records")
+		}
+	}
+	switch {
+	case cfg.Value.IsNull() && cfg.AliasTarget.IsNull():
+		resp.Diagnostics.AddAttributeError(path.Root("value"), "missing value", "set value, or alias_target to point the record at a load balancer")
+	case !cfg.Value.IsNull() && !cfg.AliasTarget.IsNull():
+		resp.Diagnostics.AddAttributeWarning(path.Root("value"), "value ignored", "alias_target is set, so the record points at the load balancer instead of value")
+	}
+	if cfg.RoutingPolicy.IsUnknown() {
+		return
+	}
+	policy := cfg.routingPolicy()
+	set := map[string]bool{
+		"weight":         !cfg.Weight.IsNull(),
+		"latency_region": !cfg.LatencyRegion.IsNull(),
+		"geo_location":   !cfg.GeoLocation.IsNull(),
+	}
+	if policy == "simple" {
+		if !cfg.SetIdentifier.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("set_identifier"), "simple routing", "set_identifier only applies to weighted, latency and geo routing policies")
+		}
+		for _, attr := range dnsRoutingAttrs {
+			if set[attr] {
+				resp.Diagnostics.AddAttributeError(path.Root(attr), "simple routing", fmt.Sprintf("%s needs a routing_policy other than simple", attr))
+			}
+		}
+		return
+	}
+	field, ok := dnsRoutingFields[policy]
+	if !ok {
+		resp.Diagnostics.AddAttributeError(path.Root("routing_policy"), "invalid routing_policy", fmt.Sprintf("%q is not one of simple, weighted, latency or geo", policy))
+		return
+	}
+	switch t := cfg.Type.ValueString(); t {
+	case "azure":
+		resp.Diagnostics.AddAttributeError(path.Root("routing_policy"), "unsupported routing policy", "Azure DNS record sets have no routing policies; weighted, latency and geo routing need Traffic Manager, which abstract_dns_record does not manage")
+		return
+	case "gcp":
+		resp.Diagnostics.AddAttributeError(path.Root("routing_policy"), "unsupported routing policy", "Cloud DNS keeps a routing policy on the whole record set rather than one record per set_identifier, which abstract_dns_record cannot express")
+		return
+	}
+	if cfg.SetIdentifier.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("set_identifier"), "missing set_identifier", fmt.Sprintf("%s routing needs a set_identifier unique among the records of the same name and type", policy))
+	}
+	for _, attr := range dnsRoutingAttrs {
+		switch {
+		case attr == field && !set[attr]:
+			resp.Diagnostics.AddAttributeError(path.Root(attr), "missing "+attr, fmt.Sprintf("%s routing needs %s", policy, attr))
+		case attr != field && set[attr]:
+			resp.Diagnostics.AddAttributeError(path.Root(attr), "wrong routing policy", fmt.Sprintf("%s does not apply to %s routing", attr, policy))
+		}
+	}
+	if w := cfg.Weight; !w.IsNull() && !w.IsUnknown() && (w.ValueInt64() < 0 || w.ValueInt64() > 255) {
+		resp.Diagnostics.AddAttributeError(path.Root("weight"), "invalid weight", fmt.Sprintf("weight %d is not between 0 and 255", w.ValueInt64()))
+	}
+	if g := cfg.GeoLocation; !g.IsNull() && !g.IsUnknown() {
+		if _, err := awsGeoLocation(g.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("geo_location"), "invalid geo_location", err.Error())
+		}
+	}
+}
+
+// rrType is the DNS record type of m; alias records are A records.
+func (m *dnsRecordResourceModel) rrType() string {
+	if !m.AliasTarget.IsNull() || m.RecordType.ValueString() == "" {
+		return "A"
+	}
+	return strings.ToUpper(m.RecordType.ValueString())
+}
+
+// fqdn is the fully qualified name of m's record, with the trailing dot.
+// name may be relative to the zone or already include it.
+func (m *dnsRecordResourceModel) fqdn() string {
+	name, zone := strings.TrimSuffix(m.Name.ValueString(), "."), strings.TrimSuffix(m.Zone.ValueString(), ".")
+	if name == zone || strings.HasSuffix(name, "."+zone) {
+		return name + "."
+	}
+	return name + "." + zone + "."
+}
+
+// awsAlias returns the alias target of the load balancer with ARN lbARN,
+// looked up in the load balancer's region.
+func (r *DNSRecordResource) awsAlias(ctx context.Context, lbARN string) (*r53types.AliasTarget, error) {
+	a, err := arn.Parse(lbARN)
+	if err != nil {
+		return nil, fmt.Errorf("alias_target %q is not a load balancer ARN", lbARN)
+	}
+	c := r.awsRegions.Clients(a.Region)
+	if c == nil {
+		return nil, fmt.Errorf("no AWS clients for %s", a.Region)
+	}
+	out, err := c.ELB.DescribeLoadBalancers(ctx, &elbv2.DescribeLoadBalancersInput{LoadBalancerArns: []string{lbARN}})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.LoadBalancers) == 0 {
+		return nil, fmt.Errorf("load balancer %s not found", lbARN)
+	}
+	lb := out.LoadBalancers[0]
+	return &r53types.AliasTarget{DNSName: lb.DNSName, HostedZoneId: lb.CanonicalHostedZoneId}, nil
+}
+
+// gcpLBAddress returns the address of the load balancer named name, which
+// abstract_load_balancer reserves as name-ip in the load balancer's region.
+func (r *DNSRecordResource) gcpLBAddress(ctx context.Context, name string) (string, error) {
+	if r.gcpCompute == nil {
+		return "", fmt.Errorf("gcp compute client not configured")
+	}
+	out, err := r.gcpCompute.Addresses.AggregatedList(r.gcpProject).Filter(fmt.Sprintf("name = %q", name+"-ip")).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	for _, scoped := range out.Items {
+		for _, addr := range scoped.Addresses {
+			if addr.Name == name+"-ip" {
+				return addr.Address, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("load balancer %s has no address", name)
+}
+
+func (m *dnsRecordResourceModel) routingPolicy() string {
+	if p := m.RoutingPolicy.ValueString(); p != "" {
+		return strings.ToLower(p)
 	}
+	return "simple"
+}
+
+// awsContinents are the Route 53 continent codes.
+var awsContinents = map[string]bool{"AF": true, "AN": true, "AS": true, "EU": true, "NA": true, "OC": true, "SA": true}
+
+// awsGeoLocation parses a geo_location: a two-letter country code such as
+// "DE", a country and subdivision such as "US-WA", "continent:EU", or "*" for
+// clients no other geo record matches.
+func awsGeoLocation(s string) (*r53types.GeoLocation, error) {
+	if s == "*" {
+		return &r53types.GeoLocation{CountryCode: aws.String("*")}, nil
+	}
+	if code, ok := strings.CutPrefix(s, "continent:"); ok {
+		if !awsContinents[code] {
+			return nil, fmt.Errorf("%q is not a continent code; use AF, AN, AS, EU, NA, OC or SA", code)
+		}
+		return &r53types.GeoLocation{ContinentCode: aws.String(code)}, nil
+	}
+	country, sub, hasSub := strings.Cut(s, "-")
+	if len(country) != 2 || strings.ToUpper(country) != country || (hasSub && sub == "") {
+		return nil, fmt.Errorf("%q is not a country code such as DE, a subdivision such as US-WA, continent:<code> or *", s)
+	}
+	loc := &r53types.GeoLocation{CountryCode: aws.String(country)}
+	if hasSub {
+		loc.SubdivisionCode = aws.String(sub)
+	}
+	return loc, nil
+}
+
+// awsRouting sets m's routing policy on a Route 53 record set.
+func (m *dnsRecordResourceModel) awsRouting(rrs *r53types.ResourceRecordSet) {
+	switch m.routingPolicy() {
+	case "weighted":
+		rrs.Weight = aws.Int64(m.Weight.ValueInt64())
+	case "latency":
+		rrs.Region = r53types.ResourceRecordSetRegion(m.LatencyRegion.ValueString())
+	case "geo":
+		rrs.GeoLocation, _ = awsGeoLocation(m.GeoLocation.ValueString())
+	default:
+		return
+	}
+	rrs.SetIdentifier = aws.String(m.SetIdentifier.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *DNSRecordResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.route53 != nil
+	case "azure":
+		return r.azureRG != nil && r.azureZones != nil && r.azureRecords != nil
+	case "gcp":
+		return r.gcpDNS != nil
+	}
+	return false
+}
+
+func (r *DNSRecordResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan dnsRecordResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	r.put(ctx, &plan, false, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// put writes the record of plan and fills in its computed attributes. On
+// update the record is replaced in place: an UPSERT on AWS, CreateOrUpdate on
+// Azure and, on GCP, one change that swaps the record set, so the name keeps
+// resolving throughout.
+func (r *DNSRecordResource) put(ctx context.Context, plan *dnsRecordResourceModel, update bool, diags *diag.Diagnostics) {
 	ttl := int64(300)
 	if !plan.TTL.IsNull() {
 		ttl = plan.TTL.ValueInt64()
 	}
-	fqdn := plan.Name.ValueString()
-	if !strings.HasSuffix(fqdn, plan.Zone.ValueString()+".") {
-		fqdn = fqdn + "." + plan.Zone.ValueString() + "."
-	}
+	fqdn := plan.fqdn()
 	switch strings.ToLower(plan.Type.ValueString()) {
 	case "aws":
-		if r.route53 == nil {
-			resp.Diagnostics.AddError("aws", "missing client")
+		zoneID, err := r.awsZoneID(ctx, plan)
+		if err != nil {
+			diags.AddError("aws zone", err.Error())
 			return
 		}
-		// lookup zone
-		out, err := r.route53.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{DNSName: aws.String(plan.Zone.ValueString())})
-		if err != nil || len(out.HostedZones) == 0 {
-			resp.Diagnostics.AddError("aws zone", "not found")
-			return
+		rrs := &r53types.ResourceRecordSet{
+			Name:            aws.String(fqdn),
+			Type:            r53types.RRType(plan.rrType()),
+			TTL:             aws.Int64(ttl),
+			ResourceRecords: []r53types.ResourceRecord{{Value: aws.String(plan.Value.ValueString())}},
+		}
+		plan.awsRouting(rrs)
+		if lb := plan.AliasTarget.ValueString(); lb != "" {
+			rrs.AliasTarget, err = r.awsAlias(ctx, lb)
+			if err != nil {
+				diags.AddAttributeError(path.Root("alias_target"), "aws alias target", err.Error())
+				return
+			}
+			// alias records answer with the target's TTL
+			rrs.TTL, rrs.ResourceRecords = nil, nil
 		}
-		zoneID := aws.ToString(out.HostedZones[0].Id)
 		_, err = r.route53.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
 			HostedZoneId: aws.String(zoneID),
 			ChangeBatch: &r53types.ChangeBatch{Changes: []r53types.Change{{
-				Action: r53types.ChangeActionUpsert,
-				ResourceRecordSet: &r53types.ResourceRecordSet{
-					Name:            aws.String(fqdn),
-					Type:            r53types.RRType(plan.Type.ValueString()),
-					TTL:             aws.Int64(ttl),
-					ResourceRecords: []r53types.ResourceRecord{{Value: aws.String(plan.Value.ValueString())}},
-				},
+				Action:            r53types.ChangeActionUpsert,
+				ResourceRecordSet: rrs,
 			}}},
 		})
 		if err != nil {
-			resp.Diagnostics.AddError("aws create", err.Error())
+			diags.AddError("aws create", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":    fmt.Sprintf("%s/%s", zoneID, fqdn),
-			"name":  plan.Name.ValueString(),
-			"zone":  plan.Zone.ValueString(),
-			"type":  plan.Type.ValueString(),
-			"value": plan.Value.ValueString(),
-			"ttl":   ttl,
-		})
+		plan.ID = types.StringValue(plan.recordID(zoneID, fqdn))
+		plan.CloudID = types.StringNull()
 	case "azure":
-		if r.azureZones == nil || r.azureRecords == nil || r.azureRG == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
-			return
-		}
-		rg := "abstract-dns-rg"
-		_, err := r.azureRG.CreateOrUpdate(ctx, rg, armresources.ResourceGroup{Location: to.Ptr("global")}, nil)
-		if err != nil {
-			resp.Diagnostics.AddError("azure rg", err.Error())
-			return
-		}
-		_, err = r.azureZones.CreateOrUpdate(ctx, rg, plan.Zone.ValueString(), armdns.Zone{Location: to.Ptr("global")}, nil)
-		if err != nil {
-			resp.Diagnostics.AddError("azure zone", err.Error())
-			return
-		}
-		recordType := armdns.RecordTypeA
-		if strings.EqualFold(plan.Type.ValueString(), "CNAME") {
-			recordType = armdns.RecordTypeCNAME
+		rg, zone := plan.azureZone()
+		if plan.ZoneID.IsNull() && !update {
+			err := shared.EnsureResourceGroup(ctx, r.azureRG, rg, "global")
+			if err != nil {
+				diags.AddError("azure rg", err.Error())
+				return
+			}
+			_, err = r.azureZones.CreateOrUpdate(ctx, rg, zone, armdns.Zone{Location: to.Ptr("global")}, nil)
+			if err != nil {
+				diags.AddError("azure zone", err.Error())
+				return
+			}
 		}
+		recordType := armdns.RecordType(plan.rrType())
 		setParams := armdns.RecordSet{Properties: &armdns.RecordSetProperties{TTL: to.Ptr(ttl)}}
-		if recordType == armdns.RecordTypeA {
-			setParams.Properties.ARecords = []*armdns.ARecord{{IPv4Address: to.Ptr(plan.Value.ValueString())}}
-		} else {
-			setParams.Properties.CnameRecord = &armdns.CnameRecord{Cname: to.Ptr(plan.Value.ValueString())}
+		value := to.Ptr(plan.Value.ValueString())
+		switch lb := plan.AliasTarget.ValueString(); {
+		case lb != "":
+			// abstract_load_balancer's public IP; an internal one has none
+			setParams.Properties.TargetResource = &armdns.SubResource{ID: to.Ptr(r.ids.AzureID("abstract-rg", "Microsoft.Network/publicIPAddresses", lb+"-pip"))}
+		case recordType == armdns.RecordTypeAAAA:
+			setParams.Properties.AaaaRecords = []*armdns.AaaaRecord{{IPv6Address: value}}
+		case recordType == armdns.RecordTypeCNAME:
+			setParams.Properties.CnameRecord = &armdns.CnameRecord{Cname: value}
+		case recordType == armdns.RecordTypeTXT:
+			setParams.Properties.TxtRecords = []*armdns.TxtRecord{{Value: []*string{value}}}
+		default:
+			setParams.Properties.ARecords = []*armdns.ARecord{{IPv4Address: value}}
 		}
-		_, err = r.azureRecords.CreateOrUpdate(ctx, rg, plan.Zone.ValueString(), fqdn, recordType, setParams, nil)
+		_, err := r.azureRecords.CreateOrUpdate(ctx, rg, zone, fqdn, recordType, setParams, nil)
 		if err != nil {
-			resp.Diagnostics.AddError("azure record", err.Error())
+			diags.AddError("azure record", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":             fmt.Sprintf("%s/%s", plan.Zone.ValueString(), fqdn),
-			"name":           plan.Name.ValueString(),
-			"zone":           plan.Zone.ValueString(),
-			"type":           plan.Type.ValueString(),
-			"value":          plan.Value.ValueString(),
-			"ttl":            ttl,
-			"resource_group": rg,
-		})
+		plan.ID = types.StringValue(plan.recordID(zone, fqdn))
+		plan.CloudID = types.StringValue(r.cloudID(plan, fqdn))
 	case "gcp":
-		if r.gcpDNS == nil {
-			resp.Diagnostics.AddError("gcp", "missing client")
-			return
+		zone := plan.gcpZone()
+		if plan.ZoneID.IsNull() && !update {
+			// ensure zone exists
+			_, err := r.gcpDNS.ManagedZones.Get(r.gcpProject, zone).Context(ctx).Do()
+			if err != nil {
+				mz := &dnsapi.ManagedZone{Name: zone, DnsName: zone + "."}
+				_, err = r.gcpDNS.ManagedZones.Create(r.gcpProject, mz).Context(ctx).Do()
+				if err != nil {
+					diags.AddError("gcp zone", err.Error())
+					return
+				}
+			}
 		}
-		// ensure zone exists
-		_, err := r.gcpDNS.ManagedZones.Get(r.gcpProject, plan.Zone.ValueString()).Context(ctx).Do()
-		if err != nil {
-			zone := &dnsapi.ManagedZone{Name: plan.Zone.ValueString(), DnsName: plan.Zone.ValueString() + "."}
-			_, err = r.gcpDNS.ManagedZones.Create(r.gcpProject, zone).Context(ctx).Do()
+		value := plan.Value.ValueString()
+		if lb := plan.AliasTarget.ValueString(); lb != "" {
+			// Cloud DNS has no alias records; point an A record at the address
+			var err error
+			if value, err = r.gcpLBAddress(ctx, lb); err != nil {
+				diags.AddAttributeError(path.Root("alias_target"), "gcp alias target", err.Error())
+				return
+			}
+		}
+		change := &dnsapi.Change{Additions: []*dnsapi.ResourceRecordSet{{Name: fqdn, Type: plan.rrType(), Ttl: ttl, Rrdatas: []string{value}}}}
+		if update {
+			// one change deletes the current record set and adds the new one,
+			// which Cloud DNS applies atomically
+			current, err := r.gcpDNS.ResourceRecordSets.List(r.gcpProject, zone).Name(fqdn).Type(plan.rrType()).Context(ctx).Do()
 			if err != nil {
-				resp.Diagnostics.AddError("gcp zone", err.Error())
+				diags.AddError("gcp record", err.Error())
 				return
 			}
+			for _, rrs := range current.Rrsets {
+				if strings.EqualFold(rrs.Name, fqdn) && rrs.Type == plan.rrType() {
+					change.Deletions = append(change.Deletions, rrs)
+				}
+			}
 		}
-		change := &dnsapi.Change{Additions: []*dnsapi.ResourceRecordSet{{Name: fqdn, Type: strings.ToUpper(plan.Type.ValueString()), Ttl: ttl, Rrdatas: []string{plan.Value.ValueString()}}}}
-		_, err = r.gcpDNS.Changes.Create(r.gcpProject, plan.Zone.ValueString(), change).Context(ctx).Do()
+		_, err := r.gcpDNS.Changes.Create(r.gcpProject, zone, change).Context(ctx).Do()
 		if err != nil {
-			resp.Diagnostics.AddError("gcp record", err.Error())
+			diags.AddError("gcp record", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":    fmt.Sprintf("%s/%s", plan.Zone.ValueString(), fqdn),
-			"name":  plan.Name.ValueString(),
-			"zone":  plan.Zone.ValueString(),
-			"type":  plan.Type.ValueString(),
-			"value": plan.Value.ValueString(),
-			"ttl":   ttl,
-		})
+		plan.ID = types.StringValue(plan.recordID(zone, fqdn))
+		plan.CloudID = types.StringValue(r.cloudID(plan, fqdn))
 	default:
-		resp.Diagnostics.AddError("unsupported cloud", "")
+		diags.AddError("unsupported cloud", "")
+		return
+	}
+	plan.TTL = types.Int64Value(ttl)
+	servers, err := r.zoneNameServers(ctx, plan)
+	if err != nil {
+		diags.AddError(plan.Type.ValueString()+" zone name servers", err.Error())
+		return
 	}
+	plan.NameServers = nameServersValue(servers)
 }
 
-func (r *DNSRecordResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID            types.String `tfsdk:"id"`
-		Zone          types.String `tfsdk:"zone"`
-		Name          types.String `tfsdk:"name"`
-		Type          types.String `tfsdk:"type"`
-		Value         types.String `tfsdk:"value"`
-		TTL           types.Int64  `tfsdk:"ttl"`
-		ResourceGroup types.String `tfsdk:"resource_group"`
+// zoneNameServers returns the delegation name servers of m's zone: the
+// hosted zone's delegation set on AWS and the zone's name servers on Azure and
+// GCP. Private zones have none.
+func (r *DNSRecordResource) zoneNameServers(ctx context.Context, m *dnsRecordResourceModel) ([]string, error) {
+	switch strings.ToLower(m.Type.ValueString()) {
+	case "aws":
+		zoneID, err := r.awsZoneID(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		out, err := r.route53.GetHostedZone(ctx, &route53.GetHostedZoneInput{Id: aws.String(zoneID)})
+		if err != nil {
+			return nil, err
+		}
+		if out.DelegationSet == nil {
+			return nil, nil
+		}
+		return out.DelegationSet.NameServers, nil
+	case "azure":
+		rg, zone := m.azureZone()
+		out, err := r.azureZones.Get(ctx, rg, zone, nil)
+		if err != nil {
+			return nil, err
+		}
+		return azureNameServers(out.Zone), nil
+	case "gcp":
+		out, err := r.gcpDNS.ManagedZones.Get(r.gcpProject, m.gcpZone()).Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+		if out.Visibility == "private" {
+			return nil, nil
+		}
+		return out.NameServers, nil
+	}
+	return nil, nil
+}
+
+// awsZoneID returns the hosted zone of m: its zone_id, or the first hosted
+// zone named zone.
+func (r *DNSRecordResource) awsZoneID(ctx context.Context, m *dnsRecordResourceModel) (string, error) {
+	if id := m.ZoneID.ValueString(); id != "" {
+		return id, nil
+	}
+	out, err := r.route53.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{DNSName: aws.String(m.Zone.ValueString())})
+	if err != nil {
+		return "", err
+	}
+	if len(out.HostedZones) == 0 {
+		return "", fmt.Errorf("hosted zone %s not found", m.Zone.ValueString())
+	}
+	return aws.ToString(out.HostedZones[0].Id), nil
+}
+
+// azureZone returns the resource group and name of m's Azure DNS zone.
+func (m *dnsRecordResourceModel) azureZone() (rg, name string) {
+	if id := m.ZoneID.ValueString(); id != "" {
+		return azureZoneRef(id)
+	}
+	return azureDNSResourceGroup, m.Zone.ValueString()
+}
+
+// gcpZone returns the name of m's Cloud DNS managed zone.
+func (m *dnsRecordResourceModel) gcpZone() string {
+	if id := m.ZoneID.ValueString(); id != "" {
+		return id
+	}
+	return m.Zone.ValueString()
+}
+
+// cloudID is the record set's resource ID on Azure and its full name on GCP.
+// Route 53 records have no ID of their own, so AWS records have none.
+func (r *DNSRecordResource) cloudID(m *dnsRecordResourceModel, fqdn string) string {
+	switch strings.ToLower(m.Type.ValueString()) {
+	case "azure":
+		rg, zone := m.azureZone()
+		return r.ids.AzureID(rg, "Microsoft.Network/dnszones/"+zone+"/"+m.rrType(), fqdn)
+	case "gcp":
+		return r.ids.GCPName(fmt.Sprintf("managedZones/%s/rrsets/%s/%s", m.gcpZone(), fqdn, m.rrType()))
+	}
+	return ""
+}
+
+// recordID is the id Create gives the record of m named fqdn in zone, the
+// hosted zone ID on AWS and the zone name elsewhere.
+func (m *dnsRecordResourceModel) recordID(zone, fqdn string) string {
+	id := fmt.Sprintf("%s/%s", zone, fqdn)
+	if sid := m.SetIdentifier.ValueString(); sid != "" && strings.EqualFold(m.Type.ValueString(), "aws") {
+		id += "/" + sid
+	}
+	return id
+}
+
+// setRecord records the TTL and values the cloud reports for m's record, so
+// that changes made outside Terraform show up as a diff. A record set holding
+// several values reports them comma-separated, which never matches a single
+// value and so brings it back to one. Alias records keep their state: they
+// answer with the target's address and TTL.
+func (m *dnsRecordResourceModel) setRecord(ttl *int64, values []string) {
+	if !m.AliasTarget.IsNull() {
+		return
+	}
+	if ttl != nil {
+		m.TTL = types.Int64Value(*ttl)
+	}
+	if len(values) > 0 {
+		m.Value = types.StringValue(strings.Join(values, ","))
+	}
+}
+
+// azureRecordValues returns the values of an Azure record set of any of the
+// record types abstract_dns_record writes.
+func azureRecordValues(p *armdns.RecordSetProperties) []string {
+	var values []string
+	for _, a := range p.ARecords {
+		if a.IPv4Address != nil {
+			values = append(values, *a.IPv4Address)
+		}
+	}
+	for _, a := range p.AaaaRecords {
+		if a.IPv6Address != nil {
+			values = append(values, *a.IPv6Address)
+		}
+	}
+	if p.CnameRecord != nil && p.CnameRecord.Cname != nil {
+		values = append(values, *p.CnameRecord.Cname)
+	}
+	for _, t := range p.TxtRecords {
+		var txt strings.Builder
+		for _, s := range t.Value {
+			if s != nil {
+				txt.WriteString(*s)
+			}
+		}
+		values = append(values, txt.String())
+	}
+	return values
+}
+
+// awsRecordSet returns the record set in sets named fqdn of type rrType with
+// set identifier setID, or nil. ListResourceRecordSets starts at a name rather
+// than filtering on it, so the sets it returns may all be other records.
+func awsRecordSet(sets []r53types.ResourceRecordSet, fqdn, rrType, setID string) *r53types.ResourceRecordSet {
+	for i, rrs := range sets {
+		if strings.EqualFold(awsRecordName(aws.ToString(rrs.Name)), fqdn) && string(rrs.Type) == rrType && aws.ToString(rrs.SetIdentifier) == setID {
+			return &sets[i]
+		}
 	}
+	return nil
+}
+
+// awsRecordName undoes the \ooo octal escapes Route 53 returns names with,
+// such as \052 for the * of a wildcard record.
+func awsRecordName(name string) string {
+	if !strings.Contains(name, `\`) {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) {
+			if c, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+func (r *DNSRecordResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state dnsRecordResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
-	fqdn := state.Name.ValueString()
-	if !strings.HasSuffix(fqdn, state.Zone.ValueString()+".") {
-		fqdn = fqdn + "." + state.Zone.ValueString() + "."
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
 	}
+	fqdn := state.fqdn()
 	switch strings.ToLower(state.Type.ValueString()) {
 	case "aws":
-		if r.route53 == nil {
-			return
-		}
-		out, err := r.route53.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{DNSName: aws.String(state.Zone.ValueString())})
-		if err != nil || len(out.HostedZones) == 0 {
+		zoneID, err := r.awsZoneID(ctx, &state)
+		if err != nil {
 			resp.State.RemoveResource(ctx)
 			return
 		}
-		zoneID := aws.ToString(out.HostedZones[0].Id)
-		rsOut, err := r.route53.ListResourceRecordSets(ctx, &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zoneID), StartRecordName: aws.String(fqdn), StartRecordType: r53types.RRType(strings.ToUpper(state.Type.ValueString()))})
-		if err != nil || len(rsOut.ResourceRecordSets) == 0 {
-			resp.State.RemoveResource(ctx)
-			return
+		input := &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zoneID), StartRecordName: aws.String(fqdn), StartRecordType: r53types.RRType(state.rrType())}
+		if id := state.SetIdentifier.ValueString(); id != "" {
+			input.StartRecordIdentifier = aws.String(id)
 		}
-	case "azure":
-		if r.azureRecords == nil {
+		rsOut, err := r.route53.ListResourceRecordSets(ctx, input)
+		var rrs *r53types.ResourceRecordSet
+		if err == nil {
+			rrs = awsRecordSet(rsOut.ResourceRecordSets, fqdn, state.rrType(), state.SetIdentifier.ValueString())
+		}
+		if rrs == nil {
+			resp.State.RemoveResource(ctx)
 			return
 		}
-		rg := state.ResourceGroup.ValueString()
-		if rg == "" {
-			rg = "abstract-dns-rg"
+		var values []string
+		for _, rr := range rrs.ResourceRecords {
+			values = append(values, aws.ToString(rr.Value))
 		}
-		_, err := r.azureRecords.Get(ctx, rg, state.Zone.ValueString(), fqdn, armdns.RecordType(strings.ToUpper(state.Type.ValueString())), nil)
+		state.setRecord(rrs.TTL, values)
+		state.ID = types.StringValue(state.recordID(zoneID, fqdn))
+	case "azure":
+		rg, zone := state.azureZone()
+		out, err := r.azureRecords.Get(ctx, rg, zone, fqdn, armdns.RecordType(state.rrType()), nil)
 		if err != nil {
 			resp.State.RemoveResource(ctx)
 			return
 		}
+		if p := out.Properties; p != nil {
+			state.setRecord(p.TTL, azureRecordValues(p))
+		}
+		state.ID = types.StringValue(state.recordID(zone, fqdn))
 	case "gcp":
-		if r.gcpDNS == nil {
-			return
+		rsOut, err := r.gcpDNS.ResourceRecordSets.List(r.gcpProject, state.gcpZone()).Name(fqdn).Type(state.rrType()).Context(ctx).Do()
+		i := -1
+		if err == nil {
+			i = slices.IndexFunc(rsOut.Rrsets, func(rrs *dnsapi.ResourceRecordSet) bool {
+				return strings.EqualFold(rrs.Name, fqdn) && rrs.Type == state.rrType()
+			})
 		}
-		rsOut, err := r.gcpDNS.ResourceRecordSets.List(r.gcpProject, state.Zone.ValueString()).Name(fqdn).Type(strings.ToUpper(state.Type.ValueString())).Context(ctx).Do()
-		if err != nil || len(rsOut.Rrsets) == 0 {
+		if i < 0 {
 			resp.State.RemoveResource(ctx)
 			return
 		}
+		rrs := rsOut.Rrsets[i]
+		state.setRecord(&rrs.Ttl, rrs.Rrdatas)
+		state.ID = types.StringValue(state.recordID(state.gcpZone(), fqdn))
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	if servers, err := r.zoneNameServers(ctx, &state); err == nil {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name_servers"), nameServersValue(servers))...)
 	}
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) {
+		return r.cloudID(&state, fqdn), nil
+	})
 }
 
-func (r *DNSRecordResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	// simplified: delete then create
-	var plan struct {
-		Name  types.String `tfsdk:"name"`
-		Zone  types.String `tfsdk:"zone"`
-		Type  types.String `tfsdk:"type"`
-		Value types.String `tfsdk:"value"`
-		TTL   types.Int64  `tfsdk:"ttl"`
+// ImportState accepts "<type>:<zone>:<name>:<record type>", e.g.
+// "aws:example.com:www:A", where zone is the zone's domain. A Route 53 record
+// with a routing policy takes its set identifier as a fifth part.
+func (r *DNSRecordResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	parts := strings.Split(req.ID, ":")
+	if len(parts) < 4 || len(parts) > 5 || slices.Contains(parts, "") || !slices.Contains([]string{"aws", "azure", "gcp"}, parts[0]) ||
+		(len(parts) == 5 && parts[0] != "aws") {
+		resp.Diagnostics.AddError("invalid import id", fmt.Sprintf("expected <aws|azure|gcp>:<zone>:<name>:<record type>, with :<set identifier> for aws routing policies, got %q", req.ID))
+		return
 	}
-	diags := req.Plan.Get(ctx, &plan)
-	resp.Diagnostics.Append(diags...)
+	recordType := strings.ToUpper(parts[3])
+	if !slices.Contains(dnsRecordTypes, recordType) {
+		resp.Diagnostics.AddError("invalid import id", fmt.Sprintf("record type %q is not one of %s", parts[3], strings.Join(dnsRecordTypes, ", ")))
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), req.ID)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("type"), parts[0])...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("zone"), strings.TrimSuffix(parts[1], "."))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), parts[2])...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("record_type"), recordType)...)
+	if len(parts) == 5 {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("set_identifier"), parts[4])...)
+	}
+}
+
+// sameRecordSet reports whether a and b name the same record set, which
+// Update can then change in place.
+func sameRecordSet(a, b *dnsRecordResourceModel) bool {
+	return strings.EqualFold(a.Type.ValueString(), b.Type.ValueString()) && a.ZoneID.Equal(b.ZoneID) &&
+		strings.TrimSuffix(a.Zone.ValueString(), ".") == strings.TrimSuffix(b.Zone.ValueString(), ".") && a.fqdn() == b.fqdn() && a.rrType() == b.rrType() && a.SetIdentifier.Equal(b.SetIdentifier)
+}
+
+// Update rewrites the record in place. Only a record that moves to another
+// cloud, zone, name, record type or set identifier is deleted and created
+// again.
+func (r *DNSRecordResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state dnsRecordResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	if sameRecordSet(&plan, &state) {
+		r.put(ctx, &plan, true, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+		return
+	}
 	delReq := resource.DeleteRequest{State: req.State}
 	delResp := &resource.DeleteResponse{}
 	r.Delete(ctx, delReq, delResp)
@@ -284,64 +819,58 @@ func (r *DNSRecordResource) Update(ctx context.Context, req resource.UpdateReque
 		return
 	}
 	createReq := resource.CreateRequest{Plan: req.Plan}
-	createResp := &resource.CreateResponse{}
+	createResp := &resource.CreateResponse{State: resp.State}
 	r.Create(ctx, createReq, createResp)
 	resp.Diagnostics.Append(createResp.Diagnostics...)
+	resp.State = createResp.State
 }
 
 func (r *DNSRecordResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		Zone          types.String `tfsdk:"zone"`
-		Name          types.String `tfsdk:"name"`
-		Type          types.String `tfsdk:"type"`
-		ResourceGroup types.String `tfsdk:"resource_group"`
-	}
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state dnsRecordResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
-	fqdn := state.Name.ValueString()
-	if !strings.HasSuffix(fqdn, state.Zone.ValueString()+".") {
-		fqdn = fqdn + "." + state.Zone.ValueString() + "."
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
 	}
+	fqdn := state.fqdn()
 	switch strings.ToLower(state.Type.ValueString()) {
 	case "aws":
-		if r.route53 == nil {
+		zoneID, err := r.awsZoneID(ctx, &state)
+		if err != nil {
 			return
 		}
-		out, err := r.route53.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{DNSName: aws.String(state.Zone.ValueString())})
-		if err != nil || len(out.HostedZones) == 0 {
-			return
+		rrs := &r53types.ResourceRecordSet{
+			Name:            aws.String(fqdn),
+			Type:            r53types.RRType(state.rrType()),
+			TTL:             aws.Int64(300),
+			ResourceRecords: []r53types.ResourceRecord{{Value: aws.String("")}},
+		}
+		// Route 53 deletes the record set that matches exactly, routing included
+		state.awsRouting(rrs)
+		if lb := state.AliasTarget.ValueString(); lb != "" {
+			if rrs.AliasTarget, err = r.awsAlias(ctx, lb); err != nil {
+				return
+			}
+			rrs.TTL, rrs.ResourceRecords = nil, nil
 		}
-		zoneID := aws.ToString(out.HostedZones[0].Id)
 		_, err = r.route53.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
 			HostedZoneId: aws.String(zoneID),
 			ChangeBatch: &r53types.ChangeBatch{Changes: []r53types.Change{{
-				Action: r53types.ChangeActionDelete,
-				ResourceRecordSet: &r53types.ResourceRecordSet{
-					Name:            aws.String(fqdn),
-					Type:            r53types.RRType(strings.ToUpper(state.Type.ValueString())),
-					TTL:             aws.Int64(300),
-					ResourceRecords: []r53types.ResourceRecord{{Value: aws.String("")}},
-				},
+				Action:            r53types.ChangeActionDelete,
+				ResourceRecordSet: rrs,
 			}}},
 		})
 		_ = err
 	case "azure":
-		if r.azureRecords == nil {
-			return
-		}
-		rg := state.ResourceGroup.ValueString()
-		if rg == "" {
-			rg = "abstract-dns-rg"
-		}
-		_, _ = r.azureRecords.Delete(ctx, rg, state.Zone.ValueString(), fqdn, armdns.RecordType(strings.ToUpper(state.Type.ValueString())), nil)
+		rg, zone := state.azureZone()
+		_, _ = r.azureRecords.Delete(ctx, rg, zone, fqdn, armdns.RecordType(state.rrType()), nil)
 	case "gcp":
-		if r.gcpDNS == nil {
-			return
-		}
-		change := &dnsapi.Change{Deletions: []*dnsapi.ResourceRecordSet{{Name: fqdn, Type: strings.ToUpper(state.Type.ValueString()), Ttl: 300, Rrdatas: []string{}}}}
-		_, _ = r.gcpDNS.Changes.Create(r.gcpProject, state.Zone.ValueString(), change).Context(ctx).Do()
+		change := &dnsapi.Change{Deletions: []*dnsapi.ResourceRecordSet{{Name: fqdn, Type: state.rrType(), Ttl: 300, Rrdatas: []string{}}}}
+		_, _ = r.gcpDNS.Changes.Create(r.gcpProject, state.gcpZone(), change).Context(ctx).Do()
 	}
 }
@@ -0,0 +1,392 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	r53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"google.golang.org/api/compute/v1"
+	dnsapi "google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+)
+
+func TestDNSRecordRoutingConfig(t *testing.T) {
+	r := &DNSRecordResource{}
+	s := testSchema(t, r)
+	record := func(cloud string, extra map[string]tftypes.Value) map[string]tftypes.Value {
+		vals := map[string]tftypes.Value{"type": str(cloud), "name": str("www"), "zone": str("example.com"), "value": str("203.0.113.10")}
+		maps.Copy(vals, extra)
+		return vals
+	}
+	cases := []struct {
+		name string
+		vals map[string]tftypes.Value
+		errs bool
+	}{
+		{"simple", record("aws", nil), false},
+		{"simple with weight", record("aws", map[string]tftypes.Value{"weight": number(10)}), true},
+		{"weighted", record("aws", map[string]tftypes.Value{"routing_policy": str("weighted"), "set_identifier": str("blue"), "weight": number(90)}), false},
+		{"weighted without set_identifier", record("aws", map[string]tftypes.Value{"routing_policy": str("weighted"), "weight": number(90)}), true},
+		{"weighted without weight", record("aws", map[string]tftypes.Value{"routing_policy": str("weighted"), "set_identifier": str("blue")}), true},
+		{"weight too large", record("aws", map[string]tftypes.Value{"routing_policy": str("weighted"), "set_identifier": str("blue"), "weight": number(256)}), true},
+		{"latency", record("aws", map[string]tftypes.Value{"routing_policy": str("latency"), "set_identifier": str("eu"), "latency_region": str("eu-west-1")}), false},
+		{"latency with weight", record("aws", map[string]tftypes.Value{"routing_policy": str("latency"), "set_identifier": str("eu"), "latency_region": str("eu-west-1"), "weight": number(1)}), true},
+		{"geo continent", record("aws", map[string]tftypes.Value{"routing_policy": str("geo"), "set_identifier": str("eu"), "geo_location": str("continent:EU")}), false},
+		{"geo bad continent", record("aws", map[string]tftypes.Value{"routing_policy": str("geo"), "set_identifier": str("eu"), "geo_location": str("continent:XX")}), true},
+		{"unknown policy", record("aws", map[string]tftypes.Value{"routing_policy": str("failover"), "set_identifier": str("a")}), true},
+		{"azure weighted", record("azure", map[string]tftypes.Value{"routing_policy": str("weighted"), "set_identifier": str("blue"), "weight": number(90)}), true},
+		{"gcp geo", record("gcp", map[string]tftypes.Value{"routing_policy": str("geo"), "set_identifier": str("eu"), "geo_location": str("DE")}), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestAWSGeoLocation(t *testing.T) {
+	cases := []struct {
+		in                           string
+		continent, country, division string
+	}{
+		{"*", "", "*", ""},
+		{"DE", "", "DE", ""},
+		{"US-WA", "", "US", "WA"},
+		{"continent:AS", "AS", "", ""},
+	}
+	for _, tc := range cases {
+		loc, err := awsGeoLocation(tc.in)
+		if err != nil || aws.ToString(loc.ContinentCode) != tc.continent || aws.ToString(loc.CountryCode) != tc.country || aws.ToString(loc.SubdivisionCode) != tc.division {
+			t.Errorf("awsGeoLocation(%q) = %+v, %v", tc.in, loc, err)
+		}
+	}
+	for _, in := range []string{"de", "Germany", "US-", "continent:"} {
+		if _, err := awsGeoLocation(in); err == nil {
+			t.Errorf("awsGeoLocation(%q) accepted", in)
+		}
+	}
+}
+
+func TestDNSRecordAliasConfig(t *testing.T) {
+	r := &DNSRecordResource{}
+	s := testSchema(t, r)
+	cases := []struct {
+		name  string
+		vals  map[string]tftypes.Value
+		errs  bool
+		warns bool
+	}{
+		{"value", map[string]tftypes.Value{"type": str("aws"), "name": str("www"), "zone": str("example.com"), "value": str("203.0.113.10")}, false, false},
+		{"alias", map[string]tftypes.Value{"type": str("aws"), "name": str("example.com"), "zone": str("example.com"), "alias_target": str("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/web/50dc6c495c0c9188")}, false, false},
+		{"alias and value", map[string]tftypes.Value{"type": str("gcp"), "name": str("www"), "zone": str("example.com"), "value": str("203.0.113.10"), "alias_target": str("web")}, false, true},
+		{"neither", map[string]tftypes.Value{"type": str("azure"), "name": str("www"), "zone": str("example.com")}, true, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs || (resp.Diagnostics.WarningsCount() > 0) != tc.warns {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestDNSRecordAliasTargets(t *testing.T) {
+	if _, err := (&DNSRecordResource{}).awsAlias(context.Background(), "web"); err == nil {
+		t.Error("awsAlias accepted a name")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/projects/p/aggregated/addresses" {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, `{"items": {"regions/us-east1": {"addresses": [{"name": "web-ip", "address": "34.1.2.3"}]}, "regions/europe-west1": {}}}`)
+	}))
+	defer srv.Close()
+	gcp, err := compute.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &DNSRecordResource{gcpCompute: gcp, gcpProject: "p"}
+	if addr, err := r.gcpLBAddress(context.Background(), "web"); err != nil || addr != "34.1.2.3" {
+		t.Errorf("gcpLBAddress = %q, %v", addr, err)
+	}
+	if _, err := r.gcpLBAddress(context.Background(), "api"); err == nil {
+		t.Error("gcpLBAddress found a missing load balancer")
+	}
+}
+
+func TestDNSRecordRRType(t *testing.T) {
+	m := &dnsRecordResourceModel{Type: types.StringValue("aws"), RecordType: types.StringValue("cname"), AliasTarget: types.StringNull()}
+	if got := m.rrType(); got != "CNAME" {
+		t.Errorf("rrType = %q", got)
+	}
+	m.AliasTarget = types.StringValue("web")
+	if got := m.rrType(); got != "A" {
+		t.Errorf("alias rrType = %q", got)
+	}
+}
+
+func TestDNSRecordFQDN(t *testing.T) {
+	for _, tc := range []struct{ name, zone, want string }{
+		{"www", "example.com", "www.example.com."},
+		{"www.example.com", "example.com", "www.example.com."},
+		{"www.example.com.", "example.com", "www.example.com."},
+		{"example.com", "example.com", "example.com."},
+		{"api.example.com", "example.com.", "api.example.com."},
+		{"notexample.com", "example.com", "notexample.com.example.com."},
+	} {
+		m := dnsRecordResourceModel{Name: types.StringValue(tc.name), Zone: types.StringValue(tc.zone)}
+		if got := m.fqdn(); got != tc.want {
+			t.Errorf("fqdn(%q, %q) = %q, want %q", tc.name, tc.zone, got, tc.want)
+		}
+	}
+}
+
+func TestAWSRecordSet(t *testing.T) {
+	set := func(name string, typ r53types.RRType, id string) r53types.ResourceRecordSet {
+		rrs := r53types.ResourceRecordSet{Name: aws.String(name), Type: typ}
+		if id != "" {
+			rrs.SetIdentifier = aws.String(id)
+		}
+		return rrs
+	}
+	// what ListResourceRecordSets returns when starting at a missing www A record
+	after := []r53types.ResourceRecordSet{set("www.example.com.", r53types.RRTypeTxt, ""), set("xyz.example.com.", r53types.RRTypeA, "")}
+	if got := awsRecordSet(after, "www.example.com.", "A", ""); got != nil {
+		t.Errorf("matched %s %s", aws.ToString(got.Name), got.Type)
+	}
+	sets := []r53types.ResourceRecordSet{set("www.example.com.", r53types.RRTypeA, "blue"), set("www.example.com.", r53types.RRTypeA, "green")}
+	if got := awsRecordSet(sets, "www.example.com.", "A", "green"); got == nil || aws.ToString(got.SetIdentifier) != "green" {
+		t.Errorf("set identifier green = %v", got)
+	}
+	if got := awsRecordSet(sets, "www.example.com.", "A", ""); got != nil {
+		t.Errorf("simple record matched a weighted one")
+	}
+	if got := awsRecordSet([]r53types.ResourceRecordSet{set(`\052.Example.com.`, r53types.RRTypeCname, "")}, "*.example.com.", "CNAME", ""); got == nil {
+		t.Errorf("escaped wildcard not matched")
+	}
+}
+
+func TestDNSRecordImportState(t *testing.T) {
+	r := &DNSRecordResource{}
+	for _, tc := range []struct {
+		id   string
+		want map[string]string
+	}{
+		{"aws:example.com:www:a", map[string]string{"type": "aws", "zone": "example.com", "name": "www", "record_type": "A"}},
+		{"aws:example.com:www:A:blue", map[string]string{"name": "www", "set_identifier": "blue"}},
+		{"gcp:example.com.:api:CNAME", map[string]string{"type": "gcp", "zone": "example.com", "record_type": "CNAME"}},
+		{"azure:example.com:www", nil},
+		{"azure:example.com:www:A:blue", nil},
+		{"aws:example.com:www:MX", nil},
+		{"oci:example.com:www:A", nil},
+		{"aws::www:A", nil},
+	} {
+		t.Run(tc.id, func(t *testing.T) {
+			resp := &resource.ImportStateResponse{State: testState(t, r, nil)}
+			r.ImportState(context.Background(), resource.ImportStateRequest{ID: tc.id}, resp)
+			if tc.want == nil {
+				if !resp.Diagnostics.HasError() {
+					t.Errorf("accepted %q", tc.id)
+				}
+				return
+			}
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("import: %v", resp.Diagnostics)
+			}
+			var got dnsRecordResourceModel
+			resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+			attrs := map[string]string{"type": got.Type.ValueString(), "zone": got.Zone.ValueString(), "name": got.Name.ValueString(),
+				"record_type": got.RecordType.ValueString(), "set_identifier": got.SetIdentifier.ValueString()}
+			for k, v := range tc.want {
+				if attrs[k] != v {
+					t.Errorf("%s = %q, want %q", k, attrs[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestDNSRecordSetRecord(t *testing.T) {
+	m := dnsRecordResourceModel{TTL: types.Int64Value(300), Value: types.StringValue("203.0.113.10"), AliasTarget: types.StringNull()}
+	m.setRecord(aws.Int64(60), []string{"203.0.113.20"})
+	if m.TTL.ValueInt64() != 60 || m.Value.ValueString() != "203.0.113.20" {
+		t.Errorf("changed record = %v, %v", m.TTL, m.Value)
+	}
+	m.setRecord(nil, []string{"203.0.113.20", "203.0.113.21"})
+	if m.TTL.ValueInt64() != 60 || m.Value.ValueString() != "203.0.113.20,203.0.113.21" {
+		t.Errorf("record with two values = %v, %v", m.TTL, m.Value)
+	}
+	alias := dnsRecordResourceModel{TTL: types.Int64Value(300), Value: types.StringNull(), AliasTarget: types.StringValue("web")}
+	alias.setRecord(aws.Int64(60), []string{"web-123.eu-west-1.elb.amazonaws.com."})
+	if alias.TTL.ValueInt64() != 300 || !alias.Value.IsNull() {
+		t.Errorf("alias record = %v, %v", alias.TTL, alias.Value)
+	}
+}
+
+func TestDNSRecordReadAzureDrift(t *testing.T) {
+	const set = "/subscriptions/sub/resourceGroups/abstract-dns-rg/providers/Microsoft.Network/dnsZones/example.com/A/www.example.com."
+	arm := newFakeARM()
+	arm.notFound = true
+	r := &DNSRecordResource{}
+	var err error
+	if r.azureRG, err = armresources.NewResourceGroupsClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	if r.azureZones, err = armdns.NewZonesClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	if r.azureRecords, err = armdns.NewRecordSetsClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	// changed in the portal since the last apply
+	arm.puts[set] = map[string]any{"properties": map[string]any{"TTL": 60, "ARecords": []any{map[string]any{"ipv4Address": "203.0.113.99"}}}}
+	state := testState(t, r, map[string]tftypes.Value{"type": str("azure"), "zone": str("example.com"), "name": str("www"), "record_type": str("A"),
+		"value": str("203.0.113.10"), "ttl": number(300)})
+	resp := &resource.ReadResponse{State: state}
+	r.Read(context.Background(), resource.ReadRequest{State: state}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("read: %v", resp.Diagnostics)
+	}
+	var got dnsRecordResourceModel
+	resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+	if got.TTL.ValueInt64() != 60 || got.Value.ValueString() != "203.0.113.99" || got.ID.ValueString() != "example.com/www.example.com." {
+		t.Errorf("state = ttl %v, value %v, id %v", got.TTL, got.Value, got.ID)
+	}
+}
+
+// fakeCloudDNS serves the record sets of one Cloud DNS zone and applies each
+// change atomically, as Cloud DNS does.
+type fakeCloudDNS struct {
+	rrsets  map[string]*dnsapi.ResourceRecordSet
+	changes int
+	// gap is set once a request left www.example.com. without a record set
+	gap bool
+}
+
+func (f *fakeCloudDNS) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	const zone = "/dns/v1/projects/p/managedZones/example.com"
+	defer func() {
+		if f.rrsets["www.example.com./A"] == nil {
+			f.gap = true
+		}
+	}()
+	switch {
+	case req.URL.Path == zone:
+		fmt.Fprint(w, `{"name": "example.com", "nameServers": ["ns-cloud-a1.googledomains.com."]}`)
+	case req.URL.Path == zone+"/rrsets":
+		var out dnsapi.ResourceRecordSetsListResponse
+		if rrs := f.rrsets[req.URL.Query().Get("name")+"/"+req.URL.Query().Get("type")]; rrs != nil {
+			out.Rrsets = append(out.Rrsets, rrs)
+		}
+		json.NewEncoder(w).Encode(out)
+	case req.URL.Path == zone+"/changes" && req.Method == http.MethodPost:
+		var change dnsapi.Change
+		json.NewDecoder(req.Body).Decode(&change)
+		next := maps.Clone(f.rrsets)
+		for _, d := range change.Deletions {
+			delete(next, d.Name+"/"+d.Type)
+		}
+		for _, a := range change.Additions {
+			if next[a.Name+"/"+a.Type] != nil {
+				http.Error(w, `{"error": {"code": 409, "message": "already exists"}}`, http.StatusConflict)
+				return
+			}
+			next[a.Name+"/"+a.Type] = a
+		}
+		f.rrsets = next
+		f.changes++
+		json.NewEncoder(w).Encode(change)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestDNSRecordUpdateInPlace(t *testing.T) {
+	fake := &fakeCloudDNS{rrsets: map[string]*dnsapi.ResourceRecordSet{
+		"www.example.com./A": {Name: "www.example.com.", Type: "A", Ttl: 300, Rrdatas: []string{"203.0.113.10"}},
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	gcp, err := dnsapi.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &DNSRecordResource{gcpDNS: gcp, gcpProject: "p"}
+	vals := map[string]tftypes.Value{"type": str("gcp"), "zone": str("example.com"), "name": str("www"), "record_type": str("A"), "value": str("203.0.113.10"), "ttl": number(300)}
+	state := testState(t, r, vals)
+	vals["value"], vals["ttl"] = str("203.0.113.20"), number(60)
+	resp := &resource.UpdateResponse{State: state}
+	r.Update(context.Background(), resource.UpdateRequest{Plan: testPlan(t, r, vals), State: state}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("update: %v", resp.Diagnostics)
+	}
+	if fake.gap || fake.changes != 1 {
+		t.Errorf("update left the name unresolved: gap %v after %d changes", fake.gap, fake.changes)
+	}
+	if rrs := fake.rrsets["www.example.com./A"]; rrs == nil || rrs.Ttl != 60 || !slices.Equal(rrs.Rrdatas, []string{"203.0.113.20"}) {
+		t.Errorf("record set = %+v", rrs)
+	}
+
+	// a new name is a different record set: the old one goes
+	vals["name"] = str("api")
+	resp = &resource.UpdateResponse{State: resp.State}
+	r.Update(context.Background(), resource.UpdateRequest{Plan: testPlan(t, r, vals), State: resp.State}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("rename: %v", resp.Diagnostics)
+	}
+	if fake.rrsets["api.example.com./A"] == nil || fake.rrsets["www.example.com./A"] != nil {
+		t.Errorf("record sets after rename = %v", slices.Collect(maps.Keys(fake.rrsets)))
+	}
+}
+
+func TestSameRecordSet(t *testing.T) {
+	base := func() *dnsRecordResourceModel {
+		return &dnsRecordResourceModel{Type: types.StringValue("aws"), Zone: types.StringValue("example.com"), ZoneID: types.StringNull(), Name: types.StringValue("www"),
+			RecordType: types.StringValue("A"), AliasTarget: types.StringNull(), SetIdentifier: types.StringNull(), Value: types.StringValue("203.0.113.10")}
+	}
+	other := base()
+	other.Value, other.TTL = types.StringValue("203.0.113.20"), types.Int64Value(60)
+	if !sameRecordSet(base(), other) {
+		t.Error("value change is not in place")
+	}
+	other = base()
+	other.Name = types.StringValue("www.example.com")
+	if !sameRecordSet(base(), other) {
+		t.Error("same fqdn spelled out is not in place")
+	}
+	for name, change := range map[string]func(*dnsRecordResourceModel){
+		"name": func(m *dnsRecordResourceModel) { m.Name = types.StringValue("api") },
+		"zone": func(m *dnsRecordResourceModel) {
+			m.Zone, m.Name = types.StringValue("www.example.com"), types.StringValue("www.example.com")
+		},
+		"record_type":    func(m *dnsRecordResourceModel) { m.RecordType = types.StringValue("AAAA") },
+		"set_identifier": func(m *dnsRecordResourceModel) { m.SetIdentifier = types.StringValue("blue") },
+		"cloud":          func(m *dnsRecordResourceModel) { m.Type = types.StringValue("gcp") },
+	} {
+		other := base()
+		change(other)
+		if sameRecordSet(base(), other) {
+			t.Errorf("%s change is in place", name)
+		}
+	}
+}
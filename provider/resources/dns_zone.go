@@ -0,0 +1,381 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	r53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	dnsapi "google.golang.org/api/dns/v1"
+)
+
+// azureDNSResourceGroup holds the Azure DNS zones and records.
+const azureDNSResourceGroup = "abstract-dns-rg"
+
+// DNSZoneResource manages a DNS zone: a Route 53 hosted zone on AWS, an Azure
+// DNS zone or a Cloud DNS managed zone on GCP. A private zone only resolves
+// inside the network it is associated with.
+type DNSZoneResource struct {
+	route53 *route53.Client
+
+	azureRG    *armresources.ResourceGroupsClient
+	azureZones *armdns.ZonesClient
+	azureLoc   string
+
+	gcpDNS     *dnsapi.Service
+	gcpProject string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	ids *shared.CloudIDs
+}
+
+type dnsZoneResourceModel struct {
+	ID          types.String `tfsdk:"id"`
+	CloudID     types.String `tfsdk:"cloud_id"`
+	Type        types.String `tfsdk:"type"`
+	Name        types.String `tfsdk:"name"`
+	Region      types.String `tfsdk:"region"`
+	Private     types.Bool   `tfsdk:"private"`
+	NetworkID   types.String `tfsdk:"network_id"`
+	NameServers types.List   `tfsdk:"name_servers"`
+}
+
+func NewDNSZoneResource() resource.Resource { return &DNSZoneResource{} }
+
+func (r *DNSZoneResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.ids = cfg.CloudIDs
+	r.route53 = cfg.AWSRoute53
+	r.azureRG = cfg.AzureRGClient
+	r.azureZones = cfg.AzureDNSZoneClient
+	r.azureLoc = cfg.AzureLocation
+	r.gcpDNS = cfg.GCPDNS
+	r.gcpProject = cfg.GCPProject
+}
+
+func (r *DNSZoneResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_dns_zone"
+}
+
+func (r *DNSZoneResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	computed := []planmodifier.String{stringplanmodifier.UseStateForUnknown()}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			// Route 53 hosted zone ID, Azure DNS zone resource ID or Cloud DNS managed zone name.
+			"id":       schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			"cloud_id": cloudIDAttribute(),
+			"type":     schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// The domain, such as example.com.
+			"name": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// Azure location of the zones' resource group, or the AWS region of network_id.
+			"region":  schema.StringAttribute{Optional: true, PlanModifiers: replace},
+			"private": schema.BoolAttribute{Optional: true, PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()}},
+			// An abstract_network's id a private zone resolves in.
+			"network_id": schema.StringAttribute{Optional: true, PlanModifiers: replace},
+			// The name servers to delegate the domain to; empty for private zones on AWS and GCP.
+			"name_servers": schema.ListAttribute{ElementType: types.StringType, Computed: true, PlanModifiers: []planmodifier.List{listplanmodifier.UseStateForUnknown()}},
+		},
+	}
+}
+
+func (r *DNSZoneResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg dnsZoneResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() || cfg.Private.IsUnknown() || cfg.NetworkID.IsUnknown() {
+		return
+	}
+	switch {
+	case cfg.Private.ValueBool() && cfg.NetworkID.IsNull():
+		resp.Diagnostics.AddAttributeError(path.Root("network_id"), "missing network_id", "a private zone needs the network it resolves in")
+	case !cfg.Private.ValueBool() && !cfg.NetworkID.IsNull():
+		resp.Diagnostics.AddAttributeError(path.Root("network_id"), "public zone", "network_id only applies to private zones; set private = true")
+	case !cfg.NetworkID.IsNull() && !cfg.Type.IsUnknown():
+		if msg := checkNetworkID(cfg.Type.ValueString(), cfg.NetworkID.ValueString()); msg != "" {
+			resp.Diagnostics.AddAttributeError(path.Root("network_id"), "network of another cloud", fmt.Sprintf("%q is not a %s network: %s", cfg.NetworkID.ValueString(), cfg.Type.ValueString(), msg))
+		}
+	}
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *DNSZoneResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.route53 != nil
+	case "azure":
+		return r.azureRG != nil && r.azureZones != nil
+	case "gcp":
+		return r.gcpDNS != nil
+	}
+	return false
+}
+
+// gcpManagedZoneName derives a Cloud DNS managed zone name from a domain:
+// "example.com" becomes "example-com".
+func gcpManagedZoneName(domain string) string {
+	name := strings.ReplaceAll(strings.TrimSuffix(strings.ToLower(domain), "."), ".", "-")
+	if name != "" && (name[0] < 'a' || name[0] > 'z') {
+		name = "zone-" + name
+	}
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// azureZoneRef splits an Azure DNS zone resource ID into its resource group
+// and name. A bare zone name is in the provider's DNS resource group.
+func azureZoneRef(id string) (rg, name string) {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	if len(parts) == 8 && strings.EqualFold(parts[2], "resourceGroups") && strings.EqualFold(parts[6], "dnszones") {
+		return parts[3], parts[7]
+	}
+	return azureDNSResourceGroup, id
+}
+
+// nameServersValue is servers as a name_servers list; nil is an empty list.
+func nameServersValue(servers []string) types.List {
+	values := []attr.Value{}
+	for _, s := range servers {
+		values = append(values, types.StringValue(s))
+	}
+	return types.ListValueMust(types.StringType, values)
+}
+
+func (r *DNSZoneResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan dnsZoneResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.CloudID = types.StringNull()
+	var servers []string
+	var err error
+	switch plan.Type.ValueString() {
+	case "aws":
+		servers, err = r.createAWS(ctx, &plan)
+	case "azure":
+		servers, err = r.createAzure(ctx, &plan)
+	case "gcp":
+		servers, err = r.createGCP(ctx, &plan)
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(plan.Type.ValueString()+" create dns zone", err.Error())
+		return
+	}
+	plan.NameServers = nameServersValue(servers)
+	id, err := r.cloudID(&plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *DNSZoneResource) createAWS(ctx context.Context, plan *dnsZoneResourceModel) ([]string, error) {
+	input := &route53.CreateHostedZoneInput{
+		Name:            aws.String(plan.Name.ValueString()),
+		CallerReference: aws.String(fmt.Sprintf("%s-%d", plan.Name.ValueString(), time.Now().UnixNano())),
+	}
+	if plan.Private.ValueBool() {
+		region := plan.Region.ValueString()
+		if region == "" {
+			region = r.route53.Options().Region
+		}
+		input.HostedZoneConfig = &r53types.HostedZoneConfig{PrivateZone: true}
+		input.VPC = &r53types.VPC{VPCId: aws.String(plan.NetworkID.ValueString()), VPCRegion: r53types.VPCRegion(region)}
+	}
+	out, err := r.route53.CreateHostedZone(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	plan.ID = types.StringValue(strings.TrimPrefix(aws.ToString(out.HostedZone.Id), "/hostedzone/"))
+	if out.DelegationSet == nil {
+		return nil, nil
+	}
+	return out.DelegationSet.NameServers, nil
+}
+
+func (r *DNSZoneResource) createAzure(ctx context.Context, plan *dnsZoneResourceModel) ([]string, error) {
+	loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+	if err := shared.EnsureResourceGroup(ctx, r.azureRG, azureDNSResourceGroup, loc); err != nil {
+		return nil, err
+	}
+	props := &armdns.ZoneProperties{ZoneType: to.Ptr(armdns.ZoneTypePublic)}
+	if plan.Private.ValueBool() {
+		props.ZoneType = to.Ptr(armdns.ZoneTypePrivate)
+		props.ResolutionVirtualNetworks = []*armdns.SubResource{{ID: to.Ptr(plan.NetworkID.ValueString())}}
+	}
+	out, err := r.azureZones.CreateOrUpdate(ctx, azureDNSResourceGroup, strings.TrimSuffix(plan.Name.ValueString(), "."), armdns.Zone{Location: to.Ptr("global"), Properties: props}, nil)
+	if err != nil {
+		return nil, err
+	}
+	plan.ID = types.StringValue(*out.ID)
+	return azureNameServers(out.Zone), nil
+}
+
+func azureNameServers(zone armdns.Zone) []string {
+	var servers []string
+	if zone.Properties != nil {
+		for _, s := range zone.Properties.NameServers {
+			servers = append(servers, *s)
+		}
+	}
+	return servers
+}
+
+func (r *DNSZoneResource) createGCP(ctx context.Context, plan *dnsZoneResourceModel) ([]string, error) {
+	zone := &dnsapi.ManagedZone{
+		Name:        gcpManagedZoneName(plan.Name.ValueString()),
+		DnsName:     strings.TrimSuffix(plan.Name.ValueString(), ".") + ".",
+		Description: "Managed by Terraform",
+		Visibility:  "public",
+	}
+	if plan.Private.ValueBool() {
+		network := plan.NetworkID.ValueString()
+		if !strings.Contains(network, "/") {
+			network = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/global/networks/%s", r.gcpProject, network)
+		}
+		zone.Visibility = "private"
+		zone.PrivateVisibilityConfig = &dnsapi.ManagedZonePrivateVisibilityConfig{
+			Networks: []*dnsapi.ManagedZonePrivateVisibilityConfigNetwork{{NetworkUrl: network}},
+		}
+	}
+	out, err := r.gcpDNS.ManagedZones.Create(r.gcpProject, zone).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	plan.ID = types.StringValue(out.Name)
+	if plan.Private.ValueBool() {
+		// private zones are only answered inside the network
+		return nil, nil
+	}
+	return out.NameServers, nil
+}
+
+// cloudID is the hosted zone's ARN, the DNS zone's resource ID or the managed
+// zone's full name.
+func (r *DNSZoneResource) cloudID(m *dnsZoneResourceModel) (string, error) {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.AccountlessARN("route53", false, "hostedzone/"+id), nil
+	case "azure":
+		return id, nil
+	case "gcp":
+		return r.ids.GCPName("managedZones/" + id), nil
+	}
+	return "", nil
+}
+
+func (r *DNSZoneResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state dnsZoneResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	var servers []string
+	var err error
+	switch state.Type.ValueString() {
+	case "aws":
+		var out *route53.GetHostedZoneOutput
+		out, err = r.route53.GetHostedZone(ctx, &route53.GetHostedZoneInput{Id: aws.String(state.ID.ValueString())})
+		if err == nil && out.DelegationSet != nil {
+			servers = out.DelegationSet.NameServers
+		}
+	case "azure":
+		rg, name := azureZoneRef(state.ID.ValueString())
+		var out armdns.ZonesClientGetResponse
+		out, err = r.azureZones.Get(ctx, rg, name, nil)
+		if err == nil {
+			servers = azureNameServers(out.Zone)
+		}
+	case "gcp":
+		var out *dnsapi.ManagedZone
+		out, err = r.gcpDNS.ManagedZones.Get(r.gcpProject, state.ID.ValueString()).Context(ctx).Do()
+		if err == nil && out.Visibility != "private" {
+			servers = out.NameServers
+		}
+	}
+	if err != nil {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	state.NameServers = nameServersValue(servers)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(&state) })
+}
+
+// Update has nothing to do: every attribute replaces the zone.
+func (r *DNSZoneResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+}
+
+// Delete removes the zone. The clouds refuse to delete a zone that still has
+// records besides its own SOA and NS records.
+func (r *DNSZoneResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state dnsZoneResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	var err error
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err = r.route53.DeleteHostedZone(ctx, &route53.DeleteHostedZoneInput{Id: aws.String(state.ID.ValueString())})
+	case "azure":
+		rg, name := azureZoneRef(state.ID.ValueString())
+		poller, perr := r.azureZones.BeginDelete(ctx, rg, name, nil)
+		err = perr
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+	case "gcp":
+		err = r.gcpDNS.ManagedZones.Delete(r.gcpProject, state.ID.ValueString()).Context(ctx).Do()
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(state.Type.ValueString()+" delete dns zone", err.Error())
+	}
+}
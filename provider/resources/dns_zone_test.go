@@ -0,0 +1,107 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	dnsapi "google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+)
+
+func TestDNSZoneConfig(t *testing.T) {
+	r := &DNSZoneResource{}
+	s := testSchema(t, r)
+	cases := []struct {
+		name string
+		vals map[string]tftypes.Value
+		errs bool
+	}{
+		{"public", map[string]tftypes.Value{"type": str("aws"), "name": str("example.com")}, false},
+		{"private", map[string]tftypes.Value{"type": str("aws"), "name": str("internal.example.com"), "private": boolean(true), "network_id": str("vpc-0123456789abcdef0")}, false},
+		{"private without network", map[string]tftypes.Value{"type": str("gcp"), "name": str("internal.example.com"), "private": boolean(true)}, true},
+		{"public with network", map[string]tftypes.Value{"type": str("gcp"), "name": str("example.com"), "network_id": str("main")}, true},
+		{"network of another cloud", map[string]tftypes.Value{"type": str("aws"), "name": str("internal.example.com"), "private": boolean(true), "network_id": str("/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Network/virtualNetworks/main")}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestGCPManagedZoneName(t *testing.T) {
+	for domain, want := range map[string]string{
+		"example.com":       "example-com",
+		"Internal.Corp.":    "internal-corp",
+		"1password.example": "zone-1password-example",
+	} {
+		if got := gcpManagedZoneName(domain); got != want {
+			t.Errorf("gcpManagedZoneName(%q) = %q, want %q", domain, got, want)
+		}
+	}
+}
+
+func TestAzureZoneRef(t *testing.T) {
+	rg, name := azureZoneRef("/subscriptions/s/resourceGroups/dns-rg/providers/Microsoft.Network/dnszones/example.com")
+	if rg != "dns-rg" || name != "example.com" {
+		t.Errorf("azureZoneRef = %q, %q", rg, name)
+	}
+	rg, name = azureZoneRef("example.com")
+	if rg != azureDNSResourceGroup || name != "example.com" {
+		t.Errorf("azureZoneRef(bare name) = %q, %q", rg, name)
+	}
+}
+
+func TestDNSRecordZoneID(t *testing.T) {
+	m := &dnsRecordResourceModel{Zone: types.StringValue("example.com"), ZoneID: types.StringValue("/subscriptions/s/resourceGroups/dns-rg/providers/Microsoft.Network/dnszones/example.com")}
+	if rg, zone := m.azureZone(); rg != "dns-rg" || zone != "example.com" {
+		t.Errorf("azureZone = %q, %q", rg, zone)
+	}
+	m = &dnsRecordResourceModel{Zone: types.StringValue("example.com"), ZoneID: types.StringValue("example-com")}
+	if got := m.gcpZone(); got != "example-com" {
+		t.Errorf("gcpZone = %q", got)
+	}
+}
+
+func TestDNSRecordNameServers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/dns/v1/projects/p/managedZones/example-com":
+			fmt.Fprint(w, `{"name": "example-com", "visibility": "public", "nameServers": ["ns-cloud-a1.googledomains.com.", "ns-cloud-a2.googledomains.com."]}`)
+		case "/dns/v1/projects/p/managedZones/internal":
+			fmt.Fprint(w, `{"name": "internal", "visibility": "private", "nameServers": ["ns-gcp-private.googledomains.com."]}`)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+	gcp, err := dnsapi.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &DNSRecordResource{gcpDNS: gcp, gcpProject: "p"}
+	m := &dnsRecordResourceModel{Type: types.StringValue("gcp"), Zone: types.StringValue("example.com"), ZoneID: types.StringValue("example-com")}
+	servers, err := r.zoneNameServers(context.Background(), m)
+	if err != nil || len(servers) != 2 || servers[0] != "ns-cloud-a1.googledomains.com." {
+		t.Errorf("name servers = %v, %v", servers, err)
+	}
+	m.ZoneID = types.StringValue("internal")
+	if servers, err := r.zoneNameServers(context.Background(), m); err != nil || len(servers) != 0 {
+		t.Errorf("private zone name servers = %v, %v", servers, err)
+	}
+	m.ZoneID = types.StringValue("missing")
+	if _, err := r.zoneNameServers(context.Background(), m); err == nil {
+		t.Error("missing zone accepted")
+	}
+}
@@ -0,0 +1,711 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+var errNotFound = errors.New("not found")
+
+// fakeS3 is an in-memory shared.S3Buckets. calls records the bucket configuration
+// operations in order so tests can check which settings were touched.
+type fakeS3 struct {
+	buckets    map[string]*s3.CreateBucketInput
+	versioning map[string]s3types.BucketVersioningStatus
+	tags       map[string][]s3types.Tag
+	kmsKeys    map[string]string
+	expiration map[string]int32
+	calls      []string
+	err        error
+	// versioningErr fails PutBucketVersioning.
+	versioningErr error
+
+	publicAccess map[string]*s3types.PublicAccessBlockConfiguration
+	// objects holds the object keys in each bucket.
+	objects map[string][]string
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		buckets:    map[string]*s3.CreateBucketInput{},
+		versioning: map[string]s3types.BucketVersioningStatus{},
+		tags:       map[string][]s3types.Tag{},
+		kmsKeys:    map[string]string{},
+		expiration: map[string]int32{},
+
+		publicAccess: map[string]*s3types.PublicAccessBlockConfiguration{},
+		objects:      map[string][]string{},
+	}
+}
+
+func (f *fakeS3) CreateBucket(ctx context.Context, in *s3.CreateBucketInput, _ ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if c := in.CreateBucketConfiguration; c != nil && (c.LocationConstraint == "" || c.LocationConstraint == "us-east-1") {
+		return nil, errors.New("InvalidLocationConstraint")
+	}
+	f.buckets[aws.ToString(in.Bucket)] = in
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func (f *fakeS3) PutBucketVersioning(ctx context.Context, in *s3.PutBucketVersioningInput, _ ...func(*s3.Options)) (*s3.PutBucketVersioningOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.versioningErr != nil {
+		return nil, f.versioningErr
+	}
+	f.calls = append(f.calls, "PutBucketVersioning")
+	f.versioning[aws.ToString(in.Bucket)] = in.VersioningConfiguration.Status
+	return &s3.PutBucketVersioningOutput{}, nil
+}
+
+func (f *fakeS3) HeadBucket(ctx context.Context, in *s3.HeadBucketInput, _ ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	if _, ok := f.buckets[aws.ToString(in.Bucket)]; !ok {
+		return nil, errNotFound
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (f *fakeS3) GetBucketLocation(ctx context.Context, in *s3.GetBucketLocationInput, _ ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	b, ok := f.buckets[aws.ToString(in.Bucket)]
+	if !ok {
+		return nil, errNotFound
+	}
+	out := &s3.GetBucketLocationOutput{}
+	if b.CreateBucketConfiguration != nil {
+		out.LocationConstraint = b.CreateBucketConfiguration.LocationConstraint
+	}
+	return out, nil
+}
+
+func (f *fakeS3) DeleteBucket(ctx context.Context, in *s3.DeleteBucketInput, _ ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
+	if _, ok := f.buckets[aws.ToString(in.Bucket)]; !ok {
+		return nil, errNotFound
+	}
+	if len(f.objects[aws.ToString(in.Bucket)]) > 0 {
+		return nil, &smithy.GenericAPIError{Code: "BucketNotEmpty"}
+	}
+	delete(f.buckets, aws.ToString(in.Bucket))
+	return &s3.DeleteBucketOutput{}, nil
+}
+
+func (f *fakeS3) ListObjectVersions(ctx context.Context, in *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	out := &s3.ListObjectVersionsOutput{IsTruncated: aws.Bool(false)}
+	for _, key := range f.objects[aws.ToString(in.Bucket)] {
+		out.Versions = append(out.Versions, s3types.ObjectVersion{Key: aws.String(key), VersionId: aws.String("null")})
+	}
+	return out, nil
+}
+
+func (f *fakeS3) DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	f.calls = append(f.calls, "DeleteObjects")
+	bucket := aws.ToString(in.Bucket)
+	for _, o := range in.Delete.Objects {
+		f.objects[bucket] = slices.DeleteFunc(f.objects[bucket], func(k string) bool { return k == aws.ToString(o.Key) })
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func (f *fakeS3) PutBucketTagging(ctx context.Context, in *s3.PutBucketTaggingInput, _ ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error) {
+	f.calls = append(f.calls, "PutBucketTagging")
+	f.tags[aws.ToString(in.Bucket)] = in.Tagging.TagSet
+	return &s3.PutBucketTaggingOutput{}, nil
+}
+
+func (f *fakeS3) DeleteBucketTagging(ctx context.Context, in *s3.DeleteBucketTaggingInput, _ ...func(*s3.Options)) (*s3.DeleteBucketTaggingOutput, error) {
+	f.calls = append(f.calls, "DeleteBucketTagging")
+	delete(f.tags, aws.ToString(in.Bucket))
+	return &s3.DeleteBucketTaggingOutput{}, nil
+}
+
+func (f *fakeS3) PutBucketEncryption(ctx context.Context, in *s3.PutBucketEncryptionInput, _ ...func(*s3.Options)) (*s3.PutBucketEncryptionOutput, error) {
+	f.calls = append(f.calls, "PutBucketEncryption")
+	rule := in.ServerSideEncryptionConfiguration.Rules[0].ApplyServerSideEncryptionByDefault
+	f.kmsKeys[aws.ToString(in.Bucket)] = aws.ToString(rule.KMSMasterKeyID)
+	return &s3.PutBucketEncryptionOutput{}, nil
+}
+
+func (f *fakeS3) DeleteBucketEncryption(ctx context.Context, in *s3.DeleteBucketEncryptionInput, _ ...func(*s3.Options)) (*s3.DeleteBucketEncryptionOutput, error) {
+	f.calls = append(f.calls, "DeleteBucketEncryption")
+	delete(f.kmsKeys, aws.ToString(in.Bucket))
+	return &s3.DeleteBucketEncryptionOutput{}, nil
+}
+
+func (f *fakeS3) PutBucketLifecycleConfiguration(ctx context.Context, in *s3.PutBucketLifecycleConfigurationInput, _ ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	f.calls = append(f.calls, "PutBucketLifecycleConfiguration")
+	f.expiration[aws.ToString(in.Bucket)] = aws.ToInt32(in.LifecycleConfiguration.Rules[0].Expiration.Days)
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+func (f *fakeS3) DeleteBucketLifecycle(ctx context.Context, in *s3.DeleteBucketLifecycleInput, _ ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error) {
+	f.calls = append(f.calls, "DeleteBucketLifecycle")
+	delete(f.expiration, aws.ToString(in.Bucket))
+	return &s3.DeleteBucketLifecycleOutput{}, nil
+}
+
+func (f *fakeS3) PutPublicAccessBlock(ctx context.Context, in *s3.PutPublicAccessBlockInput, _ ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error) {
+	f.calls = append(f.calls, "PutPublicAccessBlock")
+	f.publicAccess[aws.ToString(in.Bucket)] = in.PublicAccessBlockConfiguration
+	return &s3.PutPublicAccessBlockOutput{}, nil
+}
+
+func (f *fakeS3) GetPublicAccessBlock(ctx context.Context, in *s3.GetPublicAccessBlockInput, _ ...func(*s3.Options)) (*s3.GetPublicAccessBlockOutput, error) {
+	c, ok := f.publicAccess[aws.ToString(in.Bucket)]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchPublicAccessBlockConfiguration"}
+	}
+	return &s3.GetPublicAccessBlockOutput{PublicAccessBlockConfiguration: c}, nil
+}
+
+// fakeGCS is an in-memory shared.GCSBuckets; updates records each UpdateBucket request.
+type fakeGCS struct {
+	buckets  map[string]*storage.BucketAttrs
+	projects map[string]string
+	updates  []storage.BucketAttrsToUpdate
+	objects  map[string]int
+}
+
+func newFakeGCS() *fakeGCS {
+	return &fakeGCS{buckets: map[string]*storage.BucketAttrs{}, projects: map[string]string{}, objects: map[string]int{}}
+}
+
+func (f *fakeGCS) CreateBucket(ctx context.Context, name, project string, attrs *storage.BucketAttrs) error {
+	f.buckets[name] = attrs
+	f.projects[name] = project
+	return nil
+}
+
+func (f *fakeGCS) BucketAttrs(ctx context.Context, name string) (*storage.BucketAttrs, error) {
+	attrs, ok := f.buckets[name]
+	if !ok {
+		return nil, storage.ErrBucketNotExist
+	}
+	return attrs, nil
+}
+
+func (f *fakeGCS) UpdateBucket(ctx context.Context, name string, upd storage.BucketAttrsToUpdate, setLabels map[string]string, deleteLabels []string) (*storage.BucketAttrs, error) {
+	attrs, ok := f.buckets[name]
+	if !ok {
+		return nil, storage.ErrBucketNotExist
+	}
+	f.updates = append(f.updates, upd)
+	if v, ok := upd.VersioningEnabled.(bool); ok {
+		attrs.VersioningEnabled = v
+	}
+	if upd.Encryption != nil {
+		attrs.Encryption = upd.Encryption
+	}
+	if upd.Lifecycle != nil {
+		attrs.Lifecycle = *upd.Lifecycle
+	}
+	if upd.UniformBucketLevelAccess != nil {
+		attrs.UniformBucketLevelAccess = *upd.UniformBucketLevelAccess
+	}
+	if upd.PublicAccessPrevention != storage.PublicAccessPreventionUnknown {
+		attrs.PublicAccessPrevention = upd.PublicAccessPrevention
+	}
+	if attrs.Labels == nil {
+		attrs.Labels = map[string]string{}
+	}
+	for k, v := range setLabels {
+		attrs.Labels[k] = v
+	}
+	for _, k := range deleteLabels {
+		delete(attrs.Labels, k)
+	}
+	return attrs, nil
+}
+
+func (f *fakeGCS) DeleteBucket(ctx context.Context, name string) error {
+	if _, ok := f.buckets[name]; !ok {
+		return storage.ErrBucketNotExist
+	}
+	if f.objects[name] > 0 {
+		return errors.New("bucket not empty")
+	}
+	delete(f.buckets, name)
+	return nil
+}
+
+func (f *fakeGCS) DeleteObjects(ctx context.Context, name string) error {
+	delete(f.objects, name)
+	return nil
+}
+
+// fakeEC2 is an in-memory shared.EC2Runner holding instances by ID, each with
+// a root volume "vol-<n>". types describes the instance types
+// DescribeInstanceTypes knows about, and the region's availability zones are
+// us-east-1a and us-east-1b. eips maps Elastic IP allocation IDs to the
+// instance each is associated with. New instances are running unless launch
+// names another state. An instance's profile is kept in its
+// RunInstancesInput, by name.
+type fakeEC2 struct {
+	instances map[string]*ec2.RunInstancesInput
+	states    map[string]ec2types.InstanceStateName
+	tags      map[string][]ec2types.Tag
+	types     map[ec2types.InstanceType]ec2types.InstanceTypeInfo
+	eips      map[string]string
+	launch    ec2types.InstanceStateName
+	err       error
+}
+
+func newFakeEC2() *fakeEC2 {
+	return &fakeEC2{
+		instances: map[string]*ec2.RunInstancesInput{},
+		states:    map[string]ec2types.InstanceStateName{},
+		tags:      map[string][]ec2types.Tag{},
+		types:     map[ec2types.InstanceType]ec2types.InstanceTypeInfo{},
+		eips:      map[string]string{},
+	}
+}
+
+func (f *fakeEC2) DescribeInstanceTypes(ctx context.Context, in *ec2.DescribeInstanceTypesInput, _ ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error) {
+	out := &ec2.DescribeInstanceTypesOutput{}
+	for _, t := range in.InstanceTypes {
+		if info, ok := f.types[t]; ok {
+			out.InstanceTypes = append(out.InstanceTypes, info)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEC2) RunInstances(ctx context.Context, in *ec2.RunInstancesInput, _ ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	id := fmt.Sprintf("i-%04d", len(f.instances)+1)
+	f.instances[id] = in
+	f.states[id] = ec2types.InstanceStateNameRunning
+	if f.launch != "" {
+		f.states[id] = f.launch
+	}
+	placement := &ec2types.Placement{AvailabilityZone: aws.String("us-east-1a")}
+	if in.Placement != nil {
+		placement = in.Placement
+	}
+	return &ec2.RunInstancesOutput{Instances: []ec2types.Instance{{InstanceId: aws.String(id), InstanceType: in.InstanceType, Placement: placement}}}, nil
+}
+
+// CreateTags overwrites tags with the same key, as EC2 does.
+func (f *fakeEC2) CreateTags(ctx context.Context, in *ec2.CreateTagsInput, _ ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	for _, id := range in.Resources {
+		for _, t := range in.Tags {
+			f.tags[id] = slices.DeleteFunc(f.tags[id], func(old ec2types.Tag) bool { return aws.ToString(old.Key) == aws.ToString(t.Key) })
+			f.tags[id] = append(f.tags[id], t)
+		}
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (f *fakeEC2) DeleteTags(ctx context.Context, in *ec2.DeleteTagsInput, _ ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	for _, id := range in.Resources {
+		for _, t := range in.Tags {
+			f.tags[id] = slices.DeleteFunc(f.tags[id], func(old ec2types.Tag) bool { return aws.ToString(old.Key) == aws.ToString(t.Key) })
+		}
+	}
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeInstances(ctx context.Context, in *ec2.DescribeInstancesInput, _ ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	out := &ec2.DescribeInstancesOutput{}
+	for _, id := range in.InstanceIds {
+		if run, ok := f.instances[id]; ok {
+			out.Reservations = append(out.Reservations, ec2types.Reservation{Instances: []ec2types.Instance{{
+				InstanceId:         aws.String(id),
+				InstanceType:       run.InstanceType,
+				State:              &ec2types.InstanceState{Name: f.states[id]},
+				Tags:               slices.Clone(f.tags[id]),
+				IamInstanceProfile: fakeInstanceProfile(run.IamInstanceProfile),
+				BlockDeviceMappings: []ec2types.InstanceBlockDeviceMapping{{
+					DeviceName: aws.String("/dev/xvda"),
+					Ebs:        &ec2types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-" + strings.TrimPrefix(id, "i-"))},
+				}},
+			}}})
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEC2) StopInstances(ctx context.Context, in *ec2.StopInstancesInput, _ ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	for _, id := range in.InstanceIds {
+		f.states[id] = ec2types.InstanceStateNameStopped
+	}
+	return &ec2.StopInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) StartInstances(ctx context.Context, in *ec2.StartInstancesInput, _ ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error) {
+	for _, id := range in.InstanceIds {
+		f.states[id] = ec2types.InstanceStateNameRunning
+	}
+	return &ec2.StartInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) ModifyInstanceAttribute(ctx context.Context, in *ec2.ModifyInstanceAttributeInput, _ ...func(*ec2.Options)) (*ec2.ModifyInstanceAttributeOutput, error) {
+	run, ok := f.instances[aws.ToString(in.InstanceId)]
+	if !ok {
+		return nil, errNotFound
+	}
+	if in.EbsOptimized != nil {
+		run.EbsOptimized = in.EbsOptimized.Value
+	}
+	return &ec2.ModifyInstanceAttributeOutput{}, nil
+}
+
+func (f *fakeEC2) TerminateInstances(ctx context.Context, in *ec2.TerminateInstancesInput, _ ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
+	for _, id := range in.InstanceIds {
+		delete(f.instances, id)
+	}
+	return &ec2.TerminateInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeAvailabilityZones(ctx context.Context, in *ec2.DescribeAvailabilityZonesInput, _ ...func(*ec2.Options)) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	return &ec2.DescribeAvailabilityZonesOutput{AvailabilityZones: []ec2types.AvailabilityZone{
+		{ZoneName: aws.String("us-east-1a")},
+		{ZoneName: aws.String("us-east-1b")},
+	}}, nil
+}
+
+func (f *fakeEC2) AssociateAddress(ctx context.Context, in *ec2.AssociateAddressInput, _ ...func(*ec2.Options)) (*ec2.AssociateAddressOutput, error) {
+	id := aws.ToString(in.AllocationId)
+	if _, ok := f.eips[id]; !ok {
+		return nil, errNotFound
+	}
+	f.eips[id] = aws.ToString(in.InstanceId)
+	return &ec2.AssociateAddressOutput{AssociationId: aws.String("eipassoc-" + id)}, nil
+}
+
+func (f *fakeEC2) DescribeAddresses(ctx context.Context, in *ec2.DescribeAddressesInput, _ ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error) {
+	out := &ec2.DescribeAddressesOutput{}
+	for _, id := range in.AllocationIds {
+		instance, ok := f.eips[id]
+		if !ok {
+			continue
+		}
+		addr := ec2types.Address{AllocationId: aws.String(id)}
+		if instance != "" {
+			addr.InstanceId, addr.AssociationId = aws.String(instance), aws.String("eipassoc-"+id)
+		}
+		out.Addresses = append(out.Addresses, addr)
+	}
+	return out, nil
+}
+
+// fakeInstanceProfile describes the profile spec refers to in account
+// 123456789012.
+func fakeInstanceProfile(spec *ec2types.IamInstanceProfileSpecification) *ec2types.IamInstanceProfile {
+	if spec == nil {
+		return nil
+	}
+	arn := aws.ToString(spec.Arn)
+	if arn == "" {
+		arn = "arn:aws:iam::123456789012:instance-profile/" + aws.ToString(spec.Name)
+	}
+	return &ec2types.IamInstanceProfile{Arn: aws.String(arn)}
+}
+
+func (f *fakeEC2) DescribeIamInstanceProfileAssociations(ctx context.Context, in *ec2.DescribeIamInstanceProfileAssociationsInput, _ ...func(*ec2.Options)) (*ec2.DescribeIamInstanceProfileAssociationsOutput, error) {
+	out := &ec2.DescribeIamInstanceProfileAssociationsOutput{}
+	for _, filter := range in.Filters {
+		if aws.ToString(filter.Name) != "instance-id" {
+			continue
+		}
+		for _, id := range filter.Values {
+			if run, ok := f.instances[id]; ok && run.IamInstanceProfile != nil {
+				out.IamInstanceProfileAssociations = append(out.IamInstanceProfileAssociations, ec2types.IamInstanceProfileAssociation{
+					AssociationId: aws.String("iip-assoc-" + id),
+					InstanceId:    aws.String(id),
+				})
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEC2) AssociateIamInstanceProfile(ctx context.Context, in *ec2.AssociateIamInstanceProfileInput, _ ...func(*ec2.Options)) (*ec2.AssociateIamInstanceProfileOutput, error) {
+	run, ok := f.instances[aws.ToString(in.InstanceId)]
+	if !ok {
+		return nil, errNotFound
+	}
+	if run.IamInstanceProfile != nil {
+		return nil, fmt.Errorf("IncorrectState: instance already has a profile")
+	}
+	run.IamInstanceProfile = in.IamInstanceProfile
+	return &ec2.AssociateIamInstanceProfileOutput{}, nil
+}
+
+func (f *fakeEC2) ReplaceIamInstanceProfileAssociation(ctx context.Context, in *ec2.ReplaceIamInstanceProfileAssociationInput, _ ...func(*ec2.Options)) (*ec2.ReplaceIamInstanceProfileAssociationOutput, error) {
+	run, ok := f.instances[strings.TrimPrefix(aws.ToString(in.AssociationId), "iip-assoc-")]
+	if !ok || run.IamInstanceProfile == nil {
+		return nil, errNotFound
+	}
+	run.IamInstanceProfile = in.IamInstanceProfile
+	return &ec2.ReplaceIamInstanceProfileAssociationOutput{}, nil
+}
+
+func (f *fakeEC2) DisassociateIamInstanceProfile(ctx context.Context, in *ec2.DisassociateIamInstanceProfileInput, _ ...func(*ec2.Options)) (*ec2.DisassociateIamInstanceProfileOutput, error) {
+	run, ok := f.instances[strings.TrimPrefix(aws.ToString(in.AssociationId), "iip-assoc-")]
+	if !ok || run.IamInstanceProfile == nil {
+		return nil, errNotFound
+	}
+	run.IamInstanceProfile = nil
+	return &ec2.DisassociateIamInstanceProfileOutput{}, nil
+}
+
+func (f *fakeEC2) DisassociateAddress(ctx context.Context, in *ec2.DisassociateAddressInput, _ ...func(*ec2.Options)) (*ec2.DisassociateAddressOutput, error) {
+	id := strings.TrimPrefix(aws.ToString(in.AssociationId), "eipassoc-")
+	if f.eips[id] == "" {
+		return nil, errNotFound
+	}
+	f.eips[id] = ""
+	return &ec2.DisassociateAddressOutput{}, nil
+}
+
+// fakeSQS is an in-memory shared.SQSQueues. Queue URLs are the queue names, and unset
+// attributes read back as the SQS defaults.
+type fakeSQS struct {
+	queues map[string]map[string]string
+	err    error
+	// attrErr fails GetQueueAttributes.
+	attrErr error
+}
+
+func newFakeSQS() *fakeSQS {
+	return &fakeSQS{queues: map[string]map[string]string{}}
+}
+
+func (f *fakeSQS) CreateQueue(ctx context.Context, in *sqs.CreateQueueInput, _ ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	name := aws.ToString(in.QueueName)
+	if strings.HasSuffix(name, ".fifo") != (in.Attributes["FifoQueue"] == "true") {
+		return nil, errors.New("InvalidParameterValue: FIFO queue names must end in .fifo")
+	}
+	if err := fifoOnly(in.Attributes, in.Attributes); err != nil {
+		return nil, err
+	}
+	attrs := map[string]string{"MessageRetentionPeriod": "345600", "VisibilityTimeout": "30", "MaximumMessageSize": "262144", "QueueArn": "arn:aws:sqs:us-east-1:123456789012:" + name}
+	maps.Copy(attrs, in.Attributes)
+	f.queues[name] = attrs
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String(name)}, nil
+}
+
+func (f *fakeSQS) GetQueueAttributes(ctx context.Context, in *sqs.GetQueueAttributesInput, _ ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	if f.attrErr != nil {
+		return nil, f.attrErr
+	}
+	attrs, ok := f.queues[aws.ToString(in.QueueUrl)]
+	if !ok {
+		return nil, errNotFound
+	}
+	return &sqs.GetQueueAttributesOutput{Attributes: maps.Clone(attrs)}, nil
+}
+
+func (f *fakeSQS) SetQueueAttributes(ctx context.Context, in *sqs.SetQueueAttributesInput, _ ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	attrs, ok := f.queues[aws.ToString(in.QueueUrl)]
+	if !ok {
+		return nil, errNotFound
+	}
+	if err := fifoOnly(attrs, in.Attributes); err != nil {
+		return nil, err
+	}
+	maps.Copy(attrs, in.Attributes)
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+// fifoOnly rejects content-based deduplication on a standard queue, as SQS does.
+func fifoOnly(queue, set map[string]string) error {
+	if _, ok := set["ContentBasedDeduplication"]; ok && queue["FifoQueue"] != "true" {
+		return errors.New("InvalidAttributeName: ContentBasedDeduplication is only valid for FIFO queues")
+	}
+	return nil
+}
+
+func (f *fakeSQS) DeleteQueue(ctx context.Context, in *sqs.DeleteQueueInput, _ ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	delete(f.queues, aws.ToString(in.QueueUrl))
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+// fakeARM is an Azure Resource Manager transport that accepts every PUT and
+// echoes the resource back as created. puts records the decoded PUT bodies by
+// request path, with PATCH bodies merged in. With notFound set, a GET of a path that was never PUT answers
+// 404 as ARM does, rather than with an empty resource, and created resources
+// get their path as ID.
+type fakeARM struct {
+	puts     map[string]map[string]any
+	notFound bool
+}
+
+func newFakeARM() *fakeARM {
+	return &fakeARM{puts: map[string]map[string]any{}}
+}
+
+// options returns client options that send requests to f.
+func (f *fakeARM) options() *arm.ClientOptions {
+	return &arm.ClientOptions{ClientOptions: policy.ClientOptions{Transport: f, Retry: policy.RetryOptions{MaxRetries: -1}}}
+}
+
+func (f *fakeARM) Do(req *http.Request) (*http.Response, error) {
+	status, body := http.StatusOK, []byte("{}")
+	if req.Method == http.MethodPut {
+		in, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		var v map[string]any
+		if err := json.Unmarshal(in, &v); err != nil {
+			return nil, err
+		}
+		if _, ok := v["id"]; !ok && f.notFound {
+			v["id"] = req.URL.Path
+			in, _ = json.Marshal(v)
+		}
+		f.puts[req.URL.Path] = v
+		body = in
+	} else if req.Method == http.MethodPatch {
+		var patch map[string]any
+		if err := json.NewDecoder(req.Body).Decode(&patch); err != nil {
+			return nil, err
+		}
+		v := f.puts[req.URL.Path]
+		if v == nil {
+			v = map[string]any{}
+		}
+		mergePatch(v, patch)
+		f.puts[req.URL.Path] = v
+		body, _ = json.Marshal(v)
+	} else if v, ok := f.puts[req.URL.Path]; ok {
+		body, _ = json.Marshal(v)
+	} else if f.notFound && req.Method == http.MethodGet {
+		status, body = http.StatusNotFound, []byte(`{"error": {"code": "ResourceNotFound", "message": "not found"}}`)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// mergePatch applies a JSON merge patch to v: objects merge and null deletes.
+func mergePatch(v, patch map[string]any) {
+	for k, p := range patch {
+		switch p := p.(type) {
+		case nil:
+			delete(v, k)
+		case map[string]any:
+			dst, ok := v[k].(map[string]any)
+			if !ok {
+				dst = map[string]any{}
+				v[k] = dst
+			}
+			mergePatch(dst, p)
+		default:
+			v[k] = p
+		}
+	}
+}
+
+// fakeCredential hands out a token without contacting Entra ID.
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// testSchema returns the schema of r.
+func testSchema(t *testing.T, r resource.Resource) schema.Schema {
+	t.Helper()
+	resp := &resource.SchemaResponse{}
+	r.Schema(context.Background(), resource.SchemaRequest{}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("schema: %v", resp.Diagnostics)
+	}
+	return resp.Schema
+}
+
+// testValue builds an object of the schema type from vals. Missing attributes
+// are null, or unknown when computed and unknownComputed is set.
+func testValue(s schema.Schema, vals map[string]tftypes.Value, unknownComputed bool) tftypes.Value {
+	typ := s.Type().TerraformType(context.Background()).(tftypes.Object)
+	attrs := map[string]tftypes.Value{}
+	for name, attrType := range typ.AttributeTypes {
+		switch v, ok := vals[name]; {
+		case ok:
+			attrs[name] = v
+		case unknownComputed && s.Attributes[name].IsComputed():
+			attrs[name] = tftypes.NewValue(attrType, tftypes.UnknownValue)
+		default:
+			attrs[name] = tftypes.NewValue(attrType, nil)
+		}
+	}
+	return tftypes.NewValue(typ, attrs)
+}
+
+// testPlan builds a planned value as Terraform would before create.
+func testPlan(t *testing.T, r resource.Resource, vals map[string]tftypes.Value) tfsdk.Plan {
+	s := testSchema(t, r)
+	return tfsdk.Plan{Schema: s, Raw: testValue(s, vals, true)}
+}
+
+// testState builds a stored state; a nil vals yields an empty state.
+func testState(t *testing.T, r resource.Resource, vals map[string]tftypes.Value) tfsdk.State {
+	s := testSchema(t, r)
+	if vals == nil {
+		return tfsdk.State{Schema: s, Raw: tftypes.NewValue(s.Type().TerraformType(context.Background()), nil)}
+	}
+	return tfsdk.State{Schema: s, Raw: testValue(s, vals, false)}
+}
+
+func str(v string) tftypes.Value { return tftypes.NewValue(tftypes.String, v) }
+
+func boolean(v bool) tftypes.Value { return tftypes.NewValue(tftypes.Bool, v) }
+
+func number(v int64) tftypes.Value { return tftypes.NewValue(tftypes.Number, v) }
+
+func strMap(m map[string]string) tftypes.Value {
+	vals := map[string]tftypes.Value{}
+	for k, v := range m {
+		vals[k] = str(v)
+	}
+	return tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, vals)
+}
+
+func strList(vs ...string) tftypes.Value {
+	vals := []tftypes.Value{}
+	for _, v := range vs {
+		vals = append(vals, str(v))
+	}
+	return tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, vals)
+}
@@ -1,15 +1,24 @@
 package resources
 
 import (
+	"bytes"
         "context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
         "io/ioutil"
+	"maps"
         "net/http"
         "os"
+	"slices"
         "strings"
         "time"
 
 	"abstract-provider/provider/shared"
+	"abstract-provider/provider/shared/naming"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appservice/armappservice"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
@@ -18,8 +27,18 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
         lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
         cloudfunctions "google.golang.org/api/cloudfunctions/v1"
+	cloudfunctionsv2 "google.golang.org/api/cloudfunctions/v2"
+	run "google.golang.org/api/run/v2"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
@@ -33,10 +52,54 @@ type FunctionResource struct {
         azureSub  string
         azureLoc  string
         gcpFunc   *cloudfunctions.Service
+	gcpRun    *run.Service
+	gcpFuncV2 *cloudfunctionsv2.Service
         gcpProj   string
         gcpRegion string
+
+	// azureShared* name a provider-wide storage account that is never deleted.
+	azureSharedAcct string
+	azureSharedRG   string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	ids *shared.CloudIDs
+}
+
+type functionResourceModel struct {
+	ID             types.String `tfsdk:"id"`
+	CloudID        types.String `tfsdk:"cloud_id"`
+	Name           types.String `tfsdk:"name"`
+	Type           types.String `tfsdk:"type"`
+	Region         types.String `tfsdk:"region"`
+	Runtime        types.String `tfsdk:"runtime"`
+	Handler        types.String `tfsdk:"handler"`
+	Code           types.String `tfsdk:"code"`
+	Account        types.String `tfsdk:"account"`
+	AccountCreated types.Bool   `tfsdk:"account_created"`
+	Plan           types.String `tfsdk:"plan"`
+	ResourceGroup  types.String `tfsdk:"resource_group"`
+	RoleARN        types.String `tfsdk:"role_arn"`
+	Environment    types.Map    `tfsdk:"environment"`
+	SourceHash     types.String `tfsdk:"source_hash"`
+	EnableURL      types.Bool   `tfsdk:"enable_url"`
+	InvokeURL      types.String `tfsdk:"invoke_url"`
+	PackageType    types.String `tfsdk:"package_type"`
+	ImageURI       types.String `tfsdk:"image_uri"`
+	MemoryMB       types.Int64  `tfsdk:"memory_mb"`
+	TimeoutSeconds types.Int64  `tfsdk:"timeout_seconds"`
+	Annotations    types.Map    `tfsdk:"annotations"`
+	Generation     types.Int64  `tfsdk:"generation"`
 }
 
+// image reports whether the function is deployed from a container image
+// rather than a zip package.
+func (m *functionResourceModel) image() bool { return m.PackageType.ValueString() == "image" }
+
 func NewFunctionResource() resource.Resource { return &FunctionResource{} }
 
 func (r *FunctionResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
@@ -48,6 +111,9 @@ func (r *FunctionResource) Configure(ctx context.Context, req resource.Configure
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.ids = cfg.CloudIDs
 	r.lambda = cfg.AWSLambda
 	r.azureWeb = cfg.AzureWebClient
 	r.azurePlan = cfg.AzurePlanClient
@@ -57,8 +123,12 @@ func (r *FunctionResource) Configure(ctx context.Context, req resource.Configure
         r.azureSub = cfg.AzureSubID
         r.azureLoc = cfg.AzureLocation
         r.gcpFunc = cfg.GCPFunctions
+	r.gcpRun = cfg.GCPRun
+	r.gcpFuncV2 = cfg.GCPFunctionsV2
         r.gcpProj = cfg.GCPProject
         r.gcpRegion = cfg.GCPRegion
+	r.azureSharedAcct = cfg.AzureStorageAccount
+	r.azureSharedRG = cfg.AzureStorageResourceGroup
 }
 
 func (r *FunctionResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -68,278 +138,1164 @@ func (r *FunctionResource) Metadata(ctx context.Context, req resource.MetadataRe
 func (r *FunctionResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
-			"id":             schema.StringAttribute{Computed: true},
-			"name":           schema.StringAttribute{Required: true},
-			"type":           schema.StringAttribute{Required: true},
-			"region":         schema.StringAttribute{Optional: true},
-			"runtime":        schema.StringAttribute{Required: true},
-			"handler":        schema.StringAttribute{Required: true},
-			"code":           schema.StringAttribute{Required: true},
-			"account":        schema.StringAttribute{Computed: true},
-			"plan":           schema.StringAttribute{Computed: true},
-			"resource_group": schema.StringAttribute{Computed: true},
+			"id":             schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"cloud_id":       cloudIDAttribute(),
+			"name":           schema.StringAttribute{Required: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"type":           schema.StringAttribute{Required: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"region":         schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"runtime":        schema.StringAttribute{Optional: true},
+			"handler":        schema.StringAttribute{Optional: true},
+			"code":           schema.StringAttribute{Optional: true},
+			"account":        schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"plan":           schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"resource_group": schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"role_arn":       schema.StringAttribute{Optional: true},
+			"environment":    schema.MapAttribute{ElementType: types.StringType, Optional: true},
+
+			// Base64 SHA-256 of the code package; a change redeploys the code.
+			"source_hash": schema.StringAttribute{Computed: true},
+
+			// AWS functions only get a function URL when enable_url is set;
+			// Azure and GCP functions always have one.
+			"enable_url": schema.BoolAttribute{Optional: true},
+			"invoke_url": schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+
+			// "zip" deploys code; "image" deploys image_uri as a container
+			// Lambda, a Cloud Run service or a container function app.
+			"package_type": schema.StringAttribute{Optional: true, Computed: true, Default: stringdefault.StaticString("zip"),
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"image_uri": schema.StringAttribute{Optional: true},
+
+			// Defaults depend on the cloud; Azure plans fix the memory.
+			"memory_mb":       schema.Int64Attribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()}},
+			"timeout_seconds": schema.Int64Attribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()}},
+
+			// GCP image functions only: annotations on the Cloud Run service.
+			"annotations": schema.MapAttribute{ElementType: types.StringType, Optional: true},
+
+			// GCP zip functions only: 1 deploys to Cloud Functions, 2 to Cloud
+			// Functions v2, which runs on Cloud Run.
+			"generation": schema.Int64Attribute{Optional: true, Computed: true, Default: int64default.StaticInt64(1),
+				PlanModifiers: []planmodifier.Int64{int64planmodifier.RequiresReplace()}},
+
+			// Only an account created for this function is deleted with it.
+			"account_created": schema.BoolAttribute{Computed: true, PlanModifiers: []planmodifier.Bool{boolplanmodifier.UseStateForUnknown()}},
 		},
 	}
 }
 
-func (r *FunctionResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name    types.String `tfsdk:"name"`
-		Type    types.String `tfsdk:"type"`
-		Region  types.String `tfsdk:"region"`
-		Runtime types.String `tfsdk:"runtime"`
-		Handler types.String `tfsdk:"handler"`
-		Code    types.String `tfsdk:"code"`
+func (r *FunctionResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg functionResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	switch t := cfg.Type.ValueString(); t {
+	case "aws":
+		if cfg.RoleARN.IsNull() && os.Getenv("LAMBDA_ROLE_ARN") == "" {
+			resp.Diagnostics.AddAttributeError(path.Root("role_arn"), "missing role_arn", "aws functions need an execution role")
+		}
+	case "azure", "gcp":
+		if !cfg.EnableURL.IsNull() {
+			resp.Diagnostics.AddAttributeWarning(path.Root("enable_url"), "enable_url ignored", t+" functions always have an invoke URL")
+		}
+		if !cfg.RoleARN.IsNull() {
+			resp.Diagnostics.AddAttributeWarning(path.Root("role_arn"), "role_arn ignored", "role_arn only applies to aws")
+		}
+	}
+	if cfg.PackageType.IsUnknown() || cfg.Code.IsUnknown() || cfg.ImageURI.IsUnknown() {
+		return
+	}
+	switch pt := cfg.PackageType.ValueString(); pt {
+	case "", "zip":
+		if !cfg.ImageURI.IsNull() || cfg.Code.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("code"), "invalid package", "zip functions need code and no image_uri")
+		}
+		for _, attr := range []string{"runtime", "handler"} {
+			var v types.String
+			resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root(attr), &v)...)
+			if v.IsNull() {
+				resp.Diagnostics.AddAttributeError(path.Root(attr), "missing "+attr, attr+" is required for zip functions")
+			}
+		}
+	case "image":
+		if cfg.ImageURI.IsNull() || !cfg.Code.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("image_uri"), "invalid package", "image functions need image_uri and no code")
+		}
+		if !cfg.Runtime.IsNull() || !cfg.Handler.IsNull() {
+			resp.Diagnostics.AddAttributeWarning(path.Root("runtime"), "runtime ignored", "runtime and handler are set by the image")
+		}
+	default:
+		resp.Diagnostics.AddAttributeError(path.Root("package_type"), "invalid package type", fmt.Sprintf("%q is not zip or image", pt))
+	}
+	if !cfg.Annotations.IsNull() && !cfg.Annotations.IsUnknown() {
+		if cfg.Type.ValueString() != "gcp" || !cfg.image() {
+			resp.Diagnostics.AddAttributeWarning(path.Root("annotations"), "annotations ignored", "annotations only apply to gcp image functions")
+		} else if err := validateCloudRunAnnotations(stringMap(cfg.Annotations)); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("annotations"), "invalid annotations", err.Error())
+		}
+	}
+	if gen := cfg.Generation; !gen.IsNull() && !gen.IsUnknown() {
+		switch v := gen.ValueInt64(); {
+		case v != 1 && v != 2:
+			resp.Diagnostics.AddAttributeError(path.Root("generation"), "invalid generation", fmt.Sprintf("%d is not 1 or 2", v))
+		case v == 2 && !cfg.gen2():
+			resp.Diagnostics.AddAttributeWarning(path.Root("generation"), "generation ignored", "generation only applies to gcp zip functions")
+		}
+	}
+	lim := functionLimitsFor(cfg.Type.ValueString(), cfg.image(), cfg.gen2())
+	if mem := cfg.MemoryMB; !mem.IsNull() && !mem.IsUnknown() {
+		switch v := mem.ValueInt64(); {
+		case lim.memoryMax == 0:
+			resp.Diagnostics.AddAttributeError(path.Root("memory_mb"), "unsupported", "memory is set by the function app plan on azure")
+		case lim.memorySizes != nil && !slices.Contains(lim.memorySizes, v):
+			resp.Diagnostics.AddAttributeError(path.Root("memory_mb"), "invalid memory", fmt.Sprintf("%d is not one of %v", v, lim.memorySizes))
+		case v < lim.memoryMin || v > lim.memoryMax:
+			resp.Diagnostics.AddAttributeError(path.Root("memory_mb"), "invalid memory", fmt.Sprintf("%d is outside %d-%d", v, lim.memoryMin, lim.memoryMax))
+		}
 	}
+	if to := cfg.TimeoutSeconds; !to.IsNull() && !to.IsUnknown() {
+		if v := to.ValueInt64(); v < 1 || v > lim.timeoutMax {
+			resp.Diagnostics.AddAttributeError(path.Root("timeout_seconds"), "invalid timeout", fmt.Sprintf("%d is outside 1-%d", v, lim.timeoutMax))
+		}
+	}
+}
+
+// functionLimits are a platform's memory and timeout bounds and defaults.
+// A zero memoryMax means memory cannot be configured.
+type functionLimits struct {
+	memoryMin, memoryMax, memoryDefault int64
+	memorySizes                         []int64
+	timeoutMax, timeoutDefault          int64
+}
+
+// functionLimitsFor returns the limits of the platform a function runs on.
+func functionLimitsFor(cloud string, image, gen2 bool) functionLimits {
+	switch {
+	case cloud == "aws":
+		return functionLimits{memoryMin: 128, memoryMax: 10240, memoryDefault: 128, timeoutMax: 900, timeoutDefault: 3}
+	case cloud == "gcp" && image:
+		// Cloud Run
+		return functionLimits{memoryMin: 128, memoryMax: 32768, memoryDefault: 512, timeoutMax: 3600, timeoutDefault: 300}
+	case cloud == "gcp" && gen2:
+		// HTTP functions on Cloud Functions v2
+		return functionLimits{memoryMin: 128, memoryMax: 32768, memoryDefault: 256, timeoutMax: 3600, timeoutDefault: 60}
+	case cloud == "gcp":
+		return functionLimits{memorySizes: []int64{128, 256, 512, 1024, 2048, 4096, 8192}, memoryMin: 128, memoryMax: 8192,
+			memoryDefault: 256, timeoutMax: 540, timeoutDefault: 60}
+	case cloud == "azure" && image:
+		// Elastic Premium has no hard limit; cap it at an hour like Cloud Run
+		return functionLimits{timeoutMax: 3600, timeoutDefault: 1800}
+	default:
+		// Azure consumption plan
+		return functionLimits{timeoutMax: 600, timeoutDefault: 300}
+	}
+}
+
+// setLimitDefaults fills unset memory and timeout with the platform defaults.
+func (m *functionResourceModel) setLimitDefaults() {
+	lim := functionLimitsFor(m.Type.ValueString(), m.image(), m.gen2())
+	if m.MemoryMB.IsUnknown() || m.MemoryMB.IsNull() {
+		m.MemoryMB = types.Int64Null()
+		if lim.memoryDefault > 0 {
+			m.MemoryMB = types.Int64Value(lim.memoryDefault)
+		}
+	}
+	if m.TimeoutSeconds.IsUnknown() || m.TimeoutSeconds.IsNull() {
+		m.TimeoutSeconds = types.Int64Value(lim.timeoutDefault)
+	}
+}
+
+// azureTimeoutSetting overrides host.json's functionTimeout through an app setting.
+const azureTimeoutSetting = "AzureFunctionsJobHost__functionTimeout"
+
+// azureSettings returns the app settings for the function: its environment
+// and the timeout.
+func (m *functionResourceModel) azureSettings() map[string]string {
+	settings := stringMap(m.Environment)
+	t := time.Duration(m.TimeoutSeconds.ValueInt64()) * time.Second
+	settings[azureTimeoutSetting] = fmt.Sprintf("%02d:%02d:%02d", int(t.Hours()), int(t.Minutes())%60, int(t.Seconds())%60)
+	return settings
+}
+
+// azureTimeout parses an hh:mm:ss functionTimeout setting.
+func azureTimeout(v string) (int64, bool) {
+	var h, m, sec int64
+	if _, err := fmt.Sscanf(v, "%d:%d:%d", &h, &m, &sec); err != nil {
+		return 0, false
+	}
+	return h*3600 + m*60 + sec, true
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *FunctionResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.lambda = c.Lambda
+	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *FunctionResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.lambda != nil
+	case "azure":
+		return r.azureRG != nil && r.azureAcct != nil && r.azurePlan != nil && r.azureWeb != nil
+	case "gcp":
+		return r.gcpFunc != nil && r.gcpFuncV2 != nil && r.gcpRun != nil
+	}
+	return false
+}
+
+func (r *FunctionResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan functionResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = types.StringValue(plan.Name.ValueString())
+	plan.CloudID = types.StringNull()
+	plan.Account = types.StringNull()
+	plan.AccountCreated = types.BoolNull()
+	plan.Plan = types.StringNull()
+	plan.ResourceGroup = types.StringNull()
+	plan.InvokeURL = types.StringNull()
+	plan.SourceHash = types.StringNull()
+	plan.setLimitDefaults()
 	switch plan.Type.ValueString() {
 	case "aws":
-		if r.lambda == nil {
-			resp.Diagnostics.AddError("missing AWS client", "")
+		role := roleARN(plan.RoleARN, "role_arn", "LAMBDA_ROLE_ARN", &resp.Diagnostics)
+		if role == "" {
+			resp.Diagnostics.AddAttributeError(path.Root("role_arn"), "missing role", "role_arn must be set for aws functions")
 			return
 		}
-		role := os.Getenv("LAMBDA_ROLE_ARN")
-		if role == "" {
-			resp.Diagnostics.AddError("missing role", "LAMBDA_ROLE_ARN must be set")
+		input := &lambda.CreateFunctionInput{
+			FunctionName: aws.String(plan.Name.ValueString()),
+			Role:         aws.String(role),
+			Environment:  &lambdatypes.Environment{Variables: stringMap(plan.Environment)},
+			MemorySize:   aws.Int32(int32(plan.MemoryMB.ValueInt64())),
+			Timeout:      aws.Int32(int32(plan.TimeoutSeconds.ValueInt64())),
+		}
+		if plan.image() {
+			input.PackageType = lambdatypes.PackageTypeImage
+			input.Code = &lambdatypes.FunctionCode{ImageUri: aws.String(plan.ImageURI.ValueString())}
+		} else {
+			codeBytes, err := ioutil.ReadFile(plan.Code.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError("read code", err.Error())
+				return
+			}
+			input.Runtime = lambdatypes.Runtime(plan.Runtime.ValueString())
+			input.Handler = aws.String(plan.Handler.ValueString())
+			input.Code = &lambdatypes.FunctionCode{ZipFile: codeBytes}
+			plan.SourceHash = types.StringValue(sourceHash(codeBytes))
+		}
+		if _, err := r.lambda.CreateFunction(ctx, input); err != nil {
+			resp.Diagnostics.AddError("aws create", err.Error())
 			return
 		}
-		codeBytes, err := ioutil.ReadFile(plan.Code.ValueString())
+		if plan.EnableURL.ValueBool() {
+			url, err := r.lambdaURL(ctx, plan.Name.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError("aws function url", err.Error())
+				return
+			}
+			plan.InvokeURL = types.StringValue(url)
+		}
+       case "azure":
+		siteName, err := naming.AzureFunctionApp(plan.Name.ValueString())
 		if err != nil {
-			resp.Diagnostics.AddError("read code", err.Error())
+			resp.Diagnostics.AddAttributeError(path.Root("name"), "invalid name", err.Error())
 			return
 		}
-		_, err = r.lambda.CreateFunction(ctx, &lambda.CreateFunctionInput{
-			FunctionName: aws.String(plan.Name.ValueString()),
-			Runtime:      lambdatypes.Runtime(plan.Runtime.ValueString()),
-			Handler:      aws.String(plan.Handler.ValueString()),
-			Role:         aws.String(role),
-			Code:         &lambdatypes.FunctionCode{ZipFile: codeBytes},
-		})
+		var codeBytes []byte
+		if !plan.image() {
+			codeBytes, err = ioutil.ReadFile(plan.Code.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError("read code", err.Error())
+				return
+			}
+		}
+		planName, err := naming.AzureAppServicePlan(siteName + "-plan")
 		if err != nil {
-			resp.Diagnostics.AddError("aws create", err.Error())
+			resp.Diagnostics.AddAttributeError(path.Root("name"), "invalid name", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":      plan.Name.ValueString(),
-			"name":    plan.Name.ValueString(),
-			"type":    plan.Type.ValueString(),
-			"region":  plan.Region.ValueString(),
-			"runtime": plan.Runtime.ValueString(),
-			"handler": plan.Handler.ValueString(),
-			"code":    plan.Code.ValueString(),
-		})
-       case "azure":
-               if r.azureWeb == nil || r.azurePlan == nil || r.azureRG == nil || r.azureAcct == nil {
-                       resp.Diagnostics.AddError("azure", "missing client")
-                       return
-               }
 		rgName := "abstract-rg"
-		if r.azureLoc == "" && plan.Region.ValueString() != "" {
-			r.azureLoc = plan.Region.ValueString()
-		}
-		_, err := r.azureRG.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{Location: &r.azureLoc}, nil)
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		err = shared.EnsureResourceGroup(ctx, r.azureRG, rgName, loc)
 		if err != nil {
 			resp.Diagnostics.AddError("azure rg", err.Error())
 			return
 		}
-		acctName := strings.ToLower(plan.Name.ValueString())
-		if len(acctName) > 24 {
-			acctName = acctName[:24]
-		}
-		acctPoller, err := r.azureAcct.BeginCreate(ctx, rgName, acctName, armstorage.AccountCreateParameters{
-			Location: &r.azureLoc,
-			Kind:     to.Ptr(armstorage.KindStorageV2),
-			SKU:      &armstorage.SKU{Name: to.Ptr(armstorage.SKUNameStandardLRS)},
-		}, nil)
-		if err == nil {
-			_, err = acctPoller.PollUntilDone(ctx, nil)
-		}
+		store := azureStorage{rg: r.azureRG, acct: r.azureAcct, loc: loc, sharedAcct: r.azureSharedAcct, sharedRG: r.azureSharedRG}
+		acctName, acctRG, created, err := store.ensure(ctx, plan.Name.ValueString())
 		if err != nil {
 			resp.Diagnostics.AddError("azure storage", err.Error())
 			return
 		}
-		planName := plan.Name.ValueString() + "-plan"
-		planPoller, err := r.azurePlan.BeginCreateOrUpdate(ctx, rgName, planName, armappservice.Plan{
-			Location: &r.azureLoc,
+		keys, err := r.azureAcct.ListKeys(ctx, acctRG, acctName, nil)
+		if err != nil || keys.Keys == nil || len(keys.Keys) == 0 || keys.Keys[0].Value == nil {
+			resp.Diagnostics.AddError("azure keys", "unable to get account key")
+			return
+		}
+		appPlan := armappservice.Plan{
+			Location: &loc,
 			Kind:     to.Ptr("functionapp"),
 			SKU:      &armappservice.SKUDescription{Name: to.Ptr("Y1"), Tier: to.Ptr("Dynamic")},
-		}, nil)
+		}
+		siteKind := "functionapp"
+		siteConfig := &armappservice.SiteConfig{AppSettings: azureAppSettings(plan.azureSettings())}
+		// the Functions runtime keeps triggers, keys and logs in the account
+		siteConfig.AppSettings = append(siteConfig.AppSettings, &armappservice.NameValuePair{
+			Name:  to.Ptr("AzureWebJobsStorage"),
+			Value: to.Ptr(azureConnectionString(acctName, *keys.Keys[0].Value)),
+		})
+		if plan.image() {
+			// the consumption plan cannot run containers; Elastic Premium on Linux can
+			appPlan.Kind = to.Ptr("elastic")
+			appPlan.SKU = &armappservice.SKUDescription{Name: to.Ptr("EP1"), Tier: to.Ptr("ElasticPremium")}
+			appPlan.Properties = &armappservice.PlanProperties{Reserved: to.Ptr(true)}
+			siteKind = "functionapp,linux,container"
+			siteConfig.LinuxFxVersion = to.Ptr("DOCKER|" + plan.ImageURI.ValueString())
+		}
+		planPoller, err := r.azurePlan.BeginCreateOrUpdate(ctx, rgName, planName, appPlan, nil)
 		if err == nil {
-			_, err = planPoller.PollUntilDone(ctx, nil)
+			_, err = shared.PollAzure(ctx, planPoller)
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure plan", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure plan", err)
 			return
 		}
 		planID := "/subscriptions/" + r.azureSub + "/resourceGroups/" + rgName + "/providers/Microsoft.Web/serverfarms/" + planName
-		sitePoller, err := r.azureWeb.BeginCreateOrUpdate(ctx, rgName, plan.Name.ValueString(), armappservice.Site{
-			Location: &r.azureLoc,
-			Kind:     to.Ptr("functionapp"),
+		sitePoller, err := r.azureWeb.BeginCreateOrUpdate(ctx, rgName, siteName, armappservice.Site{
+			Location: &loc,
+			Kind:     &siteKind,
 			Properties: &armappservice.SiteProperties{
 				ServerFarmID: &planID,
+				SiteConfig:   siteConfig,
 			},
 		}, nil)
+		var site armappservice.WebAppsClientCreateOrUpdateResponse
 		if err == nil {
-			_, err = sitePoller.PollUntilDone(ctx, nil)
+			site, err = shared.PollAzure(ctx, sitePoller)
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure function", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure function", err)
 			return
 		}
-               resp.State.Set(ctx, map[string]interface{}{
-                        "id":             plan.Name.ValueString(),
-                        "name":           plan.Name.ValueString(),
-                        "type":           plan.Type.ValueString(),
-                        "region":         r.azureLoc,
-                        "runtime":        plan.Runtime.ValueString(),
-                        "handler":        plan.Handler.ValueString(),
-                        "code":           plan.Code.ValueString(),
-                        "account":        acctName,
-                        "plan":           planName,
-                        "resource_group": rgName,
-               })
+		plan.InvokeURL = azureSiteURL(site.Site)
+		if !plan.image() {
+			if err := r.azureZipDeploy(ctx, siteName, codeBytes); err != nil {
+				resp.Diagnostics.AddError("azure deploy", err.Error())
+				return
+			}
+			plan.SourceHash = types.StringValue(sourceHash(codeBytes))
+		}
+		plan.ID = types.StringValue(siteName)
+		plan.Account = types.StringValue(acctName)
+		plan.AccountCreated = types.BoolValue(created)
+		plan.Plan = types.StringValue(planName)
+		plan.ResourceGroup = types.StringValue(acctRG)
        case "gcp":
-               if r.gcpFunc == nil {
-                       resp.Diagnostics.AddError("gcp", "missing client")
-                       return
-               }
-               region := plan.Region.ValueString()
-               if region == "" {
-                       region = r.gcpRegion
-               }
                name := plan.Name.ValueString()
-               parent := "projects/" + r.gcpProj + "/locations/" + region
+               parent := r.gcpParent(&plan)
+		if plan.image() {
+			svc, err := r.putCloudRun(ctx, parent, name, &plan, true)
+			if err != nil {
+				resp.Diagnostics.AddError("gcp cloud run", err.Error())
+				return
+			}
+			plan.InvokeURL = types.StringValue(svc.Uri)
+			break
+		}
                codeBytes, err := ioutil.ReadFile(plan.Code.ValueString())
                if err != nil {
                        resp.Diagnostics.AddError("read code", err.Error())
                        return
                }
-               urlResp, err := r.gcpFunc.Projects.Locations.Functions.GenerateUploadUrl(parent, &cloudfunctions.GenerateUploadUrlRequest{}).Context(ctx).Do()
-               if err != nil {
-                       resp.Diagnostics.AddError("gcp generate url", err.Error())
-                       return
-               }
-               reqUpload, err := http.NewRequestWithContext(ctx, http.MethodPut, urlResp.UploadUrl, strings.NewReader(string(codeBytes)))
-               if err == nil {
-                       reqUpload.Header.Set("Content-Type", "application/zip")
-                       _, err = http.DefaultClient.Do(reqUpload)
-               }
-               if err != nil {
-                       resp.Diagnostics.AddError("gcp upload", err.Error())
-                       return
-               }
+		if plan.gen2() {
+			fn, err := r.putFunctionV2(ctx, parent, name, &plan, codeBytes, true)
+			if err != nil {
+				resp.Diagnostics.AddError("gcp create", err.Error())
+				return
+			}
+			plan.SourceHash = types.StringValue(sourceHash(codeBytes))
+			resp.Diagnostics.Append(plan.setFunctionV2(ctx, fn)...)
+			break
+		}
+		uploadURL, err := r.gcpUpload(ctx, parent, codeBytes)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp upload", err.Error())
+			return
+		}
                cf := &cloudfunctions.CloudFunction{
                        Name:          parent + "/functions/" + name,
                        EntryPoint:    plan.Handler.ValueString(),
                        Runtime:       plan.Runtime.ValueString(),
-                       SourceUploadUrl: urlResp.UploadUrl,
+                       SourceUploadUrl: uploadURL,
                        HttpsTrigger: &cloudfunctions.HttpsTrigger{},
+                       AvailableMemoryMb: plan.MemoryMB.ValueInt64(),
+                       Timeout:      fmt.Sprintf("%ds", plan.TimeoutSeconds.ValueInt64()),
                }
+		if env := stringMap(plan.Environment); len(env) > 0 {
+			cf.EnvironmentVariables = env
+		}
                op, err := r.gcpFunc.Projects.Locations.Functions.Create(parent, cf).Context(ctx).Do()
                if err != nil {
                        resp.Diagnostics.AddError("gcp create", err.Error())
                        return
                }
-               for {
-                       oper, err := r.gcpFunc.Operations.Get(op.Name).Context(ctx).Do()
-                       if err != nil {
-                               resp.Diagnostics.AddError("gcp create", err.Error())
-                               return
-                       }
-                       if oper.Done {
-                               break
-                       }
-                       time.Sleep(5 * time.Second)
-               }
-               resp.State.Set(ctx, map[string]interface{}{
-                       "id":      name,
-                       "name":    name,
-                       "type":    plan.Type.ValueString(),
-                       "region":  region,
-                       "runtime": plan.Runtime.ValueString(),
-                       "handler": plan.Handler.ValueString(),
-                       "code":    plan.Code.ValueString(),
-               })
+		if err := r.gcpWait(ctx, op.Name); err != nil {
+			resp.Diagnostics.AddError("gcp create", err.Error())
+			return
+		}
+		plan.SourceHash = types.StringValue(sourceHash(codeBytes))
+		fn, err := r.gcpFunc.Projects.Locations.Functions.Get(cf.Name).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp read", err.Error())
+			return
+		}
+		plan.InvokeURL = gcpFunctionURL(fn)
        default:
                resp.Diagnostics.AddError("unsupported cloud", "only aws and azure implemented")
+               return
        }
+	id, err := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
-func (r *FunctionResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID            types.String `tfsdk:"id"`
-		Type          types.String `tfsdk:"type"`
-		Plan          types.String `tfsdk:"plan"`
-		ResourceGroup types.String `tfsdk:"resource_group"`
+// cloudID is the Lambda function's ARN, the function app's ID or the full
+// name of the Cloud Function or Cloud Run service.
+func (r *FunctionResource) cloudID(ctx context.Context, m *functionResourceModel) (string, error) {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.ARN(ctx, "lambda", true, "function:"+id)
+	case "azure":
+		return r.ids.AzureID("abstract-rg", "Microsoft.Web/sites", id), nil
+	case "gcp":
+		if r.gcpProj == "" {
+			return "", nil
+		}
+		if m.image() {
+			return r.gcpParent(m) + "/services/" + id, nil
+		}
+		return r.gcpParent(m) + "/functions/" + id, nil
+	}
+	return "", nil
+}
+
+// roleARN returns the role ARN from attr, falling back to the deprecated
+// environment variable env with a warning.
+func roleARN(attr types.String, name, env string, diags *diag.Diagnostics) string {
+	if v := attr.ValueString(); v != "" {
+		return v
+	}
+	v := os.Getenv(env)
+	if v != "" {
+		diags.AddAttributeWarning(path.Root(name), "deprecated environment variable", fmt.Sprintf("%s is deprecated; set %s instead", env, name))
 	}
+	return v
+}
+
+func (r *FunctionResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state functionResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	if state.Generation.IsNull() {
+		// functions created before generation existed are all 1st gen
+		state.Generation = types.Int64Value(1)
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.lambda == nil {
-			return
-		}
-		_, err := r.lambda.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(state.ID.ValueString())})
+		out, err := r.lambda.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(state.ID.ValueString())})
 		if err != nil {
 			resp.State.RemoveResource(ctx)
+			return
+		}
+		var env map[string]string
+		if out.Configuration != nil && out.Configuration.Environment != nil {
+			env = out.Configuration.Environment.Variables
+		}
+		if state.image() {
+			if out.Code != nil && out.Code.ImageUri != nil {
+				state.ImageURI = types.StringValue(*out.Code.ImageUri)
+			}
+		} else if out.Configuration != nil && out.Configuration.CodeSha256 != nil {
+			// Lambda reports the same base64 SHA-256 as source_hash
+			state.SourceHash = types.StringValue(*out.Configuration.CodeSha256)
+		}
+		resp.Diagnostics.Append(state.setEnvironment(ctx, env)...)
+		if c := out.Configuration; c != nil && c.MemorySize != nil && c.Timeout != nil {
+			state.MemoryMB = types.Int64Value(int64(*c.MemorySize))
+			state.TimeoutSeconds = types.Int64Value(int64(*c.Timeout))
+		}
+		state.InvokeURL = types.StringNull()
+		if state.EnableURL.ValueBool() {
+			cfg, err := r.lambda.GetFunctionUrlConfig(ctx, &lambda.GetFunctionUrlConfigInput{FunctionName: aws.String(state.ID.ValueString())})
+			if err == nil {
+				state.InvokeURL = types.StringValue(aws.ToString(cfg.FunctionUrl))
+			}
 		}
        case "azure":
-               if r.azureWeb == nil {
-                       return
-               }
-               _, err := r.azureWeb.Get(ctx, "abstract-rg", state.ID.ValueString(), nil)
+               site, err := r.azureWeb.Get(ctx, "abstract-rg", state.ID.ValueString(), nil)
                if err != nil {
                        resp.State.RemoveResource(ctx)
-               }
-       case "gcp":
-               if r.gcpFunc == nil {
                        return
                }
-               region := r.gcpRegion
-               if region == "" {
-                       region = "us-central1"
-               }
-               _, err := r.gcpFunc.Projects.Locations.Functions.Get("projects/" + r.gcpProj + "/locations/" + region + "/functions/" + state.ID.ValueString()).Context(ctx).Do()
+		state.InvokeURL = azureSiteURL(site.Site)
+		if state.image() && site.Properties != nil && site.Properties.SiteConfig != nil && site.Properties.SiteConfig.LinuxFxVersion != nil {
+			if image, ok := strings.CutPrefix(*site.Properties.SiteConfig.LinuxFxVersion, "DOCKER|"); ok {
+				state.ImageURI = types.StringValue(image)
+			}
+		}
+		settings, err := r.azureWeb.ListApplicationSettings(ctx, "abstract-rg", state.ID.ValueString(), nil)
+		if err != nil {
+			resp.Diagnostics.AddError("azure read settings", err.Error())
+			return
+		}
+		resp.Diagnostics.Append(state.setEnvironment(ctx, userAppSettings(settings.Properties))...)
+		if site.Properties != nil && site.Properties.ServerFarmID != nil {
+			farm := *site.Properties.ServerFarmID
+			state.Plan = types.StringValue(farm[strings.LastIndex(farm, "/")+1:])
+		}
+		store := azureStorage{sharedAcct: r.azureSharedAcct, sharedRG: r.azureSharedRG}
+		acctName, acctRG := store.names(state.Name.ValueString())
+		if v := settings.Properties["AzureWebJobsStorage"]; v != nil {
+			if name, ok := connectionStringAccount(*v); ok {
+				acctName = name
+			}
+		}
+		state.Account = types.StringValue(acctName)
+		if state.ResourceGroup.ValueString() == "" {
+			state.ResourceGroup = types.StringValue(acctRG)
+		}
+		if state.AccountCreated.IsNull() {
+			state.AccountCreated = types.BoolValue(false)
+		}
+		if v := settings.Properties[azureTimeoutSetting]; v != nil {
+			if secs, ok := azureTimeout(*v); ok {
+				state.TimeoutSeconds = types.Int64Value(secs)
+			}
+		}
+       case "gcp":
+		if state.image() {
+			svc, err := r.gcpRun.Projects.Locations.Services.Get(r.gcpParent(&state) + "/services/" + state.ID.ValueString()).Context(ctx).Do()
+			if err != nil {
+				resp.State.RemoveResource(ctx)
+				return
+			}
+			if t := svc.Template; t != nil && len(t.Containers) > 0 {
+				state.ImageURI = types.StringValue(t.Containers[0].Image)
+				env := map[string]string{}
+				for _, e := range t.Containers[0].Env {
+					env[e.Name] = e.Value
+				}
+				resp.Diagnostics.Append(state.setEnvironment(ctx, env)...)
+				if res := t.Containers[0].Resources; res != nil {
+					if mem, ok := cloudRunMemory(res.Limits["memory"]); ok {
+						state.MemoryMB = types.Int64Value(mem)
+					}
+				}
+				if secs, ok := gcpDuration(t.Timeout); ok {
+					state.TimeoutSeconds = types.Int64Value(secs)
+				}
+			}
+			state.InvokeURL = types.StringValue(svc.Uri)
+			break
+		}
+		if state.gen2() {
+			fn, err := r.gcpFuncV2.Projects.Locations.Functions.Get(r.gcpParent(&state) + "/functions/" + state.ID.ValueString()).Context(ctx).Do()
+			if err != nil {
+				resp.State.RemoveResource(ctx)
+				return
+			}
+			resp.Diagnostics.Append(state.setFunctionV2(ctx, fn)...)
+			break
+		}
+               fn, err := r.gcpFunc.Projects.Locations.Functions.Get(r.gcpParent(&state) + "/functions/" + state.ID.ValueString()).Context(ctx).Do()
                if err != nil {
                        resp.State.RemoveResource(ctx)
+                       return
                }
+		resp.Diagnostics.Append(state.setEnvironment(ctx, fn.EnvironmentVariables)...)
+		state.InvokeURL = gcpFunctionURL(fn)
+		if fn.AvailableMemoryMb > 0 {
+			state.MemoryMB = types.Int64Value(fn.AvailableMemoryMb)
+		}
+		if secs, ok := gcpDuration(fn.Timeout); ok {
+			state.TimeoutSeconds = types.Int64Value(secs)
+		}
+	default:
+		return
        }
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+// setEnvironment records the environment reported by the cloud. An empty
+// environment leaves an unset attribute null.
+func (m *functionResourceModel) setEnvironment(ctx context.Context, env map[string]string) diag.Diagnostics {
+	if len(env) == 0 && m.Environment.IsNull() {
+		return nil
+	}
+	if env == nil {
+		env = map[string]string{}
+	}
+	v, diags := types.MapValueFrom(ctx, types.StringType, env)
+	m.Environment = v
+	return diags
+}
+
+// azureReservedSettings prefixes the app settings the Functions runtime and
+// portal manage, which are not part of a function's environment.
+var azureReservedSettings = []string{"AzureWebJobs", "AzureFunctionsJobHost__", "FUNCTIONS_", "WEBSITE_", "APPINSIGHTS_", "APPLICATIONINSIGHTS_"}
+
+func azureReservedSetting(name string) bool {
+	return slices.ContainsFunc(azureReservedSettings, func(p string) bool { return strings.HasPrefix(name, p) })
 }
+
+// userAppSettings returns the app settings that are not reserved.
+func userAppSettings(settings map[string]*string) map[string]string {
+	env := map[string]string{}
+	for k, v := range settings {
+		if v == nil || azureReservedSetting(k) {
+			continue
+		}
+		env[k] = *v
+	}
+	return env
+}
+
+// azureAppSettings converts an environment to function app settings.
+func azureAppSettings(env map[string]string) []*armappservice.NameValuePair {
+	if len(env) == 0 {
+		return nil
+	}
+	keys := slices.Sorted(maps.Keys(env))
+	pairs := make([]*armappservice.NameValuePair, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, &armappservice.NameValuePair{Name: to.Ptr(k), Value: to.Ptr(env[k])})
+	}
+	return pairs
+}
+
+// ModifyPlan hashes the code package so that editing the file in place, not
+// just changing its path, redeploys the function. A package that does not
+// exist yet at plan time leaves source_hash unknown. Toggling enable_url
+// leaves invoke_url unknown.
+func (r *FunctionResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+	if !req.State.Raw.IsNull() {
+		var planURL, stateURL types.Bool
+		resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("enable_url"), &planURL)...)
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("enable_url"), &stateURL)...)
+		if planURL.ValueBool() != stateURL.ValueBool() {
+			resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("invoke_url"), types.StringUnknown())...)
+		}
+	}
+	var code types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("code"), &code)...)
+	if code.IsUnknown() || code.IsNull() {
+		return
+	}
+	codeBytes, err := ioutil.ReadFile(code.ValueString())
+	if err != nil {
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("source_hash"), sourceHash(codeBytes))...)
+}
+
+// Update redeploys the code when its hash changed and applies runtime,
+// handler, role and environment changes, waiting for both to finish.
 func (r *FunctionResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state functionResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	var codeBytes []byte
+	var codeChanged bool
+	if plan.image() {
+		plan.SourceHash = types.StringNull()
+		codeChanged = !plan.ImageURI.Equal(state.ImageURI)
+	} else {
+		var err error
+		codeBytes, err = ioutil.ReadFile(plan.Code.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("read code", err.Error())
+			return
+		}
+		plan.SourceHash = types.StringValue(sourceHash(codeBytes))
+		codeChanged = !plan.SourceHash.Equal(state.SourceHash)
+	}
+	envChanged := !maps.Equal(stringMap(plan.Environment), stringMap(state.Environment))
+	if plan.MemoryMB.IsUnknown() {
+		plan.MemoryMB = state.MemoryMB
+	}
+	if plan.TimeoutSeconds.IsUnknown() {
+		plan.TimeoutSeconds = state.TimeoutSeconds
+	}
+	limitsChanged := !plan.MemoryMB.Equal(state.MemoryMB) || !plan.TimeoutSeconds.Equal(state.TimeoutSeconds)
+	annotationsChanged := !maps.Equal(stringMap(plan.Annotations), stringMap(state.Annotations))
+	configChanged := envChanged || limitsChanged || annotationsChanged || !plan.Runtime.Equal(state.Runtime) || !plan.Handler.Equal(state.Handler) || !plan.RoleARN.Equal(state.RoleARN)
+	switch plan.Type.ValueString() {
+	case "aws":
+		name := aws.String(state.ID.ValueString())
+		waiter := lambda.NewFunctionUpdatedV2Waiter(r.lambda)
+		if codeChanged {
+			input := &lambda.UpdateFunctionCodeInput{FunctionName: name, ZipFile: codeBytes}
+			if plan.image() {
+				input = &lambda.UpdateFunctionCodeInput{FunctionName: name, ImageUri: aws.String(plan.ImageURI.ValueString())}
+			}
+			if _, err := r.lambda.UpdateFunctionCode(ctx, input); err != nil {
+				resp.Diagnostics.AddError("aws update code", err.Error())
+				return
+			}
+			if err := waiter.Wait(ctx, &lambda.GetFunctionInput{FunctionName: name}, functionUpdateTimeout); err != nil {
+				resp.Diagnostics.AddError("aws update code", err.Error())
+				return
+			}
+		}
+		if configChanged {
+			input := &lambda.UpdateFunctionConfigurationInput{
+				FunctionName: name,
+				Environment:  &lambdatypes.Environment{Variables: stringMap(plan.Environment)},
+				MemorySize:   aws.Int32(int32(plan.MemoryMB.ValueInt64())),
+				Timeout:      aws.Int32(int32(plan.TimeoutSeconds.ValueInt64())),
+			}
+			if !plan.image() {
+				input.Runtime = lambdatypes.Runtime(plan.Runtime.ValueString())
+				input.Handler = aws.String(plan.Handler.ValueString())
+			}
+			if !plan.RoleARN.Equal(state.RoleARN) {
+				role := roleARN(plan.RoleARN, "role_arn", "LAMBDA_ROLE_ARN", &resp.Diagnostics)
+				if role == "" {
+					resp.Diagnostics.AddAttributeError(path.Root("role_arn"), "missing role", "role_arn must be set for aws functions")
+					return
+				}
+				input.Role = aws.String(role)
+			}
+			if _, err := r.lambda.UpdateFunctionConfiguration(ctx, input); err != nil {
+				resp.Diagnostics.AddError("aws update configuration", err.Error())
+				return
+			}
+			if err := waiter.Wait(ctx, &lambda.GetFunctionInput{FunctionName: name}, functionUpdateTimeout); err != nil {
+				resp.Diagnostics.AddError("aws update configuration", err.Error())
+				return
+			}
+		}
+		plan.InvokeURL = state.InvokeURL
+		switch {
+		case plan.EnableURL.ValueBool() && !state.EnableURL.ValueBool():
+			url, err := r.lambdaURL(ctx, state.ID.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError("aws function url", err.Error())
+				return
+			}
+			plan.InvokeURL = types.StringValue(url)
+		case !plan.EnableURL.ValueBool() && state.EnableURL.ValueBool():
+			if _, err := r.lambda.DeleteFunctionUrlConfig(ctx, &lambda.DeleteFunctionUrlConfigInput{FunctionName: name}); err != nil {
+				resp.Diagnostics.AddError("aws function url", err.Error())
+				return
+			}
+			plan.InvokeURL = types.StringNull()
+		}
+	case "azure":
+		site := state.ID.ValueString()
+		if envChanged || limitsChanged {
+			// keep the settings the runtime manages and replace the rest
+			current, err := r.azureWeb.ListApplicationSettings(ctx, "abstract-rg", site, nil)
+			if err != nil {
+				resp.Diagnostics.AddError("azure update settings", err.Error())
+				return
+			}
+			settings := map[string]*string{}
+			for k, v := range current.Properties {
+				if azureReservedSetting(k) {
+					settings[k] = v
+				}
+			}
+			for k, v := range plan.azureSettings() {
+				settings[k] = to.Ptr(v)
+			}
+			if _, err := r.azureWeb.UpdateApplicationSettings(ctx, "abstract-rg", site, armappservice.StringDictionary{Properties: settings}, nil); err != nil {
+				resp.Diagnostics.AddError("azure update settings", err.Error())
+				return
+			}
+		}
+		if codeChanged && plan.image() {
+			cfg := armappservice.SiteConfigResource{Properties: &armappservice.SiteConfig{LinuxFxVersion: to.Ptr("DOCKER|" + plan.ImageURI.ValueString())}}
+			if _, err := r.azureWeb.UpdateConfiguration(ctx, "abstract-rg", site, cfg, nil); err != nil {
+				resp.Diagnostics.AddError("azure update image", err.Error())
+				return
+			}
+		} else if codeChanged {
+			if err := r.azureZipDeploy(ctx, site, codeBytes); err != nil {
+				resp.Diagnostics.AddError("azure deploy", err.Error())
+				return
+			}
+		}
+	case "gcp":
+		if !codeChanged && !configChanged {
+			break
+		}
+		parent := r.gcpParent(&plan)
+		if plan.image() {
+			svc, err := r.putCloudRun(ctx, parent, state.ID.ValueString(), &plan, false)
+			if err != nil {
+				resp.Diagnostics.AddError("gcp cloud run", err.Error())
+				return
+			}
+			plan.InvokeURL = types.StringValue(svc.Uri)
+			break
+		}
+		if plan.gen2() {
+			var code []byte
+			if codeChanged {
+				code = codeBytes
+			}
+			fn, err := r.putFunctionV2(ctx, parent, state.ID.ValueString(), &plan, code, false)
+			if err != nil {
+				resp.Diagnostics.AddError("gcp update", err.Error())
+				return
+			}
+			resp.Diagnostics.Append(plan.setFunctionV2(ctx, fn)...)
+			break
+		}
+		cf := &cloudfunctions.CloudFunction{
+			EntryPoint:           plan.Handler.ValueString(),
+			Runtime:              plan.Runtime.ValueString(),
+			EnvironmentVariables: stringMap(plan.Environment),
+			AvailableMemoryMb:    plan.MemoryMB.ValueInt64(),
+			Timeout:              fmt.Sprintf("%ds", plan.TimeoutSeconds.ValueInt64()),
+		}
+		mask := []string{"entryPoint", "runtime", "environmentVariables", "availableMemoryMb", "timeout"}
+		if codeChanged {
+			uploadURL, err := r.gcpUpload(ctx, parent, codeBytes)
+			if err != nil {
+				resp.Diagnostics.AddError("gcp upload", err.Error())
+				return
+			}
+			cf.SourceUploadUrl = uploadURL
+			mask = append(mask, "sourceUploadUrl")
+		}
+		op, err := r.gcpFunc.Projects.Locations.Functions.Patch(parent+"/functions/"+state.ID.ValueString(), cf).UpdateMask(strings.Join(mask, ",")).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp update", err.Error())
+			return
+		}
+		if err := r.gcpWait(ctx, op.Name); err != nil {
+			resp.Diagnostics.AddError("gcp update", err.Error())
+			return
+		}
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	plan.Account = state.Account
+	plan.AccountCreated = state.AccountCreated
+	plan.Plan = state.Plan
+	plan.ResourceGroup = state.ResourceGroup
+	if plan.InvokeURL.IsUnknown() {
+		plan.InvokeURL = state.InvokeURL
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
-func (r *FunctionResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		ID            types.String `tfsdk:"id"`
-		Type          types.String `tfsdk:"type"`
-		Plan          types.String `tfsdk:"plan"`
-		Account       types.String `tfsdk:"account"`
-		ResourceGroup types.String `tfsdk:"resource_group"`
+
+// lambdaURL creates an IAM-authenticated function URL and returns it.
+func (r *FunctionResource) lambdaURL(ctx context.Context, name string) (string, error) {
+	out, err := r.lambda.CreateFunctionUrlConfig(ctx, &lambda.CreateFunctionUrlConfigInput{
+		FunctionName: aws.String(name),
+		AuthType:     lambdatypes.FunctionUrlAuthTypeAwsIam,
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.FunctionUrl), nil
+}
+
+// azureSiteURL returns the HTTPS address of a function app.
+func azureSiteURL(site armappservice.Site) types.String {
+	if site.Properties == nil || site.Properties.DefaultHostName == nil {
+		return types.StringNull()
 	}
+	return types.StringValue("https://" + *site.Properties.DefaultHostName)
+}
+
+// gcpFunctionURL returns the HTTPS trigger URL of a Cloud Function.
+func gcpFunctionURL(fn *cloudfunctions.CloudFunction) types.String {
+	if fn.HttpsTrigger == nil || fn.HttpsTrigger.Url == "" {
+		return types.StringNull()
+	}
+	return types.StringValue(fn.HttpsTrigger.Url)
+}
+
+// functionUpdateTimeout bounds the wait for a Lambda update to finish.
+const functionUpdateTimeout = 5 * time.Minute
+
+// sourceHash returns the base64 SHA-256 of a code package, the format Lambda
+// reports as CodeSha256.
+func sourceHash(code []byte) string {
+	sum := sha256.Sum256(code)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// gcpUploadTimeout bounds the upload of a function package to Cloud Storage.
+const gcpUploadTimeout = 10 * time.Minute
+
+// gcpUploadLimit is the content length range the signed upload URL accepts,
+// the Cloud Functions limit for a zipped source package.
+const gcpUploadLimit = "0,104857600"
+
+// gcpUpload uploads a zip package for a function in parent and returns the
+// upload URL to reference from the function.
+func (r *FunctionResource) gcpUpload(ctx context.Context, parent string, code []byte) (string, error) {
+	urlResp, err := r.gcpFunc.Projects.Locations.Functions.GenerateUploadUrl(parent, &cloudfunctions.GenerateUploadUrlRequest{}).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	if err := putPackage(ctx, urlResp.UploadUrl, code, gcpUploadLimit); err != nil {
+		return "", err
+	}
+	return urlResp.UploadUrl, nil
+}
+
+// putPackage uploads a zip package to a signed upload URL. The URL only
+// accepts requests carrying exactly the headers it was signed for, so
+// lengthRange is only sent when set.
+func putPackage(ctx context.Context, uploadURL string, code []byte, lengthRange string) error {
+	ctx, cancel := context.WithTimeout(ctx, gcpUploadTimeout)
+	defer cancel()
+	reqUpload, err := http.NewRequestWithContext(ctx, http.MethodPut, uploadURL, bytes.NewReader(code))
+	if err != nil {
+		return err
+	}
+	reqUpload.Header.Set("Content-Type", "application/zip")
+	if lengthRange != "" {
+		reqUpload.Header.Set("x-goog-content-length-range", lengthRange)
+	}
+	res, err := http.DefaultClient.Do(reqUpload)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("%s: %s", res.Status, body)
+	}
+	return nil
+}
+
+// gcpParent returns the location functions are created in: the function's
+// region, else the provider region, else us-central1.
+func (r *FunctionResource) gcpParent(m *functionResourceModel) string {
+	region := m.Region.ValueString()
+	if region == "" {
+		region = r.gcpRegion
+	}
+	if region == "" {
+		region = "us-central1"
+	}
+	return "projects/" + r.gcpProj + "/locations/" + region
+}
+
+// putCloudRun creates or replaces the Cloud Run service that runs an image
+// function, waits for the rollout and returns the service.
+func (r *FunctionResource) putCloudRun(ctx context.Context, parent, id string, m *functionResourceModel, create bool) (*run.GoogleCloudRunV2Service, error) {
+	vars := stringMap(m.Environment)
+	var env []*run.GoogleCloudRunV2EnvVar
+	for _, k := range slices.Sorted(maps.Keys(vars)) {
+		env = append(env, &run.GoogleCloudRunV2EnvVar{Name: k, Value: vars[k]})
+	}
+	svc := &run.GoogleCloudRunV2Service{
+		Template: &run.GoogleCloudRunV2RevisionTemplate{
+			Containers: []*run.GoogleCloudRunV2Container{{
+				Image: m.ImageURI.ValueString(),
+				Env:   env,
+				Resources: &run.GoogleCloudRunV2ResourceRequirements{
+					Limits: map[string]string{"memory": fmt.Sprintf("%dMi", m.MemoryMB.ValueInt64())},
+				},
+			}},
+			Timeout: fmt.Sprintf("%ds", m.TimeoutSeconds.ValueInt64()),
+		},
+	}
+	if annotations := stringMap(m.Annotations); len(annotations) > 0 {
+		svc.Annotations = annotations
+	}
+	name := parent + "/services/" + id
+	var op *run.GoogleLongrunningOperation
+	var err error
+	if create {
+		op, err = r.gcpRun.Projects.Locations.Services.Create(parent, svc).ServiceId(id).Context(ctx).Do()
+	} else {
+		op, err = r.gcpRun.Projects.Locations.Services.Patch(name, svc).Context(ctx).Do()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cloudRunWait(ctx, r.gcpRun, op.Name); err != nil {
+		return nil, err
+	}
+	return r.gcpRun.Projects.Locations.Services.Get(name).Context(ctx).Do()
+}
+
+// cloudRunReservedPrefixes are annotation prefixes Cloud Run sets itself and
+// rejects on a service.
+var cloudRunReservedPrefixes = []string{"run.googleapis.com/", "cloud.googleapis.com/", "serving.knative.dev/", "autoscaling.knative.dev/"}
+
+// validateCloudRunAnnotations rejects annotations Cloud Run would refuse.
+func validateCloudRunAnnotations(annotations map[string]string) error {
+	for _, k := range slices.Sorted(maps.Keys(annotations)) {
+		for _, prefix := range cloudRunReservedPrefixes {
+			if strings.HasPrefix(k, prefix) {
+				return fmt.Errorf("annotation %q uses the reserved prefix %s", k, prefix)
+			}
+		}
+	}
+	return nil
+}
+
+// gcpDuration parses a duration such as "60s" in seconds.
+func gcpDuration(v string) (int64, bool) {
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return int64(d / time.Second), true
+}
+
+// cloudRunMemory parses a Cloud Run memory limit such as "512Mi" or "2Gi" in MB.
+func cloudRunMemory(v string) (int64, bool) {
+	var n int64
+	var unit string
+	if _, err := fmt.Sscanf(v, "%d%s", &n, &unit); err != nil {
+		return 0, false
+	}
+	switch unit {
+	case "Mi":
+		return n, true
+	case "Gi":
+		return n * 1024, true
+	}
+	return 0, false
+}
+
+// cloudRunWait polls a Cloud Run operation until it is done.
+func cloudRunWait(ctx context.Context, svc *run.Service, name string) error {
+	for {
+		oper, err := svc.Projects.Locations.Operations.Get(name).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+		if oper.Done {
+			if oper.Error != nil {
+				return fmt.Errorf("%s", oper.Error.Message)
+			}
+			return nil
+		}
+		if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+			return err
+		}
+	}
+}
+
+// gcpWait polls a Cloud Functions operation until it is done.
+func (r *FunctionResource) gcpWait(ctx context.Context, name string) error {
+	for {
+		oper, err := r.gcpFunc.Operations.Get(name).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+		if oper.Done {
+			if oper.Error != nil {
+				return fmt.Errorf("%s", oper.Error.Message)
+			}
+			return nil
+		}
+		if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+			return err
+		}
+	}
+}
+
+// azureZipDeploy pushes a zip package to a function app through its Kudu
+// endpoint and waits for the deployment to finish.
+func (r *FunctionResource) azureZipDeploy(ctx context.Context, site string, code []byte) error {
+	if r.azureCred == nil {
+		return fmt.Errorf("missing credential")
+	}
+	tok, err := r.azureCred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://management.azure.com/.default"}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+site+".scm.azurewebsites.net/api/zipdeploy", bytes.NewReader(code))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok.Token)
+	req.Header.Set("Content-Type", "application/zip")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("%s: %s", res.Status, body)
+	}
+	return nil
+}
+func (r *FunctionResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state functionResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.lambda == nil {
-			return
-		}
 		_, err := r.lambda.DeleteFunction(ctx, &lambda.DeleteFunctionInput{FunctionName: aws.String(state.ID.ValueString())})
 		if err != nil {
 			resp.Diagnostics.AddError("aws delete", err.Error())
 		}
        case "azure":
-               if r.azureWeb == nil {
-                       return
-               }
                _, err := r.azureWeb.Delete(ctx, "abstract-rg", state.ID.ValueString(), nil)
                if err != nil {
                        resp.Diagnostics.AddError("azure delete", err.Error())
@@ -347,18 +1303,32 @@ func (r *FunctionResource) Delete(ctx context.Context, req resource.DeleteReques
                if r.azurePlan != nil && state.Plan.ValueString() != "" {
                        _, _ = r.azurePlan.Delete(ctx, "abstract-rg", state.Plan.ValueString(), nil)
                }
-               if r.azureAcct != nil && state.ResourceGroup.ValueString() != "" && state.Account.ValueString() != "" {
+               // a shared or pre-existing storage account outlives the function
+               if r.azureAcct != nil && state.AccountCreated.ValueBool() {
                        _, _ = r.azureAcct.Delete(ctx, state.ResourceGroup.ValueString(), state.Account.ValueString(), nil)
                }
        case "gcp":
-               if r.gcpFunc == nil {
-                       return
-               }
-               region := r.gcpRegion
-               if region == "" {
-                       region = "us-central1"
-               }
-               op, err := r.gcpFunc.Projects.Locations.Functions.Delete("projects/" + r.gcpProj + "/locations/" + region + "/functions/" + state.ID.ValueString()).Context(ctx).Do()
+		if state.image() {
+			op, err := r.gcpRun.Projects.Locations.Services.Delete(r.gcpParent(&state) + "/services/" + state.ID.ValueString()).Context(ctx).Do()
+			if err == nil {
+				err = cloudRunWait(ctx, r.gcpRun, op.Name)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp delete", err.Error())
+			}
+			return
+		}
+		if state.gen2() {
+			op, err := r.gcpFuncV2.Projects.Locations.Functions.Delete(r.gcpParent(&state) + "/functions/" + state.ID.ValueString()).Context(ctx).Do()
+			if err == nil {
+				err = r.gcpWaitV2(ctx, op.Name)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp delete", err.Error())
+			}
+			return
+		}
+               op, err := r.gcpFunc.Projects.Locations.Functions.Delete(r.gcpParent(&state) + "/functions/" + state.ID.ValueString()).Context(ctx).Do()
                if err != nil {
                        resp.Diagnostics.AddError("gcp delete", err.Error())
                        return
@@ -372,7 +1342,10 @@ func (r *FunctionResource) Delete(ctx context.Context, req resource.DeleteReques
                        if oper.Done {
                                break
                        }
-                       time.Sleep(5 * time.Second)
+                       if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+                               resp.Diagnostics.AddError("gcp delete", err.Error())
+                               return
+                       }
                }
        }
 }
@@ -0,0 +1,339 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	cloudfunctions "google.golang.org/api/cloudfunctions/v1"
+	cloudfunctionsv2 "google.golang.org/api/cloudfunctions/v2"
+	"google.golang.org/api/option"
+)
+
+func TestRoleARN(t *testing.T) {
+	t.Setenv("LAMBDA_ROLE_ARN", "arn:aws:iam::123456789012:role/from-env")
+
+	var diags diag.Diagnostics
+	got := roleARN(types.StringValue("arn:aws:iam::123456789012:role/configured"), "role_arn", "LAMBDA_ROLE_ARN", &diags)
+	if got != "arn:aws:iam::123456789012:role/configured" || diags.WarningsCount() != 0 {
+		t.Errorf("attribute: got %q, diagnostics %v", got, diags)
+	}
+
+	got = roleARN(types.StringNull(), "role_arn", "LAMBDA_ROLE_ARN", &diags)
+	if got != "arn:aws:iam::123456789012:role/from-env" || diags.WarningsCount() != 1 {
+		t.Errorf("env fallback: got %q, diagnostics %v", got, diags)
+	}
+
+	t.Setenv("LAMBDA_ROLE_ARN", "")
+	diags = nil
+	if got := roleARN(types.StringNull(), "role_arn", "LAMBDA_ROLE_ARN", &diags); got != "" || diags.WarningsCount() != 0 {
+		t.Errorf("unset: got %q, diagnostics %v", got, diags)
+	}
+}
+
+func TestFunctionEnvironment(t *testing.T) {
+	got := userAppSettings(map[string]*string{
+		"AzureWebJobsStorage":         to.Ptr("DefaultEndpointsProtocol=https"),
+		"FUNCTIONS_EXTENSION_VERSION": to.Ptr("~4"),
+		"WEBSITE_RUN_FROM_PACKAGE":    to.Ptr("1"),
+		"LOG_LEVEL":                   to.Ptr("debug"),
+	})
+	if want := map[string]string{"LOG_LEVEL": "debug"}; !maps.Equal(got, want) {
+		t.Errorf("userAppSettings = %v, want %v", got, want)
+	}
+
+	ctx := context.Background()
+	var m functionResourceModel
+	m.Environment = types.MapNull(types.StringType)
+	m.setEnvironment(ctx, map[string]string{})
+	if !m.Environment.IsNull() {
+		t.Errorf("empty environment set %v", m.Environment)
+	}
+	m.setEnvironment(ctx, map[string]string{"LOG_LEVEL": "debug"})
+	if got := stringMap(m.Environment); got["LOG_LEVEL"] != "debug" || len(got) != 1 {
+		t.Errorf("environment = %v", got)
+	}
+	m.setEnvironment(ctx, nil)
+	if m.Environment.IsNull() || len(m.Environment.Elements()) != 0 {
+		t.Errorf("removed variables not recorded: %v", m.Environment)
+	}
+}
+
+func TestFunctionModifyPlanSourceHash(t *testing.T) {
+	r := &FunctionResource{}
+	code := filepath.Join(t.TempDir(), "fn.zip")
+	if err := os.WriteFile(code, []byte("package"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, code string
+		known      bool
+	}{
+		{"existing package", code, true},
+		{"missing package", filepath.Join(t.TempDir(), "missing.zip"), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := testPlan(t, r, map[string]tftypes.Value{"name": str("fn"), "type": str("aws"), "runtime": str("python3.12"), "handler": str("app.handler"), "code": str(tc.code)})
+			resp := &resource.ModifyPlanResponse{Plan: plan}
+			r.ModifyPlan(context.Background(), resource.ModifyPlanRequest{Plan: plan}, resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatal(resp.Diagnostics)
+			}
+			var hash types.String
+			resp.Plan.GetAttribute(context.Background(), path.Root("source_hash"), &hash)
+			if hash.IsUnknown() == tc.known {
+				t.Fatalf("source_hash = %v", hash)
+			}
+			if tc.known && hash.ValueString() != "vEpxGAhw95RRVfuwL0sKLj+qKmLW0xtwOQEwVe0Zhpo=" {
+				t.Errorf("source_hash = %q", hash.ValueString())
+			}
+		})
+	}
+}
+
+func TestFunctionModifyPlanInvokeURL(t *testing.T) {
+	r := &FunctionResource{}
+	base := map[string]tftypes.Value{"name": str("fn"), "type": str("aws"), "runtime": str("python3.12"), "handler": str("app.handler"), "code": str("fn.zip")}
+	with := func(extra map[string]tftypes.Value) map[string]tftypes.Value {
+		vals := maps.Clone(base)
+		maps.Copy(vals, extra)
+		return vals
+	}
+	state := testState(t, r, with(map[string]tftypes.Value{"id": str("fn"), "invoke_url": str("https://abc.lambda-url.us-east-1.on.aws/"), "enable_url": boolean(true)}))
+	for _, tc := range []struct {
+		name    string
+		enable  bool
+		unknown bool
+	}{
+		{"unchanged", true, false},
+		{"disabled", false, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := testPlan(t, r, with(map[string]tftypes.Value{"id": str("fn"), "invoke_url": str("https://abc.lambda-url.us-east-1.on.aws/"), "enable_url": boolean(tc.enable)}))
+			resp := &resource.ModifyPlanResponse{Plan: plan}
+			r.ModifyPlan(context.Background(), resource.ModifyPlanRequest{Plan: plan, State: state}, resp)
+			var url types.String
+			resp.Plan.GetAttribute(context.Background(), path.Root("invoke_url"), &url)
+			if url.IsUnknown() != tc.unknown {
+				t.Errorf("invoke_url = %v", url)
+			}
+		})
+	}
+}
+
+func TestGCPUpload(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		ok     bool
+	}{
+		{"uploaded", http.StatusOK, true},
+		{"rejected", http.StatusForbidden, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var srv *httptest.Server
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if strings.HasSuffix(req.URL.Path, ":generateUploadUrl") {
+					fmt.Fprintf(w, `{"uploadUrl": %q}`, srv.URL+"/upload")
+					return
+				}
+				body, _ := io.ReadAll(req.Body)
+				if req.Method != http.MethodPut || string(body) != "package" ||
+					req.Header.Get("Content-Type") != "application/zip" || req.Header.Get("x-goog-content-length-range") != gcpUploadLimit {
+					t.Errorf("upload %s %q with headers %v", req.Method, body, req.Header)
+				}
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, "<Error>AccessDenied</Error>")
+			}))
+			defer srv.Close()
+			svc, err := cloudfunctions.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &FunctionResource{gcpFunc: svc}
+			url, err := r.gcpUpload(context.Background(), "projects/p/locations/us-central1", []byte("package"))
+			if (err == nil) != tc.ok {
+				t.Fatalf("err = %v", err)
+			}
+			if tc.ok && url != srv.URL+"/upload" {
+				t.Errorf("url = %q", url)
+			}
+			if !tc.ok && !strings.Contains(err.Error(), "AccessDenied") {
+				t.Errorf("err = %v, want the response body", err)
+			}
+		})
+	}
+}
+
+func TestGCPUploadV2(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, ":generateUploadUrl") {
+			fmt.Fprintf(w, `{"uploadUrl": %q, "storageSource": {"bucket": "gcf-v2-uploads", "object": "fn.zip"}}`, srv.URL+"/upload")
+			return
+		}
+		if req.Method != http.MethodPut || req.Header.Get("x-goog-content-length-range") != "" {
+			t.Errorf("upload %s with headers %v", req.Method, req.Header)
+		}
+	}))
+	defer srv.Close()
+	svc, err := cloudfunctionsv2.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &FunctionResource{gcpFuncV2: svc}
+	src, err := r.gcpUploadV2(context.Background(), "projects/p/locations/us-central1", []byte("package"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src == nil || src.Bucket != "gcf-v2-uploads" || src.Object != "fn.zip" {
+		t.Errorf("storage source = %+v", src)
+	}
+}
+
+func TestFunctionPackageConfig(t *testing.T) {
+	r := &FunctionResource{}
+	s := testSchema(t, r)
+	zip := map[string]tftypes.Value{"name": str("fn"), "type": str("aws"), "runtime": str("python3.12"), "handler": str("app.handler"), "code": str("fn.zip"),
+		"role_arn": str("arn:aws:iam::123456789012:role/fn")}
+	image := map[string]tftypes.Value{"name": str("fn"), "type": str("gcp"), "package_type": str("image"), "image_uri": str("us-docker.pkg.dev/p/r/fn:1")}
+	with := func(base map[string]tftypes.Value, extra map[string]tftypes.Value) map[string]tftypes.Value {
+		vals := maps.Clone(base)
+		maps.Copy(vals, extra)
+		return vals
+	}
+	cases := []struct {
+		name string
+		vals map[string]tftypes.Value
+		ok   bool
+	}{
+		{"zip", zip, true},
+		{"zip with image", with(zip, map[string]tftypes.Value{"image_uri": str("repo/fn:1")}), false},
+		{"zip without handler", with(zip, map[string]tftypes.Value{"handler": tftypes.NewValue(tftypes.String, nil)}), false},
+		{"image", image, true},
+		{"image with code", with(image, map[string]tftypes.Value{"code": str("fn.zip")}), false},
+		{"image without uri", with(image, map[string]tftypes.Value{"image_uri": tftypes.NewValue(tftypes.String, nil)}), false},
+		{"unknown type", with(zip, map[string]tftypes.Value{"package_type": str("jar")}), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() == tc.ok {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestFunctionLimitsConfig(t *testing.T) {
+	r := &FunctionResource{}
+	s := testSchema(t, r)
+	fn := func(cloud string, extra map[string]tftypes.Value) map[string]tftypes.Value {
+		vals := map[string]tftypes.Value{"name": str("fn"), "type": str(cloud), "runtime": str("python3.12"), "handler": str("main"), "code": str("fn.zip")}
+		if cloud == "aws" {
+			vals["role_arn"] = str("arn:aws:iam::123456789012:role/fn")
+		}
+		maps.Copy(vals, extra)
+		return vals
+	}
+	cases := []struct {
+		name string
+		vals map[string]tftypes.Value
+		ok   bool
+	}{
+		{"aws", fn("aws", map[string]tftypes.Value{"memory_mb": number(1769), "timeout_seconds": number(900)}), true},
+		{"aws memory too small", fn("aws", map[string]tftypes.Value{"memory_mb": number(64)}), false},
+		{"aws timeout too long", fn("aws", map[string]tftypes.Value{"timeout_seconds": number(901)}), false},
+		{"gcp", fn("gcp", map[string]tftypes.Value{"memory_mb": number(2048), "timeout_seconds": number(540)}), true},
+		{"gcp uneven memory", fn("gcp", map[string]tftypes.Value{"memory_mb": number(1000)}), false},
+		{"gcp gen2", fn("gcp", map[string]tftypes.Value{"generation": number(2), "memory_mb": number(16384), "timeout_seconds": number(3600)}), true},
+		{"gcp gen1 timeout too long", fn("gcp", map[string]tftypes.Value{"generation": number(1), "timeout_seconds": number(3600)}), false},
+		{"gcp unknown generation", fn("gcp", map[string]tftypes.Value{"generation": number(3)}), false},
+		{"cloud run", map[string]tftypes.Value{"name": str("fn"), "type": str("gcp"), "package_type": str("image"), "image_uri": str("repo/fn:1"), "memory_mb": number(1000), "timeout_seconds": number(3600)}, true},
+		{"cloud run annotations", map[string]tftypes.Value{"name": str("fn"), "type": str("gcp"), "package_type": str("image"), "image_uri": str("repo/fn:1"), "annotations": strMap(map[string]string{"example.com/owner": "web"})}, true},
+		{"cloud run reserved annotation", map[string]tftypes.Value{"name": str("fn"), "type": str("gcp"), "package_type": str("image"), "image_uri": str("repo/fn:1"), "annotations": strMap(map[string]string{"run.googleapis.com/ingress": "all"})}, false},
+		{"azure timeout", fn("azure", map[string]tftypes.Value{"timeout_seconds": number(600)}), true},
+		{"azure timeout too long", fn("azure", map[string]tftypes.Value{"timeout_seconds": number(601)}), false},
+		{"azure memory", fn("azure", map[string]tftypes.Value{"memory_mb": number(512)}), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() == tc.ok {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestFunctionTimeoutFormats(t *testing.T) {
+	m := functionResourceModel{Environment: types.MapNull(types.StringType), TimeoutSeconds: types.Int64Value(1830)}
+	v := m.azureSettings()[azureTimeoutSetting]
+	if v != "00:30:30" {
+		t.Fatalf("%s = %q", azureTimeoutSetting, v)
+	}
+	if secs, ok := azureTimeout(v); !ok || secs != 1830 {
+		t.Errorf("azureTimeout(%q) = %d, %v", v, secs, ok)
+	}
+	if secs, ok := gcpDuration("60s"); !ok || secs != 60 {
+		t.Errorf("gcpDuration = %d, %v", secs, ok)
+	}
+	for in, want := range map[string]int64{"512Mi": 512, "2Gi": 2048} {
+		if got, ok := cloudRunMemory(in); !ok || got != want {
+			t.Errorf("cloudRunMemory(%q) = %d, %v", in, got, ok)
+		}
+	}
+	if _, ok := cloudRunMemory("1G"); ok {
+		t.Error("decimal units should not parse")
+	}
+}
+
+func TestFunctionRoleConfig(t *testing.T) {
+	t.Setenv("LAMBDA_ROLE_ARN", "")
+	r := &FunctionResource{}
+	s := testSchema(t, r)
+	fn := func(cloud string, role tftypes.Value) map[string]tftypes.Value {
+		return map[string]tftypes.Value{"name": str("fn"), "type": str(cloud), "runtime": str("python3.12"), "handler": str("main"), "code": str("fn.zip"), "role_arn": role}
+	}
+	null := tftypes.NewValue(tftypes.String, nil)
+	cases := []struct {
+		name             string
+		vals             map[string]tftypes.Value
+		env              string
+		errors, warnings int
+	}{
+		{"aws with role", fn("aws", str("arn:aws:iam::123456789012:role/fn")), "", 0, 0},
+		{"aws without role", fn("aws", null), "", 1, 0},
+		{"aws with env role", fn("aws", null), "arn:aws:iam::123456789012:role/fn", 0, 0},
+		{"aws with unknown role", fn("aws", tftypes.NewValue(tftypes.String, tftypes.UnknownValue)), "", 0, 0},
+		{"gcp without role", fn("gcp", null), "", 0, 0},
+		{"gcp with role", fn("gcp", str("arn:aws:iam::123456789012:role/fn")), "", 0, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("LAMBDA_ROLE_ARN", tc.env)
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.ErrorsCount() != tc.errors || resp.Diagnostics.WarningsCount() != tc.warnings {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	cloudfunctionsv2 "google.golang.org/api/cloudfunctions/v2"
+)
+
+// gen2 reports whether the function is a 2nd gen Cloud Function. Other clouds
+// and image functions ignore generation.
+func (m *functionResourceModel) gen2() bool {
+	return m.Type.ValueString() == "gcp" && !m.image() && m.Generation.ValueInt64() == 2
+}
+
+// gcpUploadV2 uploads a zip package for a 2nd gen function in parent and
+// returns where it was stored.
+func (r *FunctionResource) gcpUploadV2(ctx context.Context, parent string, code []byte) (*cloudfunctionsv2.StorageSource, error) {
+	urlResp, err := r.gcpFuncV2.Projects.Locations.Functions.GenerateUploadUrl(parent, &cloudfunctionsv2.GenerateUploadUrlRequest{Environment: "GEN_2"}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if err := putPackage(ctx, urlResp.UploadUrl, code, ""); err != nil {
+		return nil, err
+	}
+	return urlResp.StorageSource, nil
+}
+
+// putFunctionV2 creates or updates a 2nd gen function, waits for the
+// deployment and returns the function. A nil code keeps the deployed source.
+func (r *FunctionResource) putFunctionV2(ctx context.Context, parent, id string, m *functionResourceModel, code []byte, create bool) (*cloudfunctionsv2.Function, error) {
+	fn := &cloudfunctionsv2.Function{
+		Environment: "GEN_2",
+		BuildConfig: &cloudfunctionsv2.BuildConfig{
+			Runtime:    m.Runtime.ValueString(),
+			EntryPoint: m.Handler.ValueString(),
+		},
+		ServiceConfig: &cloudfunctionsv2.ServiceConfig{
+			AvailableMemory:      fmt.Sprintf("%dMi", m.MemoryMB.ValueInt64()),
+			TimeoutSeconds:       m.TimeoutSeconds.ValueInt64(),
+			EnvironmentVariables: stringMap(m.Environment),
+		},
+	}
+	mask := []string{"buildConfig.runtime", "buildConfig.entryPoint", "serviceConfig.availableMemory", "serviceConfig.timeoutSeconds", "serviceConfig.environmentVariables"}
+	if code != nil {
+		src, err := r.gcpUploadV2(ctx, parent, code)
+		if err != nil {
+			return nil, fmt.Errorf("upload: %w", err)
+		}
+		fn.BuildConfig.Source = &cloudfunctionsv2.Source{StorageSource: src}
+		mask = append(mask, "buildConfig.source")
+	}
+	name := parent + "/functions/" + id
+	var op *cloudfunctionsv2.Operation
+	var err error
+	if create {
+		op, err = r.gcpFuncV2.Projects.Locations.Functions.Create(parent, fn).FunctionId(id).Context(ctx).Do()
+	} else {
+		op, err = r.gcpFuncV2.Projects.Locations.Functions.Patch(name, fn).UpdateMask(strings.Join(mask, ",")).Context(ctx).Do()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.gcpWaitV2(ctx, op.Name); err != nil {
+		return nil, err
+	}
+	return r.gcpFuncV2.Projects.Locations.Functions.Get(name).Context(ctx).Do()
+}
+
+// setFunctionV2 records the environment, limits and URL of a 2nd gen function.
+func (m *functionResourceModel) setFunctionV2(ctx context.Context, fn *cloudfunctionsv2.Function) diag.Diagnostics {
+	m.InvokeURL = types.StringNull()
+	if fn.Url != "" {
+		m.InvokeURL = types.StringValue(fn.Url)
+	}
+	sc := fn.ServiceConfig
+	if sc == nil {
+		return nil
+	}
+	if mem, ok := cloudRunMemory(sc.AvailableMemory); ok {
+		m.MemoryMB = types.Int64Value(mem)
+	}
+	if sc.TimeoutSeconds > 0 {
+		m.TimeoutSeconds = types.Int64Value(sc.TimeoutSeconds)
+	}
+	return m.setEnvironment(ctx, sc.EnvironmentVariables)
+}
+
+// gcpWaitV2 polls a 2nd gen Cloud Functions operation until it is done.
+func (r *FunctionResource) gcpWaitV2(ctx context.Context, name string) error {
+	for {
+		oper, err := r.gcpFuncV2.Projects.Locations.Operations.Get(name).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+		if oper.Done {
+			if oper.Error != nil {
+				return fmt.Errorf("%s", oper.Error.Message)
+			}
+			return nil
+		}
+		if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package resources
+
+import (
+	"abstract-provider/provider/shared"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// checkGCPLabels validates the map at attr against GCP's label rules so a bad
+// key fails at plan time rather than in the API call. On other clouds the
+// labels are ignored and a warning says so.
+func checkGCPLabels(diags *diag.Diagnostics, cloud types.String, attr string, labels types.Map) {
+	if labels.IsNull() || labels.IsUnknown() || cloud.IsUnknown() {
+		return
+	}
+	if cloud.ValueString() != "gcp" {
+		diags.AddAttributeWarning(path.Root(attr), "labels ignored", attr+" only applies to gcp")
+		return
+	}
+	if err := shared.ValidateGCPLabels(stringMap(labels)); err != nil {
+		diags.AddAttributeError(path.Root(attr), "invalid gcp labels", err.Error())
+	}
+}
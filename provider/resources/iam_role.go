@@ -0,0 +1,508 @@
+package resources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/msi/armmsi"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	crm "google.golang.org/api/cloudresourcemanager/v1"
+	iamapi "google.golang.org/api/iam/v1"
+)
+
+// IAMRoleResource manages an identity that other resources run as: an IAM
+// role on AWS, a user-assigned managed identity on Azure or a service
+// account on GCP.
+type IAMRoleResource struct {
+	iam *iam.Client
+
+	azureRG          *armresources.ResourceGroupsClient
+	azureIdentities  *armmsi.UserAssignedIdentitiesClient
+	azureAssignments *armauthorization.RoleAssignmentsClient
+	azureRoleDefs    *armauthorization.RoleDefinitionsClient
+	azureSubID       string
+	azureLoc         string
+
+	gcpIAM  *iamapi.Service
+	gcpCRM  *crm.Service
+	gcpProj string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	ids *shared.CloudIDs
+}
+
+type iamRoleResourceModel struct {
+	ID       types.String `tfsdk:"id"`
+	CloudID  types.String `tfsdk:"cloud_id"`
+	Type     types.String `tfsdk:"type"`
+	Name     types.String `tfsdk:"name"`
+	Region   types.String `tfsdk:"region"`
+	Trust    types.String `tfsdk:"trust"`
+	Policies types.List   `tfsdk:"policies"`
+}
+
+// policies returns the known entries of policies.
+func (m *iamRoleResourceModel) policies() []string {
+	var out []string
+	for _, v := range m.Policies.Elements() {
+		if s, ok := v.(types.String); ok && !s.IsNull() && !s.IsUnknown() {
+			out = append(out, s.ValueString())
+		}
+	}
+	return out
+}
+
+func NewIAMRoleResource() resource.Resource { return &IAMRoleResource{} }
+
+func (r *IAMRoleResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.ids = cfg.CloudIDs
+	r.iam = cfg.AWSIAM
+	r.azureRG = cfg.AzureRGClient
+	r.azureIdentities = cfg.AzureIdentityClient
+	r.azureAssignments = cfg.AzureRoleAssignmentClient
+	r.azureRoleDefs = cfg.AzureRoleDefinitionClient
+	r.azureSubID = cfg.AzureSubID
+	r.azureLoc = cfg.AzureLocation
+	r.gcpIAM = cfg.GCPIAM
+	r.gcpCRM = cfg.GCPProjects
+	r.gcpProj = cfg.GCPProject
+}
+
+func (r *IAMRoleResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_iam_role"
+}
+
+func (r *IAMRoleResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			// Role ARN, managed identity resource ID or service account email.
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"cloud_id": cloudIDAttribute(),
+			"type": schema.StringAttribute{
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"name": schema.StringAttribute{
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			// Azure location; IAM roles and service accounts are global.
+			"region": schema.StringAttribute{
+				Optional:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			// AWS service principal allowed to assume the role, such as lambda.amazonaws.com.
+			"trust": schema.StringAttribute{Optional: true},
+			// Managed policy ARNs, Azure role names or definition IDs, or GCP roles.
+			"policies": schema.ListAttribute{ElementType: types.StringType, Optional: true},
+		},
+	}
+}
+
+var (
+	// gcpAccountID matches the service account IDs GCP accepts.
+	gcpAccountID = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+	azureGUID    = regexp.MustCompile(`^[0-9a-fA-F]{8}(-[0-9a-fA-F]{4}){3}-[0-9a-fA-F]{12}$`)
+)
+
+func (r *IAMRoleResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg iamRoleResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	t := cfg.Type.ValueString()
+	switch t {
+	case "aws":
+		if cfg.Trust.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("trust"), "missing trust", "aws roles need the service principal that assumes them, such as lambda.amazonaws.com")
+		}
+		for _, p := range cfg.policies() {
+			if !strings.HasPrefix(p, "arn:") {
+				resp.Diagnostics.AddAttributeError(path.Root("policies"), "invalid policy", fmt.Sprintf("%q is not a managed policy ARN", p))
+			}
+		}
+	case "gcp":
+		if n := cfg.Name.ValueString(); !cfg.Name.IsUnknown() && !gcpAccountID.MatchString(n) {
+			resp.Diagnostics.AddAttributeError(path.Root("name"), "invalid name",
+				fmt.Sprintf("%q is not a service account ID: 6-30 lowercase letters, digits and hyphens, starting with a letter", n))
+		}
+		for _, p := range cfg.policies() {
+			if !strings.HasPrefix(p, "roles/") && !strings.HasPrefix(p, "projects/") && !strings.HasPrefix(p, "organizations/") {
+				resp.Diagnostics.AddAttributeError(path.Root("policies"), "invalid policy", fmt.Sprintf("%q is not a GCP role", p))
+			}
+		}
+	}
+	if t != "aws" && !cfg.Trust.IsNull() {
+		resp.Diagnostics.AddAttributeWarning(path.Root("trust"), "trust ignored", "trust only applies to aws")
+	}
+	if t != "azure" && !cfg.Region.IsNull() {
+		resp.Diagnostics.AddAttributeWarning(path.Root("region"), "region ignored", "region only applies to azure")
+	}
+}
+
+// awsTrustPolicy returns an assume role policy that lets service assume the role.
+func awsTrustPolicy(service string) string {
+	doc, _ := json.Marshal(map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": service},
+			"Action":    "sts:AssumeRole",
+		}},
+	})
+	return string(doc)
+}
+
+// diffStrings returns the entries in want but not have, and those in have but not want.
+func diffStrings(have, want []string) (add, remove []string) {
+	for _, p := range want {
+		if !slices.Contains(have, p) {
+			add = append(add, p)
+		}
+	}
+	for _, p := range have {
+		if !slices.Contains(want, p) {
+			remove = append(remove, p)
+		}
+	}
+	return add, remove
+}
+
+// azureAssignmentName derives the GUID of the role assignment granting role
+// to principal, so the same assignment can be found again to delete it.
+func azureAssignmentName(principal, role string) string {
+	sum := sha256.Sum256([]byte(principal + "|" + strings.ToLower(role)))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// azureRoleDefinition resolves a role given by definition ID, GUID or
+// built-in name such as "Storage Blob Data Reader" to its definition ID.
+func (r *IAMRoleResource) azureRoleDefinition(ctx context.Context, scope, role string) (string, error) {
+	if strings.HasPrefix(role, "/") {
+		return role, nil
+	}
+	if azureGUID.MatchString(role) {
+		return fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s", r.azureSubID, role), nil
+	}
+	pager := r.azureRoleDefs.NewListPager(scope, &armauthorization.RoleDefinitionsClientListOptions{
+		Filter: to.Ptr(fmt.Sprintf("roleName eq '%s'", role)),
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return "", err
+		}
+		if len(page.Value) > 0 {
+			return *page.Value[0].ID, nil
+		}
+	}
+	return "", fmt.Errorf("no Azure role named %q", role)
+}
+
+// azureScope is the resource group that managed identities are granted roles in.
+func (r *IAMRoleResource) azureScope() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/abstract-rg", r.azureSubID)
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *IAMRoleResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.iam != nil
+	case "azure":
+		return r.azureRG != nil && r.azureIdentities != nil && r.azureAssignments != nil && r.azureRoleDefs != nil
+	case "gcp":
+		return r.gcpIAM != nil && r.gcpCRM != nil
+	}
+	return false
+}
+
+// syncPolicies attaches the policies in add and detaches those in remove.
+func (r *IAMRoleResource) syncPolicies(ctx context.Context, m *iamRoleResourceModel, add, remove []string) error {
+	name := m.Name.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		for _, p := range remove {
+			if _, err := r.iam.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{RoleName: aws.String(name), PolicyArn: aws.String(p)}); err != nil {
+				return err
+			}
+		}
+		for _, p := range add {
+			if _, err := r.iam.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{RoleName: aws.String(name), PolicyArn: aws.String(p)}); err != nil {
+				return err
+			}
+		}
+	case "azure":
+		if len(add) == 0 && len(remove) == 0 {
+			return nil
+		}
+		identity, err := r.azureIdentities.Get(ctx, "abstract-rg", name, nil)
+		if err != nil {
+			return err
+		}
+		principal := *identity.Properties.PrincipalID
+		scope := r.azureScope()
+		for _, p := range remove {
+			if _, err := r.azureAssignments.Delete(ctx, scope, azureAssignmentName(principal, p), nil); err != nil {
+				return err
+			}
+		}
+		for _, p := range add {
+			def, err := r.azureRoleDefinition(ctx, scope, p)
+			if err != nil {
+				return err
+			}
+			_, err = r.azureAssignments.Create(ctx, scope, azureAssignmentName(principal, p), armauthorization.RoleAssignmentCreateParameters{
+				Properties: &armauthorization.RoleAssignmentProperties{
+					PrincipalID:      to.Ptr(principal),
+					RoleDefinitionID: to.Ptr(def),
+					// a new identity may not have replicated yet; the type skips the lookup
+					PrincipalType: to.Ptr(armauthorization.PrincipalTypeServicePrincipal),
+				},
+			}, nil)
+			if err != nil {
+				return err
+			}
+		}
+	case "gcp":
+		if len(add) == 0 && len(remove) == 0 {
+			return nil
+		}
+		policy, err := r.gcpCRM.Projects.GetIamPolicy(r.gcpProj, &crm.GetIamPolicyRequest{
+			Options: &crm.GetPolicyOptions{RequestedPolicyVersion: 3},
+		}).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+		updateBindings(policy, "serviceAccount:"+m.ID.ValueString(), add, remove)
+		_, err = r.gcpCRM.Projects.SetIamPolicy(r.gcpProj, &crm.SetIamPolicyRequest{Policy: policy}).Context(ctx).Do()
+		return err
+	}
+	return nil
+}
+
+// updateBindings grants member the roles in add and revokes those in remove.
+// Conditional bindings are left alone.
+func updateBindings(policy *crm.Policy, member string, add, remove []string) {
+	for _, b := range policy.Bindings {
+		if b.Condition == nil && slices.Contains(remove, b.Role) {
+			b.Members = slices.DeleteFunc(b.Members, func(m string) bool { return m == member })
+		}
+	}
+	for _, role := range add {
+		i := slices.IndexFunc(policy.Bindings, func(b *crm.Binding) bool { return b.Role == role && b.Condition == nil })
+		if i < 0 {
+			policy.Bindings = append(policy.Bindings, &crm.Binding{Role: role, Members: []string{member}})
+		} else if !slices.Contains(policy.Bindings[i].Members, member) {
+			policy.Bindings[i].Members = append(policy.Bindings[i].Members, member)
+		}
+	}
+	policy.Bindings = slices.DeleteFunc(policy.Bindings, func(b *crm.Binding) bool { return len(b.Members) == 0 })
+}
+
+func (r *IAMRoleResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan iamRoleResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	name := plan.Name.ValueString()
+	switch plan.Type.ValueString() {
+	case "aws":
+		out, err := r.iam.CreateRole(ctx, &iam.CreateRoleInput{
+			RoleName:                 aws.String(name),
+			AssumeRolePolicyDocument: aws.String(awsTrustPolicy(plan.Trust.ValueString())),
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("aws create", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(aws.ToString(out.Role.Arn))
+	case "azure":
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		err := shared.EnsureResourceGroup(ctx, r.azureRG, "abstract-rg", loc)
+		if err != nil {
+			resp.Diagnostics.AddError("azure rg", err.Error())
+			return
+		}
+		out, err := r.azureIdentities.CreateOrUpdate(ctx, "abstract-rg", name, armmsi.Identity{Location: &loc}, nil)
+		if err != nil {
+			resp.Diagnostics.AddError("azure create", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(*out.ID)
+	case "gcp":
+		sa, err := r.gcpIAM.Projects.ServiceAccounts.Create("projects/"+r.gcpProj, &iamapi.CreateServiceAccountRequest{
+			AccountId:      name,
+			ServiceAccount: &iamapi.ServiceAccount{DisplayName: name},
+		}).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp create", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(sa.Email)
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	if err := r.syncPolicies(ctx, &plan, plan.policies(), nil); err != nil {
+		resp.Diagnostics.AddError("attach policies", err.Error())
+		// keep the role in state so it is cleaned up on destroy
+		plan.Policies = types.ListNull(types.StringType)
+	}
+	setCloudID(&resp.Diagnostics, &plan.CloudID, r.cloudID(&plan), nil)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// cloudID is the role's ARN, the managed identity's ID or the service
+// account's full name.
+func (r *IAMRoleResource) cloudID(m *iamRoleResourceModel) string {
+	if m.Type.ValueString() == "gcp" {
+		return r.ids.GCPName("serviceAccounts/" + m.ID.ValueString())
+	}
+	return m.ID.ValueString()
+}
+
+func (r *IAMRoleResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state iamRoleResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err := r.iam.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(state.Name.ValueString())})
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+	case "azure":
+		_, err := r.azureIdentities.Get(ctx, "abstract-rg", state.Name.ValueString(), nil)
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+	case "gcp":
+		_, err := r.gcpIAM.Projects.ServiceAccounts.Get(fmt.Sprintf("projects/%s/serviceAccounts/%s", r.gcpProj, state.ID.ValueString())).Context(ctx).Do()
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(&state), nil })
+}
+
+// Update changes the trusted service and the attached policies in place.
+func (r *IAMRoleResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state iamRoleResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	if plan.Type.ValueString() == "aws" && !plan.Trust.Equal(state.Trust) {
+		_, err := r.iam.UpdateAssumeRolePolicy(ctx, &iam.UpdateAssumeRolePolicyInput{
+			RoleName:       aws.String(plan.Name.ValueString()),
+			PolicyDocument: aws.String(awsTrustPolicy(plan.Trust.ValueString())),
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("aws update trust", err.Error())
+			return
+		}
+	}
+	add, remove := diffStrings(state.policies(), plan.policies())
+	if err := r.syncPolicies(ctx, &plan, add, remove); err != nil {
+		resp.Diagnostics.AddError("update policies", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *IAMRoleResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state iamRoleResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	// a role cannot be deleted with policies attached, and Azure and GCP
+	// would otherwise keep grants for the deleted identity
+	if err := r.syncPolicies(ctx, &state, nil, state.policies()); err != nil {
+		resp.Diagnostics.AddError("detach policies", err.Error())
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err := r.iam.DeleteRole(ctx, &iam.DeleteRoleInput{RoleName: aws.String(state.Name.ValueString())})
+		if err != nil {
+			resp.Diagnostics.AddError("aws delete", err.Error())
+		}
+	case "azure":
+		_, err := r.azureIdentities.Delete(ctx, "abstract-rg", state.Name.ValueString(), nil)
+		if err != nil {
+			resp.Diagnostics.AddError("azure delete", err.Error())
+		}
+	case "gcp":
+		_, err := r.gcpIAM.Projects.ServiceAccounts.Delete(fmt.Sprintf("projects/%s/serviceAccounts/%s", r.gcpProj, state.ID.ValueString())).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete", err.Error())
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	crm "google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestIAMRoleConfig(t *testing.T) {
+	r := &IAMRoleResource{}
+	s := testSchema(t, r)
+	role := func(cloud, name string, extra map[string]tftypes.Value) map[string]tftypes.Value {
+		vals := map[string]tftypes.Value{"name": str(name), "type": str(cloud)}
+		maps.Copy(vals, extra)
+		return vals
+	}
+	cases := []struct {
+		name  string
+		vals  map[string]tftypes.Value
+		errs  bool
+		warns bool
+	}{
+		{"aws", role("aws", "fn-role", map[string]tftypes.Value{"trust": str("lambda.amazonaws.com"), "policies": strList("arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole")}), false, false},
+		{"aws without trust", role("aws", "fn-role", nil), true, false},
+		{"aws policy name", role("aws", "fn-role", map[string]tftypes.Value{"trust": str("lambda.amazonaws.com"), "policies": strList("AWSLambdaBasicExecutionRole")}), true, false},
+		{"aws region", role("aws", "fn-role", map[string]tftypes.Value{"trust": str("lambda.amazonaws.com"), "region": str("us-west-2")}), false, true},
+		{"azure", role("azure", "fn-role", map[string]tftypes.Value{"region": str("westeurope"), "policies": strList("Storage Blob Data Reader")}), false, false},
+		{"azure trust", role("azure", "fn-role", map[string]tftypes.Value{"trust": str("lambda.amazonaws.com")}), false, true},
+		{"gcp", role("gcp", "fn-runner", map[string]tftypes.Value{"policies": strList("roles/storage.objectViewer", "projects/p/roles/custom")}), false, false},
+		{"gcp short name", role("gcp", "fn", nil), true, false},
+		{"gcp uppercase name", role("gcp", "Fn-Runner", nil), true, false},
+		{"gcp policy arn", role("gcp", "fn-runner", map[string]tftypes.Value{"policies": strList("arn:aws:iam::aws:policy/ReadOnlyAccess")}), true, false},
+		{"unknown policies", role("aws", "fn-role", map[string]tftypes.Value{"trust": str("lambda.amazonaws.com"), "policies": tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, tftypes.UnknownValue)}), false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs || (resp.Diagnostics.WarningsCount() > 0) != tc.warns {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestAWSTrustPolicy(t *testing.T) {
+	var doc struct {
+		Statement []struct {
+			Effect    string
+			Principal struct{ Service string }
+			Action    string
+		}
+	}
+	if err := json.Unmarshal([]byte(awsTrustPolicy("lambda.amazonaws.com")), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Statement) != 1 || doc.Statement[0].Principal.Service != "lambda.amazonaws.com" || doc.Statement[0].Action != "sts:AssumeRole" {
+		t.Errorf("trust policy = %+v", doc)
+	}
+}
+
+func TestDiffStrings(t *testing.T) {
+	add, remove := diffStrings([]string{"a", "b"}, []string{"b", "c"})
+	if !slices.Equal(add, []string{"c"}) || !slices.Equal(remove, []string{"a"}) {
+		t.Errorf("add = %v, remove = %v", add, remove)
+	}
+}
+
+func TestAzureAssignmentName(t *testing.T) {
+	a := azureAssignmentName("principal", "Reader")
+	if !regexp.MustCompile(`^[0-9a-f]{8}(-[0-9a-f]{4}){3}-[0-9a-f]{12}$`).MatchString(a) {
+		t.Fatalf("%q is not a GUID", a)
+	}
+	if b := azureAssignmentName("principal", "reader"); a != b {
+		t.Errorf("role case changed the name: %q then %q", a, b)
+	}
+	if c := azureAssignmentName("other", "Reader"); a == c {
+		t.Errorf("two principals share assignment %q", a)
+	}
+}
+
+func TestUpdateBindings(t *testing.T) {
+	const sa = "serviceAccount:fn-runner@p.iam.gserviceaccount.com"
+	policy := &crm.Policy{Bindings: []*crm.Binding{
+		{Role: "roles/viewer", Members: []string{"user:a@example.com"}},
+		{Role: "roles/storage.objectViewer", Members: []string{sa}},
+		{Role: "roles/pubsub.publisher", Members: []string{sa}, Condition: &crm.Expr{Expression: "true"}},
+	}}
+	updateBindings(policy, sa, []string{"roles/viewer", "roles/pubsub.publisher"}, []string{"roles/storage.objectViewer"})
+	got := map[string][]string{}
+	for _, b := range policy.Bindings {
+		key := b.Role
+		if b.Condition != nil {
+			key += " (conditional)"
+		}
+		got[key] = b.Members
+	}
+	want := map[string][]string{
+		"roles/viewer":                         {"user:a@example.com", sa},
+		"roles/pubsub.publisher (conditional)": {sa},
+		"roles/pubsub.publisher":               {sa},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("bindings = %v, want %v", got, want)
+	}
+	for role, members := range want {
+		if !slices.Equal(got[role], members) {
+			t.Errorf("%s = %v, want %v", role, got[role], members)
+		}
+	}
+}
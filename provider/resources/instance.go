@@ -2,11 +2,17 @@ package resources
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"abstract-provider/provider/shared"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
@@ -14,16 +20,23 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	compute "google.golang.org/api/compute/v1"
 )
 
 type InstanceResource struct {
-	ec2 *ec2.Client
+	ec2 shared.EC2Runner
 
-	azureVM   *armcompute.VirtualMachinesClient
+	azureVM   shared.AzureVMs
 	azureNIC  *armnetwork.InterfacesClient
 	azurePIP  *armnetwork.PublicIPAddressesClient
 	azureRG   *armresources.ResourceGroupsClient
@@ -35,6 +48,47 @@ type InstanceResource struct {
 	gcp       *compute.Service
 	gcpProj   string
 	gcpRegion string
+
+	// sizeAliases are the provider's size_aliases by cloud.
+	sizeAliases map[string]map[string]string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	// defaultTags are the provider's default_tags, merged under GCP labels.
+	defaultTags map[string]string
+
+	ids *shared.CloudIDs
+}
+
+type instanceResourceModel struct {
+	ID              types.String `tfsdk:"id"`
+	CloudID         types.String `tfsdk:"cloud_id"`
+	Name            types.String `tfsdk:"name"`
+	Type            types.String `tfsdk:"type"`
+	Region          types.String `tfsdk:"region"`
+	Image           types.String `tfsdk:"image"`
+	Size            types.String `tfsdk:"size"`
+	PublicIP        types.Bool   `tfsdk:"public_ip"`
+	SubnetID        types.String `tfsdk:"subnet_id"`
+	EBSOptimized    types.Bool   `tfsdk:"ebs_optimized"`
+	EnclaveOptions  types.Bool   `tfsdk:"enclave_options"`
+	VolumeIDs       types.List   `tfsdk:"volume_ids"`
+	Labels          types.Map    `tfsdk:"labels"`
+	Tags            types.Map    `tfsdk:"tags"`
+	Zone            types.String `tfsdk:"availability_zone"`
+	StaticIPID      types.String `tfsdk:"static_ip_id"`
+	InstanceProfile types.String `tfsdk:"instance_profile"`
+	WaitForRunning  types.Bool   `tfsdk:"wait_for_running"`
+}
+
+// waitForRunning reports whether Create waits for the instance to run. Unset
+// means the default, true.
+func (m *instanceResourceModel) waitForRunning() bool {
+	return m.WaitForRunning.IsNull() || m.WaitForRunning.IsUnknown() || m.WaitForRunning.ValueBool()
 }
 
 func NewInstanceResource() resource.Resource { return &InstanceResource{} }
@@ -48,8 +102,16 @@ func (r *InstanceResource) Configure(ctx context.Context, req resource.Configure
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
-	r.ec2 = cfg.AWSEC2
-	r.azureVM = cfg.AzureVMClient
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.defaultTags = cfg.DefaultTags
+	r.ids = cfg.CloudIDs
+	if cfg.AWSEC2 != nil {
+		r.ec2 = cfg.AWSEC2
+	}
+	if cfg.AzureVMClient != nil {
+		r.azureVM = cfg.AzureVMClient
+	}
 	r.azureNIC = cfg.AzureNICClient
 	r.azurePIP = cfg.AzurePIPClient
 	r.azureRG = cfg.AzureRGClient
@@ -60,6 +122,7 @@ func (r *InstanceResource) Configure(ctx context.Context, req resource.Configure
 	r.gcp = cfg.GCPCompute
 	r.gcpProj = cfg.GCPProject
 	r.gcpRegion = cfg.GCPRegion
+	r.sizeAliases = cfg.SizeAliases
 }
 
 func (r *InstanceResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -69,55 +132,410 @@ func (r *InstanceResource) Metadata(ctx context.Context, req resource.MetadataRe
 func (r *InstanceResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
-			"id":        schema.StringAttribute{Computed: true},
+			"id":        schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"cloud_id":  cloudIDAttribute(),
 			"name":      schema.StringAttribute{Optional: true},
 			"type":      schema.StringAttribute{Required: true},
-			"region":    schema.StringAttribute{Optional: true},
+			"region":    schema.StringAttribute{Optional: true, Description: instanceRegionDescription, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
 			"image":     schema.StringAttribute{Optional: true},
-			"size":      schema.StringAttribute{Optional: true},
+			"size":      schema.StringAttribute{Optional: true, Description: instanceSizeDescription},
 			"public_ip": schema.BoolAttribute{Optional: true},
+			// Subnet ID on AWS and Azure or subnetwork name on GCP, e.g. an abstract_network's subnet_id.
+			"subnet_id": schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			// AWS only: EbsOptimized and Nitro Enclaves, validated against the instance type.
+			"ebs_optimized":   schema.BoolAttribute{Optional: true},
+			"enclave_options": schema.BoolAttribute{Optional: true, PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()}},
+			// EBS volume IDs, managed disk IDs or disk self links, refreshed on read.
+			"volume_ids": schema.ListAttribute{ElementType: types.StringType, Computed: true, PlanModifiers: []planmodifier.List{listplanmodifier.UseStateForUnknown()}},
+			// GCP only: instance labels, updated in place.
+			"labels": schema.MapAttribute{ElementType: types.StringType, Optional: true},
+			// AWS only: EC2 tags besides Name, read back and updated in place.
+			"tags": schema.MapAttribute{ElementType: types.StringType, Optional: true},
+			"availability_zone": schema.StringAttribute{Optional: true, Computed: true, Description: instanceZoneDescription,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown(), stringplanmodifier.RequiresReplace()}},
+			// An abstract_static_ip's id to use as the public address, changed in place.
+			"static_ip_id": schema.StringAttribute{Optional: true},
+			// AWS only: the IAM instance profile name or ARN, changed in place.
+			"instance_profile": schema.StringAttribute{Optional: true, Description: instanceProfileDescription},
+			// Whether create waits until the instance is running; Azure VMs are always waited for.
+			"wait_for_running": schema.BoolAttribute{Optional: true, Computed: true, Default: booldefault.StaticBool(true)},
 		},
 	}
 }
 
-func (r *InstanceResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name     types.String `tfsdk:"name"`
-		Type     types.String `tfsdk:"type"`
-		Region   types.String `tfsdk:"region"`
-		Image    types.String `tfsdk:"image"`
-		Size     types.String `tfsdk:"size"`
-		PublicIP types.Bool   `tfsdk:"public_ip"`
+func (r *InstanceResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cloud types.String
+	var labels types.Map
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("type"), &cloud)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("labels"), &labels)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	checkGCPLabels(&resp.Diagnostics, cloud, "labels", labels)
+	var tags types.Map
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("tags"), &tags)...)
+	if !tags.IsNull() && !cloud.IsUnknown() && cloud.ValueString() != "aws" {
+		resp.Diagnostics.AddAttributeWarning(path.Root("tags"), "tags ignored", "tags only applies to aws")
+	}
+	var profile types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("instance_profile"), &profile)...)
+	if !profile.IsNull() && !cloud.IsUnknown() && cloud.ValueString() != "aws" {
+		resp.Diagnostics.AddAttributeError(path.Root("instance_profile"), "instance_profile not supported",
+			"instance_profile only applies to aws; attach identities on "+cloud.ValueString()+" with abstract_managed_identity_assignment")
+	}
+	var region, zone types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("region"), &region)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("availability_zone"), &zone)...)
+	if cloud.ValueString() == "gcp" && !region.IsNull() && !region.IsUnknown() {
+		if err := checkGCPLocation(region.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("region"), "invalid region", err.Error())
+		}
+	}
+	if !zone.IsNull() && !zone.IsUnknown() && !region.IsUnknown() && !cloud.IsUnknown() {
+		if err := checkZone(cloud.ValueString(), region.ValueString(), zone.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("availability_zone"), "invalid availability zone", err.Error())
+		}
+	}
+	var publicIP types.Bool
+	var staticIP types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("public_ip"), &publicIP)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("static_ip_id"), &staticIP)...)
+	if !staticIP.IsNull() && !publicIP.IsNull() && !publicIP.IsUnknown() && !publicIP.ValueBool() {
+		resp.Diagnostics.AddAttributeError(path.Root("static_ip_id"), "conflicting public_ip", "static_ip_id gives the instance a public address; remove public_ip = false")
+	}
+	if cloud.ValueString() == "azure" {
+		var image types.String
+		resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("image"), &image)...)
+		if image.IsUnknown() {
+			return
+		}
+		if _, err := azureImageReference(image.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("image"), "invalid image", err.Error())
+		}
+	}
+}
+
+// azureImageReference parses an Azure image: a marketplace URN
+// ("publisher:offer:sku:version") or the resource ID of a managed or gallery
+// image. An empty image is Ubuntu 22.04.
+func azureImageReference(image string) (*armcompute.ImageReference, error) {
+	if image == "" {
+		return &armcompute.ImageReference{
+			Publisher: to.Ptr("Canonical"),
+			Offer:     to.Ptr("0001-com-ubuntu-server-jammy"),
+			SKU:       to.Ptr("22_04-lts"),
+			Version:   to.Ptr("latest"),
+		}, nil
+	}
+	if strings.HasPrefix(image, "/") {
+		return &armcompute.ImageReference{ID: to.Ptr(image)}, nil
+	}
+	parts := strings.Split(image, ":")
+	if len(parts) != 4 || slices.Contains(parts, "") {
+		return nil, fmt.Errorf("%q is neither a publisher:offer:sku:version URN nor an image resource ID", image)
+	}
+	return &armcompute.ImageReference{
+		Publisher: to.Ptr(parts[0]),
+		Offer:     to.Ptr(parts[1]),
+		SKU:       to.Ptr(parts[2]),
+		Version:   to.Ptr(parts[3]),
+	}, nil
+}
+
+const instanceRegionDescription = "The AWS region or Azure location, or on GCP a region such as us-central1 or a zone such as " +
+	"us-central1-a. A GCP region gets its first zone unless availability_zone names one. Defaults to the provider's region."
+
+const instanceZoneDescription = "The zone to place the instance in: an AWS zone such as us-east-1b, an Azure zone number " +
+	"(1, 2 or 3), or a GCP zone such as us-central1-b or its suffix b, which wins over a zone in region. When unset, " +
+	"it records the zone AWS and GCP placed the instance in."
+
+const instanceProfileDescription = "AWS only: the name or ARN of an IAM instance profile, whose role code on the " +
+	"instance gets credentials for. When unset, a profile attached some other way, such as by an " +
+	"abstract_managed_identity_assignment, is left alone."
+
+const instanceSizeDescription = "small (the default), medium or large, mapped to a machine type on each cloud and " +
+	"overridable with the provider's size_aliases. Any other value is used as the machine type."
+
+// instanceSizes maps the generic sizes to each cloud's machine type. The
+// provider's size_aliases add to and override these.
+var instanceSizes = map[string]map[string]string{
+	"aws": {
+		"small":  string(ec2types.InstanceTypeT3Small),
+		"medium": string(ec2types.InstanceTypeT3Medium),
+		"large":  string(ec2types.InstanceTypeT3Large),
+	},
+	"azure": {
+		"small":  string(armcompute.VirtualMachineSizeTypesStandardB1S),
+		"medium": string(armcompute.VirtualMachineSizeTypesStandardB2S),
+		"large":  string(armcompute.VirtualMachineSizeTypesStandardB4Ms),
+	},
+	"gcp": {
+		"small":  "e2-small",
+		"medium": "e2-medium",
+		"large":  "e2-standard-4",
+	},
+}
+
+// machineType resolves size to a machine type on cloud: a size alias from
+// the provider, then a built-in one, otherwise size itself. An empty size is
+// "small".
+func (r *InstanceResource) machineType(cloud, size string) string {
+	alias := strings.ToLower(size)
+	if alias == "" {
+		alias = "small"
+	}
+	if t, ok := r.sizeAliases[cloud][alias]; ok {
+		return t
+	}
+	if t, ok := instanceSizes[cloud][alias]; ok {
+		return t
+	}
+	return size
+}
+
+// gcpZoneRegion returns the region of a zone such as "us-central1-a"; a region is returned unchanged.
+func gcpZoneRegion(zone string) string {
+	if strings.Count(zone, "-") == 2 {
+		return zone[:strings.LastIndex(zone, "-")]
+	}
+	return zone
+}
+
+// gcpZone returns the zone for an instance in region, defaulting to the provider's region.
+// An availability zone given in full wins; a suffix such as "b" picks that zone of the region.
+// Otherwise a region comes back as is, for Create to pick one of its zones.
+func (r *InstanceResource) gcpZone(region, zone string) string {
+	if strings.Contains(zone, "-") {
+		return zone
+	}
+	if region == "" {
+		region = r.gcpRegion
+	}
+	if region == "" {
+		region = "us-central1-a"
+	}
+	if zone != "" {
+		return gcpZoneRegion(region) + "-" + zone
+	}
+	return region
+}
+
+// gcpWaitRunning returns an instance once it is running, or right away when
+// wait is false.
+func (r *InstanceResource) gcpWaitRunning(ctx context.Context, zone, name string, wait bool) (*compute.Instance, error) {
+	for {
+		inst, err := r.gcp.Instances.Get(r.gcpProj, zone, name).Context(ctx).Do()
+		if err != nil || !wait || inst.Status == "RUNNING" {
+			return inst, err
+		}
+		if inst.Status == "TERMINATED" || inst.Status == "SUSPENDED" {
+			return nil, fmt.Errorf("instance %s is %s instead of running", name, strings.ToLower(inst.Status))
+		}
+		if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// gcpStaticAddress returns the IP of a reserved address, given by name or
+// path, in the region of zone.
+func (r *InstanceResource) gcpStaticAddress(ctx context.Context, zone, static string) (string, error) {
+	name := static[strings.LastIndex(static, "/")+1:]
+	addr, err := r.gcp.Addresses.Get(r.gcpProj, gcpZoneRegion(zone), name).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	return addr.Address, nil
+}
+
+// setStaticIP moves the instance from the prior static IP to want, either of
+// which may be empty. Without a static IP the instance is left with no public
+// address.
+func (r *InstanceResource) setStaticIP(ctx context.Context, m *instanceResourceModel, prior, want string) error {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		if want != "" {
+			return awsAssociateAddress(ctx, r.ec2, want, id)
+		}
+		return awsDisassociateAddress(ctx, r.ec2, prior, id)
+	case "azure":
+		if want != "" {
+			return azureSetVMPublicIP(ctx, r.azureVM, r.azureNIC, id, want, true)
+		}
+		return azureSetVMPublicIP(ctx, r.azureVM, r.azureNIC, id, prior, false)
+	case "gcp":
+		zone := r.gcpZone(m.Region.ValueString(), m.Zone.ValueString())
+		static, attached := prior, false
+		if want != "" {
+			static, attached = want, true
+		}
+		address, err := r.gcpStaticAddress(ctx, zone, static)
+		if err != nil {
+			return err
+		}
+		return gcpSetNAT(ctx, r.gcp, r.gcpProj, zone, id, address, attached)
+	}
+	return nil
+}
+
+// checkInstanceCapabilities verifies instanceType supports the requested EBS and enclave settings.
+func (r *InstanceResource) checkInstanceCapabilities(ctx context.Context, instanceType string, ebsOptimized, enclave types.Bool) error {
+	if ebsOptimized.IsNull() && !enclave.ValueBool() {
+		return nil
+	}
+	out, err := r.ec2.DescribeInstanceTypes(ctx, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []ec2types.InstanceType{ec2types.InstanceType(instanceType)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(out.InstanceTypes) == 0 {
+		return fmt.Errorf("unknown instance type %s", instanceType)
 	}
+	info := out.InstanceTypes[0]
+	support := ec2types.EbsOptimizedSupportUnsupported
+	if info.EbsInfo != nil {
+		support = info.EbsInfo.EbsOptimizedSupport
+	}
+	if ebsOptimized.ValueBool() && support == ec2types.EbsOptimizedSupportUnsupported {
+		return fmt.Errorf("%s does not support EBS optimization", instanceType)
+	}
+	if !ebsOptimized.IsNull() && !ebsOptimized.ValueBool() && support == ec2types.EbsOptimizedSupportDefault {
+		return fmt.Errorf("%s is always EBS-optimized", instanceType)
+	}
+	if enclave.ValueBool() && info.NitroEnclavesSupport != ec2types.NitroEnclavesSupportSupported {
+		return fmt.Errorf("%s does not support Nitro Enclaves", instanceType)
+	}
+	return nil
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *InstanceResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.ec2 = c.EC2
+	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *InstanceResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.ec2 != nil
+	case "azure":
+		return r.azureRG != nil && r.azureVM != nil && r.azureNIC != nil && r.azurePIP != nil && r.azureSub != nil
+	case "gcp":
+		return r.gcp != nil
+	}
+	return false
+}
+
+const (
+	azureVNetName   = "abstract-vnet"
+	azureSubnetName = "default"
+)
+
+// ensureAzureSubnet returns the ID of the default subnet VMs are placed in,
+// creating its VNet and itself as far as they are missing. An existing VNet
+// is left alone, as putting it again would drop subnets it has that are not
+// in the request. A create that conflicts with another one making the same
+// network is not an error.
+func (r *InstanceResource) ensureAzureSubnet(ctx context.Context, rg, loc string) (string, error) {
+	sub, err := r.azureSub.Get(ctx, rg, azureVNetName, azureSubnetName, nil)
+	if err == nil && sub.ID != nil {
+		return *sub.ID, nil
+	}
+	if err != nil && azureStatus(err) != http.StatusNotFound {
+		return "", err
+	}
+	_, err = r.azureVNet.Get(ctx, rg, azureVNetName, nil)
+	if azureStatus(err) == http.StatusNotFound {
+		var poller *runtime.Poller[armnetwork.VirtualNetworksClientCreateOrUpdateResponse]
+		poller, err = r.azureVNet.BeginCreateOrUpdate(ctx, rg, azureVNetName, armnetwork.VirtualNetwork{
+			Location: &loc,
+			Properties: &armnetwork.VirtualNetworkPropertiesFormat{
+				AddressSpace: &armnetwork.AddressSpace{AddressPrefixes: []*string{to.Ptr("10.0.0.0/16")}},
+			},
+		}, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if azureStatus(err) == http.StatusConflict {
+			err = nil
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	poller, err := r.azureSub.BeginCreateOrUpdate(ctx, rg, azureVNetName, azureSubnetName, armnetwork.Subnet{
+		Properties: &armnetwork.SubnetPropertiesFormat{AddressPrefix: to.Ptr("10.0.0.0/24")},
+	}, nil)
+	if err == nil {
+		var created armnetwork.SubnetsClientCreateOrUpdateResponse
+		if created, err = shared.PollAzure(ctx, poller); err == nil && created.ID != nil {
+			return *created.ID, nil
+		}
+	}
+	if err != nil && azureStatus(err) != http.StatusConflict {
+		return "", err
+	}
+	// someone else created it meanwhile, or the result lacked the ID
+	sub, err = r.azureSub.Get(ctx, rg, azureVNetName, azureSubnetName, nil)
+	if err != nil {
+		return "", err
+	}
+	if sub.ID == nil {
+		return "", fmt.Errorf("azure subnet %s/%s has no ID", azureVNetName, azureSubnetName)
+	}
+	return *sub.ID, nil
+}
+
+// azureStatus returns the HTTP status of a failed Azure call, or 0 when err
+// is nil or did not come from an Azure response.
+func azureStatus(err error) int {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode
+	}
+	return 0
+}
+
+func (r *InstanceResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan instanceResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.CloudID = types.StringNull()
+	plan.WaitForRunning = types.BoolValue(plan.waitForRunning())
+	if plan.Zone.IsUnknown() {
+		// AWS and GCP record where the instance was placed below
+		plan.Zone = types.StringNull()
+	}
+	if plan.Type.ValueString() != "aws" && (!plan.EBSOptimized.IsNull() || !plan.EnclaveOptions.IsNull()) {
+		resp.Diagnostics.AddWarning("instance options ignored", "ebs_optimized and enclave_options only apply to aws")
+	}
 	switch plan.Type.ValueString() {
 	case "aws":
-		if r.ec2 == nil {
-			resp.Diagnostics.AddError("missing AWS client", "")
-			return
-		}
 		if plan.Image.ValueString() == "" {
 			resp.Diagnostics.AddError("missing image", "ami id must be provided")
 			return
 		}
-		size := plan.Size.ValueString()
-		if size == "" {
-			size = "small"
-		}
-		instanceType := size
-		switch strings.ToLower(size) {
-		case "small":
-			instanceType = string(ec2types.InstanceTypeT3Small)
-		case "medium":
-			instanceType = string(ec2types.InstanceTypeT3Medium)
-		case "large":
-			instanceType = string(ec2types.InstanceTypeT3Large)
-		default:
-			instanceType = size
+		instanceType := r.machineType("aws", plan.Size.ValueString())
+		if err := r.checkInstanceCapabilities(ctx, instanceType, plan.EBSOptimized, plan.EnclaveOptions); err != nil {
+			resp.Diagnostics.AddError("aws instance type", err.Error())
+			return
 		}
 		input := &ec2.RunInstancesInput{
 			ImageId:      aws.String(plan.Image.ValueString()),
@@ -125,13 +543,38 @@ func (r *InstanceResource) Create(ctx context.Context, req resource.CreateReques
 			MinCount:     aws.Int32(1),
 			MaxCount:     aws.Int32(1),
 		}
-		if plan.PublicIP.ValueBool() {
-			input.NetworkInterfaces = []ec2types.InstanceNetworkInterfaceSpecification{{
-				DeviceIndex:              aws.Int32(0),
-				AssociatePublicIpAddress: aws.Bool(true),
-			}}
+		if zone := plan.Zone.ValueString(); zone != "" {
+			if _, err := awsZone(ctx, r.ec2, zone); err != nil {
+				resp.Diagnostics.AddError("aws availability zone", err.Error())
+				return
+			}
+			input.Placement = &ec2types.Placement{AvailabilityZone: aws.String(zone)}
 		}
-		out, err := r.ec2.RunInstances(ctx, input)
+		if !plan.EBSOptimized.IsNull() {
+			input.EbsOptimized = aws.Bool(plan.EBSOptimized.ValueBool())
+		}
+		if plan.EnclaveOptions.ValueBool() {
+			input.EnclaveOptions = &ec2types.EnclaveOptionsRequest{Enabled: aws.Bool(true)}
+		}
+		if profile := plan.InstanceProfile.ValueString(); profile != "" {
+			input.IamInstanceProfile = awsInstanceProfileSpec(profile)
+		}
+		if !plan.SubnetID.IsNull() || !plan.PublicIP.IsNull() || !plan.StaticIPID.IsNull() {
+			// without any the subnet's default public IP setting applies
+			nic := ec2types.InstanceNetworkInterfaceSpecification{DeviceIndex: aws.Int32(0)}
+			if subnet := plan.SubnetID.ValueString(); subnet != "" {
+				nic.SubnetId = aws.String(subnet)
+			}
+			if !plan.PublicIP.IsNull() {
+				nic.AssociatePublicIpAddress = aws.Bool(plan.PublicIP.ValueBool())
+			}
+			if !plan.StaticIPID.IsNull() {
+				// the Elastic IP is associated once the instance is running
+				nic.AssociatePublicIpAddress = aws.Bool(false)
+			}
+			input.NetworkInterfaces = []ec2types.InstanceNetworkInterfaceSpecification{nic}
+		}
+		out, err := r.runInstances(ctx, input)
 		if err != nil || len(out.Instances) == 0 {
 			if err == nil {
 				err = fmt.Errorf("no instance returned")
@@ -140,144 +583,131 @@ func (r *InstanceResource) Create(ctx context.Context, req resource.CreateReques
 			return
 		}
 		id := aws.ToString(out.Instances[0].InstanceId)
-		if plan.Name.ValueString() != "" {
-			_, err = r.ec2.CreateTags(ctx, &ec2.CreateTagsInput{
-				Resources: []string{id},
-				Tags:      []ec2types.Tag{{Key: aws.String("Name"), Value: aws.String(plan.Name.ValueString())}},
+		plan.ID = types.StringValue(id)
+		if p := out.Instances[0].Placement; plan.Zone.IsNull() && p != nil {
+			plan.Zone = types.StringPointerValue(p.AvailabilityZone)
+		}
+		plan.VolumeIDs = types.ListNull(types.StringType)
+		if tags := plan.ec2Tags(r.defaultTags); len(tags) > 0 {
+			err = shared.RetryAWS(ctx, func() error {
+				_, err := r.ec2.CreateTags(ctx, &ec2.CreateTagsInput{Resources: []string{id}, Tags: tagList(tags)})
+				return err
 			})
 			if err != nil {
 				resp.Diagnostics.AddError("aws tag instance", err.Error())
+				// keep the instance in state so it is not leaked
+				resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+				return
+			}
+		}
+		if !plan.waitForRunning() && plan.StaticIPID.IsNull() {
+			// Read fills in volume_ids once they are attached
+			break
+		}
+		// volumes are attached by the time the instance is running, and an
+		// Elastic IP can only be associated with a running instance
+		desc, err := ec2.NewInstanceRunningWaiter(r.ec2).WaitForOutput(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{id}}, 10*time.Minute)
+		if err != nil {
+			resp.Diagnostics.AddError("aws wait instance", err.Error())
+			// keep the instance in state so it is not leaked; Read fills in volume_ids
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+			return
+		}
+		plan.setVolumes(awsVolumeIDs(desc))
+		if eip := plan.StaticIPID.ValueString(); eip != "" {
+			if err := awsAssociateAddress(ctx, r.ec2, eip, id); err != nil {
+				resp.Diagnostics.AddError("aws associate static ip", err.Error())
+				// keep the instance in state; the next apply associates the address
+				plan.StaticIPID = types.StringNull()
+				resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 				return
 			}
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":        id,
-			"name":      plan.Name.ValueString(),
-			"type":      plan.Type.ValueString(),
-			"region":    plan.Region.ValueString(),
-			"image":     plan.Image.ValueString(),
-			"size":      instanceType,
-			"public_ip": plan.PublicIP.ValueBool(),
-		})
 	case "azure":
-		if r.azureVM == nil || r.azureNIC == nil || r.azurePIP == nil || r.azureRG == nil || r.azureSub == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
+		// checked before any network resources are created
+		imageRef, err := azureImageReference(plan.Image.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("azure image", err.Error())
 			return
 		}
-		rgName := "abstract-rg"
-		if r.azureLoc == "" && plan.Region.ValueString() != "" {
-			r.azureLoc = plan.Region.ValueString()
+		var zones []*string
+		if zone := plan.Zone.ValueString(); zone != "" {
+			zones = []*string{to.Ptr(zone)}
 		}
-		_, err := r.azureRG.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{Location: &r.azureLoc}, nil)
+		rgName := "abstract-rg"
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		err = shared.EnsureResourceGroup(ctx, r.azureRG, rgName, loc)
 		if err != nil {
 			resp.Diagnostics.AddError("azure rg", err.Error())
 			return
 		}
-		vnetName := "abstract-vnet"
-		subnetName := "default"
-		// ensure subnet exists
-		subnetResp, err := r.azureSub.Get(ctx, rgName, vnetName, subnetName, nil)
-		if err != nil || subnetResp.ID == nil {
-			// create vnet and subnet if not existing
-			vnetPoller, verr := r.azureVNet.BeginCreateOrUpdate(ctx, rgName, vnetName, armnetwork.VirtualNetwork{
-				Location: &r.azureLoc,
-				Properties: &armnetwork.VirtualNetworkPropertiesFormat{
-					AddressSpace: &armnetwork.AddressSpace{AddressPrefixes: []*string{to.Ptr("10.0.0.0/16")}},
+		subnetID := plan.SubnetID.ValueString()
+		if subnetID == "" {
+			subnetID, err = r.ensureAzureSubnet(ctx, rgName, loc)
+			if err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure subnet", err)
+				return
+			}
+		}
+		ipConfig := &armnetwork.InterfaceIPConfigurationPropertiesFormat{Subnet: &armnetwork.Subnet{ID: &subnetID}}
+		// VMs get a public IP of their own unless public_ip is explicitly false
+		// or they use a static IP
+		if pipID := plan.StaticIPID.ValueString(); pipID != "" {
+			ipConfig.PublicIPAddress = &armnetwork.PublicIPAddress{ID: &pipID}
+		} else if plan.PublicIP.IsNull() || plan.PublicIP.ValueBool() {
+			pipName := plan.Name.ValueString() + "-pip"
+			pip := armnetwork.PublicIPAddress{
+				Location: &loc,
+				Properties: &armnetwork.PublicIPAddressPropertiesFormat{
+					PublicIPAllocationMethod: to.Ptr(armnetwork.IPAllocationMethodDynamic),
 				},
-			}, nil)
-			if verr == nil {
-				_, verr = vnetPoller.PollUntilDone(ctx, nil)
 			}
-			if verr != nil {
-				resp.Diagnostics.AddError("azure vnet", verr.Error())
-				return
+			if zones != nil {
+				// a zonal VM needs a zonal Standard public IP, which is always static
+				pip.Zones = zones
+				pip.SKU = &armnetwork.PublicIPAddressSKU{Name: to.Ptr(armnetwork.PublicIPAddressSKUNameStandard)}
+				pip.Properties.PublicIPAllocationMethod = to.Ptr(armnetwork.IPAllocationMethodStatic)
 			}
-			subnetPoller, serr := r.azureSub.BeginCreateOrUpdate(ctx, rgName, vnetName, subnetName, armnetwork.Subnet{
-				Properties: &armnetwork.SubnetPropertiesFormat{AddressPrefix: to.Ptr("10.0.0.0/24")},
-			}, nil)
-			if serr == nil {
-				subResp, serr := subnetPoller.PollUntilDone(ctx, nil)
-				if serr == nil {
-					subnetResp.Subnet = subResp.Subnet
+			pipPoller, err := r.azurePIP.BeginCreateOrUpdate(ctx, rgName, pipName, pip, nil)
+			if err == nil {
+				var pipResp armnetwork.PublicIPAddressesClientCreateOrUpdateResponse
+				pipResp, err = shared.PollAzure(ctx, pipPoller)
+				if err == nil && pipResp.ID != nil {
+					ipConfig.PublicIPAddress = &armnetwork.PublicIPAddress{ID: pipResp.ID}
 				}
-				err = serr
-			} else {
-				err = serr
 			}
 			if err != nil {
-				resp.Diagnostics.AddError("azure subnet", err.Error())
+				shared.AddAzureError(&resp.Diagnostics, "azure pip", err)
 				return
 			}
 		}
-		subnetID := *subnetResp.ID
-		pipName := plan.Name.ValueString() + "-pip"
-		pipPoller, err := r.azurePIP.BeginCreateOrUpdate(ctx, rgName, pipName, armnetwork.PublicIPAddress{
-			Location: &r.azureLoc,
-			Properties: &armnetwork.PublicIPAddressPropertiesFormat{
-				PublicIPAllocationMethod: to.Ptr(armnetwork.IPAllocationMethodDynamic),
-			},
-		}, nil)
-		var pipID string
-		if err == nil {
-			pipResp, perr := pipPoller.PollUntilDone(ctx, nil)
-			err = perr
-			if perr == nil && pipResp.ID != nil {
-				pipID = *pipResp.ID
-			}
-		}
-		if err != nil {
-			resp.Diagnostics.AddError("azure pip", err.Error())
-			return
-		}
 		nicName := plan.Name.ValueString() + "-nic"
 		nicPoller, err := r.azureNIC.BeginCreateOrUpdate(ctx, rgName, nicName, armnetwork.Interface{
-			Location: &r.azureLoc,
+			Location: &loc,
 			Properties: &armnetwork.InterfacePropertiesFormat{
 				IPConfigurations: []*armnetwork.InterfaceIPConfiguration{{
-					Name: to.Ptr("ipconfig1"),
-					Properties: &armnetwork.InterfaceIPConfigurationPropertiesFormat{
-						Subnet:          &armnetwork.Subnet{ID: &subnetID},
-						PublicIPAddress: &armnetwork.PublicIPAddress{ID: &pipID},
-					},
+					Name:       to.Ptr("ipconfig1"),
+					Properties: ipConfig,
 				}},
 			},
 		}, nil)
 		var nicID string
 		if err == nil {
-			nicResp, nerr := nicPoller.PollUntilDone(ctx, nil)
+			nicResp, nerr := shared.PollAzure(ctx, nicPoller)
 			err = nerr
 			if nerr == nil && nicResp.ID != nil {
 				nicID = *nicResp.ID
 			}
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure nic", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure nic", err)
 			return
 		}
 
-		size := plan.Size.ValueString()
-		if size == "" {
-			size = "small"
-		}
-		vmSize := size
-		switch strings.ToLower(size) {
-		case "small":
-			vmSize = string(armcompute.VirtualMachineSizeTypesStandardB1S)
-		case "medium":
-			vmSize = string(armcompute.VirtualMachineSizeTypesStandardB2S)
-		case "large":
-			vmSize = string(armcompute.VirtualMachineSizeTypesStandardB4Ms)
-		default:
-			vmSize = size
-		}
-		imageRef := &armcompute.ImageReference{
-			Publisher: to.Ptr("Canonical"),
-			Offer:     to.Ptr("0001-com-ubuntu-server-jammy"),
-			SKU:       to.Ptr("22_04-lts"),
-			Version:   to.Ptr("latest"),
-		}
+		vmSize := r.machineType("azure", plan.Size.ValueString())
 		vmPoller, err := r.azureVM.BeginCreateOrUpdate(ctx, rgName, plan.Name.ValueString(), armcompute.VirtualMachine{
-			Location: &r.azureLoc,
+			Location: &loc,
+			Zones:    zones,
 			Properties: &armcompute.VirtualMachineProperties{
 				HardwareProfile: &armcompute.HardwareProfile{VMSize: to.Ptr(armcompute.VirtualMachineSizeTypes(vmSize))},
 				StorageProfile: &armcompute.StorageProfile{
@@ -301,53 +731,36 @@ func (r *InstanceResource) Create(ctx context.Context, req resource.CreateReques
 			},
 		}, nil)
 		var vmID string
+		var vm armcompute.VirtualMachine
 		if err == nil {
-			vmResp, verr := vmPoller.PollUntilDone(ctx, nil)
+			vmResp, verr := shared.PollAzure(ctx, vmPoller)
 			err = verr
 			if verr == nil && vmResp.ID != nil {
 				vmID = *vmResp.ID
+				vm = vmResp.VirtualMachine
 			}
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure vm", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure vm", err)
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":        vmID,
-			"name":      plan.Name.ValueString(),
-			"type":      plan.Type.ValueString(),
-			"region":    r.azureLoc,
-			"image":     plan.Image.ValueString(),
-			"size":      vmSize,
-			"public_ip": plan.PublicIP.ValueBool(),
-		})
+		plan.ID = types.StringValue(vmID)
+		plan.setVolumes(azureVolumeIDs(vm))
 	case "gcp":
-		if r.gcp == nil {
-			resp.Diagnostics.AddError("gcp", "missing client")
-			return
-		}
-		zone := plan.Region.ValueString()
-		if zone == "" {
-			zone = r.gcpRegion
-		}
-		if zone == "" {
-			zone = "us-central1-a"
-		}
-		size := plan.Size.ValueString()
-		if size == "" {
-			size = "small"
+		zone := r.gcpZone(plan.Region.ValueString(), plan.Zone.ValueString())
+		if !gcpZonePattern.MatchString(zone) {
+			// a region: use its first zone
+			first, err := gcpFirstZone(ctx, r.gcp, r.gcpProj, zone)
+			if err != nil {
+				resp.Diagnostics.AddError("gcp zone", err.Error())
+				return
+			}
+			zone = first
 		}
-		machineType := size
-		switch strings.ToLower(size) {
-		case "small":
-			machineType = "e2-small"
-		case "medium":
-			machineType = "e2-medium"
-		case "large":
-			machineType = "e2-standard-4"
-		default:
-			machineType = size
+		if plan.Zone.IsNull() {
+			plan.Zone = types.StringValue(zone)
 		}
+		machineType := r.machineType("gcp", plan.Size.ValueString())
 		image := plan.Image.ValueString()
 		if image == "" {
 			image = "projects/debian-cloud/global/images/family/debian-11"
@@ -364,120 +777,383 @@ func (r *InstanceResource) Create(ctx context.Context, req resource.CreateReques
 				Network: fmt.Sprintf("projects/%s/global/networks/default", r.gcpProj),
 			}},
 		}
+		if labels := shared.MergeTags(r.defaultTags, stringMap(plan.Labels)); len(labels) > 0 {
+			inst.Labels = labels
+		}
+		if subnet := plan.SubnetID.ValueString(); subnet != "" {
+			// the network is implied by the subnetwork
+			if !strings.Contains(subnet, "/") {
+				subnet = fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", r.gcpProj, gcpZoneRegion(zone), subnet)
+			}
+			inst.NetworkInterfaces[0] = &compute.NetworkInterface{Subnetwork: subnet}
+		}
 		if plan.PublicIP.ValueBool() {
 			inst.NetworkInterfaces[0].AccessConfigs = []*compute.AccessConfig{{
 				Name: "External",
 				Type: "ONE_TO_ONE_NAT",
 			}}
 		}
-		_, err := r.gcp.Instances.Insert(r.gcpProj, zone, inst).Context(ctx).Do()
+		if static := plan.StaticIPID.ValueString(); static != "" {
+			address, err := r.gcpStaticAddress(ctx, zone, static)
+			if err != nil {
+				resp.Diagnostics.AddError("gcp static ip", err.Error())
+				return
+			}
+			inst.NetworkInterfaces[0].AccessConfigs = []*compute.AccessConfig{{
+				Name:  "External",
+				Type:  "ONE_TO_ONE_NAT",
+				NatIP: address,
+			}}
+		}
+		op, err := r.gcp.Instances.Insert(r.gcpProj, zone, inst).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
 		if err != nil {
 			resp.Diagnostics.AddError("gcp create instance", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":        inst.Name,
-			"name":      plan.Name.ValueString(),
-			"type":      plan.Type.ValueString(),
-			"region":    zone,
-			"image":     image,
-			"size":      machineType,
-			"public_ip": plan.PublicIP.ValueBool(),
-		})
+		plan.ID = types.StringValue(inst.Name)
+		created, err := r.gcpWaitRunning(ctx, zone, inst.Name, plan.waitForRunning())
+		if err != nil {
+			resp.Diagnostics.AddError("gcp read instance", err.Error())
+			// keep the instance in state so it is not leaked; Read fills in volume_ids
+			plan.VolumeIDs = types.ListNull(types.StringType)
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+			return
+		}
+		plan.setVolumes(gcpVolumeIDs(created))
 	default:
 		resp.Diagnostics.AddError("unsupported cloud", "only aws and azure implemented")
+		return
 	}
+	id, err := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
-func (r *InstanceResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID     types.String `tfsdk:"id"`
-		Type   types.String `tfsdk:"type"`
-		Region types.String `tfsdk:"region"`
+// cloudID is the instance's ARN, the VM's ID or the instance's self link.
+func (r *InstanceResource) cloudID(ctx context.Context, m *instanceResourceModel) (string, error) {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.ARN(ctx, "ec2", true, "instance/"+id)
+	case "azure":
+		return id, nil
+	case "gcp":
+		return r.ids.GCPSelfLink(fmt.Sprintf("zones/%s/instances/%s", r.gcpZone(m.Region.ValueString(), m.Zone.ValueString()), id)), nil
 	}
+	return "", nil
+}
+
+func (r *InstanceResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state instanceResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	// instances created before wait_for_running existed were waited for
+	if state.WaitForRunning.IsNull() {
+		state.WaitForRunning = types.BoolValue(true)
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.ec2 == nil {
-			return
-		}
 		out, err := r.ec2.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{state.ID.ValueString()}})
 		if err != nil || len(out.Reservations) == 0 || len(out.Reservations[0].Instances) == 0 {
 			resp.State.RemoveResource(ctx)
-		}
-	case "azure":
-		if r.azureVM == nil {
 			return
 		}
-		_, err := r.azureVM.Get(ctx, "abstract-rg", state.ID.ValueString(), nil)
+		inst := out.Reservations[0].Instances[0]
+		state.setVolumes(awsVolumeIDs(out))
+		// tags added in the console show up as drift
+		state.setEC2Tags(inst.Tags, r.defaultTags)
+		if !state.InstanceProfile.IsNull() {
+			state.setInstanceProfile(inst.IamInstanceProfile)
+		}
+	case "azure":
+		vm, err := r.azureVM.Get(ctx, "abstract-rg", azureVMName(state.ID.ValueString()), nil)
 		if err != nil {
 			resp.State.RemoveResource(ctx)
+			return
 		}
+		state.setVolumes(azureVolumeIDs(vm.VirtualMachine))
 	case "gcp":
-		if r.gcp == nil {
+		zone := r.gcpZone(state.Region.ValueString(), state.Zone.ValueString())
+		inst, err := r.gcp.Instances.Get(r.gcpProj, zone, state.ID.ValueString()).Context(ctx).Do()
+		if err != nil {
+			resp.State.RemoveResource(ctx)
 			return
 		}
-		zone := state.Region.ValueString()
-		if zone == "" {
-			zone = r.gcpRegion
+		state.setVolumes(gcpVolumeIDs(inst))
+	default:
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+// runInstances launches the instance, retrying while a new instance profile
+// has not yet propagated from IAM to EC2.
+func (r *InstanceResource) runInstances(ctx context.Context, input *ec2.RunInstancesInput) (*ec2.RunInstancesOutput, error) {
+	for attempt := 1; ; attempt++ {
+		out, err := r.ec2.RunInstances(ctx, input)
+		if err == nil || input.IamInstanceProfile == nil || awsErrorCode(err) != "InvalidParameterValue" || attempt == 12 {
+			return out, err
 		}
-		if zone == "" {
-			zone = "us-central1-a"
+		if err := shared.Sleep(ctx, instanceProfilePollInterval); err != nil {
+			return nil, err
 		}
-		_, err := r.gcp.Instances.Get(r.gcpProj, zone, state.ID.ValueString()).Context(ctx).Do()
-		if err != nil {
-			resp.State.RemoveResource(ctx)
+	}
+}
+
+// awsInstanceProfileSpec refers to an instance profile by ARN or by name.
+func awsInstanceProfileSpec(profile string) *ec2types.IamInstanceProfileSpecification {
+	if strings.HasPrefix(profile, "arn:") {
+		return &ec2types.IamInstanceProfileSpecification{Arn: aws.String(profile)}
+	}
+	return &ec2types.IamInstanceProfileSpecification{Name: aws.String(profile)}
+}
+
+// setInstanceProfile records the profile associated with the instance,
+// keeping the configured name or ARN while it still refers to that profile.
+func (m *instanceResourceModel) setInstanceProfile(p *ec2types.IamInstanceProfile) {
+	if p == nil || p.Arn == nil {
+		m.InstanceProfile = types.StringNull()
+		return
+	}
+	arn, want := aws.ToString(p.Arn), m.InstanceProfile.ValueString()
+	if arn == want || strings.HasSuffix(arn, "/"+want) {
+		return
+	}
+	m.InstanceProfile = types.StringValue(arn)
+}
+
+// associateInstanceProfile replaces the instance's profile with profile, or
+// removes it when profile is empty.
+func (r *InstanceResource) associateInstanceProfile(ctx context.Context, id, profile string) error {
+	out, err := r.ec2.DescribeIamInstanceProfileAssociations(ctx, &ec2.DescribeIamInstanceProfileAssociationsInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("instance-id"), Values: []string{id}},
+			{Name: aws.String("state"), Values: []string{"associating", "associated"}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	var assoc string
+	if len(out.IamInstanceProfileAssociations) > 0 {
+		assoc = aws.ToString(out.IamInstanceProfileAssociations[0].AssociationId)
+	}
+	switch {
+	case profile == "" && assoc == "":
+		return nil
+	case profile == "":
+		_, err = r.ec2.DisassociateIamInstanceProfile(ctx, &ec2.DisassociateIamInstanceProfileInput{AssociationId: aws.String(assoc)})
+	case assoc == "":
+		_, err = r.ec2.AssociateIamInstanceProfile(ctx, &ec2.AssociateIamInstanceProfileInput{InstanceId: aws.String(id), IamInstanceProfile: awsInstanceProfileSpec(profile)})
+	default:
+		_, err = r.ec2.ReplaceIamInstanceProfileAssociation(ctx, &ec2.ReplaceIamInstanceProfileAssociationInput{AssociationId: aws.String(assoc), IamInstanceProfile: awsInstanceProfileSpec(profile)})
+	}
+	return err
+}
+
+// setVolumes records the IDs of the instance's attached volumes.
+func (m *instanceResourceModel) setVolumes(ids []string) {
+	values := []attr.Value{}
+	for _, id := range ids {
+		values = append(values, types.StringValue(id))
+	}
+	m.VolumeIDs = types.ListValueMust(types.StringType, values)
+}
+
+// awsVolumeIDs returns the EBS volumes in the instance's block device mappings.
+func awsVolumeIDs(out *ec2.DescribeInstancesOutput) []string {
+	var ids []string
+	for _, res := range out.Reservations {
+		for _, inst := range res.Instances {
+			for _, m := range inst.BlockDeviceMappings {
+				if m.Ebs != nil && m.Ebs.VolumeId != nil {
+					ids = append(ids, *m.Ebs.VolumeId)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// azureVolumeIDs returns the managed OS and data disks of a VM.
+func azureVolumeIDs(vm armcompute.VirtualMachine) []string {
+	if vm.Properties == nil || vm.Properties.StorageProfile == nil {
+		return nil
+	}
+	sp := vm.Properties.StorageProfile
+	var ids []string
+	if sp.OSDisk != nil && sp.OSDisk.ManagedDisk != nil && sp.OSDisk.ManagedDisk.ID != nil {
+		ids = append(ids, *sp.OSDisk.ManagedDisk.ID)
+	}
+	for _, d := range sp.DataDisks {
+		if d.ManagedDisk != nil && d.ManagedDisk.ID != nil {
+			ids = append(ids, *d.ManagedDisk.ID)
+		}
+	}
+	return ids
+}
+
+// gcpVolumeIDs returns the source disks attached to an instance.
+func gcpVolumeIDs(inst *compute.Instance) []string {
+	var ids []string
+	for _, d := range inst.Disks {
+		if d.Source != "" {
+			ids = append(ids, d.Source)
 		}
 	}
+	return ids
+}
+
+// azureVMName returns the VM name from its resource ID.
+func azureVMName(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
 }
 func (r *InstanceResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state instanceResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	if plan.VolumeIDs.IsUnknown() {
+		plan.VolumeIDs = state.VolumeIDs
+	}
+	if plan.Type.ValueString() == "aws" && !plan.EBSOptimized.IsNull() && !plan.EBSOptimized.Equal(state.EBSOptimized) {
+		instanceType := r.machineType("aws", plan.Size.ValueString())
+		if err := r.checkInstanceCapabilities(ctx, instanceType, plan.EBSOptimized, types.BoolNull()); err != nil {
+			resp.Diagnostics.AddError("aws instance type", err.Error())
+			return
+		}
+		// EbsOptimized can only be changed while the instance is stopped
+		ids := []string{state.ID.ValueString()}
+		_, err := r.ec2.StopInstances(ctx, &ec2.StopInstancesInput{InstanceIds: ids})
+		if err == nil {
+			err = ec2.NewInstanceStoppedWaiter(r.ec2).Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: ids}, 10*time.Minute)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("aws stop instance", err.Error())
+			return
+		}
+		_, err = r.ec2.ModifyInstanceAttribute(ctx, &ec2.ModifyInstanceAttributeInput{
+			InstanceId:   aws.String(state.ID.ValueString()),
+			EbsOptimized: &ec2types.AttributeBooleanValue{Value: aws.Bool(plan.EBSOptimized.ValueBool())},
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("aws modify instance", err.Error())
+			return
+		}
+		_, err = r.ec2.StartInstances(ctx, &ec2.StartInstancesInput{InstanceIds: ids})
+		if err != nil {
+			resp.Diagnostics.AddError("aws start instance", err.Error())
+			return
+		}
+	}
+	if plan.Type.ValueString() == "aws" {
+		prior, want := state.ec2Tags(r.defaultTags), plan.ec2Tags(r.defaultTags)
+		if !maps.Equal(prior, want) {
+			if err := r.retagEC2(ctx, state.ID.ValueString(), prior, want); err != nil {
+				resp.Diagnostics.AddError("aws tag instance", err.Error())
+				return
+			}
+		}
+	}
+	if plan.Type.ValueString() == "aws" && !plan.InstanceProfile.Equal(state.InstanceProfile) {
+		if err := r.associateInstanceProfile(ctx, state.ID.ValueString(), plan.InstanceProfile.ValueString()); err != nil {
+			resp.Diagnostics.AddError("aws instance profile", err.Error())
+			return
+		}
+	}
+	if plan.Type.ValueString() == "gcp" && !maps.Equal(stringMap(plan.Labels), stringMap(state.Labels)) {
+		zone := r.gcpZone(state.Region.ValueString(), state.Zone.ValueString())
+		// SetLabels replaces every label and needs the current fingerprint
+		inst, err := r.gcp.Instances.Get(r.gcpProj, zone, state.ID.ValueString()).Context(ctx).Do()
+		if err == nil {
+			var op *compute.Operation
+			op, err = r.gcp.Instances.SetLabels(r.gcpProj, zone, state.ID.ValueString(), &compute.InstancesSetLabelsRequest{
+				Labels:           shared.MergeTags(r.defaultTags, stringMap(plan.Labels)),
+				LabelFingerprint: inst.LabelFingerprint,
+			}).Context(ctx).Do()
+			if err == nil {
+				err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+			}
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp set labels", err.Error())
+			return
+		}
+	}
+	if prior, want := state.StaticIPID.ValueString(), plan.StaticIPID.ValueString(); prior != want {
+		if err := r.setStaticIP(ctx, &plan, prior, want); err != nil {
+			resp.Diagnostics.AddError(plan.Type.ValueString()+" static ip", err.Error())
+			return
+		}
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 func (r *InstanceResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		ID     types.String `tfsdk:"id"`
-		Type   types.String `tfsdk:"type"`
-		Region types.String `tfsdk:"region"`
-	}
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state instanceResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
-	switch state.Type.ValueString() {
-	case "aws":
-		if r.ec2 == nil {
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	// a static IP is disassociated but kept; on GCP deleting the instance frees it
+	if static := state.StaticIPID.ValueString(); static != "" {
+		var err error
+		switch state.Type.ValueString() {
+		case "aws":
+			err = awsDisassociateAddress(ctx, r.ec2, static, state.ID.ValueString())
+		case "azure":
+			err = azureSetVMPublicIP(ctx, r.azureVM, r.azureNIC, state.ID.ValueString(), static, false)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError(state.Type.ValueString()+" disassociate static ip", err.Error())
 			return
 		}
+	}
+	switch state.Type.ValueString() {
+	case "aws":
 		_, err := r.ec2.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: []string{state.ID.ValueString()}})
 		if err != nil {
 			resp.Diagnostics.AddError("aws terminate", err.Error())
 		}
 	case "azure":
-		if r.azureVM == nil {
-			return
-		}
-		poller, err := r.azureVM.BeginDelete(ctx, "abstract-rg", state.ID.ValueString(), nil)
+		poller, err := r.azureVM.BeginDelete(ctx, "abstract-rg", azureVMName(state.ID.ValueString()), nil)
 		if err == nil {
-			_, err = poller.PollUntilDone(ctx, nil)
+			_, err = shared.PollAzure(ctx, poller)
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure delete", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure delete", err)
 		}
 	case "gcp":
-		if r.gcp == nil {
-			return
-		}
-		zone := state.Region.ValueString()
-		if zone == "" {
-			zone = r.gcpRegion
-		}
-		if zone == "" {
-			zone = "us-central1-a"
-		}
+		zone := r.gcpZone(state.Region.ValueString(), state.Zone.ValueString())
 		_, err := r.gcp.Instances.Delete(r.gcpProj, zone, state.ID.ValueString()).Context(ctx).Do()
 		if err != nil {
 			resp.Diagnostics.AddError("gcp delete", err.Error())
@@ -0,0 +1,86 @@
+package resources
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"abstract-provider/provider/shared"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// ec2Tags returns the tags an EC2 instance should carry: the provider
+// defaults overlaid with tags, plus Name when the instance has one.
+func (m *instanceResourceModel) ec2Tags(defaults map[string]string) map[string]string {
+	tags := shared.MergeTags(defaults, stringMap(m.Tags))
+	if name := m.Name.ValueString(); name != "" {
+		tags["Name"] = name
+	}
+	return tags
+}
+
+// tagList converts tags to EC2 tags, sorted by key.
+func tagList(tags map[string]string) []ec2types.Tag {
+	list := make([]ec2types.Tag, 0, len(tags))
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		list = append(list, ec2types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return list
+}
+
+// setEC2Tags records the instance's tags as read from EC2. Name and the
+// reserved aws: tags have attributes of their own or belong to AWS, and a
+// provider default still carrying its default value is left out unless tags
+// sets that key itself. No tags leave an unset attribute null.
+func (m *instanceResourceModel) setEC2Tags(remote []ec2types.Tag, defaults map[string]string) {
+	own := stringMap(m.Tags)
+	tags := map[string]attr.Value{}
+	for _, t := range remote {
+		k, v := aws.ToString(t.Key), aws.ToString(t.Value)
+		if k == "Name" || strings.HasPrefix(k, "aws:") {
+			continue
+		}
+		if d, ok := defaults[k]; ok && d == v {
+			if _, set := own[k]; !set {
+				continue
+			}
+		}
+		tags[k] = types.StringValue(v)
+	}
+	if len(tags) == 0 && m.Tags.IsNull() {
+		return
+	}
+	m.Tags = types.MapValueMust(types.StringType, tags)
+}
+
+// retagEC2 moves the instance from the prior tags to want, deleting the keys
+// that are gone and writing the ones that are new or changed.
+func (r *InstanceResource) retagEC2(ctx context.Context, id string, prior, want map[string]string) error {
+	var stale []ec2types.Tag
+	for _, k := range slices.Sorted(maps.Keys(prior)) {
+		if _, ok := want[k]; !ok {
+			stale = append(stale, ec2types.Tag{Key: aws.String(k)})
+		}
+	}
+	if len(stale) > 0 {
+		if _, err := r.ec2.DeleteTags(ctx, &ec2.DeleteTagsInput{Resources: []string{id}, Tags: stale}); err != nil {
+			return err
+		}
+	}
+	changed := map[string]string{}
+	for k, v := range want {
+		if old, ok := prior[k]; !ok || old != v {
+			changed[k] = v
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	_, err := r.ec2.CreateTags(ctx, &ec2.CreateTagsInput{Resources: []string{id}, Tags: tagList(changed)})
+	return err
+}
@@ -0,0 +1,588 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func createInstance(t *testing.T, r *InstanceResource, vals map[string]tftypes.Value) (instanceResourceModel, *resource.CreateResponse) {
+	t.Helper()
+	ctx := context.Background()
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, vals)}, resp)
+	var got instanceResourceModel
+	if !resp.Diagnostics.HasError() {
+		resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	}
+	return got, resp
+}
+
+func TestInstanceCreateAWSSize(t *testing.T) {
+	for _, tc := range []struct {
+		size string
+		want ec2types.InstanceType
+	}{
+		{"", ec2types.InstanceTypeT3Small},
+		{"small", ec2types.InstanceTypeT3Small},
+		{"Medium", ec2types.InstanceTypeT3Medium},
+		{"large", ec2types.InstanceTypeT3Large},
+		{"c5.xlarge", ec2types.InstanceTypeC5Xlarge},
+	} {
+		t.Run(tc.size, func(t *testing.T) {
+			ec2 := newFakeEC2()
+			r := &InstanceResource{ec2: ec2}
+			vals := map[string]tftypes.Value{"name": str("web"), "type": str("aws"), "image": str("ami-0abc")}
+			if tc.size != "" {
+				vals["size"] = str(tc.size)
+			}
+			got, resp := createInstance(t, r, vals)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("create: %v", resp.Diagnostics)
+			}
+			run, ok := ec2.instances[got.ID.ValueString()]
+			if !ok {
+				t.Fatalf("instance %q not created", got.ID.ValueString())
+			}
+			if run.InstanceType != tc.want {
+				t.Errorf("instance type = %q, want %q", run.InstanceType, tc.want)
+			}
+			if tags := ec2.tags[got.ID.ValueString()]; len(tags) != 1 || aws.ToString(tags[0].Value) != "web" {
+				t.Errorf("tags = %+v", tags)
+			}
+		})
+	}
+}
+
+func TestInstanceMachineType(t *testing.T) {
+	r := &InstanceResource{sizeAliases: map[string]map[string]string{
+		"aws": {"large": "m5.large", "xlarge": "m5.xlarge"},
+	}}
+	for _, tc := range []struct{ cloud, size, want string }{
+		{"aws", "Large", "m5.large"},
+		{"aws", "xlarge", "m5.xlarge"},
+		{"aws", "", "t3.small"},
+		{"gcp", "large", "e2-standard-4"},
+		{"azure", "medium", "Standard_B2s"},
+		{"gcp", "n2-standard-8", "n2-standard-8"},
+	} {
+		if got := r.machineType(tc.cloud, tc.size); got != tc.want {
+			t.Errorf("%s %q = %q, want %q", tc.cloud, tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestInstanceCreateAWSNetwork(t *testing.T) {
+	ec2 := newFakeEC2()
+	r := &InstanceResource{ec2: ec2}
+	got, resp := createInstance(t, r, map[string]tftypes.Value{
+		"type":      str("aws"),
+		"image":     str("ami-0abc"),
+		"subnet_id": str("subnet-0abc"),
+		"public_ip": boolean(false),
+	})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	run := ec2.instances[got.ID.ValueString()]
+	if len(run.NetworkInterfaces) != 1 {
+		t.Fatalf("network interfaces = %+v", run.NetworkInterfaces)
+	}
+	nic := run.NetworkInterfaces[0]
+	if aws.ToString(nic.SubnetId) != "subnet-0abc" || nic.AssociatePublicIpAddress == nil || *nic.AssociatePublicIpAddress {
+		t.Errorf("network interface = %+v", nic)
+	}
+	if len(ec2.tags) != 0 {
+		t.Errorf("unnamed instance tagged: %v", ec2.tags)
+	}
+}
+
+func TestInstanceCreateAWSErrors(t *testing.T) {
+	base := map[string]tftypes.Value{"type": str("aws"), "image": str("ami-0abc")}
+	with := func(extra map[string]tftypes.Value) map[string]tftypes.Value {
+		vals := maps.Clone(base)
+		maps.Copy(vals, extra)
+		return vals
+	}
+	for _, tc := range []struct {
+		name string
+		vals map[string]tftypes.Value
+		err  error
+	}{
+		{"no image", map[string]tftypes.Value{"type": str("aws")}, nil},
+		{"api error", base, errors.New("InsufficientInstanceCapacity")},
+		{"unknown instance type", with(map[string]tftypes.Value{"size": str("x9.huge"), "ebs_optimized": boolean(true)}), nil},
+		{"unsupported ebs optimization", with(map[string]tftypes.Value{"ebs_optimized": boolean(true)}), nil},
+		{"unsupported enclave", with(map[string]tftypes.Value{"enclave_options": boolean(true)}), nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ec2 := newFakeEC2()
+			ec2.types[ec2types.InstanceTypeT3Small] = ec2types.InstanceTypeInfo{
+				InstanceType: ec2types.InstanceTypeT3Small,
+				EbsInfo:      &ec2types.EbsInfo{EbsOptimizedSupport: ec2types.EbsOptimizedSupportUnsupported},
+			}
+			ec2.err = tc.err
+			r := &InstanceResource{ec2: ec2}
+			_, resp := createInstance(t, r, tc.vals)
+			if !resp.Diagnostics.HasError() {
+				t.Fatal("expected error")
+			}
+			if len(ec2.instances) != 0 {
+				t.Errorf("instances created: %v", ec2.instances)
+			}
+			if !resp.State.Raw.IsNull() {
+				t.Error("state written after failed create")
+			}
+		})
+	}
+}
+
+func TestInstanceCreateAWSZone(t *testing.T) {
+	ec2 := newFakeEC2()
+	r := &InstanceResource{ec2: ec2}
+	got, resp := createInstance(t, r, map[string]tftypes.Value{"type": str("aws"), "image": str("ami-0abc"), "availability_zone": str("us-east-1b")})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if p := ec2.instances[got.ID.ValueString()].Placement; p == nil || aws.ToString(p.AvailabilityZone) != "us-east-1b" {
+		t.Errorf("placement = %+v", p)
+	}
+	if got.Zone.ValueString() != "us-east-1b" {
+		t.Errorf("availability_zone = %s", got.Zone)
+	}
+
+	got, resp = createInstance(t, r, map[string]tftypes.Value{"type": str("aws"), "image": str("ami-0abc")})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if p := ec2.instances[got.ID.ValueString()].Placement; p != nil {
+		t.Errorf("placement = %+v without availability_zone", p)
+	}
+	if got.Zone.ValueString() != "us-east-1a" {
+		t.Errorf("availability_zone = %s, want the zone EC2 placed it in", got.Zone)
+	}
+
+	_, resp = createInstance(t, r, map[string]tftypes.Value{"type": str("aws"), "image": str("ami-0abc"), "availability_zone": str("us-east-1f")})
+	if !resp.Diagnostics.HasError() {
+		t.Error("zone outside the region accepted")
+	}
+	if len(ec2.instances) != 2 {
+		t.Errorf("%d instances, want 2", len(ec2.instances))
+	}
+}
+
+func TestInstanceAWSStaticIP(t *testing.T) {
+	ctx := context.Background()
+	ec2 := newFakeEC2()
+	ec2.eips["eipalloc-1"], ec2.eips["eipalloc-2"] = "", ""
+	r := &InstanceResource{ec2: ec2}
+	vals := map[string]tftypes.Value{"type": str("aws"), "image": str("ami-0abc"), "static_ip_id": str("eipalloc-1")}
+	got, resp := createInstance(t, r, vals)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	id := got.ID.ValueString()
+	if ec2.eips["eipalloc-1"] != id {
+		t.Errorf("eipalloc-1 associated with %q, want %s", ec2.eips["eipalloc-1"], id)
+	}
+	if nics := ec2.instances[id].NetworkInterfaces; len(nics) != 1 || aws.ToBool(nics[0].AssociatePublicIpAddress) {
+		t.Errorf("launched with an ephemeral public IP: %+v", nics)
+	}
+
+	// moving to another address leaves the first one unassociated
+	vals["id"] = str(id)
+	planned := maps.Clone(vals)
+	planned["static_ip_id"] = str("eipalloc-2")
+	upd := &resource.UpdateResponse{State: testState(t, r, vals)}
+	r.Update(ctx, resource.UpdateRequest{Plan: testPlan(t, r, planned), State: testState(t, r, vals)}, upd)
+	if upd.Diagnostics.HasError() {
+		t.Fatalf("update: %v", upd.Diagnostics)
+	}
+	if ec2.eips["eipalloc-2"] != id {
+		t.Errorf("eipalloc-2 associated with %q, want %s", ec2.eips["eipalloc-2"], id)
+	}
+
+	del := &resource.DeleteResponse{}
+	r.Delete(ctx, resource.DeleteRequest{State: upd.State}, del)
+	if del.Diagnostics.HasError() {
+		t.Fatalf("delete: %v", del.Diagnostics)
+	}
+	if _, ok := ec2.eips["eipalloc-2"]; !ok || ec2.eips["eipalloc-2"] != "" {
+		t.Errorf("eipalloc-2 = %q after delete, want kept and unassociated", ec2.eips["eipalloc-2"])
+	}
+}
+
+func TestInstanceStaticIPConfig(t *testing.T) {
+	r := &InstanceResource{}
+	s := testSchema(t, r)
+	vals := map[string]tftypes.Value{"type": str("aws"), "static_ip_id": str("eipalloc-1"), "public_ip": boolean(false)}
+	resp := &resource.ValidateConfigResponse{}
+	r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, vals, false)}}, resp)
+	if !resp.Diagnostics.HasError() {
+		t.Error("static_ip_id with public_ip = false accepted")
+	}
+}
+
+func TestInstanceGCPZone(t *testing.T) {
+	r := &InstanceResource{gcpRegion: "europe-west1"}
+	cases := []struct{ region, zone, want string }{
+		{"", "", "europe-west1"},
+		{"us-east1-c", "", "us-east1-c"},
+		{"us-east1", "b", "us-east1-b"},
+		{"us-east1-c", "b", "us-east1-b"},
+		{"", "d", "europe-west1-d"},
+		{"us-east1", "us-east1-d", "us-east1-d"},
+	}
+	for _, tc := range cases {
+		if got := r.gcpZone(tc.region, tc.zone); got != tc.want {
+			t.Errorf("gcpZone(%q, %q) = %q, want %q", tc.region, tc.zone, got, tc.want)
+		}
+	}
+}
+
+func TestInstanceGCPRegionConfig(t *testing.T) {
+	r := &InstanceResource{}
+	s := testSchema(t, r)
+	cases := []struct {
+		region, zone string
+		ok           bool
+	}{
+		{"us-central1", "", true},
+		{"us-central1-a", "", true},
+		{"us-central1", "b", true},
+		{"us-central", "", false},
+		{"us-central1", "us-central1", false},
+		{"us-central1", "bb", false},
+	}
+	for _, tc := range cases {
+		vals := map[string]tftypes.Value{"type": str("gcp"), "region": str(tc.region)}
+		if tc.zone != "" {
+			vals["availability_zone"] = str(tc.zone)
+		}
+		resp := &resource.ValidateConfigResponse{}
+		r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, vals, false)}}, resp)
+		if resp.Diagnostics.HasError() == tc.ok {
+			t.Errorf("region %q, zone %q: %v", tc.region, tc.zone, resp.Diagnostics)
+		}
+	}
+}
+
+func TestInstanceCreateMissingClient(t *testing.T) {
+	for _, cloud := range []string{"aws", "azure", "gcp", "oracle"} {
+		t.Run(cloud, func(t *testing.T) {
+			_, resp := createInstance(t, &InstanceResource{}, map[string]tftypes.Value{"type": str(cloud), "image": str("image")})
+			if !resp.Diagnostics.HasError() {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestInstanceUpdateEBSOptimized(t *testing.T) {
+	ec2 := newFakeEC2()
+	ec2.types[ec2types.InstanceTypeT3Small] = ec2types.InstanceTypeInfo{
+		InstanceType: ec2types.InstanceTypeT3Small,
+		EbsInfo:      &ec2types.EbsInfo{EbsOptimizedSupport: ec2types.EbsOptimizedSupportSupported},
+	}
+	r := &InstanceResource{ec2: ec2}
+	got, resp := createInstance(t, r, map[string]tftypes.Value{"type": str("aws"), "image": str("ami-0abc")})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	id := got.ID.ValueString()
+	vals := map[string]tftypes.Value{"id": str(id), "type": str("aws"), "image": str("ami-0abc")}
+	planned := maps.Clone(vals)
+	planned["ebs_optimized"] = boolean(true)
+	upd := &resource.UpdateResponse{State: testState(t, r, vals)}
+	r.Update(context.Background(), resource.UpdateRequest{Plan: testPlan(t, r, planned), State: testState(t, r, vals)}, upd)
+	if upd.Diagnostics.HasError() {
+		t.Fatalf("update: %v", upd.Diagnostics)
+	}
+	if !aws.ToBool(ec2.instances[id].EbsOptimized) {
+		t.Error("ebs optimization not enabled")
+	}
+}
+
+func TestInstanceVolumeIDs(t *testing.T) {
+	ec2 := newFakeEC2()
+	r := &InstanceResource{ec2: ec2}
+	got, resp := createInstance(t, r, map[string]tftypes.Value{"type": str("aws"), "image": str("ami-0abc")})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	var ids []string
+	resp.Diagnostics.Append(got.VolumeIDs.ElementsAs(context.Background(), &ids, false)...)
+	if len(ids) != 1 || ids[0] != "vol-0001" {
+		t.Fatalf("volume_ids = %v", ids)
+	}
+
+	// volumes attached since creation show up on refresh
+	vals := map[string]tftypes.Value{"id": str(got.ID.ValueString()), "type": str("aws"), "image": str("ami-0abc"), "volume_ids": strList()}
+	read := &resource.ReadResponse{State: testState(t, r, vals)}
+	r.Read(context.Background(), resource.ReadRequest{State: testState(t, r, vals)}, read)
+	var state instanceResourceModel
+	read.Diagnostics.Append(read.State.Get(context.Background(), &state)...)
+	if read.Diagnostics.HasError() {
+		t.Fatalf("read: %v", read.Diagnostics)
+	}
+	if n := len(state.VolumeIDs.Elements()); n != 1 {
+		t.Errorf("read %d volumes, want 1", n)
+	}
+}
+
+// ec2TagMap returns an instance's tags in the fake by key.
+func ec2TagMap(f *fakeEC2, id string) map[string]string {
+	tags := map[string]string{}
+	for _, t := range f.tags[id] {
+		tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return tags
+}
+
+func TestInstanceAWSTags(t *testing.T) {
+	ec2 := newFakeEC2()
+	r := &InstanceResource{ec2: ec2, defaultTags: map[string]string{"owner": "platform", "team": "ops"}}
+	vals := map[string]tftypes.Value{"name": str("web"), "type": str("aws"), "image": str("ami-0abc"), "tags": strMap(map[string]string{"team": "web", "env": "dev"})}
+	got, resp := createInstance(t, r, vals)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	id := got.ID.ValueString()
+	want := map[string]string{"Name": "web", "owner": "platform", "team": "web", "env": "dev"}
+	if tags := ec2TagMap(ec2, id); !maps.Equal(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+
+	// a tag added in the console is drift; Name and defaults are not
+	ec2.tags[id] = append(ec2.tags[id], ec2types.Tag{Key: aws.String("debug"), Value: aws.String("1")})
+	vals["id"] = str(id)
+	vals["volume_ids"] = strList("vol-0001")
+	read := &resource.ReadResponse{State: testState(t, r, vals)}
+	r.Read(context.Background(), resource.ReadRequest{State: testState(t, r, vals)}, read)
+	var state instanceResourceModel
+	read.Diagnostics.Append(read.State.Get(context.Background(), &state)...)
+	if read.Diagnostics.HasError() {
+		t.Fatalf("read: %v", read.Diagnostics)
+	}
+	if tags := stringMap(state.Tags); !maps.Equal(tags, map[string]string{"team": "web", "env": "dev", "debug": "1"}) {
+		t.Errorf("read tags = %v", tags)
+	}
+
+	// the update restores the configured tags and renames the instance
+	vals["tags"] = strMap(map[string]string{"team": "web", "env": "dev", "debug": "1"})
+	planned := maps.Clone(vals)
+	planned["name"] = str("api")
+	planned["tags"] = strMap(map[string]string{"team": "web"})
+	upd := &resource.UpdateResponse{State: testState(t, r, vals)}
+	r.Update(context.Background(), resource.UpdateRequest{Plan: testPlan(t, r, planned), State: testState(t, r, vals)}, upd)
+	if upd.Diagnostics.HasError() {
+		t.Fatalf("update: %v", upd.Diagnostics)
+	}
+	want = map[string]string{"Name": "api", "owner": "platform", "team": "web"}
+	if tags := ec2TagMap(ec2, id); !maps.Equal(tags, want) {
+		t.Errorf("tags after update = %v, want %v", tags, want)
+	}
+}
+
+func TestInstanceAWSInstanceProfile(t *testing.T) {
+	ctx := context.Background()
+	ec2 := newFakeEC2()
+	r := &InstanceResource{ec2: ec2}
+	vals := map[string]tftypes.Value{"type": str("aws"), "image": str("ami-0abc"), "instance_profile": str("app")}
+	got, resp := createInstance(t, r, vals)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	id := got.ID.ValueString()
+	if p := ec2.instances[id].IamInstanceProfile; p == nil || aws.ToString(p.Name) != "app" {
+		t.Errorf("launched with profile %+v, want app", p)
+	}
+
+	// the configured name is kept while the instance has that profile
+	vals["id"] = str(id)
+	vals["volume_ids"] = strList("vol-0001")
+	read := &resource.ReadResponse{State: testState(t, r, vals)}
+	r.Read(ctx, resource.ReadRequest{State: testState(t, r, vals)}, read)
+	var state instanceResourceModel
+	read.Diagnostics.Append(read.State.Get(ctx, &state)...)
+	if read.Diagnostics.HasError() || state.InstanceProfile.ValueString() != "app" {
+		t.Fatalf("read: %v, instance_profile = %v", read.Diagnostics, state.InstanceProfile)
+	}
+
+	// a profile swapped outside Terraform is drift
+	const other = "arn:aws:iam::123456789012:instance-profile/other"
+	ec2.instances[id].IamInstanceProfile = &ec2types.IamInstanceProfileSpecification{Arn: aws.String(other)}
+	r.Read(ctx, resource.ReadRequest{State: testState(t, r, vals)}, read)
+	read.Diagnostics.Append(read.State.Get(ctx, &state)...)
+	if state.InstanceProfile.ValueString() != other {
+		t.Errorf("instance_profile after drift = %v, want %s", state.InstanceProfile, other)
+	}
+
+	// the update puts the configured profile back, and removing it detaches it
+	drifted := maps.Clone(vals)
+	drifted["instance_profile"] = str(other)
+	upd := &resource.UpdateResponse{State: testState(t, r, drifted)}
+	r.Update(ctx, resource.UpdateRequest{Plan: testPlan(t, r, vals), State: testState(t, r, drifted)}, upd)
+	if upd.Diagnostics.HasError() {
+		t.Fatalf("update: %v", upd.Diagnostics)
+	}
+	if p := ec2.instances[id].IamInstanceProfile; p == nil || aws.ToString(p.Name) != "app" {
+		t.Errorf("profile after update = %+v, want app", p)
+	}
+	planned := maps.Clone(vals)
+	delete(planned, "instance_profile")
+	upd = &resource.UpdateResponse{State: testState(t, r, vals)}
+	r.Update(ctx, resource.UpdateRequest{Plan: testPlan(t, r, planned), State: testState(t, r, vals)}, upd)
+	if upd.Diagnostics.HasError() || ec2.instances[id].IamInstanceProfile != nil {
+		t.Fatalf("update: %v, profile %+v", upd.Diagnostics, ec2.instances[id].IamInstanceProfile)
+	}
+}
+
+func TestInstanceProfileConfig(t *testing.T) {
+	r := &InstanceResource{}
+	s := testSchema(t, r)
+	vals := map[string]tftypes.Value{"type": str("gcp"), "instance_profile": str("app")}
+	resp := &resource.ValidateConfigResponse{}
+	r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, vals, false)}}, resp)
+	if !resp.Diagnostics.HasError() {
+		t.Error("instance_profile accepted on gcp")
+	}
+}
+
+func TestInstanceReadNoTags(t *testing.T) {
+	ec2 := newFakeEC2()
+	r := &InstanceResource{ec2: ec2, defaultTags: map[string]string{"owner": "platform"}}
+	got, resp := createInstance(t, r, map[string]tftypes.Value{"name": str("web"), "type": str("aws"), "image": str("ami-0abc")})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	vals := map[string]tftypes.Value{"id": str(got.ID.ValueString()), "name": str("web"), "type": str("aws"), "image": str("ami-0abc"), "volume_ids": strList("vol-0001")}
+	read := &resource.ReadResponse{State: testState(t, r, vals)}
+	r.Read(context.Background(), resource.ReadRequest{State: testState(t, r, vals)}, read)
+	var state instanceResourceModel
+	read.Diagnostics.Append(read.State.Get(context.Background(), &state)...)
+	if read.Diagnostics.HasError() {
+		t.Fatalf("read: %v", read.Diagnostics)
+	}
+	if !state.Tags.IsNull() {
+		t.Errorf("tags = %v, want null with only Name and default tags", state.Tags)
+	}
+}
+
+func TestInstanceCreateAWSKeepsPendingInstance(t *testing.T) {
+	ec2 := newFakeEC2()
+	ec2.launch = ec2types.InstanceStateNamePending
+	r := &InstanceResource{ec2: ec2, timeout: 50 * time.Millisecond}
+	ctx := context.Background()
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, map[string]tftypes.Value{"type": str("aws"), "image": str("ami-0abc")})}, resp)
+	if !resp.Diagnostics.HasError() {
+		t.Fatal("expected the wait for the instance to fail")
+	}
+	var got instanceResourceModel
+	if diags := resp.State.Get(ctx, &got); diags.HasError() {
+		t.Fatalf("state: %v", diags)
+	}
+	if got.ID.ValueString() != "i-0001" || !got.VolumeIDs.IsNull() {
+		t.Errorf("state = id %v, volume_ids %v", got.ID, got.VolumeIDs)
+	}
+}
+
+func TestInstanceCreateAWSNoWait(t *testing.T) {
+	ec2 := newFakeEC2()
+	ec2.launch = ec2types.InstanceStateNamePending
+	r := &InstanceResource{ec2: ec2, timeout: 50 * time.Millisecond}
+	got, resp := createInstance(t, r, map[string]tftypes.Value{"type": str("aws"), "image": str("ami-0abc"), "wait_for_running": boolean(false)})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if got.ID.ValueString() != "i-0001" || !got.VolumeIDs.IsNull() {
+		t.Errorf("state = id %v, volume_ids %v", got.ID, got.VolumeIDs)
+	}
+}
+
+func TestAzureVolumeIDs(t *testing.T) {
+	const disks = "/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Compute/disks/"
+	vm := armcompute.VirtualMachine{Properties: &armcompute.VirtualMachineProperties{StorageProfile: &armcompute.StorageProfile{
+		OSDisk:    &armcompute.OSDisk{ManagedDisk: &armcompute.ManagedDiskParameters{ID: to.Ptr(disks + "web_OsDisk")}},
+		DataDisks: []*armcompute.DataDisk{{ManagedDisk: &armcompute.ManagedDiskParameters{ID: to.Ptr(disks + "web-data")}}, {}},
+	}}}
+	if got := azureVolumeIDs(vm); !slices.Equal(got, []string{disks + "web_OsDisk", disks + "web-data"}) {
+		t.Errorf("volume ids = %v", got)
+	}
+	if got := azureVolumeIDs(armcompute.VirtualMachine{}); got != nil {
+		t.Errorf("vm without storage profile = %v", got)
+	}
+	if got := azureVMName("/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Compute/virtualMachines/web"); got != "web" {
+		t.Errorf("vm name = %q", got)
+	}
+}
+
+func TestAzureImageReference(t *testing.T) {
+	ref, err := azureImageReference("")
+	if err != nil || aws.ToString(ref.Publisher) != "Canonical" || aws.ToString(ref.SKU) != "22_04-lts" {
+		t.Errorf("default image = %+v, %v", ref, err)
+	}
+	ref, err = azureImageReference("MicrosoftWindowsServer:WindowsServer:2022-datacenter:latest")
+	if err != nil || aws.ToString(ref.Publisher) != "MicrosoftWindowsServer" || aws.ToString(ref.Offer) != "WindowsServer" ||
+		aws.ToString(ref.SKU) != "2022-datacenter" || aws.ToString(ref.Version) != "latest" || ref.ID != nil {
+		t.Errorf("urn = %+v, %v", ref, err)
+	}
+	const gallery = "/subscriptions/s/resourceGroups/images/providers/Microsoft.Compute/galleries/g/images/web/versions/1.0.0"
+	ref, err = azureImageReference(gallery)
+	if err != nil || aws.ToString(ref.ID) != gallery || ref.Publisher != nil {
+		t.Errorf("image id = %+v, %v", ref, err)
+	}
+	for _, bad := range []string{"Canonical:ubuntu", "a:b::latest", "a:b:c:d:e"} {
+		if _, err := azureImageReference(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestEnsureAzureSubnet(t *testing.T) {
+	const vnet = "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.Network/virtualNetworks/abstract-vnet"
+	arm := newFakeARM()
+	arm.notFound = true
+	existing := map[string]any{"id": vnet, "location": "westeurope", "properties": map[string]any{"subnets": []any{map[string]any{"name": "apps"}}}}
+	arm.puts[vnet] = existing
+	r := &InstanceResource{}
+	var err error
+	if r.azureVNet, err = armnetwork.NewVirtualNetworksClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	if r.azureSub, err = armnetwork.NewSubnetsClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	id, err := r.ensureAzureSubnet(context.Background(), "abstract-rg", "eastus")
+	if err != nil || id != vnet+"/subnets/default" {
+		t.Fatalf("subnet = %q, %v", id, err)
+	}
+	if arm.puts[vnet]["location"] != "westeurope" {
+		t.Errorf("existing vnet was put again: %v", arm.puts[vnet])
+	}
+	if id, err := r.ensureAzureSubnet(context.Background(), "abstract-rg", "eastus"); err != nil || id != vnet+"/subnets/default" {
+		t.Errorf("existing subnet = %q, %v", id, err)
+	}
+
+	delete(arm.puts, vnet)
+	delete(arm.puts, vnet+"/subnets/default")
+	if _, err := r.ensureAzureSubnet(context.Background(), "abstract-rg", "eastus"); err != nil {
+		t.Fatal(err)
+	}
+	if arm.puts[vnet]["location"] != "eastus" || arm.puts[vnet+"/subnets/default"] == nil {
+		t.Errorf("missing network not created: %v", arm.puts)
+	}
+}
@@ -0,0 +1,22 @@
+package resources
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// refreshKMSKey records the key a cloud reports in key. AWS may report a key
+// ARN for a configured key ID or alias, so a key that names the same key as
+// the configured one is kept as configured. An empty key means the
+// cloud-managed key.
+func refreshKMSKey(key *types.String, actual string) {
+	configured := key.ValueString()
+	switch {
+	case actual == "":
+		*key = types.StringNull()
+	case configured != "" && (actual == configured || strings.HasSuffix(actual, ":"+configured) || strings.HasSuffix(actual, "/"+configured)):
+	default:
+		*key = types.StringValue(actual)
+	}
+}
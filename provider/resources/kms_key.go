@@ -0,0 +1,550 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// gcpKeyRing is the key ring GCP keys are created in, one per location.
+// Key rings and keys can never be deleted on GCP.
+const gcpKeyRing = "abstract"
+
+// kmsDeletionWindowDays is how long a deleted AWS key can still be restored.
+const kmsDeletionWindowDays = 30
+
+var kmsKeyNames = map[string]*regexp.Regexp{
+	"aws":   regexp.MustCompile(`^[a-zA-Z0-9/_-]{1,250}$`),
+	"azure": regexp.MustCompile(`^[a-zA-Z0-9-]{1,127}$`),
+	"gcp":   regexp.MustCompile(`^[a-zA-Z0-9_-]{1,63}$`),
+}
+
+// kmsRotationDays are the shortest and longest rotation periods each cloud
+// allows; 0 is no limit.
+var kmsRotationDays = map[string][2]int64{
+	"aws":   {90, 2560},
+	"azure": {7, 0},
+	"gcp":   {1, 0},
+}
+
+// KMSKeyResource manages a symmetric encryption key other resources can use
+// as their kms_key_id: a KMS key with an alias on AWS, a key in the Key Vault
+// at AZURE_KEY_VAULT_URL on Azure, or a Cloud KMS key on GCP.
+type KMSKeyResource struct {
+	kms *kms.Client
+
+	azureCred azcore.TokenCredential
+	// azureOpts are the options of the Key Vault clients; nil uses the defaults.
+	azureOpts *policy.ClientOptions
+
+	gcpKMS    *cloudkms.Service
+	gcpProj   string
+	gcpRegion string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+}
+
+type kmsKeyResourceModel struct {
+	ID           types.String `tfsdk:"id"`
+	CloudID      types.String `tfsdk:"cloud_id"`
+	Type         types.String `tfsdk:"type"`
+	Name         types.String `tfsdk:"name"`
+	RotationDays types.Int64  `tfsdk:"rotation_days"`
+	Region       types.String `tfsdk:"region"`
+}
+
+func NewKMSKeyResource() resource.Resource { return &KMSKeyResource{} }
+
+func (r *KMSKeyResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.kms = cfg.AWSKMS
+	r.azureCred = cfg.AzureCred
+	r.gcpKMS = cfg.GCPKMS
+	r.gcpProj = cfg.GCPProject
+	r.gcpRegion = cfg.GCPRegion
+}
+
+func (r *KMSKeyResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_kms_key"
+}
+
+func (r *KMSKeyResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			// Key ARN, versionless Key Vault key ID or crypto key name; what
+			// kms_key_id takes.
+			"id":       schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"cloud_id": cloudIDAttribute(),
+			"type":     schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// The alias on AWS, without "alias/".
+			"name": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// Days between automatic rotations; unset is no rotation.
+			"rotation_days": schema.Int64Attribute{Optional: true},
+			// AWS region or GCP location of the key; Azure keys live in the vault.
+			"region": schema.StringAttribute{Optional: true, PlanModifiers: replace},
+		},
+	}
+}
+
+func (r *KMSKeyResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg kmsKeyResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() || cfg.Type.IsUnknown() {
+		return
+	}
+	cloud := cfg.Type.ValueString()
+	if re, ok := kmsKeyNames[cloud]; ok && !cfg.Name.IsUnknown() {
+		name := cfg.Name.ValueString()
+		switch {
+		case !re.MatchString(name):
+			resp.Diagnostics.AddAttributeError(path.Root("name"), "invalid name", fmt.Sprintf("%q is not a valid %s key name", name, cloud))
+		case cloud == "aws" && (strings.HasPrefix(name, "alias/") || strings.HasPrefix(name, "aws/")):
+			resp.Diagnostics.AddAttributeError(path.Root("name"), "invalid name", `give the alias without "alias/"; aliases starting with "aws/" are reserved`)
+		}
+	}
+	if limits, ok := kmsRotationDays[cloud]; ok && !cfg.RotationDays.IsNull() && !cfg.RotationDays.IsUnknown() {
+		days := cfg.RotationDays.ValueInt64()
+		if days < limits[0] || limits[1] > 0 && days > limits[1] {
+			msg := fmt.Sprintf("%s keys rotate at least every %d days", cloud, limits[0])
+			if limits[1] > 0 {
+				msg = fmt.Sprintf("%s keys rotate every %d to %d days", cloud, limits[0], limits[1])
+			}
+			resp.Diagnostics.AddAttributeError(path.Root("rotation_days"), "invalid rotation_days", msg)
+		}
+	}
+	if cloud == "azure" && !cfg.Region.IsNull() {
+		resp.Diagnostics.AddAttributeWarning(path.Root("region"), "region ignored", "Azure keys are created in the Key Vault at AZURE_KEY_VAULT_URL")
+	}
+}
+
+// useRegion points the AWS client at the key's region.
+func (r *KMSKeyResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.kms = c.KMS
+	}
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *KMSKeyResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.kms != nil
+	case "azure":
+		return r.azureCred != nil
+	case "gcp":
+		return r.gcpKMS != nil
+	}
+	return true
+}
+
+// awsAlias returns the alias of a key named name.
+func awsAlias(name string) string { return "alias/" + name }
+
+// isKMSNotFound reports whether err says the key or alias does not exist.
+func isKMSNotFound(err error) bool {
+	var nf *kmstypes.NotFoundException
+	return errors.As(err, &nf)
+}
+
+// azureKeyRef splits a versionless Key Vault key ID into the vault URL and key name.
+func azureKeyRef(id string) (string, string, error) {
+	vault, name, ok := strings.Cut(id, "/keys/")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("%q is not a Key Vault key ID", id)
+	}
+	return vault, name, nil
+}
+
+// gcpKeyRingName returns the key ring of m's location: its region,
+// defaulting to the provider's.
+func (r *KMSKeyResource) gcpKeyRingName(m *kmsKeyResourceModel) string {
+	loc := m.Region.ValueString()
+	if loc == "" {
+		loc = r.gcpRegion
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/keyRings/%s", r.gcpProj, loc, gcpKeyRing)
+}
+
+// ensureGCPKeyRing creates the key ring unless it exists.
+func (r *KMSKeyResource) ensureGCPKeyRing(ctx context.Context, name string) error {
+	_, err := r.gcpKMS.Projects.Locations.KeyRings.Get(name).Context(ctx).Do()
+	if gcpStatus(err) != http.StatusNotFound {
+		return err
+	}
+	parent := name[:strings.LastIndex(name, "/keyRings/")]
+	_, err = r.gcpKMS.Projects.Locations.KeyRings.Create(parent, &cloudkms.KeyRing{}).KeyRingId(gcpKeyRing).Context(ctx).Do()
+	if gcpStatus(err) == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+// gcpStatus returns the HTTP status of a failed Google API call, or 0.
+func gcpStatus(err error) int {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		return gErr.Code
+	}
+	return 0
+}
+
+// gcpRotation returns the rotation settings of a key rotated every days
+// days, empty for none.
+func gcpRotation(days int64) (period, next string) {
+	if days <= 0 {
+		return "", ""
+	}
+	d := time.Duration(days) * 24 * time.Hour
+	return fmt.Sprintf("%ds", int64(d.Seconds())), time.Now().Add(d).UTC().Format(time.RFC3339)
+}
+
+// gcpRotationDays returns the days of a rotation period such as "7776000s".
+func gcpRotationDays(period string) int64 {
+	d, err := time.ParseDuration(period)
+	if err != nil {
+		return 0
+	}
+	return int64(d / (24 * time.Hour))
+}
+
+// setRotation records the rotation a cloud reports; a key that is not
+// rotated has no rotation_days.
+func (m *kmsKeyResourceModel) setRotation(days int64) {
+	if days == 0 {
+		m.RotationDays = types.Int64Null()
+		return
+	}
+	m.RotationDays = types.Int64Value(days)
+}
+
+func (r *KMSKeyResource) azureKeys() (*shared.AzureKeysClient, error) {
+	vaultURL := os.Getenv("AZURE_KEY_VAULT_URL")
+	if vaultURL == "" {
+		return nil, errors.New("AZURE_KEY_VAULT_URL not set")
+	}
+	return shared.NewAzureKeysClient(vaultURL, r.azureCred, r.azureOpts)
+}
+
+func (r *KMSKeyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan kmsKeyResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	name, days := plan.Name.ValueString(), plan.RotationDays.ValueInt64()
+	switch plan.Type.ValueString() {
+	case "aws":
+		out, err := r.kms.CreateKey(ctx, &kms.CreateKeyInput{Description: aws.String("abstract_kms_key " + name)})
+		if err != nil {
+			resp.Diagnostics.AddError("aws create", err.Error())
+			return
+		}
+		keyID := out.KeyMetadata.KeyId
+		if _, err := r.kms.CreateAlias(ctx, &kms.CreateAliasInput{AliasName: aws.String(awsAlias(name)), TargetKeyId: keyID}); err != nil {
+			// a key without its alias would be lost, so it goes too
+			_, _ = r.kms.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{KeyId: keyID, PendingWindowInDays: aws.Int32(7)})
+			resp.Diagnostics.AddError("aws alias", err.Error())
+			return
+		}
+		if days > 0 {
+			if _, err := r.kms.EnableKeyRotation(ctx, &kms.EnableKeyRotationInput{KeyId: keyID, RotationPeriodInDays: aws.Int32(int32(days))}); err != nil {
+				resp.Diagnostics.AddError("aws rotation", err.Error())
+				// keep the key in state so it is deleted on destroy
+				plan.RotationDays = types.Int64Null()
+			}
+		}
+		plan.ID = types.StringValue(aws.ToString(out.KeyMetadata.Arn))
+	case "azure":
+		client, err := r.azureKeys()
+		if err != nil {
+			resp.Diagnostics.AddError("azure", err.Error())
+			return
+		}
+		// creating an existing key adds a version to it, so take it over only
+		// explicitly, through import
+		if _, err := client.GetKey(ctx, name); azureStatus(err) != http.StatusNotFound {
+			if err == nil {
+				err = fmt.Errorf("key %s already exists in the vault", name)
+			}
+			shared.AddAzureError(&resp.Diagnostics, "azure create", err)
+			return
+		}
+		key, err := client.CreateKey(ctx, name)
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure create", err)
+			return
+		}
+		if days > 0 {
+			if err := client.SetKeyRotation(ctx, name, days); err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure rotation", err)
+				plan.RotationDays = types.Int64Null()
+			}
+		}
+		plan.ID = types.StringValue(key.ID())
+	case "gcp":
+		ring := r.gcpKeyRingName(&plan)
+		if err := r.ensureGCPKeyRing(ctx, ring); err != nil {
+			resp.Diagnostics.AddError("gcp key ring", err.Error())
+			return
+		}
+		period, next := gcpRotation(days)
+		key, err := r.gcpKMS.Projects.Locations.KeyRings.CryptoKeys.Create(ring, &cloudkms.CryptoKey{
+			Purpose:          "ENCRYPT_DECRYPT",
+			RotationPeriod:   period,
+			NextRotationTime: next,
+		}).CryptoKeyId(name).Context(ctx).Do()
+		if gcpStatus(err) == http.StatusConflict {
+			err = fmt.Errorf("%w; GCP keys cannot be deleted, so a deleted key's name cannot be used again", err)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp create", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(key.Name)
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	plan.CloudID = plan.ID
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Read drops a key that is gone or on its way out: pending deletion on AWS,
+// deleted from the vault on Azure or with no version left to use on GCP.
+func (r *KMSKeyResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state kmsKeyResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	id := state.ID.ValueString()
+	switch state.Type.ValueString() {
+	case "aws":
+		out, err := r.kms.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(id)})
+		if isKMSNotFound(err) || err == nil && (out.KeyMetadata.KeyState == kmstypes.KeyStatePendingDeletion || out.KeyMetadata.KeyState == kmstypes.KeyStatePendingReplicaDeletion) {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("aws read", err.Error())
+			return
+		}
+		rot, err := r.kms.GetKeyRotationStatus(ctx, &kms.GetKeyRotationStatusInput{KeyId: aws.String(id)})
+		if err != nil {
+			resp.Diagnostics.AddError("aws rotation", err.Error())
+			return
+		}
+		var days int64
+		if rot.KeyRotationEnabled {
+			days = int64(aws.ToInt32(rot.RotationPeriodInDays))
+		}
+		state.setRotation(days)
+	case "azure":
+		vault, name, err := azureKeyRef(id)
+		if err != nil {
+			resp.Diagnostics.AddError("azure read", err.Error())
+			return
+		}
+		client, err := shared.NewAzureKeysClient(vault, r.azureCred, r.azureOpts)
+		if err != nil {
+			resp.Diagnostics.AddError("azure", err.Error())
+			return
+		}
+		if _, err := client.GetKey(ctx, name); err != nil {
+			if azureStatus(err) == http.StatusNotFound {
+				resp.State.RemoveResource(ctx)
+				return
+			}
+			shared.AddAzureError(&resp.Diagnostics, "azure read", err)
+			return
+		}
+		days, err := client.KeyRotation(ctx, name)
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure rotation", err)
+			return
+		}
+		state.setRotation(days)
+	case "gcp":
+		key, err := r.gcpKMS.Projects.Locations.KeyRings.CryptoKeys.Get(id).Context(ctx).Do()
+		if gcpStatus(err) == http.StatusNotFound || err == nil && (key.Primary == nil || key.Primary.State != "ENABLED") {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp read", err.Error())
+			return
+		}
+		state.setRotation(gcpRotationDays(key.RotationPeriod))
+	}
+	state.CloudID = state.ID
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+// Update changes the rotation; everything else replaces the key.
+func (r *KMSKeyResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state kmsKeyResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	id, days := state.ID.ValueString(), plan.RotationDays.ValueInt64()
+	switch plan.Type.ValueString() {
+	case "aws":
+		var err error
+		if days > 0 {
+			_, err = r.kms.EnableKeyRotation(ctx, &kms.EnableKeyRotationInput{KeyId: aws.String(id), RotationPeriodInDays: aws.Int32(int32(days))})
+		} else {
+			_, err = r.kms.DisableKeyRotation(ctx, &kms.DisableKeyRotationInput{KeyId: aws.String(id)})
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("aws rotation", err.Error())
+			return
+		}
+	case "azure":
+		vault, name, err := azureKeyRef(id)
+		if err != nil {
+			resp.Diagnostics.AddError("azure update", err.Error())
+			return
+		}
+		client, err := shared.NewAzureKeysClient(vault, r.azureCred, r.azureOpts)
+		if err == nil {
+			err = client.SetKeyRotation(ctx, name, days)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure rotation", err)
+			return
+		}
+	case "gcp":
+		period, next := gcpRotation(days)
+		_, err := r.gcpKMS.Projects.Locations.KeyRings.CryptoKeys.Patch(id, &cloudkms.CryptoKey{RotationPeriod: period, NextRotationTime: next}).
+			UpdateMask("rotationPeriod,nextRotationTime").Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp rotation", err.Error())
+			return
+		}
+	}
+	plan.ID, plan.CloudID = state.ID, state.CloudID
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Delete schedules an AWS key for deletion after kmsDeletionWindowDays and
+// removes its alias, soft-deletes an Azure key, and destroys every version
+// of a GCP key, which itself cannot be deleted, and stops its rotation.
+func (r *KMSKeyResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state kmsKeyResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	id := state.ID.ValueString()
+	switch state.Type.ValueString() {
+	case "aws":
+		if _, err := r.kms.DeleteAlias(ctx, &kms.DeleteAliasInput{AliasName: aws.String(awsAlias(state.Name.ValueString()))}); err != nil && !isKMSNotFound(err) {
+			resp.Diagnostics.AddError("aws alias", err.Error())
+			return
+		}
+		_, err := r.kms.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{KeyId: aws.String(id), PendingWindowInDays: aws.Int32(kmsDeletionWindowDays)})
+		if err != nil && !isKMSNotFound(err) {
+			resp.Diagnostics.AddError("aws delete", err.Error())
+		}
+	case "azure":
+		vault, name, err := azureKeyRef(id)
+		if err != nil {
+			resp.Diagnostics.AddError("azure delete", err.Error())
+			return
+		}
+		client, err := shared.NewAzureKeysClient(vault, r.azureCred, r.azureOpts)
+		if err == nil {
+			err = client.DeleteKey(ctx, name)
+		}
+		if err != nil && azureStatus(err) != http.StatusNotFound {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete", err)
+		}
+	case "gcp":
+		keys := r.gcpKMS.Projects.Locations.KeyRings.CryptoKeys
+		_, err := keys.Patch(id, &cloudkms.CryptoKey{}).UpdateMask("rotationPeriod,nextRotationTime").Context(ctx).Do()
+		if gcpStatus(err) == http.StatusNotFound {
+			return
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete", err.Error())
+			return
+		}
+		err = keys.CryptoKeyVersions.List(id).Filter("state = ENABLED OR state = DISABLED").Pages(ctx, func(page *cloudkms.ListCryptoKeyVersionsResponse) error {
+			for _, v := range page.CryptoKeyVersions {
+				if _, err := keys.CryptoKeyVersions.Destroy(v.Name, &cloudkms.DestroyCryptoKeyVersionRequest{}).Context(ctx).Do(); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete", err.Error())
+		}
+	}
+}
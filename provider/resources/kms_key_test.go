@@ -0,0 +1,278 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/option"
+)
+
+func TestKMSKeyConfig(t *testing.T) {
+	r := &KMSKeyResource{}
+	s := testSchema(t, r)
+	for _, tc := range []struct {
+		cloud, name string
+		days        int64
+		errs        bool
+	}{
+		{"aws", "app/data", 365, false},
+		{"aws", "alias/app", 0, true},
+		{"aws", "aws/s3", 0, true},
+		{"aws", "app", 30, true},
+		{"azure", "app-data", 30, false},
+		{"azure", "app_data", 0, true},
+		{"azure", "app", 1, true},
+		{"gcp", "app_data", 1, false},
+		{"gcp", "app/data", 0, true},
+	} {
+		t.Run(fmt.Sprintf("%s %s %d", tc.cloud, tc.name, tc.days), func(t *testing.T) {
+			vals := map[string]tftypes.Value{"type": str(tc.cloud), "name": str(tc.name)}
+			if tc.days > 0 {
+				vals["rotation_days"] = number(tc.days)
+			}
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestKMSKeyAWS(t *testing.T) {
+	const arn = "arn:aws:kms:eu-west-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var in map[string]any
+		json.NewDecoder(req.Body).Decode(&in)
+		action := strings.TrimPrefix(req.Header.Get("X-Amz-Target"), "TrentService.")
+		calls = append(calls, action)
+		switch {
+		case action == "CreateKey":
+			fmt.Fprintf(w, `{"KeyMetadata":{"KeyId":"1234abcd-12ab-34cd-56ef-1234567890ab","Arn":%q,"KeyState":"Enabled"}}`, arn)
+		case action == "CreateAlias" && in["AliasName"] == "alias/app", action == "EnableKeyRotation" && in["RotationPeriodInDays"] == float64(90):
+			w.Write([]byte(`{}`))
+		case action == "DescribeKey":
+			fmt.Fprintf(w, `{"KeyMetadata":{"KeyId":"1234abcd-12ab-34cd-56ef-1234567890ab","Arn":%q,"KeyState":"PendingDeletion"}}`, arn)
+		case action == "DeleteAlias":
+			w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"NotFoundException","message":"alias/app is not found"}`))
+		case action == "ScheduleKeyDeletion" && in["PendingWindowInDays"] == float64(kmsDeletionWindowDays):
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected %s %v", action, in)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	client := kms.New(kms.Options{
+		Region:       "eu-west-1",
+		BaseEndpoint: aws.String(srv.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "secret", ""),
+		Retryer:      aws.NopRetryer{},
+	})
+	r := &KMSKeyResource{kms: client}
+	ctx := context.Background()
+
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, map[string]tftypes.Value{"type": str("aws"), "name": str("app"), "rotation_days": number(90)})}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	var got kmsKeyResourceModel
+	resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	if got.ID.ValueString() != arn || got.RotationDays.ValueInt64() != 90 {
+		t.Errorf("id = %v, rotation_days = %v", got.ID, got.RotationDays)
+	}
+
+	// a key scheduled for deletion outside Terraform is gone
+	readResp := &resource.ReadResponse{State: resp.State}
+	r.Read(ctx, resource.ReadRequest{State: resp.State}, readResp)
+	if readResp.Diagnostics.HasError() || !readResp.State.Raw.IsNull() {
+		t.Errorf("read: %v, state %v", readResp.Diagnostics, readResp.State.Raw)
+	}
+
+	// an alias that is already gone does not stop the delete
+	delResp := &resource.DeleteResponse{State: resp.State}
+	r.Delete(ctx, resource.DeleteRequest{State: resp.State}, delResp)
+	if delResp.Diagnostics.HasError() {
+		t.Fatalf("delete: %v", delResp.Diagnostics)
+	}
+	if want := "CreateKey CreateAlias EnableKeyRotation DescribeKey DeleteAlias ScheduleKeyDeletion"; strings.Join(calls, " ") != want {
+		t.Errorf("calls = %v, want %s", calls, want)
+	}
+}
+
+// fakeVault serves the keys of a Key Vault, with their rotation policies.
+type fakeVault struct {
+	url      string
+	keys     map[string]bool
+	policies map[string]string
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Authorization") != "Bearer token" || req.URL.Query().Get("api-version") == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/keys/"), "/")
+	name := parts[0]
+	op := strings.Join(parts[1:], "/")
+	switch {
+	case req.Method == http.MethodPost && op == "create":
+		v.keys[name] = true
+		fallthrough
+	case req.Method == http.MethodGet && op == "" && v.keys[name]:
+		fmt.Fprintf(w, `{"key": {"kid": "%s/keys/%s/0123456789abcdef"}, "attributes": {"enabled": true}}`, v.url, name)
+	case req.Method == http.MethodDelete && op == "" && v.keys[name]:
+		delete(v.keys, name)
+		w.Write([]byte(`{}`))
+	case op == "rotationpolicy" && v.keys[name]:
+		if req.Method == http.MethodPut {
+			var in map[string]any
+			json.NewDecoder(req.Body).Decode(&in)
+			b, _ := json.Marshal(in)
+			v.policies[name] = string(b)
+		}
+		w.Write([]byte(v.policies[name]))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": {"code": "KeyNotFound"}}`))
+	}
+}
+
+func TestKMSKeyAzure(t *testing.T) {
+	vault := &fakeVault{keys: map[string]bool{}, policies: map[string]string{}}
+	srv := httptest.NewTLSServer(vault)
+	defer srv.Close()
+	vault.url = srv.URL
+	t.Setenv("AZURE_KEY_VAULT_URL", srv.URL+"/")
+	r := &KMSKeyResource{azureCred: fakeCredential{}, azureOpts: &policy.ClientOptions{Transport: srv.Client(), Retry: policy.RetryOptions{MaxRetries: -1}}}
+	ctx := context.Background()
+
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, map[string]tftypes.Value{"type": str("azure"), "name": str("app"), "rotation_days": number(90)})}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	var got kmsKeyResourceModel
+	resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	if want := srv.URL + "/keys/app"; got.ID.ValueString() != want || got.CloudID != got.ID {
+		t.Errorf("id = %v, cloud_id = %v, want %s", got.ID, got.CloudID, want)
+	}
+	if p := vault.policies["app"]; !strings.Contains(p, `"timeAfterCreate":"P90D"`) || !strings.Contains(p, `"Rotate"`) {
+		t.Errorf("rotation policy = %s", p)
+	}
+
+	// a second key of the same name would only add a version to the first
+	resp2 := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, map[string]tftypes.Value{"type": str("azure"), "name": str("app")})}, resp2)
+	if !resp2.Diagnostics.HasError() {
+		t.Error("created a key over an existing one")
+	}
+
+	// rotation turned off outside Terraform shows up as drift
+	vault.policies["app"] = `{"lifetimeActions": [{"trigger": {"timeBeforeExpiry": "P30D"}, "action": {"type": "Notify"}}]}`
+	readResp := &resource.ReadResponse{State: resp.State}
+	r.Read(ctx, resource.ReadRequest{State: resp.State}, readResp)
+	if readResp.Diagnostics.HasError() {
+		t.Fatalf("read: %v", readResp.Diagnostics)
+	}
+	readResp.State.Get(ctx, &got)
+	if !got.RotationDays.IsNull() {
+		t.Errorf("rotation_days = %v, want null", got.RotationDays)
+	}
+
+	delResp := &resource.DeleteResponse{State: resp.State}
+	r.Delete(ctx, resource.DeleteRequest{State: resp.State}, delResp)
+	if delResp.Diagnostics.HasError() || vault.keys["app"] {
+		t.Fatalf("delete: %v, keys %v", delResp.Diagnostics, vault.keys)
+	}
+	readResp = &resource.ReadResponse{State: resp.State}
+	r.Read(ctx, resource.ReadRequest{State: resp.State}, readResp)
+	if !readResp.State.Raw.IsNull() {
+		t.Errorf("deleted key kept in state")
+	}
+}
+
+func TestKMSKeyGCP(t *testing.T) {
+	const ring = "projects/p/locations/europe-west1/keyRings/abstract"
+	var created, destroyed []string
+	var rotation string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p := strings.TrimPrefix(req.URL.Path, "/v1/")
+		switch {
+		case req.Method == http.MethodGet && p == ring:
+			http.NotFound(w, req)
+		case req.Method == http.MethodPost && p == "projects/p/locations/europe-west1/keyRings":
+			created = append(created, req.URL.Query().Get("keyRingId"))
+			json.NewEncoder(w).Encode(cloudkms.KeyRing{Name: ring})
+		case req.Method == http.MethodPost && p == ring+"/cryptoKeys":
+			var key cloudkms.CryptoKey
+			json.NewDecoder(req.Body).Decode(&key)
+			rotation = key.RotationPeriod
+			key.Name = ring + "/cryptoKeys/" + req.URL.Query().Get("cryptoKeyId")
+			json.NewEncoder(w).Encode(key)
+		case req.Method == http.MethodPatch && p == ring+"/cryptoKeys/app":
+			var key cloudkms.CryptoKey
+			json.NewDecoder(req.Body).Decode(&key)
+			rotation = key.RotationPeriod
+			json.NewEncoder(w).Encode(key)
+		case req.Method == http.MethodGet && p == ring+"/cryptoKeys/app/cryptoKeyVersions":
+			json.NewEncoder(w).Encode(cloudkms.ListCryptoKeyVersionsResponse{CryptoKeyVersions: []*cloudkms.CryptoKeyVersion{
+				{Name: ring + "/cryptoKeys/app/cryptoKeyVersions/1"},
+				{Name: ring + "/cryptoKeys/app/cryptoKeyVersions/2"},
+			}})
+		case req.Method == http.MethodPost && strings.HasSuffix(p, ":destroy"):
+			destroyed = append(destroyed, strings.TrimSuffix(p, ":destroy"))
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected %s %s", req.Method, req.URL.Path)
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+	svc, err := cloudkms.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &KMSKeyResource{gcpKMS: svc, gcpProj: "p", gcpRegion: "europe-west1"}
+	ctx := context.Background()
+
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, map[string]tftypes.Value{"type": str("gcp"), "name": str("app"), "rotation_days": number(90)})}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	var got kmsKeyResourceModel
+	resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	if got.ID.ValueString() != ring+"/cryptoKeys/app" || len(created) != 1 || created[0] != "abstract" {
+		t.Errorf("id = %v, key rings created %v", got.ID, created)
+	}
+	if rotation != "7776000s" || gcpRotationDays(rotation) != 90 {
+		t.Errorf("rotation period = %q", rotation)
+	}
+
+	delResp := &resource.DeleteResponse{State: resp.State}
+	r.Delete(ctx, resource.DeleteRequest{State: resp.State}, delResp)
+	if delResp.Diagnostics.HasError() {
+		t.Fatalf("delete: %v", delResp.Diagnostics)
+	}
+	if rotation != "" || len(destroyed) != 2 {
+		t.Errorf("rotation period %q after delete, destroyed %v", rotation, destroyed)
+	}
+}
@@ -0,0 +1,29 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestRefreshKMSKey(t *testing.T) {
+	const arn = "arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	for _, tc := range []struct {
+		configured types.String
+		actual     string
+		want       types.String
+	}{
+		{types.StringValue("1234abcd-12ab-34cd-56ef-1234567890ab"), arn, types.StringValue("1234abcd-12ab-34cd-56ef-1234567890ab")},
+		{types.StringValue("alias/app"), "arn:aws:kms:us-east-1:123456789012:alias/app", types.StringValue("alias/app")},
+		{types.StringValue(arn), arn, types.StringValue(arn)},
+		{types.StringValue("alias/old"), arn, types.StringValue(arn)},
+		{types.StringNull(), arn, types.StringValue(arn)},
+		{types.StringValue(arn), "", types.StringNull()},
+	} {
+		key := tc.configured
+		refreshKMSKey(&key, tc.actual)
+		if !key.Equal(tc.want) {
+			t.Errorf("refresh %v with %q = %v, want %v", tc.configured, tc.actual, key, tc.want)
+		}
+	}
+}
@@ -3,6 +3,9 @@ package resources
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"abstract-provider/provider/shared"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -11,11 +14,19 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
 	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	compute "google.golang.org/api/compute/v1"
 )
 
 // LoadBalancerResource manages an abstract load balancer across clouds.
@@ -25,9 +36,63 @@ type LoadBalancerResource struct {
 	azureRG    *armresources.ResourceGroupsClient
 	azureLB    *armnetwork.LoadBalancersClient
 	azurePIP   *armnetwork.PublicIPAddressesClient
+	azureNIC   *armnetwork.InterfacesClient
+	azureVM    shared.AzureVMs
 	azureCred  azcore.TokenCredential
 	azureSubID string
 	azureLoc   string
+	gcp        *compute.Service
+	gcpProj    string
+	gcpRegion  string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	ids *shared.CloudIDs
+}
+
+type loadBalancerResourceModel struct {
+	ID        types.String `tfsdk:"id"`
+	CloudID   types.String `tfsdk:"cloud_id"`
+	Name      types.String `tfsdk:"name"`
+	Type      types.String `tfsdk:"type"`
+	Region    types.String `tfsdk:"region"`
+	IPAddress types.String `tfsdk:"ip_address"`
+	Internal  types.Bool   `tfsdk:"internal"`
+	LBType    types.String `tfsdk:"lb_type"`
+	// SecurityGroupIDs are the AWS security groups of the load balancer.
+	SecurityGroupIDs []string          `tfsdk:"security_group_ids"`
+	Listeners        []lbListenerModel `tfsdk:"listeners"`
+	TargetIDs        []string          `tfsdk:"target_ids"`
+}
+
+type lbListenerModel struct {
+	Port     types.Int64  `tfsdk:"port"`
+	Protocol types.String `tfsdk:"protocol"`
+}
+
+// protocol returns the listener protocol in lower case, tcp if unset.
+func (l lbListenerModel) protocol() string {
+	if p := l.Protocol.ValueString(); p != "" {
+		return strings.ToLower(p)
+	}
+	return "tcp"
+}
+
+// transport returns the protocol the listener uses on the wire: udp or tcp.
+func (l lbListenerModel) transport() string {
+	if l.protocol() == "udp" {
+		return "udp"
+	}
+	return "tcp"
+}
+
+// key identifies a listener by protocol and port, such as "tcp80".
+func (l lbListenerModel) key() string {
+	return fmt.Sprintf("%s%d", l.protocol(), l.Port.ValueInt64())
 }
 
 func NewLoadBalancerResource() resource.Resource { return &LoadBalancerResource{} }
@@ -41,14 +106,24 @@ func (r *LoadBalancerResource) Configure(ctx context.Context, req resource.Confi
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.ids = cfg.CloudIDs
 	r.elb = cfg.AWSELB
 	r.ec2 = cfg.AWSEC2
 	r.azureRG = cfg.AzureRGClient
 	r.azureLB = cfg.AzureLBClient
 	r.azurePIP = cfg.AzurePIPClient
+	r.azureNIC = cfg.AzureNICClient
+	if cfg.AzureVMClient != nil {
+		r.azureVM = cfg.AzureVMClient
+	}
 	r.azureCred = cfg.AzureCred
 	r.azureSubID = cfg.AzureSubID
 	r.azureLoc = cfg.AzureLocation
+	r.gcp = cfg.GCPCompute
+	r.gcpProj = cfg.GCPProject
+	r.gcpRegion = cfg.GCPRegion
 }
 
 func (r *LoadBalancerResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -58,51 +133,607 @@ func (r *LoadBalancerResource) Metadata(ctx context.Context, req resource.Metada
 func (r *LoadBalancerResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
-			"id":         schema.StringAttribute{Computed: true},
-			"name":       schema.StringAttribute{Required: true},
-			"type":       schema.StringAttribute{Required: true},
-			"region":     schema.StringAttribute{Optional: true},
-			"ip_address": schema.StringAttribute{Computed: true},
+			"id":       schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"cloud_id": cloudIDAttribute(),
+			"name":     schema.StringAttribute{Required: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"type":     schema.StringAttribute{Required: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			// Azure location, AWS region or GCP zone.
+			"region":     schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"ip_address": schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			// Internal load balancers get a private address in the default network instead of a public one.
+			// State from before the attribute existed is null and already matches the default.
+			"internal": schema.BoolAttribute{Optional: true, Computed: true, Default: booldefault.StaticBool(false),
+				PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplaceIf(
+					func(ctx context.Context, req planmodifier.BoolRequest, resp *boolplanmodifier.RequiresReplaceIfFuncResponse) {
+						resp.RequiresReplace = req.StateValue.ValueBool() != req.PlanValue.ValueBool()
+					},
+					"Changing internal replaces the load balancer.",
+					"Changing internal replaces the load balancer.",
+				)}},
+			// network (layer 4) or application (HTTP); only AWS has application load balancers.
+			"lb_type": schema.StringAttribute{Optional: true, Computed: true, Default: stringdefault.StaticString("network"),
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplaceIf(
+					func(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+						resp.RequiresReplace = !req.StateValue.IsNull() || req.PlanValue.ValueString() != "network"
+					},
+					"Changing lb_type replaces the load balancer.",
+					"Changing lb_type replaces the load balancer.",
+				)}},
+			"security_group_ids": schema.SetAttribute{ElementType: types.StringType, Optional: true},
+			// Each listener forwards its port to the same port on every target.
+			"listeners": schema.ListNestedAttribute{
+				Optional: true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"port":     schema.Int64Attribute{Required: true},
+						"protocol": schema.StringAttribute{Optional: true},
+					},
+				},
+			},
+			// IDs of abstract_instance resources: EC2 instance IDs, Azure VM IDs or GCP instance names.
+			"target_ids": schema.SetAttribute{ElementType: types.StringType, Optional: true},
 		},
 	}
 }
 
-func (r *LoadBalancerResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name   types.String `tfsdk:"name"`
-		Type   types.String `tfsdk:"type"`
-		Region types.String `tfsdk:"region"`
+func (r *LoadBalancerResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cloud, lbType types.String
+	var list types.List
+	var groups types.Set
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("type"), &cloud)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("lb_type"), &lbType)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("listeners"), &list)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("security_group_ids"), &groups)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	application := lbType.ValueString() == "application"
+	if t := lbType.ValueString(); t != "" && t != "network" && !application {
+		resp.Diagnostics.AddAttributeError(path.Root("lb_type"), "invalid lb_type", fmt.Sprintf("%q is not network or application", t))
+	}
+	if cloud.ValueString() == "aws" {
+		if application && groups.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("security_group_ids"), "missing security groups", "an application load balancer needs security_group_ids")
+		}
+	} else if !cloud.IsUnknown() {
+		if application {
+			resp.Diagnostics.AddAttributeWarning(path.Root("lb_type"), "no application load balancer", fmt.Sprintf("%s creates a network load balancer; http listeners are forwarded as tcp", cloud.ValueString()))
+		}
+		if !groups.IsNull() {
+			resp.Diagnostics.AddAttributeWarning(path.Root("security_group_ids"), "ignored", fmt.Sprintf("security_group_ids only applies to aws, not %s", cloud.ValueString()))
+		}
+	}
+	if list.IsUnknown() {
+		return
+	}
+	var listeners []lbListenerModel
+	resp.Diagnostics.Append(list.ElementsAs(ctx, &listeners, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	seen := map[string]bool{}
+	transports := map[string]bool{}
+	for i, l := range listeners {
+		p := path.Root("listeners").AtListIndex(i)
+		if l.Port.IsUnknown() || l.Protocol.IsUnknown() {
+			continue
+		}
+		if port := l.Port.ValueInt64(); port < 1 || port > 65535 {
+			resp.Diagnostics.AddAttributeError(p.AtName("port"), "invalid port", fmt.Sprintf("%d is not between 1 and 65535", port))
+		}
+		proto := l.protocol()
+		switch {
+		case application && proto != "http":
+			resp.Diagnostics.AddAttributeError(p.AtName("protocol"), "invalid protocol", fmt.Sprintf("an application load balancer listens on http, not %q", proto))
+		case !application && proto != "tcp" && proto != "udp":
+			resp.Diagnostics.AddAttributeError(p.AtName("protocol"), "invalid protocol", fmt.Sprintf("%q is not tcp or udp", proto))
+		}
+		if seen[l.key()] {
+			resp.Diagnostics.AddAttributeError(p, "duplicate listener", fmt.Sprintf("%s port %d is listed twice", proto, l.Port.ValueInt64()))
+		}
+		seen[l.key()] = true
+		transports[l.transport()] = true
+	}
+	if cloud.ValueString() == "gcp" && len(transports) > 1 {
+		resp.Diagnostics.AddAttributeError(path.Root("listeners"), "mixed protocols", "a gcp backend service carries one protocol, so listeners must all be tcp or all udp")
+	}
+	var targets types.Set
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("target_ids"), &targets)...)
+	if !targets.IsNull() && len(listeners) == 0 {
+		resp.Diagnostics.AddAttributeError(path.Root("target_ids"), "no listeners", "target_ids needs at least one listener to receive traffic")
+	}
+}
+
+// awsTargetGroupName derives a target group name (at most 32 characters) for a listener.
+func awsTargetGroupName(lb string, l lbListenerModel) string {
+	suffix := "-" + l.key()
+	if len(lb) > 32-len(suffix) {
+		lb = strings.TrimRight(lb[:32-len(suffix)], "-")
+	}
+	return lb + suffix
+}
+
+// awsListener is a listener of an AWS load balancer and the target group it forwards to.
+type awsListener struct {
+	arn, targetGroup string
+}
+
+// awsListeners returns the listeners of an AWS load balancer by key.
+func (r *LoadBalancerResource) awsListeners(ctx context.Context, lbArn string) (map[string]awsListener, error) {
+	out, err := r.elb.DescribeListeners(ctx, &elbv2.DescribeListenersInput{LoadBalancerArn: aws.String(lbArn)})
+	if err != nil {
+		return nil, err
+	}
+	listeners := map[string]awsListener{}
+	for _, l := range out.Listeners {
+		key := lbListenerModel{Port: types.Int64Value(int64(aws.ToInt32(l.Port))), Protocol: types.StringValue(string(l.Protocol))}.key()
+		var tg string
+		if len(l.DefaultActions) > 0 {
+			tg = aws.ToString(l.DefaultActions[0].TargetGroupArn)
+		}
+		listeners[key] = awsListener{arn: aws.ToString(l.ListenerArn), targetGroup: tg}
+	}
+	return listeners, nil
+}
+
+// awsSync makes the listeners and targets of an AWS load balancer match plan.
+// have lists the targets registered so far.
+func (r *LoadBalancerResource) awsSync(ctx context.Context, plan *loadBalancerResourceModel, have []string) error {
+	lbArn := plan.ID.ValueString()
+	existing, err := r.awsListeners(ctx, lbArn)
+	if err != nil {
+		return err
+	}
+	wanted := map[string]bool{}
+	for _, l := range plan.Listeners {
+		wanted[l.key()] = true
+	}
+	for key, l := range existing {
+		if wanted[key] {
+			continue
+		}
+		if _, err := r.elb.DeleteListener(ctx, &elbv2.DeleteListenerInput{ListenerArn: aws.String(l.arn)}); err != nil {
+			return err
+		}
+		if _, err := r.elb.DeleteTargetGroup(ctx, &elbv2.DeleteTargetGroupInput{TargetGroupArn: aws.String(l.targetGroup)}); err != nil {
+			return err
+		}
+	}
+	var vpc string
+	for _, l := range plan.Listeners {
+		if e, ok := existing[l.key()]; ok {
+			add, remove := diffStrings(have, plan.TargetIDs)
+			if err := r.awsSetTargets(ctx, e.targetGroup, add, remove); err != nil {
+				return err
+			}
+			continue
+		}
+		if vpc == "" {
+			out, err := r.elb.DescribeLoadBalancers(ctx, &elbv2.DescribeLoadBalancersInput{LoadBalancerArns: []string{lbArn}})
+			if err != nil {
+				return err
+			}
+			if len(out.LoadBalancers) == 0 {
+				return fmt.Errorf("load balancer %s not found", lbArn)
+			}
+			vpc = aws.ToString(out.LoadBalancers[0].VpcId)
+		}
+		proto := elbtypes.ProtocolEnum(strings.ToUpper(l.protocol()))
+		port := aws.Int32(int32(l.Port.ValueInt64()))
+		// UDP targets are checked over TCP on the same port
+		check := elbtypes.ProtocolEnumTcp
+		if proto == elbtypes.ProtocolEnumHttp {
+			check = elbtypes.ProtocolEnumHttp
+		}
+		tgOut, err := r.elb.CreateTargetGroup(ctx, &elbv2.CreateTargetGroupInput{
+			Name:                aws.String(awsTargetGroupName(plan.Name.ValueString(), l)),
+			Protocol:            proto,
+			Port:                port,
+			VpcId:               aws.String(vpc),
+			TargetType:          elbtypes.TargetTypeEnumInstance,
+			HealthCheckProtocol: check,
+		})
+		if err != nil {
+			return err
+		}
+		tg := aws.ToString(tgOut.TargetGroups[0].TargetGroupArn)
+		if err := r.awsSetTargets(ctx, tg, plan.TargetIDs, nil); err != nil {
+			return err
+		}
+		_, err = r.elb.CreateListener(ctx, &elbv2.CreateListenerInput{
+			LoadBalancerArn: aws.String(lbArn),
+			Protocol:        proto,
+			Port:            port,
+			DefaultActions:  []elbtypes.Action{{Type: elbtypes.ActionTypeEnumForward, TargetGroupArn: aws.String(tg)}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// awsSetTargets registers the instances in add with a target group and deregisters those in remove.
+func (r *LoadBalancerResource) awsSetTargets(ctx context.Context, tg string, add, remove []string) error {
+	if len(remove) > 0 {
+		_, err := r.elb.DeregisterTargets(ctx, &elbv2.DeregisterTargetsInput{TargetGroupArn: aws.String(tg), Targets: awsTargets(remove)})
+		if err != nil {
+			return err
+		}
+	}
+	if len(add) > 0 {
+		_, err := r.elb.RegisterTargets(ctx, &elbv2.RegisterTargetsInput{TargetGroupArn: aws.String(tg), Targets: awsTargets(add)})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func awsTargets(ids []string) []elbtypes.TargetDescription {
+	var targets []elbtypes.TargetDescription
+	for _, id := range ids {
+		targets = append(targets, elbtypes.TargetDescription{Id: aws.String(id)})
+	}
+	return targets
+}
+
+// azureLBRef returns the ID of a child resource, such as a backend pool, of an Azure load balancer.
+func (r *LoadBalancerResource) azureLBRef(lb, kind, name string) *string {
+	return to.Ptr(fmt.Sprintf("/subscriptions/%s/resourceGroups/abstract-rg/providers/Microsoft.Network/loadBalancers/%s/%s/%s", r.azureSubID, lb, kind, name))
+}
+
+// azureFrontend returns the frontend of an Azure load balancer: its public IP
+// or, if internal, a dynamic private IP in the subnet abstract_instance uses by default.
+func (r *LoadBalancerResource) azureFrontend(name string, internal bool) *armnetwork.FrontendIPConfigurationPropertiesFormat {
+	if internal {
+		return &armnetwork.FrontendIPConfigurationPropertiesFormat{
+			Subnet:                    &armnetwork.Subnet{ID: to.Ptr(fmt.Sprintf("/subscriptions/%s/resourceGroups/abstract-rg/providers/Microsoft.Network/virtualNetworks/abstract-vnet/subnets/default", r.azureSubID))},
+			PrivateIPAllocationMethod: to.Ptr(armnetwork.IPAllocationMethodDynamic),
+		}
+	}
+	return &armnetwork.FrontendIPConfigurationPropertiesFormat{
+		PublicIPAddress: &armnetwork.PublicIPAddress{ID: to.Ptr(fmt.Sprintf("/subscriptions/%s/resourceGroups/abstract-rg/providers/Microsoft.Network/publicIPAddresses/%s-pip", r.azureSubID, name))},
+	}
+}
+
+// azureLoadBalancer builds the complete definition of an Azure load balancer:
+// the frontend, one backend pool and a TCP probe and rule per listener.
+func (r *LoadBalancerResource) azureLoadBalancer(loc, name string, internal bool, listeners []lbListenerModel) armnetwork.LoadBalancer {
+	props := &armnetwork.LoadBalancerPropertiesFormat{
+		FrontendIPConfigurations: []*armnetwork.FrontendIPConfiguration{{
+			Name:       to.Ptr("lbfe"),
+			Properties: r.azureFrontend(name, internal),
+		}},
+		BackendAddressPools: []*armnetwork.BackendAddressPool{{Name: to.Ptr("lbbe")}},
+	}
+	for _, l := range listeners {
+		port := to.Ptr(int32(l.Port.ValueInt64()))
+		probe := "probe-" + l.key()
+		props.Probes = append(props.Probes, &armnetwork.Probe{
+			Name: to.Ptr(probe),
+			Properties: &armnetwork.ProbePropertiesFormat{
+				Protocol: to.Ptr(armnetwork.ProbeProtocolTCP),
+				Port:     port,
+			},
+		})
+		proto := armnetwork.TransportProtocolTCP
+		if l.transport() == "udp" {
+			proto = armnetwork.TransportProtocolUDP
+		}
+		props.LoadBalancingRules = append(props.LoadBalancingRules, &armnetwork.LoadBalancingRule{
+			Name: to.Ptr("rule-" + l.key()),
+			Properties: &armnetwork.LoadBalancingRulePropertiesFormat{
+				FrontendIPConfiguration: &armnetwork.SubResource{ID: r.azureLBRef(name, "frontendIPConfigurations", "lbfe")},
+				BackendAddressPool:      &armnetwork.SubResource{ID: r.azureLBRef(name, "backendAddressPools", "lbbe")},
+				Probe:                   &armnetwork.SubResource{ID: r.azureLBRef(name, "probes", probe)},
+				Protocol:                to.Ptr(proto),
+				FrontendPort:            port,
+				BackendPort:             port,
+			},
+		})
+	}
+	return armnetwork.LoadBalancer{Location: &loc, Properties: props}
+}
+
+// awsZoneSubnets returns the first subnet in each availability zone, since a
+// load balancer takes at most one subnet per zone.
+func awsZoneSubnets(subnets []ec2types.Subnet) []string {
+	var ids []string
+	zones := map[string]bool{}
+	for _, s := range subnets {
+		zone := aws.ToString(s.AvailabilityZone)
+		if zones[zone] {
+			continue
+		}
+		zones[zone] = true
+		ids = append(ids, aws.ToString(s.SubnetId))
+	}
+	return ids
+}
+
+// azurePrimaryIPConfig returns the primary IP configuration of a NIC.
+func azurePrimaryIPConfig(nic *armnetwork.Interface) (*armnetwork.InterfaceIPConfiguration, error) {
+	if nic.Properties == nil || len(nic.Properties.IPConfigurations) == 0 {
+		return nil, fmt.Errorf("network interface has no IP configuration")
+	}
+	for _, c := range nic.Properties.IPConfigurations {
+		if c.Properties != nil && c.Properties.Primary != nil && *c.Properties.Primary {
+			return c, nil
+		}
+	}
+	return nic.Properties.IPConfigurations[0], nil
+}
+
+// azureSetPool adds the primary NIC of a VM to the backend pool, or removes it.
+func (r *LoadBalancerResource) azureSetPool(ctx context.Context, vmID, pool string, member bool) error {
+	vmName := vmID[strings.LastIndex(vmID, "/")+1:]
+	vm, err := r.azureVM.Get(ctx, "abstract-rg", vmName, nil)
+	if err != nil {
+		return err
+	}
+	if vm.Properties == nil || vm.Properties.NetworkProfile == nil || len(vm.Properties.NetworkProfile.NetworkInterfaces) == 0 {
+		return fmt.Errorf("vm %s has no network interface", vmName)
+	}
+	nicID := *vm.Properties.NetworkProfile.NetworkInterfaces[0].ID
+	for _, ref := range vm.Properties.NetworkProfile.NetworkInterfaces {
+		if ref.Properties != nil && ref.Properties.Primary != nil && *ref.Properties.Primary {
+			nicID = *ref.ID
+		}
+	}
+	nicName := nicID[strings.LastIndex(nicID, "/")+1:]
+	nic, err := r.azureNIC.Get(ctx, "abstract-rg", nicName, nil)
+	if err != nil {
+		return err
+	}
+	ipc, err := azurePrimaryIPConfig(&nic.Interface)
+	if err != nil {
+		return err
+	}
+	pools := slices.DeleteFunc(ipc.Properties.LoadBalancerBackendAddressPools, func(p *armnetwork.BackendAddressPool) bool {
+		return p.ID != nil && strings.EqualFold(*p.ID, pool)
+	})
+	if member {
+		pools = append(pools, &armnetwork.BackendAddressPool{ID: to.Ptr(pool)})
+	}
+	ipc.Properties.LoadBalancerBackendAddressPools = pools
+	poller, err := r.azureNIC.BeginCreateOrUpdate(ctx, "abstract-rg", nicName, nic.Interface, nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, poller)
+	}
+	return err
+}
+
+// azureTargetIDs returns the IDs of the VMs in the backend pool of an Azure
+// load balancer, spelled as in known where they match case-insensitively.
+func (r *LoadBalancerResource) azureTargetIDs(ctx context.Context, lb armnetwork.LoadBalancer, known []string) ([]string, error) {
+	var ids []string
+	if lb.Properties == nil {
+		return ids, nil
+	}
+	for _, pool := range lb.Properties.BackendAddressPools {
+		if pool.Properties == nil {
+			continue
+		}
+		for _, ipc := range pool.Properties.BackendIPConfigurations {
+			// .../networkInterfaces/<nic>/ipConfigurations/<name>
+			parts := strings.Split(*ipc.ID, "/")
+			if len(parts) < 3 {
+				continue
+			}
+			nic, err := r.azureNIC.Get(ctx, "abstract-rg", parts[len(parts)-3], nil)
+			if err != nil {
+				return nil, err
+			}
+			if nic.Properties == nil || nic.Properties.VirtualMachine == nil {
+				continue
+			}
+			id := *nic.Properties.VirtualMachine.ID
+			if i := slices.IndexFunc(known, func(k string) bool { return strings.EqualFold(k, id) }); i >= 0 {
+				id = known[i]
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// gcpZone returns the zone of a GCP load balancer's instance group.
+func (r *LoadBalancerResource) gcpZone(m *loadBalancerResourceModel) string {
+	zone := m.Region.ValueString()
+	if zone == "" {
+		zone = r.gcpRegion
+	}
+	if zone == "" {
+		zone = "us-central1-a"
+	}
+	return zone
+}
+
+// gcpScheme returns the load balancing scheme of a GCP load balancer.
+func gcpScheme(m *loadBalancerResourceModel) string {
+	if m.Internal.ValueBool() {
+		return "INTERNAL"
+	}
+	return "EXTERNAL"
+}
+
+// gcpSubnetwork returns the default network's subnetwork in region, where internal load balancers get their address.
+func (r *LoadBalancerResource) gcpSubnetwork(region string) string {
+	return fmt.Sprintf("projects/%s/regions/%s/subnetworks/default", r.gcpProj, region)
+}
+
+// gcpForwardingRule names the forwarding rule of a listener.
+func gcpForwardingRule(lb string, l lbListenerModel) string {
+	return lb + "-" + l.key()
+}
+
+// gcpInstanceRefs returns the instance group references for instance names in zone.
+func (r *LoadBalancerResource) gcpInstanceRefs(zone string, names []string) []*compute.InstanceReference {
+	var refs []*compute.InstanceReference
+	for _, n := range names {
+		refs = append(refs, &compute.InstanceReference{Instance: fmt.Sprintf("projects/%s/zones/%s/instances/%s", r.gcpProj, zone, n)})
+	}
+	return refs
+}
+
+// gcpSetTargets adds the instances in add to the load balancer's instance group and removes those in remove.
+func (r *LoadBalancerResource) gcpSetTargets(ctx context.Context, m *loadBalancerResourceModel, add, remove []string) error {
+	zone := r.gcpZone(m)
+	group := m.Name.ValueString() + "-ig"
+	if len(remove) > 0 {
+		op, err := r.gcp.InstanceGroups.RemoveInstances(r.gcpProj, zone, group, &compute.InstanceGroupsRemoveInstancesRequest{Instances: r.gcpInstanceRefs(zone, remove)}).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(add) > 0 {
+		op, err := r.gcp.InstanceGroups.AddInstances(r.gcpProj, zone, group, &compute.InstanceGroupsAddInstancesRequest{Instances: r.gcpInstanceRefs(zone, add)}).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gcpHealthCheck returns the TCP health check for the first listener.
+func gcpHealthCheck(name string, listeners []lbListenerModel) *compute.HealthCheck {
+	port := int64(80)
+	if len(listeners) > 0 {
+		port = listeners[0].Port.ValueInt64()
+	}
+	return &compute.HealthCheck{Name: name + "-hc", Type: "TCP", TcpHealthCheck: &compute.TCPHealthCheck{Port: port}}
+}
+
+// gcpSyncListeners deletes the forwarding rules of listeners in have that are
+// not in want and creates those in want that are new.
+func (r *LoadBalancerResource) gcpSyncListeners(ctx context.Context, m *loadBalancerResourceModel, have, want []lbListenerModel) error {
+	region := gcpZoneRegion(r.gcpZone(m))
+	name := m.Name.ValueString()
+	keys := func(ls []lbListenerModel) []string {
+		var out []string
+		for _, l := range ls {
+			out = append(out, l.key())
+		}
+		return out
 	}
+	add, remove := diffStrings(keys(have), keys(want))
+	for _, l := range have {
+		if !slices.Contains(remove, l.key()) {
+			continue
+		}
+		op, err := r.gcp.ForwardingRules.Delete(r.gcpProj, region, gcpForwardingRule(name, l)).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, l := range want {
+		if !slices.Contains(add, l.key()) {
+			continue
+		}
+		rule := &compute.ForwardingRule{
+			Name:                gcpForwardingRule(name, l),
+			IPAddress:           m.IPAddress.ValueString(),
+			IPProtocol:          strings.ToUpper(l.transport()),
+			LoadBalancingScheme: gcpScheme(m),
+			BackendService:      fmt.Sprintf("projects/%s/regions/%s/backendServices/%s-bs", r.gcpProj, region, name),
+		}
+		if m.Internal.ValueBool() {
+			// internal passthrough rules take a port list rather than a range
+			rule.Ports = []string{fmt.Sprint(l.Port.ValueInt64())}
+			rule.Subnetwork = r.gcpSubnetwork(region)
+		} else {
+			rule.PortRange = fmt.Sprint(l.Port.ValueInt64())
+		}
+		op, err := r.gcp.ForwardingRules.Insert(r.gcpProj, region, rule).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *LoadBalancerResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.elb = c.ELB
+		r.ec2 = c.EC2
+	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *LoadBalancerResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.elb != nil && r.ec2 != nil
+	case "azure":
+		return r.azureRG != nil && r.azureLB != nil && r.azurePIP != nil && r.azureNIC != nil && r.azureVM != nil
+	case "gcp":
+		return r.gcp != nil
+	}
+	return false
+}
+
+func (r *LoadBalancerResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan loadBalancerResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	name := plan.Name.ValueString()
+	plan.CloudID = types.StringNull()
 
 	switch plan.Type.ValueString() {
 	case "aws":
-		if r.elb == nil || r.ec2 == nil {
-			resp.Diagnostics.AddError("aws", "missing client")
+		vpcs, err := r.ec2.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{Filters: []ec2types.Filter{{Name: aws.String("isDefault"), Values: []string{"true"}}}})
+		if err != nil || len(vpcs.Vpcs) == 0 {
+			resp.Diagnostics.AddError("aws default vpc", "unable to find default vpc")
 			return
 		}
-		subOut, err := r.ec2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{})
+		vpcID := aws.ToString(vpcs.Vpcs[0].VpcId)
+		subIn := &ec2.DescribeSubnetsInput{Filters: []ec2types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}}
+		scheme := elbtypes.LoadBalancerSchemeEnumInternetFacing
+		if plan.Internal.ValueBool() {
+			// private subnets are those that give instances no public IP
+			subIn.Filters = append(subIn.Filters, ec2types.Filter{Name: aws.String("map-public-ip-on-launch"), Values: []string{"false"}})
+			scheme = elbtypes.LoadBalancerSchemeEnumInternal
+		}
+		subOut, err := r.ec2.DescribeSubnets(ctx, subIn)
 		if err != nil || len(subOut.Subnets) == 0 {
-			resp.Diagnostics.AddError("aws subnets", "unable to find subnets")
+			resp.Diagnostics.AddError("aws subnets", fmt.Sprintf("unable to find subnets in vpc %s", vpcID))
 			return
 		}
-		var subnets []string
-		for i, s := range subOut.Subnets {
-			if i >= 2 {
-				break
-			}
-			subnets = append(subnets, aws.ToString(s.SubnetId))
-		}
+		subnets := awsZoneSubnets(subOut.Subnets)
 		lbOut, err := r.elb.CreateLoadBalancer(ctx, &elbv2.CreateLoadBalancerInput{
-			Name:          aws.String(plan.Name.ValueString()),
-			Subnets:       subnets,
-			Type:          elbtypes.LoadBalancerTypeEnumNetwork,
-			Scheme:        elbtypes.LoadBalancerSchemeEnumInternetFacing,
-			IpAddressType: elbtypes.IpAddressTypeIpv4,
+			Name:           aws.String(name),
+			Subnets:        subnets,
+			Type:           elbtypes.LoadBalancerTypeEnum(plan.LBType.ValueString()),
+			SecurityGroups: plan.SecurityGroupIDs,
+			Scheme:         scheme,
+			IpAddressType:  elbtypes.IpAddressTypeIpv4,
 		})
 		if err != nil || len(lbOut.LoadBalancers) == 0 {
 			if err == nil {
@@ -112,149 +743,448 @@ func (r *LoadBalancerResource) Create(ctx context.Context, req resource.CreateRe
 			return
 		}
 		lb := lbOut.LoadBalancers[0]
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":         aws.ToString(lb.LoadBalancerArn),
-			"name":       plan.Name.ValueString(),
-			"type":       plan.Type.ValueString(),
-			"ip_address": aws.ToString(lb.DNSName),
-		})
-	case "azure":
-		if r.azureLB == nil || r.azureRG == nil || r.azurePIP == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
-			return
+		plan.ID = types.StringValue(aws.ToString(lb.LoadBalancerArn))
+		plan.IPAddress = types.StringValue(aws.ToString(lb.DNSName))
+		if err := r.awsSync(ctx, &plan, nil); err != nil {
+			resp.Diagnostics.AddError("aws listeners", err.Error())
+			// keep the load balancer in state so it is cleaned up on destroy
+			plan.Listeners, plan.TargetIDs = nil, nil
 		}
+	case "azure":
 		rgName := "abstract-rg"
-		if r.azureLoc == "" && plan.Region.ValueString() != "" {
-			r.azureLoc = plan.Region.ValueString()
-		}
-		_, err := r.azureRG.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{Location: &r.azureLoc}, nil)
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		err := shared.EnsureResourceGroup(ctx, r.azureRG, rgName, loc)
 		if err != nil {
 			resp.Diagnostics.AddError("azure rg", err.Error())
 			return
 		}
-		pipName := plan.Name.ValueString() + "-pip"
-		pipPoller, err := r.azurePIP.BeginCreateOrUpdate(ctx, rgName, pipName, armnetwork.PublicIPAddress{
-			Location: &r.azureLoc,
-			Properties: &armnetwork.PublicIPAddressPropertiesFormat{
-				PublicIPAllocationMethod: to.Ptr(armnetwork.IPAllocationMethodStatic),
-			},
-		}, nil)
-		var pipID string
-		if err == nil {
-			pipResp, perr := pipPoller.PollUntilDone(ctx, nil)
-			err = perr
-			if perr == nil && pipResp.ID != nil {
-				pipID = *pipResp.ID
+		pipName := name + "-pip"
+		internal := plan.Internal.ValueBool()
+		if !internal {
+			pipPoller, err := r.azurePIP.BeginCreateOrUpdate(ctx, rgName, pipName, armnetwork.PublicIPAddress{
+				Location: &loc,
+				Properties: &armnetwork.PublicIPAddressPropertiesFormat{
+					PublicIPAllocationMethod: to.Ptr(armnetwork.IPAllocationMethodStatic),
+				},
+			}, nil)
+			if err == nil {
+				_, err = shared.PollAzure(ctx, pipPoller)
+			}
+			if err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure pip", err)
+				return
 			}
 		}
+		lbPoller, err := r.azureLB.BeginCreateOrUpdate(ctx, rgName, name, r.azureLoadBalancer(loc, name, internal, plan.Listeners), nil)
+		var lb armnetwork.LoadBalancersClientCreateOrUpdateResponse
+		if err == nil {
+			lb, err = shared.PollAzure(ctx, lbPoller)
+		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure pip", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure create lb", err)
 			return
 		}
-		lbPoller, err := r.azureLB.BeginCreateOrUpdate(ctx, rgName, plan.Name.ValueString(), armnetwork.LoadBalancer{
-			Location: &r.azureLoc,
-			Properties: &armnetwork.LoadBalancerPropertiesFormat{
-				FrontendIPConfigurations: []*armnetwork.FrontendIPConfiguration{{
-					Name: to.Ptr("lbfe"),
-					Properties: &armnetwork.FrontendIPConfigurationPropertiesFormat{
-						PublicIPAddress: &armnetwork.PublicIPAddress{ID: &pipID},
-					},
-				}},
-			},
-		}, nil)
+		ip := r.azureFrontendIP(ctx, lb.LoadBalancer, name, internal)
+		if ip == nil {
+			resp.Diagnostics.AddError("azure ip", "unable to get IP")
+			return
+		}
+		plan.ID = types.StringValue(name)
+		plan.IPAddress = types.StringValue(*ip)
+		pool := *r.azureLBRef(name, "backendAddressPools", "lbbe")
+		for i, vm := range plan.TargetIDs {
+			if err := r.azureSetPool(ctx, vm, pool, true); err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure add target", err)
+				plan.TargetIDs = plan.TargetIDs[:i]
+				break
+			}
+		}
+	case "gcp":
+		zone := r.gcpZone(&plan)
+		region := gcpZoneRegion(zone)
+		address := &compute.Address{Name: name + "-ip"}
+		if plan.Internal.ValueBool() {
+			// every listener's forwarding rule shares the one internal address
+			address.AddressType = "INTERNAL"
+			address.Purpose = "SHARED_LOADBALANCER_VIP"
+			address.Subnetwork = r.gcpSubnetwork(region)
+		}
+		op, err := r.gcp.Addresses.Insert(r.gcpProj, region, address).Context(ctx).Do()
 		if err == nil {
-			_, err = lbPoller.PollUntilDone(ctx, nil)
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure create lb", err.Error())
+			resp.Diagnostics.AddError("gcp address", err.Error())
 			return
 		}
-		pip, err := r.azurePIP.Get(ctx, rgName, pipName, nil)
-		if err != nil || pip.Properties == nil || pip.Properties.IPAddress == nil {
-			resp.Diagnostics.AddError("azure pip", "unable to get IP")
+		addr, err := r.gcp.Addresses.Get(r.gcpProj, region, name+"-ip").Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp address", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":         plan.Name.ValueString(),
-			"name":       plan.Name.ValueString(),
-			"type":       plan.Type.ValueString(),
-			"region":     r.azureLoc,
-			"ip_address": *pip.Properties.IPAddress,
-		})
-	case "gcp":
-		resp.Diagnostics.AddError("gcp", "load balancer resource not implemented")
+		plan.ID = types.StringValue(name)
+		plan.IPAddress = types.StringValue(addr.Address)
+		// from here on, keep what exists in state so it is cleaned up on destroy
+		listeners, targets := plan.Listeners, plan.TargetIDs
+		plan.Listeners, plan.TargetIDs = nil, nil
+		steps := []struct {
+			what string
+			do   func() (*compute.Operation, error)
+		}{
+			{"health check", func() (*compute.Operation, error) {
+				return r.gcp.RegionHealthChecks.Insert(r.gcpProj, region, gcpHealthCheck(name, listeners)).Context(ctx).Do()
+			}},
+			{"instance group", func() (*compute.Operation, error) {
+				return r.gcp.InstanceGroups.Insert(r.gcpProj, zone, &compute.InstanceGroup{Name: name + "-ig"}).Context(ctx).Do()
+			}},
+			{"backend service", func() (*compute.Operation, error) {
+				protocol := "TCP"
+				if len(listeners) > 0 {
+					protocol = strings.ToUpper(listeners[0].transport())
+				}
+				return r.gcp.RegionBackendServices.Insert(r.gcpProj, region, &compute.BackendService{
+					Name:                name + "-bs",
+					LoadBalancingScheme: gcpScheme(&plan),
+					Protocol:            protocol,
+					HealthChecks:        []string{fmt.Sprintf("projects/%s/regions/%s/healthChecks/%s-hc", r.gcpProj, region, name)},
+					Backends: []*compute.Backend{{
+						Group:         fmt.Sprintf("projects/%s/zones/%s/instanceGroups/%s-ig", r.gcpProj, zone, name),
+						BalancingMode: "CONNECTION",
+					}},
+				}).Context(ctx).Do()
+			}},
+		}
+		for _, step := range steps {
+			op, err := step.do()
+			if err == nil {
+				err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp "+step.what, err.Error())
+				resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+				return
+			}
+		}
+		if err := r.gcpSetTargets(ctx, &plan, targets, nil); err != nil {
+			resp.Diagnostics.AddError("gcp add targets", err.Error())
+			break
+		}
+		plan.TargetIDs = targets
+		if err := r.gcpSyncListeners(ctx, &plan, nil, listeners); err != nil {
+			resp.Diagnostics.AddError("gcp forwarding rules", err.Error())
+			break
+		}
+		plan.Listeners = listeners
 	default:
-		resp.Diagnostics.AddError("unsupported cloud", "only aws and azure implemented")
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
 	}
+	setCloudID(&resp.Diagnostics, &plan.CloudID, r.cloudID(&plan), nil)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
-func (r *LoadBalancerResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID   types.String `tfsdk:"id"`
-		Type types.String `tfsdk:"type"`
-		Name types.String `tfsdk:"name"`
+// cloudID is the load balancer's ARN or resource ID, or the self link of the
+// backend service its forwarding rules point at.
+func (r *LoadBalancerResource) cloudID(m *loadBalancerResourceModel) string {
+	switch m.Type.ValueString() {
+	case "aws":
+		return m.ID.ValueString()
+	case "azure":
+		return r.ids.AzureID("abstract-rg", "Microsoft.Network/loadBalancers", m.Name.ValueString())
+	case "gcp":
+		return r.ids.GCPSelfLink(fmt.Sprintf("regions/%s/backendServices/%s-bs", gcpZoneRegion(r.gcpZone(m)), m.Name.ValueString()))
+	}
+	return ""
+}
+
+// azureFrontendIP returns the address of load balancer name: the private
+// frontend address of an internal one, else that of its public IP.
+func (r *LoadBalancerResource) azureFrontendIP(ctx context.Context, lb armnetwork.LoadBalancer, name string, internal bool) *string {
+	if internal {
+		if lb.Properties != nil {
+			if fe := lb.Properties.FrontendIPConfigurations; len(fe) > 0 && fe[0].Properties != nil {
+				return fe[0].Properties.PrivateIPAddress
+			}
+		}
+		return nil
 	}
+	if pip, err := r.azurePIP.Get(ctx, "abstract-rg", name+"-pip", nil); err == nil && pip.Properties != nil {
+		return pip.Properties.IPAddress
+	}
+	return nil
+}
+
+func (r *LoadBalancerResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state loadBalancerResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	var targets []string
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.elb == nil {
+		out, err := r.elb.DescribeLoadBalancers(ctx, &elbv2.DescribeLoadBalancersInput{LoadBalancerArns: []string{state.ID.ValueString()}})
+		if err != nil || len(out.LoadBalancers) == 0 {
+			resp.State.RemoveResource(ctx)
 			return
 		}
-		_, err := r.elb.DescribeLoadBalancers(ctx, &elbv2.DescribeLoadBalancersInput{LoadBalancerArns: []string{state.ID.ValueString()}})
+		state.IPAddress = types.StringValue(aws.ToString(out.LoadBalancers[0].DNSName))
+		listeners, err := r.awsListeners(ctx, state.ID.ValueString())
 		if err != nil {
-			resp.State.RemoveResource(ctx)
+			resp.Diagnostics.AddError("aws read listeners", err.Error())
+			return
+		}
+		// every target group holds the same targets, so the first listener's is enough
+		for _, l := range state.Listeners {
+			tg, ok := listeners[l.key()]
+			if !ok {
+				continue
+			}
+			out, err := r.elb.DescribeTargetHealth(ctx, &elbv2.DescribeTargetHealthInput{TargetGroupArn: aws.String(tg.targetGroup)})
+			if err != nil {
+				resp.Diagnostics.AddError("aws read targets", err.Error())
+				return
+			}
+			for _, th := range out.TargetHealthDescriptions {
+				targets = append(targets, aws.ToString(th.Target.Id))
+			}
+			break
 		}
 	case "azure":
-		if r.azureLB == nil {
+		lb, err := r.azureLB.Get(ctx, "abstract-rg", state.Name.ValueString(), nil)
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		if ip := r.azureFrontendIP(ctx, lb.LoadBalancer, state.Name.ValueString(), state.Internal.ValueBool()); ip != nil {
+			state.IPAddress = types.StringValue(*ip)
+		}
+		targets, err = r.azureTargetIDs(ctx, lb.LoadBalancer, state.TargetIDs)
+		if err != nil {
+			resp.Diagnostics.AddError("azure read targets", err.Error())
 			return
 		}
-		_, err := r.azureLB.Get(ctx, "abstract-rg", state.Name.ValueString(), nil)
+	case "gcp":
+		zone := r.gcpZone(&state)
+		_, err := r.gcp.RegionBackendServices.Get(r.gcpProj, gcpZoneRegion(zone), state.Name.ValueString()+"-bs").Context(ctx).Do()
 		if err != nil {
 			resp.State.RemoveResource(ctx)
+			return
+		}
+		if addr, err := r.gcp.Addresses.Get(r.gcpProj, gcpZoneRegion(zone), state.Name.ValueString()+"-ip").Context(ctx).Do(); err == nil {
+			state.IPAddress = types.StringValue(addr.Address)
 		}
+		err = r.gcp.InstanceGroups.ListInstances(r.gcpProj, zone, state.Name.ValueString()+"-ig", &compute.InstanceGroupsListInstancesRequest{}).Pages(ctx,
+			func(page *compute.InstanceGroupsListInstances) error {
+				for _, inst := range page.Items {
+					targets = append(targets, inst.Instance[strings.LastIndex(inst.Instance, "/")+1:])
+				}
+				return nil
+			})
+		if err != nil {
+			resp.Diagnostics.AddError("gcp read targets", err.Error())
+			return
+		}
+	default:
+		return
 	}
+	switch {
+	case len(targets) > 0:
+		state.TargetIDs = targets
+	case state.TargetIDs != nil:
+		// keep an empty target_ids from turning into null
+		state.TargetIDs = []string{}
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(&state), nil })
 }
 
+// Update changes the listeners and registered targets in place; any other change replaces the load balancer.
 func (r *LoadBalancerResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	// no updatable fields
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state loadBalancerResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	plan.IPAddress = state.IPAddress
+	name := plan.Name.ValueString()
+	add, remove := diffStrings(state.TargetIDs, plan.TargetIDs)
+	switch plan.Type.ValueString() {
+	case "aws":
+		if added, removed := diffStrings(state.SecurityGroupIDs, plan.SecurityGroupIDs); len(added) > 0 || len(removed) > 0 {
+			_, err := r.elb.SetSecurityGroups(ctx, &elbv2.SetSecurityGroupsInput{LoadBalancerArn: aws.String(plan.ID.ValueString()), SecurityGroups: plan.SecurityGroupIDs})
+			if err != nil {
+				resp.Diagnostics.AddError("aws security groups", err.Error())
+				return
+			}
+		}
+		if err := r.awsSync(ctx, &plan, state.TargetIDs); err != nil {
+			resp.Diagnostics.AddError("aws update", err.Error())
+			return
+		}
+	case "azure":
+		pool := *r.azureLBRef(name, "backendAddressPools", "lbbe")
+		for _, vm := range remove {
+			if err := r.azureSetPool(ctx, vm, pool, false); err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure remove target", err)
+				return
+			}
+		}
+		lb, err := r.azureLB.Get(ctx, "abstract-rg", name, nil)
+		if err != nil {
+			resp.Diagnostics.AddError("azure get lb", err.Error())
+			return
+		}
+		poller, err := r.azureLB.BeginCreateOrUpdate(ctx, "abstract-rg", name, r.azureLoadBalancer(*lb.Location, name, plan.Internal.ValueBool(), plan.Listeners), nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure update lb", err)
+			return
+		}
+		for _, vm := range add {
+			if err := r.azureSetPool(ctx, vm, pool, true); err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure add target", err)
+				return
+			}
+		}
+	case "gcp":
+		if err := r.gcpSetTargets(ctx, &plan, add, remove); err != nil {
+			resp.Diagnostics.AddError("gcp update targets", err.Error())
+			return
+		}
+		if err := r.gcpSyncListeners(ctx, &plan, state.Listeners, plan.Listeners); err != nil {
+			resp.Diagnostics.AddError("gcp update forwarding rules", err.Error())
+			return
+		}
+		if hc := gcpHealthCheck(name, plan.Listeners); hc.TcpHealthCheck.Port != gcpHealthCheck(name, state.Listeners).TcpHealthCheck.Port {
+			op, err := r.gcp.RegionHealthChecks.Patch(r.gcpProj, gcpZoneRegion(r.gcpZone(&plan)), hc.Name, hc).Context(ctx).Do()
+			if err == nil {
+				err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp update health check", err.Error())
+				return
+			}
+		}
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
 func (r *LoadBalancerResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		ID   types.String `tfsdk:"id"`
-		Type types.String `tfsdk:"type"`
-		Name types.String `tfsdk:"name"`
-	}
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state loadBalancerResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	name := state.Name.ValueString()
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.elb == nil {
+		// target groups outlive the load balancer, so remove them with their listeners
+		listeners, err := r.awsListeners(ctx, state.ID.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("aws delete", err.Error())
 			return
 		}
-		_, err := r.elb.DeleteLoadBalancer(ctx, &elbv2.DeleteLoadBalancerInput{LoadBalancerArn: aws.String(state.ID.ValueString())})
+		for _, l := range listeners {
+			if _, err := r.elb.DeleteListener(ctx, &elbv2.DeleteListenerInput{ListenerArn: aws.String(l.arn)}); err != nil {
+				resp.Diagnostics.AddError("aws delete listener", err.Error())
+				return
+			}
+			if _, err := r.elb.DeleteTargetGroup(ctx, &elbv2.DeleteTargetGroupInput{TargetGroupArn: aws.String(l.targetGroup)}); err != nil {
+				resp.Diagnostics.AddError("aws delete target group", err.Error())
+				return
+			}
+		}
+		_, err = r.elb.DeleteLoadBalancer(ctx, &elbv2.DeleteLoadBalancerInput{LoadBalancerArn: aws.String(state.ID.ValueString())})
 		if err != nil {
 			resp.Diagnostics.AddError("aws delete", err.Error())
 		}
 	case "azure":
-		if r.azureLB == nil || r.azurePIP == nil {
-			return
+		// a load balancer cannot be deleted while NICs use its backend pool
+		pool := *r.azureLBRef(name, "backendAddressPools", "lbbe")
+		for _, vm := range state.TargetIDs {
+			if err := r.azureSetPool(ctx, vm, pool, false); err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure remove target", err)
+				return
+			}
+		}
+		lbPoller, err := r.azureLB.BeginDelete(ctx, "abstract-rg", name, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, lbPoller)
 		}
-		_, err := r.azureLB.BeginDelete(ctx, "abstract-rg", state.Name.ValueString(), nil)
 		if err != nil {
-			resp.Diagnostics.AddError("azure delete lb", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure delete lb", err)
+			return
+		}
+		if state.Internal.ValueBool() {
+			return
+		}
+		pipPoller, err := r.azurePIP.BeginDelete(ctx, "abstract-rg", name+"-pip", nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, pipPoller)
 		}
-		pipName := state.Name.ValueString() + "-pip"
-		_, err = r.azurePIP.BeginDelete(ctx, "abstract-rg", pipName, nil)
 		if err != nil {
-			resp.Diagnostics.AddError("azure delete pip", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure delete pip", err)
+		}
+	case "gcp":
+		zone := r.gcpZone(&state)
+		region := gcpZoneRegion(zone)
+		if err := r.gcpSyncListeners(ctx, &state, state.Listeners, nil); err != nil {
+			resp.Diagnostics.AddError("gcp delete forwarding rules", err.Error())
+			return
+		}
+		// each step depends on the ones before it having been removed
+		steps := []struct {
+			what string
+			do   func() (*compute.Operation, error)
+		}{
+			{"backend service", func() (*compute.Operation, error) {
+				return r.gcp.RegionBackendServices.Delete(r.gcpProj, region, name+"-bs").Context(ctx).Do()
+			}},
+			{"health check", func() (*compute.Operation, error) {
+				return r.gcp.RegionHealthChecks.Delete(r.gcpProj, region, name+"-hc").Context(ctx).Do()
+			}},
+			{"instance group", func() (*compute.Operation, error) {
+				return r.gcp.InstanceGroups.Delete(r.gcpProj, zone, name+"-ig").Context(ctx).Do()
+			}},
+			{"address", func() (*compute.Operation, error) {
+				return r.gcp.Addresses.Delete(r.gcpProj, region, name+"-ip").Context(ctx).Do()
+			}},
+		}
+		for _, step := range steps {
+			op, err := step.do()
+			if err == nil {
+				err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+			}
+			if err != nil && !strings.Contains(err.Error(), "notFound") {
+				resp.Diagnostics.AddError("gcp delete "+step.what, err.Error())
+				return
+			}
 		}
 	}
 }
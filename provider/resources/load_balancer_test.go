@@ -0,0 +1,168 @@
+package resources
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+var lbListenerType = tftypes.Object{AttributeTypes: map[string]tftypes.Type{"port": tftypes.Number, "protocol": tftypes.String}}
+
+func listenerList(ls ...lbListenerModel) tftypes.Value {
+	var vals []tftypes.Value
+	for _, l := range ls {
+		proto := tftypes.NewValue(tftypes.String, nil)
+		if !l.Protocol.IsNull() {
+			proto = str(l.Protocol.ValueString())
+		}
+		vals = append(vals, tftypes.NewValue(lbListenerType, map[string]tftypes.Value{"port": number(l.Port.ValueInt64()), "protocol": proto}))
+	}
+	return tftypes.NewValue(tftypes.List{ElementType: lbListenerType}, vals)
+}
+
+func listener(port int64, protocol string) lbListenerModel {
+	l := lbListenerModel{Port: types.Int64Value(port), Protocol: types.StringNull()}
+	if protocol != "" {
+		l.Protocol = types.StringValue(protocol)
+	}
+	return l
+}
+
+func TestLoadBalancerConfig(t *testing.T) {
+	r := &LoadBalancerResource{}
+	s := testSchema(t, r)
+	lb := func(cloud string, listeners tftypes.Value) map[string]tftypes.Value {
+		return map[string]tftypes.Value{"name": str("web"), "type": str(cloud), "listeners": listeners}
+	}
+	targets := tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, []tftypes.Value{str("i-0abc")})
+	alb := func(cloud string, extra map[string]tftypes.Value) map[string]tftypes.Value {
+		vals := lb(cloud, listenerList(listener(80, "http")))
+		vals["lb_type"] = str("application")
+		maps.Copy(vals, extra)
+		return vals
+	}
+	groups := tftypes.NewValue(tftypes.Set{ElementType: tftypes.String}, []tftypes.Value{str("sg-0abc")})
+	cases := []struct {
+		name  string
+		vals  map[string]tftypes.Value
+		errs  bool
+		warns bool
+	}{
+		{"tcp and udp", lb("aws", listenerList(listener(80, ""), listener(53, "udp"))), false, false},
+		{"port zero", lb("aws", listenerList(listener(0, ""))), true, false},
+		{"port too high", lb("azure", listenerList(listener(70000, "tcp"))), true, false},
+		{"http", lb("aws", listenerList(listener(80, "http"))), true, false},
+		{"duplicate", lb("aws", listenerList(listener(80, ""), listener(80, "TCP"))), true, false},
+		{"same port other protocol", lb("azure", listenerList(listener(53, "tcp"), listener(53, "udp"))), false, false},
+		{"gcp mixed protocols", lb("gcp", listenerList(listener(80, ""), listener(53, "udp"))), true, false},
+		{"targets", map[string]tftypes.Value{"name": str("web"), "type": str("aws"), "listeners": listenerList(listener(80, "")), "target_ids": targets}, false, false},
+		{"targets without listeners", map[string]tftypes.Value{"name": str("web"), "type": str("aws"), "target_ids": targets}, true, false},
+		{"application", alb("aws", map[string]tftypes.Value{"security_group_ids": groups}), false, false},
+		{"application without security groups", alb("aws", nil), true, false},
+		{"application tcp listener", alb("aws", map[string]tftypes.Value{"security_group_ids": groups, "listeners": listenerList(listener(80, ""))}), true, false},
+		{"invalid lb_type", map[string]tftypes.Value{"name": str("web"), "type": str("aws"), "lb_type": str("gateway")}, true, false},
+		{"azure application", alb("azure", nil), false, true},
+		{"gcp security groups", map[string]tftypes.Value{"name": str("web"), "type": str("gcp"), "security_group_ids": groups}, false, true},
+		{"unknown listeners", lb("gcp", tftypes.NewValue(tftypes.List{ElementType: lbListenerType}, tftypes.UnknownValue)), false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs || (resp.Diagnostics.WarningsCount() > 0) != tc.warns {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestAWSTargetGroupName(t *testing.T) {
+	if got := awsTargetGroupName("web", listener(80, "")); got != "web-tcp80" {
+		t.Errorf("name = %q", got)
+	}
+	long := awsTargetGroupName(strings.Repeat("a", 20)+"-"+strings.Repeat("b", 20), listener(8443, "udp"))
+	if len(long) > 32 || !strings.HasSuffix(long, "-udp8443") || strings.Contains(long, "--") {
+		t.Errorf("name = %q", long)
+	}
+}
+
+func TestAWSZoneSubnets(t *testing.T) {
+	subnet := func(id, zone string) ec2types.Subnet {
+		return ec2types.Subnet{SubnetId: aws.String(id), AvailabilityZone: aws.String(zone)}
+	}
+	got := awsZoneSubnets([]ec2types.Subnet{subnet("subnet-a1", "us-east-1a"), subnet("subnet-a2", "us-east-1a"), subnet("subnet-b1", "us-east-1b")})
+	if !slices.Equal(got, []string{"subnet-a1", "subnet-b1"}) {
+		t.Errorf("subnets = %v", got)
+	}
+}
+
+func TestAzureLoadBalancer(t *testing.T) {
+	r := &LoadBalancerResource{azureSubID: "sub"}
+	lb := r.azureLoadBalancer("westeurope", "web", false, []lbListenerModel{listener(80, ""), listener(53, "udp")})
+	props := lb.Properties
+	if len(props.FrontendIPConfigurations) != 1 || *props.FrontendIPConfigurations[0].Properties.PublicIPAddress.ID != "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.Network/publicIPAddresses/web-pip" {
+		t.Fatalf("frontends = %+v", props.FrontendIPConfigurations)
+	}
+	if len(props.BackendAddressPools) != 1 || len(props.Probes) != 2 || len(props.LoadBalancingRules) != 2 {
+		t.Fatalf("pools = %d, probes = %d, rules = %d", len(props.BackendAddressPools), len(props.Probes), len(props.LoadBalancingRules))
+	}
+	for i, want := range []struct {
+		protocol armnetwork.TransportProtocol
+		port     int32
+	}{{armnetwork.TransportProtocolTCP, 80}, {armnetwork.TransportProtocolUDP, 53}} {
+		rule := props.LoadBalancingRules[i].Properties
+		if *rule.Protocol != want.protocol || *rule.FrontendPort != want.port || *rule.BackendPort != want.port {
+			t.Errorf("rule %d = %s %d->%d", i, *rule.Protocol, *rule.FrontendPort, *rule.BackendPort)
+		}
+		if probe := props.Probes[i].Properties; *probe.Protocol != armnetwork.ProbeProtocolTCP || *probe.Port != want.port {
+			t.Errorf("probe %d = %s %d", i, *probe.Protocol, *probe.Port)
+		}
+		if !strings.HasSuffix(*rule.Probe.ID, "/loadBalancers/web/probes/"+*props.Probes[i].Name) {
+			t.Errorf("rule %d probe = %s", i, *rule.Probe.ID)
+		}
+	}
+	if pool := *props.LoadBalancingRules[0].Properties.BackendAddressPool.ID; pool != "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.Network/loadBalancers/web/backendAddressPools/lbbe" {
+		t.Errorf("pool = %s", pool)
+	}
+}
+
+func TestAzureInternalFrontend(t *testing.T) {
+	r := &LoadBalancerResource{azureSubID: "sub"}
+	fe := r.azureLoadBalancer("westeurope", "web", true, []lbListenerModel{listener(80, "")}).Properties.FrontendIPConfigurations[0].Properties
+	if fe.PublicIPAddress != nil {
+		t.Errorf("internal frontend has public IP %s", *fe.PublicIPAddress.ID)
+	}
+	if fe.Subnet == nil || !strings.HasSuffix(*fe.Subnet.ID, "/virtualNetworks/abstract-vnet/subnets/default") || *fe.PrivateIPAllocationMethod != armnetwork.IPAllocationMethodDynamic {
+		t.Errorf("frontend = %+v", fe)
+	}
+}
+
+func TestAzureFrontendIP(t *testing.T) {
+	arm := newFakeARM()
+	arm.puts["/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.Network/publicIPAddresses/web-pip"] = map[string]any{"properties": map[string]any{"ipAddress": "20.1.2.3"}}
+	r := &LoadBalancerResource{}
+	var err error
+	if r.azurePIP, err = armnetwork.NewPublicIPAddressesClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	if ip := r.azureFrontendIP(context.Background(), armnetwork.LoadBalancer{}, "web", false); ip == nil || *ip != "20.1.2.3" {
+		t.Errorf("public ip = %v", ip)
+	}
+	lb := armnetwork.LoadBalancer{Properties: &armnetwork.LoadBalancerPropertiesFormat{FrontendIPConfigurations: []*armnetwork.FrontendIPConfiguration{
+		{Properties: &armnetwork.FrontendIPConfigurationPropertiesFormat{PrivateIPAddress: to.Ptr("10.0.0.7")}},
+	}}}
+	if ip := r.azureFrontendIP(context.Background(), lb, "web", true); ip == nil || *ip != "10.0.0.7" {
+		t.Errorf("private ip = %v", ip)
+	}
+}
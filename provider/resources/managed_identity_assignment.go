@@ -0,0 +1,571 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/msi/armmsi"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/smithy-go"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	compute "google.golang.org/api/compute/v1"
+	iamapi "google.golang.org/api/iam/v1"
+)
+
+// gcpCloudPlatformScope lets an attached service account use every API its
+// IAM roles allow.
+const gcpCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// instanceProfilePollInterval is how long Create waits between attempts to
+// associate a new instance profile, which IAM takes a few seconds to
+// propagate to EC2.
+var instanceProfilePollInterval = 5 * time.Second
+
+// ManagedIdentityAssignmentResource attaches an identity to an instance so
+// code on it gets credentials from the cloud instead of embedded keys: an IAM
+// role through an instance profile on AWS, a user-assigned managed identity
+// on Azure or a service account on GCP.
+type ManagedIdentityAssignmentResource struct {
+	ec2 *ec2.Client
+	iam *iam.Client
+
+	azureVM         shared.AzureVMs
+	azureIdentities *armmsi.UserAssignedIdentitiesClient
+
+	gcp       *compute.Service
+	gcpIAM    *iamapi.Service
+	gcpProj   string
+	gcpRegion string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+}
+
+type managedIdentityAssignmentResourceModel struct {
+	ID              types.String `tfsdk:"id"`
+	CloudID         types.String `tfsdk:"cloud_id"`
+	Type            types.String `tfsdk:"type"`
+	InstanceID      types.String `tfsdk:"instance_id"`
+	IdentityID      types.String `tfsdk:"identity_id"`
+	Region          types.String `tfsdk:"region"`
+	InstanceProfile types.String `tfsdk:"instance_profile"`
+}
+
+func NewManagedIdentityAssignmentResource() resource.Resource {
+	return &ManagedIdentityAssignmentResource{}
+}
+
+func (r *ManagedIdentityAssignmentResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.ec2 = cfg.AWSEC2
+	r.iam = cfg.AWSIAM
+	if cfg.AzureVMClient != nil {
+		r.azureVM = cfg.AzureVMClient
+	}
+	r.azureIdentities = cfg.AzureIdentityClient
+	r.gcp = cfg.GCPCompute
+	r.gcpIAM = cfg.GCPIAM
+	r.gcpProj = cfg.GCPProject
+	r.gcpRegion = cfg.GCPRegion
+}
+
+func (r *ManagedIdentityAssignmentResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_managed_identity_assignment"
+}
+
+func (r *ManagedIdentityAssignmentResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	computed := []planmodifier.String{stringplanmodifier.UseStateForUnknown()}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			// Instance profile association ID on AWS; "<instance_id>|<identity_id>"
+			// elsewhere.
+			"id":       schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			"cloud_id": cloudIDAttribute(),
+			"type":     schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// An abstract_instance's id.
+			"instance_id": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// An abstract_iam_role's id: role ARN, managed identity resource ID
+			// or service account email.
+			"identity_id": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// AWS region, or GCP zone of the instance.
+			"region": schema.StringAttribute{Optional: true, PlanModifiers: replace},
+			// The instance profile created for the role on AWS.
+			"instance_profile": schema.StringAttribute{Computed: true, PlanModifiers: computed},
+		},
+	}
+}
+
+// ValidateConfig checks that instance_id and identity_id are both the
+// cloud's; whether they exist is checked on create.
+func (r *ManagedIdentityAssignmentResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg managedIdentityAssignmentResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() || cfg.Type.IsUnknown() {
+		return
+	}
+	cloud := cfg.Type.ValueString()
+	if !cfg.IdentityID.IsUnknown() {
+		if err := checkIdentityID(cloud, cfg.IdentityID.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("identity_id"), "invalid identity_id", err.Error())
+		}
+	}
+	if !cfg.InstanceID.IsUnknown() {
+		id := cfg.InstanceID.ValueString()
+		var ok bool
+		switch cloud {
+		case "aws":
+			ok = strings.HasPrefix(id, "i-")
+		case "azure":
+			ok = strings.Contains(strings.ToLower(id), "/providers/microsoft.compute/virtualmachines/")
+		case "gcp":
+			ok = !strings.Contains(id, "/")
+		default:
+			ok = true
+		}
+		if !ok {
+			resp.Diagnostics.AddAttributeError(path.Root("instance_id"), "invalid instance_id", fmt.Sprintf("%q is not the id of a %s abstract_instance", id, cloud))
+		}
+	}
+}
+
+// checkIdentityID reports an identity_id that is not an identity of cloud.
+func checkIdentityID(cloud, id string) error {
+	switch cloud {
+	case "aws":
+		if !strings.HasPrefix(id, "arn:") || !strings.Contains(id, ":role/") {
+			return fmt.Errorf("%q is not an IAM role ARN", id)
+		}
+	case "azure":
+		if _, _, err := azureIdentityRef(id); err != nil {
+			return err
+		}
+	case "gcp":
+		if !strings.HasSuffix(id, ".gserviceaccount.com") || !strings.Contains(id, "@") {
+			return fmt.Errorf("%q is not a service account email", id)
+		}
+	}
+	return nil
+}
+
+// azureIdentityRef returns the resource group and name of a user-assigned
+// managed identity's resource ID.
+func azureIdentityRef(id string) (string, string, error) {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	if len(parts) != 8 || !strings.EqualFold(parts[2], "resourceGroups") || !strings.EqualFold(parts[5], "Microsoft.ManagedIdentity") || !strings.EqualFold(parts[6], "userAssignedIdentities") {
+		return "", "", fmt.Errorf("%q is not a user-assigned managed identity ID", id)
+	}
+	return parts[3], parts[7], nil
+}
+
+// awsRoleName returns the name of a role from its ARN, without its path.
+func awsRoleName(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// awsInstanceProfileName names the instance profile created for instance.
+func awsInstanceProfileName(instance string) string { return "abstract-" + instance }
+
+func awsErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+// useRegion points the AWS EC2 client at the instance's region; IAM is global.
+func (r *ManagedIdentityAssignmentResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.ec2 = c.EC2
+	}
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *ManagedIdentityAssignmentResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.ec2 != nil && r.iam != nil
+	case "azure":
+		return r.azureVM != nil && r.azureIdentities != nil
+	case "gcp":
+		return r.gcp != nil && r.gcpIAM != nil
+	}
+	return true
+}
+
+// gcpZone returns the zone of the instance, defaulting like abstract_static_ip.
+func (r *ManagedIdentityAssignmentResource) gcpZone(m *managedIdentityAssignmentResourceModel) string {
+	zone := m.Region.ValueString()
+	if zone == "" {
+		zone = r.gcpRegion
+	}
+	if zone == "" {
+		zone = "us-central1-a"
+	}
+	return zone
+}
+
+func (r *ManagedIdentityAssignmentResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan managedIdentityAssignmentResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	instance, identity := plan.InstanceID.ValueString(), plan.IdentityID.ValueString()
+	plan.ID = types.StringValue(instance + "|" + identity)
+	plan.InstanceProfile = types.StringNull()
+	switch plan.Type.ValueString() {
+	case "aws":
+		profile, err := r.awsInstanceProfile(ctx, instance, identity)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("identity_id"), "aws instance profile", err.Error())
+			return
+		}
+		assoc, err := r.awsAssociate(ctx, instance, profile)
+		if err != nil {
+			resp.Diagnostics.AddError("aws associate", err.Error())
+			r.awsDeleteInstanceProfile(ctx, instance, identity)
+			return
+		}
+		plan.ID = types.StringValue(assoc)
+		plan.InstanceProfile = types.StringValue(awsInstanceProfileName(instance))
+	case "azure":
+		rg, name, _ := azureIdentityRef(identity)
+		if _, err := r.azureIdentities.Get(ctx, rg, name, nil); err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure identity", err)
+			return
+		}
+		if err := r.azureSetIdentity(ctx, instance, identity, true); err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure assign", err)
+			return
+		}
+	case "gcp":
+		if _, err := r.gcpIAM.Projects.ServiceAccounts.Get("projects/-/serviceAccounts/" + identity).Context(ctx).Do(); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("identity_id"), "gcp service account", err.Error())
+			return
+		}
+		if err := r.gcpSetServiceAccount(ctx, r.gcpZone(&plan), instance, identity); err != nil {
+			resp.Diagnostics.AddError("gcp assign", err.Error())
+			return
+		}
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	// an attachment has no identifier of its own in any cloud
+	plan.CloudID = types.StringNull()
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// awsInstanceProfile checks that the role exists and that EC2 may assume it,
+// and creates an instance profile of it for instance. It returns the
+// profile's ARN.
+func (r *ManagedIdentityAssignmentResource) awsInstanceProfile(ctx context.Context, instance, roleARN string) (string, error) {
+	role, err := r.iam.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(awsRoleName(roleARN))})
+	if err != nil {
+		return "", err
+	}
+	trust, _ := url.QueryUnescape(aws.ToString(role.Role.AssumeRolePolicyDocument))
+	if !strings.Contains(trust, "ec2.amazonaws.com") {
+		return "", fmt.Errorf("role %s does not trust ec2.amazonaws.com; set trust = \"ec2.amazonaws.com\" on its abstract_iam_role", awsRoleName(roleARN))
+	}
+	name := aws.String(awsInstanceProfileName(instance))
+	out, err := r.iam.CreateInstanceProfile(ctx, &iam.CreateInstanceProfileInput{InstanceProfileName: name})
+	if err != nil {
+		return "", err
+	}
+	_, err = r.iam.AddRoleToInstanceProfile(ctx, &iam.AddRoleToInstanceProfileInput{InstanceProfileName: name, RoleName: role.Role.RoleName})
+	if err != nil {
+		r.iam.DeleteInstanceProfile(ctx, &iam.DeleteInstanceProfileInput{InstanceProfileName: name})
+		return "", err
+	}
+	return aws.ToString(out.InstanceProfile.Arn), nil
+}
+
+// awsAssociate associates the instance profile with instance, which must not
+// have one already, and returns the association ID.
+func (r *ManagedIdentityAssignmentResource) awsAssociate(ctx context.Context, instance, profileARN string) (string, error) {
+	out, err := r.ec2.DescribeIamInstanceProfileAssociations(ctx, &ec2.DescribeIamInstanceProfileAssociationsInput{
+		Filters: []ec2types.Filter{{Name: aws.String("instance-id"), Values: []string{instance}}},
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, a := range out.IamInstanceProfileAssociations {
+		if a.State == ec2types.IamInstanceProfileAssociationStateAssociated || a.State == ec2types.IamInstanceProfileAssociationStateAssociating {
+			return "", fmt.Errorf("%s already has instance profile %s", instance, aws.ToString(a.IamInstanceProfile.Arn))
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		out, err := r.ec2.AssociateIamInstanceProfile(ctx, &ec2.AssociateIamInstanceProfileInput{
+			InstanceId:         aws.String(instance),
+			IamInstanceProfile: &ec2types.IamInstanceProfileSpecification{Arn: aws.String(profileARN)},
+		})
+		if err == nil {
+			return aws.ToString(out.IamInstanceProfileAssociation.AssociationId), nil
+		}
+		// EC2 rejects a profile IAM has not propagated yet as invalid
+		if awsErrorCode(err) != "InvalidParameterValue" || attempt == 12 {
+			return "", err
+		}
+		if err := shared.Sleep(ctx, instanceProfilePollInterval); err != nil {
+			return "", err
+		}
+	}
+}
+
+// awsDeleteInstanceProfile removes the role from instance's profile and
+// deletes the profile. Parts already gone are skipped.
+func (r *ManagedIdentityAssignmentResource) awsDeleteInstanceProfile(ctx context.Context, instance, roleARN string) error {
+	name := aws.String(awsInstanceProfileName(instance))
+	var gone *iamtypes.NoSuchEntityException
+	_, err := r.iam.RemoveRoleFromInstanceProfile(ctx, &iam.RemoveRoleFromInstanceProfileInput{InstanceProfileName: name, RoleName: aws.String(awsRoleName(roleARN))})
+	if err != nil && !errors.As(err, &gone) {
+		return err
+	}
+	_, err = r.iam.DeleteInstanceProfile(ctx, &iam.DeleteInstanceProfileInput{InstanceProfileName: name})
+	if err != nil && !errors.As(err, &gone) {
+		return err
+	}
+	return nil
+}
+
+// azureSetIdentity adds the user-assigned identity to a VM, or removes it,
+// keeping the VM's other identities.
+func (r *ManagedIdentityAssignmentResource) azureSetIdentity(ctx context.Context, vmID, identity string, attached bool) error {
+	vmName := azureVMName(vmID)
+	vm, err := r.azureVM.Get(ctx, "abstract-rg", vmName, nil)
+	if err != nil {
+		return err
+	}
+	systemAssigned := false
+	assigned := map[string]*armcompute.UserAssignedIdentitiesValue{}
+	if id := vm.Identity; id != nil {
+		systemAssigned = id.Type != nil && strings.Contains(string(*id.Type), string(armcompute.ResourceIdentityTypeSystemAssigned))
+		for k := range id.UserAssignedIdentities {
+			if !strings.EqualFold(k, identity) {
+				assigned[k] = &armcompute.UserAssignedIdentitiesValue{}
+			}
+		}
+	}
+	if attached {
+		assigned[identity] = &armcompute.UserAssignedIdentitiesValue{}
+	}
+	update := &armcompute.VirtualMachineIdentity{}
+	switch {
+	case len(assigned) > 0 && systemAssigned:
+		update.Type = to.Ptr(armcompute.ResourceIdentityTypeSystemAssignedUserAssigned)
+	case len(assigned) > 0:
+		update.Type = to.Ptr(armcompute.ResourceIdentityTypeUserAssigned)
+	case systemAssigned:
+		update.Type = to.Ptr(armcompute.ResourceIdentityTypeSystemAssigned)
+	default:
+		update.Type = to.Ptr(armcompute.ResourceIdentityTypeNone)
+	}
+	if len(assigned) > 0 {
+		if !attached {
+			// a PATCH keeps identities it does not name
+			assigned[identity] = nil
+		}
+		update.UserAssignedIdentities = assigned
+	}
+	poller, err := r.azureVM.BeginUpdate(ctx, "abstract-rg", vmName, armcompute.VirtualMachineUpdate{Identity: update}, nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, poller)
+	}
+	return err
+}
+
+// azureHasIdentity reports whether identity is among a VM's identities.
+func azureHasIdentity(vm armcompute.VirtualMachine, identity string) bool {
+	if vm.Identity == nil || vm.Identity.Type == nil || !strings.Contains(string(*vm.Identity.Type), string(armcompute.ResourceIdentityTypeUserAssigned)) {
+		return false
+	}
+	for k := range vm.Identity.UserAssignedIdentities {
+		if strings.EqualFold(k, identity) {
+			return true
+		}
+	}
+	return false
+}
+
+// gcpSetServiceAccount makes email the service account of an instance, or
+// removes its service account when email is empty. GCP only changes it on a
+// stopped instance, so a running one is stopped and started again.
+func (r *ManagedIdentityAssignmentResource) gcpSetServiceAccount(ctx context.Context, zone, instance, email string) error {
+	inst, err := r.gcp.Instances.Get(r.gcpProj, zone, instance).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	current := ""
+	if len(inst.ServiceAccounts) > 0 {
+		current = inst.ServiceAccounts[0].Email
+	}
+	if current == email {
+		return nil
+	}
+	running := inst.Status == "RUNNING"
+	if running {
+		op, err := r.gcp.Instances.Stop(r.gcpProj, zone, instance).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			return fmt.Errorf("stop: %w", err)
+		}
+	}
+	set := &compute.InstancesSetServiceAccountRequest{}
+	if email != "" {
+		set = &compute.InstancesSetServiceAccountRequest{Email: email, Scopes: []string{gcpCloudPlatformScope}}
+	}
+	op, err := r.gcp.Instances.SetServiceAccount(r.gcpProj, zone, instance, set).Context(ctx).Do()
+	if err == nil {
+		err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+	}
+	if running {
+		// start the instance again even if the change failed
+		op, startErr := r.gcp.Instances.Start(r.gcpProj, zone, instance).Context(ctx).Do()
+		if startErr == nil {
+			startErr = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if startErr != nil {
+			err = errors.Join(err, fmt.Errorf("start: %w", startErr))
+		}
+	}
+	return err
+}
+
+// Read drops an assignment undone outside Terraform, or whose instance is gone.
+func (r *ManagedIdentityAssignmentResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state managedIdentityAssignmentResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	instance, identity := state.InstanceID.ValueString(), state.IdentityID.ValueString()
+	found := false
+	switch state.Type.ValueString() {
+	case "aws":
+		out, err := r.ec2.DescribeIamInstanceProfileAssociations(ctx, &ec2.DescribeIamInstanceProfileAssociationsInput{AssociationIds: []string{state.ID.ValueString()}})
+		if err != nil && awsErrorCode(err) != "InvalidAssociationID.NotFound" {
+			resp.Diagnostics.AddError("aws read", err.Error())
+			return
+		}
+		if err == nil {
+			for _, a := range out.IamInstanceProfileAssociations {
+				found = found || a.State == ec2types.IamInstanceProfileAssociationStateAssociated || a.State == ec2types.IamInstanceProfileAssociationStateAssociating
+			}
+		}
+	case "azure":
+		vm, err := r.azureVM.Get(ctx, "abstract-rg", azureVMName(instance), nil)
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		found = azureHasIdentity(vm.VirtualMachine, identity)
+	case "gcp":
+		inst, err := r.gcp.Instances.Get(r.gcpProj, r.gcpZone(&state), instance).Context(ctx).Do()
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		found = len(inst.ServiceAccounts) > 0 && inst.ServiceAccounts[0].Email == identity
+	}
+	if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+// Update has nothing to change; every attribute replaces the assignment.
+func (r *ManagedIdentityAssignmentResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+}
+
+// Delete detaches the identity. On AWS the instance profile created for the
+// instance is deleted too, so the role itself can be deleted.
+func (r *ManagedIdentityAssignmentResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state managedIdentityAssignmentResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	instance, identity := state.InstanceID.ValueString(), state.IdentityID.ValueString()
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err := r.ec2.DisassociateIamInstanceProfile(ctx, &ec2.DisassociateIamInstanceProfileInput{AssociationId: aws.String(state.ID.ValueString())})
+		if err != nil && awsErrorCode(err) != "InvalidAssociationID.NotFound" {
+			resp.Diagnostics.AddError("aws disassociate", err.Error())
+			return
+		}
+		if err := r.awsDeleteInstanceProfile(ctx, instance, identity); err != nil {
+			resp.Diagnostics.AddError("aws instance profile", err.Error())
+		}
+	case "azure":
+		err := r.azureSetIdentity(ctx, instance, identity, false)
+		if err != nil && azureStatus(err) != http.StatusNotFound {
+			shared.AddAzureError(&resp.Diagnostics, "azure unassign", err)
+		}
+	case "gcp":
+		err := r.gcpSetServiceAccount(ctx, r.gcpZone(&state), instance, "")
+		if err != nil && gcpStatus(err) != http.StatusNotFound {
+			resp.Diagnostics.AddError("gcp unassign", err.Error())
+		}
+	}
+}
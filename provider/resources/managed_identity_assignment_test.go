@@ -0,0 +1,168 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/msi/armmsi"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	compute "google.golang.org/api/compute/v1"
+	iamapi "google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+)
+
+const (
+	testVMID       = "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.Compute/virtualMachines/web"
+	testIdentityID = "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/app"
+)
+
+func TestManagedIdentityAssignmentConfig(t *testing.T) {
+	r := &ManagedIdentityAssignmentResource{}
+	s := testSchema(t, r)
+	for _, tc := range []struct {
+		name, cloud, instance, identity string
+		errs                            bool
+	}{
+		{"aws", "aws", "i-0123456789abcdef0", "arn:aws:iam::123456789012:role/app", false},
+		{"aws identity from gcp", "aws", "i-0123456789abcdef0", "app@p.iam.gserviceaccount.com", true},
+		{"aws instance from azure", "aws", testVMID, "arn:aws:iam::123456789012:role/app", true},
+		{"azure", "azure", testVMID, testIdentityID, false},
+		{"azure identity from aws", "azure", testVMID, "arn:aws:iam::123456789012:role/app", true},
+		{"gcp", "gcp", "web", "app@p.iam.gserviceaccount.com", false},
+		{"gcp identity from azure", "gcp", "web", testIdentityID, true},
+		{"gcp instance from azure", "gcp", testVMID, "app@p.iam.gserviceaccount.com", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vals := map[string]tftypes.Value{"type": str(tc.cloud), "instance_id": str(tc.instance), "identity_id": str(tc.identity)}
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestManagedIdentityAssignmentAzure(t *testing.T) {
+	arm := newFakeARM()
+	// the VM's system-assigned identity is kept throughout
+	arm.puts[testVMID] = map[string]any{"id": testVMID, "identity": map[string]any{"type": "SystemAssigned"}}
+	vms, err := armcompute.NewVirtualMachinesClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	identities, err := armmsi.NewUserAssignedIdentitiesClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ManagedIdentityAssignmentResource{azureVM: vms, azureIdentities: identities}
+	ctx := context.Background()
+
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, map[string]tftypes.Value{"type": str("azure"), "instance_id": str(testVMID), "identity_id": str(testIdentityID)})}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	identity := arm.puts[testVMID]["identity"].(map[string]any)
+	if identity["type"] != "SystemAssigned, UserAssigned" || identity["userAssignedIdentities"].(map[string]any)[testIdentityID] == nil {
+		t.Errorf("identity after create = %v", identity)
+	}
+
+	readResp := &resource.ReadResponse{State: resp.State}
+	r.Read(ctx, resource.ReadRequest{State: resp.State}, readResp)
+	if readResp.Diagnostics.HasError() || readResp.State.Raw.IsNull() {
+		t.Fatalf("read: %v", readResp.Diagnostics)
+	}
+
+	delResp := &resource.DeleteResponse{State: resp.State}
+	r.Delete(ctx, resource.DeleteRequest{State: resp.State}, delResp)
+	if delResp.Diagnostics.HasError() {
+		t.Fatalf("delete: %v", delResp.Diagnostics)
+	}
+	identity = arm.puts[testVMID]["identity"].(map[string]any)
+	if identity["type"] != "SystemAssigned" {
+		t.Errorf("identity after delete = %v", identity)
+	}
+
+	readResp = &resource.ReadResponse{State: resp.State}
+	r.Read(ctx, resource.ReadRequest{State: resp.State}, readResp)
+	if !readResp.State.Raw.IsNull() {
+		t.Errorf("removed identity kept in state")
+	}
+}
+
+func TestManagedIdentityAssignmentGCP(t *testing.T) {
+	const email = "app@p.iam.gserviceaccount.com"
+	var calls []string
+	inst := &compute.Instance{Name: "web", Status: "RUNNING", ServiceAccounts: []*compute.ServiceAccount{{Email: "123-compute@developer.gserviceaccount.com"}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p := req.URL.Path
+		switch {
+		case strings.HasSuffix(p, "/serviceAccounts/"+email):
+			json.NewEncoder(w).Encode(iamapi.ServiceAccount{Email: email})
+		case strings.Contains(p, "/zones/europe-west1-b/operations/"):
+			json.NewEncoder(w).Encode(compute.Operation{Status: "DONE"})
+		case req.Method == http.MethodGet && strings.HasSuffix(p, "/zones/europe-west1-b/instances/web"):
+			json.NewEncoder(w).Encode(inst)
+		case req.Method == http.MethodPost && strings.Contains(p, "/zones/europe-west1-b/instances/web/"):
+			op := p[strings.LastIndex(p, "/")+1:]
+			calls = append(calls, op)
+			switch op {
+			case "stop":
+				inst.Status = "TERMINATED"
+			case "start":
+				inst.Status = "RUNNING"
+			case "setServiceAccount":
+				if inst.Status != "TERMINATED" {
+					t.Error("service account set on a running instance")
+				}
+				var in compute.InstancesSetServiceAccountRequest
+				json.NewDecoder(req.Body).Decode(&in)
+				inst.ServiceAccounts = nil
+				if in.Email != "" {
+					inst.ServiceAccounts = []*compute.ServiceAccount{{Email: in.Email, Scopes: in.Scopes}}
+				}
+			}
+			json.NewEncoder(w).Encode(compute.Operation{Name: op, Zone: "europe-west1-b", Status: "DONE"})
+		default:
+			t.Errorf("unexpected %s %s", req.Method, p)
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+	svc, err := compute.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	iamSvc, err := iamapi.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ManagedIdentityAssignmentResource{gcp: svc, gcpIAM: iamSvc, gcpProj: "p", gcpRegion: "europe-west1-b"}
+	ctx := context.Background()
+
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, map[string]tftypes.Value{"type": str("gcp"), "instance_id": str("web"), "identity_id": str(email)})}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if got := strings.Join(calls, ","); got != "stop,setServiceAccount,start" {
+		t.Errorf("calls = %s", got)
+	}
+	if sa := inst.ServiceAccounts; len(sa) != 1 || sa[0].Email != email || sa[0].Scopes[0] != gcpCloudPlatformScope {
+		t.Errorf("service accounts = %+v", sa)
+	}
+
+	delResp := &resource.DeleteResponse{State: resp.State}
+	r.Delete(ctx, resource.DeleteRequest{State: resp.State}, delResp)
+	if delResp.Diagnostics.HasError() || len(inst.ServiceAccounts) != 0 || inst.Status != "RUNNING" {
+		t.Fatalf("delete: %v, instance %+v", delResp.Diagnostics, inst)
+	}
+}
@@ -0,0 +1,558 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	monitoringv3 "google.golang.org/api/monitoring/v3"
+)
+
+// azureMetricAlertAPIVersion is the Microsoft.Insights API version used for
+// metric alerts, which are managed through the generic resources client like
+// dashboards.
+const azureMetricAlertAPIVersion = "2018-03-01"
+
+// alarmPeriod is how long the metric is averaged over before it is compared
+// with the threshold.
+const alarmPeriod = 5 * time.Minute
+
+// alarmOperator is a comparison as each cloud spells it.
+type alarmOperator struct {
+	aws   cwtypes.ComparisonOperator
+	azure string
+	gcp   string
+}
+
+// alarmComparisons maps comparison to the clouds' operators.
+var alarmComparisons = map[string]alarmOperator{
+	"greater_than":          {cwtypes.ComparisonOperatorGreaterThanThreshold, "GreaterThan", "COMPARISON_GT"},
+	"greater_than_or_equal": {cwtypes.ComparisonOperatorGreaterThanOrEqualToThreshold, "GreaterThanOrEqual", "COMPARISON_GE"},
+	"less_than":             {cwtypes.ComparisonOperatorLessThanThreshold, "LessThan", "COMPARISON_LT"},
+	"less_than_or_equal":    {cwtypes.ComparisonOperatorLessThanOrEqualToThreshold, "LessThanOrEqual", "COMPARISON_LE"},
+}
+
+// alarmComparison returns the comparison whose operator matches, or "".
+func alarmComparison(match func(alarmOperator) bool) string {
+	for name, op := range alarmComparisons {
+		if match(op) {
+			return name
+		}
+	}
+	return ""
+}
+
+// awsAlarmDimensions names the dimension resource_id fills in for each
+// CloudWatch namespace.
+var awsAlarmDimensions = map[string]string{
+	"AWS/EC2":    "InstanceId",
+	"AWS/Lambda": "FunctionName",
+	"AWS/RDS":    "DBInstanceIdentifier",
+	"AWS/SQS":    "QueueName",
+	"AWS/SNS":    "TopicName",
+}
+
+// gcpAlarmLabels names the label resource_id is matched against for each
+// Cloud Monitoring metric domain.
+var gcpAlarmLabels = map[string]string{
+	"compute.googleapis.com":        "metric.label.instance_name",
+	"cloudfunctions.googleapis.com": "resource.label.function_name",
+	"run.googleapis.com":            "resource.label.service_name",
+	"cloudsql.googleapis.com":       "resource.label.database_id",
+}
+
+// awsAlarmMetric splits a metric such as "AWS/EC2/CPUUtilization" into its
+// namespace and name, and returns the dimension resource_id fills in.
+func awsAlarmMetric(metric string) (namespace, name, dimension string, err error) {
+	i := strings.LastIndex(metric, "/")
+	if i < 0 {
+		return "", "", "", fmt.Errorf("%q is not a namespace and metric name such as AWS/EC2/CPUUtilization", metric)
+	}
+	namespace, name = metric[:i], metric[i+1:]
+	dimension, ok := awsAlarmDimensions[namespace]
+	if !ok {
+		return "", "", "", fmt.Errorf("namespace %s is not one of %s", namespace, strings.Join(slices.Sorted(maps.Keys(awsAlarmDimensions)), ", "))
+	}
+	return namespace, name, dimension, nil
+}
+
+// gcpAlarmFilter returns the Cloud Monitoring filter selecting metric for the
+// resource. Cloud SQL database IDs carry the project.
+func gcpAlarmFilter(metric, resourceID, project string) (string, error) {
+	domain, _, _ := strings.Cut(metric, "/")
+	label, ok := gcpAlarmLabels[domain]
+	if !ok {
+		return "", fmt.Errorf("metric domain %s is not one of %s", domain, strings.Join(slices.Sorted(maps.Keys(gcpAlarmLabels)), ", "))
+	}
+	if domain == "cloudsql.googleapis.com" {
+		resourceID = project + ":" + resourceID
+	}
+	return fmt.Sprintf("metric.type = %q AND %s = %q", metric, label, resourceID), nil
+}
+
+// azureMetricAlert is the body of a Microsoft.Insights/metricAlerts resource
+// with a single static threshold.
+type azureMetricAlert struct {
+	Severity            int                      `json:"severity"`
+	Enabled             bool                     `json:"enabled"`
+	Scopes              []string                 `json:"scopes"`
+	EvaluationFrequency string                   `json:"evaluationFrequency"`
+	WindowSize          string                   `json:"windowSize"`
+	Criteria            azureMetricAlertCriteria `json:"criteria"`
+	Actions             []azureMetricAlertAction `json:"actions"`
+}
+
+type azureMetricAlertCriteria struct {
+	ODataType string                      `json:"odata.type"`
+	AllOf     []azureMetricAlertCriterion `json:"allOf"`
+}
+
+type azureMetricAlertCriterion struct {
+	CriterionType   string  `json:"criterionType"`
+	Name            string  `json:"name"`
+	MetricName      string  `json:"metricName"`
+	Operator        string  `json:"operator"`
+	Threshold       float64 `json:"threshold"`
+	TimeAggregation string  `json:"timeAggregation"`
+}
+
+type azureMetricAlertAction struct {
+	ActionGroupID string `json:"actionGroupId"`
+}
+
+// MonitoringAlarmResource manages an alarm on a single metric of an existing
+// resource.
+type MonitoringAlarmResource struct {
+	cw *cloudwatch.Client
+
+	azureRG    *armresources.ResourceGroupsClient
+	azureRes   *armresources.Client
+	azureSubID string
+	azureLoc   string
+
+	monitoring *monitoringv3.Service
+	gcpProj    string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	ids *shared.CloudIDs
+}
+
+type monitoringAlarmResourceModel struct {
+	ID                 types.String  `tfsdk:"id"`
+	CloudID            types.String  `tfsdk:"cloud_id"`
+	Type               types.String  `tfsdk:"type"`
+	Name               types.String  `tfsdk:"name"`
+	ResourceID         types.String  `tfsdk:"resource_id"`
+	Metric             types.String  `tfsdk:"metric"`
+	Threshold          types.Float64 `tfsdk:"threshold"`
+	Comparison         types.String  `tfsdk:"comparison"`
+	NotificationTarget types.String  `tfsdk:"notification_target"`
+}
+
+func NewMonitoringAlarmResource() resource.Resource { return &MonitoringAlarmResource{} }
+
+func (r *MonitoringAlarmResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.ids = cfg.CloudIDs
+	r.cw = cfg.AWSCloudWatch
+	r.azureRG = cfg.AzureRGClient
+	r.azureRes = cfg.AzureResourcesClient
+	r.azureSubID = cfg.AzureSubID
+	r.azureLoc = cfg.AzureLocation
+	r.monitoring = cfg.GCPMonitoringV3
+	r.gcpProj = cfg.GCPProject
+}
+
+func (r *MonitoringAlarmResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_monitoring_alarm"
+}
+
+func (r *MonitoringAlarmResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"cloud_id": cloudIDAttribute(),
+			"type": schema.StringAttribute{
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"name": schema.StringAttribute{
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			// The instance ID or function name on AWS, the Azure resource ID,
+			// or the instance, function or service name on GCP.
+			"resource_id": schema.StringAttribute{Required: true},
+			// "AWS/EC2/CPUUtilization", "Percentage CPU" or
+			// "compute.googleapis.com/instance/cpu/utilization".
+			"metric":     schema.StringAttribute{Required: true},
+			"threshold":  schema.Float64Attribute{Required: true},
+			"comparison": schema.StringAttribute{Required: true},
+			// An SNS topic ARN, Azure action group ID or GCP notification
+			// channel name.
+			"notification_target": schema.StringAttribute{Optional: true},
+		},
+	}
+}
+
+func (r *MonitoringAlarmResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg monitoringAlarmResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if c := cfg.Comparison; !c.IsUnknown() && !c.IsNull() {
+		if _, ok := alarmComparisons[c.ValueString()]; !ok {
+			resp.Diagnostics.AddAttributeError(path.Root("comparison"), "invalid comparison",
+				fmt.Sprintf("%q is not one of %s", c.ValueString(), strings.Join(slices.Sorted(maps.Keys(alarmComparisons)), ", ")))
+		}
+	}
+	if cfg.Metric.IsUnknown() || cfg.Metric.IsNull() {
+		return
+	}
+	var err error
+	switch cfg.Type.ValueString() {
+	case "aws":
+		_, _, _, err = awsAlarmMetric(cfg.Metric.ValueString())
+	case "gcp":
+		_, err = gcpAlarmFilter(cfg.Metric.ValueString(), "", "")
+	}
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("metric"), "unsupported metric", err.Error())
+	}
+}
+
+// azureAlarmID returns the ARM resource ID of the metric alert called name.
+func (r *MonitoringAlarmResource) azureAlarmID(name string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/abstract-rg/providers/Microsoft.Insights/metricAlerts/%s", r.azureSubID, name)
+}
+
+// azureMetricAlert builds the metric alert for plan.
+func (m *monitoringAlarmResourceModel) azureMetricAlert() azureMetricAlert {
+	window := fmt.Sprintf("PT%dM", int(alarmPeriod/time.Minute))
+	alert := azureMetricAlert{
+		Severity:            3,
+		Enabled:             true,
+		Scopes:              []string{m.ResourceID.ValueString()},
+		EvaluationFrequency: "PT1M",
+		WindowSize:          window,
+		Criteria: azureMetricAlertCriteria{
+			ODataType: "Microsoft.Azure.Monitor.SingleResourceMultipleMetricCriteria",
+			AllOf: []azureMetricAlertCriterion{{
+				CriterionType:   "StaticThresholdCriterion",
+				Name:            "threshold",
+				MetricName:      m.Metric.ValueString(),
+				Operator:        alarmComparisons[m.Comparison.ValueString()].azure,
+				Threshold:       m.Threshold.ValueFloat64(),
+				TimeAggregation: "Average",
+			}},
+		},
+		Actions: []azureMetricAlertAction{},
+	}
+	if target := m.NotificationTarget.ValueString(); target != "" {
+		alert.Actions = append(alert.Actions, azureMetricAlertAction{ActionGroupID: target})
+	}
+	return alert
+}
+
+// gcpAlertPolicy builds the alerting policy for plan.
+func (r *MonitoringAlarmResource) gcpAlertPolicy(m *monitoringAlarmResourceModel) (*monitoringv3.AlertPolicy, error) {
+	filter, err := gcpAlarmFilter(m.Metric.ValueString(), m.ResourceID.ValueString(), r.gcpProj)
+	if err != nil {
+		return nil, err
+	}
+	period := fmt.Sprintf("%ds", int(alarmPeriod/time.Second))
+	policy := &monitoringv3.AlertPolicy{
+		DisplayName: m.Name.ValueString(),
+		Combiner:    "OR",
+		Conditions: []*monitoringv3.Condition{{
+			DisplayName: m.Name.ValueString(),
+			ConditionThreshold: &monitoringv3.MetricThreshold{
+				Filter:         filter,
+				Comparison:     alarmComparisons[m.Comparison.ValueString()].gcp,
+				ThresholdValue: m.Threshold.ValueFloat64(),
+				Duration:       "0s",
+				Aggregations:   []*monitoringv3.Aggregation{{AlignmentPeriod: period, PerSeriesAligner: "ALIGN_MEAN"}},
+			},
+		}},
+	}
+	if target := m.NotificationTarget.ValueString(); target != "" {
+		policy.NotificationChannels = []string{target}
+	}
+	return policy, nil
+}
+
+// put creates or overwrites the alarm and returns its identifier. Updates
+// address the alarm by plan's id.
+func (r *MonitoringAlarmResource) put(ctx context.Context, plan *monitoringAlarmResourceModel, exists bool) (string, error) {
+	name := plan.Name.ValueString()
+	switch plan.Type.ValueString() {
+	case "aws":
+		namespace, metric, dimension, err := awsAlarmMetric(plan.Metric.ValueString())
+		if err != nil {
+			return "", err
+		}
+		input := &cloudwatch.PutMetricAlarmInput{
+			AlarmName:          aws.String(name),
+			Namespace:          aws.String(namespace),
+			MetricName:         aws.String(metric),
+			Dimensions:         []cwtypes.Dimension{{Name: aws.String(dimension), Value: aws.String(plan.ResourceID.ValueString())}},
+			Statistic:          cwtypes.StatisticAverage,
+			Period:             aws.Int32(int32(alarmPeriod / time.Second)),
+			EvaluationPeriods:  aws.Int32(1),
+			Threshold:          aws.Float64(plan.Threshold.ValueFloat64()),
+			ComparisonOperator: alarmComparisons[plan.Comparison.ValueString()].aws,
+		}
+		if target := plan.NotificationTarget.ValueString(); target != "" {
+			input.AlarmActions = []string{target}
+		}
+		if _, err := r.cw.PutMetricAlarm(ctx, input); err != nil {
+			return "", err
+		}
+		return name, nil
+	case "azure":
+		if !exists {
+			loc := shared.AzureLocation("", r.azureLoc)
+			err := shared.EnsureResourceGroup(ctx, r.azureRG, "abstract-rg", loc)
+			if err != nil {
+				return "", err
+			}
+		}
+		id := r.azureAlarmID(name)
+		poller, err := r.azureRes.BeginCreateOrUpdateByID(ctx, id, azureMetricAlertAPIVersion, armresources.GenericResource{
+			// metric alerts are global resources
+			Location:   to.Ptr("global"),
+			Properties: plan.azureMetricAlert(),
+		}, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			return "", err
+		}
+		return id, nil
+	case "gcp":
+		policy, err := r.gcpAlertPolicy(plan)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			_, err = r.monitoring.Projects.AlertPolicies.Patch(plan.ID.ValueString(), policy).
+				UpdateMask("displayName,combiner,conditions,notificationChannels").Context(ctx).Do()
+			return plan.ID.ValueString(), err
+		}
+		created, err := r.monitoring.Projects.AlertPolicies.Create("projects/"+r.gcpProj, policy).Context(ctx).Do()
+		if err != nil {
+			return "", err
+		}
+		return created.Name, nil
+	}
+	return "", fmt.Errorf("unsupported cloud %s", plan.Type.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *MonitoringAlarmResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.cw != nil
+	case "azure":
+		return r.azureRG != nil && r.azureRes != nil
+	case "gcp":
+		return r.monitoring != nil
+	}
+	return false
+}
+
+func (r *MonitoringAlarmResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan monitoringAlarmResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	id, err := r.put(ctx, &plan, false)
+	if err != nil {
+		resp.Diagnostics.AddError(plan.Type.ValueString()+" create", err.Error())
+		return
+	}
+	plan.ID = types.StringValue(id)
+	cloudID, err := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, cloudID, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// cloudID is the CloudWatch alarm's ARN; the Azure and GCP IDs already are
+// the alert's resource ID and the policy's full name.
+func (r *MonitoringAlarmResource) cloudID(ctx context.Context, m *monitoringAlarmResourceModel) (string, error) {
+	if m.Type.ValueString() == "aws" {
+		return r.ids.ARN(ctx, "cloudwatch", true, "alarm:"+m.ID.ValueString())
+	}
+	return m.ID.ValueString(), nil
+}
+
+// setObserved records the threshold, comparison and notification target the
+// cloud reports, so edits made outside Terraform show up in the next plan.
+func (m *monitoringAlarmResourceModel) setObserved(threshold float64, comparison string, targets []string) {
+	m.Threshold = types.Float64Value(threshold)
+	if comparison != "" {
+		m.Comparison = types.StringValue(comparison)
+	}
+	m.NotificationTarget = types.StringNull()
+	if len(targets) > 0 {
+		m.NotificationTarget = types.StringValue(targets[0])
+	}
+}
+
+func (r *MonitoringAlarmResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state monitoringAlarmResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		out, err := r.cw.DescribeAlarms(ctx, &cloudwatch.DescribeAlarmsInput{AlarmNames: []string{state.ID.ValueString()}})
+		if err != nil || len(out.MetricAlarms) == 0 {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		alarm := out.MetricAlarms[0]
+		comparison := alarmComparison(func(op alarmOperator) bool { return op.aws == alarm.ComparisonOperator })
+		state.setObserved(aws.ToFloat64(alarm.Threshold), comparison, alarm.AlarmActions)
+	case "azure":
+		out, err := r.azureRes.GetByID(ctx, state.ID.ValueString(), azureMetricAlertAPIVersion, nil)
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		raw, err := json.Marshal(out.Properties)
+		if err != nil {
+			resp.Diagnostics.AddError("azure read", err.Error())
+			return
+		}
+		var alert azureMetricAlert
+		if err := json.Unmarshal(raw, &alert); err != nil {
+			resp.Diagnostics.AddError("azure read", err.Error())
+			return
+		}
+		if len(alert.Criteria.AllOf) > 0 {
+			c := alert.Criteria.AllOf[0]
+			var targets []string
+			for _, a := range alert.Actions {
+				targets = append(targets, a.ActionGroupID)
+			}
+			comparison := alarmComparison(func(op alarmOperator) bool { return strings.EqualFold(op.azure, c.Operator) })
+			state.setObserved(c.Threshold, comparison, targets)
+		}
+	case "gcp":
+		policy, err := r.monitoring.Projects.AlertPolicies.Get(state.ID.ValueString()).Context(ctx).Do()
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		if len(policy.Conditions) > 0 && policy.Conditions[0].ConditionThreshold != nil {
+			c := policy.Conditions[0].ConditionThreshold
+			comparison := alarmComparison(func(op alarmOperator) bool { return op.gcp == c.Comparison })
+			state.setObserved(c.ThresholdValue, comparison, policy.NotificationChannels)
+		}
+	default:
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+func (r *MonitoringAlarmResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state monitoringAlarmResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	if _, err := r.put(ctx, &plan, true); err != nil {
+		resp.Diagnostics.AddError(plan.Type.ValueString()+" update", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *MonitoringAlarmResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state monitoringAlarmResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err := r.cw.DeleteAlarms(ctx, &cloudwatch.DeleteAlarmsInput{AlarmNames: []string{state.ID.ValueString()}})
+		if err != nil {
+			resp.Diagnostics.AddError("aws delete", err.Error())
+		}
+	case "azure":
+		poller, err := r.azureRes.BeginDeleteByID(ctx, state.ID.ValueString(), azureMetricAlertAPIVersion, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete", err)
+		}
+	case "gcp":
+		_, err := r.monitoring.Projects.AlertPolicies.Delete(state.ID.ValueString()).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete", err.Error())
+		}
+	}
+}
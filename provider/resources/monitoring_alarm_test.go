@@ -0,0 +1,71 @@
+package resources
+
+import (
+	"testing"
+
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestAWSAlarmMetric(t *testing.T) {
+	namespace, name, dimension, err := awsAlarmMetric("AWS/EC2/CPUUtilization")
+	if err != nil || namespace != "AWS/EC2" || name != "CPUUtilization" || dimension != "InstanceId" {
+		t.Errorf("awsAlarmMetric = %q, %q, %q, %v", namespace, name, dimension, err)
+	}
+	for _, metric := range []string{"CPUUtilization", "Custom/App/Latency"} {
+		if _, _, _, err := awsAlarmMetric(metric); err == nil {
+			t.Errorf("awsAlarmMetric(%q) succeeded", metric)
+		}
+	}
+}
+
+func TestGCPAlarmFilter(t *testing.T) {
+	cases := []struct {
+		metric, resource, want string
+	}{
+		{"compute.googleapis.com/instance/cpu/utilization", "web", `metric.type = "compute.googleapis.com/instance/cpu/utilization" AND metric.label.instance_name = "web"`},
+		{"run.googleapis.com/request_count", "api", `metric.type = "run.googleapis.com/request_count" AND resource.label.service_name = "api"`},
+		{"cloudsql.googleapis.com/database/cpu/utilization", "db", `metric.type = "cloudsql.googleapis.com/database/cpu/utilization" AND resource.label.database_id = "proj:db"`},
+	}
+	for _, tc := range cases {
+		got, err := gcpAlarmFilter(tc.metric, tc.resource, "proj")
+		if err != nil || got != tc.want {
+			t.Errorf("gcpAlarmFilter(%q) = %q, %v; want %q", tc.metric, got, err, tc.want)
+		}
+	}
+	if _, err := gcpAlarmFilter("pubsub.googleapis.com/topic/send_request_count", "t", "proj"); err == nil {
+		t.Error("unsupported domain accepted")
+	}
+}
+
+func TestAlarmComparison(t *testing.T) {
+	got := alarmComparison(func(op alarmOperator) bool { return op.aws == cwtypes.ComparisonOperatorLessThanOrEqualToThreshold })
+	if got != "less_than_or_equal" {
+		t.Errorf("aws comparison = %q", got)
+	}
+	if got := alarmComparison(func(op alarmOperator) bool { return op.gcp == "COMPARISON_NE" }); got != "" {
+		t.Errorf("unknown comparison = %q", got)
+	}
+}
+
+func TestAzureMetricAlert(t *testing.T) {
+	m := monitoringAlarmResourceModel{
+		ResourceID:         types.StringValue("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/web"),
+		Metric:             types.StringValue("Percentage CPU"),
+		Threshold:          types.Float64Value(80),
+		Comparison:         types.StringValue("greater_than"),
+		NotificationTarget: types.StringNull(),
+	}
+	alert := m.azureMetricAlert()
+	c := alert.Criteria.AllOf[0]
+	if c.Operator != "GreaterThan" || c.Threshold != 80 || c.MetricName != "Percentage CPU" || alert.WindowSize != "PT5M" {
+		t.Errorf("criterion = %+v, window %s", c, alert.WindowSize)
+	}
+	if len(alert.Actions) != 0 {
+		t.Errorf("actions = %+v without a notification target", alert.Actions)
+	}
+	m.NotificationTarget = types.StringValue("/ag/ops")
+	if alert := m.azureMetricAlert(); len(alert.Actions) != 1 || alert.Actions[0].ActionGroupID != "/ag/ops" {
+		t.Errorf("actions = %+v", alert.Actions)
+	}
+}
@@ -3,6 +3,8 @@ package resources
 import (
 	"context"
 	"fmt"
+	"net"
+	"time"
 
 	"abstract-provider/provider/shared"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -12,11 +14,15 @@ import (
 	compute "google.golang.org/api/compute/v1"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
@@ -24,12 +30,53 @@ type NetworkResource struct {
 	ec2       *ec2.Client
 	azureV    *armnetwork.VirtualNetworksClient
 	azureS    *armnetwork.SubnetsClient
+	azurePIP  *armnetwork.PublicIPAddressesClient
+	azureNAT  *armnetwork.NatGatewaysClient
 	azureRG   *armresources.ResourceGroupsClient
 	azureCred azcore.TokenCredential
 	azureLoc  string
 	gcp       *compute.Service
 	gcpProj   string
 	gcpRegion string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	ids *shared.CloudIDs
+}
+
+type networkResourceModel struct {
+	ID        types.String `tfsdk:"id"`
+	CloudID   types.String `tfsdk:"cloud_id"`
+	Name      types.String `tfsdk:"name"`
+	CIDR      types.String `tfsdk:"cidr"`
+	Type      types.String `tfsdk:"type"`
+	Region    types.String `tfsdk:"region"`
+	SubnetID  types.String `tfsdk:"subnet_id"`
+	GatewayID types.String `tfsdk:"gateway_id"`
+	Zone      types.String `tfsdk:"availability_zone"`
+
+	NATGateway   types.Bool   `tfsdk:"nat_gateway"`
+	NATGatewayID types.String `tfsdk:"nat_gateway_id"`
+}
+
+// setIDs records the created network's IDs; an empty ID is recorded as null.
+func (m *networkResourceModel) setIDs(id, subnetID, gatewayID, natID string) {
+	m.ID = types.StringValue(id)
+	m.CloudID = types.StringNull()
+	m.SubnetID, m.GatewayID, m.NATGatewayID = types.StringNull(), types.StringNull(), types.StringNull()
+	if subnetID != "" {
+		m.SubnetID = types.StringValue(subnetID)
+	}
+	if gatewayID != "" {
+		m.GatewayID = types.StringValue(gatewayID)
+	}
+	if natID != "" {
+		m.NATGatewayID = types.StringValue(natID)
+	}
 }
 
 func NewNetworkResource() resource.Resource { return &NetworkResource{} }
@@ -43,9 +90,14 @@ func (r *NetworkResource) Configure(ctx context.Context, req resource.ConfigureR
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.ids = cfg.CloudIDs
 	r.ec2 = cfg.AWSEC2
 	r.azureV = cfg.AzureVNetClient
 	r.azureS = cfg.AzureSubnetClient
+	r.azurePIP = cfg.AzurePIPClient
+	r.azureNAT = cfg.AzureNATClient
 	r.azureRG = cfg.AzureRGClient
 	r.azureCred = cfg.AzureCred
 	r.azureLoc = cfg.AzureLocation
@@ -62,34 +114,72 @@ func (r *NetworkResource) Schema(ctx context.Context, req resource.SchemaRequest
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
 			"id":         schema.StringAttribute{Computed: true},
+			"cloud_id":   cloudIDAttribute(),
 			"name":       schema.StringAttribute{Optional: true},
 			"cidr":       schema.StringAttribute{Optional: true},
 			"type":       schema.StringAttribute{Required: true},
+			"region":     schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
 			"subnet_id":  schema.StringAttribute{Computed: true},
 			"gateway_id": schema.StringAttribute{Computed: true},
+			// AWS only: the subnet's availability zone, by default the region's first.
+			"availability_zone": schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+
+			// Gives the subnet outbound internet access through a NAT gateway.
+			"nat_gateway":    schema.BoolAttribute{Optional: true, PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()}},
+			"nat_gateway_id": schema.StringAttribute{Computed: true},
 		},
 	}
 }
 
-func (r *NetworkResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name types.String `tfsdk:"name"`
-		CIDR types.String `tfsdk:"cidr"`
-		Type types.String `tfsdk:"type"`
+// useRegion points the AWS clients at the resource's region.
+func (r *NetworkResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.ec2 = c.EC2
+	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *NetworkResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.ec2 != nil
+	case "azure":
+		return r.azureRG != nil && r.azureV != nil && r.azureS != nil && r.azurePIP != nil && r.azureNAT != nil
+	case "gcp":
+		return r.gcp != nil
 	}
+	return false
+}
+
+func (r *NetworkResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan networkResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 
+	if plan.Type.ValueString() != "aws" && !plan.Zone.IsNull() {
+		resp.Diagnostics.AddWarning("availability zone ignored", "availability_zone only applies to aws; azure and gcp subnets span the region")
+	}
 	switch plan.Type.ValueString() {
 	case "aws":
-		if r.ec2 == nil {
-			resp.Diagnostics.AddError("missing AWS client", "")
+		// checked before the VPC is created so a bad zone leaves nothing behind
+		zone, err := awsZone(ctx, r.ec2, plan.Zone.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("aws zones", err.Error())
 			return
 		}
-
 		cidr := plan.CIDR.ValueString()
 		if cidr == "" {
 			cidr = "10.0.0.0/16"
@@ -100,11 +190,26 @@ func (r *NetworkResource) Create(ctx context.Context, req resource.CreateRequest
 			return
 		}
 		vpcID := aws.ToString(vpcOut.Vpc.VpcId)
+		// with a NAT gateway, the subnet gets half the range and the public
+		// subnet holding the gateway the other half
+		subnetCIDR, publicCIDR := cidr, ""
+		if plan.NATGateway.ValueBool() {
+			subnetCIDR, publicCIDR, err = splitCIDR(cidr)
+			if err != nil {
+				resp.Diagnostics.AddError("aws nat gateway", err.Error())
+				plan.setIDs(vpcID, "", "", "")
+				resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+				return
+			}
+		}
 
 		if plan.Name.ValueString() != "" {
-			_, err = r.ec2.CreateTags(ctx, &ec2.CreateTagsInput{
-				Resources: []string{vpcID},
-				Tags:      []ec2types.Tag{{Key: aws.String("Name"), Value: aws.String(plan.Name.ValueString())}},
+			err = shared.RetryAWS(ctx, func() error {
+				_, err := r.ec2.CreateTags(ctx, &ec2.CreateTagsInput{
+					Resources: []string{vpcID},
+					Tags:      []ec2types.Tag{{Key: aws.String("Name"), Value: aws.String(plan.Name.ValueString())}},
+				})
+				return err
 			})
 			if err != nil {
 				resp.Diagnostics.AddError("aws tag vpc", err.Error())
@@ -112,16 +217,14 @@ func (r *NetworkResource) Create(ctx context.Context, req resource.CreateRequest
 			}
 		}
 
-		azs, err := r.ec2.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{})
-		if err != nil || len(azs.AvailabilityZones) == 0 {
-			resp.Diagnostics.AddError("aws zones", "unable to determine availability zone")
-			return
-		}
-		zone := aws.ToString(azs.AvailabilityZones[0].ZoneName)
-		subnetOut, err := r.ec2.CreateSubnet(ctx, &ec2.CreateSubnetInput{
-			VpcId:            aws.String(vpcID),
-			CidrBlock:        aws.String(cidr),
-			AvailabilityZone: aws.String(zone),
+		var subnetOut *ec2.CreateSubnetOutput
+		err = shared.RetryAWS(ctx, func() error {
+			subnetOut, err = r.ec2.CreateSubnet(ctx, &ec2.CreateSubnetInput{
+				VpcId:            aws.String(vpcID),
+				CidrBlock:        aws.String(subnetCIDR),
+				AvailabilityZone: aws.String(zone),
+			})
+			return err
 		})
 		if err != nil {
 			resp.Diagnostics.AddError("aws create subnet", err.Error())
@@ -135,90 +238,87 @@ func (r *NetworkResource) Create(ctx context.Context, req resource.CreateRequest
 			return
 		}
 		gatewayID := aws.ToString(igwOut.InternetGateway.InternetGatewayId)
-		_, err = r.ec2.AttachInternetGateway(ctx, &ec2.AttachInternetGatewayInput{
-			VpcId:             aws.String(vpcID),
-			InternetGatewayId: aws.String(gatewayID),
+		err = shared.RetryAWS(ctx, func() error {
+			_, err := r.ec2.AttachInternetGateway(ctx, &ec2.AttachInternetGatewayInput{
+				VpcId:             aws.String(vpcID),
+				InternetGatewayId: aws.String(gatewayID),
+			})
+			return err
 		})
 		if err != nil {
 			resp.Diagnostics.AddError("aws attach igw", err.Error())
 			return
 		}
 
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":         vpcID,
-			"name":       plan.Name.ValueString(),
-			"cidr":       cidr,
-			"type":       plan.Type.ValueString(),
-			"subnet_id":  subnetID,
-			"gateway_id": gatewayID,
-		})
-		return
-	case "azure":
-		if r.azureV == nil || r.azureS == nil || r.azureRG == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
-			return
+		var natID string
+		if plan.NATGateway.ValueBool() {
+			natID, err = r.createAWSNAT(ctx, vpcID, zone, publicCIDR, subnetID, gatewayID)
+			if err != nil {
+				resp.Diagnostics.AddError("aws create nat gateway", err.Error())
+				// keep the network in state so what was created is removed on destroy
+				plan.setIDs(vpcID, subnetID, gatewayID, "")
+				resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+				return
+			}
 		}
+		plan.setIDs(vpcID, subnetID, gatewayID, natID)
+	case "azure":
 
 		cidr := plan.CIDR.ValueString()
 		if cidr == "" {
 			cidr = "10.0.0.0/16"
 		}
 		rgName := "abstract-rg"
-		if r.azureLoc == "" {
-			r.azureLoc = "eastus"
-		}
-		_, err := r.azureRG.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{Location: &r.azureLoc}, nil)
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		err := shared.EnsureResourceGroup(ctx, r.azureRG, rgName, loc)
 		if err != nil {
 			resp.Diagnostics.AddError("azure rg", err.Error())
 			return
 		}
 		vnetPoller, err := r.azureV.BeginCreateOrUpdate(ctx, rgName, plan.Name.ValueString(), armnetwork.VirtualNetwork{
-			Location: &r.azureLoc,
+			Location: &loc,
 			Properties: &armnetwork.VirtualNetworkPropertiesFormat{
 				AddressSpace: &armnetwork.AddressSpace{AddressPrefixes: []*string{&cidr}},
 			},
 		}, nil)
 		var vnetID string
 		if err == nil {
-			vnetResp, perr := vnetPoller.PollUntilDone(ctx, nil)
+			vnetResp, perr := shared.PollAzure(ctx, vnetPoller)
 			err = perr
 			if perr == nil && vnetResp.ID != nil {
 				vnetID = *vnetResp.ID
 			}
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure create vnet", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure create vnet", err)
 			return
 		}
-		subnetPoller, err := r.azureS.BeginCreateOrUpdate(ctx, rgName, plan.Name.ValueString(), "default", armnetwork.Subnet{
-			Properties: &armnetwork.SubnetPropertiesFormat{AddressPrefix: &cidr},
-		}, nil)
+		subnet := armnetwork.Subnet{Properties: &armnetwork.SubnetPropertiesFormat{AddressPrefix: &cidr}}
+		var natID string
+		if plan.NATGateway.ValueBool() {
+			natID, err = r.createAzureNAT(ctx, rgName, plan.Name.ValueString(), loc)
+			if err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure create nat gateway", err)
+				return
+			}
+			subnet.Properties.NatGateway = &armnetwork.SubResource{ID: &natID}
+		}
+		subnetPoller, err := r.azureS.BeginCreateOrUpdate(ctx, rgName, plan.Name.ValueString(), "default", subnet, nil)
 		var subnetID string
 		if err == nil {
-			subnetResp, serr := subnetPoller.PollUntilDone(ctx, nil)
+			subnetResp, serr := shared.PollAzure(ctx, subnetPoller)
 			err = serr
 			if serr == nil && subnetResp.ID != nil {
 				subnetID = *subnetResp.ID
 			}
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure create subnet", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure create subnet", err)
 			return
 		}
 
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":        vnetID,
-			"name":      plan.Name.ValueString(),
-			"cidr":      cidr,
-			"type":      plan.Type.ValueString(),
-			"subnet_id": subnetID,
-		})
-		return
+		plan.setIDs(vnetID, subnetID, "", natID)
 	case "gcp":
-		if r.gcp == nil {
-			resp.Diagnostics.AddError("gcp", "missing client")
-			return
-		}
 		name := plan.Name.ValueString()
 		if name == "" {
 			name = "abstract-network"
@@ -230,7 +330,10 @@ func (r *NetworkResource) Create(ctx context.Context, req resource.CreateRequest
 		} else {
 			net.AutoCreateSubnetworks = false
 		}
-		_, err := r.gcp.Networks.Insert(r.gcpProj, net).Context(ctx).Do()
+		op, err := r.gcp.Networks.Insert(r.gcpProj, net).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
 		if err != nil {
 			resp.Diagnostics.AddError("gcp create network", err.Error())
 			return
@@ -242,88 +345,134 @@ func (r *NetworkResource) Create(ctx context.Context, req resource.CreateRequest
 				IpCidrRange: cidr,
 				Network:     fmt.Sprintf("projects/%s/global/networks/%s", r.gcpProj, name),
 			}
-			region := r.gcpRegion
-			if region == "" {
-				region = "us-central1"
-			}
-			_, err = r.gcp.Subnetworks.Insert(r.gcpProj, region, sn).Context(ctx).Do()
+			_, err = r.gcp.Subnetworks.Insert(r.gcpProj, r.gcpSubnetRegion(&plan), sn).Context(ctx).Do()
 			if err != nil {
 				resp.Diagnostics.AddError("gcp create subnet", err.Error())
 				return
 			}
 			subnetID = sn.Name
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":        name,
-			"name":      name,
-			"cidr":      cidr,
-			"type":      plan.Type.ValueString(),
-			"subnet_id": subnetID,
-		})
-		return
+		var natID string
+		if plan.NATGateway.ValueBool() {
+			natID = name + "-nat"
+			router := &compute.Router{
+				Name:    natID,
+				Network: fmt.Sprintf("projects/%s/global/networks/%s", r.gcpProj, name),
+				Nats: []*compute.RouterNat{{
+					Name:                          natID,
+					NatIpAllocateOption:           "AUTO_ONLY",
+					SourceSubnetworkIpRangesToNat: "ALL_SUBNETWORKS_ALL_IP_RANGES",
+				}},
+			}
+			op, err := r.gcp.Routers.Insert(r.gcpProj, r.gcpSubnetRegion(&plan), router).Context(ctx).Do()
+			if err == nil {
+				err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp create cloud nat", err.Error())
+				// keep the network in state so it is removed on destroy
+				plan.setIDs(name, subnetID, "", "")
+				resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+				return
+			}
+		}
+		plan.setIDs(name, subnetID, "", natID)
 	default:
 		resp.Diagnostics.AddError("unsupported cloud", "only aws and azure implemented")
 		return
 	}
+	id, err := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
-func (r *NetworkResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID   types.String `tfsdk:"id"`
-		Type types.String `tfsdk:"type"`
+// cloudID is the VPC's ARN, the virtual network's ID or the network's self link.
+func (r *NetworkResource) cloudID(ctx context.Context, m *networkResourceModel) (string, error) {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.ARN(ctx, "ec2", true, "vpc/"+id)
+	case "azure":
+		return id, nil
+	case "gcp":
+		return r.ids.GCPSelfLink("global/networks/" + id), nil
 	}
+	return "", nil
+}
+
+// gcpSubnetRegion returns the region of a network's GCP subnetwork.
+func (r *NetworkResource) gcpSubnetRegion(m *networkResourceModel) string {
+	switch {
+	case m.Region.ValueString() != "":
+		return m.Region.ValueString()
+	case r.gcpRegion != "":
+		return r.gcpRegion
+	}
+	return "us-central1"
+}
+
+func (r *NetworkResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state networkResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.ec2 == nil {
-			return
-		}
 		out, err := r.ec2.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{VpcIds: []string{state.ID.ValueString()}})
 		if err != nil || len(out.Vpcs) == 0 {
 			resp.State.RemoveResource(ctx)
-		}
-	case "azure":
-		if r.azureV == nil {
 			return
 		}
+	case "azure":
 		_, err := r.azureV.Get(ctx, "abstract-rg", state.ID.ValueString(), nil)
 		if err != nil {
 			resp.State.RemoveResource(ctx)
-		}
-	case "gcp":
-		if r.gcp == nil {
 			return
 		}
+	case "gcp":
 		_, err := r.gcp.Networks.Get(r.gcpProj, state.ID.ValueString()).Context(ctx).Do()
 		if err != nil {
 			resp.State.RemoveResource(ctx)
+			return
 		}
 	}
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
 }
 
 func (r *NetworkResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
 }
 
 func (r *NetworkResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		ID        types.String `tfsdk:"id"`
-		Type      types.String `tfsdk:"type"`
-		SubnetID  types.String `tfsdk:"subnet_id"`
-		GatewayID types.String `tfsdk:"gateway_id"`
-	}
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state networkResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.ec2 == nil {
-			return
+		// the NAT gateway's address must be released before the internet gateway detaches
+		if state.NATGateway.ValueBool() {
+			if err := r.deleteAWSNAT(ctx, state.ID.ValueString()); err != nil {
+				resp.Diagnostics.AddError("aws delete nat gateway", err.Error())
+				return
+			}
 		}
 		if state.GatewayID.ValueString() != "" {
 			_, _ = r.ec2.DetachInternetGateway(ctx, &ec2.DetachInternetGatewayInput{
@@ -340,22 +489,45 @@ func (r *NetworkResource) Delete(ctx context.Context, req resource.DeleteRequest
 			resp.Diagnostics.AddError("aws delete vpc", err.Error())
 		}
 	case "azure":
-		if r.azureV == nil {
+		poller, err := r.azureV.BeginDelete(ctx, "abstract-rg", state.ID.ValueString(), nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete vnet", err)
 			return
 		}
-		poller, err := r.azureV.BeginDelete(ctx, "abstract-rg", state.ID.ValueString(), nil)
+		if !state.NATGateway.ValueBool() {
+			return
+		}
+		natPoller, err := r.azureNAT.BeginDelete(ctx, "abstract-rg", state.Name.ValueString()+"-nat", nil)
 		if err == nil {
-			_, err = poller.PollUntilDone(ctx, nil)
+			_, err = shared.PollAzure(ctx, natPoller)
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure delete vnet", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure delete nat gateway", err)
+			return
+		}
+		pipPoller, err := r.azurePIP.BeginDelete(ctx, "abstract-rg", state.Name.ValueString()+"-nat-pip", nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, pipPoller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete nat pip", err)
 		}
 	case "gcp":
-		if r.gcp == nil {
-			return
+		if natID := state.NATGatewayID.ValueString(); natID != "" {
+			op, err := r.gcp.Routers.Delete(r.gcpProj, r.gcpSubnetRegion(&state), natID).Context(ctx).Do()
+			if err == nil {
+				err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp delete cloud nat", err.Error())
+				return
+			}
 		}
 		if state.SubnetID.ValueString() != "" {
-			_, _ = r.gcp.Subnetworks.Delete(r.gcpProj, r.gcpRegion, state.SubnetID.ValueString()).Context(ctx).Do()
+			_, _ = r.gcp.Subnetworks.Delete(r.gcpProj, r.gcpSubnetRegion(&state), state.SubnetID.ValueString()).Context(ctx).Do()
 		}
 		_, err := r.gcp.Networks.Delete(r.gcpProj, state.ID.ValueString()).Context(ctx).Do()
 		if err != nil {
@@ -363,3 +535,188 @@ func (r *NetworkResource) Delete(ctx context.Context, req resource.DeleteRequest
 		}
 	}
 }
+
+// splitCIDR halves a range, for a private subnet and the public subnet that
+// holds its NAT gateway.
+func splitCIDR(cidr string) (string, string, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", "", err
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits != 32 || ones >= 28 {
+		return "", "", fmt.Errorf("%s is too small to split into two subnets of at least /28", cidr)
+	}
+	mask := net.CIDRMask(ones+1, bits)
+	lower := ipnet.IP.To4()
+	upper := make(net.IP, len(lower))
+	copy(upper, lower)
+	upper[ones/8] |= 0x80 >> (ones % 8)
+	return (&net.IPNet{IP: lower, Mask: mask}).String(), (&net.IPNet{IP: upper, Mask: mask}).String(), nil
+}
+
+// createAWSNAT creates a public subnet routed to the internet gateway, puts a
+// NAT gateway with an Elastic IP in it and routes the private subnet through
+// the NAT gateway. It returns the NAT gateway's ID.
+func (r *NetworkResource) createAWSNAT(ctx context.Context, vpcID, zone, publicCIDR, privateSubnetID, igwID string) (string, error) {
+	var subnetOut *ec2.CreateSubnetOutput
+	err := shared.RetryAWS(ctx, func() error {
+		var err error
+		subnetOut, err = r.ec2.CreateSubnet(ctx, &ec2.CreateSubnetInput{
+			VpcId:            aws.String(vpcID),
+			CidrBlock:        aws.String(publicCIDR),
+			AvailabilityZone: aws.String(zone),
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	publicSubnetID := aws.ToString(subnetOut.Subnet.SubnetId)
+	if err := r.awsDefaultRoute(ctx, vpcID, publicSubnetID, &ec2types.Route{GatewayId: aws.String(igwID)}); err != nil {
+		return "", err
+	}
+	eip, err := r.ec2.AllocateAddress(ctx, &ec2.AllocateAddressInput{Domain: ec2types.DomainTypeVpc})
+	if err != nil {
+		return "", err
+	}
+	nat, err := r.ec2.CreateNatGateway(ctx, &ec2.CreateNatGatewayInput{SubnetId: aws.String(publicSubnetID), AllocationId: eip.AllocationId})
+	if err != nil {
+		_, _ = r.ec2.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: eip.AllocationId})
+		return "", err
+	}
+	natID := aws.ToString(nat.NatGateway.NatGatewayId)
+	err = ec2.NewNatGatewayAvailableWaiter(r.ec2).Wait(ctx, &ec2.DescribeNatGatewaysInput{NatGatewayIds: []string{natID}}, 15*time.Minute)
+	if err != nil {
+		return "", err
+	}
+	if err := r.awsDefaultRoute(ctx, vpcID, privateSubnetID, &ec2types.Route{NatGatewayId: aws.String(natID)}); err != nil {
+		return "", err
+	}
+	return natID, nil
+}
+
+// awsDefaultRoute gives subnetID a route table of its own whose default route
+// goes to the gateway set in target.
+func (r *NetworkResource) awsDefaultRoute(ctx context.Context, vpcID, subnetID string, target *ec2types.Route) error {
+	rt, err := r.ec2.CreateRouteTable(ctx, &ec2.CreateRouteTableInput{VpcId: aws.String(vpcID)})
+	if err != nil {
+		return err
+	}
+	rtID := rt.RouteTable.RouteTableId
+	err = shared.RetryAWS(ctx, func() error {
+		_, err := r.ec2.CreateRoute(ctx, &ec2.CreateRouteInput{
+			RouteTableId:         rtID,
+			DestinationCidrBlock: aws.String("0.0.0.0/0"),
+			GatewayId:            target.GatewayId,
+			NatGatewayId:         target.NatGatewayId,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.ec2.AssociateRouteTable(ctx, &ec2.AssociateRouteTableInput{RouteTableId: rtID, SubnetId: aws.String(subnetID)})
+	return err
+}
+
+// deleteAWSNAT removes the NAT gateways in a VPC with their Elastic IPs, the
+// route tables created for them and the public subnet. It looks them up by
+// VPC so a create that failed part way is cleaned up too.
+func (r *NetworkResource) deleteAWSNAT(ctx context.Context, vpcID string) error {
+	inVPC := []ec2types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}
+	nats, err := r.ec2.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{Filter: inVPC})
+	if err != nil {
+		return err
+	}
+	var natIDs, allocations, publicSubnets []string
+	for _, n := range nats.NatGateways {
+		if n.State == ec2types.NatGatewayStateDeleted {
+			continue
+		}
+		natIDs = append(natIDs, aws.ToString(n.NatGatewayId))
+		publicSubnets = append(publicSubnets, aws.ToString(n.SubnetId))
+		for _, a := range n.NatGatewayAddresses {
+			allocations = append(allocations, aws.ToString(a.AllocationId))
+		}
+		if _, err := r.ec2.DeleteNatGateway(ctx, &ec2.DeleteNatGatewayInput{NatGatewayId: n.NatGatewayId}); err != nil {
+			return err
+		}
+	}
+	if len(natIDs) > 0 {
+		err = ec2.NewNatGatewayDeletedWaiter(r.ec2).Wait(ctx, &ec2.DescribeNatGatewaysInput{NatGatewayIds: natIDs}, 15*time.Minute)
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range allocations {
+		if _, err := r.ec2.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: aws.String(id)}); err != nil {
+			return err
+		}
+	}
+	tables, err := r.ec2.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{Filters: inVPC})
+	if err != nil {
+		return err
+	}
+	for _, rt := range tables.RouteTables {
+		isMain := false
+		for _, a := range rt.Associations {
+			if aws.ToBool(a.Main) {
+				isMain = true
+				continue
+			}
+			if _, err := r.ec2.DisassociateRouteTable(ctx, &ec2.DisassociateRouteTableInput{AssociationId: a.RouteTableAssociationId}); err != nil {
+				return err
+			}
+		}
+		if isMain {
+			continue
+		}
+		if _, err := r.ec2.DeleteRouteTable(ctx, &ec2.DeleteRouteTableInput{RouteTableId: rt.RouteTableId}); err != nil {
+			return err
+		}
+	}
+	for _, id := range publicSubnets {
+		if _, err := r.ec2.DeleteSubnet(ctx, &ec2.DeleteSubnetInput{SubnetId: aws.String(id)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createAzureNAT creates a NAT gateway with a public IP for the network's
+// subnet and returns its resource ID.
+func (r *NetworkResource) createAzureNAT(ctx context.Context, rgName, name, loc string) (string, error) {
+	pipPoller, err := r.azurePIP.BeginCreateOrUpdate(ctx, rgName, name+"-nat-pip", armnetwork.PublicIPAddress{
+		Location: &loc,
+		SKU:      &armnetwork.PublicIPAddressSKU{Name: to.Ptr(armnetwork.PublicIPAddressSKUNameStandard)},
+		Properties: &armnetwork.PublicIPAddressPropertiesFormat{
+			PublicIPAllocationMethod: to.Ptr(armnetwork.IPAllocationMethodStatic),
+		},
+	}, nil)
+	var pip armnetwork.PublicIPAddressesClientCreateOrUpdateResponse
+	if err == nil {
+		pip, err = shared.PollAzure(ctx, pipPoller)
+	}
+	if err != nil {
+		return "", err
+	}
+	natPoller, err := r.azureNAT.BeginCreateOrUpdate(ctx, rgName, name+"-nat", armnetwork.NatGateway{
+		Location: &loc,
+		SKU:      &armnetwork.NatGatewaySKU{Name: to.Ptr(armnetwork.NatGatewaySKUNameStandard)},
+		Properties: &armnetwork.NatGatewayPropertiesFormat{
+			PublicIPAddresses: []*armnetwork.SubResource{{ID: pip.ID}},
+		},
+	}, nil)
+	var nat armnetwork.NatGatewaysClientCreateOrUpdateResponse
+	if err == nil {
+		nat, err = shared.PollAzure(ctx, natPoller)
+	}
+	if err != nil {
+		return "", err
+	}
+	if nat.ID == nil {
+		return "", fmt.Errorf("nat gateway has no ID")
+	}
+	return *nat.ID, nil
+}
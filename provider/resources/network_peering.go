@@ -0,0 +1,405 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// NetworkPeeringResource connects two abstract_networks in the same cloud:
+// a VPC peering connection on AWS, a pair of VNet peerings on Azure, or VPC
+// Network Peering in both directions on GCP.
+type NetworkPeeringResource struct {
+	ec2 *ec2.Client
+
+	azurePeering *armnetwork.VirtualNetworkPeeringsClient
+
+	gcp     *compute.Service
+	gcpProj string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	ids *shared.CloudIDs
+}
+
+type networkPeeringResourceModel struct {
+	ID            types.String `tfsdk:"id"`
+	CloudID       types.String `tfsdk:"cloud_id"`
+	Type          types.String `tfsdk:"type"`
+	NetworkID     types.String `tfsdk:"network_id"`
+	PeerNetworkID types.String `tfsdk:"peer_network_id"`
+}
+
+func NewNetworkPeeringResource() resource.Resource { return &NetworkPeeringResource{} }
+
+func (r *NetworkPeeringResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.ids = cfg.CloudIDs
+	r.ec2 = cfg.AWSEC2
+	r.azurePeering = cfg.AzurePeeringClient
+	r.gcp = cfg.GCPCompute
+	r.gcpProj = cfg.GCPProject
+}
+
+func (r *NetworkPeeringResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_network_peering"
+}
+
+func (r *NetworkPeeringResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			// VPC peering connection ID, Azure peering resource ID or GCP peering name.
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"type": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// abstract_network ids; both must be networks of type.
+			"network_id":      schema.StringAttribute{Required: true, PlanModifiers: replace},
+			"peer_network_id": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			"cloud_id":        cloudIDAttribute(),
+		},
+	}
+}
+
+// checkNetworkID reports why id is not an abstract_network id of cloud, or
+// "" if it is.
+func checkNetworkID(cloud, id string) string {
+	switch cloud {
+	case "aws":
+		if !strings.HasPrefix(id, "vpc-") {
+			return "AWS networks are VPC IDs like vpc-0abc"
+		}
+	case "azure":
+		if _, _, err := azureVNetRef(id); err != nil {
+			return "Azure networks are virtual network resource IDs"
+		}
+	case "gcp":
+		if !gcpResourceName.MatchString(id) {
+			return "GCP networks are network names"
+		}
+	}
+	return ""
+}
+
+func (r *NetworkPeeringResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg networkPeeringResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() || cfg.Type.IsUnknown() {
+		return
+	}
+	cloud := cfg.Type.ValueString()
+	for attr, id := range map[string]types.String{"network_id": cfg.NetworkID, "peer_network_id": cfg.PeerNetworkID} {
+		if id.IsUnknown() || id.IsNull() {
+			continue
+		}
+		// cross-cloud peering is not supported, so both ids must be of type
+		if msg := checkNetworkID(cloud, id.ValueString()); msg != "" {
+			resp.Diagnostics.AddAttributeError(path.Root(attr), "network of another cloud", fmt.Sprintf("%q is not a %s network: %s", id.ValueString(), cloud, msg))
+		}
+	}
+	if !cfg.NetworkID.IsUnknown() && !cfg.PeerNetworkID.IsUnknown() && cfg.NetworkID.ValueString() == cfg.PeerNetworkID.ValueString() {
+		resp.Diagnostics.AddAttributeError(path.Root("peer_network_id"), "invalid peer_network_id", "a network cannot be peered with itself")
+	}
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *NetworkPeeringResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.ec2 != nil
+	case "azure":
+		return r.azurePeering != nil
+	case "gcp":
+		return r.gcp != nil
+	}
+	return false
+}
+
+// azurePeeringName names the peering from one virtual network to another.
+func azurePeeringName(from, peer string) string {
+	return from + "-to-" + peer
+}
+
+// gcpPeeringName names the peering from one GCP network to another, within
+// GCP's 63 character limit.
+func gcpPeeringName(from, peer string) string {
+	name := from + "-" + peer
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+func (r *NetworkPeeringResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan networkPeeringResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	a, b := plan.NetworkID.ValueString(), plan.PeerNetworkID.ValueString()
+	plan.CloudID = types.StringNull()
+	switch plan.Type.ValueString() {
+	case "aws":
+		out, err := r.ec2.CreateVpcPeeringConnection(ctx, &ec2.CreateVpcPeeringConnectionInput{VpcId: aws.String(a), PeerVpcId: aws.String(b)})
+		if err != nil {
+			resp.Diagnostics.AddError("aws create peering", err.Error())
+			return
+		}
+		id := out.VpcPeeringConnection.VpcPeeringConnectionId
+		plan.ID = types.StringValue(aws.ToString(id))
+		cloudID, idErr := r.cloudID(ctx, &plan)
+		setCloudID(&resp.Diagnostics, &plan.CloudID, cloudID, idErr)
+		// both VPCs are in this account, so the request is accepted here too
+		err = ec2.NewVpcPeeringConnectionExistsWaiter(r.ec2).Wait(ctx, &ec2.DescribeVpcPeeringConnectionsInput{VpcPeeringConnectionIds: []string{plan.ID.ValueString()}}, 5*time.Minute)
+		if err == nil {
+			_, err = r.ec2.AcceptVpcPeeringConnection(ctx, &ec2.AcceptVpcPeeringConnectionInput{VpcPeeringConnectionId: id})
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("aws accept peering", err.Error())
+			// keep the peering in state so it is not leaked
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+			return
+		}
+	case "azure":
+		rgA, nameA, _ := azureVNetRef(a)
+		rgB, nameB, _ := azureVNetRef(b)
+		id, err := r.azurePeer(ctx, rgA, nameA, azurePeeringName(nameA, nameB), b)
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure create peering", err)
+			return
+		}
+		plan.ID = types.StringValue(id)
+		cloudID, idErr := r.cloudID(ctx, &plan)
+		setCloudID(&resp.Diagnostics, &plan.CloudID, cloudID, idErr)
+		if _, err := r.azurePeer(ctx, rgB, nameB, azurePeeringName(nameB, nameA), a); err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure create peering", err)
+			// keep the first direction in state so it is removed on destroy
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+			return
+		}
+	case "gcp":
+		if err := r.gcpPeer(ctx, a, b); err != nil {
+			resp.Diagnostics.AddError("gcp create peering", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(gcpPeeringName(a, b))
+		if err := r.gcpPeer(ctx, b, a); err != nil {
+			resp.Diagnostics.AddError("gcp create peering", err.Error())
+			// keep the first direction in state so it is removed on destroy
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+			return
+		}
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// azurePeer peers a virtual network with remote and returns the peering's ID.
+func (r *NetworkPeeringResource) azurePeer(ctx context.Context, rg, vnet, name, remote string) (string, error) {
+	poller, err := r.azurePeering.BeginCreateOrUpdate(ctx, rg, vnet, name, armnetwork.VirtualNetworkPeering{
+		Properties: &armnetwork.VirtualNetworkPeeringPropertiesFormat{
+			RemoteVirtualNetwork:      &armnetwork.SubResource{ID: &remote},
+			AllowVirtualNetworkAccess: to.Ptr(true),
+			AllowForwardedTraffic:     to.Ptr(true),
+		},
+	}, nil)
+	var res armnetwork.VirtualNetworkPeeringsClientCreateOrUpdateResponse
+	if err == nil {
+		res, err = shared.PollAzure(ctx, poller)
+	}
+	if err != nil {
+		return "", err
+	}
+	if res.ID == nil {
+		return "", fmt.Errorf("peering %s has no ID", name)
+	}
+	return *res.ID, nil
+}
+
+// gcpPeer adds a peering from one GCP network to another. GCP runs one
+// peering operation per network at a time, so it waits for each.
+func (r *NetworkPeeringResource) gcpPeer(ctx context.Context, from, peer string) error {
+	op, err := r.gcp.Networks.AddPeering(r.gcpProj, from, &compute.NetworksAddPeeringRequest{
+		NetworkPeering: &compute.NetworkPeering{
+			Name:                 gcpPeeringName(from, peer),
+			Network:              fmt.Sprintf("projects/%s/global/networks/%s", r.gcpProj, peer),
+			ExchangeSubnetRoutes: true,
+		},
+	}).Context(ctx).Do()
+	if err == nil {
+		err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+	}
+	return err
+}
+
+func (r *NetworkPeeringResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state networkPeeringResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	a, b := state.NetworkID.ValueString(), state.PeerNetworkID.ValueString()
+	var err error
+	switch state.Type.ValueString() {
+	case "aws":
+		var out *ec2.DescribeVpcPeeringConnectionsOutput
+		out, err = r.ec2.DescribeVpcPeeringConnections(ctx, &ec2.DescribeVpcPeeringConnectionsInput{VpcPeeringConnectionIds: []string{state.ID.ValueString()}})
+		if err == nil && (len(out.VpcPeeringConnections) == 0 || awsPeeringGone(out.VpcPeeringConnections[0])) {
+			err = fmt.Errorf("peering %s not found", state.ID.ValueString())
+		}
+	case "azure":
+		rgA, nameA, _ := azureVNetRef(a)
+		_, nameB, _ := azureVNetRef(b)
+		_, err = r.azurePeering.Get(ctx, rgA, nameA, azurePeeringName(nameA, nameB), nil)
+	case "gcp":
+		var network *compute.Network
+		network, err = r.gcp.Networks.Get(r.gcpProj, a).Context(ctx).Do()
+		if err == nil && !gcpHasPeering(network, state.ID.ValueString()) {
+			err = fmt.Errorf("peering %s not found", state.ID.ValueString())
+		}
+	}
+	if err != nil {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+// cloudID is the peering connection's ARN or the Azure peering's ID. GCP
+// peerings are part of their networks and have none.
+func (r *NetworkPeeringResource) cloudID(ctx context.Context, m *networkPeeringResourceModel) (string, error) {
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.ARN(ctx, "ec2", true, "vpc-peering-connection/"+m.ID.ValueString())
+	case "azure":
+		return m.ID.ValueString(), nil
+	}
+	return "", nil
+}
+
+// awsPeeringGone reports whether a VPC peering connection no longer connects
+// its VPCs.
+func awsPeeringGone(p ec2types.VpcPeeringConnection) bool {
+	if p.Status == nil {
+		return false
+	}
+	switch p.Status.Code {
+	case ec2types.VpcPeeringConnectionStateReasonCodeDeleted, ec2types.VpcPeeringConnectionStateReasonCodeDeleting,
+		ec2types.VpcPeeringConnectionStateReasonCodeRejected, ec2types.VpcPeeringConnectionStateReasonCodeFailed,
+		ec2types.VpcPeeringConnectionStateReasonCodeExpired:
+		return true
+	}
+	return false
+}
+
+func gcpHasPeering(network *compute.Network, name string) bool {
+	for _, p := range network.Peerings {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Update is never called: every attribute replaces the peering.
+func (r *NetworkPeeringResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan networkPeeringResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *NetworkPeeringResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state networkPeeringResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	a, b := state.NetworkID.ValueString(), state.PeerNetworkID.ValueString()
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err := r.ec2.DeleteVpcPeeringConnection(ctx, &ec2.DeleteVpcPeeringConnectionInput{VpcPeeringConnectionId: aws.String(state.ID.ValueString())})
+		if err != nil {
+			resp.Diagnostics.AddError("aws delete", err.Error())
+		}
+	case "azure":
+		rgA, nameA, _ := azureVNetRef(a)
+		rgB, nameB, _ := azureVNetRef(b)
+		// deleting a peering that does not exist succeeds, so a half-created pair is fine
+		for _, p := range []struct{ rg, vnet, name string }{
+			{rgA, nameA, azurePeeringName(nameA, nameB)},
+			{rgB, nameB, azurePeeringName(nameB, nameA)},
+		} {
+			poller, err := r.azurePeering.BeginDelete(ctx, p.rg, p.vnet, p.name, nil)
+			if err == nil {
+				_, err = shared.PollAzure(ctx, poller)
+			}
+			if err != nil {
+				shared.AddAzureError(&resp.Diagnostics, "azure delete", err)
+				return
+			}
+		}
+	case "gcp":
+		for _, p := range [][2]string{{a, b}, {b, a}} {
+			network, err := r.gcp.Networks.Get(r.gcpProj, p[0]).Context(ctx).Do()
+			if err != nil {
+				resp.Diagnostics.AddError("gcp delete", err.Error())
+				return
+			}
+			name := gcpPeeringName(p[0], p[1])
+			if !gcpHasPeering(network, name) {
+				continue
+			}
+			op, err := r.gcp.Networks.RemovePeering(r.gcpProj, p[0], &compute.NetworksRemovePeeringRequest{Name: name}).Context(ctx).Do()
+			if err == nil {
+				err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp delete", err.Error())
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package resources
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestNetworkPeeringConfig(t *testing.T) {
+	r := &NetworkPeeringResource{}
+	s := testSchema(t, r)
+	const vnet = "/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Network/virtualNetworks/"
+	peering := func(cloud, a, b string) map[string]tftypes.Value {
+		return map[string]tftypes.Value{"type": str(cloud), "network_id": str(a), "peer_network_id": str(b)}
+	}
+	cases := []struct {
+		name string
+		vals map[string]tftypes.Value
+		errs bool
+	}{
+		{"aws", peering("aws", "vpc-0abc", "vpc-0def"), false},
+		{"aws with gcp network", peering("aws", "vpc-0abc", "backend"), true},
+		{"aws with azure network", peering("aws", vnet+"hub", "vpc-0def"), true},
+		{"azure", peering("azure", vnet+"hub", vnet+"spoke"), false},
+		{"azure with aws network", peering("azure", vnet+"hub", "vpc-0def"), true},
+		{"gcp", peering("gcp", "frontend", "backend"), false},
+		{"gcp with azure network", peering("gcp", "frontend", vnet+"spoke"), true},
+		{"self", peering("gcp", "frontend", "frontend"), true},
+		{"unknown peer", map[string]tftypes.Value{"type": str("aws"), "network_id": str("vpc-0abc"), "peer_network_id": tftypes.NewValue(tftypes.String, tftypes.UnknownValue)}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestGCPPeeringName(t *testing.T) {
+	if got := gcpPeeringName("frontend", "backend"); got != "frontend-backend" {
+		t.Errorf("gcpPeeringName = %q", got)
+	}
+	long := gcpPeeringName(strings.Repeat("a", 62), "backend")
+	if len(long) > 63 || strings.HasSuffix(long, "-") {
+		t.Errorf("gcpPeeringName of long names = %q", long)
+	}
+}
@@ -0,0 +1,25 @@
+package resources
+
+import "testing"
+
+func TestSplitCIDR(t *testing.T) {
+	cases := []struct {
+		cidr, lower, upper string
+	}{
+		{"10.0.0.0/16", "10.0.0.0/17", "10.0.128.0/17"},
+		{"10.0.0.0/24", "10.0.0.0/25", "10.0.0.128/25"},
+		{"172.16.4.0/22", "172.16.4.0/23", "172.16.6.0/23"},
+		{"192.168.1.7/24", "192.168.1.0/25", "192.168.1.128/25"},
+	}
+	for _, tc := range cases {
+		lower, upper, err := splitCIDR(tc.cidr)
+		if err != nil || lower != tc.lower || upper != tc.upper {
+			t.Errorf("splitCIDR(%q) = %q, %q, %v; want %q, %q", tc.cidr, lower, upper, err, tc.lower, tc.upper)
+		}
+	}
+	for _, cidr := range []string{"10.0.0.0/28", "10.0.0.0", "2001:db8::/48"} {
+		if _, _, err := splitCIDR(cidr); err == nil {
+			t.Errorf("splitCIDR(%q) accepted", cidr)
+		}
+	}
+}
@@ -2,32 +2,74 @@ package resources
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"abstract-provider/provider/shared"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
-	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azqueue"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 type QueueResource struct {
-	sqs        *sqs.Client
+	sqs        shared.SQSQueues
 	azureRG    *armresources.ResourceGroupsClient
 	azureAcct  *armstorage.AccountsClient
 	azureCred  azcore.TokenCredential
 	azureSubID string
 	azureLoc   string
+
+	// azureShared* name a provider-wide storage account that is never deleted.
+	azureSharedAcct string
+	azureSharedRG   string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+}
+
+type queueResourceModel struct {
+	ID                       types.String `tfsdk:"id"`
+	CloudID                  types.String `tfsdk:"cloud_id"`
+	ARN                      types.String `tfsdk:"arn"`
+	Name                     types.String `tfsdk:"name"`
+	QueueName                types.String `tfsdk:"queue_name"`
+	Type                     types.String `tfsdk:"type"`
+	Region                   types.String `tfsdk:"region"`
+	FIFO                     types.Bool   `tfsdk:"fifo"`
+	ContentBasedDedup        types.Bool   `tfsdk:"content_based_dedup"`
+	MessageRetentionSeconds  types.Int64  `tfsdk:"message_retention_seconds"`
+	VisibilityTimeoutSeconds types.Int64  `tfsdk:"visibility_timeout_seconds"`
+	MaxMessageSize           types.Int64  `tfsdk:"max_message_size"`
+	DeadLetterTargetARN      types.String `tfsdk:"dead_letter_target_arn"`
+	MaxReceiveCount          types.Int64  `tfsdk:"max_receive_count"`
+	KMSKeyID                 types.String `tfsdk:"kms_key_id"`
+	Account                  types.String `tfsdk:"account"`
+	ResourceGroup            types.String `tfsdk:"resource_group"`
+	AccountCreated           types.Bool   `tfsdk:"account_created"`
+	ConnectionString         types.String `tfsdk:"connection_string"`
 }
 
+// azureQueueTuningWarning explains why SQS tuning attributes are ignored on Azure.
+const azureQueueTuningWarning = "message_retention_seconds, visibility_timeout_seconds and max_message_size have no Azure Storage Queue equivalent"
+
 func NewQueueResource() resource.Resource { return &QueueResource{} }
 
 func (r *QueueResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
@@ -39,12 +81,18 @@ func (r *QueueResource) Configure(ctx context.Context, req resource.ConfigureReq
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
-	r.sqs = cfg.AWSSQS
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	if cfg.AWSSQS != nil {
+		r.sqs = cfg.AWSSQS
+	}
 	r.azureRG = cfg.AzureRGClient
 	r.azureAcct = cfg.AzureStorageAcct
 	r.azureCred = cfg.AzureCred
 	r.azureSubID = cfg.AzureSubID
 	r.azureLoc = cfg.AzureLocation
+	r.azureSharedAcct = cfg.AzureStorageAccount
+	r.azureSharedRG = cfg.AzureStorageResourceGroup
 }
 
 func (r *QueueResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -54,97 +102,295 @@ func (r *QueueResource) Metadata(ctx context.Context, req resource.MetadataReque
 func (r *QueueResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
-			"id":             schema.StringAttribute{Computed: true},
-			"name":           schema.StringAttribute{Required: true},
-			"type":           schema.StringAttribute{Required: true},
-			"region":         schema.StringAttribute{Optional: true},
-			"fifo":           schema.BoolAttribute{Optional: true},
-			"account":        schema.StringAttribute{Computed: true},
-			"resource_group": schema.StringAttribute{Computed: true},
+			"id":     schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"name":   schema.StringAttribute{Required: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"type":   schema.StringAttribute{Required: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"region": schema.StringAttribute{Optional: true, PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()}},
+			"fifo":   schema.BoolAttribute{Optional: true, PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()}},
+			// FIFO queues only: deduplicate messages by a hash of their body
+			// instead of an explicit deduplication ID.
+			"content_based_dedup": schema.BoolAttribute{Optional: true},
+			// SQS tuning; unset values take the cloud default and are read back for drift detection.
+			"message_retention_seconds":  schema.Int64Attribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()}},
+			"visibility_timeout_seconds": schema.Int64Attribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()}},
+			"max_message_size":           schema.Int64Attribute{Optional: true, Computed: true, PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()}},
+			"account":                    schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"resource_group":             schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+
+			// Only an account created for this queue is deleted with it.
+			"account_created": schema.BoolAttribute{Computed: true, PlanModifiers: []planmodifier.Bool{boolplanmodifier.UseStateForUnknown()}},
+			// Azure only: the storage account's connection string, for apps
+			// using the queue.
+			"connection_string": schema.StringAttribute{Computed: true, Sensitive: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+
+			// SQS redrive policy: messages received max_receive_count times move to the target queue.
+			"dead_letter_target_arn": schema.StringAttribute{Optional: true},
+			"max_receive_count":      schema.Int64Attribute{Optional: true},
+			// Customer-managed key for SQS server-side encryption; unset uses SQS-managed encryption.
+			"kms_key_id": schema.StringAttribute{Optional: true},
+
+			// Queue ARN on AWS and queue resource ID on Azure, e.g. for IAM policies.
+			"arn":      schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
+			"cloud_id": cloudIDAttribute(),
+			// The queue's name in the cloud, with the .fifo suffix SQS adds
+			// to FIFO queues; name stays as configured.
+			"queue_name": schema.StringAttribute{Computed: true, PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()}},
 		},
 	}
 }
 
-func (r *QueueResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name   types.String `tfsdk:"name"`
-		Type   types.String `tfsdk:"type"`
-		Region types.String `tfsdk:"region"`
-		FIFO   types.Bool   `tfsdk:"fifo"`
+func (r *QueueResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg queueResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !cfg.DeadLetterTargetARN.IsNull() && !cfg.MaxReceiveCount.IsUnknown() && cfg.MaxReceiveCount.ValueInt64() < 1 {
+		resp.Diagnostics.AddAttributeError(path.Root("max_receive_count"), "invalid max_receive_count", "max_receive_count must be positive when dead_letter_target_arn is set")
+	}
+	if cfg.DeadLetterTargetARN.IsNull() && !cfg.MaxReceiveCount.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("max_receive_count"), "missing dead_letter_target_arn", "max_receive_count requires dead_letter_target_arn")
+	}
+	if cfg.ContentBasedDedup.ValueBool() && !cfg.FIFO.IsUnknown() && !cfg.FIFO.ValueBool() {
+		resp.Diagnostics.AddAttributeError(path.Root("content_based_dedup"), "invalid content_based_dedup", "content_based_dedup requires fifo = true")
+	}
+	switch t := cfg.Type.ValueString(); t {
+	case "azure":
+		if cfg.FIFO.ValueBool() {
+			resp.Diagnostics.AddAttributeError(path.Root("fifo"), "unsupported", "Azure Storage queues have no FIFO mode; fifo only applies to aws")
+		}
+		if !cfg.DeadLetterTargetARN.IsNull() {
+			resp.Diagnostics.AddAttributeWarning(path.Root("dead_letter_target_arn"), "dead-letter queue ignored", "Azure Storage queues have no dead-letter queue")
+		}
+		if !cfg.KMSKeyID.IsNull() {
+			resp.Diagnostics.AddAttributeWarning(path.Root("kms_key_id"), "kms_key_id ignored", "customer-managed keys are a storage account setting on Azure")
+		}
+	case "gcp":
+		resp.Diagnostics.AddAttributeError(path.Root("type"), "unsupported cloud", "queues are not implemented on gcp")
+	}
+}
+
+// tuning maps the SQS attribute names to the tuning values in m.
+func (m *queueResourceModel) tuning() map[sqstypes.QueueAttributeName]types.Int64 {
+	return map[sqstypes.QueueAttributeName]types.Int64{
+		sqstypes.QueueAttributeNameMessageRetentionPeriod: m.MessageRetentionSeconds,
+		sqstypes.QueueAttributeNameVisibilityTimeout:      m.VisibilityTimeoutSeconds,
+		sqstypes.QueueAttributeNameMaximumMessageSize:     m.MaxMessageSize,
+	}
+}
+
+// sqsAttributes returns the SQS attributes for the tuning values set in m.
+func (m *queueResourceModel) sqsAttributes() map[string]string {
+	attrs := map[string]string{}
+	for name, v := range m.tuning() {
+		if !v.IsNull() && !v.IsUnknown() {
+			attrs[string(name)] = strconv.FormatInt(v.ValueInt64(), 10)
+		}
+	}
+	if !m.DeadLetterTargetARN.IsNull() && !m.DeadLetterTargetARN.IsUnknown() {
+		policy, _ := json.Marshal(map[string]string{
+			"deadLetterTargetArn": m.DeadLetterTargetARN.ValueString(),
+			"maxReceiveCount":     strconv.FormatInt(m.MaxReceiveCount.ValueInt64(), 10),
+		})
+		attrs[string(sqstypes.QueueAttributeNameRedrivePolicy)] = string(policy)
+	}
+	if key := m.KMSKeyID.ValueString(); key != "" {
+		attrs[string(sqstypes.QueueAttributeNameKmsMasterKeyId)] = key
+	}
+	// standard queues reject the attribute, even when false
+	if m.FIFO.ValueBool() && !m.ContentBasedDedup.IsNull() && !m.ContentBasedDedup.IsUnknown() {
+		attrs[string(sqstypes.QueueAttributeNameContentBasedDeduplication)] = strconv.FormatBool(m.ContentBasedDedup.ValueBool())
 	}
+	return attrs
+}
+
+// setFIFO refreshes fifo and content_based_dedup from the queue. Unset values
+// stay null while the queue has the setting off, the SQS default.
+func (m *queueResourceModel) setFIFO(fifo, dedup bool) {
+	if fifo || !m.FIFO.IsNull() {
+		m.FIFO = types.BoolValue(fifo)
+	}
+	if dedup || !m.ContentBasedDedup.IsNull() {
+		m.ContentBasedDedup = types.BoolValue(dedup)
+	}
+}
+
+// setRedrive refreshes the dead-letter settings in m from a RedrivePolicy
+// attribute; an empty policy means the queue has none. SQS may return the
+// count as a number or a string.
+func (m *queueResourceModel) setRedrive(policy string) {
+	m.DeadLetterTargetARN = types.StringNull()
+	m.MaxReceiveCount = types.Int64Null()
+	if policy == "" {
+		return
+	}
+	var p struct {
+		TargetARN       string          `json:"deadLetterTargetArn"`
+		MaxReceiveCount json.RawMessage `json:"maxReceiveCount"`
+	}
+	if err := json.Unmarshal([]byte(policy), &p); err != nil {
+		return
+	}
+	m.DeadLetterTargetARN = types.StringValue(p.TargetARN)
+	if n, err := strconv.ParseInt(strings.Trim(string(p.MaxReceiveCount), `"`), 10, 64); err == nil {
+		m.MaxReceiveCount = types.Int64Value(n)
+	}
+}
+
+// ignoreTuning resolves unset tuning values to null on clouds without an
+// equivalent, reporting whether any were configured.
+func (m *queueResourceModel) ignoreTuning() bool {
+	for _, v := range []*types.Int64{&m.MessageRetentionSeconds, &m.VisibilityTimeoutSeconds, &m.MaxMessageSize} {
+		if v.IsUnknown() {
+			*v = types.Int64Null()
+		}
+	}
+	return len(m.sqsAttributes()) > 0
+}
+
+// readSQSAttributes refreshes the ARN and tuning values in m from the queue.
+// unknownAsNull clears the computed attributes Create could not read back.
+func (m *queueResourceModel) unknownAsNull() {
+	for _, v := range []*types.String{&m.CloudID, &m.ARN, &m.QueueName} {
+		if v.IsUnknown() {
+			*v = types.StringNull()
+		}
+	}
+	for _, v := range []*types.Int64{&m.MessageRetentionSeconds, &m.VisibilityTimeoutSeconds, &m.MaxMessageSize} {
+		if v.IsUnknown() {
+			*v = types.Int64Null()
+		}
+	}
+}
+
+func (r *QueueResource) readSQSAttributes(ctx context.Context, m *queueResourceModel) error {
+	names := []sqstypes.QueueAttributeName{
+		sqstypes.QueueAttributeNameQueueArn, sqstypes.QueueAttributeNameRedrivePolicy, sqstypes.QueueAttributeNameKmsMasterKeyId,
+		sqstypes.QueueAttributeNameFifoQueue, sqstypes.QueueAttributeNameContentBasedDeduplication,
+	}
+	for name := range m.tuning() {
+		names = append(names, name)
+	}
+	out, err := r.sqs.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{QueueUrl: aws.String(m.ID.ValueString()), AttributeNames: names})
+	if err != nil {
+		return err
+	}
+	parse := func(name sqstypes.QueueAttributeName) types.Int64 {
+		v, err := strconv.ParseInt(out.Attributes[string(name)], 10, 64)
+		if err != nil {
+			return types.Int64Null()
+		}
+		return types.Int64Value(v)
+	}
+	m.MessageRetentionSeconds = parse(sqstypes.QueueAttributeNameMessageRetentionPeriod)
+	m.VisibilityTimeoutSeconds = parse(sqstypes.QueueAttributeNameVisibilityTimeout)
+	m.MaxMessageSize = parse(sqstypes.QueueAttributeNameMaximumMessageSize)
+	m.ARN = types.StringValue(out.Attributes[string(sqstypes.QueueAttributeNameQueueArn)])
+	// the ARN ends in the queue name
+	arn := m.ARN.ValueString()
+	m.QueueName = types.StringValue(arn[strings.LastIndex(arn, ":")+1:])
+	m.setRedrive(out.Attributes[string(sqstypes.QueueAttributeNameRedrivePolicy)])
+	refreshKMSKey(&m.KMSKeyID, out.Attributes[string(sqstypes.QueueAttributeNameKmsMasterKeyId)])
+	m.setFIFO(out.Attributes[string(sqstypes.QueueAttributeNameFifoQueue)] == "true", out.Attributes[string(sqstypes.QueueAttributeNameContentBasedDeduplication)] == "true")
+	return nil
+}
+
+// azureQueueID returns the resource ID of a queue in a storage account.
+func azureQueueID(subID, rgName, acctName, name string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Storage/storageAccounts/%s/queueServices/default/queues/%s",
+		subID, rgName, acctName, name)
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *QueueResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.sqs = c.SQS
+	}
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+// Clouds without an implementation pass so Create can reject them.
+func (r *QueueResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.sqs != nil
+	case "azure":
+		return r.azureRG != nil && r.azureAcct != nil
+	}
+	return true
+}
+
+func (r *QueueResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan queueResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.Account = types.StringNull()
+	plan.ResourceGroup = types.StringNull()
+	plan.AccountCreated = types.BoolNull()
+	plan.ConnectionString = types.StringNull()
 	switch plan.Type.ValueString() {
 	case "aws":
-		if r.sqs == nil {
-			resp.Diagnostics.AddError("aws", "missing client")
-			return
-		}
 		name := plan.Name.ValueString()
-		input := &sqs.CreateQueueInput{QueueName: aws.String(name)}
+		input := &sqs.CreateQueueInput{QueueName: aws.String(name), Attributes: plan.sqsAttributes()}
 		if plan.FIFO.ValueBool() {
 			if !strings.HasSuffix(name, ".fifo") {
 				name += ".fifo"
 			}
 			input.QueueName = aws.String(name)
-			input.Attributes = map[string]string{"FifoQueue": "true"}
+			input.Attributes["FifoQueue"] = "true"
 		}
 		out, err := r.sqs.CreateQueue(ctx, input)
 		if err != nil {
 			resp.Diagnostics.AddError("aws create", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":   aws.ToString(out.QueueUrl),
-			"name": name,
-			"type": plan.Type.ValueString(),
-			"fifo": plan.FIFO.ValueBool(),
-		})
-	case "azure":
-		if r.azureAcct == nil || r.azureRG == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
-			return
-		}
-		rgName := "abstract-rg"
-		if r.azureLoc == "" && plan.Region.ValueString() != "" {
-			r.azureLoc = plan.Region.ValueString()
-		}
-		_, err := r.azureRG.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{Location: &r.azureLoc}, nil)
-		if err != nil {
-			resp.Diagnostics.AddError("azure rg", err.Error())
+		plan.ID = types.StringValue(aws.ToString(out.QueueUrl))
+		if err := r.readSQSAttributes(ctx, &plan); err != nil {
+			resp.Diagnostics.AddError("aws read attributes", err.Error())
+			// keep the queue in state so it is not leaked; Read fills in the rest
+			plan.unknownAsNull()
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 			return
 		}
-		acctName := strings.ToLower(plan.Name.ValueString())
-		if len(acctName) > 24 {
-			acctName = acctName[:24]
-		}
-		poller, err := r.azureAcct.BeginCreate(ctx, rgName, acctName, armstorage.AccountCreateParameters{
-			Location: &r.azureLoc,
-			Kind:     to.Ptr(armstorage.KindStorageV2),
-			SKU:      &armstorage.SKU{Name: to.Ptr(armstorage.SKUNameStandardLRS)},
-		}, nil)
-		if err == nil {
-			_, err = poller.PollUntilDone(ctx, nil)
+	case "azure":
+		if plan.ignoreTuning() {
+			resp.Diagnostics.AddWarning("queue settings ignored", azureQueueTuningWarning)
 		}
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		store := azureStorage{rg: r.azureRG, acct: r.azureAcct, loc: loc, sharedAcct: r.azureSharedAcct, sharedRG: r.azureSharedRG}
+		acctName, rgName, created, err := store.ensure(ctx, plan.Name.ValueString())
 		if err != nil {
 			resp.Diagnostics.AddError("azure create account", err.Error())
 			return
 		}
+		plan.Account = types.StringValue(acctName)
+		plan.ResourceGroup = types.StringValue(rgName)
+		plan.AccountCreated = types.BoolValue(created)
 		keys, err := r.azureAcct.ListKeys(ctx, rgName, acctName, nil)
 		if err != nil || keys.Keys == nil || len(keys.Keys) == 0 {
 			resp.Diagnostics.AddError("azure keys", "unable to get account key")
 			return
 		}
 		key := *keys.Keys[0].Value
+		plan.ConnectionString = types.StringValue(azureConnectionString(acctName, key))
 		cred, err := azqueue.NewSharedKeyCredential(acctName, key)
 		if err != nil {
 			resp.Diagnostics.AddError("azure cred", err.Error())
 			return
 		}
-		svc, err := azqueue.NewServiceClientWithSharedKey(fmt.Sprintf("https://%s.queue.core.windows.net/", acctName), cred, nil)
+		svc, err := azqueue.NewServiceClientWithSharedKeyCredential(fmt.Sprintf("https://%s.queue.core.windows.net/", acctName), cred, nil)
 		if err != nil {
 			resp.Diagnostics.AddError("azure service", err.Error())
 			return
@@ -154,46 +400,47 @@ func (r *QueueResource) Create(ctx context.Context, req resource.CreateRequest,
 			resp.Diagnostics.AddError("azure create queue", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":             plan.Name.ValueString(),
-			"name":           plan.Name.ValueString(),
-			"type":           plan.Type.ValueString(),
-			"region":         r.azureLoc,
-			"fifo":           plan.FIFO.ValueBool(),
-			"account":        acctName,
-			"resource_group": rgName,
-		})
+		plan.ID = types.StringValue(plan.Name.ValueString())
+		plan.QueueName = plan.ID
+		plan.ARN = types.StringValue(azureQueueID(r.azureSubID, rgName, acctName, plan.Name.ValueString()))
 	case "gcp":
 		resp.Diagnostics.AddError("gcp", "queue resource not implemented")
+		return
 	default:
 		resp.Diagnostics.AddError("unsupported cloud", "only aws and azure implemented")
+		return
 	}
+	// the ARN already is the queue's canonical ID on both clouds
+	setCloudID(&resp.Diagnostics, &plan.CloudID, plan.ARN.ValueString(), nil)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
 func (r *QueueResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID            types.String `tfsdk:"id"`
-		Type          types.String `tfsdk:"type"`
-		Account       types.String `tfsdk:"account"`
-		ResourceGroup types.String `tfsdk:"resource_group"`
-	}
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state queueResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.sqs == nil {
-			return
-		}
-		_, err := r.sqs.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{QueueUrl: aws.String(state.ID.ValueString()), AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameQueueArn}})
-		if err != nil {
+		if err := r.readSQSAttributes(ctx, &state); err != nil {
 			resp.State.RemoveResource(ctx)
+			return
 		}
+		resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 	case "azure":
-		if r.azureAcct == nil {
-			return
+		if state.Account.ValueString() == "" || state.ResourceGroup.ValueString() == "" {
+			store := azureStorage{sharedAcct: r.azureSharedAcct, sharedRG: r.azureSharedRG}
+			acctName, rgName := store.names(state.ID.ValueString())
+			state.Account = types.StringValue(acctName)
+			state.ResourceGroup = types.StringValue(rgName)
 		}
 		keys, err := r.azureAcct.ListKeys(ctx, state.ResourceGroup.ValueString(), state.Account.ValueString(), nil)
 		if err != nil || keys.Keys == nil || len(keys.Keys) == 0 {
@@ -206,7 +453,7 @@ func (r *QueueResource) Read(ctx context.Context, req resource.ReadRequest, resp
 			resp.State.RemoveResource(ctx)
 			return
 		}
-		svc, err := azqueue.NewServiceClientWithSharedKey(fmt.Sprintf("https://%s.queue.core.windows.net/", state.Account.ValueString()), cred, nil)
+		svc, err := azqueue.NewServiceClientWithSharedKeyCredential(fmt.Sprintf("https://%s.queue.core.windows.net/", state.Account.ValueString()), cred, nil)
 		if err != nil {
 			resp.State.RemoveResource(ctx)
 			return
@@ -214,39 +461,96 @@ func (r *QueueResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		_, err = svc.NewQueueClient(state.ID.ValueString()).GetProperties(ctx, nil)
 		if err != nil {
 			resp.State.RemoveResource(ctx)
+			return
+		}
+		// a rotated key shows up in the connection string
+		state.ConnectionString = types.StringValue(azureConnectionString(state.Account.ValueString(), key))
+		state.QueueName = state.ID
+		state.ARN = types.StringValue(azureQueueID(r.azureSubID, state.ResourceGroup.ValueString(), state.Account.ValueString(), state.ID.ValueString()))
+		if state.AccountCreated.IsNull() {
+			state.AccountCreated = types.BoolValue(false)
 		}
+		resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 	}
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return state.ARN.ValueString(), nil })
 }
 
 func (r *QueueResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	// no updatable fields for now
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state queueResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = state.ID
+	plan.ARN = state.ARN
+	plan.QueueName = state.QueueName
+	plan.CloudID = state.CloudID
+	plan.Account = state.Account
+	plan.ResourceGroup = state.ResourceGroup
+	plan.AccountCreated = state.AccountCreated
+	plan.ConnectionString = state.ConnectionString
+	switch plan.Type.ValueString() {
+	case "aws":
+		attrs := plan.sqsAttributes()
+		if plan.DeadLetterTargetARN.IsNull() && !state.DeadLetterTargetARN.IsNull() {
+			// an empty policy removes the dead-letter queue
+			attrs[string(sqstypes.QueueAttributeNameRedrivePolicy)] = ""
+		}
+		if plan.KMSKeyID.IsNull() && !state.KMSKeyID.IsNull() {
+			// back to SQS-managed encryption
+			attrs[string(sqstypes.QueueAttributeNameKmsMasterKeyId)] = ""
+			attrs[string(sqstypes.QueueAttributeNameSqsManagedSseEnabled)] = "true"
+		}
+		if plan.FIFO.ValueBool() && plan.ContentBasedDedup.IsNull() && state.ContentBasedDedup.ValueBool() {
+			// unset means the SQS default, off
+			attrs[string(sqstypes.QueueAttributeNameContentBasedDeduplication)] = "false"
+		}
+		if len(attrs) > 0 {
+			_, err := r.sqs.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{QueueUrl: aws.String(state.ID.ValueString()), Attributes: attrs})
+			if err != nil {
+				resp.Diagnostics.AddError("aws update", err.Error())
+				return
+			}
+		}
+		if err := r.readSQSAttributes(ctx, &plan); err != nil {
+			resp.Diagnostics.AddError("aws read attributes", err.Error())
+			return
+		}
+	case "azure":
+		if plan.ignoreTuning() {
+			resp.Diagnostics.AddWarning("queue settings ignored", azureQueueTuningWarning)
+		}
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
 func (r *QueueResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		ID            types.String `tfsdk:"id"`
-		Type          types.String `tfsdk:"type"`
-		Account       types.String `tfsdk:"account"`
-		ResourceGroup types.String `tfsdk:"resource_group"`
-	}
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state queueResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.sqs == nil {
-			return
-		}
 		_, err := r.sqs.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: aws.String(state.ID.ValueString())})
 		if err != nil {
 			resp.Diagnostics.AddError("aws delete", err.Error())
 		}
 	case "azure":
-		if r.azureAcct == nil {
-			return
-		}
 		keys, err := r.azureAcct.ListKeys(ctx, state.ResourceGroup.ValueString(), state.Account.ValueString(), nil)
 		if err != nil || keys.Keys == nil || len(keys.Keys) == 0 {
 			resp.Diagnostics.AddError("azure keys", "unable to get account key")
@@ -258,7 +562,7 @@ func (r *QueueResource) Delete(ctx context.Context, req resource.DeleteRequest,
 			resp.Diagnostics.AddError("azure cred", err.Error())
 			return
 		}
-		svc, err := azqueue.NewServiceClientWithSharedKey(fmt.Sprintf("https://%s.queue.core.windows.net/", state.Account.ValueString()), cred, nil)
+		svc, err := azqueue.NewServiceClientWithSharedKeyCredential(fmt.Sprintf("https://%s.queue.core.windows.net/", state.Account.ValueString()), cred, nil)
 		if err != nil {
 			resp.Diagnostics.AddError("azure service", err.Error())
 			return
@@ -267,6 +571,9 @@ func (r *QueueResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		if err != nil {
 			resp.Diagnostics.AddError("azure delete", err.Error())
 		}
+		if !state.AccountCreated.ValueBool() {
+			return
+		}
 		_, err = r.azureAcct.Delete(ctx, state.ResourceGroup.ValueString(), state.Account.ValueString(), nil)
 		if err != nil {
 			resp.Diagnostics.AddError("azure delete account", err.Error())
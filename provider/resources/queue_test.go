@@ -0,0 +1,314 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func createQueue(t *testing.T, r *QueueResource, vals map[string]tftypes.Value) (queueResourceModel, *resource.CreateResponse) {
+	t.Helper()
+	ctx := context.Background()
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, vals)}, resp)
+	var got queueResourceModel
+	if !resp.Diagnostics.HasError() {
+		resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	}
+	return got, resp
+}
+
+func TestQueueCreateAWSName(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fifo tftypes.Value
+		want string
+	}{
+		{"jobs", tftypes.NewValue(tftypes.Bool, nil), "jobs"},
+		{"jobs", boolean(false), "jobs"},
+		{"jobs", boolean(true), "jobs.fifo"},
+		{"jobs.fifo", boolean(true), "jobs.fifo"},
+	} {
+		t.Run(tc.name+" "+tc.fifo.String(), func(t *testing.T) {
+			sqs := newFakeSQS()
+			r := &QueueResource{sqs: sqs}
+			got, resp := createQueue(t, r, map[string]tftypes.Value{"name": str(tc.name), "type": str("aws"), "fifo": tc.fifo})
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("create: %v", resp.Diagnostics)
+			}
+			if _, ok := sqs.queues[tc.want]; !ok || got.ID.ValueString() != tc.want {
+				t.Errorf("queue %q created with id %q, want %q", got.Name.ValueString(), got.ID.ValueString(), tc.want)
+			}
+			if got.Name.ValueString() != tc.name || got.QueueName.ValueString() != tc.want {
+				t.Errorf("name, queue_name = %q, %q; want %q as configured, %q", got.Name.ValueString(), got.QueueName.ValueString(), tc.name, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueueCreateAWSTuning(t *testing.T) {
+	sqs := newFakeSQS()
+	r := &QueueResource{sqs: sqs}
+	got, resp := createQueue(t, r, map[string]tftypes.Value{
+		"name":                      str("jobs"),
+		"type":                      str("aws"),
+		"message_retention_seconds": number(86400),
+	})
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if v := sqs.queues["jobs"]["MessageRetentionPeriod"]; v != "86400" {
+		t.Errorf("MessageRetentionPeriod = %q, want 86400", v)
+	}
+	if _, ok := sqs.queues["jobs"]["FifoQueue"]; ok {
+		t.Error("FifoQueue set without fifo = true")
+	}
+	// unset values are read back from the queue
+	if got.MessageRetentionSeconds.ValueInt64() != 86400 || got.VisibilityTimeoutSeconds.ValueInt64() != 30 || got.MaxMessageSize.ValueInt64() != 262144 {
+		t.Errorf("tuning = %v, %v, %v", got.MessageRetentionSeconds, got.VisibilityTimeoutSeconds, got.MaxMessageSize)
+	}
+	if !got.Account.IsNull() || !got.ResourceGroup.IsNull() || !got.AccountCreated.IsNull() || !got.ConnectionString.IsNull() {
+		t.Errorf("unexpected azure attributes: %+v", got)
+	}
+	if want := "arn:aws:sqs:us-east-1:123456789012:jobs"; got.ARN.ValueString() != want {
+		t.Errorf("arn = %q, want %q", got.ARN.ValueString(), want)
+	}
+}
+
+func TestAzureQueueID(t *testing.T) {
+	want := "/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Storage/storageAccounts/acct/queueServices/default/queues/jobs"
+	if got := azureQueueID("s", "abstract-rg", "acct", "jobs"); got != want {
+		t.Errorf("id = %q", got)
+	}
+}
+
+func TestQueueCreateAWSError(t *testing.T) {
+	sqs := newFakeSQS()
+	sqs.err = errors.New("QueueAlreadyExists")
+	r := &QueueResource{sqs: sqs}
+	_, resp := createQueue(t, r, map[string]tftypes.Value{"name": str("jobs"), "type": str("aws")})
+	if !resp.Diagnostics.HasError() {
+		t.Fatal("expected error")
+	}
+	if !resp.State.Raw.IsNull() {
+		t.Error("state written after failed create")
+	}
+}
+
+func TestQueueCreateAWSReadError(t *testing.T) {
+	sqs := newFakeSQS()
+	sqs.attrErr = errors.New("AccessDenied")
+	r := &QueueResource{sqs: sqs}
+	_, resp := createQueue(t, r, map[string]tftypes.Value{"name": str("jobs"), "type": str("aws")})
+	if !resp.Diagnostics.HasError() {
+		t.Fatal("expected error")
+	}
+	// the queue exists, so it must stay in state
+	var got queueResourceModel
+	resp.State.Get(context.Background(), &got)
+	if got.ID.ValueString() != "jobs" || !got.ARN.IsNull() {
+		t.Errorf("id, arn = %v, %v; want jobs, null", got.ID, got.ARN)
+	}
+	if !resp.State.Raw.IsFullyKnown() {
+		t.Error("state has unknown values")
+	}
+}
+
+func TestQueueCreateUnsupported(t *testing.T) {
+	for _, cloud := range []string{"aws", "azure", "gcp", "oracle"} {
+		t.Run(cloud, func(t *testing.T) {
+			_, resp := createQueue(t, &QueueResource{}, map[string]tftypes.Value{"name": str("jobs"), "type": str(cloud)})
+			if !resp.Diagnostics.HasError() {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestQueueAWSDeadLetter(t *testing.T) {
+	const dlq = "arn:aws:sqs:us-east-1:123456789012:jobs-dlq"
+	sqs := newFakeSQS()
+	r := &QueueResource{sqs: sqs}
+	vals := map[string]tftypes.Value{"name": str("jobs"), "type": str("aws"), "dead_letter_target_arn": str(dlq), "max_receive_count": number(5)}
+	got, resp := createQueue(t, r, vals)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if v := sqs.queues["jobs"]["RedrivePolicy"]; v != `{"deadLetterTargetArn":"`+dlq+`","maxReceiveCount":"5"}` {
+		t.Errorf("RedrivePolicy = %s", v)
+	}
+	if got.DeadLetterTargetARN.ValueString() != dlq || got.MaxReceiveCount.ValueInt64() != 5 {
+		t.Errorf("dead letter = %v, %v", got.DeadLetterTargetARN, got.MaxReceiveCount)
+	}
+
+	vals["id"] = str("jobs")
+	planned := map[string]tftypes.Value{"id": str("jobs"), "name": str("jobs"), "type": str("aws")}
+	upd := &resource.UpdateResponse{State: testState(t, r, vals)}
+	r.Update(context.Background(), resource.UpdateRequest{Plan: testPlan(t, r, planned), State: testState(t, r, vals)}, upd)
+	if upd.Diagnostics.HasError() {
+		t.Fatalf("update: %v", upd.Diagnostics)
+	}
+	if v := sqs.queues["jobs"]["RedrivePolicy"]; v != "" {
+		t.Errorf("RedrivePolicy = %s after removing the dead-letter queue", v)
+	}
+}
+
+func TestQueueAWSContentBasedDedup(t *testing.T) {
+	sqs := newFakeSQS()
+	r := &QueueResource{sqs: sqs}
+	vals := map[string]tftypes.Value{"name": str("jobs"), "type": str("aws"), "fifo": boolean(true), "content_based_dedup": boolean(true)}
+	got, resp := createQueue(t, r, vals)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	if v := sqs.queues["jobs.fifo"]["ContentBasedDeduplication"]; v != "true" {
+		t.Errorf("ContentBasedDeduplication = %q", v)
+	}
+	if !got.FIFO.ValueBool() || !got.ContentBasedDedup.ValueBool() || got.Name.ValueString() != "jobs" {
+		t.Errorf("fifo, content_based_dedup, name = %v, %v, %v", got.FIFO, got.ContentBasedDedup, got.Name)
+	}
+
+	vals["id"] = str("jobs.fifo")
+	planned := map[string]tftypes.Value{"id": str("jobs.fifo"), "name": str("jobs"), "type": str("aws"), "fifo": boolean(true)}
+	upd := &resource.UpdateResponse{State: testState(t, r, vals)}
+	r.Update(context.Background(), resource.UpdateRequest{Plan: testPlan(t, r, planned), State: testState(t, r, vals)}, upd)
+	if upd.Diagnostics.HasError() {
+		t.Fatalf("update: %v", upd.Diagnostics)
+	}
+	if v := sqs.queues["jobs.fifo"]["ContentBasedDeduplication"]; v != "false" {
+		t.Errorf("ContentBasedDeduplication = %q after unsetting it", v)
+	}
+}
+
+// TestQueueFIFONoDiff refreshes a FIFO queue created from a name without the
+// suffix: every configured attribute must still match the configuration, or
+// the next plan would show a change.
+func TestQueueFIFONoDiff(t *testing.T) {
+	r := &QueueResource{sqs: newFakeSQS()}
+	config := map[string]tftypes.Value{"name": str("jobs"), "type": str("aws"), "fifo": boolean(true), "content_based_dedup": boolean(true)}
+	created, resp := createQueue(t, r, config)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	ctx := context.Background()
+	read := &resource.ReadResponse{State: resp.State}
+	r.Read(ctx, resource.ReadRequest{State: resp.State}, read)
+	if read.Diagnostics.HasError() {
+		t.Fatalf("read: %v", read.Diagnostics)
+	}
+	if !read.State.Raw.Equal(resp.State.Raw) {
+		t.Errorf("refresh changed the state:\n%v\nwant\n%v", read.State.Raw, resp.State.Raw)
+	}
+	for name, want := range config {
+		var got attr.Value
+		read.Diagnostics.Append(read.State.GetAttribute(ctx, path.Root(name), &got)...)
+		if v, _ := got.ToTerraformValue(ctx); !v.Equal(want) {
+			t.Errorf("%s = %v, configured %v", name, v, want)
+		}
+	}
+	if created.QueueName.ValueString() != "jobs.fifo" {
+		t.Errorf("queue_name = %q", created.QueueName.ValueString())
+	}
+}
+
+func TestQueueReadFIFO(t *testing.T) {
+	sqs := newFakeSQS()
+	r := &QueueResource{sqs: sqs}
+	if _, resp := createQueue(t, r, map[string]tftypes.Value{"name": str("jobs"), "type": str("aws")}); resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	sqs.queues["orders.fifo"] = map[string]string{"FifoQueue": "true", "ContentBasedDeduplication": "true"}
+	cases := []struct {
+		name  string
+		vals  map[string]tftypes.Value
+		fifo  types.Bool
+		dedup types.Bool
+	}{
+		// the SQS defaults leave unset attributes null
+		{"standard", map[string]tftypes.Value{"id": str("jobs"), "name": str("jobs"), "type": str("aws")}, types.BoolNull(), types.BoolNull()},
+		{"standard set false", map[string]tftypes.Value{"id": str("jobs"), "name": str("jobs"), "type": str("aws"), "fifo": boolean(false)}, types.BoolValue(false), types.BoolNull()},
+		// dedup turned on outside Terraform shows up as drift
+		{"fifo", map[string]tftypes.Value{"id": str("orders.fifo"), "name": str("orders"), "type": str("aws"), "fifo": boolean(true)}, types.BoolValue(true), types.BoolValue(true)},
+		// a queue recreated as a standard queue is no longer FIFO
+		{"no longer fifo", map[string]tftypes.Value{"id": str("jobs"), "name": str("jobs"), "type": str("aws"), "fifo": boolean(true)}, types.BoolValue(false), types.BoolNull()},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ReadResponse{State: testState(t, r, tc.vals)}
+			r.Read(context.Background(), resource.ReadRequest{State: testState(t, r, tc.vals)}, resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("read: %v", resp.Diagnostics)
+			}
+			var got queueResourceModel
+			resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+			if !got.FIFO.Equal(tc.fifo) || !got.ContentBasedDedup.Equal(tc.dedup) {
+				t.Errorf("fifo, content_based_dedup = %v, %v; want %v, %v", got.FIFO, got.ContentBasedDedup, tc.fifo, tc.dedup)
+			}
+		})
+	}
+}
+
+func TestQueueSetRedrive(t *testing.T) {
+	var m queueResourceModel
+	m.setRedrive(`{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:1:dlq","maxReceiveCount":3}`)
+	if m.DeadLetterTargetARN.ValueString() != "arn:aws:sqs:us-east-1:1:dlq" || m.MaxReceiveCount.ValueInt64() != 3 {
+		t.Errorf("numeric count = %v, %v", m.DeadLetterTargetARN, m.MaxReceiveCount)
+	}
+	m.setRedrive("")
+	if !m.DeadLetterTargetARN.IsNull() || !m.MaxReceiveCount.IsNull() {
+		t.Errorf("empty policy = %v, %v", m.DeadLetterTargetARN, m.MaxReceiveCount)
+	}
+}
+
+func TestQueueConfig(t *testing.T) {
+	r := &QueueResource{}
+	s := testSchema(t, r)
+	cases := []struct {
+		name string
+		vals map[string]tftypes.Value
+		errs bool
+	}{
+		{"dead letter", map[string]tftypes.Value{"name": str("q"), "type": str("aws"), "dead_letter_target_arn": str("arn"), "max_receive_count": number(3)}, false},
+		{"no receive count", map[string]tftypes.Value{"name": str("q"), "type": str("aws"), "dead_letter_target_arn": str("arn")}, true},
+		{"zero receive count", map[string]tftypes.Value{"name": str("q"), "type": str("aws"), "dead_letter_target_arn": str("arn"), "max_receive_count": number(0)}, true},
+		{"count without target", map[string]tftypes.Value{"name": str("q"), "type": str("aws"), "max_receive_count": number(3)}, true},
+		{"azure dead letter", map[string]tftypes.Value{"name": str("q"), "type": str("azure"), "dead_letter_target_arn": str("arn"), "max_receive_count": number(3)}, false},
+		{"fifo dedup", map[string]tftypes.Value{"name": str("q"), "type": str("aws"), "fifo": boolean(true), "content_based_dedup": boolean(true)}, false},
+		{"dedup without fifo", map[string]tftypes.Value{"name": str("q"), "type": str("aws"), "content_based_dedup": boolean(true)}, true},
+		{"dedup off without fifo", map[string]tftypes.Value{"name": str("q"), "type": str("aws"), "content_based_dedup": boolean(false)}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestAzureStorageNames(t *testing.T) {
+	if acct, rg := (azureStorage{}).names("Orders-Queue-For-Invoicing-Jobs"); acct != "orders-queue-for-invoici" || rg != "abstract-rg" {
+		t.Errorf("dedicated = %q, %q", acct, rg)
+	}
+	if acct, rg := (azureStorage{sharedAcct: "shared"}).names("orders"); acct != "shared" || rg != "abstract-rg" {
+		t.Errorf("shared = %q, %q", acct, rg)
+	}
+	if acct, rg := (azureStorage{sharedAcct: "shared", sharedRG: "storage"}).names("orders"); acct != "shared" || rg != "storage" {
+		t.Errorf("shared with group = %q, %q", acct, rg)
+	}
+	if acct, ok := connectionStringAccount(azureConnectionString("funcs", "a2V5")); !ok || acct != "funcs" {
+		t.Errorf("connection string account = %q, %v", acct, ok)
+	}
+	if _, ok := connectionStringAccount("UseDevelopmentStorage=true"); ok {
+		t.Errorf("account found in a connection string without one")
+	}
+}
@@ -2,15 +2,17 @@ package resources
 
 import (
 	"context"
-	"fmt"
+	"time"
 
 	"abstract-provider/provider/shared"
+	"abstract-provider/provider/shared/naming"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/types"
@@ -24,6 +26,22 @@ type RegistryResource struct {
 	azureCred azcore.TokenCredential
 	azureSub  string
 	azureLoc  string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+}
+
+type registryResourceModel struct {
+	ID            types.String `tfsdk:"id"`
+	CloudID       types.String `tfsdk:"cloud_id"`
+	Name          types.String `tfsdk:"name"`
+	Type          types.String `tfsdk:"type"`
+	Region        types.String `tfsdk:"region"`
+	LoginServer   types.String `tfsdk:"login_server"`
+	ResourceGroup types.String `tfsdk:"resource_group"`
 }
 
 // NewRegistryResource returns a new registry resource.
@@ -39,6 +57,8 @@ func (r *RegistryResource) Configure(ctx context.Context, req resource.Configure
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
 	r.ecr = cfg.AWSECR
 	r.azureRG = cfg.AzureRGClient
 	r.azureReg = cfg.AzureRegistryClient
@@ -62,67 +82,84 @@ func (r *RegistryResource) Schema(ctx context.Context, req resource.SchemaReques
 			"region":         schema.StringAttribute{Optional: true},
 			"login_server":   schema.StringAttribute{Computed: true},
 			"resource_group": schema.StringAttribute{Computed: true},
+			"cloud_id":       cloudIDAttribute(),
 		},
 	}
 }
 
+// useRegion points the AWS clients at the resource's region.
+func (r *RegistryResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.ecr = c.ECR
+	}
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+// Clouds without an implementation pass so Create can reject them.
+func (r *RegistryResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.ecr != nil
+	case "azure":
+		return r.azureRG != nil && r.azureReg != nil
+	}
+	return true
+}
+
 // Create provisions a container registry.
 func (r *RegistryResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name   types.String `tfsdk:"name"`
-		Type   types.String `tfsdk:"type"`
-		Region types.String `tfsdk:"region"`
-	}
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan registryResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.LoginServer = types.StringNull()
+	plan.ResourceGroup = types.StringNull()
 
 	switch plan.Type.ValueString() {
 	case "aws":
-		if r.ecr == nil {
-			resp.Diagnostics.AddError("aws", "missing client")
-			return
-		}
 		out, err := r.ecr.CreateRepository(ctx, &ecr.CreateRepositoryInput{RepositoryName: aws.String(plan.Name.ValueString())})
 		if err != nil {
 			resp.Diagnostics.AddError("aws create", err.Error())
 			return
 		}
-		arn := aws.ToString(out.Repository.RepositoryArn)
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":   arn,
-			"name": plan.Name.ValueString(),
-			"type": plan.Type.ValueString(),
-		})
+		plan.ID = types.StringValue(aws.ToString(out.Repository.RepositoryArn))
 	case "azure":
-		if r.azureReg == nil || r.azureRG == nil {
-			resp.Diagnostics.AddError("azure", "missing client")
+		regName, err := naming.AzureRegistry(plan.Name.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("name"), "invalid name", err.Error())
 			return
 		}
 		rgName := "abstract-rg"
-		if r.azureLoc == "" && plan.Region.ValueString() != "" {
-			r.azureLoc = plan.Region.ValueString()
-		}
-		_, err := r.azureRG.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{Location: &r.azureLoc}, nil)
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		err = shared.EnsureResourceGroup(ctx, r.azureRG, rgName, loc)
 		if err != nil {
 			resp.Diagnostics.AddError("azure rg", err.Error())
 			return
 		}
-		poller, err := r.azureReg.BeginCreate(ctx, rgName, plan.Name.ValueString(), armcontainerregistry.Registry{
-			Location: &r.azureLoc,
+		poller, err := r.azureReg.BeginCreate(ctx, rgName, regName, armcontainerregistry.Registry{
+			Location: &loc,
 			SKU:      &armcontainerregistry.SKU{Name: to.Ptr(armcontainerregistry.SKUNameBasic)},
 		}, nil)
 		if err == nil {
-			_, err = poller.PollUntilDone(ctx, nil)
+			_, err = shared.PollAzure(ctx, poller)
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure create", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure create", err)
 			return
 		}
 		// fetch properties to get login server
-		reg, err := r.azureReg.Get(ctx, rgName, plan.Name.ValueString(), nil)
+		reg, err := r.azureReg.Get(ctx, rgName, regName, nil)
 		if err != nil {
 			resp.Diagnostics.AddError("azure get", err.Error())
 			return
@@ -131,90 +168,104 @@ func (r *RegistryResource) Create(ctx context.Context, req resource.CreateReques
 		if reg.Properties != nil && reg.Properties.LoginServer != nil {
 			login = *reg.Properties.LoginServer
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":             *reg.ID,
-			"name":           plan.Name.ValueString(),
-			"type":           plan.Type.ValueString(),
-			"region":         r.azureLoc,
-			"login_server":   login,
-			"resource_group": rgName,
-		})
+		plan.ID = types.StringValue(*reg.ID)
+		plan.LoginServer = types.StringValue(login)
+		plan.ResourceGroup = types.StringValue(rgName)
 	case "gcp":
 		resp.Diagnostics.AddError("gcp", "registry resource not implemented")
+		return
 	default:
 		resp.Diagnostics.AddError("unsupported cloud", "only aws and azure implemented")
+		return
 	}
+	// the ID already is the repository's ARN or the registry's resource ID
+	setCloudID(&resp.Diagnostics, &plan.CloudID, plan.ID.ValueString(), nil)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// azureRegistryName returns the registry name used on Azure for the configured name.
+func azureRegistryName(name string) string {
+	// Create already rejected names that cannot be normalized
+	regName, _ := naming.AzureRegistry(name)
+	return regName
 }
 
-// Read verifies the registry still exists.
+// Read verifies the registry still exists and refreshes its computed
+// attributes.
 func (r *RegistryResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID            types.String `tfsdk:"id"`
-		Type          types.String `tfsdk:"type"`
-		Name          types.String `tfsdk:"name"`
-		ResourceGroup types.String `tfsdk:"resource_group"`
-	}
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state registryResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.ecr == nil {
-			return
-		}
-		_, err := r.ecr.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{RepositoryNames: []string{state.Name.ValueString()}})
-		if err != nil {
+		out, err := r.ecr.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{RepositoryNames: []string{state.Name.ValueString()}})
+		if err != nil || len(out.Repositories) == 0 {
 			resp.State.RemoveResource(ctx)
+			return
 		}
+		state.ID = types.StringValue(aws.ToString(out.Repositories[0].RepositoryArn))
 	case "azure":
-		if r.azureReg == nil {
-			return
+		if state.ResourceGroup.ValueString() == "" {
+			state.ResourceGroup = types.StringValue("abstract-rg")
 		}
-		_, err := r.azureReg.Get(ctx, state.ResourceGroup.ValueString(), state.Name.ValueString(), nil)
+		reg, err := r.azureReg.Get(ctx, state.ResourceGroup.ValueString(), azureRegistryName(state.Name.ValueString()), nil)
 		if err != nil {
 			resp.State.RemoveResource(ctx)
+			return
+		}
+		if reg.ID != nil {
+			state.ID = types.StringValue(*reg.ID)
+		}
+		if reg.Properties != nil && reg.Properties.LoginServer != nil {
+			state.LoginServer = types.StringValue(*reg.Properties.LoginServer)
 		}
 	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return state.ID.ValueString(), nil })
 }
 
 // Update has no updatable fields currently.
 func (r *RegistryResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
 }
 
 // Delete removes the registry.
 func (r *RegistryResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		ID            types.String `tfsdk:"id"`
-		Type          types.String `tfsdk:"type"`
-		Name          types.String `tfsdk:"name"`
-		ResourceGroup types.String `tfsdk:"resource_group"`
-	}
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state registryResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.ecr == nil {
-			return
-		}
-		_, err := r.ecr.DeleteRepository(ctx, &ecr.DeleteRepositoryInput{RepositoryName: aws.String(state.Name.ValueString()), Force: aws.Bool(true)})
+		_, err := r.ecr.DeleteRepository(ctx, &ecr.DeleteRepositoryInput{RepositoryName: aws.String(state.Name.ValueString()), Force: true})
 		if err != nil {
 			resp.Diagnostics.AddError("aws delete", err.Error())
 		}
 	case "azure":
-		if r.azureReg == nil {
-			return
-		}
-		poller, err := r.azureReg.BeginDelete(ctx, state.ResourceGroup.ValueString(), state.Name.ValueString(), nil)
+		poller, err := r.azureReg.BeginDelete(ctx, state.ResourceGroup.ValueString(), azureRegistryName(state.Name.ValueString()), nil)
 		if err == nil {
-			_, err = poller.PollUntilDone(ctx, nil)
+			_, err = shared.PollAzure(ctx, poller)
 		}
 		if err != nil {
-			resp.Diagnostics.AddError("azure delete", err.Error())
+			shared.AddAzureError(&resp.Diagnostics, "azure delete", err)
 		}
 	}
 }
@@ -0,0 +1,45 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestRegistryReadAzure(t *testing.T) {
+	const id = "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.ContainerRegistry/registries/images"
+	arm := newFakeARM()
+	arm.notFound = true
+	arm.puts[id] = map[string]any{"id": id, "properties": map[string]any{"loginServer": "images.azurecr.io"}}
+	r := &RegistryResource{}
+	var err error
+	if r.azureRG, err = armresources.NewResourceGroupsClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	if r.azureReg, err = armcontainerregistry.NewRegistriesClient("sub", fakeCredential{}, arm.options()); err != nil {
+		t.Fatal(err)
+	}
+	// state as left by an import: only the identifying attributes are known
+	state := testState(t, r, map[string]tftypes.Value{"id": str("images"), "name": str("images"), "type": str("azure")})
+	resp := &resource.ReadResponse{State: state}
+	r.Read(context.Background(), resource.ReadRequest{State: state}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("read: %v", resp.Diagnostics)
+	}
+	var got registryResourceModel
+	resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+	if got.ID.ValueString() != id || got.LoginServer.ValueString() != "images.azurecr.io" || got.ResourceGroup.ValueString() != "abstract-rg" {
+		t.Errorf("state = %+v", got)
+	}
+
+	delete(arm.puts, id)
+	resp = &resource.ReadResponse{State: state}
+	r.Read(context.Background(), resource.ReadRequest{State: state}, resp)
+	if !resp.State.Raw.IsNull() {
+		t.Errorf("deleted registry kept in state")
+	}
+}
@@ -6,13 +6,19 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"abstract-provider/provider/shared"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/types"
@@ -24,6 +30,24 @@ type SecretResource struct {
 	azureCred azcore.TokenCredential
 	gcp       *secretmanager.Service
 	gcpProj   string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+}
+
+type secretResourceModel struct {
+	ID             types.String `tfsdk:"id"`
+	CloudID        types.String `tfsdk:"cloud_id"`
+	Name           types.String `tfsdk:"name"`
+	Type           types.String `tfsdk:"type"`
+	Value          types.String `tfsdk:"value"`
+	ValueBase64    types.String `tfsdk:"value_base64"`
+	ReplicaRegions types.List   `tfsdk:"replica_regions"`
+	ReplicaStatus  types.Map    `tfsdk:"replica_status"`
+
+	ReplicationLocations types.List `tfsdk:"replication_locations"`
+
+	KMSKeyID types.String `tfsdk:"kms_key_id"`
 }
 
 func NewSecretResource() resource.Resource { return &SecretResource{} }
@@ -37,6 +61,7 @@ func (r *SecretResource) Configure(ctx context.Context, req resource.ConfigureRe
 		resp.Diagnostics.AddError("invalid provider data", "")
 		return
 	}
+	r.timeout = cfg.RequestTimeout
 	r.sm = cfg.AWSSM
 	r.azureCred = cfg.AzureCred
 	r.gcp = cfg.GCPSecrets
@@ -50,49 +75,276 @@ func (r *SecretResource) Metadata(ctx context.Context, req resource.MetadataRequ
 func (r *SecretResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
-			"id":    schema.StringAttribute{Computed: true},
-			"name":  schema.StringAttribute{Required: true},
-			"type":  schema.StringAttribute{Required: true},
-			"value": schema.StringAttribute{Required: true, Sensitive: true},
+			"id":   schema.StringAttribute{Computed: true},
+			"name": schema.StringAttribute{Required: true},
+			"type": schema.StringAttribute{Required: true},
+			// Exactly one of value and value_base64 is set.
+			"value":        schema.StringAttribute{Optional: true, Sensitive: true},
+			"value_base64": schema.StringAttribute{Optional: true, Sensitive: true},
+			// AWS regions the secret is replicated to; replica_status maps each to its replication status.
+			"replica_regions": schema.ListAttribute{ElementType: types.StringType, Optional: true},
+			"replica_status":  schema.MapAttribute{ElementType: types.StringType, Computed: true},
+			// GCP regions for user-managed replication; unset means automatic replication.
+			"replication_locations": schema.ListAttribute{ElementType: types.StringType, Optional: true},
+			// Customer-managed key: a KMS key on AWS or a Cloud KMS key name on GCP; unset uses the cloud-managed key.
+			"kms_key_id": schema.StringAttribute{Optional: true},
+			// Update may recreate the secret, so cloud_id is not carried over from state.
+			"cloud_id": schema.StringAttribute{Computed: true},
 		},
 	}
 }
 
-func (r *SecretResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var plan struct {
-		Name  types.String `tfsdk:"name"`
-		Type  types.String `tfsdk:"type"`
-		Value types.String `tfsdk:"value"`
+// cloudID is the secret's ARN on AWS, its identifier in the vault on Azure
+// and its full name on GCP.
+func (m *secretResourceModel) cloudID() string {
+	id := m.ID.ValueString()
+	if m.Type.ValueString() == "azure" {
+		vaultURL, name, ok := strings.Cut(id, "#")
+		if !ok {
+			return ""
+		}
+		return strings.TrimSuffix(vaultURL, "/") + "/secrets/" + name
+	}
+	return id
+}
+
+func (r *SecretResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg secretResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if cfg.Value.IsNull() == cfg.ValueBase64.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("value"), "invalid value", "exactly one of value and value_base64 must be set")
+	}
+	if v := cfg.ValueBase64; !v.IsNull() && !v.IsUnknown() {
+		if _, err := base64.StdEncoding.DecodeString(v.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("value_base64"), "invalid base64", err.Error())
+		}
+	}
+	if !cfg.KMSKeyID.IsNull() && cfg.Type.ValueString() == "azure" {
+		resp.Diagnostics.AddAttributeWarning(path.Root("kms_key_id"), "kms_key_id ignored", "Key Vault encrypts secrets with the vault's own keys")
+	}
+	if cfg.ReplicationLocations.IsNull() || cfg.ReplicationLocations.IsUnknown() {
+		return
+	}
+	if !cfg.KMSKeyID.IsNull() && len(cfg.ReplicationLocations.Elements()) > 1 && cfg.Type.ValueString() == "gcp" {
+		// each replica needs a key in its own location
+		resp.Diagnostics.AddAttributeError(path.Root("kms_key_id"), "regional key", "a Cloud KMS key is regional, so kms_key_id allows at most one replication location")
+	}
+	if t := cfg.Type.ValueString(); t != "gcp" {
+		resp.Diagnostics.AddAttributeWarning(path.Root("replication_locations"), "replication_locations ignored", "replication_locations only applies to gcp")
+		return
+	}
+	if len(cfg.ReplicationLocations.Elements()) == 0 {
+		resp.Diagnostics.AddAttributeError(path.Root("replication_locations"), "no locations", "replication_locations needs at least one region; omit it for automatic replication")
+	}
+}
+
+// azureBinaryContentType marks a Key Vault secret whose value is base64 of
+// binary data, so Read can tell it apart from a string secret.
+const azureBinaryContentType = "application/octet-stream;base64"
+
+// payload returns the secret value as bytes.
+func (m *secretResourceModel) payload() ([]byte, error) {
+	if !m.ValueBase64.IsNull() {
+		return base64.StdEncoding.DecodeString(m.ValueBase64.ValueString())
+	}
+	return []byte(m.Value.ValueString()), nil
+}
+
+// setPayload records data in value_base64 if binary is set, or in value otherwise.
+func (m *secretResourceModel) setPayload(data []byte, binary bool) {
+	if binary {
+		m.Value = types.StringNull()
+		m.ValueBase64 = types.StringValue(base64.StdEncoding.EncodeToString(data))
+		return
+	}
+	m.Value = types.StringValue(string(data))
+	m.ValueBase64 = types.StringNull()
+}
+
+// replicas returns the AWS replica regions.
+func (m *secretResourceModel) replicas(ctx context.Context) ([]string, diag.Diagnostics) {
+	var regions []string
+	diags := m.ReplicaRegions.ElementsAs(ctx, &regions, false)
+	return regions, diags
+}
+
+// refreshList records the regions read back from the cloud in list. The
+// order already in list is kept when the regions are the same, since the
+// APIs do not return them in the order they were given.
+func refreshList(ctx context.Context, list *types.List, regions []string) diag.Diagnostics {
+	var have []string
+	diags := list.ElementsAs(ctx, &have, false)
+	if add, remove := diffStrings(have, regions); len(add) == 0 && len(remove) == 0 {
+		return diags
+	}
+	l, d := types.ListValueFrom(ctx, types.StringType, regions)
+	diags.Append(d...)
+	*list = l
+	return diags
+}
+
+// gcpReplication returns user-managed replication to locations, or automatic
+// replication if there are none, encrypted with key unless it is empty.
+func gcpReplication(locations []string, key string) *secretmanager.Replication {
+	var cmek *secretmanager.CustomerManagedEncryption
+	if key != "" {
+		cmek = &secretmanager.CustomerManagedEncryption{KmsKeyName: key}
+	}
+	if len(locations) == 0 {
+		return &secretmanager.Replication{Automatic: &secretmanager.Automatic{CustomerManagedEncryption: cmek}}
+	}
+	um := &secretmanager.UserManaged{}
+	for _, loc := range locations {
+		um.Replicas = append(um.Replicas, &secretmanager.Replica{Location: loc, CustomerManagedEncryption: cmek})
+	}
+	return &secretmanager.Replication{UserManaged: um}
+}
+
+// gcpReplicationKey returns the Cloud KMS key replication encrypts with, or
+// "" for a Google-managed key.
+func gcpReplicationKey(rep *secretmanager.Replication) string {
+	switch {
+	case rep == nil:
+	case rep.Automatic != nil && rep.Automatic.CustomerManagedEncryption != nil:
+		return rep.Automatic.CustomerManagedEncryption.KmsKeyName
+	case rep.UserManaged != nil && len(rep.UserManaged.Replicas) > 0 && rep.UserManaged.Replicas[0].CustomerManagedEncryption != nil:
+		return rep.UserManaged.Replicas[0].CustomerManagedEncryption.KmsKeyName
+	}
+	return ""
+}
+
+// syncSecretReplicas adds and removes replica regions so the secret is replicated to exactly want.
+func (r *SecretResource) syncSecretReplicas(ctx context.Context, id string, have, want []string) error {
+	current := map[string]bool{}
+	for _, region := range have {
+		current[region] = true
+	}
+	wanted := map[string]bool{}
+	var add []smtypes.ReplicaRegionType
+	for _, region := range want {
+		wanted[region] = true
+		if !current[region] {
+			add = append(add, smtypes.ReplicaRegionType{Region: aws.String(region)})
+		}
+	}
+	var remove []string
+	for _, region := range have {
+		if !wanted[region] {
+			remove = append(remove, region)
+		}
 	}
+	if len(add) > 0 {
+		_, err := r.sm.ReplicateSecretToRegions(ctx, &secretsmanager.ReplicateSecretToRegionsInput{
+			SecretId:          aws.String(id),
+			AddReplicaRegions: add,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		_, err := r.sm.RemoveRegionsFromReplication(ctx, &secretsmanager.RemoveRegionsFromReplicationInput{
+			SecretId:             aws.String(id),
+			RemoveReplicaRegions: remove,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// secretReplicaStatus returns the replica regions of an AWS secret, the
+// replication status of each and the secret's KMS key.
+func (r *SecretResource) secretReplicaStatus(ctx context.Context, id string) ([]string, types.Map, string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	out, err := r.sm.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(id)})
+	if err != nil {
+		diags.AddError("aws describe", err.Error())
+		return nil, types.MapNull(types.StringType), "", diags
+	}
+	var regions []string
+	statuses := map[string]attr.Value{}
+	for _, rs := range out.ReplicationStatus {
+		regions = append(regions, aws.ToString(rs.Region))
+		statuses[aws.ToString(rs.Region)] = types.StringValue(string(rs.Status))
+	}
+	m, d := types.MapValue(types.StringType, statuses)
+	diags.Append(d...)
+	return regions, m, aws.ToString(out.KmsKeyId), diags
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *SecretResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.sm != nil
+	case "azure":
+		return r.azureCred != nil
+	case "gcp":
+		return r.gcp != nil
+	}
+	return false
+}
+
+func (r *SecretResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan secretResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ReplicaStatus = types.MapNull(types.StringType)
+	plan.CloudID = types.StringNull()
+	data, err := plan.payload()
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("value_base64"), "invalid base64", err.Error())
+		return
+	}
+	replicas, d := plan.replicas(ctx)
+	resp.Diagnostics.Append(d...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if len(replicas) > 0 && plan.Type.ValueString() != "aws" {
+		resp.Diagnostics.AddWarning("replica_regions ignored", "secret replication is only managed on aws")
+	}
 	switch plan.Type.ValueString() {
 	case "aws":
-		if r.sm == nil {
-			resp.Diagnostics.AddError("aws", "missing client")
-			return
+		in := &secretsmanager.CreateSecretInput{Name: aws.String(plan.Name.ValueString())}
+		if key := plan.KMSKeyID.ValueString(); key != "" {
+			in.KmsKeyId = aws.String(key)
 		}
-		out, err := r.sm.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
-			Name:         aws.String(plan.Name.ValueString()),
-			SecretString: aws.String(plan.Value.ValueString()),
-		})
+		if plan.ValueBase64.IsNull() {
+			in.SecretString = aws.String(plan.Value.ValueString())
+		} else {
+			in.SecretBinary = data
+		}
+		out, err := r.sm.CreateSecret(ctx, in)
 		if err != nil {
 			resp.Diagnostics.AddError("aws create", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":   aws.ToString(out.ARN),
-			"name": plan.Name.ValueString(),
-			"type": plan.Type.ValueString(),
-		})
-	case "azure":
-		if r.azureCred == nil {
-			resp.Diagnostics.AddError("azure", "missing credential")
+		plan.ID = types.StringValue(aws.ToString(out.ARN))
+		if err := r.syncSecretReplicas(ctx, plan.ID.ValueString(), nil, replicas); err != nil {
+			resp.Diagnostics.AddError("aws replicate", err.Error())
+			// keep the primary in state so it is cleaned up on destroy
+			plan.ReplicaRegions = types.ListNull(types.StringType)
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 			return
 		}
+		_, status, _, d := r.secretReplicaStatus(ctx, plan.ID.ValueString())
+		resp.Diagnostics.Append(d...)
+		plan.ReplicaStatus = status
+	case "azure":
 		vaultURL := os.Getenv("AZURE_KEY_VAULT_URL")
 		if vaultURL == "" {
 			resp.Diagnostics.AddError("azure", "AZURE_KEY_VAULT_URL not set")
@@ -103,68 +355,81 @@ func (r *SecretResource) Create(ctx context.Context, req resource.CreateRequest,
 			resp.Diagnostics.AddError("azure client", err.Error())
 			return
 		}
-		_, err = client.SetSecret(ctx, plan.Name.ValueString(), plan.Value.ValueString(), nil)
+		params := azsecrets.SetSecretParameters{Value: to.Ptr(plan.Value.ValueString())}
+		if !plan.ValueBase64.IsNull() {
+			params = azsecrets.SetSecretParameters{Value: to.Ptr(plan.ValueBase64.ValueString()), ContentType: to.Ptr(azureBinaryContentType)}
+		}
+		_, err = client.SetSecret(ctx, plan.Name.ValueString(), params, nil)
 		if err != nil {
 			resp.Diagnostics.AddError("azure set", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":   fmt.Sprintf("%s#%s", vaultURL, plan.Name.ValueString()),
-			"name": plan.Name.ValueString(),
-			"type": plan.Type.ValueString(),
-		})
+		plan.ID = types.StringValue(fmt.Sprintf("%s#%s", vaultURL, plan.Name.ValueString()))
 	case "gcp":
-		if r.gcp == nil {
-			resp.Diagnostics.AddError("gcp", "missing client")
+		var locations []string
+		resp.Diagnostics.Append(plan.ReplicationLocations.ElementsAs(ctx, &locations, false)...)
+		if resp.Diagnostics.HasError() {
 			return
 		}
 		parent := fmt.Sprintf("projects/%s", r.gcpProj)
-		sec := &secretmanager.Secret{Replication: &secretmanager.Replication{Automatic: &secretmanager.Automatic{}}}
+		sec := &secretmanager.Secret{Replication: gcpReplication(locations, plan.KMSKeyID.ValueString())}
 		_, err := r.gcp.Projects.Secrets.Create(parent, sec).SecretId(plan.Name.ValueString()).Context(ctx).Do()
 		if err != nil && !strings.Contains(err.Error(), "Already exists") {
 			resp.Diagnostics.AddError("gcp create", err.Error())
 			return
 		}
-		payload := &secretmanager.SecretPayload{Data: base64.StdEncoding.EncodeToString([]byte(plan.Value.ValueString()))}
+		payload := &secretmanager.SecretPayload{Data: base64.StdEncoding.EncodeToString(data)}
 		_, err = r.gcp.Projects.Secrets.AddVersion(fmt.Sprintf("projects/%s/secrets/%s", r.gcpProj, plan.Name.ValueString()), &secretmanager.AddSecretVersionRequest{Payload: payload}).Context(ctx).Do()
 		if err != nil {
 			resp.Diagnostics.AddError("gcp version", err.Error())
 			return
 		}
-		resp.State.Set(ctx, map[string]interface{}{
-			"id":   fmt.Sprintf("%s/secrets/%s", parent, plan.Name.ValueString()),
-			"name": plan.Name.ValueString(),
-			"type": plan.Type.ValueString(),
-		})
+		plan.ID = types.StringValue(fmt.Sprintf("%s/secrets/%s", parent, plan.Name.ValueString()))
 	default:
 		resp.Diagnostics.AddError("unsupported cloud", "")
+		return
 	}
+	setCloudID(&resp.Diagnostics, &plan.CloudID, plan.cloudID(), nil)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
 func (r *SecretResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var state struct {
-		ID   types.String `tfsdk:"id"`
-		Name types.String `tfsdk:"name"`
-		Type types.String `tfsdk:"type"`
-	}
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state secretResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.sm == nil {
-			return
-		}
-		_, err := r.sm.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(state.Name.ValueString())})
+		out, err := r.sm.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(state.Name.ValueString())})
 		if err != nil {
 			resp.State.RemoveResource(ctx)
+			return
 		}
-	case "azure":
-		if r.azureCred == nil {
+		if out.SecretBinary != nil {
+			state.setPayload(out.SecretBinary, true)
+		} else {
+			state.setPayload([]byte(aws.ToString(out.SecretString)), false)
+		}
+		regions, status, key, d := r.secretReplicaStatus(ctx, state.Name.ValueString())
+		resp.Diagnostics.Append(d...)
+		if d.HasError() {
 			return
 		}
+		// replicas added or removed outside Terraform show up as drift
+		if len(regions) > 0 || !state.ReplicaRegions.IsNull() {
+			resp.Diagnostics.Append(refreshList(ctx, &state.ReplicaRegions, regions)...)
+		}
+		state.ReplicaStatus = status
+		refreshKMSKey(&state.KMSKeyID, key)
+		resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	case "azure":
 		vaultURL := os.Getenv("AZURE_KEY_VAULT_URL")
 		if vaultURL == "" {
 			resp.State.RemoveResource(ctx)
@@ -175,32 +440,100 @@ func (r *SecretResource) Read(ctx context.Context, req resource.ReadRequest, res
 			resp.State.RemoveResource(ctx)
 			return
 		}
-		_, err = client.GetSecret(ctx, state.Name.ValueString(), nil)
+		sec, err := client.GetSecret(ctx, state.Name.ValueString(), "", nil)
 		if err != nil {
 			resp.State.RemoveResource(ctx)
+			return
+		}
+		if sec.ContentType != nil && *sec.ContentType == azureBinaryContentType {
+			state.Value = types.StringNull()
+			state.ValueBase64 = types.StringPointerValue(sec.Value)
+		} else {
+			state.Value = types.StringPointerValue(sec.Value)
+			state.ValueBase64 = types.StringNull()
 		}
+		resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 	case "gcp":
-		if r.gcp == nil {
+		name := fmt.Sprintf("projects/%s/secrets/%s", r.gcpProj, state.Name.ValueString())
+		sec, err := r.gcp.Projects.Secrets.Get(name).Context(ctx).Do()
+		if err != nil {
+			resp.State.RemoveResource(ctx)
 			return
 		}
-		_, err := r.gcp.Projects.Secrets.Get(fmt.Sprintf("projects/%s/secrets/%s", r.gcpProj, state.Name.ValueString())).Context(ctx).Do()
+		ver, err := r.gcp.Projects.Secrets.Versions.Access(name + "/versions/latest").Context(ctx).Do()
 		if err != nil {
-			resp.State.RemoveResource(ctx)
+			resp.Diagnostics.AddError("gcp access", err.Error())
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(ver.Payload.Data)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp access", err.Error())
+			return
+		}
+		// Secret Manager does not record how the value was given, so keep the attribute in use
+		state.setPayload(data, !state.ValueBase64.IsNull())
+		if sec.Replication != nil && sec.Replication.UserManaged != nil {
+			var locations []string
+			for _, rep := range sec.Replication.UserManaged.Replicas {
+				locations = append(locations, rep.Location)
+			}
+			resp.Diagnostics.Append(refreshList(ctx, &state.ReplicationLocations, locations)...)
+		} else {
+			state.ReplicationLocations = types.ListNull(types.StringType)
 		}
+		refreshKMSKey(&state.KMSKeyID, gcpReplicationKey(sec.Replication))
+		resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	default:
+		return
 	}
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return state.cloudID(), nil })
 }
 
 func (r *SecretResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var plan struct {
-		Name  types.String `tfsdk:"name"`
-		Type  types.String `tfsdk:"type"`
-		Value types.String `tfsdk:"value"`
-	}
-	diags := req.Plan.Get(ctx, &plan)
-	resp.Diagnostics.Append(diags...)
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state secretResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	// replicas and the key can be changed in place; anything else recreates the secret
+	if plan.Type.ValueString() == "aws" && plan.Type.Equal(state.Type) && plan.Name.Equal(state.Name) && plan.Value.Equal(state.Value) && plan.ValueBase64.Equal(state.ValueBase64) {
+		have, d := state.replicas(ctx)
+		resp.Diagnostics.Append(d...)
+		want, d := plan.replicas(ctx)
+		resp.Diagnostics.Append(d...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		if err := r.syncSecretReplicas(ctx, state.ID.ValueString(), have, want); err != nil {
+			resp.Diagnostics.AddError("aws replicate", err.Error())
+			return
+		}
+		if !plan.KMSKeyID.Equal(state.KMSKeyID) {
+			// the AWS managed key is named by its alias
+			key := plan.KMSKeyID.ValueString()
+			if key == "" {
+				key = "alias/aws/secretsmanager"
+			}
+			_, err := r.sm.UpdateSecret(ctx, &secretsmanager.UpdateSecretInput{SecretId: aws.String(state.ID.ValueString()), KmsKeyId: aws.String(key)})
+			if err != nil {
+				resp.Diagnostics.AddError("aws update key", err.Error())
+				return
+			}
+		}
+		_, status, _, d := r.secretReplicaStatus(ctx, state.ID.ValueString())
+		resp.Diagnostics.Append(d...)
+		plan.ID = state.ID
+		plan.CloudID = state.CloudID
+		plan.ReplicaStatus = status
+		resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+		return
+	}
 	delReq := resource.DeleteRequest{State: req.State}
 	delResp := &resource.DeleteResponse{}
 	r.Delete(ctx, delReq, delResp)
@@ -209,34 +542,43 @@ func (r *SecretResource) Update(ctx context.Context, req resource.UpdateRequest,
 		return
 	}
 	createReq := resource.CreateRequest{Plan: req.Plan}
-	createResp := &resource.CreateResponse{}
+	createResp := &resource.CreateResponse{State: resp.State}
 	r.Create(ctx, createReq, createResp)
 	resp.Diagnostics.Append(createResp.Diagnostics...)
+	resp.State = createResp.State
 }
 
 func (r *SecretResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var state struct {
-		Name types.String `tfsdk:"name"`
-		Type types.String `tfsdk:"type"`
-	}
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state secretResourceModel
 	diags := req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
 	switch state.Type.ValueString() {
 	case "aws":
-		if r.sm == nil {
+		replicas, d := state.replicas(ctx)
+		resp.Diagnostics.Append(d...)
+		if resp.Diagnostics.HasError() {
 			return
 		}
+		// a primary secret cannot be deleted while it still has replicas
+		if len(replicas) > 0 {
+			if err := r.syncSecretReplicas(ctx, state.Name.ValueString(), replicas, nil); err != nil {
+				resp.Diagnostics.AddError("aws remove replicas", err.Error())
+				return
+			}
+		}
 		_, err := r.sm.DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{SecretId: aws.String(state.Name.ValueString()), ForceDeleteWithoutRecovery: aws.Bool(true)})
 		if err != nil {
 			resp.Diagnostics.AddError("aws delete", err.Error())
 		}
 	case "azure":
-		if r.azureCred == nil {
-			return
-		}
 		vaultURL := os.Getenv("AZURE_KEY_VAULT_URL")
 		if vaultURL == "" {
 			return
@@ -245,14 +587,11 @@ func (r *SecretResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		if err != nil {
 			return
 		}
-		_, err = client.BeginDeleteSecret(ctx, state.Name.ValueString(), nil)
+		_, err = client.DeleteSecret(ctx, state.Name.ValueString(), nil)
 		if err != nil {
 			resp.Diagnostics.AddError("azure delete", err.Error())
 		}
 	case "gcp":
-		if r.gcp == nil {
-			return
-		}
 		_, err := r.gcp.Projects.Secrets.Delete(fmt.Sprintf("projects/%s/secrets/%s", r.gcpProj, state.Name.ValueString())).Context(ctx).Do()
 		if err != nil {
 			resp.Diagnostics.AddError("gcp delete", err.Error())
@@ -0,0 +1,135 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestSecretReplicationConfig(t *testing.T) {
+	r := &SecretResource{}
+	s := testSchema(t, r)
+	cases := []struct {
+		name      string
+		cloud     string
+		locations tftypes.Value
+		errs      bool
+		warns     bool
+	}{
+		{"gcp", "gcp", strList("us-east1", "europe-west1"), false, false},
+		{"gcp automatic", "gcp", tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, nil), false, false},
+		{"gcp empty", "gcp", strList(), true, false},
+		{"aws", "aws", strList("us-east1"), false, true},
+		{"azure", "azure", strList("us-east1"), false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			vals := map[string]tftypes.Value{"name": str("db-password"), "type": str(tc.cloud), "value": str("secret"), "replication_locations": tc.locations}
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs || (resp.Diagnostics.WarningsCount() > 0) != tc.warns {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestGCPReplication(t *testing.T) {
+	if rep := gcpReplication(nil, ""); rep.Automatic == nil || rep.UserManaged != nil || gcpReplicationKey(rep) != "" {
+		t.Errorf("no locations = %+v, want automatic", rep)
+	}
+	rep := gcpReplication([]string{"us-east1", "europe-west1"}, "")
+	if rep.Automatic != nil || rep.UserManaged == nil || len(rep.UserManaged.Replicas) != 2 {
+		t.Fatalf("replication = %+v, want user managed", rep)
+	}
+	for i, want := range []string{"us-east1", "europe-west1"} {
+		if got := rep.UserManaged.Replicas[i].Location; got != want {
+			t.Errorf("replica %d = %q, want %q", i, got, want)
+		}
+	}
+	const key = "projects/p/locations/us-east1/keyRings/r/cryptoKeys/k"
+	if got := gcpReplicationKey(gcpReplication(nil, key)); got != key {
+		t.Errorf("automatic key = %q", got)
+	}
+	if got := gcpReplicationKey(gcpReplication([]string{"us-east1"}, key)); got != key {
+		t.Errorf("user managed key = %q", got)
+	}
+}
+
+func TestSecretValueConfig(t *testing.T) {
+	r := &SecretResource{}
+	s := testSchema(t, r)
+	null := tftypes.NewValue(tftypes.String, nil)
+	cases := []struct {
+		name          string
+		value, binary tftypes.Value
+		ok            bool
+	}{
+		{"value", str("hunter2"), null, true},
+		{"value_base64", null, str("/wCAgQ=="), true},
+		{"both", str("hunter2"), str("/wCAgQ=="), false},
+		{"neither", null, null, false},
+		{"invalid base64", null, str("not base64!"), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			vals := map[string]tftypes.Value{"name": str("tls-key"), "type": str("aws"), "value": tc.value, "value_base64": tc.binary}
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, vals, false)}}, resp)
+			if resp.Diagnostics.HasError() == tc.ok {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestSecretPayload(t *testing.T) {
+	// not valid UTF-8, so it would be corrupted as a string
+	raw := []byte{0xff, 0x00, 0x80, 0x81}
+	var m secretResourceModel
+	m.setPayload(raw, true)
+	if !m.Value.IsNull() || m.ValueBase64.ValueString() != "/wCAgQ==" {
+		t.Fatalf("value = %v, value_base64 = %v", m.Value, m.ValueBase64)
+	}
+	data, err := m.payload()
+	if err != nil || !bytes.Equal(data, raw) {
+		t.Fatalf("payload = %v, %v", data, err)
+	}
+	m = secretResourceModel{Value: types.StringValue("hunter2"), ValueBase64: types.StringNull()}
+	if data, err := m.payload(); err != nil || string(data) != "hunter2" {
+		t.Fatalf("payload = %q, %v", data, err)
+	}
+	m.setPayload([]byte("hunter3"), false)
+	if m.Value.ValueString() != "hunter3" || !m.ValueBase64.IsNull() {
+		t.Errorf("value = %v, value_base64 = %v", m.Value, m.ValueBase64)
+	}
+}
+
+func TestRefreshList(t *testing.T) {
+	ctx := context.Background()
+	l := types.ListNull(types.StringType)
+	if refreshList(ctx, &l, nil).HasError() || !l.IsNull() {
+		t.Fatalf("no regions = %v, want null", l)
+	}
+	refreshList(ctx, &l, []string{"us-west-2", "eu-west-1"})
+	// regions read back in another order are not drift
+	refreshList(ctx, &l, []string{"eu-west-1", "us-west-2"})
+	var got []string
+	l.ElementsAs(ctx, &got, false)
+	if !slices.Equal(got, []string{"us-west-2", "eu-west-1"}) {
+		t.Errorf("regions = %v", got)
+	}
+	// a region removed outside Terraform is
+	refreshList(ctx, &l, []string{"eu-west-1"})
+	got = nil
+	l.ElementsAs(ctx, &got, false)
+	if !slices.Equal(got, []string{"eu-west-1"}) {
+		t.Errorf("regions = %v", got)
+	}
+}
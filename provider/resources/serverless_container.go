@@ -1,265 +1,877 @@
 package resources
 
 import (
-    "context"
-    "fmt"
-
-    "abstract-provider/provider/shared"
-    "github.com/Azure/azure-sdk-for-go/sdk/azcore"
-    "github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
-    ci "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerinstance/armcontainerinstance"
-    "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
-    "github.com/aws/aws-sdk-go-v2/aws"
-    "github.com/aws/aws-sdk-go-v2/service/ec2"
-    "github.com/aws/aws-sdk-go-v2/service/ecs"
-    ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
-    "github.com/hashicorp/terraform-plugin-framework/resource"
-    schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
-    "github.com/hashicorp/terraform-plugin-framework/types"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	ci "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerinstance/armcontainerinstance"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	run "google.golang.org/api/run/v2"
 )
 
 // ServerlessContainerResource manages a serverless container service.
 type ServerlessContainerResource struct {
-    ecs    *ecs.Client
-    ec2    *ec2.Client
-    azureRG *armresources.ResourceGroupsClient
-    azureCI *ci.ContainerGroupsClient
-    azureCred azcore.TokenCredential
-    azureSubID string
-    azureLoc   string
+	ecs        *ecs.Client
+	ec2        *ec2.Client
+	azureRG    *armresources.ResourceGroupsClient
+	azureCI    *ci.ContainerGroupsClient
+	azureCred  azcore.TokenCredential
+	azureSubID string
+	azureLoc   string
+	gcpRun     *run.Service
+	gcpProj    string
+	gcpRegion  string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+}
+
+type serverlessContainerResourceModel struct {
+	ID         types.String                    `tfsdk:"id"`
+	CloudID    types.String                    `tfsdk:"cloud_id"`
+	Name       types.String                    `tfsdk:"name"`
+	Image      types.String                    `tfsdk:"image"`
+	Type       types.String                    `tfsdk:"type"`
+	Region     types.String                    `tfsdk:"region"`
+	CPU        types.Int64                     `tfsdk:"cpu"`
+	Memory     types.Int64                     `tfsdk:"memory"`
+	Ports      types.List                      `tfsdk:"ports"`
+	Containers []serverlessContainerDefinition `tfsdk:"containers"`
+	IPAddress  types.String                    `tfsdk:"ip_address"`
+	Endpoint   types.String                    `tfsdk:"endpoint"`
+
+	Environment      types.Map    `tfsdk:"environment"`
+	Secrets          types.Map    `tfsdk:"secrets"`
+	ExecutionRoleARN types.String `tfsdk:"execution_role_arn"`
+}
+
+type serverlessContainerDefinition struct {
+	Name  types.String `tfsdk:"name"`
+	Image types.String `tfsdk:"image"`
+	Ports types.List   `tfsdk:"ports"`
+	Env   types.Map    `tfsdk:"env"`
+}
+
+// containerSpec is one container to run, from containers or, without them,
+// from the resource's own name, image and ports. secrets maps variable names
+// to abstract_secret IDs.
+type containerSpec struct {
+	name, image string
+	ports       []int64
+	env         map[string]string
+	secrets     map[string]string
+}
+
+// containers returns the containers to run. Each gets environment, overridden
+// by its own env, and secrets.
+func (m *serverlessContainerResourceModel) containers() []containerSpec {
+	env, secrets := stringMap(m.Environment), stringMap(m.Secrets)
+	if len(m.Containers) == 0 {
+		return []containerSpec{{name: m.Name.ValueString(), image: m.Image.ValueString(), ports: int64List(m.Ports), env: env, secrets: secrets}}
+	}
+	var specs []containerSpec
+	for _, c := range m.Containers {
+		own := maps.Clone(env)
+		if own == nil {
+			own = map[string]string{}
+		}
+		maps.Copy(own, stringMap(c.Env))
+		specs = append(specs, containerSpec{name: c.Name.ValueString(), image: c.Image.ValueString(), ports: int64List(c.Ports), env: own, secrets: secrets})
+	}
+	return specs
+}
+
+// firstPort returns the first port any container exposes, 0 if none does.
+func firstPort(specs []containerSpec) int64 {
+	for _, c := range specs {
+		if len(c.ports) > 0 {
+			return c.ports[0]
+		}
+	}
+	return 0
+}
+
+// int64List returns the known elements of an int64 list.
+func int64List(l types.List) []int64 {
+	var out []int64
+	for _, e := range l.Elements() {
+		if v, ok := e.(types.Int64); ok && !v.IsNull() && !v.IsUnknown() {
+			out = append(out, v.ValueInt64())
+		}
+	}
+	return out
 }
 
 func NewServerlessContainerResource() resource.Resource { return &ServerlessContainerResource{} }
 
 func (r *ServerlessContainerResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
-    if req.ProviderData == nil {
-        return
-    }
-    cfg, ok := req.ProviderData.(*shared.ProviderConfig)
-    if !ok {
-        resp.Diagnostics.AddError("invalid provider data", "")
-        return
-    }
-    r.ecs = cfg.AWSECS
-    r.ec2 = cfg.AWSEC2
-    r.azureRG = cfg.AzureRGClient
-    r.azureCI = cfg.AzureContainerClient
-    r.azureCred = cfg.AzureCred
-    r.azureSubID = cfg.AzureSubID
-    r.azureLoc = cfg.AzureLocation
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.ecs = cfg.AWSECS
+	r.ec2 = cfg.AWSEC2
+	r.azureRG = cfg.AzureRGClient
+	r.azureCI = cfg.AzureContainerClient
+	r.azureCred = cfg.AzureCred
+	r.azureSubID = cfg.AzureSubID
+	r.azureLoc = cfg.AzureLocation
+	r.gcpRun = cfg.GCPRun
+	r.gcpProj = cfg.GCPProject
+	r.gcpRegion = cfg.GCPRegion
 }
 
 func (r *ServerlessContainerResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
-    resp.TypeName = "abstract_container"
+	resp.TypeName = "abstract_container"
 }
 
 func (r *ServerlessContainerResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
-    resp.Schema = schema.Schema{
-        Attributes: map[string]schema.Attribute{
-            "id":         schema.StringAttribute{Computed: true},
-            "name":       schema.StringAttribute{Required: true},
-            "image":      schema.StringAttribute{Required: true},
-            "type":       schema.StringAttribute{Required: true},
-            "region":     schema.StringAttribute{Optional: true},
-            "ip_address": schema.StringAttribute{Computed: true},
-        },
-    }
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	computed := []planmodifier.String{stringplanmodifier.UseStateForUnknown()}
+	ports := schema.ListAttribute{ElementType: types.Int64Type, Optional: true, PlanModifiers: []planmodifier.List{listplanmodifier.RequiresReplace()}}
+	// an ECS task cannot change, but container groups and Cloud Run services can
+	replaceOnAWS := []planmodifier.Map{mapplanmodifier.RequiresReplaceIf(
+		func(ctx context.Context, req planmodifier.MapRequest, resp *mapplanmodifier.RequiresReplaceIfFuncResponse) {
+			var cloud types.String
+			resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("type"), &cloud)...)
+			resp.RequiresReplace = cloud.ValueString() == "aws"
+		},
+		"Changing this replaces an AWS task.",
+		"Changing this replaces an AWS task.",
+	)}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id":   schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			"name": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// The image of a single container named after the resource; set containers instead for more.
+			"image":  schema.StringAttribute{Optional: true, PlanModifiers: replace},
+			"type":   schema.StringAttribute{Required: true, PlanModifiers: replace},
+			"region": schema.StringAttribute{Optional: true, PlanModifiers: replace},
+			"cpu": schema.Int64Attribute{Optional: true, Description: serverlessCPUDescription,
+				PlanModifiers: []planmodifier.Int64{int64planmodifier.RequiresReplace()}},
+			"memory": schema.Int64Attribute{Optional: true, Description: "Memory in MiB for all containers together.",
+				PlanModifiers: []planmodifier.Int64{int64planmodifier.RequiresReplace()}},
+			// TCP ports the image container listens on.
+			"ports": ports,
+			"containers": schema.ListNestedAttribute{
+				Optional:      true,
+				PlanModifiers: []planmodifier.List{listplanmodifier.RequiresReplace()},
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name":  schema.StringAttribute{Required: true},
+						"image": schema.StringAttribute{Required: true},
+						"ports": schema.ListAttribute{ElementType: types.Int64Type, Optional: true},
+						"env":   schema.MapAttribute{ElementType: types.StringType, Optional: true},
+					},
+				},
+			},
+			// Environment variables of every container, overridden by a container's env.
+			"environment": schema.MapAttribute{ElementType: types.StringType, Optional: true, PlanModifiers: replaceOnAWS},
+			// Environment variables set from abstract_secret resources, by their id.
+			"secrets": schema.MapAttribute{ElementType: types.StringType, Optional: true, PlanModifiers: replaceOnAWS},
+			// AWS only: the task execution role, which ECS uses to read secrets.
+			"execution_role_arn": schema.StringAttribute{Optional: true, PlanModifiers: replace},
+			"ip_address":         schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			// host:port of the first exposed port on AWS and Azure, the service URL on GCP.
+			"endpoint": schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			"cloud_id": cloudIDAttribute(),
+		},
+	}
+}
+
+const serverlessCPUDescription = "CPU units for all containers together, 1024 to a vCPU. On AWS cpu and memory " +
+	"must be a Fargate task size, by default 256 and the least memory Fargate allows for the cpu."
+
+func (r *ServerlessContainerResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cloud, image types.String
+	var cpu, memory types.Int64
+	var ports, containers types.List
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("type"), &cloud)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("image"), &image)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("cpu"), &cpu)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("memory"), &memory)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("ports"), &ports)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("containers"), &containers)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	switch {
+	case containers.IsNull() && image.IsNull():
+		resp.Diagnostics.AddAttributeError(path.Root("image"), "missing image", "set image, or containers to run more than one container")
+	case !containers.IsNull() && !image.IsNull():
+		resp.Diagnostics.AddAttributeError(path.Root("image"), "conflicting image", "image and containers cannot both be set; give each container its image")
+	case !containers.IsNull() && !ports.IsNull():
+		resp.Diagnostics.AddAttributeError(path.Root("ports"), "conflicting ports", "with containers, set ports on each container")
+	}
+	if cloud.ValueString() == "aws" && !cpu.IsUnknown() && !memory.IsUnknown() {
+		if _, _, err := fargateSize(cpu, memory); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("memory"), "invalid fargate size", err.Error())
+		}
+	}
+	for _, v := range []struct {
+		name string
+		v    types.Int64
+	}{{"cpu", cpu}, {"memory", memory}} {
+		if !v.v.IsNull() && !v.v.IsUnknown() && v.v.ValueInt64() <= 0 {
+			resp.Diagnostics.AddAttributeError(path.Root(v.name), "invalid "+v.name, v.name+" must be positive")
+		}
+	}
+	r.validateSecrets(ctx, cloud, req, resp)
+	if containers.IsUnknown() || ports.IsUnknown() {
+		return
+	}
+	var defs []serverlessContainerDefinition
+	resp.Diagnostics.Append(containers.ElementsAs(ctx, &defs, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	m := serverlessContainerResourceModel{Name: types.StringValue("app"), Ports: ports, Containers: defs}
+	names := map[string]bool{}
+	for i, d := range defs {
+		name := d.Name.ValueString()
+		if d.Name.IsUnknown() {
+			continue
+		}
+		if !gcpResourceName.MatchString(name) {
+			resp.Diagnostics.AddAttributeError(path.Root("containers").AtListIndex(i).AtName("name"), "invalid container name",
+				fmt.Sprintf("%q must be lower case letters, digits and hyphens, starting with a letter", name))
+		}
+		if names[name] {
+			resp.Diagnostics.AddAttributeError(path.Root("containers").AtListIndex(i).AtName("name"), "duplicate container name", fmt.Sprintf("%q is used twice", name))
+		}
+		names[name] = true
+	}
+	// the containers share one network namespace, so a port can only be used once
+	seen := map[int64]bool{}
+	var all []int64
+	for _, c := range m.containers() {
+		for _, p := range c.ports {
+			if p < 1 || p > 65535 {
+				resp.Diagnostics.AddError("invalid port", fmt.Sprintf("port %d is not between 1 and 65535", p))
+			} else if seen[p] {
+				resp.Diagnostics.AddError("duplicate port", fmt.Sprintf("port %d is exposed twice", p))
+			}
+			seen[p] = true
+			all = append(all, p)
+		}
+	}
+	if cloud.ValueString() == "gcp" {
+		// Cloud Run routes requests to a single port of the ingress container
+		if len(all) > 1 {
+			resp.Diagnostics.AddError("too many ports", "Cloud Run exposes one port; set a single port on one container")
+		}
+		if len(defs) > 1 && len(all) == 0 {
+			resp.Diagnostics.AddError("missing port", "Cloud Run needs a port on the container that receives requests")
+		}
+	}
+}
+
+// validateSecrets checks that secrets refer to secrets of the resource's
+// cloud and do not collide with environment.
+func (r *ServerlessContainerResource) validateSecrets(ctx context.Context, cloud types.String, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var env, secrets types.Map
+	var role types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("environment"), &env)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("secrets"), &secrets)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("execution_role_arn"), &role)...)
+	if resp.Diagnostics.HasError() || cloud.IsUnknown() {
+		return
+	}
+	if !role.IsNull() && cloud.ValueString() != "aws" {
+		resp.Diagnostics.AddAttributeWarning(path.Root("execution_role_arn"), "execution_role_arn ignored", "execution_role_arn only applies to aws")
+	}
+	if secrets.IsNull() || secrets.IsUnknown() {
+		return
+	}
+	if cloud.ValueString() == "aws" && role.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("execution_role_arn"), "missing execution role",
+			"ECS reads secrets with the task execution role; set execution_role_arn to a role that may read them")
+	}
+	plain := stringMap(env)
+	for name, v := range secrets.Elements() {
+		if _, ok := plain[name]; ok {
+			resp.Diagnostics.AddAttributeError(path.Root("secrets").AtMapKey(name), "conflicting variable", fmt.Sprintf("%s is set in both environment and secrets", name))
+		}
+		id, ok := v.(types.String)
+		if !ok || id.IsUnknown() {
+			continue
+		}
+		if err := checkSecretRef(cloud.ValueString(), id.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("secrets").AtMapKey(name), "invalid secret", err.Error())
+		}
+	}
+}
+
+// checkSecretRef checks that id is the id of an abstract_secret on cloud: a
+// Secrets Manager ARN, a vault URL and name joined by "#", or a Secret
+// Manager secret name.
+func checkSecretRef(cloud, id string) error {
+	var ok bool
+	switch cloud {
+	case "aws":
+		ok = strings.HasPrefix(id, "arn:") && strings.Contains(id, ":secretsmanager:")
+	case "azure":
+		vault, name, found := strings.Cut(id, "#")
+		ok = found && strings.HasPrefix(vault, "https://") && name != ""
+	case "gcp":
+		ok = strings.HasPrefix(id, "projects/") && strings.Contains(id, "/secrets/")
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("%q is not the id of an abstract_secret on %s", id, cloud)
+	}
+	return nil
+}
+
+// fargateMemory lists the memory in MiB Fargate allows for each task cpu:
+// from min to max in steps of step.
+var fargateMemory = map[int64]struct{ min, max, step int64 }{
+	256:   {512, 2048, 512},
+	512:   {1024, 4096, 1024},
+	1024:  {2048, 8192, 1024},
+	2048:  {4096, 16384, 1024},
+	4096:  {8192, 30720, 1024},
+	8192:  {16384, 61440, 4096},
+	16384: {32768, 122880, 8192},
+}
+
+// fargateSize returns the task cpu and memory, defaulting cpu to 256 and
+// memory to the least Fargate allows for the cpu, and checks that Fargate
+// supports the combination.
+func fargateSize(cpu, memory types.Int64) (int64, int64, error) {
+	c := cpu.ValueInt64()
+	if cpu.IsNull() {
+		c = 256
+	}
+	sizes, ok := fargateMemory[c]
+	if !ok {
+		return 0, 0, fmt.Errorf("cpu %d is not a Fargate task size; use 256, 512, 1024, 2048, 4096, 8192 or 16384", c)
+	}
+	m := memory.ValueInt64()
+	if memory.IsNull() {
+		m = sizes.min
+	}
+	// 256 cpu allows 512, 1024 and 2048 but not 1536
+	if m < sizes.min || m > sizes.max || (m-sizes.min)%sizes.step != 0 || (c == 256 && m == 1536) {
+		return 0, 0, fmt.Errorf("memory %d is not allowed with cpu %d; Fargate takes %d to %d MiB in steps of %d", m, c, sizes.min, sizes.max, sizes.step)
+	}
+	return c, m, nil
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *ServerlessContainerResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.ecs = c.ECS
+		r.ec2 = c.EC2
+	}
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+// Clouds without an implementation pass so Create can reject them.
+func (r *ServerlessContainerResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.ecs != nil && r.ec2 != nil
+	case "azure":
+		return r.azureRG != nil && r.azureCI != nil
+	case "gcp":
+		return r.gcpRun != nil
+	}
+	return true
+}
+
+// ecsContainerDefinitions maps the containers to ECS, each port to a TCP
+// port mapping.
+func ecsContainerDefinitions(specs []containerSpec) []ecstypes.ContainerDefinition {
+	var defs []ecstypes.ContainerDefinition
+	for _, c := range specs {
+		def := ecstypes.ContainerDefinition{
+			Name:      aws.String(c.name),
+			Image:     aws.String(c.image),
+			Essential: aws.Bool(true),
+		}
+		for _, p := range c.ports {
+			def.PortMappings = append(def.PortMappings, ecstypes.PortMapping{ContainerPort: aws.Int32(int32(p)), Protocol: ecstypes.TransportProtocolTcp})
+		}
+		for _, k := range slices.Sorted(maps.Keys(c.env)) {
+			def.Environment = append(def.Environment, ecstypes.KeyValuePair{Name: aws.String(k), Value: aws.String(c.env[k])})
+		}
+		for _, k := range slices.Sorted(maps.Keys(c.secrets)) {
+			def.Secrets = append(def.Secrets, ecstypes.Secret{Name: aws.String(k), ValueFrom: aws.String(c.secrets[k])})
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// azureContainerGroup maps the containers to a container group. The cpu and
+// memory, 1 core and 1 GB by default, are split evenly between the
+// containers, and the group gets a public IP when any container has a port.
+// secretValues holds the values of the secrets, which are set as secure
+// environment variables.
+func azureContainerGroup(m *serverlessContainerResourceModel, loc string, secretValues map[string]string) ci.ContainerGroup {
+	specs := m.containers()
+	cores, gb := 1.0, 1.0
+	if !m.CPU.IsNull() {
+		cores = float64(m.CPU.ValueInt64()) / 1024
+	}
+	if !m.Memory.IsNull() {
+		gb = float64(m.Memory.ValueInt64()) / 1024
+	}
+	n := float64(len(specs))
+	// ACI takes memory in steps of 0.1 GB
+	cpuEach, memEach := math.Round(cores/n*100)/100, max(math.Floor(gb/n*10)/10, 0.1)
+	props := &ci.ContainerGroupProperties{
+		OSType:        to.Ptr(ci.OperatingSystemTypesLinux),
+		RestartPolicy: to.Ptr(ci.ContainerGroupRestartPolicyNever),
+	}
+	var groupPorts []*ci.Port
+	for _, c := range specs {
+		container := &ci.Container{
+			Name: to.Ptr(c.name),
+			Properties: &ci.ContainerProperties{
+				Image: to.Ptr(c.image),
+				Resources: &ci.ResourceRequirements{Requests: &ci.ResourceRequests{
+					CPU:        to.Ptr(cpuEach),
+					MemoryInGB: to.Ptr(memEach),
+				}},
+			},
+		}
+		for _, p := range c.ports {
+			container.Properties.Ports = append(container.Properties.Ports, &ci.ContainerPort{Port: to.Ptr(int32(p)), Protocol: to.Ptr(ci.ContainerNetworkProtocolTCP)})
+			groupPorts = append(groupPorts, &ci.Port{Port: to.Ptr(int32(p)), Protocol: to.Ptr(ci.ContainerGroupNetworkProtocolTCP)})
+		}
+		for _, k := range slices.Sorted(maps.Keys(c.env)) {
+			container.Properties.EnvironmentVariables = append(container.Properties.EnvironmentVariables, &ci.EnvironmentVariable{Name: to.Ptr(k), Value: to.Ptr(c.env[k])})
+		}
+		for _, k := range slices.Sorted(maps.Keys(c.secrets)) {
+			container.Properties.EnvironmentVariables = append(container.Properties.EnvironmentVariables, &ci.EnvironmentVariable{Name: to.Ptr(k), SecureValue: to.Ptr(secretValues[k])})
+		}
+		props.Containers = append(props.Containers, container)
+	}
+	if len(groupPorts) > 0 {
+		props.IPAddress = &ci.IPAddress{Type: to.Ptr(ci.ContainerGroupIPAddressTypePublic), Ports: groupPorts}
+	}
+	return ci.ContainerGroup{Location: &loc, Properties: props}
+}
+
+// cloudRunService maps the containers to a Cloud Run service. The cpu and
+// memory, Cloud Run's defaults when unset, are split evenly between the
+// containers.
+func cloudRunService(m *serverlessContainerResourceModel) *run.GoogleCloudRunV2Service {
+	specs := m.containers()
+	n := int64(len(specs))
+	limits := map[string]string{}
+	if !m.CPU.IsNull() {
+		limits["cpu"] = fmt.Sprintf("%dm", m.CPU.ValueInt64()*1000/1024/n)
+	}
+	if !m.Memory.IsNull() {
+		limits["memory"] = fmt.Sprintf("%dMi", m.Memory.ValueInt64()/n)
+	}
+	tmpl := &run.GoogleCloudRunV2RevisionTemplate{}
+	for _, c := range specs {
+		container := &run.GoogleCloudRunV2Container{Name: c.name, Image: c.image}
+		if len(limits) > 0 {
+			container.Resources = &run.GoogleCloudRunV2ResourceRequirements{Limits: limits}
+		}
+		for _, p := range c.ports {
+			container.Ports = append(container.Ports, &run.GoogleCloudRunV2ContainerPort{ContainerPort: p})
+		}
+		for _, k := range slices.Sorted(maps.Keys(c.env)) {
+			container.Env = append(container.Env, &run.GoogleCloudRunV2EnvVar{Name: k, Value: c.env[k]})
+		}
+		for _, k := range slices.Sorted(maps.Keys(c.secrets)) {
+			container.Env = append(container.Env, &run.GoogleCloudRunV2EnvVar{Name: k, ValueSource: &run.GoogleCloudRunV2EnvVarSource{
+				SecretKeyRef: &run.GoogleCloudRunV2SecretKeySelector{Secret: c.secrets[k], Version: "latest"},
+			}})
+		}
+		tmpl.Containers = append(tmpl.Containers, container)
+	}
+	return &run.GoogleCloudRunV2Service{Template: tmpl}
+}
+
+// gcpParent returns the location the service runs in: the resource's region,
+// else the provider region, else us-central1.
+func (r *ServerlessContainerResource) gcpParent(m *serverlessContainerResourceModel) string {
+	region := m.Region.ValueString()
+	if region == "" {
+		region = r.gcpRegion
+	}
+	if region == "" {
+		region = "us-central1"
+	}
+	return "projects/" + r.gcpProj + "/locations/" + region
+}
+
+// setAddress records the public IP and the endpoint of its first port; both
+// are null without an IP.
+func (m *serverlessContainerResourceModel) setAddress(ip string) {
+	m.IPAddress, m.Endpoint = types.StringNull(), types.StringNull()
+	if ip == "" {
+		return
+	}
+	m.IPAddress = types.StringValue(ip)
+	if port := firstPort(m.containers()); port > 0 {
+		m.Endpoint = types.StringValue(fmt.Sprintf("%s:%d", ip, port))
+	}
+}
+
+// ecsTaskENI returns the ID of the task's network interface, "" before one is
+// attached.
+func ecsTaskENI(task ecstypes.Task) string {
+	for _, a := range task.Attachments {
+		if aws.ToString(a.Type) != "ElasticNetworkInterface" {
+			continue
+		}
+		for _, d := range a.Details {
+			if aws.ToString(d.Name) == "networkInterfaceId" {
+				return aws.ToString(d.Value)
+			}
+		}
+	}
+	return ""
+}
+
+// awsTaskIP returns the public IP of the task's network interface, "" when it
+// has none or the interface was deleted with the stopped task.
+func (r *ServerlessContainerResource) awsTaskIP(ctx context.Context, task ecstypes.Task) (string, error) {
+	eni := ecsTaskENI(task)
+	if eni == "" {
+		return "", nil
+	}
+	out, err := r.ec2.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{eni}})
+	if awsErrorCode(err) == "InvalidNetworkInterfaceID.NotFound" {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(out.NetworkInterfaces) == 0 || out.NetworkInterfaces[0].Association == nil {
+		return "", nil
+	}
+	return aws.ToString(out.NetworkInterfaces[0].Association.PublicIp), nil
+}
+
+// azureGroupIP returns the public IP of a container group, "" if it has none.
+func azureGroupIP(cg ci.ContainerGroup) string {
+	if cg.Properties != nil && cg.Properties.IPAddress != nil && cg.Properties.IPAddress.IP != nil {
+		return *cg.Properties.IPAddress.IP
+	}
+	return ""
 }
 
 func (r *ServerlessContainerResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-    var plan struct {
-        Name   types.String `tfsdk:"name"`
-        Image  types.String `tfsdk:"image"`
-        Type   types.String `tfsdk:"type"`
-        Region types.String `tfsdk:"region"`
-    }
-    diags := req.Plan.Get(ctx, &plan)
-    resp.Diagnostics.Append(diags...)
-    if resp.Diagnostics.HasError() {
-        return
-    }
-
-    switch plan.Type.ValueString() {
-    case "aws":
-        if r.ecs == nil || r.ec2 == nil {
-            resp.Diagnostics.AddError("aws", "missing client")
-            return
-        }
-        subOut, err := r.ec2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{})
-        if err != nil || len(subOut.Subnets) == 0 {
-            resp.Diagnostics.AddError("aws subnets", "unable to find subnets")
-            return
-        }
-        subnet := aws.ToString(subOut.Subnets[0].SubnetId)
-        tdOut, err := r.ecs.RegisterTaskDefinition(ctx, &ecs.RegisterTaskDefinitionInput{
-            Family:                  aws.String(plan.Name.ValueString()),
-            RequiresCompatibilities: []ecstypes.Compatibility{ecstypes.CompatibilityFargate},
-            NetworkMode:             ecstypes.NetworkModeAwsvpc,
-            Cpu:                     aws.String("256"),
-            Memory:                  aws.String("512"),
-            ContainerDefinitions: []ecstypes.ContainerDefinition{{
-                Name:      aws.String("app"),
-                Image:     aws.String(plan.Image.ValueString()),
-                Essential: aws.Bool(true),
-            }},
-        })
-        if err != nil {
-            resp.Diagnostics.AddError("aws register", err.Error())
-            return
-        }
-        tdArn := aws.ToString(tdOut.TaskDefinition.TaskDefinitionArn)
-        runOut, err := r.ecs.RunTask(ctx, &ecs.RunTaskInput{
-            Cluster:        aws.String("default"),
-            LaunchType:     ecstypes.LaunchTypeFargate,
-            TaskDefinition: aws.String(tdArn),
-            NetworkConfiguration: &ecstypes.NetworkConfiguration{
-                AwsvpcConfiguration: &ecstypes.AwsVpcConfiguration{
-                    Subnets:       []string{subnet},
-                    AssignPublicIp: ecstypes.AssignPublicIpEnabled,
-                },
-            },
-        })
-        if err != nil || len(runOut.Tasks) == 0 {
-            if err == nil {
-                err = fmt.Errorf("no task returned")
-            }
-            resp.Diagnostics.AddError("aws run", err.Error())
-            return
-        }
-        task := runOut.Tasks[0]
-        resp.State.Set(ctx, map[string]interface{}{
-            "id":    aws.ToString(task.TaskArn),
-            "name":  plan.Name.ValueString(),
-            "image": plan.Image.ValueString(),
-            "type":  plan.Type.ValueString(),
-        })
-    case "azure":
-        if r.azureCI == nil || r.azureRG == nil {
-            resp.Diagnostics.AddError("azure", "missing client")
-            return
-        }
-        rgName := "abstract-rg"
-        if r.azureLoc == "" && plan.Region.ValueString() != "" {
-            r.azureLoc = plan.Region.ValueString()
-        }
-        _, err := r.azureRG.CreateOrUpdate(ctx, rgName, armresources.ResourceGroup{Location: &r.azureLoc}, nil)
-        if err != nil {
-            resp.Diagnostics.AddError("azure rg", err.Error())
-            return
-        }
-        poller, err := r.azureCI.BeginCreateOrUpdate(ctx, rgName, plan.Name.ValueString(), ci.ContainerGroup{
-            Location: &r.azureLoc,
-            Properties: &ci.ContainerGroupProperties{
-                OsType:       to.Ptr(ci.OperatingSystemTypesLinux),
-                RestartPolicy: to.Ptr(ci.ContainerGroupRestartPolicyNever),
-                Containers: []*ci.Container{{
-                    Name: to.Ptr(plan.Name.ValueString()),
-                    Properties: &ci.ContainerProperties{
-                        Image: to.Ptr(plan.Image.ValueString()),
-                        Resources: &ci.ResourceRequirements{Requests: &ci.ResourceRequests{
-                            CPU:        to.Ptr[float64](1.0),
-                            MemoryInGB: to.Ptr[float64](1.0),
-                        }},
-                    },
-                }},
-                IPAddress: &ci.IPAddress{Type: to.Ptr(ci.ContainerGroupIPAddressTypePublic)},
-            },
-        }, nil)
-        if err == nil {
-            _, err = poller.PollUntilDone(ctx, nil)
-        }
-        if err != nil {
-            resp.Diagnostics.AddError("azure create", err.Error())
-            return
-        }
-        cg, err := r.azureCI.Get(ctx, rgName, plan.Name.ValueString(), nil)
-        if err != nil {
-            resp.Diagnostics.AddError("azure get", err.Error())
-            return
-        }
-        ip := ""
-        if cg.Properties != nil && cg.Properties.IPAddress != nil && cg.Properties.IPAddress.IP != nil {
-            ip = *cg.Properties.IPAddress.IP
-        }
-        resp.State.Set(ctx, map[string]interface{}{
-            "id":         *cg.ID,
-            "name":       plan.Name.ValueString(),
-            "image":      plan.Image.ValueString(),
-            "type":       plan.Type.ValueString(),
-            "region":     r.azureLoc,
-            "ip_address": ip,
-        })
-    case "gcp":
-        resp.Diagnostics.AddError("gcp", "serverless container resource not implemented")
-    default:
-        resp.Diagnostics.AddError("unsupported cloud", "only aws and azure implemented")
-    }
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan serverlessContainerResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+
+	switch plan.Type.ValueString() {
+	case "aws":
+		cpu, memory, err := fargateSize(plan.CPU, plan.Memory)
+		if err != nil {
+			resp.Diagnostics.AddError("aws fargate size", err.Error())
+			return
+		}
+		subOut, err := r.ec2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{})
+		if err != nil || len(subOut.Subnets) == 0 {
+			resp.Diagnostics.AddError("aws subnets", "unable to find subnets")
+			return
+		}
+		subnet := aws.ToString(subOut.Subnets[0].SubnetId)
+		tdOut, err := r.ecs.RegisterTaskDefinition(ctx, &ecs.RegisterTaskDefinitionInput{
+			Family:                  aws.String(plan.Name.ValueString()),
+			RequiresCompatibilities: []ecstypes.Compatibility{ecstypes.CompatibilityFargate},
+			NetworkMode:             ecstypes.NetworkModeAwsvpc,
+			Cpu:                     aws.String(fmt.Sprint(cpu)),
+			Memory:                  aws.String(fmt.Sprint(memory)),
+			ContainerDefinitions:    ecsContainerDefinitions(plan.containers()),
+			ExecutionRoleArn:        plan.ExecutionRoleARN.ValueStringPointer(),
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("aws register", err.Error())
+			return
+		}
+		tdArn := aws.ToString(tdOut.TaskDefinition.TaskDefinitionArn)
+		runOut, err := r.ecs.RunTask(ctx, &ecs.RunTaskInput{
+			Cluster:        aws.String("default"),
+			LaunchType:     ecstypes.LaunchTypeFargate,
+			TaskDefinition: aws.String(tdArn),
+			NetworkConfiguration: &ecstypes.NetworkConfiguration{
+				AwsvpcConfiguration: &ecstypes.AwsVpcConfiguration{
+					Subnets:        []string{subnet},
+					AssignPublicIp: ecstypes.AssignPublicIpEnabled,
+				},
+			},
+		})
+		if err != nil || len(runOut.Tasks) == 0 {
+			if err == nil {
+				err = fmt.Errorf("no task returned")
+			}
+			resp.Diagnostics.AddError("aws run", err.Error())
+			return
+		}
+		task := runOut.Tasks[0]
+		plan.ID = types.StringValue(aws.ToString(task.TaskArn))
+		plan.CloudID = plan.ID
+		plan.setAddress("")
+		// the network interface gets its public IP once the task is running
+		desc, err := ecs.NewTasksRunningWaiter(r.ecs).WaitForOutput(ctx, &ecs.DescribeTasksInput{Cluster: aws.String("default"), Tasks: []string{plan.ID.ValueString()}}, 10*time.Minute)
+		var ip string
+		if err == nil && len(desc.Tasks) > 0 {
+			ip, err = r.awsTaskIP(ctx, desc.Tasks[0])
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("aws wait task", err.Error())
+			// keep the task in state so it is not leaked; Read fills in ip_address
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+			return
+		}
+		plan.setAddress(ip)
+	case "azure":
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		if err := shared.EnsureResourceGroup(ctx, r.azureRG, "abstract-rg", loc); err != nil {
+			resp.Diagnostics.AddError("azure rg", err.Error())
+			return
+		}
+		cg, err := r.putAzureGroup(ctx, &plan, loc)
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure create", err)
+			return
+		}
+		plan.ID = types.StringValue(*cg.ID)
+		plan.CloudID = plan.ID
+		plan.setAddress(azureGroupIP(cg))
+	case "gcp":
+		svc, err := r.putCloudRun(ctx, &plan, true)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp create", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(svc.Name)
+		plan.CloudID = plan.ID
+		plan.IPAddress = types.StringNull()
+		plan.Endpoint = types.StringValue(svc.Uri)
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure and gcp implemented")
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
 func (r *ServerlessContainerResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-    var state struct {
-        ID   types.String `tfsdk:"id"`
-        Type types.String `tfsdk:"type"`
-        Name types.String `tfsdk:"name"`
-    }
-    diags := req.State.Get(ctx, &state)
-    resp.Diagnostics.Append(diags...)
-    if resp.Diagnostics.HasError() {
-        return
-    }
-    switch state.Type.ValueString() {
-    case "aws":
-        if r.ecs == nil {
-            return
-        }
-        _, err := r.ecs.DescribeTasks(ctx, &ecs.DescribeTasksInput{Cluster: aws.String("default"), Tasks: []string{state.ID.ValueString()}})
-        if err != nil {
-            resp.State.RemoveResource(ctx)
-        }
-    case "azure":
-        if r.azureCI == nil {
-            return
-        }
-        _, err := r.azureCI.Get(ctx, "abstract-rg", state.Name.ValueString(), nil)
-        if err != nil {
-            resp.State.RemoveResource(ctx)
-        }
-    }
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state serverlessContainerResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		out, err := r.ecs.DescribeTasks(ctx, &ecs.DescribeTasksInput{Cluster: aws.String("default"), Tasks: []string{state.ID.ValueString()}})
+		if err != nil || len(out.Tasks) == 0 {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		ip, err := r.awsTaskIP(ctx, out.Tasks[0])
+		if err != nil {
+			resp.Diagnostics.AddError("aws describe network interface", err.Error())
+			return
+		}
+		state.setAddress(ip)
+	case "azure":
+		cg, err := r.azureCI.Get(ctx, "abstract-rg", state.Name.ValueString(), nil)
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		state.setAddress(azureGroupIP(cg.ContainerGroup))
+	case "gcp":
+		svc, err := r.gcpRun.Projects.Locations.Services.Get(state.ID.ValueString()).Context(ctx).Do()
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		state.Endpoint = types.StringValue(svc.Uri)
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	// the ID already is the task's ARN, the container group's resource ID or
+	// the service's name
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return state.ID.ValueString(), nil })
 }
 
-func (r *ServerlessContainerResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {}
+// putAzureGroup creates or redeploys the container group, with the values of
+// its secrets read from Key Vault, and returns it once it is deployed.
+func (r *ServerlessContainerResource) putAzureGroup(ctx context.Context, m *serverlessContainerResourceModel, loc string) (ci.ContainerGroup, error) {
+	values := map[string]string{}
+	for name, id := range stringMap(m.Secrets) {
+		vaultURL, secret, _ := strings.Cut(id, "#")
+		client, err := azsecrets.NewClient(vaultURL, r.azureCred, nil)
+		if err != nil {
+			return ci.ContainerGroup{}, err
+		}
+		s, err := client.GetSecret(ctx, secret, "", nil)
+		if err != nil {
+			return ci.ContainerGroup{}, fmt.Errorf("read secret %s: %w", id, err)
+		}
+		if s.Value != nil {
+			values[name] = *s.Value
+		}
+	}
+	poller, err := r.azureCI.BeginCreateOrUpdate(ctx, "abstract-rg", m.Name.ValueString(), azureContainerGroup(m, loc, values), nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, poller)
+	}
+	if err != nil {
+		return ci.ContainerGroup{}, err
+	}
+	cg, err := r.azureCI.Get(ctx, "abstract-rg", m.Name.ValueString(), nil)
+	return cg.ContainerGroup, err
+}
 
-func (r *ServerlessContainerResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-    var state struct {
-        ID   types.String `tfsdk:"id"`
-        Type types.String `tfsdk:"type"`
-        Name types.String `tfsdk:"name"`
-    }
-    diags := req.State.Get(ctx, &state)
-    resp.Diagnostics.Append(diags...)
-    if resp.Diagnostics.HasError() {
-        return
-    }
-    switch state.Type.ValueString() {
-    case "aws":
-        if r.ecs == nil {
-            return
-        }
-        _, err := r.ecs.StopTask(ctx, &ecs.StopTaskInput{Cluster: aws.String("default"), Task: aws.String(state.ID.ValueString())})
-        if err != nil {
-            resp.Diagnostics.AddError("aws delete", err.Error())
-        }
-    case "azure":
-        if r.azureCI == nil {
-            return
-        }
-        poller, err := r.azureCI.BeginDelete(ctx, "abstract-rg", state.Name.ValueString(), nil)
-        if err == nil {
-            _, err = poller.PollUntilDone(ctx, nil)
-        }
-        if err != nil {
-            resp.Diagnostics.AddError("azure delete", err.Error())
-        }
-    }
+// putCloudRun creates or replaces the Cloud Run service, waits for the
+// rollout and returns the service.
+func (r *ServerlessContainerResource) putCloudRun(ctx context.Context, m *serverlessContainerResourceModel, create bool) (*run.GoogleCloudRunV2Service, error) {
+	parent := r.gcpParent(m)
+	name := parent + "/services/" + m.Name.ValueString()
+	var op *run.GoogleLongrunningOperation
+	var err error
+	if create {
+		op, err = r.gcpRun.Projects.Locations.Services.Create(parent, cloudRunService(m)).ServiceId(m.Name.ValueString()).Context(ctx).Do()
+	} else {
+		op, err = r.gcpRun.Projects.Locations.Services.Patch(name, cloudRunService(m)).Context(ctx).Do()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cloudRunWait(ctx, r.gcpRun, op.Name); err != nil {
+		return nil, err
+	}
+	return r.gcpRun.Projects.Locations.Services.Get(name).Context(ctx).Do()
+}
+
+// Update changes environment and secrets, the only attributes that do not
+// replace the container, and only on Azure and GCP. A container group is
+// redeployed, restarting its containers, and a Cloud Run service rolls out a
+// new revision.
+func (r *ServerlessContainerResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state serverlessContainerResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	switch plan.Type.ValueString() {
+	case "azure":
+		cg, err := r.putAzureGroup(ctx, &plan, shared.AzureLocation(plan.Region.ValueString(), r.azureLoc))
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure update", err)
+			return
+		}
+		plan.setAddress(azureGroupIP(cg))
+	case "gcp":
+		svc, err := r.putCloudRun(ctx, &plan, false)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp update", err.Error())
+			return
+		}
+		plan.Endpoint = types.StringValue(svc.Uri)
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
 }
 
+func (r *ServerlessContainerResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state serverlessContainerResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err := r.ecs.StopTask(ctx, &ecs.StopTaskInput{Cluster: aws.String("default"), Task: aws.String(state.ID.ValueString())})
+		if err != nil {
+			resp.Diagnostics.AddError("aws delete", err.Error())
+		}
+	case "azure":
+		poller, err := r.azureCI.BeginDelete(ctx, "abstract-rg", state.Name.ValueString(), nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete", err)
+		}
+	case "gcp":
+		op, err := r.gcpRun.Projects.Locations.Services.Delete(state.ID.ValueString()).Context(ctx).Do()
+		if err == nil {
+			err = cloudRunWait(ctx, r.gcpRun, op.Name)
+		}
+		if err != nil && gcpStatus(err) != http.StatusNotFound {
+			resp.Diagnostics.AddError("gcp delete", err.Error())
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ci "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerinstance/armcontainerinstance"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"google.golang.org/api/option"
+	run "google.golang.org/api/run/v2"
+)
+
+var containerType = tftypes.Object{AttributeTypes: map[string]tftypes.Type{
+	"name":  tftypes.String,
+	"image": tftypes.String,
+	"ports": tftypes.List{ElementType: tftypes.Number},
+	"env":   tftypes.Map{ElementType: tftypes.String},
+}}
+
+func portList(ports ...int64) tftypes.Value {
+	vals := []tftypes.Value{}
+	for _, p := range ports {
+		vals = append(vals, number(p))
+	}
+	return tftypes.NewValue(tftypes.List{ElementType: tftypes.Number}, vals)
+}
+
+// containerValue returns a containers element; env is null when empty.
+func containerValue(name, image string, env map[string]string, ports ...int64) tftypes.Value {
+	envVal := tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, nil)
+	if len(env) > 0 {
+		envVal = strMap(env)
+	}
+	return tftypes.NewValue(containerType, map[string]tftypes.Value{"name": str(name), "image": str(image), "ports": portList(ports...), "env": envVal})
+}
+
+func containerList(cs ...tftypes.Value) tftypes.Value {
+	return tftypes.NewValue(tftypes.List{ElementType: containerType}, cs)
+}
+
+func TestServerlessContainerConfig(t *testing.T) {
+	r := &ServerlessContainerResource{}
+	s := testSchema(t, r)
+	two := containerList(containerValue("web", "nginx", nil, 80), containerValue("log", "fluentbit", nil))
+	for _, tc := range []struct {
+		name string
+		vals map[string]tftypes.Value
+		errs bool
+	}{
+		{"image", map[string]tftypes.Value{"type": str("aws"), "image": str("nginx"), "ports": portList(80)}, false},
+		{"no image", map[string]tftypes.Value{"type": str("aws")}, true},
+		{"image and containers", map[string]tftypes.Value{"type": str("aws"), "image": str("nginx"), "containers": two}, true},
+		{"ports and containers", map[string]tftypes.Value{"type": str("aws"), "ports": portList(80), "containers": two}, true},
+		{"containers", map[string]tftypes.Value{"type": str("azure"), "containers": two}, false},
+		{"duplicate name", map[string]tftypes.Value{"type": str("aws"), "containers": containerList(containerValue("web", "a", nil), containerValue("web", "b", nil))}, true},
+		{"invalid name", map[string]tftypes.Value{"type": str("aws"), "containers": containerList(containerValue("Web_1", "a", nil))}, true},
+		{"duplicate port", map[string]tftypes.Value{"type": str("aws"), "containers": containerList(containerValue("a", "a", nil, 80), containerValue("b", "b", nil, 80))}, true},
+		{"port range", map[string]tftypes.Value{"type": str("aws"), "image": str("nginx"), "ports": portList(70000)}, true},
+		{"fargate size", map[string]tftypes.Value{"type": str("aws"), "image": str("nginx"), "cpu": number(1024), "memory": number(3072)}, false},
+		{"fargate default memory", map[string]tftypes.Value{"type": str("aws"), "image": str("nginx"), "cpu": number(4096)}, false},
+		{"fargate memory too small", map[string]tftypes.Value{"type": str("aws"), "image": str("nginx"), "cpu": number(1024), "memory": number(1024)}, true},
+		{"fargate 256 with 1536", map[string]tftypes.Value{"type": str("aws"), "image": str("nginx"), "memory": number(1536)}, true},
+		{"fargate cpu", map[string]tftypes.Value{"type": str("aws"), "image": str("nginx"), "cpu": number(300)}, true},
+		{"azure any size", map[string]tftypes.Value{"type": str("azure"), "image": str("nginx"), "cpu": number(300), "memory": number(1536)}, false},
+		{"cloud run sidecar", map[string]tftypes.Value{"type": str("gcp"), "containers": two}, false},
+		{"cloud run two ports", map[string]tftypes.Value{"type": str("gcp"), "image": str("nginx"), "ports": portList(80, 443)}, true},
+		{"aws secret", map[string]tftypes.Value{"type": str("aws"), "image": str("nginx"), "execution_role_arn": str("arn:aws:iam::123456789012:role/exec"),
+			"secrets": strMap(map[string]string{"DB": "arn:aws:secretsmanager:us-east-1:123456789012:secret:db-AbCdEf"})}, false},
+		{"aws secret without role", map[string]tftypes.Value{"type": str("aws"), "image": str("nginx"),
+			"secrets": strMap(map[string]string{"DB": "arn:aws:secretsmanager:us-east-1:123456789012:secret:db-AbCdEf"})}, true},
+		{"secret from another cloud", map[string]tftypes.Value{"type": str("gcp"), "image": str("nginx"), "secrets": strMap(map[string]string{"DB": "https://v.vault.azure.net/#db"})}, true},
+		{"azure secret", map[string]tftypes.Value{"type": str("azure"), "image": str("nginx"), "secrets": strMap(map[string]string{"DB": "https://v.vault.azure.net/#db"})}, false},
+		{"secret and variable", map[string]tftypes.Value{"type": str("gcp"), "image": str("nginx"),
+			"environment": strMap(map[string]string{"DB": "x"}), "secrets": strMap(map[string]string{"DB": "projects/p/secrets/db"})}, true},
+		{"cloud run no ingress", map[string]tftypes.Value{"type": str("gcp"), "containers": containerList(containerValue("a", "a", nil), containerValue("b", "b", nil))}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.vals["name"] = str("app")
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestECSContainerDefinitions(t *testing.T) {
+	r := &ServerlessContainerResource{}
+	const secret = "arn:aws:secretsmanager:us-east-1:123456789012:secret:db-AbCdEf"
+	vals := map[string]tftypes.Value{"name": str("app"), "type": str("aws"), "containers": containerList(
+		containerValue("web", "nginx", map[string]string{"MODE": "prod"}, 80, 443),
+		containerValue("log", "fluentbit", nil),
+	), "secrets": strMap(map[string]string{"DB_PASSWORD": secret})}
+	var m serverlessContainerResourceModel
+	plan := testPlan(t, r, vals)
+	if diags := plan.Get(context.Background(), &m); diags.HasError() {
+		t.Fatal(diags)
+	}
+	defs := ecsContainerDefinitions(m.containers())
+	if len(defs) != 2 || aws.ToString(defs[0].Name) != "web" || aws.ToString(defs[1].Image) != "fluentbit" {
+		t.Fatalf("definitions = %+v", defs)
+	}
+	if pm := defs[0].PortMappings; len(pm) != 2 || aws.ToInt32(pm[1].ContainerPort) != 443 {
+		t.Errorf("port mappings = %+v", pm)
+	}
+	if env := defs[0].Environment; len(env) != 1 || aws.ToString(env[0].Value) != "prod" {
+		t.Errorf("environment = %+v", env)
+	}
+	for _, def := range defs {
+		if s := def.Secrets; len(s) != 1 || aws.ToString(s[0].Name) != "DB_PASSWORD" || aws.ToString(s[0].ValueFrom) != secret {
+			t.Errorf("secrets of %s = %+v", aws.ToString(def.Name), s)
+		}
+	}
+}
+
+func TestECSTaskENI(t *testing.T) {
+	detail := func(name, value string) ecstypes.KeyValuePair {
+		return ecstypes.KeyValuePair{Name: aws.String(name), Value: aws.String(value)}
+	}
+	task := ecstypes.Task{Attachments: []ecstypes.Attachment{
+		{Type: aws.String("ServiceConnect")},
+		{Type: aws.String("ElasticNetworkInterface"), Details: []ecstypes.KeyValuePair{
+			detail("subnetId", "subnet-1"),
+			detail("networkInterfaceId", "eni-0abc"),
+		}},
+	}}
+	if eni := ecsTaskENI(task); eni != "eni-0abc" {
+		t.Errorf("eni = %q", eni)
+	}
+	// a provisioning task has no interface yet
+	if eni := ecsTaskENI(ecstypes.Task{}); eni != "" {
+		t.Errorf("eni of a task without attachments = %q", eni)
+	}
+}
+
+func TestAzureContainerGroupSecrets(t *testing.T) {
+	r := &ServerlessContainerResource{}
+	var m serverlessContainerResourceModel
+	plan := testPlan(t, r, map[string]tftypes.Value{"name": str("app"), "type": str("azure"), "image": str("nginx"),
+		"environment": strMap(map[string]string{"LEVEL": "info"}),
+		"secrets":     strMap(map[string]string{"DB_PASSWORD": "https://v.vault.azure.net/#db"}),
+	})
+	if diags := plan.Get(context.Background(), &m); diags.HasError() {
+		t.Fatal(diags)
+	}
+	vars := azureContainerGroup(&m, "westeurope", map[string]string{"DB_PASSWORD": "s3cret"}).Properties.Containers[0].Properties.EnvironmentVariables
+	if len(vars) != 2 || *vars[0].Value != "info" || vars[1].Value != nil || *vars[1].SecureValue != "s3cret" {
+		t.Errorf("environment variables = %+v", vars)
+	}
+}
+
+func TestServerlessContainerAzure(t *testing.T) {
+	const group = "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.ContainerInstance/containerGroups/app"
+	arm := newFakeARM()
+	arm.notFound = true
+	rg, err := armresources.NewResourceGroupsClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg, err := ci.NewContainerGroupsClient("sub", fakeCredential{}, arm.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ServerlessContainerResource{azureRG: rg, azureCI: cg, azureLoc: "westeurope"}
+	ctx := context.Background()
+
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, map[string]tftypes.Value{
+		"name": str("app"), "type": str("azure"), "cpu": number(2048), "memory": number(4096),
+		"containers": containerList(containerValue("web", "nginx", nil, 8080), containerValue("log", "fluentbit", nil)),
+	})}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	put, _ := json.Marshal(arm.puts[group])
+	var sent ci.ContainerGroup
+	json.Unmarshal(put, &sent)
+	p := sent.Properties
+	if len(p.Containers) != 2 || *p.Containers[0].Properties.Resources.Requests.CPU != 1 || *p.Containers[1].Properties.Resources.Requests.MemoryInGB != 2 {
+		t.Fatalf("containers = %s", put)
+	}
+	if p.IPAddress == nil || len(p.IPAddress.Ports) != 1 || *p.IPAddress.Ports[0].Port != 8080 || *p.Containers[0].Properties.Ports[0].Port != 8080 {
+		t.Errorf("ports = %s", put)
+	}
+
+	// the IP is assigned once the group runs
+	arm.puts[group]["properties"].(map[string]any)["ipAddress"].(map[string]any)["ip"] = "20.1.2.3"
+	readResp := &resource.ReadResponse{State: resp.State}
+	r.Read(ctx, resource.ReadRequest{State: resp.State}, readResp)
+	var got serverlessContainerResourceModel
+	readResp.Diagnostics.Append(readResp.State.Get(ctx, &got)...)
+	if readResp.Diagnostics.HasError() {
+		t.Fatalf("read: %v", readResp.Diagnostics)
+	}
+	if got.IPAddress.ValueString() != "20.1.2.3" || got.Endpoint.ValueString() != "20.1.2.3:8080" {
+		t.Errorf("ip_address = %v, endpoint = %v", got.IPAddress, got.Endpoint)
+	}
+}
+
+// cloudRunEnv formats a Cloud Run container's variables, secrets as
+// secret@version.
+func cloudRunEnv(c *run.GoogleCloudRunV2Container) string {
+	var vars []string
+	for _, e := range c.Env {
+		if e.ValueSource != nil {
+			vars = append(vars, e.Name+"="+e.ValueSource.SecretKeyRef.Secret+"@"+e.ValueSource.SecretKeyRef.Version)
+		} else {
+			vars = append(vars, e.Name+"="+e.Value)
+		}
+	}
+	return strings.Join(vars, " ")
+}
+
+func TestServerlessContainerGCP(t *testing.T) {
+	const name = "projects/p/locations/europe-west1/services/app"
+	var svc *run.GoogleCloudRunV2Service
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p := strings.TrimPrefix(req.URL.Path, "/v2/")
+		switch {
+		case req.Method == http.MethodPost && p == "projects/p/locations/europe-west1/services":
+			svc = &run.GoogleCloudRunV2Service{}
+			json.NewDecoder(req.Body).Decode(svc)
+			svc.Name, svc.Uri = name, "https://app-xyz.a.run.app"
+			json.NewEncoder(w).Encode(run.GoogleLongrunningOperation{Name: "operations/create"})
+		case req.Method == http.MethodPatch && p == name:
+			svc = &run.GoogleCloudRunV2Service{}
+			json.NewDecoder(req.Body).Decode(svc)
+			svc.Name, svc.Uri = name, "https://app-xyz.a.run.app"
+			json.NewEncoder(w).Encode(run.GoogleLongrunningOperation{Name: "operations/update"})
+		case req.Method == http.MethodGet && strings.HasPrefix(p, "operations/"):
+			json.NewEncoder(w).Encode(run.GoogleLongrunningOperation{Name: p, Done: true})
+		case req.Method == http.MethodGet && p == name && svc != nil:
+			json.NewEncoder(w).Encode(svc)
+		case req.Method == http.MethodDelete && p == name:
+			svc = nil
+			json.NewEncoder(w).Encode(run.GoogleLongrunningOperation{Name: "operations/delete"})
+		default:
+			t.Errorf("unexpected %s %s", req.Method, req.URL.Path)
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+	runSvc, err := run.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ServerlessContainerResource{gcpRun: runSvc, gcpProj: "p", gcpRegion: "europe-west1"}
+	ctx := context.Background()
+
+	vals := map[string]tftypes.Value{
+		"name": str("app"), "type": str("gcp"), "cpu": number(2048), "memory": number(1024),
+		"containers":  containerList(containerValue("web", "nginx", map[string]string{"MODE": "prod"}, 8080), containerValue("log", "fluentbit", nil)),
+		"environment": strMap(map[string]string{"MODE": "dev", "LEVEL": "info"}),
+		"secrets":     strMap(map[string]string{"DB_PASSWORD": "projects/p/secrets/db"}),
+	}
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, vals)}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	var got serverlessContainerResourceModel
+	resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	if got.ID.ValueString() != name || got.Endpoint.ValueString() != "https://app-xyz.a.run.app" {
+		t.Errorf("id = %v, endpoint = %v", got.ID, got.Endpoint)
+	}
+	cs := svc.Template.Containers
+	if len(cs) != 2 || cs[0].Ports[0].ContainerPort != 8080 || cs[1].Resources.Limits["cpu"] != "1000m" || cs[1].Resources.Limits["memory"] != "512Mi" {
+		t.Errorf("containers = %+v", cs)
+	}
+	// a container's env wins over environment, and secrets are referenced, not copied
+	if env := cloudRunEnv(cs[0]); env != "LEVEL=info MODE=prod DB_PASSWORD=projects/p/secrets/db@latest" {
+		t.Errorf("web env = %s", env)
+	}
+	if env := cloudRunEnv(cs[1]); env != "LEVEL=info MODE=dev DB_PASSWORD=projects/p/secrets/db@latest" {
+		t.Errorf("log env = %s", env)
+	}
+
+	// environment changes roll out a new revision
+	planned := maps.Clone(vals)
+	planned["environment"] = strMap(map[string]string{"LEVEL": "debug"})
+	planned["id"], planned["cloud_id"], planned["endpoint"] = str(name), str(name), str("https://app-xyz.a.run.app")
+	updResp := &resource.UpdateResponse{State: resp.State}
+	r.Update(ctx, resource.UpdateRequest{Plan: testPlan(t, r, planned), State: resp.State}, updResp)
+	if updResp.Diagnostics.HasError() {
+		t.Fatalf("update: %v", updResp.Diagnostics)
+	}
+	if env := cloudRunEnv(svc.Template.Containers[1]); env != "LEVEL=debug DB_PASSWORD=projects/p/secrets/db@latest" {
+		t.Errorf("log env after update = %s", env)
+	}
+
+	delResp := &resource.DeleteResponse{State: resp.State}
+	r.Delete(ctx, resource.DeleteRequest{State: resp.State}, delResp)
+	if delResp.Diagnostics.HasError() || svc != nil {
+		t.Fatalf("delete: %v", delResp.Diagnostics)
+	}
+}
@@ -0,0 +1,358 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"google.golang.org/api/googleapi"
+	iamapi "google.golang.org/api/iam/v1"
+)
+
+// azureGraphURL is the Microsoft Graph endpoint application passwords are
+// managed through; they are not ARM resources.
+const azureGraphURL = "https://graph.microsoft.com/v1.0"
+
+// ServiceAccountKeyResource issues credentials for a principal: an access key
+// of an IAM user on AWS, a password of an application's service principal on
+// Azure or a key of a service account on GCP.
+type ServiceAccountKeyResource struct {
+	iam *iam.Client
+
+	azureCred azcore.TokenCredential
+	// graph sends Microsoft Graph requests to graphURL.
+	graph    *http.Client
+	graphURL string
+
+	gcpIAM *iamapi.Service
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+}
+
+type serviceAccountKeyResourceModel struct {
+	ID        types.String `tfsdk:"id"`
+	CloudID   types.String `tfsdk:"cloud_id"`
+	Type      types.String `tfsdk:"type"`
+	Principal types.String `tfsdk:"principal"`
+	KeyID     types.String `tfsdk:"key_id"`
+	Secret    types.String `tfsdk:"secret"`
+}
+
+func NewServiceAccountKeyResource() resource.Resource { return &ServiceAccountKeyResource{} }
+
+func (r *ServiceAccountKeyResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.iam = cfg.AWSIAM
+	r.azureCred = cfg.AzureCred
+	r.graph = &http.Client{Transport: &shared.LogTransport{Base: http.DefaultTransport}}
+	r.graphURL = azureGraphURL
+	r.gcpIAM = cfg.GCPIAM
+}
+
+func (r *ServiceAccountKeyResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_service_account_key"
+}
+
+func (r *ServiceAccountKeyResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	keep := []planmodifier.String{stringplanmodifier.UseStateForUnknown()}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			// The key ID, which is as sensitive as the rest of the key.
+			"id":       schema.StringAttribute{Computed: true, Sensitive: true, PlanModifiers: keep},
+			"cloud_id": cloudIDAttribute(),
+			"type":     schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// IAM user name, application (client) ID or service account email.
+			"principal": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			"key_id":    schema.StringAttribute{Computed: true, Sensitive: true, PlanModifiers: keep},
+			// Secret access key, client secret or service account key file.
+			"secret": schema.StringAttribute{Computed: true, Sensitive: true, PlanModifiers: keep},
+		},
+	}
+}
+
+func (r *ServiceAccountKeyResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg serviceAccountKeyResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() || cfg.Principal.IsUnknown() {
+		return
+	}
+	p := cfg.Principal.ValueString()
+	switch cfg.Type.ValueString() {
+	case "aws":
+		if strings.HasPrefix(p, "arn:") {
+			resp.Diagnostics.AddAttributeError(path.Root("principal"), "invalid principal", "aws access keys belong to an IAM user; give the user name, not its ARN")
+		}
+	case "azure":
+		if !azureGUID.MatchString(p) {
+			resp.Diagnostics.AddAttributeError(path.Root("principal"), "invalid principal", fmt.Sprintf("%q is not an application (client) ID", p))
+		}
+	case "gcp":
+		if !strings.Contains(p, "@") {
+			resp.Diagnostics.AddAttributeError(path.Root("principal"), "invalid principal", fmt.Sprintf("%q is not a service account email, such as the id of a gcp abstract_iam_role", p))
+		}
+	}
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *ServiceAccountKeyResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.iam != nil
+	case "azure":
+		return r.azureCred != nil && r.graph != nil
+	case "gcp":
+		return r.gcpIAM != nil
+	}
+	return true
+}
+
+// gcpKeyName returns the resource name of key id of a service account.
+func gcpKeyName(email, id string) string {
+	return fmt.Sprintf("projects/-/serviceAccounts/%s/keys/%s", email, id)
+}
+
+// azureApplication returns the Graph path of the application with client ID appID.
+func azureApplication(appID string) string {
+	return fmt.Sprintf("/applications(appId='%s')", url.PathEscape(appID))
+}
+
+// azurePasswordCredential is a password of an application as Graph returns it.
+// SecretText is only set in the response to addPassword.
+type azurePasswordCredential struct {
+	KeyID       string `json:"keyId"`
+	DisplayName string `json:"displayName,omitempty"`
+	SecretText  string `json:"secretText,omitempty"`
+}
+
+// errGraphNotFound is returned for a Graph request on an object that does not exist.
+var errGraphNotFound = errors.New("not found")
+
+// graphDo sends a Microsoft Graph request and decodes the response into out.
+// Error bodies are not included in the error, as some echo the request.
+func (r *ServiceAccountKeyResource) graphDo(ctx context.Context, method, p string, in, out any) error {
+	tok, err := r.azureCred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://graph.microsoft.com/.default"}})
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.graphURL+p, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok.Token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := r.graph.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return errGraphNotFound
+	}
+	if res.StatusCode >= 300 {
+		var e struct {
+			Error struct{ Code string } `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(res.Body, 4096)).Decode(&e)
+		return fmt.Errorf("graph %s: %s", res.Status, e.Error.Code)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func (r *ServiceAccountKeyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan serviceAccountKeyResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	principal := plan.Principal.ValueString()
+	switch plan.Type.ValueString() {
+	case "aws":
+		out, err := r.iam.CreateAccessKey(ctx, &iam.CreateAccessKeyInput{UserName: aws.String(principal)})
+		if err != nil {
+			resp.Diagnostics.AddError("aws create", err.Error())
+			return
+		}
+		plan.KeyID = types.StringValue(aws.ToString(out.AccessKey.AccessKeyId))
+		plan.Secret = types.StringValue(aws.ToString(out.AccessKey.SecretAccessKey))
+	case "azure":
+		var cred azurePasswordCredential
+		in := map[string]any{"passwordCredential": azurePasswordCredential{DisplayName: "abstract_service_account_key"}}
+		if err := r.graphDo(ctx, http.MethodPost, azureApplication(principal)+"/addPassword", in, &cred); err != nil {
+			resp.Diagnostics.AddError("azure create", err.Error())
+			return
+		}
+		plan.KeyID = types.StringValue(cred.KeyID)
+		plan.Secret = types.StringValue(cred.SecretText)
+	case "gcp":
+		key, err := r.gcpIAM.Projects.ServiceAccounts.Keys.Create("projects/-/serviceAccounts/"+principal, &iamapi.CreateServiceAccountKeyRequest{}).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp create", err.Error())
+			return
+		}
+		plan.KeyID = types.StringValue(key.Name[strings.LastIndex(key.Name, "/")+1:])
+		// the key file applications read from GOOGLE_APPLICATION_CREDENTIALS
+		file, err := base64.StdEncoding.DecodeString(key.PrivateKeyData)
+		if err != nil {
+			resp.Diagnostics.AddError("gcp key file", err.Error())
+			// keep the key in state so it is revoked on destroy
+			file = nil
+		}
+		plan.Secret = types.StringValue(string(file))
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	plan.ID = plan.KeyID
+	// the key ID is sensitive, so there is no non-sensitive cloud ID to export
+	plan.CloudID = types.StringNull()
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// Read drops a key that was revoked outside Terraform. The secret cannot be
+// read back from any cloud, so it keeps the value issued at create.
+func (r *ServiceAccountKeyResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state serviceAccountKeyResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	principal, keyID := state.Principal.ValueString(), state.KeyID.ValueString()
+	found := true
+	switch state.Type.ValueString() {
+	case "aws":
+		found = false
+		pages := iam.NewListAccessKeysPaginator(r.iam, &iam.ListAccessKeysInput{UserName: aws.String(principal)})
+		for pages.HasMorePages() && !found {
+			page, err := pages.NextPage(ctx)
+			if err != nil {
+				resp.State.RemoveResource(ctx)
+				return
+			}
+			for _, k := range page.AccessKeyMetadata {
+				found = found || aws.ToString(k.AccessKeyId) == keyID
+			}
+		}
+	case "azure":
+		var app struct {
+			PasswordCredentials []azurePasswordCredential `json:"passwordCredentials"`
+		}
+		err := r.graphDo(ctx, http.MethodGet, azureApplication(principal)+"?$select=passwordCredentials", nil, &app)
+		if errors.Is(err, errGraphNotFound) {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("azure read", err.Error())
+			return
+		}
+		found = slices.ContainsFunc(app.PasswordCredentials, func(c azurePasswordCredential) bool { return c.KeyID == keyID })
+	case "gcp":
+		_, err := r.gcpIAM.Projects.ServiceAccounts.Keys.Get(gcpKeyName(principal, keyID)).Context(ctx).Do()
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+	}
+	if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+// Update has nothing to change; every attribute replaces the key.
+func (r *ServiceAccountKeyResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+}
+
+// Delete revokes the key. A key that is already gone is not an error.
+func (r *ServiceAccountKeyResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state serviceAccountKeyResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	principal, keyID := state.Principal.ValueString(), state.KeyID.ValueString()
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err := r.iam.DeleteAccessKey(ctx, &iam.DeleteAccessKeyInput{UserName: aws.String(principal), AccessKeyId: aws.String(keyID)})
+		var gone *iamtypes.NoSuchEntityException
+		if err != nil && !errors.As(err, &gone) {
+			resp.Diagnostics.AddError("aws delete", err.Error())
+		}
+	case "azure":
+		err := r.graphDo(ctx, http.MethodPost, azureApplication(principal)+"/removePassword", map[string]string{"keyId": keyID}, nil)
+		if err != nil && !errors.Is(err, errGraphNotFound) {
+			resp.Diagnostics.AddError("azure delete", err.Error())
+		}
+	case "gcp":
+		_, err := r.gcpIAM.Projects.ServiceAccounts.Keys.Delete(gcpKeyName(principal, keyID)).Context(ctx).Do()
+		var gErr *googleapi.Error
+		if err != nil && !(errors.As(err, &gErr) && gErr.Code == http.StatusNotFound) {
+			resp.Diagnostics.AddError("gcp delete", err.Error())
+		}
+	}
+}
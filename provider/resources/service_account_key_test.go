@@ -0,0 +1,153 @@
+package resources
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	iamapi "google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+)
+
+const testAppID = "11111111-2222-3333-4444-555555555555"
+
+func TestServiceAccountKeyConfig(t *testing.T) {
+	r := &ServiceAccountKeyResource{}
+	s := testSchema(t, r)
+	for _, tc := range []struct {
+		cloud, principal string
+		errs             bool
+	}{
+		{"aws", "deployer", false},
+		{"aws", "arn:aws:iam::123456789012:user/deployer", true},
+		{"azure", testAppID, false},
+		{"azure", "deployer", true},
+		{"gcp", "deployer@p.iam.gserviceaccount.com", false},
+		{"gcp", "deployer", true},
+	} {
+		t.Run(tc.cloud+" "+tc.principal, func(t *testing.T) {
+			vals := map[string]tftypes.Value{"type": str(tc.cloud), "principal": str(tc.principal)}
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestServiceAccountKeySensitive(t *testing.T) {
+	s := testSchema(t, &ServiceAccountKeyResource{})
+	for _, name := range []string{"id", "key_id", "secret"} {
+		if !s.Attributes[name].IsSensitive() {
+			t.Errorf("%s is not sensitive", name)
+		}
+	}
+}
+
+// fakeGraph serves the passwords of one application.
+type fakeGraph struct {
+	keys []string
+}
+
+func (g *fakeGraph) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	app := "/applications(appId='" + testAppID + "')"
+	if req.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch req.Method + " " + req.URL.Path {
+	case "POST " + app + "/addPassword":
+		id := fmt.Sprintf("key-%d", len(g.keys)+1)
+		g.keys = append(g.keys, id)
+		fmt.Fprintf(w, `{"keyId": %q, "secretText": "s3cret"}`, id)
+	case "POST " + app + "/removePassword":
+		var in struct{ KeyID string }
+		json.NewDecoder(req.Body).Decode(&in)
+		g.keys = slices.DeleteFunc(g.keys, func(k string) bool { return k == in.KeyID })
+		w.WriteHeader(http.StatusNoContent)
+	case "GET " + app:
+		var creds []azurePasswordCredential
+		for _, k := range g.keys {
+			creds = append(creds, azurePasswordCredential{KeyID: k})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"passwordCredentials": creds})
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestServiceAccountKeyAzure(t *testing.T) {
+	graph := &fakeGraph{}
+	srv := httptest.NewServer(graph)
+	defer srv.Close()
+	r := &ServiceAccountKeyResource{azureCred: fakeCredential{}, graph: srv.Client(), graphURL: srv.URL}
+	ctx := context.Background()
+
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(ctx, resource.CreateRequest{Plan: testPlan(t, r, map[string]tftypes.Value{"type": str("azure"), "principal": str(testAppID)})}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	var got serviceAccountKeyResourceModel
+	resp.Diagnostics.Append(resp.State.Get(ctx, &got)...)
+	if got.KeyID.ValueString() != "key-1" || got.ID != got.KeyID || got.Secret.ValueString() != "s3cret" || !got.CloudID.IsNull() {
+		t.Errorf("state = %+v", got)
+	}
+
+	readResp := &resource.ReadResponse{State: resp.State}
+	r.Read(ctx, resource.ReadRequest{State: resp.State}, readResp)
+	if readResp.Diagnostics.HasError() || readResp.State.Raw.IsNull() {
+		t.Fatalf("read: %v", readResp.Diagnostics)
+	}
+
+	delResp := &resource.DeleteResponse{State: resp.State}
+	r.Delete(ctx, resource.DeleteRequest{State: resp.State}, delResp)
+	if delResp.Diagnostics.HasError() || len(graph.keys) != 0 {
+		t.Fatalf("delete: %v, keys %v", delResp.Diagnostics, graph.keys)
+	}
+
+	// a password removed outside Terraform drops out of state
+	readResp = &resource.ReadResponse{State: resp.State}
+	r.Read(ctx, resource.ReadRequest{State: resp.State}, readResp)
+	if !readResp.State.Raw.IsNull() {
+		t.Errorf("revoked key kept in state")
+	}
+}
+
+func TestServiceAccountKeyGCP(t *testing.T) {
+	const name = "projects/p/serviceAccounts/deployer@p.iam.gserviceaccount.com/keys/abc123"
+	file := `{"type": "service_account", "private_key_id": "abc123"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/projects/-/serviceAccounts/deployer@p.iam.gserviceaccount.com/keys") {
+			http.NotFound(w, req)
+			return
+		}
+		json.NewEncoder(w).Encode(iamapi.ServiceAccountKey{Name: name, PrivateKeyData: base64.StdEncoding.EncodeToString([]byte(file))})
+	}))
+	defer srv.Close()
+	svc, err := iamapi.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ServiceAccountKeyResource{gcpIAM: svc}
+	resp := &resource.CreateResponse{State: testState(t, r, nil)}
+	r.Create(context.Background(), resource.CreateRequest{Plan: testPlan(t, r, map[string]tftypes.Value{"type": str("gcp"), "principal": str("deployer@p.iam.gserviceaccount.com")})}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("create: %v", resp.Diagnostics)
+	}
+	var got serviceAccountKeyResourceModel
+	resp.Diagnostics.Append(resp.State.Get(context.Background(), &got)...)
+	if got.KeyID.ValueString() != "abc123" || got.Secret.ValueString() != file {
+		t.Errorf("state = %+v", got)
+	}
+}
@@ -0,0 +1,425 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	compute "google.golang.org/api/compute/v1"
+	sqladmin "google.golang.org/api/sqladmin/v1beta4"
+)
+
+// SnapshotResource manages a point-in-time copy of a disk or database: an
+// EBS or RDS snapshot on AWS, a managed disk snapshot on Azure, or a disk
+// snapshot or Cloud SQL backup on GCP.
+type SnapshotResource struct {
+	ec2 *ec2.Client
+	rds *rds.Client
+
+	azureSnapshots *armcompute.SnapshotsClient
+	azureLoc       string
+
+	gcp     *compute.Service
+	gcpSQL  *sqladmin.Service
+	gcpProj string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	ids *shared.CloudIDs
+}
+
+type snapshotResourceModel struct {
+	ID       types.String `tfsdk:"id"`
+	CloudID  types.String `tfsdk:"cloud_id"`
+	Type     types.String `tfsdk:"type"`
+	Name     types.String `tfsdk:"name"`
+	Kind     types.String `tfsdk:"kind"`
+	SourceID types.String `tfsdk:"source_id"`
+	Region   types.String `tfsdk:"region"`
+}
+
+func (m *snapshotResourceModel) database() bool { return m.Kind.ValueString() == "database" }
+
+func NewSnapshotResource() resource.Resource { return &SnapshotResource{} }
+
+func (r *SnapshotResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.ids = cfg.CloudIDs
+	r.ec2 = cfg.AWSEC2
+	r.rds = cfg.AWSRDS
+	r.azureSnapshots = cfg.AzureSnapshotClient
+	r.azureLoc = cfg.AzureLocation
+	r.gcp = cfg.GCPCompute
+	r.gcpSQL = cfg.GCPCloudSQL
+	r.gcpProj = cfg.GCPProject
+}
+
+func (r *SnapshotResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_snapshot"
+}
+
+func (r *SnapshotResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			// EBS snapshot ID, RDS snapshot identifier, Azure snapshot resource ID,
+			// GCP snapshot name or Cloud SQL backup ID.
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"type": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			"name": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// disk or database.
+			"kind": schema.StringAttribute{Optional: true, Computed: true, Default: stringdefault.StaticString("disk"), PlanModifiers: replace},
+			// A volume from abstract_instance's volume_ids, or an abstract_database id.
+			"source_id": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// Azure location, which must be the disk's, or GCP storage location.
+			"region": schema.StringAttribute{Optional: true, PlanModifiers: replace},
+
+			"cloud_id": cloudIDAttribute(),
+		},
+	}
+}
+
+// gcpResourceName matches the names GCP accepts for snapshots.
+var gcpResourceName = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+func (r *SnapshotResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cfg snapshotResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &cfg)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if k := cfg.Kind.ValueString(); !cfg.Kind.IsUnknown() && k != "" && k != "disk" && k != "database" {
+		resp.Diagnostics.AddAttributeError(path.Root("kind"), "invalid kind", fmt.Sprintf("%q is not disk or database", k))
+		return
+	}
+	if cfg.Type.IsUnknown() || cfg.Kind.IsUnknown() {
+		return
+	}
+	name, source := cfg.Name.ValueString(), cfg.SourceID.ValueString()
+	switch cfg.Type.ValueString() {
+	case "aws":
+		if !cfg.Region.IsNull() {
+			resp.Diagnostics.AddAttributeWarning(path.Root("region"), "ignored", "aws snapshots are created in the provider region")
+		}
+	case "azure":
+		if cfg.database() {
+			resp.Diagnostics.AddAttributeError(path.Root("kind"), "unsupported kind", "Azure flexible servers only take automatic backups")
+		}
+	case "gcp":
+		if !cfg.Name.IsUnknown() && !cfg.database() && !gcpResourceName.MatchString(name) {
+			resp.Diagnostics.AddAttributeError(path.Root("name"), "invalid name", "GCP snapshot names are 1 to 63 lowercase letters, digits and hyphens, starting with a letter")
+		}
+		if !cfg.SourceID.IsUnknown() && !cfg.database() && !strings.Contains(source, "/disks/") {
+			resp.Diagnostics.AddAttributeError(path.Root("source_id"), "invalid source_id", "GCP disks are given by self link or zones/<zone>/disks/<name>, as in abstract_instance's volume_ids")
+		}
+	}
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *SnapshotResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.ec2 != nil && r.rds != nil
+	case "azure":
+		return r.azureSnapshots != nil
+	case "gcp":
+		return r.gcp != nil && r.gcpSQL != nil
+	}
+	return false
+}
+
+func (r *SnapshotResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan snapshotResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	name, source := plan.Name.ValueString(), plan.SourceID.ValueString()
+	plan.CloudID = types.StringNull()
+	switch plan.Type.ValueString() {
+	case "aws":
+		if plan.database() {
+			_, err := r.rds.CreateDBSnapshot(ctx, &rds.CreateDBSnapshotInput{DBInstanceIdentifier: aws.String(source), DBSnapshotIdentifier: aws.String(name)})
+			if err != nil {
+				resp.Diagnostics.AddError("aws create snapshot", err.Error())
+				return
+			}
+			plan.ID = types.StringValue(name)
+			err = rds.NewDBSnapshotAvailableWaiter(r.rds).Wait(ctx, &rds.DescribeDBSnapshotsInput{DBSnapshotIdentifier: aws.String(name)}, time.Hour)
+			if err != nil {
+				resp.Diagnostics.AddError("aws create snapshot", err.Error())
+				// keep the snapshot in state so it is not leaked
+				resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+				return
+			}
+			break
+		}
+		out, err := r.ec2.CreateSnapshot(ctx, &ec2.CreateSnapshotInput{
+			VolumeId: aws.String(source),
+			TagSpecifications: []ec2types.TagSpecification{{
+				ResourceType: ec2types.ResourceTypeSnapshot,
+				Tags:         []ec2types.Tag{{Key: aws.String("Name"), Value: aws.String(name)}},
+			}},
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("aws create snapshot", err.Error())
+			return
+		}
+		id := aws.ToString(out.SnapshotId)
+		plan.ID = types.StringValue(id)
+		err = ec2.NewSnapshotCompletedWaiter(r.ec2).Wait(ctx, &ec2.DescribeSnapshotsInput{SnapshotIds: []string{id}}, time.Hour)
+		if err != nil {
+			resp.Diagnostics.AddError("aws create snapshot", err.Error())
+			// keep the snapshot in state so it is not leaked
+			resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+			return
+		}
+	case "azure":
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		poller, err := r.azureSnapshots.BeginCreateOrUpdate(ctx, "abstract-rg", name, armcompute.Snapshot{
+			Location: &loc,
+			Properties: &armcompute.SnapshotProperties{
+				CreationData: &armcompute.CreationData{
+					CreateOption:     to.Ptr(armcompute.DiskCreateOptionCopy),
+					SourceResourceID: &source,
+				},
+				// only the blocks changed since the last snapshot are stored
+				Incremental: to.Ptr(true),
+			},
+		}, nil)
+		var res armcompute.SnapshotsClientCreateOrUpdateResponse
+		if err == nil {
+			res, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure create snapshot", err)
+			return
+		}
+		if res.ID == nil {
+			resp.Diagnostics.AddError("azure create snapshot", "snapshot has no ID")
+			return
+		}
+		plan.ID = types.StringValue(*res.ID)
+	case "gcp":
+		if plan.database() {
+			op, err := r.gcpSQL.BackupRuns.Insert(r.gcpProj, source, &sqladmin.BackupRun{Description: name}).Context(ctx).Do()
+			if err == nil {
+				op, err = waitSQLOperation(ctx, r.gcpSQL, r.gcpProj, op)
+			}
+			if err == nil && (op.BackupContext == nil || op.BackupContext.BackupId == 0) {
+				err = fmt.Errorf("operation %s did not report a backup", op.Name)
+			}
+			if err != nil {
+				resp.Diagnostics.AddError("gcp create backup", err.Error())
+				return
+			}
+			plan.ID = types.StringValue(strconv.FormatInt(op.BackupContext.BackupId, 10))
+			break
+		}
+		snap := &compute.Snapshot{Name: name, SourceDisk: source}
+		if region := plan.Region.ValueString(); region != "" {
+			snap.StorageLocations = []string{region}
+		}
+		op, err := r.gcp.Snapshots.Insert(r.gcpProj, snap).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp create snapshot", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(name)
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	id, err := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, err)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// cloudID is the snapshot's ARN, resource ID or self link; Cloud SQL backups
+// have the self link of their backup run.
+func (r *SnapshotResource) cloudID(ctx context.Context, m *snapshotResourceModel) (string, error) {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		if m.database() {
+			return r.ids.ARN(ctx, "rds", true, "snapshot:"+id)
+		}
+		return r.ids.AccountlessARN("ec2", true, "snapshot/"+id), nil
+	case "azure":
+		return id, nil
+	case "gcp":
+		if m.database() {
+			if r.gcpProj == "" {
+				return "", nil
+			}
+			return fmt.Sprintf("https://sqladmin.googleapis.com/sql/v1beta4/projects/%s/instances/%s/backupRuns/%s", r.gcpProj, m.SourceID.ValueString(), id), nil
+		}
+		return r.ids.GCPSelfLink("global/snapshots/" + id), nil
+	}
+	return "", nil
+}
+
+func (r *SnapshotResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state snapshotResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	id := state.ID.ValueString()
+	var err error
+	switch state.Type.ValueString() {
+	case "aws":
+		if state.database() {
+			_, err = r.rds.DescribeDBSnapshots(ctx, &rds.DescribeDBSnapshotsInput{DBSnapshotIdentifier: aws.String(id)})
+			break
+		}
+		var out *ec2.DescribeSnapshotsOutput
+		out, err = r.ec2.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{SnapshotIds: []string{id}})
+		if err == nil && len(out.Snapshots) == 0 {
+			err = fmt.Errorf("snapshot %s not found", id)
+		}
+	case "azure":
+		_, err = r.azureSnapshots.Get(ctx, "abstract-rg", state.Name.ValueString(), nil)
+	case "gcp":
+		if state.database() {
+			backup, perr := strconv.ParseInt(id, 10, 64)
+			if perr != nil {
+				resp.Diagnostics.AddError("invalid backup id", perr.Error())
+				return
+			}
+			_, err = r.gcpSQL.BackupRuns.Get(r.gcpProj, state.SourceID.ValueString(), backup).Context(ctx).Do()
+			break
+		}
+		_, err = r.gcp.Snapshots.Get(r.gcpProj, id).Context(ctx).Do()
+	}
+	if err != nil {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+// Update is never called: every attribute replaces the snapshot.
+func (r *SnapshotResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan snapshotResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *SnapshotResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state snapshotResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	id := state.ID.ValueString()
+	switch state.Type.ValueString() {
+	case "aws":
+		var err error
+		if state.database() {
+			_, err = r.rds.DeleteDBSnapshot(ctx, &rds.DeleteDBSnapshotInput{DBSnapshotIdentifier: aws.String(id)})
+		} else {
+			_, err = r.ec2.DeleteSnapshot(ctx, &ec2.DeleteSnapshotInput{SnapshotId: aws.String(id)})
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("aws delete", err.Error())
+		}
+	case "azure":
+		poller, err := r.azureSnapshots.BeginDelete(ctx, "abstract-rg", state.Name.ValueString(), nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete", err)
+		}
+	case "gcp":
+		var err error
+		if state.database() {
+			backup, perr := strconv.ParseInt(id, 10, 64)
+			if perr != nil {
+				resp.Diagnostics.AddError("invalid backup id", perr.Error())
+				return
+			}
+			var op *sqladmin.Operation
+			op, err = r.gcpSQL.BackupRuns.Delete(r.gcpProj, state.SourceID.ValueString(), backup).Context(ctx).Do()
+			if err == nil {
+				_, err = waitSQLOperation(ctx, r.gcpSQL, r.gcpProj, op)
+			}
+		} else {
+			var op *compute.Operation
+			op, err = r.gcp.Snapshots.Delete(r.gcpProj, id).Context(ctx).Do()
+			if err == nil {
+				err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+			}
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete", err.Error())
+		}
+	}
+}
+
+// waitSQLOperation polls a Cloud SQL operation until it is done and returns
+// the finished operation.
+func waitSQLOperation(ctx context.Context, svc *sqladmin.Service, project string, op *sqladmin.Operation) (*sqladmin.Operation, error) {
+	for op.Status != "DONE" {
+		if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+			return nil, err
+		}
+		var err error
+		op, err = svc.Operations.Get(project, op.Name).Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		return nil, fmt.Errorf("%s", op.Error.Errors[0].Message)
+	}
+	return op, nil
+}
@@ -0,0 +1,68 @@
+package resources
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestSnapshotConfig(t *testing.T) {
+	r := &SnapshotResource{}
+	s := testSchema(t, r)
+	snap := func(cloud, kind, source string, extra map[string]tftypes.Value) map[string]tftypes.Value {
+		vals := map[string]tftypes.Value{"type": str(cloud), "name": str("nightly"), "kind": str(kind), "source_id": str(source)}
+		maps.Copy(vals, extra)
+		return vals
+	}
+	const gcpDisk = "https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-a/disks/web"
+	cases := []struct {
+		name  string
+		vals  map[string]tftypes.Value
+		errs  bool
+		warns bool
+	}{
+		{"aws disk", snap("aws", "disk", "vol-0abc", nil), false, false},
+		{"aws database", snap("aws", "database", "orders-db", nil), false, false},
+		{"aws region", snap("aws", "disk", "vol-0abc", map[string]tftypes.Value{"region": str("us-west-2")}), false, true},
+		{"bad kind", snap("aws", "volume", "vol-0abc", nil), true, false},
+		{"azure disk", snap("azure", "disk", "/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Compute/disks/web_OsDisk", nil), false, false},
+		{"azure database", snap("azure", "database", "orders-db", nil), true, false},
+		{"gcp disk", snap("gcp", "disk", gcpDisk, nil), false, false},
+		{"gcp disk name", snap("gcp", "disk", "web", nil), true, false},
+		{"gcp uppercase name", snap("gcp", "disk", gcpDisk, map[string]tftypes.Value{"name": str("Nightly")}), true, false},
+		{"gcp backup", snap("gcp", "database", "orders-db", map[string]tftypes.Value{"name": str("Nightly backup")}), false, false},
+		{"unknown source", snap("gcp", "disk", gcpDisk, map[string]tftypes.Value{"source_id": tftypes.NewValue(tftypes.String, tftypes.UnknownValue)}), false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs || (resp.Diagnostics.WarningsCount() > 0) != tc.warns {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestSnapshotDeleteMissingClient(t *testing.T) {
+	r := &SnapshotResource{}
+	for _, cloud := range []string{"aws", "azure", "gcp"} {
+		for _, kind := range []string{"disk", "database"} {
+			t.Run(cloud+" "+kind, func(t *testing.T) {
+				state := testState(t, r, map[string]tftypes.Value{"id": str("1"), "type": str(cloud), "name": str("nightly"), "kind": str(kind), "source_id": str("src")})
+				resp := &resource.DeleteResponse{State: state}
+				r.Delete(context.Background(), resource.DeleteRequest{State: state}, resp)
+				if !resp.Diagnostics.HasError() {
+					t.Fatal("delete without a client reported success")
+				}
+				if d := resp.Diagnostics.Errors()[0]; d.Summary() != cloud+" not configured in provider block" {
+					t.Errorf("diagnostic = %q", d.Summary())
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,587 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// StaticIPResource manages a reserved public IPv4 address: an Elastic IP on
+// AWS, a static Standard public IP on Azure or a regional external address on
+// GCP. The address outlives the instance it is attached to.
+type StaticIPResource struct {
+	ec2 *ec2.Client
+
+	azureRG  *armresources.ResourceGroupsClient
+	azurePIP *armnetwork.PublicIPAddressesClient
+	azureNIC *armnetwork.InterfacesClient
+	azureVM  *armcompute.VirtualMachinesClient
+	azureLoc string
+
+	gcp       *compute.Service
+	gcpProj   string
+	gcpRegion string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	ids *shared.CloudIDs
+}
+
+type staticIPResourceModel struct {
+	ID         types.String `tfsdk:"id"`
+	CloudID    types.String `tfsdk:"cloud_id"`
+	Type       types.String `tfsdk:"type"`
+	Name       types.String `tfsdk:"name"`
+	Region     types.String `tfsdk:"region"`
+	Address    types.String `tfsdk:"address"`
+	InstanceID types.String `tfsdk:"instance_id"`
+}
+
+func NewStaticIPResource() resource.Resource { return &StaticIPResource{} }
+
+func (r *StaticIPResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.ids = cfg.CloudIDs
+	r.ec2 = cfg.AWSEC2
+	r.azureRG = cfg.AzureRGClient
+	r.azurePIP = cfg.AzurePIPClient
+	r.azureNIC = cfg.AzureNICClient
+	r.azureVM = cfg.AzureVMClient
+	r.azureLoc = cfg.AzureLocation
+	r.gcp = cfg.GCPCompute
+	r.gcpProj = cfg.GCPProject
+	r.gcpRegion = cfg.GCPRegion
+}
+
+func (r *StaticIPResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_static_ip"
+}
+
+func (r *StaticIPResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	computed := []planmodifier.String{stringplanmodifier.UseStateForUnknown()}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			// Elastic IP allocation ID, Azure public IP resource ID or GCP address name.
+			"id":       schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			"cloud_id": cloudIDAttribute(),
+			"type":     schema.StringAttribute{Required: true, PlanModifiers: replace},
+			"name":     schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// Azure location, or GCP region or zone; a GCP zone is where instance_id is looked up.
+			"region":  schema.StringAttribute{Optional: true, PlanModifiers: replace},
+			"address": schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			// An abstract_instance's id to attach the address to, changed in place.
+			"instance_id": schema.StringAttribute{Optional: true},
+		},
+	}
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *StaticIPResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.ec2 = c.EC2
+	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *StaticIPResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.ec2 != nil
+	case "azure":
+		return r.azureRG != nil && r.azurePIP != nil && r.azureNIC != nil && r.azureVM != nil
+	case "gcp":
+		return r.gcp != nil
+	}
+	return false
+}
+
+// gcpZone returns the zone GCP instances are looked up in; the address is
+// reserved in its region.
+func (r *StaticIPResource) gcpZone(m *staticIPResourceModel) string {
+	zone := m.Region.ValueString()
+	if zone == "" {
+		zone = r.gcpRegion
+	}
+	if zone == "" {
+		zone = "us-central1-a"
+	}
+	return zone
+}
+
+func (r *StaticIPResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan staticIPResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.CloudID = types.StringNull()
+	var err error
+	switch plan.Type.ValueString() {
+	case "aws":
+		err = r.createAWS(ctx, &plan)
+	case "azure":
+		err = r.createAzure(ctx, &plan)
+	case "gcp":
+		err = r.createGCP(ctx, &plan)
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(plan.Type.ValueString()+" create static ip", err.Error())
+		return
+	}
+	if instance := plan.InstanceID.ValueString(); instance != "" {
+		if err := r.attach(ctx, &plan, instance); err != nil {
+			resp.Diagnostics.AddError(plan.Type.ValueString()+" attach static ip", err.Error())
+			// keep the address in state so it is released on destroy
+			plan.InstanceID = types.StringNull()
+		}
+	}
+	id, idErr := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, idErr)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// cloudID is the Elastic IP's ARN, the public IP's resource ID or the
+// address's self link.
+func (r *StaticIPResource) cloudID(ctx context.Context, m *staticIPResourceModel) (string, error) {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.ARN(ctx, "ec2", true, "elastic-ip/"+id)
+	case "azure":
+		return id, nil
+	case "gcp":
+		return r.ids.GCPSelfLink(fmt.Sprintf("regions/%s/addresses/%s", gcpZoneRegion(r.gcpZone(m)), id)), nil
+	}
+	return "", nil
+}
+
+func (r *StaticIPResource) createAWS(ctx context.Context, plan *staticIPResourceModel) error {
+	out, err := r.ec2.AllocateAddress(ctx, &ec2.AllocateAddressInput{
+		Domain: ec2types.DomainTypeVpc,
+		TagSpecifications: []ec2types.TagSpecification{{
+			ResourceType: ec2types.ResourceTypeElasticIp,
+			Tags:         []ec2types.Tag{{Key: aws.String("Name"), Value: aws.String(plan.Name.ValueString())}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	plan.ID = types.StringValue(aws.ToString(out.AllocationId))
+	plan.Address = types.StringValue(aws.ToString(out.PublicIp))
+	return nil
+}
+
+func (r *StaticIPResource) createAzure(ctx context.Context, plan *staticIPResourceModel) error {
+	rgName := "abstract-rg"
+	loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+	if err := shared.EnsureResourceGroup(ctx, r.azureRG, rgName, loc); err != nil {
+		return err
+	}
+	poller, err := r.azurePIP.BeginCreateOrUpdate(ctx, rgName, plan.Name.ValueString(), armnetwork.PublicIPAddress{
+		Location: &loc,
+		SKU:      &armnetwork.PublicIPAddressSKU{Name: to.Ptr(armnetwork.PublicIPAddressSKUNameStandard)},
+		Properties: &armnetwork.PublicIPAddressPropertiesFormat{
+			PublicIPAllocationMethod: to.Ptr(armnetwork.IPAllocationMethodStatic),
+		},
+	}, nil)
+	var pip armnetwork.PublicIPAddressesClientCreateOrUpdateResponse
+	if err == nil {
+		pip, err = shared.PollAzure(ctx, poller)
+	}
+	if err != nil {
+		return err
+	}
+	if pip.ID == nil || pip.Properties == nil || pip.Properties.IPAddress == nil {
+		return fmt.Errorf("public ip %s has no address", plan.Name.ValueString())
+	}
+	plan.ID = types.StringValue(*pip.ID)
+	plan.Address = types.StringValue(*pip.Properties.IPAddress)
+	return nil
+}
+
+func (r *StaticIPResource) createGCP(ctx context.Context, plan *staticIPResourceModel) error {
+	name, region := plan.Name.ValueString(), gcpZoneRegion(r.gcpZone(plan))
+	op, err := r.gcp.Addresses.Insert(r.gcpProj, region, &compute.Address{Name: name}).Context(ctx).Do()
+	if err == nil {
+		err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+	}
+	if err != nil {
+		return err
+	}
+	addr, err := r.gcp.Addresses.Get(r.gcpProj, region, name).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	plan.ID = types.StringValue(name)
+	plan.Address = types.StringValue(addr.Address)
+	return nil
+}
+
+// attach associates the address with an instance, replacing the instance's
+// current public address.
+func (r *StaticIPResource) attach(ctx context.Context, m *staticIPResourceModel, instance string) error {
+	switch m.Type.ValueString() {
+	case "aws":
+		return awsAssociateAddress(ctx, r.ec2, m.ID.ValueString(), instance)
+	case "azure":
+		return azureSetVMPublicIP(ctx, r.azureVM, r.azureNIC, instance, m.ID.ValueString(), true)
+	case "gcp":
+		return gcpSetNAT(ctx, r.gcp, r.gcpProj, r.gcpZone(m), instance, m.Address.ValueString(), true)
+	}
+	return nil
+}
+
+// detach removes the address from an instance, leaving it without a public
+// address on Azure and GCP.
+func (r *StaticIPResource) detach(ctx context.Context, m *staticIPResourceModel, instance string) error {
+	switch m.Type.ValueString() {
+	case "aws":
+		return awsDisassociateAddress(ctx, r.ec2, m.ID.ValueString(), instance)
+	case "azure":
+		return azureSetVMPublicIP(ctx, r.azureVM, r.azureNIC, instance, m.ID.ValueString(), false)
+	case "gcp":
+		return gcpSetNAT(ctx, r.gcp, r.gcpProj, r.gcpZone(m), instance, m.Address.ValueString(), false)
+	}
+	return nil
+}
+
+// eipAssociations is the part of the EC2 API that moves Elastic IPs between
+// instances.
+type eipAssociations interface {
+	AssociateAddress(ctx context.Context, params *ec2.AssociateAddressInput, optFns ...func(*ec2.Options)) (*ec2.AssociateAddressOutput, error)
+	DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error)
+	DisassociateAddress(ctx context.Context, params *ec2.DisassociateAddressInput, optFns ...func(*ec2.Options)) (*ec2.DisassociateAddressOutput, error)
+}
+
+// awsAssociateAddress associates an Elastic IP with an instance, taking it
+// from any instance it is associated with.
+func awsAssociateAddress(ctx context.Context, ec2Client eipAssociations, allocationID, instance string) error {
+	_, err := ec2Client.AssociateAddress(ctx, &ec2.AssociateAddressInput{
+		AllocationId:       aws.String(allocationID),
+		InstanceId:         aws.String(instance),
+		AllowReassociation: aws.Bool(true),
+	})
+	return err
+}
+
+// awsDisassociateAddress disassociates an Elastic IP from an instance; one
+// associated elsewhere is left alone.
+func awsDisassociateAddress(ctx context.Context, ec2Client eipAssociations, allocationID, instance string) error {
+	out, err := ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{AllocationIds: []string{allocationID}})
+	if err != nil {
+		return err
+	}
+	for _, a := range out.Addresses {
+		if a.AssociationId != nil && aws.ToString(a.InstanceId) == instance {
+			if _, err := ec2Client.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{AssociationId: a.AssociationId}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// azureSetVMPublicIP points the primary IP configuration of a VM's primary
+// NIC at the public IP, or clears it when it still points there.
+func azureSetVMPublicIP(ctx context.Context, vms shared.AzureVMs, nics *armnetwork.InterfacesClient, vmID, pipID string, attached bool) error {
+	vmName := azureVMName(vmID)
+	vm, err := vms.Get(ctx, "abstract-rg", vmName, nil)
+	if err != nil {
+		return err
+	}
+	if vm.Properties == nil || vm.Properties.NetworkProfile == nil || len(vm.Properties.NetworkProfile.NetworkInterfaces) == 0 {
+		return fmt.Errorf("vm %s has no network interface", vmName)
+	}
+	nicID := *vm.Properties.NetworkProfile.NetworkInterfaces[0].ID
+	for _, ref := range vm.Properties.NetworkProfile.NetworkInterfaces {
+		if ref.Properties != nil && ref.Properties.Primary != nil && *ref.Properties.Primary {
+			nicID = *ref.ID
+		}
+	}
+	nicName := nicID[strings.LastIndex(nicID, "/")+1:]
+	nic, err := nics.Get(ctx, "abstract-rg", nicName, nil)
+	if err != nil {
+		return err
+	}
+	ipc, err := azurePrimaryIPConfig(&nic.Interface)
+	if err != nil {
+		return err
+	}
+	current := ipc.Properties.PublicIPAddress
+	switch {
+	case attached:
+		ipc.Properties.PublicIPAddress = &armnetwork.PublicIPAddress{ID: to.Ptr(pipID)}
+	case current != nil && current.ID != nil && strings.EqualFold(*current.ID, pipID):
+		ipc.Properties.PublicIPAddress = nil
+	default:
+		return nil
+	}
+	poller, err := nics.BeginCreateOrUpdate(ctx, "abstract-rg", nicName, nic.Interface, nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, poller)
+	}
+	return err
+}
+
+// gcpSetNAT gives an instance's first network interface an external NAT on
+// address in place of its current one, or removes the NAT that uses address.
+func gcpSetNAT(ctx context.Context, gcp *compute.Service, project, zone, instance, address string, attached bool) error {
+	inst, err := gcp.Instances.Get(project, zone, instance).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	if len(inst.NetworkInterfaces) == 0 {
+		return fmt.Errorf("instance %s has no network interface", instance)
+	}
+	nic := inst.NetworkInterfaces[0]
+	for _, ac := range nic.AccessConfigs {
+		if !attached && ac.NatIP != address {
+			continue
+		}
+		op, err := gcp.Instances.DeleteAccessConfig(project, zone, instance, ac.Name, nic.Name).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, gcp, project, op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !attached {
+		return nil
+	}
+	op, err := gcp.Instances.AddAccessConfig(project, zone, instance, nic.Name, &compute.AccessConfig{
+		Name:  "External",
+		Type:  "ONE_TO_ONE_NAT",
+		NatIP: address,
+	}).Context(ctx).Do()
+	if err == nil {
+		err = waitComputeOperation(ctx, gcp, project, op)
+	}
+	return err
+}
+
+// gcpAddressInstance returns the name of the instance among an address's
+// users, or "" when no instance uses it.
+func gcpAddressInstance(users []string) string {
+	for _, u := range users {
+		if i := strings.LastIndex(u, "/instances/"); i >= 0 {
+			return u[i+len("/instances/"):]
+		}
+	}
+	return ""
+}
+
+// azureIPConfigNIC returns the name of the NIC an IP configuration ID
+// (".../networkInterfaces/<nic>/ipConfigurations/<name>") belongs to.
+func azureIPConfigNIC(id string) string {
+	parts := strings.Split(id, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if strings.EqualFold(parts[i], "networkInterfaces") {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// setInstance records the instance the address is attached to as read from
+// the cloud, keeping the configured spelling of an equal ID. An unset
+// instance_id stays null: the address may be attached through an
+// abstract_instance's static_ip_id instead.
+func (m *staticIPResourceModel) setInstance(instance string) {
+	switch {
+	case m.InstanceID.IsNull():
+	case instance == "":
+		m.InstanceID = types.StringNull()
+	case !strings.EqualFold(m.InstanceID.ValueString(), instance):
+		m.InstanceID = types.StringValue(instance)
+	}
+}
+
+func (r *StaticIPResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state staticIPResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	var err error
+	switch state.Type.ValueString() {
+	case "aws":
+		var out *ec2.DescribeAddressesOutput
+		out, err = r.ec2.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{AllocationIds: []string{state.ID.ValueString()}})
+		if err == nil && len(out.Addresses) == 0 {
+			err = fmt.Errorf("address %s not found", state.ID.ValueString())
+		}
+		if err == nil {
+			state.Address = types.StringValue(aws.ToString(out.Addresses[0].PublicIp))
+			state.setInstance(aws.ToString(out.Addresses[0].InstanceId))
+		}
+	case "azure":
+		var pip armnetwork.PublicIPAddressesClientGetResponse
+		pip, err = r.azurePIP.Get(ctx, "abstract-rg", state.Name.ValueString(), nil)
+		if err == nil {
+			err = r.readAzureInstance(ctx, &state, pip.PublicIPAddress)
+		}
+	case "gcp":
+		var addr *compute.Address
+		addr, err = r.gcp.Addresses.Get(r.gcpProj, gcpZoneRegion(r.gcpZone(&state)), state.ID.ValueString()).Context(ctx).Do()
+		if err == nil {
+			state.Address = types.StringValue(addr.Address)
+			state.setInstance(gcpAddressInstance(addr.Users))
+		}
+	}
+	if err != nil {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+// readAzureInstance records the address of a public IP and the VM whose NIC
+// uses it.
+func (r *StaticIPResource) readAzureInstance(ctx context.Context, state *staticIPResourceModel, pip armnetwork.PublicIPAddress) error {
+	if pip.Properties == nil {
+		return nil
+	}
+	if pip.Properties.IPAddress != nil {
+		state.Address = types.StringValue(*pip.Properties.IPAddress)
+	}
+	ipc := pip.Properties.IPConfiguration
+	if ipc == nil || ipc.ID == nil {
+		state.setInstance("")
+		return nil
+	}
+	nic, err := r.azureNIC.Get(ctx, "abstract-rg", azureIPConfigNIC(*ipc.ID), nil)
+	if err != nil {
+		return err
+	}
+	if nic.Properties == nil || nic.Properties.VirtualMachine == nil || nic.Properties.VirtualMachine.ID == nil {
+		state.setInstance("")
+		return nil
+	}
+	state.setInstance(*nic.Properties.VirtualMachine.ID)
+	return nil
+}
+
+// Update moves the address between instances; every other attribute
+// replaces it.
+func (r *StaticIPResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state staticIPResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID, plan.Address, plan.CloudID = state.ID, state.Address, state.CloudID
+	if old, instance := state.InstanceID.ValueString(), plan.InstanceID.ValueString(); !strings.EqualFold(old, instance) {
+		if old != "" {
+			if err := r.detach(ctx, &state, old); err != nil {
+				resp.Diagnostics.AddError(plan.Type.ValueString()+" detach static ip", err.Error())
+				return
+			}
+		}
+		if instance != "" {
+			if err := r.attach(ctx, &plan, instance); err != nil {
+				resp.Diagnostics.AddError(plan.Type.ValueString()+" attach static ip", err.Error())
+				plan.InstanceID = types.StringNull()
+			}
+		}
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *StaticIPResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state staticIPResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	// an attached address cannot be released
+	if instance := state.InstanceID.ValueString(); instance != "" {
+		if err := r.detach(ctx, &state, instance); err != nil {
+			resp.Diagnostics.AddError(state.Type.ValueString()+" detach static ip", err.Error())
+			return
+		}
+	}
+	var err error
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err = r.ec2.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: aws.String(state.ID.ValueString())})
+	case "azure":
+		poller, perr := r.azurePIP.BeginDelete(ctx, "abstract-rg", state.Name.ValueString(), nil)
+		err = perr
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+	case "gcp":
+		var op *compute.Operation
+		op, err = r.gcp.Addresses.Delete(r.gcpProj, gcpZoneRegion(r.gcpZone(&state)), state.ID.ValueString()).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(state.Type.ValueString()+" release static ip", err.Error())
+	}
+}
@@ -0,0 +1,48 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestGCPAddressInstance(t *testing.T) {
+	users := []string{
+		"https://www.googleapis.com/compute/v1/projects/p/regions/us-central1/forwardingRules/web",
+		"https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-a/instances/web-1",
+	}
+	if got := gcpAddressInstance(users); got != "web-1" {
+		t.Errorf("gcpAddressInstance = %q", got)
+	}
+	if got := gcpAddressInstance(users[:1]); got != "" {
+		t.Errorf("gcpAddressInstance without an instance = %q", got)
+	}
+}
+
+func TestAzureIPConfigNIC(t *testing.T) {
+	id := "/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Network/networkInterfaces/web-nic/ipConfigurations/ipconfig1"
+	if got := azureIPConfigNIC(id); got != "web-nic" {
+		t.Errorf("azureIPConfigNIC = %q", got)
+	}
+}
+
+func TestStaticIPSetInstance(t *testing.T) {
+	m := staticIPResourceModel{InstanceID: types.StringValue("/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Compute/virtualMachines/web")}
+	m.setInstance("/subscriptions/s/resourceGroups/ABSTRACT-RG/providers/Microsoft.Compute/virtualMachines/web")
+	if m.InstanceID.ValueString() != "/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Compute/virtualMachines/web" {
+		t.Errorf("instance_id = %s, want the configured spelling", m.InstanceID)
+	}
+	m.setInstance("i-0abc")
+	if m.InstanceID.ValueString() != "i-0abc" {
+		t.Errorf("instance_id = %s after moving", m.InstanceID)
+	}
+	m.setInstance("")
+	if !m.InstanceID.IsNull() {
+		t.Errorf("instance_id = %s after detaching", m.InstanceID)
+	}
+	// attached through the instance's static_ip_id
+	m.setInstance("i-0abc")
+	if !m.InstanceID.IsNull() {
+		t.Errorf("instance_id = %s, want it left unset", m.InstanceID)
+	}
+}
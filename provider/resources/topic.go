@@ -0,0 +1,523 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/servicebus/armservicebus"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+// TopicResource manages a publish/subscribe topic and its subscriptions.
+type TopicResource struct {
+	sns *sns.Client
+
+	azureRG   *armresources.ResourceGroupsClient
+	azureNS   *armservicebus.NamespacesClient
+	azureTopc *armservicebus.TopicsClient
+	azureSubs *armservicebus.SubscriptionsClient
+	azureLoc  string
+
+	pubsub  *pubsub.Service
+	gcpProj string
+
+	// timeout is the provider's request_timeout for each operation.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+}
+
+type topicResourceModel struct {
+	ID            types.String             `tfsdk:"id"`
+	CloudID       types.String             `tfsdk:"cloud_id"`
+	Type          types.String             `tfsdk:"type"`
+	Name          types.String             `tfsdk:"name"`
+	Region        types.String             `tfsdk:"region"`
+	Namespace     types.String             `tfsdk:"namespace"`
+	Subscriptions []topicSubscriptionModel `tfsdk:"subscriptions"`
+}
+
+type topicSubscriptionModel struct {
+	ID       types.String `tfsdk:"id"`
+	Name     types.String `tfsdk:"name"`
+	Protocol types.String `tfsdk:"protocol"`
+	Endpoint types.String `tfsdk:"endpoint"`
+}
+
+func NewTopicResource() resource.Resource { return &TopicResource{} }
+
+func (r *TopicResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.sns = cfg.AWSSNS
+	r.azureRG = cfg.AzureRGClient
+	r.azureNS = cfg.AzureSBNamespaceClient
+	r.azureTopc = cfg.AzureSBTopicClient
+	r.azureSubs = cfg.AzureSBSubscriptionClient
+	r.azureLoc = cfg.AzureLocation
+	r.pubsub = cfg.GCPPubSub
+	r.gcpProj = cfg.GCPProject
+}
+
+func (r *TopicResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_topic"
+}
+
+func (r *TopicResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"cloud_id": cloudIDAttribute(),
+			"type": schema.StringAttribute{
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"name": schema.StringAttribute{
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			// Azure location or AWS region; GCP uses the provider project.
+			"region": schema.StringAttribute{
+				Optional:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"namespace": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"subscriptions": schema.ListNestedAttribute{
+				Optional: true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id":   schema.StringAttribute{Computed: true},
+						"name": schema.StringAttribute{Required: true},
+						// SNS protocol such as https, sqs or lambda; ignored on azure and gcp.
+						"protocol": schema.StringAttribute{Optional: true},
+						// SNS endpoint, Service Bus forward-to entity or Pub/Sub push endpoint.
+						"endpoint": schema.StringAttribute{Optional: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+// topicNamespace derives a Service Bus namespace name (6-50 chars, starting with a letter).
+func topicNamespace(name string) string {
+	ns := strings.ToLower(strings.NewReplacer(".", "-", "_", "-").Replace(name)) + "-ns"
+	if len(ns) > 50 {
+		ns = ns[:50]
+	}
+	return ns
+}
+
+// createSubscription creates one subscription and returns its cloud identifier.
+func (r *TopicResource) createSubscription(ctx context.Context, plan *topicResourceModel, sub topicSubscriptionModel) (string, error) {
+	switch plan.Type.ValueString() {
+	case "aws":
+		if sub.Protocol.ValueString() == "" || sub.Endpoint.ValueString() == "" {
+			return "", fmt.Errorf("subscription %s: protocol and endpoint are required on aws", sub.Name.ValueString())
+		}
+		out, err := r.sns.Subscribe(ctx, &sns.SubscribeInput{
+			TopicArn:              aws.String(plan.ID.ValueString()),
+			Protocol:              aws.String(sub.Protocol.ValueString()),
+			Endpoint:              aws.String(sub.Endpoint.ValueString()),
+			ReturnSubscriptionArn: true,
+		})
+		if err != nil {
+			return "", err
+		}
+		return aws.ToString(out.SubscriptionArn), nil
+	case "azure":
+		props := &armservicebus.SBSubscriptionProperties{}
+		if sub.Endpoint.ValueString() != "" {
+			props.ForwardTo = to.Ptr(sub.Endpoint.ValueString())
+		}
+		out, err := r.azureSubs.CreateOrUpdate(ctx, "abstract-rg", plan.Namespace.ValueString(), plan.Name.ValueString(), sub.Name.ValueString(), armservicebus.SBSubscription{Properties: props}, nil)
+		if err != nil {
+			return "", err
+		}
+		return *out.ID, nil
+	case "gcp":
+		s := &pubsub.Subscription{Topic: plan.ID.ValueString()}
+		if sub.Endpoint.ValueString() != "" {
+			s.PushConfig = &pubsub.PushConfig{PushEndpoint: sub.Endpoint.ValueString()}
+		}
+		out, err := r.pubsub.Projects.Subscriptions.Create(fmt.Sprintf("projects/%s/subscriptions/%s", r.gcpProj, sub.Name.ValueString()), s).Context(ctx).Do()
+		if err != nil {
+			return "", err
+		}
+		return out.Name, nil
+	}
+	return "", fmt.Errorf("unsupported cloud %s", plan.Type.ValueString())
+}
+
+// deleteSubscription removes one subscription created by createSubscription.
+func (r *TopicResource) deleteSubscription(ctx context.Context, state *topicResourceModel, sub topicSubscriptionModel) error {
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err := r.sns.Unsubscribe(ctx, &sns.UnsubscribeInput{SubscriptionArn: aws.String(sub.ID.ValueString())})
+		return err
+	case "azure":
+		_, err := r.azureSubs.Delete(ctx, "abstract-rg", state.Namespace.ValueString(), state.Name.ValueString(), sub.Name.ValueString(), nil)
+		return err
+	case "gcp":
+		_, err := r.pubsub.Projects.Subscriptions.Delete(sub.ID.ValueString()).Context(ctx).Do()
+		return err
+	}
+	return nil
+}
+
+// liveSubscription is a subscription the cloud reports for a topic. id is
+// empty for SNS subscriptions still pending confirmation.
+type liveSubscription struct {
+	id, endpoint string
+}
+
+// listSubscriptions returns the topic's subscriptions as the cloud reports them.
+func (r *TopicResource) listSubscriptions(ctx context.Context, state *topicResourceModel) ([]liveSubscription, error) {
+	var live []liveSubscription
+	switch state.Type.ValueString() {
+	case "aws":
+		pages := sns.NewListSubscriptionsByTopicPaginator(r.sns, &sns.ListSubscriptionsByTopicInput{TopicArn: aws.String(state.ID.ValueString())})
+		for pages.HasMorePages() {
+			page, err := pages.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range page.Subscriptions {
+				id := aws.ToString(s.SubscriptionArn)
+				if id == "PendingConfirmation" {
+					id = ""
+				}
+				live = append(live, liveSubscription{id: id, endpoint: aws.ToString(s.Endpoint)})
+			}
+		}
+	case "azure":
+		pages := r.azureSubs.NewListByTopicPager("abstract-rg", state.Namespace.ValueString(), state.Name.ValueString(), nil)
+		for pages.More() {
+			page, err := pages.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range page.Value {
+				sub := liveSubscription{id: *s.ID}
+				if s.Properties != nil && s.Properties.ForwardTo != nil {
+					sub.endpoint = *s.Properties.ForwardTo
+				}
+				live = append(live, sub)
+			}
+		}
+	case "gcp":
+		// the listing only has names; push endpoints are not refreshed
+		err := r.pubsub.Projects.Topics.Subscriptions.List(state.ID.ValueString()).Pages(ctx, func(page *pubsub.ListTopicSubscriptionsResponse) error {
+			for _, name := range page.Subscriptions {
+				live = append(live, liveSubscription{id: name})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return live, nil
+}
+
+// reconcileSubscriptions drops the subscriptions that no longer exist and
+// records endpoints changed outside Terraform. SNS subscriptions pending
+// confirmation are matched by endpoint, as they have no ARN yet.
+func reconcileSubscriptions(subs []topicSubscriptionModel, live []liveSubscription) []topicSubscriptionModel {
+	if subs == nil {
+		return nil
+	}
+	kept := []topicSubscriptionModel{}
+	for _, sub := range subs {
+		for _, l := range live {
+			match := l.id != "" && strings.EqualFold(l.id, sub.ID.ValueString()) ||
+				l.id == "" && l.endpoint == sub.Endpoint.ValueString()
+			if !match {
+				continue
+			}
+			if l.endpoint != "" && !sub.Endpoint.IsNull() {
+				sub.Endpoint = types.StringValue(l.endpoint)
+			}
+			kept = append(kept, sub)
+			break
+		}
+	}
+	return kept
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *TopicResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.sns = c.SNS
+	}
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *TopicResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.sns != nil
+	case "azure":
+		return r.azureRG != nil && r.azureNS != nil && r.azureTopc != nil && r.azureSubs != nil
+	case "gcp":
+		return r.pubsub != nil
+	}
+	return false
+}
+
+func (r *TopicResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan topicResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	name := plan.Name.ValueString()
+	plan.Namespace = types.StringValue("")
+
+	switch plan.Type.ValueString() {
+	case "aws":
+		out, err := r.sns.CreateTopic(ctx, &sns.CreateTopicInput{Name: aws.String(name)})
+		if err != nil {
+			resp.Diagnostics.AddError("aws create", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(aws.ToString(out.TopicArn))
+	case "azure":
+		rgName := "abstract-rg"
+		loc := shared.AzureLocation(plan.Region.ValueString(), r.azureLoc)
+		err := shared.EnsureResourceGroup(ctx, r.azureRG, rgName, loc)
+		if err != nil {
+			resp.Diagnostics.AddError("azure rg", err.Error())
+			return
+		}
+		// topics need at least the Standard tier
+		ns := topicNamespace(name)
+		poller, err := r.azureNS.BeginCreateOrUpdate(ctx, rgName, ns, armservicebus.SBNamespace{
+			Location: &loc,
+			SKU: &armservicebus.SBSKU{
+				Name: to.Ptr(armservicebus.SKUNameStandard),
+				Tier: to.Ptr(armservicebus.SKUTierStandard),
+			},
+		}, nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure create namespace", err)
+			return
+		}
+		topic, err := r.azureTopc.CreateOrUpdate(ctx, rgName, ns, name, armservicebus.SBTopic{}, nil)
+		if err != nil {
+			resp.Diagnostics.AddError("azure create topic", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(*topic.ID)
+		plan.Namespace = types.StringValue(ns)
+	case "gcp":
+		topic, err := r.pubsub.Projects.Topics.Create(fmt.Sprintf("projects/%s/topics/%s", r.gcpProj, name), &pubsub.Topic{}).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp create", err.Error())
+			return
+		}
+		plan.ID = types.StringValue(topic.Name)
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+
+	for i, sub := range plan.Subscriptions {
+		id, err := r.createSubscription(ctx, &plan, sub)
+		if err != nil {
+			resp.Diagnostics.AddError("create subscription", err.Error())
+			// keep the topic in state so it is cleaned up on destroy
+			plan.Subscriptions = plan.Subscriptions[:i]
+			break
+		}
+		plan.Subscriptions[i].ID = types.StringValue(id)
+	}
+	// the ID already is the topic's ARN, resource ID or full name
+	setCloudID(&resp.Diagnostics, &plan.CloudID, plan.ID.ValueString(), nil)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *TopicResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state topicResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		_, err := r.sns.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: aws.String(state.ID.ValueString())})
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+	case "azure":
+		_, err := r.azureTopc.Get(ctx, "abstract-rg", state.Namespace.ValueString(), state.Name.ValueString(), nil)
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+	case "gcp":
+		_, err := r.pubsub.Projects.Topics.Get(state.ID.ValueString()).Context(ctx).Do()
+		if err != nil {
+			resp.State.RemoveResource(ctx)
+			return
+		}
+	}
+	live, err := r.listSubscriptions(ctx, &state)
+	if err != nil {
+		resp.Diagnostics.AddError("read subscriptions", err.Error())
+		return
+	}
+	state.Subscriptions = reconcileSubscriptions(state.Subscriptions, live)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return state.ID.ValueString(), nil })
+}
+
+// Update reconciles subscriptions by name; the topic itself is replaced on any other change.
+func (r *TopicResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, done := shared.StartOperation(ctx, "update", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan, state topicResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.ID = state.ID
+	plan.CloudID = state.CloudID
+	plan.Namespace = state.Namespace
+
+	existing := map[string]topicSubscriptionModel{}
+	for _, sub := range state.Subscriptions {
+		existing[sub.Name.ValueString()] = sub
+	}
+	wanted := map[string]bool{}
+	for _, sub := range plan.Subscriptions {
+		old, ok := existing[sub.Name.ValueString()]
+		if ok && old.Protocol.Equal(sub.Protocol) && old.Endpoint.Equal(sub.Endpoint) {
+			wanted[sub.Name.ValueString()] = true
+		}
+	}
+	for _, sub := range state.Subscriptions {
+		if wanted[sub.Name.ValueString()] {
+			continue
+		}
+		if err := r.deleteSubscription(ctx, &state, sub); err != nil {
+			resp.Diagnostics.AddError("delete subscription", err.Error())
+			return
+		}
+	}
+	for i, sub := range plan.Subscriptions {
+		if wanted[sub.Name.ValueString()] {
+			plan.Subscriptions[i].ID = existing[sub.Name.ValueString()].ID
+			continue
+		}
+		id, err := r.createSubscription(ctx, &plan, sub)
+		if err != nil {
+			resp.Diagnostics.AddError("create subscription", err.Error())
+			return
+		}
+		plan.Subscriptions[i].ID = types.StringValue(id)
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *TopicResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state topicResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	switch state.Type.ValueString() {
+	case "aws":
+		for _, sub := range state.Subscriptions {
+			if err := r.deleteSubscription(ctx, &state, sub); err != nil {
+				resp.Diagnostics.AddError("aws delete subscription", err.Error())
+				return
+			}
+		}
+		_, err := r.sns.DeleteTopic(ctx, &sns.DeleteTopicInput{TopicArn: aws.String(state.ID.ValueString())})
+		if err != nil {
+			resp.Diagnostics.AddError("aws delete", err.Error())
+		}
+	case "azure":
+		// deleting the namespace removes the topic and its subscriptions
+		poller, err := r.azureNS.BeginDelete(ctx, "abstract-rg", state.Namespace.ValueString(), nil)
+		if err == nil {
+			_, err = shared.PollAzure(ctx, poller)
+		}
+		if err != nil {
+			shared.AddAzureError(&resp.Diagnostics, "azure delete", err)
+		}
+	case "gcp":
+		// subscriptions outlive their topic on Pub/Sub, so remove them first
+		for _, sub := range state.Subscriptions {
+			if err := r.deleteSubscription(ctx, &state, sub); err != nil {
+				resp.Diagnostics.AddError("gcp delete subscription", err.Error())
+				return
+			}
+		}
+		_, err := r.pubsub.Projects.Topics.Delete(state.ID.ValueString()).Context(ctx).Do()
+		if err != nil {
+			resp.Diagnostics.AddError("gcp delete", err.Error())
+		}
+	}
+}
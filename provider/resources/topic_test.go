@@ -0,0 +1,37 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestReconcileSubscriptions(t *testing.T) {
+	sub := func(name, id, endpoint string) topicSubscriptionModel {
+		return topicSubscriptionModel{ID: types.StringValue(id), Name: types.StringValue(name), Protocol: types.StringValue("https"), Endpoint: types.StringValue(endpoint)}
+	}
+	subs := []topicSubscriptionModel{
+		sub("orders", "arn:aws:sns:us-east-1:123456789012:t:1", "https://a.example.com"),
+		sub("audit", "arn:aws:sns:us-east-1:123456789012:t:2", "https://b.example.com"),
+		sub("billing", "arn:aws:sns:us-east-1:123456789012:t:3", "https://c.example.com"),
+	}
+	got := reconcileSubscriptions(subs, []liveSubscription{
+		{id: "arn:aws:sns:us-east-1:123456789012:t:1", endpoint: "https://moved.example.com"},
+		// pending confirmation, so listed without an ARN
+		{endpoint: "https://c.example.com"},
+	})
+	if len(got) != 2 || got[0].Name.ValueString() != "orders" || got[1].Name.ValueString() != "billing" {
+		t.Fatalf("subscriptions = %+v, want orders and billing", got)
+	}
+	if got[0].Endpoint.ValueString() != "https://moved.example.com" {
+		t.Errorf("endpoint = %v, want the one the cloud reports", got[0].Endpoint)
+	}
+	if reconcileSubscriptions(nil, nil) != nil {
+		t.Error("unset subscriptions became an empty list")
+	}
+	// Pub/Sub only lists names, which leaves the configured push endpoint alone
+	gcp := []topicSubscriptionModel{sub("push", "projects/p/subscriptions/push", "https://p.example.com")}
+	if got := reconcileSubscriptions(gcp, []liveSubscription{{id: "projects/p/subscriptions/push"}}); len(got) != 1 || got[0].Endpoint.ValueString() != "https://p.example.com" {
+		t.Errorf("gcp subscriptions = %+v", got)
+	}
+}
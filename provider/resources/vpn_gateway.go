@@ -0,0 +1,822 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"abstract-provider/provider/shared"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	schema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// VPNGatewayResource manages a site-to-site IPsec VPN from a network to a
+// peer device: a virtual private gateway, customer gateway and VPN connection
+// on AWS, a virtual network gateway, local network gateway and connection on
+// Azure, or a Classic VPN gateway and tunnel on GCP.
+type VPNGatewayResource struct {
+	ec2 *ec2.Client
+
+	azureVNet    *armnetwork.VirtualNetworksClient
+	azureSubnets *armnetwork.SubnetsClient
+	azurePIP     *armnetwork.PublicIPAddressesClient
+	azureGW      *armnetwork.VirtualNetworkGatewaysClient
+	azureLocalGW *armnetwork.LocalNetworkGatewaysClient
+	azureConn    *armnetwork.VirtualNetworkGatewayConnectionsClient
+
+	gcp       *compute.Service
+	gcpProj   string
+	gcpRegion string
+
+	// timeout is the provider's request_timeout for each operation. Gateways
+	// take a long time to provision, Azure's up to 45 minutes.
+	timeout time.Duration
+
+	// awsRegions hands out the AWS clients of the resource's region.
+	awsRegions *shared.AWSRegions
+
+	ids *shared.CloudIDs
+}
+
+type vpnGatewayResourceModel struct {
+	ID                types.String `tfsdk:"id"`
+	CloudID           types.String `tfsdk:"cloud_id"`
+	Type              types.String `tfsdk:"type"`
+	Name              types.String `tfsdk:"name"`
+	Region            types.String `tfsdk:"region"`
+	NetworkID         types.String `tfsdk:"network_id"`
+	PeerIP            types.String `tfsdk:"peer_ip"`
+	PeerCIDRs         []string     `tfsdk:"peer_cidrs"`
+	SharedKey         types.String `tfsdk:"shared_key"`
+	GatewaySubnetCIDR types.String `tfsdk:"gateway_subnet_cidr"`
+	PublicIP          types.String `tfsdk:"public_ip"`
+	ConnectionID      types.String `tfsdk:"connection_id"`
+}
+
+func NewVPNGatewayResource() resource.Resource { return &VPNGatewayResource{} }
+
+func (r *VPNGatewayResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	cfg, ok := req.ProviderData.(*shared.ProviderConfig)
+	if !ok {
+		resp.Diagnostics.AddError("invalid provider data", "")
+		return
+	}
+	r.timeout = cfg.RequestTimeout
+	r.awsRegions = cfg.AWSRegions
+	r.ids = cfg.CloudIDs
+	r.ec2 = cfg.AWSEC2
+	r.azureVNet = cfg.AzureVNetClient
+	r.azureSubnets = cfg.AzureSubnetClient
+	r.azurePIP = cfg.AzurePIPClient
+	r.azureGW = cfg.AzureVPNGatewayClient
+	r.azureLocalGW = cfg.AzureLocalGatewayClient
+	r.azureConn = cfg.AzureVPNConnectionClient
+	r.gcp = cfg.GCPCompute
+	r.gcpProj = cfg.GCPProject
+	r.gcpRegion = cfg.GCPRegion
+}
+
+func (r *VPNGatewayResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "abstract_vpn_gateway"
+}
+
+func (r *VPNGatewayResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	replace := []planmodifier.String{stringplanmodifier.RequiresReplace()}
+	computed := []planmodifier.String{stringplanmodifier.UseStateForUnknown()}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			// Virtual private gateway ID, Azure gateway resource ID or GCP gateway name.
+			"id":   schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			"type": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			"name": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// GCP region; Azure gateways are created in the network's location.
+			"region": schema.StringAttribute{Optional: true, PlanModifiers: replace},
+			// An abstract_network's id.
+			"network_id": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// Public IPv4 address of the peer device.
+			"peer_ip": schema.StringAttribute{Required: true, PlanModifiers: replace},
+			// Address ranges behind the peer that are routed through the tunnel.
+			"peer_cidrs": schema.ListAttribute{ElementType: types.StringType, Optional: true, PlanModifiers: []planmodifier.List{listplanmodifier.RequiresReplace()}},
+			"shared_key": schema.StringAttribute{Required: true, Sensitive: true, PlanModifiers: replace},
+			// Azure only: range for the GatewaySubnet, added to the network's address space if needed.
+			"gateway_subnet_cidr": schema.StringAttribute{Optional: true, PlanModifiers: replace},
+			// Address the peer connects to.
+			"public_ip": schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			// VPN connection ID, Azure connection resource ID or GCP tunnel name.
+			"connection_id": schema.StringAttribute{Computed: true, PlanModifiers: computed},
+			"cloud_id":      cloudIDAttribute(),
+		},
+	}
+}
+
+// awsPreSharedKey matches the keys AWS accepts for VPN tunnels.
+var awsPreSharedKey = regexp.MustCompile(`^[A-Za-z1-9_.][A-Za-z0-9_.]{7,63}$`)
+
+func (r *VPNGatewayResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var cloud, peerIP, key, gwCIDR types.String
+	var peerCIDRs types.List
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("type"), &cloud)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("peer_ip"), &peerIP)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("shared_key"), &key)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("gateway_subnet_cidr"), &gwCIDR)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("peer_cidrs"), &peerCIDRs)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if ip := peerIP.ValueString(); !peerIP.IsUnknown() && !peerIP.IsNull() {
+		if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+			resp.Diagnostics.AddAttributeError(path.Root("peer_ip"), "invalid peer_ip", fmt.Sprintf("%q is not an IPv4 address", ip))
+		}
+	}
+	for _, e := range peerCIDRs.Elements() {
+		if s, ok := e.(types.String); ok && !s.IsUnknown() {
+			if _, _, err := net.ParseCIDR(s.ValueString()); err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("peer_cidrs"), "invalid peer_cidrs", err.Error())
+			}
+		}
+	}
+	if !gwCIDR.IsUnknown() && !gwCIDR.IsNull() {
+		if _, _, err := net.ParseCIDR(gwCIDR.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("gateway_subnet_cidr"), "invalid gateway_subnet_cidr", err.Error())
+		}
+	}
+	if cloud.IsUnknown() {
+		return
+	}
+	if cloud.ValueString() != "azure" && !gwCIDR.IsNull() {
+		resp.Diagnostics.AddAttributeWarning(path.Root("gateway_subnet_cidr"), "ignored", "gateway_subnet_cidr only applies to azure")
+	}
+	switch cloud.ValueString() {
+	case "aws":
+		if !key.IsUnknown() && !key.IsNull() && !awsPreSharedKey.MatchString(key.ValueString()) {
+			resp.Diagnostics.AddAttributeError(path.Root("shared_key"), "invalid shared_key", "AWS keys are 8 to 64 letters, digits, periods and underscores, not starting with 0")
+		}
+	case "azure":
+		if gwCIDR.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("gateway_subnet_cidr"), "missing gateway_subnet_cidr", "Azure VPN gateways need a GatewaySubnet of their own, at least a /27")
+		}
+		if peerCIDRs.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("peer_cidrs"), "missing peer_cidrs", "Azure local network gateways need the peer's address ranges")
+		}
+	}
+}
+
+// useRegion points the AWS clients at the resource's region.
+func (r *VPNGatewayResource) useRegion(cloud, region types.String) {
+	if cloud.ValueString() != "aws" {
+		return
+	}
+	if c := r.awsRegions.Clients(region.ValueString()); c != nil {
+		r.ec2 = c.EC2
+	}
+	r.ids = r.ids.In(region.ValueString())
+}
+
+// configured reports whether the clients for cloud were set up by the provider.
+func (r *VPNGatewayResource) configured(cloud string) bool {
+	switch cloud {
+	case "aws":
+		return r.ec2 != nil
+	case "azure":
+		return r.azureVNet != nil && r.azureGW != nil && r.azureLocalGW != nil && r.azureConn != nil
+	case "gcp":
+		return r.gcp != nil
+	}
+	return false
+}
+
+func (r *VPNGatewayResource) gcpRegionFor(m *vpnGatewayResourceModel) string {
+	if region := m.Region.ValueString(); region != "" {
+		return region
+	}
+	return r.gcpRegion
+}
+
+func (r *VPNGatewayResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, done := shared.StartOperation(ctx, "create", req.Plan, r.timeout, &resp.Diagnostics)
+	defer done()
+	var plan vpnGatewayResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(plan.Type, plan.Region)
+	if t := plan.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	plan.PublicIP, plan.ConnectionID, plan.CloudID = types.StringNull(), types.StringNull(), types.StringNull()
+	var err error
+	switch plan.Type.ValueString() {
+	case "aws":
+		err = r.createAWS(ctx, &plan)
+	case "azure":
+		err = r.createAzure(ctx, &plan)
+	case "gcp":
+		err = r.createGCP(ctx, &plan)
+	default:
+		resp.Diagnostics.AddError("unsupported cloud", "only aws, azure, and gcp implemented")
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(plan.Type.ValueString()+" create vpn gateway", err.Error())
+		if plan.ID.IsUnknown() {
+			return
+		}
+		// keep the gateway in state so what was created is removed on destroy
+	}
+	id, idErr := r.cloudID(ctx, &plan)
+	setCloudID(&resp.Diagnostics, &plan.CloudID, id, idErr)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+// cloudID is the virtual private gateway's ARN, the Azure gateway's ID or the
+// Classic VPN gateway's self link.
+func (r *VPNGatewayResource) cloudID(ctx context.Context, m *vpnGatewayResourceModel) (string, error) {
+	id := m.ID.ValueString()
+	switch m.Type.ValueString() {
+	case "aws":
+		return r.ids.ARN(ctx, "ec2", true, "vpn-gateway/"+id)
+	case "azure":
+		return id, nil
+	case "gcp":
+		return r.ids.GCPSelfLink(fmt.Sprintf("regions/%s/targetVpnGateways/%s", r.gcpRegionFor(m), id)), nil
+	}
+	return "", nil
+}
+
+// createAWS attaches a virtual private gateway to the VPC and connects it to
+// a customer gateway for the peer, with static routes to peer_cidrs.
+func (r *VPNGatewayResource) createAWS(ctx context.Context, plan *vpnGatewayResourceModel) error {
+	name := plan.Name.ValueString()
+	tags := func(t ec2types.ResourceType) []ec2types.TagSpecification {
+		return []ec2types.TagSpecification{{ResourceType: t, Tags: []ec2types.Tag{{Key: aws.String("Name"), Value: aws.String(name)}}}}
+	}
+	gw, err := r.ec2.CreateVpnGateway(ctx, &ec2.CreateVpnGatewayInput{
+		Type:              ec2types.GatewayTypeIpsec1,
+		TagSpecifications: tags(ec2types.ResourceTypeVpnGateway),
+	})
+	if err != nil {
+		return err
+	}
+	gwID := aws.ToString(gw.VpnGateway.VpnGatewayId)
+	plan.ID = types.StringValue(gwID)
+	if err := r.awsWaitGateway(ctx, gwID, func(g ec2types.VpnGateway) bool { return g.State == ec2types.VpnStateAvailable }); err != nil {
+		return err
+	}
+	_, err = r.ec2.AttachVpnGateway(ctx, &ec2.AttachVpnGatewayInput{VpcId: aws.String(plan.NetworkID.ValueString()), VpnGatewayId: aws.String(gwID)})
+	if err != nil {
+		return err
+	}
+	cgw, err := r.ec2.CreateCustomerGateway(ctx, &ec2.CreateCustomerGatewayInput{
+		Type:              ec2types.GatewayTypeIpsec1,
+		IpAddress:         aws.String(plan.PeerIP.ValueString()),
+		BgpAsn:            aws.Int32(65000),
+		TagSpecifications: tags(ec2types.ResourceTypeCustomerGateway),
+	})
+	if err != nil {
+		return err
+	}
+	cgwID := cgw.CustomerGateway.CustomerGatewayId
+	key := aws.String(plan.SharedKey.ValueString())
+	conn, err := r.ec2.CreateVpnConnection(ctx, &ec2.CreateVpnConnectionInput{
+		Type:              aws.String(string(ec2types.GatewayTypeIpsec1)),
+		CustomerGatewayId: cgwID,
+		VpnGatewayId:      aws.String(gwID),
+		Options: &ec2types.VpnConnectionOptionsSpecification{
+			StaticRoutesOnly: aws.Bool(true),
+			TunnelOptions:    []ec2types.VpnTunnelOptionsSpecification{{PreSharedKey: key}, {PreSharedKey: key}},
+		},
+		TagSpecifications: tags(ec2types.ResourceTypeVpnConnection),
+	})
+	if err != nil {
+		// the customer gateway is not in state yet, so do not leave it behind
+		_, _ = r.ec2.DeleteCustomerGateway(ctx, &ec2.DeleteCustomerGatewayInput{CustomerGatewayId: cgwID})
+		return err
+	}
+	connID := aws.ToString(conn.VpnConnection.VpnConnectionId)
+	plan.ConnectionID = types.StringValue(connID)
+	for _, cidr := range plan.PeerCIDRs {
+		_, err := r.ec2.CreateVpnConnectionRoute(ctx, &ec2.CreateVpnConnectionRouteInput{VpnConnectionId: aws.String(connID), DestinationCidrBlock: aws.String(cidr)})
+		if err != nil {
+			return err
+		}
+	}
+	describe := &ec2.DescribeVpnConnectionsInput{VpnConnectionIds: []string{connID}}
+	if err := ec2.NewVpnConnectionAvailableWaiter(r.ec2).Wait(ctx, describe, time.Hour); err != nil {
+		return err
+	}
+	out, err := r.ec2.DescribeVpnConnections(ctx, describe)
+	if err != nil {
+		return err
+	}
+	if len(out.VpnConnections) > 0 {
+		if ip := awsTunnelIP(out.VpnConnections[0]); ip != "" {
+			plan.PublicIP = types.StringValue(ip)
+		}
+	}
+	return nil
+}
+
+// awsTunnelIP returns the outside address of a VPN connection's first tunnel.
+func awsTunnelIP(conn ec2types.VpnConnection) string {
+	if conn.Options != nil {
+		for _, t := range conn.Options.TunnelOptions {
+			if ip := aws.ToString(t.OutsideIpAddress); ip != "" {
+				return ip
+			}
+		}
+	}
+	for _, t := range conn.VgwTelemetry {
+		if ip := aws.ToString(t.OutsideIpAddress); ip != "" {
+			return ip
+		}
+	}
+	return ""
+}
+
+// awsWaitGateway polls a virtual private gateway until ready reports true.
+// The SDK has no waiters for virtual private gateways.
+func (r *VPNGatewayResource) awsWaitGateway(ctx context.Context, id string, ready func(ec2types.VpnGateway) bool) error {
+	for {
+		out, err := r.ec2.DescribeVpnGateways(ctx, &ec2.DescribeVpnGatewaysInput{VpnGatewayIds: []string{id}})
+		if err != nil {
+			return err
+		}
+		if len(out.VpnGateways) == 0 || ready(out.VpnGateways[0]) {
+			return nil
+		}
+		if err := shared.Sleep(ctx, 5*time.Second); err != nil {
+			return err
+		}
+	}
+}
+
+// azureVNetRef splits a virtual network resource ID into its resource group
+// and name.
+func azureVNetRef(id string) (rg, name string, err error) {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	if len(parts) != 8 || !strings.EqualFold(parts[2], "resourceGroups") || !strings.EqualFold(parts[6], "virtualNetworks") {
+		return "", "", fmt.Errorf("%q is not a virtual network resource ID", id)
+	}
+	return parts[3], parts[7], nil
+}
+
+// addAzureAddressSpace adds cidr to the address space of a virtual network
+// unless it already lies within it, and returns the network.
+func addAzureAddressSpace(ctx context.Context, vnets *armnetwork.VirtualNetworksClient, rg, name, cidr string) (armnetwork.VirtualNetwork, error) {
+	vnet, err := vnets.Get(ctx, rg, name, nil)
+	if err != nil {
+		return armnetwork.VirtualNetwork{}, err
+	}
+	props := vnet.Properties
+	if props == nil {
+		return vnet.VirtualNetwork, nil
+	}
+	if props.AddressSpace == nil {
+		props.AddressSpace = &armnetwork.AddressSpace{}
+	}
+	if cidrWithin(cidr, props.AddressSpace.AddressPrefixes) {
+		return vnet.VirtualNetwork, nil
+	}
+	props.AddressSpace.AddressPrefixes = append(props.AddressSpace.AddressPrefixes, &cidr)
+	poller, err := vnets.BeginCreateOrUpdate(ctx, rg, name, vnet.VirtualNetwork, nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, poller)
+	}
+	if err != nil {
+		return vnet.VirtualNetwork, fmt.Errorf("address space: %w", err)
+	}
+	return vnet.VirtualNetwork, nil
+}
+
+// cidrWithin reports whether cidr lies inside one of prefixes.
+func cidrWithin(cidr string, prefixes []*string) bool {
+	ip, inner, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	innerOnes, _ := inner.Mask.Size()
+	for _, p := range prefixes {
+		_, outer, err := net.ParseCIDR(aws.ToString(p))
+		if err != nil {
+			continue
+		}
+		if ones, _ := outer.Mask.Size(); ones <= innerOnes && outer.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// createAzure adds a GatewaySubnet to the network and creates a route-based
+// gateway in it, connected to a local network gateway for the peer.
+func (r *VPNGatewayResource) createAzure(ctx context.Context, plan *vpnGatewayResourceModel) error {
+	name := plan.Name.ValueString()
+	rg, vnetName, err := azureVNetRef(plan.NetworkID.ValueString())
+	if err != nil {
+		return err
+	}
+	gwCIDR := plan.GatewaySubnetCIDR.ValueString()
+	vnet, err := addAzureAddressSpace(ctx, r.azureVNet, rg, vnetName, gwCIDR)
+	if err != nil {
+		return err
+	}
+	loc := vnet.Location
+	subnetPoller, err := r.azureSubnets.BeginCreateOrUpdate(ctx, rg, vnetName, "GatewaySubnet", armnetwork.Subnet{
+		Properties: &armnetwork.SubnetPropertiesFormat{AddressPrefix: &gwCIDR},
+	}, nil)
+	var subnet armnetwork.SubnetsClientCreateOrUpdateResponse
+	if err == nil {
+		subnet, err = shared.PollAzure(ctx, subnetPoller)
+	}
+	if err != nil {
+		return fmt.Errorf("gateway subnet: %w", err)
+	}
+	pipPoller, err := r.azurePIP.BeginCreateOrUpdate(ctx, "abstract-rg", name+"-pip", armnetwork.PublicIPAddress{
+		Location: loc,
+		SKU:      &armnetwork.PublicIPAddressSKU{Name: to.Ptr(armnetwork.PublicIPAddressSKUNameStandard)},
+		Properties: &armnetwork.PublicIPAddressPropertiesFormat{
+			PublicIPAllocationMethod: to.Ptr(armnetwork.IPAllocationMethodStatic),
+		},
+	}, nil)
+	var pip armnetwork.PublicIPAddressesClientCreateOrUpdateResponse
+	if err == nil {
+		pip, err = shared.PollAzure(ctx, pipPoller)
+	}
+	if err != nil {
+		return fmt.Errorf("pip: %w", err)
+	}
+	gwPoller, err := r.azureGW.BeginCreateOrUpdate(ctx, "abstract-rg", name, armnetwork.VirtualNetworkGateway{
+		Location: loc,
+		Properties: &armnetwork.VirtualNetworkGatewayPropertiesFormat{
+			GatewayType: to.Ptr(armnetwork.VirtualNetworkGatewayTypeVPN),
+			VPNType:     to.Ptr(armnetwork.VPNTypeRouteBased),
+			SKU: &armnetwork.VirtualNetworkGatewaySKU{
+				Name: to.Ptr(armnetwork.VirtualNetworkGatewaySKUNameVPNGw1),
+				Tier: to.Ptr(armnetwork.VirtualNetworkGatewaySKUTierVPNGw1),
+			},
+			IPConfigurations: []*armnetwork.VirtualNetworkGatewayIPConfiguration{{
+				Name: to.Ptr("default"),
+				Properties: &armnetwork.VirtualNetworkGatewayIPConfigurationPropertiesFormat{
+					PrivateIPAllocationMethod: to.Ptr(armnetwork.IPAllocationMethodDynamic),
+					PublicIPAddress:           &armnetwork.SubResource{ID: pip.ID},
+					Subnet:                    &armnetwork.SubResource{ID: subnet.ID},
+				},
+			}},
+		},
+	}, nil)
+	var gw armnetwork.VirtualNetworkGatewaysClientCreateOrUpdateResponse
+	if err == nil {
+		gw, err = shared.PollAzure(ctx, gwPoller)
+	}
+	if err != nil {
+		return err
+	}
+	if gw.ID == nil {
+		return fmt.Errorf("gateway has no ID")
+	}
+	plan.ID = types.StringValue(*gw.ID)
+	if pip.Properties != nil && pip.Properties.IPAddress != nil {
+		plan.PublicIP = types.StringValue(*pip.Properties.IPAddress)
+	}
+	var prefixes []*string
+	for i := range plan.PeerCIDRs {
+		prefixes = append(prefixes, &plan.PeerCIDRs[i])
+	}
+	localPoller, err := r.azureLocalGW.BeginCreateOrUpdate(ctx, "abstract-rg", name+"-peer", armnetwork.LocalNetworkGateway{
+		Location: loc,
+		Properties: &armnetwork.LocalNetworkGatewayPropertiesFormat{
+			GatewayIPAddress:         to.Ptr(plan.PeerIP.ValueString()),
+			LocalNetworkAddressSpace: &armnetwork.AddressSpace{AddressPrefixes: prefixes},
+		},
+	}, nil)
+	var local armnetwork.LocalNetworkGatewaysClientCreateOrUpdateResponse
+	if err == nil {
+		local, err = shared.PollAzure(ctx, localPoller)
+	}
+	if err != nil {
+		return fmt.Errorf("local gateway: %w", err)
+	}
+	connPoller, err := r.azureConn.BeginCreateOrUpdate(ctx, "abstract-rg", name, armnetwork.VirtualNetworkGatewayConnection{
+		Location: loc,
+		Properties: &armnetwork.VirtualNetworkGatewayConnectionPropertiesFormat{
+			ConnectionType:         to.Ptr(armnetwork.VirtualNetworkGatewayConnectionTypeIPsec),
+			VirtualNetworkGateway1: &gw.VirtualNetworkGateway,
+			LocalNetworkGateway2:   &local.LocalNetworkGateway,
+			SharedKey:              to.Ptr(plan.SharedKey.ValueString()),
+		},
+	}, nil)
+	var conn armnetwork.VirtualNetworkGatewayConnectionsClientCreateOrUpdateResponse
+	if err == nil {
+		conn, err = shared.PollAzure(ctx, connPoller)
+	}
+	if err != nil {
+		return fmt.Errorf("connection: %w", err)
+	}
+	if conn.ID != nil {
+		plan.ConnectionID = types.StringValue(*conn.ID)
+	}
+	return nil
+}
+
+// gcpVPNForwarding lists the forwarding rules a Classic VPN gateway needs for
+// IPsec traffic, by name suffix.
+var gcpVPNForwarding = []struct{ suffix, protocol, ports string }{
+	{"esp", "ESP", ""},
+	{"udp500", "UDP", "500"},
+	{"udp4500", "UDP", "4500"},
+}
+
+// createGCP creates a Classic VPN gateway on a reserved address with a tunnel
+// to the peer, routing peer_cidrs through it.
+func (r *VPNGatewayResource) createGCP(ctx context.Context, plan *vpnGatewayResourceModel) error {
+	name, region := plan.Name.ValueString(), r.gcpRegionFor(plan)
+	network := fmt.Sprintf("projects/%s/global/networks/%s", r.gcpProj, plan.NetworkID.ValueString())
+	op, err := r.gcp.Addresses.Insert(r.gcpProj, region, &compute.Address{Name: name + "-ip"}).Context(ctx).Do()
+	if err == nil {
+		err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+	}
+	if err != nil {
+		return fmt.Errorf("address: %w", err)
+	}
+	addr, err := r.gcp.Addresses.Get(r.gcpProj, region, name+"-ip").Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("address: %w", err)
+	}
+	plan.ID = types.StringValue(name)
+	plan.PublicIP = types.StringValue(addr.Address)
+	// from here on the gateway is in state, so a failed step is cleaned up on destroy
+	op, err = r.gcp.TargetVpnGateways.Insert(r.gcpProj, region, &compute.TargetVpnGateway{Name: name, Network: network}).Context(ctx).Do()
+	if err == nil {
+		err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+	}
+	if err != nil {
+		return err
+	}
+	gateway := fmt.Sprintf("projects/%s/regions/%s/targetVpnGateways/%s", r.gcpProj, region, name)
+	for _, f := range gcpVPNForwarding {
+		op, err := r.gcp.ForwardingRules.Insert(r.gcpProj, region, &compute.ForwardingRule{
+			Name:       name + "-" + f.suffix,
+			IPProtocol: f.protocol,
+			PortRange:  f.ports,
+			IPAddress:  addr.Address,
+			Target:     gateway,
+		}).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			return fmt.Errorf("forwarding rule: %w", err)
+		}
+	}
+	remote := plan.PeerCIDRs
+	if len(remote) == 0 {
+		remote = []string{"0.0.0.0/0"}
+	}
+	op, err = r.gcp.VpnTunnels.Insert(r.gcpProj, region, &compute.VpnTunnel{
+		Name:                  name,
+		PeerIp:                plan.PeerIP.ValueString(),
+		SharedSecret:          plan.SharedKey.ValueString(),
+		TargetVpnGateway:      gateway,
+		IkeVersion:            2,
+		LocalTrafficSelector:  []string{"0.0.0.0/0"},
+		RemoteTrafficSelector: remote,
+	}).Context(ctx).Do()
+	if err == nil {
+		err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+	}
+	if err != nil {
+		return fmt.Errorf("tunnel: %w", err)
+	}
+	plan.ConnectionID = types.StringValue(name)
+	tunnel := fmt.Sprintf("projects/%s/regions/%s/vpnTunnels/%s", r.gcpProj, region, name)
+	for i, cidr := range plan.PeerCIDRs {
+		op, err := r.gcp.Routes.Insert(r.gcpProj, &compute.Route{
+			Name:             fmt.Sprintf("%s-%d", name, i),
+			Network:          network,
+			DestRange:        cidr,
+			NextHopVpnTunnel: tunnel,
+		}).Context(ctx).Do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		if err != nil {
+			return fmt.Errorf("route: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *VPNGatewayResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, done := shared.StartOperation(ctx, "read", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state vpnGatewayResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	id := state.ID.ValueString()
+	var err error
+	switch state.Type.ValueString() {
+	case "aws":
+		var out *ec2.DescribeVpnGatewaysOutput
+		out, err = r.ec2.DescribeVpnGateways(ctx, &ec2.DescribeVpnGatewaysInput{VpnGatewayIds: []string{id}})
+		if err == nil && (len(out.VpnGateways) == 0 || out.VpnGateways[0].State == ec2types.VpnStateDeleted) {
+			err = fmt.Errorf("vpn gateway %s not found", id)
+		}
+	case "azure":
+		_, err = r.azureGW.Get(ctx, "abstract-rg", state.Name.ValueString(), nil)
+	case "gcp":
+		_, err = r.gcp.TargetVpnGateways.Get(r.gcpProj, r.gcpRegionFor(&state), id).Context(ctx).Do()
+	}
+	if err != nil {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	refreshCloudID(ctx, &resp.State, &resp.Diagnostics, state.CloudID, func() (string, error) { return r.cloudID(ctx, &state) })
+}
+
+// Update is never called: every attribute replaces the gateway.
+func (r *VPNGatewayResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan vpnGatewayResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *VPNGatewayResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, done := shared.StartOperation(ctx, "delete", req.State, r.timeout, &resp.Diagnostics)
+	defer done()
+	var state vpnGatewayResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.useRegion(state.Type, state.Region)
+	if t := state.Type.ValueString(); !shared.RequireClients(&resp.Diagnostics, t, r.configured(t)) {
+		return
+	}
+	var err error
+	switch state.Type.ValueString() {
+	case "aws":
+		err = r.deleteAWS(ctx, &state)
+	case "azure":
+		err = r.deleteAzure(ctx, &state)
+	case "gcp":
+		err = r.deleteGCP(ctx, &state)
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(state.Type.ValueString()+" delete", err.Error())
+	}
+}
+
+func (r *VPNGatewayResource) deleteAWS(ctx context.Context, state *vpnGatewayResourceModel) error {
+	gwID := state.ID.ValueString()
+	if connID := state.ConnectionID.ValueString(); connID != "" {
+		describe := &ec2.DescribeVpnConnectionsInput{VpnConnectionIds: []string{connID}}
+		out, err := r.ec2.DescribeVpnConnections(ctx, describe)
+		if err != nil {
+			return err
+		}
+		if _, err := r.ec2.DeleteVpnConnection(ctx, &ec2.DeleteVpnConnectionInput{VpnConnectionId: aws.String(connID)}); err != nil {
+			return err
+		}
+		if err := ec2.NewVpnConnectionDeletedWaiter(r.ec2).Wait(ctx, describe, time.Hour); err != nil {
+			return err
+		}
+		if len(out.VpnConnections) > 0 {
+			_, err := r.ec2.DeleteCustomerGateway(ctx, &ec2.DeleteCustomerGatewayInput{CustomerGatewayId: out.VpnConnections[0].CustomerGatewayId})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	vpc := state.NetworkID.ValueString()
+	_, err := r.ec2.DetachVpnGateway(ctx, &ec2.DetachVpnGatewayInput{VpcId: aws.String(vpc), VpnGatewayId: aws.String(gwID)})
+	if err == nil {
+		err = r.awsWaitGateway(ctx, gwID, func(g ec2types.VpnGateway) bool {
+			for _, a := range g.VpcAttachments {
+				if aws.ToString(a.VpcId) == vpc && a.State != ec2types.AttachmentStatusDetached {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	if err != nil && !strings.Contains(err.Error(), "InvalidVpnGatewayAttachment.NotFound") {
+		return err
+	}
+	_, err = r.ec2.DeleteVpnGateway(ctx, &ec2.DeleteVpnGatewayInput{VpnGatewayId: aws.String(gwID)})
+	return err
+}
+
+func (r *VPNGatewayResource) deleteAzure(ctx context.Context, state *vpnGatewayResourceModel) error {
+	name := state.Name.ValueString()
+	connPoller, err := r.azureConn.BeginDelete(ctx, "abstract-rg", name, nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, connPoller)
+	}
+	if err != nil {
+		return fmt.Errorf("connection: %w", err)
+	}
+	localPoller, err := r.azureLocalGW.BeginDelete(ctx, "abstract-rg", name+"-peer", nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, localPoller)
+	}
+	if err != nil {
+		return fmt.Errorf("local gateway: %w", err)
+	}
+	gwPoller, err := r.azureGW.BeginDelete(ctx, "abstract-rg", name, nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, gwPoller)
+	}
+	if err != nil {
+		return err
+	}
+	pipPoller, err := r.azurePIP.BeginDelete(ctx, "abstract-rg", name+"-pip", nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, pipPoller)
+	}
+	if err != nil {
+		return fmt.Errorf("pip: %w", err)
+	}
+	// the address range added for the subnet stays in the network's address space
+	rg, vnetName, err := azureVNetRef(state.NetworkID.ValueString())
+	if err != nil {
+		return err
+	}
+	subnetPoller, err := r.azureSubnets.BeginDelete(ctx, rg, vnetName, "GatewaySubnet", nil)
+	if err == nil {
+		_, err = shared.PollAzure(ctx, subnetPoller)
+	}
+	if err != nil {
+		return fmt.Errorf("gateway subnet: %w", err)
+	}
+	return nil
+}
+
+func (r *VPNGatewayResource) deleteGCP(ctx context.Context, state *vpnGatewayResourceModel) error {
+	name, region := state.Name.ValueString(), r.gcpRegionFor(state)
+	type step struct {
+		what string
+		do   func() (*compute.Operation, error)
+	}
+	// each step depends on the ones before it having been removed
+	var steps []step
+	if !state.ConnectionID.IsNull() {
+		for i := range state.PeerCIDRs {
+			route := fmt.Sprintf("%s-%d", name, i)
+			steps = append(steps, step{"route", func() (*compute.Operation, error) {
+				return r.gcp.Routes.Delete(r.gcpProj, route).Context(ctx).Do()
+			}})
+		}
+		steps = append(steps, step{"tunnel", func() (*compute.Operation, error) {
+			return r.gcp.VpnTunnels.Delete(r.gcpProj, region, name).Context(ctx).Do()
+		}})
+	}
+	for _, f := range gcpVPNForwarding {
+		rule := name + "-" + f.suffix
+		steps = append(steps, step{"forwarding rule", func() (*compute.Operation, error) {
+			return r.gcp.ForwardingRules.Delete(r.gcpProj, region, rule).Context(ctx).Do()
+		}})
+	}
+	steps = append(steps,
+		step{"gateway", func() (*compute.Operation, error) {
+			return r.gcp.TargetVpnGateways.Delete(r.gcpProj, region, name).Context(ctx).Do()
+		}},
+		step{"address", func() (*compute.Operation, error) {
+			return r.gcp.Addresses.Delete(r.gcpProj, region, name+"-ip").Context(ctx).Do()
+		}},
+	)
+	for _, s := range steps {
+		op, err := s.do()
+		if err == nil {
+			err = waitComputeOperation(ctx, r.gcp, r.gcpProj, op)
+		}
+		// a create that failed part way leaves later steps with nothing to remove
+		if err != nil && !strings.Contains(err.Error(), "notFound") {
+			return fmt.Errorf("%s: %w", s.what, err)
+		}
+	}
+	return nil
+}
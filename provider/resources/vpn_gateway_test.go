@@ -0,0 +1,96 @@
+package resources
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestVPNGatewayConfig(t *testing.T) {
+	r := &VPNGatewayResource{}
+	s := testSchema(t, r)
+	vpn := func(cloud string, extra map[string]tftypes.Value) map[string]tftypes.Value {
+		vals := map[string]tftypes.Value{"type": str(cloud), "name": str("office"), "network_id": str("net"), "peer_ip": str("203.0.113.10"), "shared_key": str("s3cret.key")}
+		maps.Copy(vals, extra)
+		return vals
+	}
+	azure := map[string]tftypes.Value{"gateway_subnet_cidr": str("10.1.255.0/27"), "peer_cidrs": strList("192.168.0.0/24")}
+	cases := []struct {
+		name  string
+		vals  map[string]tftypes.Value
+		errs  bool
+		warns bool
+	}{
+		{"aws", vpn("aws", map[string]tftypes.Value{"peer_cidrs": strList("192.168.0.0/24")}), false, false},
+		{"aws short key", vpn("aws", map[string]tftypes.Value{"shared_key": str("short")}), true, false},
+		{"aws key leading zero", vpn("aws", map[string]tftypes.Value{"shared_key": str("0s3cret.key")}), true, false},
+		{"aws gateway subnet", vpn("aws", map[string]tftypes.Value{"gateway_subnet_cidr": str("10.1.255.0/27")}), false, true},
+		{"ipv6 peer", vpn("aws", map[string]tftypes.Value{"peer_ip": str("2001:db8::1")}), true, false},
+		{"bad peer cidr", vpn("gcp", map[string]tftypes.Value{"peer_cidrs": strList("192.168.0.0")}), true, false},
+		{"azure", vpn("azure", azure), false, false},
+		{"azure without gateway subnet", vpn("azure", map[string]tftypes.Value{"peer_cidrs": strList("192.168.0.0/24")}), true, false},
+		{"azure without peer cidrs", vpn("azure", map[string]tftypes.Value{"gateway_subnet_cidr": str("10.1.255.0/27")}), true, false},
+		{"gcp", vpn("gcp", nil), false, false},
+		{"unknown peer", vpn("aws", map[string]tftypes.Value{"peer_ip": tftypes.NewValue(tftypes.String, tftypes.UnknownValue)}), false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &resource.ValidateConfigResponse{}
+			r.ValidateConfig(context.Background(), resource.ValidateConfigRequest{Config: tfsdk.Config{Schema: s, Raw: testValue(s, tc.vals, false)}}, resp)
+			if resp.Diagnostics.HasError() != tc.errs || (resp.Diagnostics.WarningsCount() > 0) != tc.warns {
+				t.Errorf("diagnostics = %v", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestAzureVNetRef(t *testing.T) {
+	rg, name, err := azureVNetRef("/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Network/virtualNetworks/main")
+	if err != nil || rg != "abstract-rg" || name != "main" {
+		t.Errorf("azureVNetRef = %q, %q, %v", rg, name, err)
+	}
+	for _, id := range []string{"main", "/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Network/virtualNetworks/main/subnets/default"} {
+		if _, _, err := azureVNetRef(id); err == nil {
+			t.Errorf("azureVNetRef(%q) accepted", id)
+		}
+	}
+}
+
+func TestCIDRWithin(t *testing.T) {
+	space := []*string{aws.String("10.0.0.0/16")}
+	cases := map[string]bool{
+		"10.0.255.0/27": true,
+		"10.0.0.0/16":   true,
+		"10.1.255.0/27": false,
+		"10.0.0.0/8":    false,
+		"bad":           false,
+	}
+	for cidr, want := range cases {
+		if got := cidrWithin(cidr, space); got != want {
+			t.Errorf("cidrWithin(%q) = %v, want %v", cidr, got, want)
+		}
+	}
+}
+
+func TestAWSTunnelIP(t *testing.T) {
+	conn := ec2types.VpnConnection{
+		Options:      &ec2types.VpnConnectionOptions{TunnelOptions: []ec2types.TunnelOption{{}, {OutsideIpAddress: aws.String("198.51.100.2")}}},
+		VgwTelemetry: []ec2types.VgwTelemetry{{OutsideIpAddress: aws.String("198.51.100.9")}},
+	}
+	if got := awsTunnelIP(conn); got != "198.51.100.2" {
+		t.Errorf("awsTunnelIP = %q", got)
+	}
+	conn.Options = nil
+	if got := awsTunnelIP(conn); got != "198.51.100.9" {
+		t.Errorf("awsTunnelIP from telemetry = %q", got)
+	}
+	if got := awsTunnelIP(ec2types.VpnConnection{}); got != "" {
+		t.Errorf("awsTunnelIP of empty connection = %q", got)
+	}
+}
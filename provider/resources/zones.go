@@ -0,0 +1,109 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"google.golang.org/api/compute/v1"
+)
+
+var (
+	// gcpRegionPattern matches a GCP region such as "us-central1".
+	gcpRegionPattern = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+$`)
+	// gcpZonePattern matches a GCP zone such as "us-central1-a".
+	gcpZonePattern = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+-[a-z]$`)
+)
+
+// zoneLister lists the availability zones of the client's region.
+type zoneLister interface {
+	DescribeAvailabilityZones(ctx context.Context, params *ec2.DescribeAvailabilityZonesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAvailabilityZonesOutput, error)
+}
+
+// checkZone reports whether zone can be an availability_zone in region, as far
+// as can be told without asking the cloud: an AWS zone such as "us-east-1b",
+// an Azure zone number or a GCP zone or zone suffix such as "b". An empty
+// region is not checked against.
+func checkZone(cloud, region, zone string) error {
+	switch cloud {
+	case "aws":
+		if region != "" && (!strings.HasPrefix(zone, region) || len(zone) == len(region)) {
+			return fmt.Errorf("availability zone %s is not in region %s", zone, region)
+		}
+	case "azure":
+		if zone != "1" && zone != "2" && zone != "3" {
+			return fmt.Errorf("%q is not an Azure availability zone; use 1, 2 or 3", zone)
+		}
+	case "gcp":
+		if !strings.Contains(zone, "-") {
+			if len(zone) != 1 || zone[0] < 'a' || zone[0] > 'z' {
+				return fmt.Errorf("%q is neither a GCP zone nor a zone suffix such as \"b\"", zone)
+			}
+			return nil
+		}
+		if gcpRegionPattern.MatchString(zone) {
+			return fmt.Errorf("%s is a region, not a zone; use one of its zones such as %s-b", zone, zone)
+		}
+		if !gcpZonePattern.MatchString(zone) {
+			return fmt.Errorf("%q is not a GCP zone such as us-central1-b", zone)
+		}
+		if region != "" && gcpZoneRegion(zone) != gcpZoneRegion(region) {
+			return fmt.Errorf("zone %s is not in region %s", zone, gcpZoneRegion(region))
+		}
+	}
+	return nil
+}
+
+// awsZone returns zone after checking it is one of the region's availability
+// zones, or the region's first zone when zone is empty.
+func awsZone(ctx context.Context, ec2Client zoneLister, zone string) (string, error) {
+	out, err := ec2Client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{})
+	if err != nil {
+		return "", err
+	}
+	if len(out.AvailabilityZones) == 0 {
+		return "", fmt.Errorf("the region has no availability zones")
+	}
+	if zone == "" {
+		return aws.ToString(out.AvailabilityZones[0].ZoneName), nil
+	}
+	var names []string
+	for _, z := range out.AvailabilityZones {
+		if aws.ToString(z.ZoneName) == zone {
+			return zone, nil
+		}
+		names = append(names, aws.ToString(z.ZoneName))
+	}
+	return "", fmt.Errorf("availability zone %s is not in the region; it has %s", zone, strings.Join(names, ", "))
+}
+
+// checkGCPLocation reports whether location is a GCP region or zone.
+func checkGCPLocation(location string) error {
+	if !gcpRegionPattern.MatchString(location) && !gcpZonePattern.MatchString(location) {
+		return fmt.Errorf("%q is neither a GCP region such as us-central1 nor a zone such as us-central1-b", location)
+	}
+	return nil
+}
+
+// gcpFirstZone returns the first zone of a GCP region in alphabetical order,
+// the zone picked when only a region is given.
+func gcpFirstZone(ctx context.Context, gcp *compute.Service, project, region string) (string, error) {
+	reg, err := gcp.Regions.Get(project, region).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	var zones []string
+	for _, z := range reg.Zones {
+		zones = append(zones, path.Base(z))
+	}
+	if len(zones) == 0 {
+		return "", fmt.Errorf("region %s has no zones", region)
+	}
+	slices.Sort(zones)
+	return zones[0], nil
+}
@@ -0,0 +1,73 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+func TestCheckZone(t *testing.T) {
+	cases := []struct {
+		cloud, region, zone string
+		ok                  bool
+	}{
+		{"aws", "us-east-1", "us-east-1a", true},
+		{"aws", "", "eu-west-1c", true},
+		{"aws", "us-east-1", "us-west-2a", false},
+		{"aws", "us-east-1", "us-east-1", false},
+		{"azure", "eastus", "2", true},
+		{"azure", "eastus", "eastus-2", false},
+		{"gcp", "us-central1", "b", true},
+		{"gcp", "us-central1", "us-central1-f", true},
+		{"gcp", "us-central1-a", "us-central1-c", true},
+		{"gcp", "us-central1", "europe-west1-b", false},
+		{"gcp", "us-central1", "us-central1", false},
+		{"gcp", "us-central1", "bc", false},
+		{"gcp", "", "us-central1-zone", false},
+	}
+	for _, tc := range cases {
+		if err := checkZone(tc.cloud, tc.region, tc.zone); (err == nil) != tc.ok {
+			t.Errorf("checkZone(%q, %q, %q) = %v", tc.cloud, tc.region, tc.zone, err)
+		}
+	}
+}
+
+func TestAWSZone(t *testing.T) {
+	ctx := context.Background()
+	if zone, err := awsZone(ctx, newFakeEC2(), ""); err != nil || zone != "us-east-1a" {
+		t.Errorf("default zone = %q, %v", zone, err)
+	}
+	if zone, err := awsZone(ctx, newFakeEC2(), "us-east-1b"); err != nil || zone != "us-east-1b" {
+		t.Errorf("zone = %q, %v", zone, err)
+	}
+	if _, err := awsZone(ctx, newFakeEC2(), "us-east-1f"); err == nil {
+		t.Error("unknown zone accepted")
+	}
+}
+
+func TestGCPFirstZone(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/projects/p/regions/us-east1" {
+			http.NotFound(w, req)
+			return
+		}
+		base := "https://www.googleapis.com/compute/v1/projects/p/zones/"
+		fmt.Fprintf(w, `{"name": "us-east1", "zones": [%q, %q, %q]}`, base+"us-east1-d", base+"us-east1-b", base+"us-east1-c")
+	}))
+	defer srv.Close()
+	gcp, err := compute.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone, err := gcpFirstZone(context.Background(), gcp, "p", "us-east1"); err != nil || zone != "us-east1-b" {
+		t.Errorf("first zone = %q, %v", zone, err)
+	}
+	if _, err := gcpFirstZone(context.Background(), gcp, "p", "mars-north1"); err == nil {
+		t.Error("unknown region accepted")
+	}
+}
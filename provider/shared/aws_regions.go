@@ -0,0 +1,96 @@
+package shared
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// AWSClients are the clients of the regional AWS services in one region.
+// IAM, Route 53 and CloudFront are global and only have the provider's.
+type AWSClients struct {
+	S3         *s3.Client
+	EC2        *ec2.Client
+	EKS        *eks.Client
+	Lambda     *lambda.Client
+	RDS        *rds.Client
+	SQS        *sqs.Client
+	SM         *secretsmanager.Client
+	ECR        *ecr.Client
+	ECS        *ecs.Client
+	ELB        *elbv2.Client
+	SNS        *sns.Client
+	CloudWatch *cloudwatch.Client
+
+	ElastiCache *elasticache.Client
+	KMS         *kms.Client
+}
+
+// NewAWSClients builds the regional clients for cfg's region.
+func NewAWSClients(cfg aws.Config) *AWSClients {
+	return &AWSClients{
+		S3:         s3.NewFromConfig(cfg),
+		EC2:        ec2.NewFromConfig(cfg),
+		EKS:        eks.NewFromConfig(cfg),
+		Lambda:     lambda.NewFromConfig(cfg),
+		RDS:        rds.NewFromConfig(cfg),
+		SQS:        sqs.NewFromConfig(cfg),
+		SM:         secretsmanager.NewFromConfig(cfg),
+		ECR:        ecr.NewFromConfig(cfg),
+		ECS:        ecs.NewFromConfig(cfg),
+		ELB:        elbv2.NewFromConfig(cfg),
+		SNS:        sns.NewFromConfig(cfg),
+		CloudWatch: cloudwatch.NewFromConfig(cfg),
+
+		ElastiCache: elasticache.NewFromConfig(cfg),
+		KMS:         kms.NewFromConfig(cfg),
+	}
+}
+
+// AWSRegions hands out the AWS clients of any region, building each region's
+// the first time it is asked for. A nil AWSRegions has no clients.
+type AWSRegions struct {
+	cfg     aws.Config
+	mu      sync.Mutex
+	clients map[string]*AWSClients
+}
+
+// NewAWSRegions returns an AWSRegions building clients from cfg, with home
+// as the clients of cfg's region.
+func NewAWSRegions(cfg aws.Config, home *AWSClients) *AWSRegions {
+	return &AWSRegions{cfg: cfg, clients: map[string]*AWSClients{cfg.Region: home}}
+}
+
+// Clients returns the clients for region; an empty region is the provider's.
+func (a *AWSRegions) Clients(region string) *AWSClients {
+	if a == nil {
+		return nil
+	}
+	if region == "" {
+		region = a.cfg.Region
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, ok := a.clients[region]
+	if !ok {
+		cfg := a.cfg.Copy()
+		cfg.Region = region
+		c = NewAWSClients(cfg)
+		a.clients[region] = c
+	}
+	return c
+}
@@ -0,0 +1,32 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestAWSRegionsClients(t *testing.T) {
+	home := &AWSClients{}
+	regions := NewAWSRegions(aws.Config{Region: "us-east-1"}, home)
+	if got := regions.Clients(""); got != home {
+		t.Errorf("Clients(\"\") = %p, want the provider's %p", got, home)
+	}
+	if got := regions.Clients("us-east-1"); got != home {
+		t.Errorf("Clients(us-east-1) = %p, want the provider's %p", got, home)
+	}
+	west := regions.Clients("us-west-2")
+	if west == nil || west == home || west.EC2 == nil {
+		t.Fatalf("Clients(us-west-2) = %+v, want new clients", west)
+	}
+	if got := west.EC2.Options().Region; got != "us-west-2" {
+		t.Errorf("us-west-2 EC2 client region = %q", got)
+	}
+	if regions.Clients("us-west-2") != west {
+		t.Error("us-west-2 clients were built twice")
+	}
+	var none *AWSRegions
+	if none.Clients("us-west-2") != nil {
+		t.Error("nil AWSRegions returned clients")
+	}
+}
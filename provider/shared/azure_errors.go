@@ -0,0 +1,129 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// AzureError is a failed Azure call reduced to what explains it: the Azure
+// error code, the resource the call was about and Azure's message.
+type AzureError struct {
+	Code    string
+	Target  string
+	Message string
+	Err     error
+}
+
+func (e *AzureError) Error() string {
+	s := e.Message
+	if e.Target != "" {
+		s = e.Target + ": " + s
+	}
+	if e.Code != "" {
+		s = "[" + e.Code + "] " + s
+	}
+	return s
+}
+
+func (e *AzureError) Unwrap() error { return e.Err }
+
+// azureError turns an Azure response error into an AzureError about target,
+// or the resource of the failed request when target is empty. Other errors
+// are returned unchanged.
+func azureError(err error, target string) error {
+	var azErr *AzureError
+	if errors.As(err, &azErr) {
+		return err
+	}
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) || respErr.RawResponse == nil {
+		return err
+	}
+	azErr = &AzureError{Code: respErr.ErrorCode, Target: target, Message: http.StatusText(respErr.StatusCode), Err: err}
+	if azErr.Target == "" && respErr.RawResponse.Request != nil {
+		azErr.Target = respErr.RawResponse.Request.URL.Path
+	}
+	// ARM errors and failed operations both carry {"error": {"code", "message"}}
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if payload, perr := runtime.Payload(respErr.RawResponse); perr == nil && json.Unmarshal(payload, &body) == nil {
+		if body.Error.Message != "" {
+			azErr.Message = body.Error.Message
+		}
+		if azErr.Code == "" {
+			azErr.Code = body.Error.Code
+		}
+	}
+	return azErr
+}
+
+// PollAzure waits for an Azure long-running operation like PollUntilDone. A
+// failure is an AzureError naming the resource the operation was started on,
+// rather than the operation status URL the poller last read.
+func PollAzure[T any](ctx context.Context, poller *runtime.Poller[T]) (T, error) {
+	target := azurePollerTarget(poller)
+	res, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return res, azureError(err, target)
+	}
+	return res, nil
+}
+
+// azurePollerTarget returns the path of the resource a poller's operation was
+// started on, taken from its resume token, or "" when it has none.
+func azurePollerTarget[T any](poller *runtime.Poller[T]) string {
+	tk, err := poller.ResumeToken()
+	if err != nil {
+		return ""
+	}
+	var wrapper struct {
+		Token struct {
+			Type    string `json:"type"`
+			OrigURL string `json:"origURL"`
+			PollURL string `json:"pollURL"`
+		} `json:"token"`
+	}
+	if json.Unmarshal([]byte(tk), &wrapper) != nil {
+		return ""
+	}
+	raw := wrapper.Token.OrigURL
+	if raw == "" && wrapper.Token.Type == "body" {
+		// body pollers poll the resource itself
+		raw = wrapper.Token.PollURL
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// AddAzureError adds err from an Azure call as an error diagnostic. An Azure
+// response error gets its error code in the summary, as in "azure create
+// failed [QuotaExceeded]", and the resource and Azure's message as detail.
+func AddAzureError(diags *diag.Diagnostics, summary string, err error) {
+	var azErr *AzureError
+	if !errors.As(azureError(err, ""), &azErr) {
+		diags.AddError(summary, err.Error())
+		return
+	}
+	if azErr.Code != "" {
+		summary += " failed [" + azErr.Code + "]"
+	}
+	detail := azErr.Message
+	if azErr.Target != "" {
+		detail = azErr.Target + ": " + detail
+	}
+	diags.AddError(summary, detail)
+}
@@ -0,0 +1,73 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+const azureVMURL = "https://management.azure.com/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Compute/virtualMachines/web"
+
+func azureResponse(method, rawURL string, status int, body string) *http.Response {
+	req, _ := http.NewRequest(method, rawURL, nil)
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body)), Request: req}
+}
+
+func TestAddAzureError(t *testing.T) {
+	err := runtime.NewResponseError(azureResponse(http.MethodPut, azureVMURL+"?api-version=2024-07-01", http.StatusConflict,
+		`{"error": {"code": "QuotaExceeded", "message": "Operation could not be completed as it results in exceeding approved quota."}}`))
+	var diags diag.Diagnostics
+	AddAzureError(&diags, "azure vm", err)
+	if got := diags[0].Summary(); got != "azure vm failed [QuotaExceeded]" {
+		t.Errorf("summary = %q", got)
+	}
+	want := "/subscriptions/s/resourceGroups/abstract-rg/providers/Microsoft.Compute/virtualMachines/web: Operation could not be completed as it results in exceeding approved quota."
+	if got := diags[0].Detail(); got != want {
+		t.Errorf("detail = %q", got)
+	}
+
+	diags = nil
+	AddAzureError(&diags, "azure vm", errors.New("boom"))
+	if diags[0].Summary() != "azure vm" || diags[0].Detail() != "boom" {
+		t.Errorf("plain error = %q, %q", diags[0].Summary(), diags[0].Detail())
+	}
+}
+
+// vmResult stands in for a generated client response; resume tokens need a named type.
+type vmResult struct{}
+
+type azureTransport func(*http.Request) *http.Response
+
+func (f azureTransport) Do(req *http.Request) (*http.Response, error) { return f(req), nil }
+
+func TestPollAzure(t *testing.T) {
+	opURL := "https://management.azure.com/subscriptions/s/providers/Microsoft.Compute/locations/eastus/operations/op1"
+	pl := runtime.NewPipeline("test", "v1", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport: azureTransport(func(req *http.Request) *http.Response {
+			return azureResponse(req.Method, req.URL.String(), http.StatusOK,
+				`{"status": "Failed", "error": {"code": "AllocationFailed", "message": "no capacity"}}`)
+		}),
+		Retry: policy.RetryOptions{MaxRetries: -1},
+	})
+	initial := azureResponse(http.MethodPut, azureVMURL+"?api-version=2024-07-01", http.StatusCreated, `{"properties": {"provisioningState": "Creating"}}`)
+	initial.Header.Set("Azure-AsyncOperation", opURL)
+	poller, err := runtime.NewPoller[vmResult](initial, pl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = PollAzure(context.Background(), poller)
+	var azErr *AzureError
+	if !errors.As(err, &azErr) {
+		t.Fatalf("err = %v, want an AzureError", err)
+	}
+	if azErr.Code != "AllocationFailed" || azErr.Message != "no capacity" || !strings.HasSuffix(azErr.Target, "/virtualMachines/web") {
+		t.Errorf("err = %+v, want the VM as target", azErr)
+	}
+}
@@ -0,0 +1,150 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+const azureKeyVaultAPIVersion = "7.4"
+
+// AzureKeysClient manages the keys of a Key Vault through its data plane. It
+// only covers the calls abstract_kms_key makes and sends them through the
+// azcore pipeline, which handles authentication and retries.
+//
+// TODO: replace with azkeys once the module is added to go.mod.
+type AzureKeysClient struct {
+	vault  string
+	client *azcore.Client
+}
+
+// NewAzureKeysClient returns a client for the keys of the vault at vaultURL.
+func NewAzureKeysClient(vaultURL string, cred azcore.TokenCredential, options *policy.ClientOptions) (*AzureKeysClient, error) {
+	auth := runtime.NewBearerTokenPolicy(cred, []string{"https://vault.azure.net/.default"}, nil)
+	cl, err := azcore.NewClient("abstract-provider/keyvault", "v1.0.0", runtime.PipelineOptions{PerRetry: []policy.Policy{auth}}, options)
+	if err != nil {
+		return nil, err
+	}
+	return &AzureKeysClient{vault: strings.TrimSuffix(vaultURL, "/"), client: cl}, nil
+}
+
+// AzureKey is the part of a Key Vault key bundle the provider uses.
+type AzureKey struct {
+	Key struct {
+		// KID is the ID of the key's current version.
+		KID string `json:"kid"`
+	} `json:"key"`
+	Attributes struct {
+		Enabled bool `json:"enabled"`
+	} `json:"attributes"`
+}
+
+// ID returns the key's ID without its version, which follows rotations.
+func (k AzureKey) ID() string {
+	if i := strings.Index(k.Key.KID, "/keys/"); i >= 0 {
+		if j := strings.Index(k.Key.KID[i+len("/keys/"):], "/"); j >= 0 {
+			return k.Key.KID[:i+len("/keys/")+j]
+		}
+	}
+	return k.Key.KID
+}
+
+func (c *AzureKeysClient) do(ctx context.Context, method, p string, in, out any) error {
+	req, err := runtime.NewRequest(ctx, method, runtime.JoinPaths(c.vault, p))
+	if err != nil {
+		return err
+	}
+	q := req.Raw().URL.Query()
+	q.Set("api-version", azureKeyVaultAPIVersion)
+	req.Raw().URL.RawQuery = q.Encode()
+	req.Raw().Header.Set("Accept", "application/json")
+	if in != nil {
+		if err := runtime.MarshalAsJSON(req, in); err != nil {
+			return err
+		}
+	}
+	resp, err := c.client.Pipeline().Do(req)
+	if err != nil {
+		return err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return runtime.NewResponseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return runtime.UnmarshalAsJSON(resp, out)
+}
+
+// CreateKey creates an RSA key, or a new version of an existing one.
+func (c *AzureKeysClient) CreateKey(ctx context.Context, name string) (AzureKey, error) {
+	var key AzureKey
+	err := c.do(ctx, http.MethodPost, "/keys/"+url.PathEscape(name)+"/create", map[string]any{"kty": "RSA", "key_size": 2048}, &key)
+	return key, err
+}
+
+// GetKey returns the current version of the key.
+func (c *AzureKeysClient) GetKey(ctx context.Context, name string) (AzureKey, error) {
+	var key AzureKey
+	err := c.do(ctx, http.MethodGet, "/keys/"+url.PathEscape(name), nil, &key)
+	return key, err
+}
+
+// DeleteKey soft-deletes the key; it can be recovered until the vault's
+// retention period ends.
+func (c *AzureKeysClient) DeleteKey(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/keys/"+url.PathEscape(name), nil, nil)
+}
+
+type azureKeyLifetimeAction struct {
+	Trigger struct {
+		TimeAfterCreate  string `json:"timeAfterCreate,omitempty"`
+		TimeBeforeExpiry string `json:"timeBeforeExpiry,omitempty"`
+	} `json:"trigger"`
+	Action struct {
+		Type string `json:"type"`
+	} `json:"action"`
+}
+
+// SetKeyRotation rotates the key every days days, or only notifies before it
+// expires, the vault's default, when days is 0.
+func (c *AzureKeysClient) SetKeyRotation(ctx context.Context, name string, days int64) error {
+	var a azureKeyLifetimeAction
+	if days > 0 {
+		a.Trigger.TimeAfterCreate = fmt.Sprintf("P%dD", days)
+		a.Action.Type = "Rotate"
+	} else {
+		a.Trigger.TimeBeforeExpiry = "P30D"
+		a.Action.Type = "Notify"
+	}
+	in := map[string]any{"lifetimeActions": []azureKeyLifetimeAction{a}}
+	return c.do(ctx, http.MethodPut, "/keys/"+url.PathEscape(name)+"/rotationpolicy", in, nil)
+}
+
+// KeyRotation returns the rotation period of the key in days, 0 when it is
+// not rotated or rotated on some other trigger.
+func (c *AzureKeysClient) KeyRotation(ctx context.Context, name string) (int64, error) {
+	var p struct {
+		LifetimeActions []azureKeyLifetimeAction `json:"lifetimeActions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/keys/"+url.PathEscape(name)+"/rotationpolicy", nil, &p); err != nil {
+		return 0, err
+	}
+	for _, a := range p.LifetimeActions {
+		if !strings.EqualFold(a.Action.Type, "Rotate") {
+			continue
+		}
+		d := strings.TrimSuffix(strings.TrimPrefix(a.Trigger.TimeAfterCreate, "P"), "D")
+		if n, err := strconv.ParseInt(d, 10, 64); err == nil && strings.HasPrefix(a.Trigger.TimeAfterCreate, "P") {
+			return n, nil
+		}
+	}
+	return 0, nil
+}
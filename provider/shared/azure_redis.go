@@ -0,0 +1,126 @@
+package shared
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+const azureRedisAPIVersion = "2023-08-01"
+
+// AzureRedisClient manages Azure Cache for Redis instances through ARM. It
+// only covers the calls abstract_cache makes and sends them through the arm
+// pipeline, so authentication, retries and polling work as in the SDK clients.
+//
+// TODO: replace with armredis once the module is added to go.mod.
+type AzureRedisClient struct {
+	sub    string
+	client *arm.Client
+}
+
+// NewAzureRedisClient returns a client for the caches of subscription sub.
+func NewAzureRedisClient(sub string, cred azcore.TokenCredential, options *arm.ClientOptions) (*AzureRedisClient, error) {
+	cl, err := arm.NewClient("abstract-provider/redis", "v1.0.0", cred, options)
+	if err != nil {
+		return nil, err
+	}
+	return &AzureRedisClient{sub: sub, client: cl}, nil
+}
+
+// AzureRedis is the part of a Microsoft.Cache/redis resource the provider uses.
+type AzureRedis struct {
+	ID         string               `json:"id,omitempty"`
+	Name       string               `json:"name,omitempty"`
+	Location   string               `json:"location"`
+	Properties AzureRedisProperties `json:"properties"`
+}
+
+type AzureRedisProperties struct {
+	SKU               AzureRedisSKU `json:"sku"`
+	MinimumTLSVersion string        `json:"minimumTlsVersion,omitempty"`
+	ProvisioningState string        `json:"provisioningState,omitempty"`
+	HostName          string        `json:"hostName,omitempty"`
+	SSLPort           int64         `json:"sslPort,omitempty"`
+}
+
+// AzureRedisSKU is a cache size, such as Basic C0: Name "Basic", Family "C"
+// and Capacity 0.
+type AzureRedisSKU struct {
+	Name     string `json:"name"`
+	Family   string `json:"family"`
+	Capacity int    `json:"capacity"`
+}
+
+// AzureRedisDeleteResult is the empty result of a delete.
+type AzureRedisDeleteResult struct{}
+
+func (c *AzureRedisClient) request(ctx context.Context, method, rg, name string) (*policy.Request, error) {
+	u := runtime.JoinPaths(c.client.Endpoint(), "/subscriptions/"+url.PathEscape(c.sub)+"/resourceGroups/"+url.PathEscape(rg)+"/providers/Microsoft.Cache/redis/"+url.PathEscape(name))
+	req, err := runtime.NewRequest(ctx, method, u)
+	if err != nil {
+		return nil, err
+	}
+	q := req.Raw().URL.Query()
+	q.Set("api-version", azureRedisAPIVersion)
+	req.Raw().URL.RawQuery = q.Encode()
+	req.Raw().Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// BeginCreate starts creating or updating a cache.
+func (c *AzureRedisClient) BeginCreate(ctx context.Context, rg, name string, cache AzureRedis) (*runtime.Poller[AzureRedis], error) {
+	req, err := c.request(ctx, http.MethodPut, rg, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.MarshalAsJSON(req, cache); err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Pipeline().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusCreated) {
+		return nil, runtime.NewResponseError(resp)
+	}
+	return runtime.NewPoller[AzureRedis](resp, c.client.Pipeline(), nil)
+}
+
+// Get returns the cache.
+func (c *AzureRedisClient) Get(ctx context.Context, rg, name string) (AzureRedis, error) {
+	var cache AzureRedis
+	req, err := c.request(ctx, http.MethodGet, rg, name)
+	if err != nil {
+		return cache, err
+	}
+	resp, err := c.client.Pipeline().Do(req)
+	if err != nil {
+		return cache, err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return cache, runtime.NewResponseError(resp)
+	}
+	err = runtime.UnmarshalAsJSON(resp, &cache)
+	return cache, err
+}
+
+// BeginDelete starts deleting the cache.
+func (c *AzureRedisClient) BeginDelete(ctx context.Context, rg, name string) (*runtime.Poller[AzureRedisDeleteResult], error) {
+	req, err := c.request(ctx, http.MethodDelete, rg, name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Pipeline().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusAccepted, http.StatusNoContent) {
+		return nil, runtime.NewResponseError(resp)
+	}
+	return runtime.NewPoller[AzureRedisDeleteResult](resp, c.client.Pipeline(), nil)
+}
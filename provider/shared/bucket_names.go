@@ -0,0 +1,76 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var (
+	s3BucketName       = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	gcsBucketName      = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,220}[a-z0-9]$`)
+	azureContainerName = regexp.MustCompile(`^[a-z0-9](-?[a-z0-9])*$`)
+	azureAccountName   = regexp.MustCompile(`^[a-z0-9]{3,24}$`)
+)
+
+// Reserved parts of S3 bucket names.
+var (
+	s3ReservedPrefixes = []string{"xn--", "sthree-", "amzn-s3-demo-"}
+	s3ReservedSuffixes = []string{"-s3alias", "--ol-s3", ".mrap", "--x-s3", "--table-s3"}
+)
+
+// ValidateBucketName checks the name of a bucket against the rules of cloud:
+// an S3 bucket, a GCS bucket or an Azure blob container. Names must also be
+// globally unique on AWS and GCP, which only the create can tell.
+func ValidateBucketName(cloud, name string) error {
+	switch cloud {
+	case "aws":
+		if !s3BucketName.MatchString(name) {
+			return errors.New("S3 bucket names are 3 to 63 lowercase letters, digits, dots and hyphens, starting and ending with a letter or digit")
+		}
+		if strings.Contains(name, "..") {
+			return errors.New("S3 bucket names cannot contain two adjacent dots")
+		}
+		if net.ParseIP(name) != nil {
+			return errors.New("S3 bucket names cannot be IP addresses")
+		}
+		for _, p := range s3ReservedPrefixes {
+			if strings.HasPrefix(name, p) {
+				return fmt.Errorf("S3 bucket names cannot start with %q", p)
+			}
+		}
+		for _, s := range s3ReservedSuffixes {
+			if strings.HasSuffix(name, s) {
+				return fmt.Errorf("S3 bucket names cannot end with %q", s)
+			}
+		}
+	case "gcp":
+		if !gcsBucketName.MatchString(name) {
+			return errors.New("GCS bucket names are 3 to 222 lowercase letters, digits, dots, underscores and hyphens, starting and ending with a letter or digit")
+		}
+		for _, part := range strings.Split(name, ".") {
+			if len(part) > 63 {
+				return fmt.Errorf("each dot-separated part of a GCS bucket name is at most 63 characters, %q has %d", part, len(part))
+			}
+		}
+		if net.ParseIP(name) != nil {
+			return errors.New("GCS bucket names cannot be IP addresses")
+		}
+		if strings.HasPrefix(name, "goog") || strings.Contains(name, "google") {
+			return errors.New(`GCS bucket names cannot start with "goog" or contain "google"`)
+		}
+	case "azure":
+		if len(name) < 3 || len(name) > 63 || !azureContainerName.MatchString(name) {
+			return errors.New("Azure container names are 3 to 63 lowercase letters, digits and hyphens, starting and ending with a letter or digit, with no two hyphens in a row")
+		}
+	}
+	return nil
+}
+
+// ValidAzureStorageAccount reports whether name is a valid storage account
+// name: 3 to 24 lowercase letters and digits.
+func ValidAzureStorageAccount(name string) bool {
+	return azureAccountName.MatchString(name)
+}
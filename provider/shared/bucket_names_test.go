@@ -0,0 +1,50 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateBucketName(t *testing.T) {
+	cases := []struct {
+		cloud, name string
+		ok          bool
+	}{
+		{"aws", "my-bucket.logs", true},
+		{"aws", "ab", false},
+		{"aws", strings.Repeat("a", 64), false},
+		{"aws", "My-Bucket", false},
+		{"aws", "my_bucket", false},
+		{"aws", "-bucket", false},
+		{"aws", "my..bucket", false},
+		{"aws", "192.168.5.4", false},
+		{"aws", "xn--bucket", false},
+		{"aws", "bucket-s3alias", false},
+		{"gcp", "my_bucket-1", true},
+		{"gcp", strings.Repeat("a", 63) + ".example.com", true},
+		{"gcp", strings.Repeat("a", 64), false},
+		{"gcp", "_bucket", false},
+		{"gcp", "googbucket", false},
+		{"gcp", "my-google-bucket", false},
+		{"gcp", "10.0.0.1", false},
+		{"azure", "my-container", true},
+		{"azure", "my--container", false},
+		{"azure", "my-container-", false},
+		{"azure", "my.container", false},
+		{"azure", "Container", false},
+		{"azure", strings.Repeat("a", 64), false},
+	}
+	for _, tc := range cases {
+		if err := ValidateBucketName(tc.cloud, tc.name); (err == nil) != tc.ok {
+			t.Errorf("%s %q: err = %v", tc.cloud, tc.name, err)
+		}
+	}
+}
+
+func TestValidAzureStorageAccount(t *testing.T) {
+	for name, ok := range map[string]bool{"logs2024": true, "ab": false, "my-logs": false, strings.Repeat("a", 25): false} {
+		if got := ValidAzureStorageAccount(name); got != ok {
+			t.Errorf("ValidAzureStorageAccount(%q) = %v", name, got)
+		}
+	}
+}
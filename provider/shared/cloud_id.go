@@ -0,0 +1,125 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// CloudIDs builds the canonical identifiers resources expose as cloud_id: an
+// ARN on AWS, a resource ID on Azure and a self link or full resource name on
+// GCP. A nil CloudIDs builds empty identifiers.
+type CloudIDs struct {
+	AWSRegion  string
+	AzureSubID string
+	GCPProject string
+
+	// awsAccount looks up the AWS account ID, which only ARNs need.
+	awsAccount func(context.Context) (string, error)
+	mu         sync.Mutex
+	account    string
+	// home is the CloudIDs In was called on, which looks the account up.
+	home *CloudIDs
+}
+
+// NewCloudIDs returns a CloudIDs for the provider's clouds. awsAccount is
+// called the first time an ARN needs the account ID, and again only if it
+// failed.
+func NewCloudIDs(awsRegion, azureSubID, gcpProject string, awsAccount func(context.Context) (string, error)) *CloudIDs {
+	return &CloudIDs{AWSRegion: awsRegion, AzureSubID: azureSubID, GCPProject: gcpProject, awsAccount: awsAccount}
+}
+
+// AWSPartition returns the partition of an AWS region.
+func AWSPartition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	}
+	return "aws"
+}
+
+// In returns a CloudIDs building ARNs in region, sharing c's account lookup.
+// An empty region is c's own.
+func (c *CloudIDs) In(region string) *CloudIDs {
+	if c == nil || region == "" || region == c.AWSRegion {
+		return c
+	}
+	return &CloudIDs{AWSRegion: region, AzureSubID: c.AzureSubID, GCPProject: c.GCPProject, home: c}
+}
+
+func (c *CloudIDs) awsAccountID(ctx context.Context) (string, error) {
+	if c.home != nil {
+		return c.home.awsAccountID(ctx)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.account != "" || c.awsAccount == nil {
+		return c.account, nil
+	}
+	account, err := c.awsAccount(ctx)
+	if err != nil {
+		return "", fmt.Errorf("aws account lookup: %w", err)
+	}
+	c.account = account
+	return account, nil
+}
+
+// ARN builds the ARN of resource in the provider's account. Regional services
+// get the provider's region; global ones, like IAM and CloudFront, none.
+func (c *CloudIDs) ARN(ctx context.Context, service string, regional bool, resource string) (string, error) {
+	if c == nil {
+		return "", nil
+	}
+	account, err := c.awsAccountID(ctx)
+	if err != nil || account == "" {
+		return "", err
+	}
+	return c.arn(service, regional, account, resource), nil
+}
+
+// AccountlessARN builds an ARN without an account, the form S3 buckets and
+// EBS snapshots use.
+func (c *CloudIDs) AccountlessARN(service string, regional bool, resource string) string {
+	if c == nil {
+		return ""
+	}
+	return c.arn(service, regional, "", resource)
+}
+
+func (c *CloudIDs) arn(service string, regional bool, account, resource string) string {
+	region := ""
+	if regional {
+		region = c.AWSRegion
+	}
+	return fmt.Sprintf("arn:%s:%s:%s:%s:%s", AWSPartition(c.AWSRegion), service, region, account, resource)
+}
+
+// AzureID builds the ID of a resource in the provider's subscription, such as
+// AzureID("abstract-rg", "Microsoft.Network/loadBalancers", "web").
+func (c *CloudIDs) AzureID(resourceGroup, resourceType, name string) string {
+	if c == nil || c.AzureSubID == "" {
+		return ""
+	}
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s/%s", c.AzureSubID, resourceGroup, resourceType, name)
+}
+
+// GCPSelfLink builds the self link of a Compute Engine resource from its path
+// in the project, such as "zones/us-central1-a/instances/web".
+func (c *CloudIDs) GCPSelfLink(path string) string {
+	if c == nil || c.GCPProject == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/%s", c.GCPProject, path)
+}
+
+// GCPName builds the full name of a resource from its path in the project,
+// such as "secrets/db-password".
+func (c *CloudIDs) GCPName(path string) string {
+	if c == nil || c.GCPProject == "" {
+		return ""
+	}
+	return fmt.Sprintf("projects/%s/%s", c.GCPProject, path)
+}
@@ -0,0 +1,109 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCloudIDsARN(t *testing.T) {
+	calls := 0
+	ids := NewCloudIDs("us-east-2", "sub", "proj", func(context.Context) (string, error) {
+		calls++
+		return "123456789012", nil
+	})
+	ctx := context.Background()
+	cases := []struct {
+		service  string
+		regional bool
+		resource string
+		want     string
+	}{
+		{"ec2", true, "instance/i-0abc", "arn:aws:ec2:us-east-2:123456789012:instance/i-0abc"},
+		{"iam", false, "role/web", "arn:aws:iam::123456789012:role/web"},
+	}
+	for _, tc := range cases {
+		got, err := ids.ARN(ctx, tc.service, tc.regional, tc.resource)
+		if err != nil || got != tc.want {
+			t.Errorf("ARN(%q, %q) = %q, %v; want %q", tc.service, tc.resource, got, err, tc.want)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("account looked up %d times, want once", calls)
+	}
+	if got := ids.AccountlessARN("s3", false, "logs"); got != "arn:aws:s3:::logs" {
+		t.Errorf("AccountlessARN = %q", got)
+	}
+}
+
+func TestCloudIDsIn(t *testing.T) {
+	calls := 0
+	ids := NewCloudIDs("us-east-2", "sub", "proj", func(context.Context) (string, error) {
+		calls++
+		return "123456789012", nil
+	})
+	ctx := context.Background()
+	if ids.In("") != ids || ids.In("us-east-2") != ids {
+		t.Error("In the provider region built a new CloudIDs")
+	}
+	west := ids.In("eu-west-1")
+	if got, err := west.ARN(ctx, "sqs", true, "jobs"); err != nil || got != "arn:aws:sqs:eu-west-1:123456789012:jobs" {
+		t.Errorf("ARN = %q, %v", got, err)
+	}
+	if got, _ := ids.ARN(ctx, "sqs", true, "jobs"); got != "arn:aws:sqs:us-east-2:123456789012:jobs" {
+		t.Errorf("provider region ARN = %q", got)
+	}
+	if calls != 1 {
+		t.Errorf("account looked up %d times, want once", calls)
+	}
+}
+
+func TestCloudIDsARNLookupFails(t *testing.T) {
+	fail := true
+	ids := NewCloudIDs("cn-north-1", "", "", func(context.Context) (string, error) {
+		if fail {
+			return "", errors.New("no credentials")
+		}
+		return "123456789012", nil
+	})
+	if _, err := ids.ARN(context.Background(), "ec2", true, "vpc/vpc-0abc"); err == nil {
+		t.Fatal("ARN succeeded without an account")
+	}
+	// a failed lookup is retried
+	fail = false
+	got, err := ids.ARN(context.Background(), "ec2", true, "vpc/vpc-0abc")
+	if err != nil || got != "arn:aws-cn:ec2:cn-north-1:123456789012:vpc/vpc-0abc" {
+		t.Errorf("ARN = %q, %v", got, err)
+	}
+}
+
+func TestCloudIDsNil(t *testing.T) {
+	var ids *CloudIDs
+	if got, err := ids.ARN(context.Background(), "ec2", true, "vpc/vpc-0abc"); got != "" || err != nil {
+		t.Errorf("ARN = %q, %v", got, err)
+	}
+	if ids.AzureID("rg", "Microsoft.Network/virtualNetworks", "main") != "" || ids.GCPSelfLink("global/networks/main") != "" || ids.GCPName("secrets/s") != "" {
+		t.Error("nil CloudIDs built an identifier")
+	}
+}
+
+func TestCloudIDsAzureAndGCP(t *testing.T) {
+	ids := NewCloudIDs("", "sub", "proj", nil)
+	if got := ids.AzureID("abstract-rg", "Microsoft.Network/loadBalancers", "web"); got != "/subscriptions/sub/resourceGroups/abstract-rg/providers/Microsoft.Network/loadBalancers/web" {
+		t.Errorf("AzureID = %q", got)
+	}
+	if got := ids.GCPSelfLink("zones/us-central1-a/instances/web"); got != "https://www.googleapis.com/compute/v1/projects/proj/zones/us-central1-a/instances/web" {
+		t.Errorf("GCPSelfLink = %q", got)
+	}
+	if got := ids.GCPName("secrets/db"); got != "projects/proj/secrets/db" {
+		t.Errorf("GCPName = %q", got)
+	}
+}
+
+func TestAWSPartition(t *testing.T) {
+	for region, want := range map[string]string{"us-east-1": "aws", "cn-northwest-1": "aws-cn", "us-gov-west-1": "aws-us-gov", "": "aws"} {
+		if got := AWSPartition(region); got != want {
+			t.Errorf("AWSPartition(%q) = %q, want %q", region, got, want)
+		}
+	}
+}
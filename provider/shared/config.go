@@ -1,82 +1,164 @@
 package shared
 
 import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
 	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 
 	"cloud.google.com/go/storage"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appservice/armappservice"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/cdn/armcdn"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
 	ci "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerinstance/armcontainerinstance"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/msi/armmsi"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mysql/armmysqlflexibleservers"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/postgresql/armpostgresqlflexibleservers"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/servicebus/armservicebus"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
-	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	cloudfunctions "google.golang.org/api/cloudfunctions/v1"
+	cloudfunctionsv2 "google.golang.org/api/cloudfunctions/v2"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	crm "google.golang.org/api/cloudresourcemanager/v1"
 	compute "google.golang.org/api/compute/v1"
 	container "google.golang.org/api/container/v1"
 	dnsapi "google.golang.org/api/dns/v1"
+	iamapi "google.golang.org/api/iam/v1"
+	memcache "google.golang.org/api/memcache/v1"
+	monitoring "google.golang.org/api/monitoring/v1"
+	monitoringv3 "google.golang.org/api/monitoring/v3"
+	pubsub "google.golang.org/api/pubsub/v1"
+	redis "google.golang.org/api/redis/v1"
+	run "google.golang.org/api/run/v2"
 	secretmanager "google.golang.org/api/secretmanager/v1"
+	servicenetworking "google.golang.org/api/servicenetworking/v1"
 	sqladmin "google.golang.org/api/sqladmin/v1beta4"
 )
 
 type ProviderConfig struct {
-	AWSS3      *s3.Client
-	AWSEC2     *ec2.Client
-	AWSEKS     *eks.Client
-	AWSLambda  *lambda.Client
-	AWSRDS     *rds.Client
-	AWSSQS     *sqs.Client
-	AWSSM      *secretsmanager.Client
-	AWSECR     *ecr.Client
-	AWSECS     *ecs.Client
-	AWSELB     *elbv2.Client
-	AWSRoute53 *route53.Client
+	AWSS3          *s3.Client
+	AWSEC2         *ec2.Client
+	AWSEKS         *eks.Client
+	AWSLambda      *lambda.Client
+	AWSRDS         *rds.Client
+	AWSSQS         *sqs.Client
+	AWSSM          *secretsmanager.Client
+	AWSECR         *ecr.Client
+	AWSECS         *ecs.Client
+	AWSELB         *elbv2.Client
+	AWSRoute53     *route53.Client
+	AWSCloudFront  *cloudfront.Client
+	AWSSNS         *sns.Client
+	AWSCloudWatch  *cloudwatch.Client
+	AWSIAM         *iam.Client
+	AWSElastiCache *elasticache.Client
+	AWSKMS         *kms.Client
+	// AWSRegions has the regional clients of every region; the ones above are
+	// the provider region's.
+	AWSRegions *AWSRegions
+
+	AzureCred                 azcore.TokenCredential
+	AzureSubID                string
+	AzureLocation             string
+	AzureRGClient             *armresources.ResourceGroupsClient
+	AzureResourcesClient      *armresources.Client
+	AzureStorageAcct          *armstorage.AccountsClient
+	AzureBlobContainers       *armstorage.BlobContainersClient
+	AzureBlobServices         *armstorage.BlobServicesClient
+	AzureVNetClient           *armnetwork.VirtualNetworksClient
+	AzureSubnetClient         *armnetwork.SubnetsClient
+	AzureNICClient            *armnetwork.InterfacesClient
+	AzurePIPClient            *armnetwork.PublicIPAddressesClient
+	AzureLBClient             *armnetwork.LoadBalancersClient
+	AzureVPNGatewayClient     *armnetwork.VirtualNetworkGatewaysClient
+	AzureLocalGatewayClient   *armnetwork.LocalNetworkGatewaysClient
+	AzureVPNConnectionClient  *armnetwork.VirtualNetworkGatewayConnectionsClient
+	AzureNATClient            *armnetwork.NatGatewaysClient
+	AzurePeeringClient        *armnetwork.VirtualNetworkPeeringsClient
+	AzureVMClient             *armcompute.VirtualMachinesClient
+	AzureSnapshotClient       *armcompute.SnapshotsClient
+	AzureAKSClient            *armcontainerservice.ManagedClustersClient
+	AzureWebClient            *armappservice.WebAppsClient
+	AzurePlanClient           *armappservice.PlansClient
+	AzureMySQLClient          *armmysqlflexibleservers.ServersClient
+	AzurePostgresClient       *armpostgresqlflexibleservers.ServersClient
+	AzureRegistryClient       *armcontainerregistry.RegistriesClient
+	AzureContainerClient      *ci.ContainerGroupsClient
+	AzureDNSZoneClient        *armdns.ZonesClient
+	AzureDNSRecordClient      *armdns.RecordSetsClient
+	AzureCDNProfileClient     *armcdn.ProfilesClient
+	AzureCDNEndpointClient    *armcdn.EndpointsClient
+	AzureCDNDomainClient      *armcdn.CustomDomainsClient
+	AzureSBNamespaceClient    *armservicebus.NamespacesClient
+	AzureSBTopicClient        *armservicebus.TopicsClient
+	AzureSBSubscriptionClient *armservicebus.SubscriptionsClient
+	AzureIdentityClient       *armmsi.UserAssignedIdentitiesClient
+	AzureRoleAssignmentClient *armauthorization.RoleAssignmentsClient
+	AzureRoleDefinitionClient *armauthorization.RoleDefinitionsClient
+	AzureRedisClient          *AzureRedisClient
+	// AzureStorageAccount, when set, is shared by all buckets, queues and functions
+	// and is never created or deleted by the provider.
+	AzureStorageAccount       string
+	AzureStorageResourceGroup string
+
+	GCPStorage    *storage.Client
+	GCPCompute    *compute.Service
+	GCPGKE        *container.Service
+	GCPFunctions  *cloudfunctions.Service
+	GCPCloudSQL   *sqladmin.Service
+	GCPDNS        *dnsapi.Service
+	GCPSecrets    *secretmanager.Service
+	GCPPubSub     *pubsub.Service
+	GCPMonitoring *monitoring.Service
+	GCPRun        *run.Service
+	GCPIAM        *iamapi.Service
+	GCPProjects   *crm.Service
+	GCPRedis      *redis.Service
+	GCPMemcache   *memcache.Service
+	GCPKMS        *cloudkms.Service
+	// GCPServiceNetworking peers networks with Google services for private Cloud SQL.
+	GCPServiceNetworking *servicenetworking.APIService
+	GCPProject           string
+	GCPRegion            string
+
+	// GCPFunctionsV2 manages 2nd gen Cloud Functions, which run on Cloud Run.
+	GCPFunctionsV2 *cloudfunctionsv2.Service
+	// GCPMonitoringV3 manages alerting policies; GCPMonitoring only has dashboards.
+	GCPMonitoringV3 *monitoringv3.Service
+
+	// RequestTimeout bounds each resource operation; zero means no limit.
+	RequestTimeout time.Duration
+
+	// CloudIDs builds the cloud_id of every resource.
+	CloudIDs *CloudIDs
 
-	AzureCred            azcore.TokenCredential
-	AzureSubID           string
-	AzureLocation        string
-	AzureRGClient        *armresources.ResourceGroupsClient
-	AzureStorageAcct     *armstorage.AccountsClient
-	AzureBlobContainers  *armstorage.BlobContainersClient
-	AzureVNetClient      *armnetwork.VirtualNetworksClient
-	AzureSubnetClient    *armnetwork.SubnetsClient
-	AzureNICClient       *armnetwork.InterfacesClient
-	AzurePIPClient       *armnetwork.PublicIPAddressesClient
-	AzureLBClient        *armnetwork.LoadBalancersClient
-	AzureVMClient        *armcompute.VirtualMachinesClient
-	AzureAKSClient       *armcontainerservice.ManagedClustersClient
-	AzureWebClient       *armappservice.WebAppsClient
-	AzurePlanClient      *armappservice.PlansClient
-	AzureMySQLClient     *armmysqlflexibleservers.ServersClient
-	AzurePostgresClient  *armpostgresqlflexibleservers.ServersClient
-	AzureRegistryClient  *armcontainerregistry.RegistriesClient
-	AzureContainerClient *ci.ContainerGroupsClient
-	AzureDNSZoneClient   *armdns.ZonesClient
-	AzureDNSRecordClient *armdns.RecordSetsClient
+	// SizeAliases maps a cloud to lowercase instance size aliases and the
+	// machine types they stand for, on top of the built-in ones.
+	SizeAliases map[string]map[string]string
 
-	GCPStorage   *storage.Client
-	GCPCompute   *compute.Service
-	GCPGKE       *container.Service
-	GCPFunctions *cloudfunctions.Service
-	GCPCloudSQL  *sqladmin.Service
-	GCPDNS       *dnsapi.Service
-	GCPSecrets   *secretmanager.Service
-	GCPProject   string
-	GCPRegion    string
+	// DefaultTags are the provider's default_tags, added to every resource's
+	// tags or labels. A resource's own value wins on a shared key.
+	DefaultTags map[string]string
 }
@@ -0,0 +1,30 @@
+package shared
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// cloudSettings names what the provider block needs before it creates the
+// clients for each cloud.
+var cloudSettings = map[string]string{
+	"aws":   "AWS credentials",
+	"azure": "subscription_id, client_id, client_secret and tenant_id",
+	"gcp":   "a project",
+}
+
+// RequireClients reports whether a resource of the given cloud type can be
+// managed. ok is the resource's own check that it holds every client that
+// cloud needs; when it is false an error explaining the missing provider
+// configuration is added to diags. Clouds the provider does not support are
+// passed through for the resource to reject.
+func RequireClients(diags *diag.Diagnostics, cloud string, ok bool) bool {
+	settings, known := cloudSettings[cloud]
+	if ok || !known {
+		return true
+	}
+	diags.AddError(cloud+" not configured in provider block",
+		fmt.Sprintf("The resource has type = %q, but the provider has no %s clients. Set %s in the provider's %s block.", cloud, cloud, settings, cloud))
+	return false
+}
@@ -0,0 +1,23 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+func TestRequireClients(t *testing.T) {
+	var diags diag.Diagnostics
+	if !RequireClients(&diags, "aws", true) || diags.HasError() {
+		t.Errorf("configured cloud rejected: %v", diags)
+	}
+	if !RequireClients(&diags, "oracle", false) || diags.HasError() {
+		t.Errorf("unsupported cloud should be left to the resource: %v", diags)
+	}
+	if RequireClients(&diags, "gcp", false) {
+		t.Error("missing gcp clients accepted")
+	}
+	if len(diags) != 1 || diags[0].Summary() != "gcp not configured in provider block" {
+		t.Errorf("diagnostics = %v", diags)
+	}
+}
@@ -0,0 +1,45 @@
+package shared
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// MaxGCPLabels is the most labels a GCP resource can carry.
+const MaxGCPLabels = 64
+
+var (
+	gcpLabelKey   = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	gcpLabelValue = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+)
+
+// MergeTags returns defaults overlaid with tags, so a resource's own tag wins
+// over a provider default with the same key.
+func MergeTags(defaults, tags map[string]string) map[string]string {
+	merged := maps.Clone(defaults)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, tags)
+	return merged
+}
+
+// ValidateGCPLabels checks labels against the rules GCP applies to them:
+// keys are 1 to 63 lowercase letters, digits, underscores and hyphens
+// starting with a letter, and values are up to 63 of the same characters.
+func ValidateGCPLabels(labels map[string]string) error {
+	if len(labels) > MaxGCPLabels {
+		return fmt.Errorf("%d labels given, GCP allows at most %d", len(labels), MaxGCPLabels)
+	}
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		if !gcpLabelKey.MatchString(k) {
+			return fmt.Errorf("label key %q must be 1 to 63 lowercase letters, digits, underscores and hyphens, starting with a letter", k)
+		}
+		if !gcpLabelValue.MatchString(labels[k]) {
+			return fmt.Errorf("value of label %q must be at most 63 lowercase letters, digits, underscores and hyphens", k)
+		}
+	}
+	return nil
+}
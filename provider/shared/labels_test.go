@@ -0,0 +1,43 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateGCPLabels(t *testing.T) {
+	many := map[string]string{}
+	for i := range MaxGCPLabels + 1 {
+		many[fmt.Sprintf("k%d", i)] = ""
+	}
+	cases := []struct {
+		name   string
+		labels map[string]string
+		ok     bool
+	}{
+		{"none", nil, true},
+		{"valid", map[string]string{"team": "web", "cost_center": "cc-42", "empty": ""}, true},
+		{"uppercase key", map[string]string{"Team": "web"}, false},
+		{"digit first", map[string]string{"1team": "web"}, false},
+		{"uppercase value", map[string]string{"team": "Web"}, false},
+		{"dotted value", map[string]string{"version": "1.2"}, false},
+		{"long key", map[string]string{strings.Repeat("a", 64): ""}, false},
+		{"too many", many, false},
+	}
+	for _, tc := range cases {
+		if err := ValidateGCPLabels(tc.labels); (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	got := MergeTags(map[string]string{"owner": "platform", "team": "ops"}, map[string]string{"team": "web"})
+	if len(got) != 2 || got["owner"] != "platform" || got["team"] != "web" {
+		t.Errorf("MergeTags = %v, want the resource's team to win", got)
+	}
+	if got := MergeTags(nil, nil); got == nil || len(got) != 0 {
+		t.Errorf("MergeTags(nil, nil) = %#v, want an empty map", got)
+	}
+}
@@ -0,0 +1,17 @@
+package shared
+
+// DefaultAzureLocation is used when neither the resource nor the provider
+// sets a location.
+const DefaultAzureLocation = "eastus"
+
+// AzureLocation resolves where an Azure resource is created. The resource's
+// own region wins over the provider's location.
+func AzureLocation(region, providerLocation string) string {
+	switch {
+	case region != "":
+		return region
+	case providerLocation != "":
+		return providerLocation
+	}
+	return DefaultAzureLocation
+}
@@ -0,0 +1,18 @@
+package shared
+
+import "testing"
+
+func TestAzureLocation(t *testing.T) {
+	cases := []struct {
+		region, provider, want string
+	}{
+		{"westeurope", "eastus2", "westeurope"},
+		{"", "eastus2", "eastus2"},
+		{"", "", DefaultAzureLocation},
+	}
+	for _, tc := range cases {
+		if got := AzureLocation(tc.region, tc.provider); got != tc.want {
+			t.Errorf("AzureLocation(%q, %q) = %q, want %q", tc.region, tc.provider, got, tc.want)
+		}
+	}
+}
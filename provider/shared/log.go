@@ -0,0 +1,106 @@
+package shared
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// StartOperation prepares the context for a resource's create, read, update
+// or delete. data is the request's plan, or its state for read and delete.
+// Log entries get the resource's cloud and name as fields, and the values of
+// sensitive attributes are masked. The operation is bounded by timeout as in
+// WithTimeout. The returned function logs how the operation ended and must be
+// deferred.
+func StartOperation(ctx context.Context, op string, data any, timeout time.Duration, diags *diag.Diagnostics) (context.Context, func()) {
+	var get func(string) string
+	var sensitive []string
+	switch d := data.(type) {
+	case tfsdk.Plan:
+		get = func(name string) string { return attrString(ctx, d.GetAttribute, name) }
+		sensitive = sensitiveAttributes(d.Schema.GetAttributes())
+	case tfsdk.State:
+		get = func(name string) string { return attrString(ctx, d.GetAttribute, name) }
+		sensitive = sensitiveAttributes(d.Schema.GetAttributes())
+	default:
+		get = func(string) string { return "" }
+	}
+	for _, name := range sensitive {
+		if v := get(name); v != "" {
+			ctx = tflog.MaskLogStrings(ctx, v)
+		}
+	}
+	ctx = tflog.SetField(ctx, "cloud", get("type"))
+	ctx = tflog.SetField(ctx, "name", get("name"))
+
+	start := time.Now()
+	tflog.Debug(ctx, "starting "+op)
+	ctx, done := WithTimeout(ctx, timeout, diags)
+	return ctx, func() {
+		done()
+		fields := map[string]any{"duration_ms": time.Since(start).Milliseconds()}
+		if diags.HasError() {
+			fields["errors"] = diags.ErrorsCount()
+			tflog.Debug(ctx, op+" failed", fields)
+			return
+		}
+		tflog.Debug(ctx, "finished "+op, fields)
+	}
+}
+
+// attrString reads a string attribute, returning "" if it is not set or the
+// resource has no such attribute.
+func attrString(ctx context.Context, get func(context.Context, path.Path, any) diag.Diagnostics, name string) string {
+	var v types.String
+	if get(ctx, path.Root(name), &v).HasError() {
+		return ""
+	}
+	return v.ValueString()
+}
+
+// sensitiveAttributes returns the names of the top-level sensitive attributes.
+func sensitiveAttributes[A interface{ IsSensitive() bool }](attrs map[string]A) []string {
+	var names []string
+	for name, a := range attrs {
+		if a.IsSensitive() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// LogTransport logs each API request with its status and duration. Only the
+// method, host and path are logged; query strings can carry signatures and
+// tokens.
+type LogTransport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip sends req and logs it at debug level.
+func (t *LogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	fields := map[string]any{
+		"method":      req.Method,
+		"host":        req.URL.Host,
+		"path":        req.URL.Path,
+		"duration_ms": time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	} else {
+		fields["status"] = resp.StatusCode
+	}
+	tflog.Debug(req.Context(), "api request", fields)
+	return resp, err
+}
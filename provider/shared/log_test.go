@@ -0,0 +1,84 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+)
+
+func TestStartOperation(t *testing.T) {
+	var out bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &out)
+	s := schema.Schema{Attributes: map[string]schema.Attribute{
+		"type":  schema.StringAttribute{Required: true},
+		"name":  schema.StringAttribute{Required: true},
+		"value": schema.StringAttribute{Required: true, Sensitive: true},
+	}}
+	raw := tftypes.NewValue(s.Type().TerraformType(ctx), map[string]tftypes.Value{
+		"type":  tftypes.NewValue(tftypes.String, "aws"),
+		"name":  tftypes.NewValue(tftypes.String, "db-password"),
+		"value": tftypes.NewValue(tftypes.String, "hunter2"),
+	})
+	var diags diag.Diagnostics
+	ctx, done := StartOperation(ctx, "create", tfsdk.State{Schema: s, Raw: raw}, 0, &diags)
+	tflog.Debug(ctx, "calling the API with hunter2")
+	diags.AddError("aws", "boom")
+	done()
+
+	entries, err := tflogtest.MultilineJSONDecode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("entries = %v", entries)
+	}
+	for _, e := range entries {
+		if e["cloud"] != "aws" || e["name"] != "db-password" {
+			t.Errorf("entry without resource fields: %v", e)
+		}
+	}
+	if msg := entries[1]["@message"]; strings.Contains(msg.(string), "hunter2") {
+		t.Errorf("sensitive value logged: %q", msg)
+	}
+	if last := entries[2]; last["@message"] != "create failed" || last["errors"] != float64(1) {
+		t.Errorf("last entry = %v", last)
+	}
+}
+
+func TestLogTransport(t *testing.T) {
+	var out bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &out)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/upload?signature=secret", nil)
+	resp, err := (&http.Client{Transport: &LogTransport{}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	logged := out.String()
+	entries, err := tflogtest.MultilineJSONDecode(&out)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("entries = %v, %v", entries, err)
+	}
+	e := entries[0]
+	if e["path"] != "/upload" || e["status"] != float64(http.StatusTeapot) || e["method"] != "GET" {
+		t.Errorf("entry = %v", e)
+	}
+	if strings.Contains(logged, "signature") {
+		t.Error("query string logged")
+	}
+}
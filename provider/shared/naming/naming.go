@@ -0,0 +1,90 @@
+// Package naming derives Azure resource names from the names given in
+// configuration. Each Azure resource type has its own length and character
+// rules, so a name that is valid for one resource may be rejected by another.
+package naming
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// suffixLen is the number of hash characters appended to a name that had to
+// be altered, so that distinct names stay distinct after normalization.
+const suffixLen = 6
+
+// rule describes the names Azure accepts for one resource type.
+type rule struct {
+	kind     string
+	min, max int
+	// hyphens allows '-' inside the name; it is never allowed at either end.
+	hyphens bool
+}
+
+var (
+	registry  = rule{kind: "container registry", min: 5, max: 50}
+	dnsPrefix = rule{kind: "AKS DNS prefix", min: 1, max: 54, hyphens: true}
+	siteName  = rule{kind: "function app", min: 2, max: 60, hyphens: true}
+	planName  = rule{kind: "app service plan", min: 1, max: 40, hyphens: true}
+)
+
+// AzureRegistry returns a container registry name: 5-50 lowercase letters and digits.
+func AzureRegistry(name string) (string, error) { return registry.normalize(name) }
+
+// AzureDNSPrefix returns an AKS DNS prefix: up to 54 letters, digits and
+// hyphens, starting and ending with a letter or digit.
+func AzureDNSPrefix(name string) (string, error) { return dnsPrefix.normalize(name) }
+
+// AzureFunctionApp returns a function app (site) name: 2-60 letters, digits
+// and hyphens, starting and ending with a letter or digit.
+func AzureFunctionApp(name string) (string, error) { return siteName.normalize(name) }
+
+// AzureAppServicePlan returns an app service plan name of at most 40 letters,
+// digits and hyphens.
+func AzureAppServicePlan(name string) (string, error) { return planName.normalize(name) }
+
+// normalize lower-cases name and replaces or drops characters the rule does
+// not allow. A name that is already valid is returned unchanged apart from
+// case, which Azure ignores for these resources. Any other change, including
+// truncation, appends a short hash of the original name so two names that
+// normalize to the same prefix do not collide.
+func (r rule) normalize(name string) (string, error) {
+	lower := strings.ToLower(name)
+	var b strings.Builder
+	for _, c := range lower {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+			b.WriteRune(c)
+		case r.hyphens:
+			b.WriteRune('-')
+		}
+	}
+	clean := b.String()
+	if r.hyphens {
+		clean = strings.Trim(clean, "-")
+	}
+	if clean == "" {
+		return "", fmt.Errorf("%q has no characters allowed in an Azure %s name", name, r.kind)
+	}
+	if clean == lower && len(clean) <= r.max {
+		if len(clean) < r.min {
+			return "", fmt.Errorf("%q is shorter than the %d characters required for an Azure %s name", name, r.min, r.kind)
+		}
+		return clean, nil
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	suffix := hex.EncodeToString(sum[:])[:suffixLen]
+	sep := ""
+	if r.hyphens {
+		sep = "-"
+	}
+	if keep := r.max - len(sep) - suffixLen; len(clean) > keep {
+		clean = clean[:keep]
+	}
+	if r.hyphens {
+		clean = strings.TrimRight(clean, "-")
+	}
+	return clean + sep + suffix, nil
+}
@@ -0,0 +1,72 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name   string
+		fn     func(string) (string, error)
+		in     string
+		want   string
+		prefix string
+		err    bool
+	}{
+		{name: "registry valid", fn: AzureRegistry, in: "MyRegistry", want: "myregistry"},
+		{name: "registry hyphens dropped", fn: AzureRegistry, in: "my-registry", prefix: "myregistry"},
+		{name: "registry too short", fn: AzureRegistry, in: "abc", err: true},
+		{name: "registry nothing usable", fn: AzureRegistry, in: "---", err: true},
+		{name: "dns prefix valid", fn: AzureDNSPrefix, in: "prod-cluster", want: "prod-cluster"},
+		{name: "dns prefix underscores", fn: AzureDNSPrefix, in: "_prod_cluster_", prefix: "prod-cluster-"},
+		{name: "function valid", fn: AzureFunctionApp, in: "orders", want: "orders"},
+		{name: "function dots", fn: AzureFunctionApp, in: "orders.v2", prefix: "orders-v2-"},
+		{name: "plan truncated", fn: AzureAppServicePlan, in: strings.Repeat("a", 50) + "-plan", prefix: strings.Repeat("a", 33) + "-"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.fn(tc.in)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("got %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want != "" && got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if tc.prefix != "" && (!strings.HasPrefix(got, tc.prefix) || len(got) != len(tc.prefix)+suffixLen) {
+				t.Errorf("got %q, want %q plus a %d character suffix", got, tc.prefix, suffixLen)
+			}
+		})
+	}
+}
+
+func TestNormalizeLimits(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	for _, r := range []rule{registry, dnsPrefix, siteName, planName} {
+		got, err := r.normalize(long)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != r.max {
+			t.Errorf("%s: len = %d, want %d", r.kind, len(got), r.max)
+		}
+	}
+}
+
+func TestNormalizeCollisions(t *testing.T) {
+	a, _ := AzureFunctionApp("my_app")
+	b, _ := AzureFunctionApp("my.app")
+	if a == b {
+		t.Errorf("my_app and my.app both normalize to %q", a)
+	}
+	again, _ := AzureFunctionApp("my_app")
+	if a != again {
+		t.Errorf("not deterministic: %q then %q", a, again)
+	}
+}
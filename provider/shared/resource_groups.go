@@ -0,0 +1,64 @@
+package shared
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+// resourceGroups remembers the resource groups this process has created,
+// per client so that providers for different subscriptions do not share them.
+var resourceGroups = struct {
+	sync.Mutex
+	m map[resourceGroupKey]*resourceGroup
+}{m: map[resourceGroupKey]*resourceGroup{}}
+
+type resourceGroupKey struct {
+	client *armresources.ResourceGroupsClient
+	name   string
+}
+
+type resourceGroup struct {
+	// sem is held by the caller creating the group.
+	sem     chan struct{}
+	created bool
+}
+
+// EnsureResourceGroup creates the resource group name in location unless it
+// was already created through client. Resources that share a group, such as
+// abstract-rg, would otherwise all send the same create at the start of a
+// parallel apply, which ARM can reject with a conflict. Concurrent callers
+// wait for the first one; a failed create is not remembered, so the next
+// caller tries again.
+func EnsureResourceGroup(ctx context.Context, client *armresources.ResourceGroupsClient, name, location string) error {
+	g := resourceGroupFor(client, name)
+	select {
+	case g.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-g.sem }()
+	if g.created {
+		return nil
+	}
+	if _, err := client.CreateOrUpdate(ctx, name, armresources.ResourceGroup{Location: &location}, nil); err != nil {
+		return err
+	}
+	g.created = true
+	return nil
+}
+
+func resourceGroupFor(client *armresources.ResourceGroupsClient, name string) *resourceGroup {
+	resourceGroups.Lock()
+	defer resourceGroups.Unlock()
+	// resource group names are case-insensitive
+	key := resourceGroupKey{client, strings.ToLower(name)}
+	g, ok := resourceGroups.m[key]
+	if !ok {
+		g = &resourceGroup{sem: make(chan struct{}, 1)}
+		resourceGroups.m[key] = g
+	}
+	return g
+}
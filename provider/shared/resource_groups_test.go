@@ -0,0 +1,59 @@
+package shared
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+type testCredential struct{}
+
+func (testCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestEnsureResourceGroup(t *testing.T) {
+	var puts atomic.Int32
+	status := http.StatusConflict
+	client, err := armresources.NewResourceGroupsClient("sub", testCredential{}, &arm.ClientOptions{ClientOptions: policy.ClientOptions{
+		Transport: azureTransport(func(req *http.Request) *http.Response {
+			puts.Add(1)
+			// let the other callers pile up behind this one
+			time.Sleep(10 * time.Millisecond)
+			return azureResponse(req.Method, req.URL.String(), status, `{"location": "eastus"}`)
+		}),
+		Retry: policy.RetryOptions{MaxRetries: -1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureResourceGroup(context.Background(), client, "abstract-rg", "eastus"); err == nil {
+		t.Fatal("failed create reported as success")
+	}
+	status = http.StatusOK
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := EnsureResourceGroup(context.Background(), client, "Abstract-RG", "eastus"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := puts.Load(); got != 2 {
+		t.Errorf("%d creates, want the failed one and one more", got)
+	}
+	if err := EnsureResourceGroup(context.Background(), client, "abstract-dns-rg", "global"); err != nil || puts.Load() != 3 {
+		t.Errorf("other group: err = %v, creates = %d", err, puts.Load())
+	}
+}
@@ -0,0 +1,119 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// transientAWSCodes are error codes AWS returns while a just-created resource
+// is still propagating, or when requests are throttled.
+var transientAWSCodes = map[string]bool{
+	"NoSuchBucket":                      true,
+	"InvalidInstanceID.NotFound":        true,
+	"InvalidVpcID.NotFound":             true,
+	"InvalidSubnetID.NotFound":          true,
+	"InvalidInternetGatewayID.NotFound": true,
+	"Throttling":                        true,
+	"ThrottlingException":               true,
+	"RequestLimitExceeded":              true,
+	"TooManyRequestsException":          true,
+	"SlowDown":                          true,
+}
+
+// Backoff settings for RetryAWS; tests shorten them.
+var (
+	retryAttempts = 6
+	retryDelay    = 500 * time.Millisecond
+)
+
+// IsTransientAWSError reports whether err carries one of the transient AWS error codes.
+func IsTransientAWSError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && transientAWSCodes[apiErr.ErrorCode()]
+}
+
+// RetryAWS calls fn until it succeeds, returns a non-transient error, or the
+// attempts run out, doubling the delay between attempts. It is meant for
+// calls that follow a create and may race AWS eventual consistency.
+func RetryAWS(ctx context.Context, fn func() error) error {
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == retryAttempts || !IsTransientAWSError(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// DefaultMaxRetries is used when the provider does not set max_retries.
+const DefaultMaxRetries = 5
+
+// RetryTransport retries throttled and unavailable HTTP responses with
+// exponential backoff. It backs the GCP API clients, which have no retry
+// policy of their own.
+type RetryTransport struct {
+	Base       http.RoundTripper
+	MaxRetries int
+}
+
+// retryable reports whether a response status may be retried. 429 and 503
+// mean the request was not processed; other server errors are only retried
+// for requests that are safe to repeat.
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method == http.MethodGet || method == http.MethodHead
+	}
+	return false
+}
+
+// RoundTrip sends req, retrying up to MaxRetries times.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if err != nil || attempt >= t.MaxRetries || !retryable(req.Method, resp.StatusCode) {
+			return resp, err
+		}
+		// a body that cannot be replayed ends the retries
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		wait := delay
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+			wait = time.Duration(s) * time.Second
+		}
+		resp.Body.Close()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// shortRetryDelay speeds up retries for the rest of the test.
+func shortRetryDelay(t *testing.T) {
+	old := retryDelay
+	retryDelay = time.Millisecond
+	t.Cleanup(func() { retryDelay = old })
+}
+
+func TestRetryAWS(t *testing.T) {
+	shortRetryDelay(t)
+	notFound := &smithy.GenericAPIError{Code: "NoSuchBucket"}
+	denied := &smithy.GenericAPIError{Code: "AccessDenied"}
+	boom := errors.New("boom")
+
+	cases := []struct {
+		name  string
+		errs  []error
+		calls int
+		err   error
+	}{
+		{"success", nil, 1, nil},
+		{"transient then success", []error{notFound, notFound}, 3, nil},
+		{"permanent", []error{denied}, 1, denied},
+		{"plain error", []error{boom}, 1, boom},
+		{"exhausted", []error{notFound, notFound, notFound, notFound, notFound, notFound, notFound}, retryAttempts, notFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			err := RetryAWS(context.Background(), func() error {
+				calls++
+				if calls <= len(tc.errs) {
+					return tc.errs[calls-1]
+				}
+				return nil
+			})
+			if calls != tc.calls {
+				t.Errorf("calls = %d, want %d", calls, tc.calls)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Errorf("err = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestRetryAWSWrapped(t *testing.T) {
+	err := errors.Join(errors.New("versioning"), &smithy.GenericAPIError{Code: "InvalidInstanceID.NotFound"})
+	if !IsTransientAWSError(err) {
+		t.Error("wrapped transient error not detected")
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	shortRetryDelay(t)
+	cases := []struct {
+		name     string
+		method   string
+		statuses []int
+		calls    int
+		status   int
+	}{
+		{"success", http.MethodGet, nil, 1, http.StatusOK},
+		{"throttled then success", http.MethodPost, []int{429, 503}, 3, http.StatusOK},
+		{"server error on get", http.MethodGet, []int{500}, 2, http.StatusOK},
+		{"server error on post", http.MethodPost, []int{500}, 1, http.StatusInternalServerError},
+		{"client error", http.MethodGet, []int{404}, 1, http.StatusNotFound},
+		{"exhausted", http.MethodGet, []int{429, 429, 429, 429}, 3, http.StatusTooManyRequests},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if body, _ := io.ReadAll(r.Body); r.Method == http.MethodPost && string(body) != "payload" {
+					t.Errorf("attempt %d body = %q", calls, body)
+				}
+				if calls <= len(tc.statuses) {
+					w.WriteHeader(tc.statuses[calls-1])
+				}
+			}))
+			defer srv.Close()
+			client := &http.Client{Transport: &RetryTransport{MaxRetries: 2}}
+			req, err := http.NewRequest(tc.method, srv.URL, strings.NewReader("payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if calls != tc.calls || resp.StatusCode != tc.status {
+				t.Errorf("calls = %d, status = %d, want %d, %d", calls, resp.StatusCode, tc.calls, tc.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// WithTimeout derives the context for one resource operation from the
+// provider's request_timeout. A zero timeout adds no deadline. The returned
+// function releases the context and, if the deadline passed, adds an error
+// to diags so a timed-out operation is reported as such.
+func WithTimeout(ctx context.Context, timeout time.Duration, diags *diag.Diagnostics) (context.Context, func()) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			diags.AddError("request timeout", "the operation did not finish within request_timeout ("+timeout.String()+")")
+		}
+		cancel()
+	}
+}
+
+// Sleep waits for d between polls. It returns early with the context's error
+// if ctx is cancelled or its deadline passes.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,37 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+func TestWithTimeout(t *testing.T) {
+	var diags diag.Diagnostics
+	ctx, done := WithTimeout(context.Background(), 0, &diags)
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("zero timeout set a deadline")
+	}
+	done()
+
+	ctx, done = WithTimeout(context.Background(), time.Hour, &diags)
+	if err := Sleep(ctx, time.Millisecond); err != nil {
+		t.Errorf("Sleep = %v", err)
+	}
+	done()
+	if diags.HasError() {
+		t.Errorf("finished operation reported %v", diags)
+	}
+
+	ctx, done = WithTimeout(context.Background(), time.Millisecond, &diags)
+	if err := Sleep(ctx, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Sleep = %v, want deadline exceeded", err)
+	}
+	done()
+	if diags.ErrorsCount() != 1 || diags[0].Summary() != "request timeout" {
+		t.Errorf("diagnostics = %v", diags)
+	}
+}